@@ -8,6 +8,7 @@ import (
 	"testing"
 
 	"github.com/DO-Solutions/terraform-provider-docidr/docidr"
+	"github.com/DO-Solutions/terraform-provider-docidr/docidr/config"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/acctest"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
@@ -39,10 +40,37 @@ func init() {
 	}
 }
 
+// DefaultCassette is the cassette used when config.CassetteEnvVar isn't set,
+// relative to the directory of the package being tested.
+const DefaultCassette = "testdata/acceptance_cassette.json"
+
+// replayToken is the token used while replaying a cassette. It is never sent
+// to the API.
+const replayToken = "docidr-cassette-replay"
+
 // TestAccPreCheck validates the necessary test API keys exist in the environment.
+// Without a token, the tests replay the responses recorded in the cassette
+// instead, and fail only if there is no cassette. With DOCIDR_RECORD=1, the
+// responses from the API are recorded to the cassette.
 func TestAccPreCheck(t *testing.T) {
-	if os.Getenv("DIGITALOCEAN_TOKEN") == "" && os.Getenv("DIGITALOCEAN_ACCESS_TOKEN") == "" {
-		t.Fatal("DIGITALOCEAN_TOKEN or DIGITALOCEAN_ACCESS_TOKEN must be set for acceptance tests")
+	hasToken := os.Getenv("DIGITALOCEAN_TOKEN") != "" || os.Getenv("DIGITALOCEAN_ACCESS_TOKEN") != ""
+	cassette := os.Getenv(config.CassetteEnvVar)
+	if cassette == "" {
+		cassette = DefaultCassette
+	}
+
+	switch {
+	case os.Getenv(config.RecordEnvVar) == "1":
+		if !hasToken {
+			t.Fatalf("DIGITALOCEAN_TOKEN or DIGITALOCEAN_ACCESS_TOKEN must be set to record %s", cassette)
+		}
+		os.Setenv(config.CassetteEnvVar, cassette)
+	case !hasToken:
+		if _, err := os.Stat(cassette); err != nil {
+			t.Fatalf("DIGITALOCEAN_TOKEN or DIGITALOCEAN_ACCESS_TOKEN must be set for acceptance tests without a cassette: %s", err)
+		}
+		os.Setenv(config.CassetteEnvVar, cassette)
+		os.Setenv("DIGITALOCEAN_TOKEN", replayToken)
 	}
 
 	err := TestAccProvider.Configure(context.Background(), terraform.NewResourceConfigRaw(nil))
@@ -0,0 +1,116 @@
+package acceptance
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/DO-Solutions/terraform-provider-docidr/docidr/cidr"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+// CheckAllocationWithinCIDR returns a check that the CIDR in attribute attr of
+// resourceName lies entirely within base.
+func CheckAllocationWithinCIDR(resourceName, attr, base string) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		network, err := attributeCIDR(s, resourceName, attr)
+		if err != nil {
+			return err
+		}
+		baseNetwork, err := cidr.ParseCIDR(base)
+		if err != nil {
+			return fmt.Errorf("invalid base CIDR: %w", err)
+		}
+
+		if !cidr.ContainsNetwork(baseNetwork, network) {
+			return fmt.Errorf("%s: attribute %s is %s, which is not within %s", resourceName, attr, network, baseNetwork)
+		}
+		return nil
+	}
+}
+
+// CheckAllocationsDoNotOverlap returns a check that no two CIDRs in the map or
+// list attribute attrPrefix of resourceName, such as "allocations", overlap.
+func CheckAllocationsDoNotOverlap(resourceName, attrPrefix string) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		attributes, err := primaryAttributes(s, resourceName)
+		if err != nil {
+			return err
+		}
+
+		prefix := strings.TrimSuffix(attrPrefix, ".") + "."
+		var keys []string
+		for key := range attributes {
+			if strings.HasPrefix(key, prefix) && key != prefix+"%" && key != prefix+"#" {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+
+		networks := make([]*net.IPNet, len(keys))
+		for i, key := range keys {
+			networks[i], err = cidr.ParseCIDR(attributes[key])
+			if err != nil {
+				return fmt.Errorf("%s: attribute %s: %w", resourceName, key, err)
+			}
+		}
+
+		for i := range networks {
+			for j := i + 1; j < len(networks); j++ {
+				if cidr.Classify(networks[i], networks[j]) != cidr.RelationshipNone {
+					return fmt.Errorf("%s: %s (%s) overlaps %s (%s)", resourceName, keys[i], networks[i], keys[j], networks[j])
+				}
+			}
+		}
+		return nil
+	}
+}
+
+// CheckAllocationHasPrefixLength returns a check that the CIDR in attribute
+// attr of resourceName has the given prefix length.
+func CheckAllocationHasPrefixLength(resourceName, attr string, prefix int) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		network, err := attributeCIDR(s, resourceName, attr)
+		if err != nil {
+			return err
+		}
+
+		if ones, _ := network.Mask.Size(); ones != prefix {
+			return fmt.Errorf("%s: attribute %s is %s, want prefix length /%d", resourceName, attr, network, prefix)
+		}
+		return nil
+	}
+}
+
+// primaryAttributes returns the attributes of the primary instance of
+// resourceName in the root module.
+func primaryAttributes(s *terraform.State, resourceName string) (map[string]string, error) {
+	rs, ok := s.RootModule().Resources[resourceName]
+	if !ok {
+		return nil, fmt.Errorf("Not found: %s", resourceName)
+	}
+	if rs.Primary == nil {
+		return nil, fmt.Errorf("No primary instance: %s", resourceName)
+	}
+	return rs.Primary.Attributes, nil
+}
+
+// attributeCIDR parses attribute attr of resourceName as a CIDR.
+func attributeCIDR(s *terraform.State, resourceName, attr string) (*net.IPNet, error) {
+	attributes, err := primaryAttributes(s, resourceName)
+	if err != nil {
+		return nil, err
+	}
+
+	value, ok := attributes[attr]
+	if !ok {
+		return nil, fmt.Errorf("%s: attribute %s not found", resourceName, attr)
+	}
+	network, err := cidr.ParseCIDR(value)
+	if err != nil {
+		return nil, fmt.Errorf("%s: attribute %s: %w", resourceName, attr, err)
+	}
+	return network, nil
+}
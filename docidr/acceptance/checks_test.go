@@ -0,0 +1,66 @@
+package acceptance
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+// testState returns a state holding docidr_pool.test with the given
+// attributes.
+func testState(attributes map[string]string) *terraform.State {
+	s := terraform.NewState()
+	s.RootModule().Resources["docidr_pool.test"] = &terraform.ResourceState{
+		Type:    "docidr_pool",
+		Primary: &terraform.InstanceState{ID: "pool", Attributes: attributes},
+	}
+	return s
+}
+
+func TestChecks(t *testing.T) {
+	state := testState(map[string]string{
+		"allocations.%":   "3",
+		"allocations.vpc": "10.0.0.0/16",
+		"allocations.k8s": "10.1.0.0/20",
+		"allocations.svc": "10.1.16.0/20",
+	})
+	overlapping := testState(map[string]string{
+		"allocations.%":   "2",
+		"allocations.vpc": "10.0.0.0/16",
+		"allocations.k8s": "10.0.16.0/20",
+	})
+
+	tests := []struct {
+		name    string
+		check   resource.TestCheckFunc
+		state   *terraform.State
+		wantErr string
+	}{
+		{name: "within", check: CheckAllocationWithinCIDR("docidr_pool.test", "allocations.k8s", "10.0.0.0/8"), state: state},
+		{name: "within itself", check: CheckAllocationWithinCIDR("docidr_pool.test", "allocations.vpc", "10.0.0.0/16"), state: state},
+		{name: "not within", check: CheckAllocationWithinCIDR("docidr_pool.test", "allocations.k8s", "10.0.0.0/16"), state: state, wantErr: "is 10.1.0.0/20, which is not within 10.0.0.0/16"},
+		{name: "missing attribute", check: CheckAllocationWithinCIDR("docidr_pool.test", "allocations.db", "10.0.0.0/8"), state: state, wantErr: "attribute allocations.db not found"},
+		{name: "missing resource", check: CheckAllocationWithinCIDR("docidr_pool.other", "allocations.vpc", "10.0.0.0/8"), state: state, wantErr: "Not found: docidr_pool.other"},
+		{name: "no overlap", check: CheckAllocationsDoNotOverlap("docidr_pool.test", "allocations"), state: state},
+		{name: "overlap", check: CheckAllocationsDoNotOverlap("docidr_pool.test", "allocations."), state: overlapping, wantErr: "allocations.k8s (10.0.16.0/20) overlaps allocations.vpc (10.0.0.0/16)"},
+		{name: "prefix length", check: CheckAllocationHasPrefixLength("docidr_pool.test", "allocations.k8s", 20), state: state},
+		{name: "wrong prefix length", check: CheckAllocationHasPrefixLength("docidr_pool.test", "allocations.vpc", 20), state: state, wantErr: "is 10.0.0.0/16, want prefix length /20"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.check(tt.state)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("check error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("check error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -1,20 +1,121 @@
 package cidr
 
 import (
+	"crypto/sha256"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"net"
 )
 
+// ErrNoSpace is returned, wrapped, when the base CIDR has no free block large
+// enough for a request.
+var ErrNoSpace = errors.New("no available space")
+
+// AllocationError is returned by Allocate when a request has no room in its
+// base CIDR.
+type AllocationError struct {
+	Name         string
+	PrefixLength int
+	// BaseCIDR is the range the request was allocated from.
+	BaseCIDR *net.IPNet
+	// OwnBase is set when BaseCIDR is the request's own rather than the
+	// allocator's.
+	OwnBase bool
+	Err     error
+}
+
+func (e *AllocationError) Error() string {
+	if e.OwnBase {
+		return fmt.Sprintf("failed to allocate CIDR for %q (/%d) from its own base CIDR: %v", e.Name, e.PrefixLength, e.Err)
+	}
+	return fmt.Sprintf("failed to allocate CIDR for %q (/%d): %v", e.Name, e.PrefixLength, e.Err)
+}
+
+// Unwrap returns the underlying error, or nil for a request's own base CIDR,
+// as widening the allocator's base wouldn't help it.
+func (e *AllocationError) Unwrap() error {
+	if e.OwnBase {
+		return nil
+	}
+	return e.Err
+}
+
+// PrefixLengthError is returned when a request's prefix length is shorter
+// than that of the base CIDR it is allocated from, so the block can't fit.
+type PrefixLengthError struct {
+	Name             string
+	PrefixLength     int
+	BasePrefixLength int
+}
+
+func (e *PrefixLengthError) Error() string {
+	return fmt.Sprintf("requested prefix length /%d for %q is smaller than base CIDR prefix /%d", e.PrefixLength, e.Name, e.BasePrefixLength)
+}
+
+// ReservationError is returned when a reservation's CIDR block can't be given
+// to the allocation it names.
+type ReservationError struct {
+	Name string
+	Err  error
+}
+
+func (e *ReservationError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ReservationError) Unwrap() error {
+	return e.Err
+}
+
 // AllocationRequest represents a request to allocate a CIDR block.
 type AllocationRequest struct {
 	Name         string
 	PrefixLength int
+	// Region is the region the allocation is intended for. It doesn't
+	// affect where the block is allocated.
+	Region string
+	// Group is the name of the group the allocation is organized under. It
+	// doesn't affect where the block is allocated.
+	Group string
+	// Hub marks the allocation as the hub of GenerateRouteTable. It doesn't
+	// affect where the block is allocated.
+	Hub bool
+	// Visibility is VisibilityInternal, VisibilityExternal or empty, which
+	// is internal. It doesn't affect where the block is allocated;
+	// see CheckVisibility.
+	Visibility string
+	// Exclusions are avoided when placing this request only, in addition
+	// to the exclusions that apply to every request.
+	Exclusions []*net.IPNet
+	// BaseCIDR, if set, is the range this request is allocated from instead
+	// of the allocator's base. It doesn't need to be within the allocator's
+	// base.
+	BaseCIDR *net.IPNet
 }
 
+// ReservationRequest pins an allocation to a specific CIDR block.
+type ReservationRequest struct {
+	Name string
+	CIDR *net.IPNet
+}
+
+// Placement strategies, deciding where in the base CIDR each block is looked
+// for first.
+const (
+	// PlacementFirstFit allocates the lowest free block.
+	PlacementFirstFit = "first_fit"
+	// PlacementRandom starts each request at a pseudo-random block derived
+	// from a seed and the request's name, and takes the first free block
+	// from there, wrapping around to the start of the base CIDR.
+	PlacementRandom = "random"
+)
+
 // Allocator handles CIDR block allocation within a base range.
 type Allocator struct {
 	baseCIDR *net.IPNet
+	// seed enables random placement when set.
+	seed []byte
 }
 
 // NewAllocator creates a new CIDR allocator for the given base CIDR.
@@ -29,9 +130,55 @@ func NewAllocator(baseCIDR string) (*Allocator, error) {
 	}, nil
 }
 
+// BaseCIDR returns the base network allocations are made from.
+func (a *Allocator) BaseCIDR() *net.IPNet {
+	return a.baseCIDR
+}
+
+// WithRandomPlacement returns an allocator for the same base CIDR that uses
+// PlacementRandom, starting each request at a block derived from seed and the
+// request's name. The same seed always places the same requests in the same
+// blocks, given the same exclusions.
+func (a *Allocator) WithRandomPlacement(seed []byte) *Allocator {
+	return &Allocator{baseCIDR: a.baseCIDR, seed: seed}
+}
+
+// Placement returns the allocator's placement strategy.
+func (a *Allocator) Placement() string {
+	if a.seed != nil {
+		return PlacementRandom
+	}
+	return PlacementFirstFit
+}
+
+// Expanded returns an allocator with the same placement for the supernet one
+// bit wider than the base CIDR, as ExpandCIDR returns.
+func (a *Allocator) Expanded() (*Allocator, error) {
+	expanded, err := ExpandCIDR(a.baseCIDR)
+	if err != nil {
+		return nil, err
+	}
+	return &Allocator{baseCIDR: expanded, seed: a.seed}, nil
+}
+
+// forRequest returns the allocator for req's own BaseCIDR, or a if it has
+// none.
+func (a *Allocator) forRequest(req AllocationRequest) *Allocator {
+	if req.BaseCIDR == nil {
+		return a
+	}
+	return &Allocator{baseCIDR: req.BaseCIDR, seed: a.seed}
+}
+
 // Allocate finds available CIDR blocks for each request, avoiding the given exclusions.
 // Allocations are made sequentially, with each new allocation added to the exclusion
-// list before processing the next request.
+// list before processing the next request. A request's own Exclusions are only
+// avoided when placing that request, so a later request without them may take
+// the space it skipped. A request with its own BaseCIDR is allocated from it,
+// avoiding the same exclusions and allocations as every other request; when it
+// has no room the error doesn't wrap ErrNoSpace, as widening the allocator's
+// base wouldn't help. A request without room is reported as an
+// *AllocationError. A nil or empty exclusions slice means no exclusions.
 func (a *Allocator) Allocate(requests []AllocationRequest, exclusions []*net.IPNet) (map[string]string, error) {
 	results := make(map[string]string)
 
@@ -40,16 +187,27 @@ func (a *Allocator) Allocate(requests []AllocationRequest, exclusions []*net.IPN
 	copy(usedBlocks, exclusions)
 
 	for _, req := range requests {
+		base := a.forRequest(req)
+
 		// Validate prefix length is within base CIDR
-		basePrefixLen, _ := a.baseCIDR.Mask.Size()
+		basePrefixLen, _ := base.baseCIDR.Mask.Size()
 		if req.PrefixLength < basePrefixLen {
-			return nil, fmt.Errorf("requested prefix length /%d for %q is smaller than base CIDR prefix /%d",
-				req.PrefixLength, req.Name, basePrefixLen)
+			return nil, &PrefixLengthError{Name: req.Name, PrefixLength: req.PrefixLength, BasePrefixLength: basePrefixLen}
 		}
 
-		allocated, err := a.findAvailableBlock(req.PrefixLength, usedBlocks)
+		blocked := usedBlocks
+		if len(req.Exclusions) > 0 {
+			blocked = append(append([]*net.IPNet{}, usedBlocks...), req.Exclusions...)
+		}
+		allocated, err := base.findAvailableBlock(req.PrefixLength, blocked, req.Name)
 		if err != nil {
-			return nil, fmt.Errorf("failed to allocate CIDR for %q (/%d): %w", req.Name, req.PrefixLength, err)
+			return nil, &AllocationError{
+				Name:         req.Name,
+				PrefixLength: req.PrefixLength,
+				BaseCIDR:     base.baseCIDR,
+				OwnBase:      base != a,
+				Err:          err,
+			}
 		}
 
 		results[req.Name] = allocated.String()
@@ -60,33 +218,85 @@ func (a *Allocator) Allocate(requests []AllocationRequest, exclusions []*net.IPN
 }
 
 // findAvailableBlock finds the first available CIDR block of the given prefix length
-// that doesn't overlap with any of the exclusions.
-func (a *Allocator) findAvailableBlock(prefixLen int, exclusions []*net.IPNet) (*net.IPNet, error) {
-	// Create mask for the requested prefix length
-	mask := net.CIDRMask(prefixLen, 32)
-
+// that doesn't overlap with any of the exclusions. Overlap works in both
+// directions: a candidate inside an excluded supernet is skipped, and so is a
+// candidate containing any excluded subnet, however small. A /16 request with
+// only 10.0.0.128/25 excluded therefore skips all of 10.0.0.0/16.
+//
+// With random placement the search starts at the block picked for name and
+// wraps around to the start of the base CIDR, so every block is still tried
+// before reporting that there is no space.
+func (a *Allocator) findAvailableBlock(prefixLen int, exclusions []*net.IPNet, name string) (*net.IPNet, error) {
 	// Start from the beginning of the base CIDR
 	currentIP := a.baseCIDR.IP.Mask(a.baseCIDR.Mask)
 
-	// Calculate the block size for the requested prefix
-	blockSize := uint32(1) << (32 - prefixLen)
+	// Calculate the block size for the requested prefix. The arithmetic is
+	// done in uint64, as a block at the top of the address space ends at
+	// 2^32 and a /0 base or block holds 2^32 addresses.
+	blockSize := uint64(1) << (32 - prefixLen)
 
-	// Convert base CIDR boundaries to uint32 for easier math
-	baseStart := ipToUint32(a.baseCIDR.IP.Mask(a.baseCIDR.Mask))
+	// Convert base CIDR boundaries to uint64 for easier math
+	baseStart := uint64(ipToUint32(a.baseCIDR.IP.Mask(a.baseCIDR.Mask)))
 	basePrefixLen, _ := a.baseCIDR.Mask.Size()
-	baseEnd := baseStart + (uint32(1) << (32 - basePrefixLen))
+	baseEnd := baseStart + (uint64(1) << (32 - basePrefixLen))
 
-	// Start scanning from the beginning
-	candidateStart := baseStart
+	// Ignore exclusions entirely outside the base CIDR. An exclusion that starts
+	// before the base but still overlaps it, such as a supernet, is kept.
+	inBase := NetworksContainedBy(a.baseCIDR, exclusions)
+	for _, exclusion := range NetworksOutside(a.baseCIDR, exclusions) {
+		if ContainsNetwork(exclusion, a.baseCIDR) {
+			inBase = append(inBase, exclusion)
+		}
+	}
+	exclusions = inBase
 
 	// Align to block boundary
-	if candidateStart%blockSize != 0 {
-		candidateStart = ((candidateStart / blockSize) + 1) * blockSize
+	alignedStart := baseStart
+	if alignedStart%blockSize != 0 {
+		alignedStart = ((alignedStart / blockSize) + 1) * blockSize
+	}
+
+	start := alignedStart
+	if a.seed != nil && alignedStart+blockSize <= baseEnd {
+		blocks := (baseEnd - alignedStart) / blockSize
+		start = alignedStart + a.randomBlock(name, blocks)*blockSize
+		currentIP = uint32ToIP(uint32(start))
+	}
+
+	if candidate := scanForBlock(start, baseEnd, prefixLen, exclusions); candidate != nil {
+		return candidate, nil
+	}
+	if start != alignedStart {
+		// Wrap around to the blocks before the starting block
+		if candidate := scanForBlock(alignedStart, start, prefixLen, exclusions); candidate != nil {
+			return candidate, nil
+		}
 	}
 
-	for candidateStart+blockSize <= baseEnd {
+	return nil, fmt.Errorf("%w for /%d block in %s (tried from %s)",
+		ErrNoSpace, prefixLen, a.baseCIDR.String(), currentIP.String())
+}
+
+// randomBlock returns the index, below blocks, of the block the search for
+// name starts at.
+func (a *Allocator) randomBlock(name string, blocks uint64) uint64 {
+	h := sha256.New()
+	h.Write(a.seed)
+	h.Write([]byte{0})
+	h.Write([]byte(name))
+	return binary.BigEndian.Uint64(h.Sum(nil)[:8]) % blocks
+}
+
+// scanForBlock returns the first block of the given prefix length that starts
+// at or after candidateStart, ends at or before end and doesn't overlap any
+// exclusion. candidateStart must be aligned to the block size. It returns nil
+// if there is none.
+func scanForBlock(candidateStart, end uint64, prefixLen int, exclusions []*net.IPNet) *net.IPNet {
+	mask := net.CIDRMask(prefixLen, 32)
+	blockSize := uint64(1) << (32 - prefixLen)
+	for candidateStart+blockSize <= end {
 		candidate := &net.IPNet{
-			IP:   uint32ToIP(candidateStart),
+			IP:   uint32ToIP(uint32(candidateStart)),
 			Mask: mask,
 		}
 
@@ -96,11 +306,13 @@ func (a *Allocator) findAvailableBlock(prefixLen int, exclusions []*net.IPNet) (
 			if networksOverlap(candidate, exclusion) {
 				overlaps = true
 				// Skip past the overlapping exclusion
-				exclStart := ipToUint32(exclusion.IP.Mask(exclusion.Mask))
+				exclStart := uint64(ipToUint32(exclusion.IP.Mask(exclusion.Mask)))
 				exclPrefixLen, _ := exclusion.Mask.Size()
-				exclEnd := exclStart + (uint32(1) << (32 - exclPrefixLen))
+				exclEnd := exclStart + (uint64(1) << (32 - exclPrefixLen))
 
-				// Move candidate past the exclusion, aligned to block boundary
+				// Move candidate past the exclusion, aligned to block boundary.
+				// The new candidate is rechecked against every exclusion on the
+				// next iteration, including those earlier in the list.
 				candidateStart = exclEnd
 				if candidateStart%blockSize != 0 {
 					candidateStart = ((candidateStart / blockSize) + 1) * blockSize
@@ -110,12 +322,145 @@ func (a *Allocator) findAvailableBlock(prefixLen int, exclusions []*net.IPNet) (
 		}
 
 		if !overlaps {
-			return candidate, nil
+			return candidate
 		}
 	}
+	return nil
+}
+
+// AllocateWithReservations assigns each reservation its CIDR block, then
+// allocates the remaining requests as Allocate does, avoiding the exclusions
+// and the reserved blocks. Requests named by a reservation are skipped.
+// Reserved blocks must lie within the base CIDR, or the BaseCIDR of the
+// request with the same name, and must not overlap each other, but are not
+// checked against the exclusions.
+func (a *Allocator) AllocateWithReservations(reservations []ReservationRequest, requests []AllocationRequest, exclusions []*net.IPNet) (map[string]string, error) {
+	bases := make(map[string]*net.IPNet, len(requests))
+	for _, req := range requests {
+		bases[req.Name] = a.forRequest(req).baseCIDR
+	}
+
+	results := make(map[string]string)
+	var reserved []*net.IPNet
+	for _, r := range reservations {
+		base, ok := bases[r.Name]
+		if !ok {
+			base = a.baseCIDR
+		}
+		if !ContainsNetwork(base, r.CIDR) {
+			return nil, &ReservationError{Name: r.Name, Err: fmt.Errorf("reserved CIDR %s for %q is outside base CIDR %s", r.CIDR.String(), r.Name, base.String())}
+		}
+		for _, other := range reserved {
+			if networksOverlap(r.CIDR, other) {
+				return nil, &ReservationError{Name: r.Name, Err: fmt.Errorf("reserved CIDR %s for %q overlaps reserved CIDR %s", r.CIDR.String(), r.Name, other.String())}
+			}
+		}
+		reserved = append(reserved, r.CIDR)
+		results[r.Name] = r.CIDR.String()
+	}
+
+	var remaining []AllocationRequest
+	for _, req := range requests {
+		if _, ok := results[req.Name]; !ok {
+			remaining = append(remaining, req)
+		}
+	}
+
+	allocated, err := a.Allocate(remaining, append(append([]*net.IPNet{}, exclusions...), reserved...))
+	if err != nil {
+		return nil, err
+	}
+	for name, block := range allocated {
+		results[name] = block
+	}
+	return results, nil
+}
 
-	return nil, fmt.Errorf("no available space for /%d block in %s (tried from %s)",
-		prefixLen, a.baseCIDR.String(), currentIP.String())
+// MovedAllocation records why a request couldn't keep its previous block.
+type MovedAllocation struct {
+	Name     string
+	Previous string
+	Reason   string
+}
+
+// PreferPrevious returns reservations keeping each request's previous block,
+// for AllocateWithReservations, so that re-allocating only moves the requests
+// whose blocks are no longer available. A previous block is kept if it is the
+// same size as the request, lies within the request's base CIDR and doesn't
+// overlap the exclusions, the request's own exclusions or a block kept for an
+// earlier request. Requests without a previous block are left out of both
+// results; the rest are returned in moved, in request order, with the reason.
+func (a *Allocator) PreferPrevious(previous map[string]string, requests []AllocationRequest, exclusions []*net.IPNet) ([]ReservationRequest, []MovedAllocation) {
+	var kept []ReservationRequest
+	var moved []MovedAllocation
+	for _, req := range requests {
+		prev, ok := previous[req.Name]
+		if !ok {
+			continue
+		}
+		reason := a.previousUnavailable(prev, req, exclusions, kept)
+		if reason != "" {
+			moved = append(moved, MovedAllocation{Name: req.Name, Previous: prev, Reason: reason})
+			continue
+		}
+		network, _ := ParseCIDR(prev)
+		kept = append(kept, ReservationRequest{Name: req.Name, CIDR: network})
+	}
+	return kept, moved
+}
+
+// previousUnavailable returns why req can't keep its previous block prev, or
+// "" if it can.
+func (a *Allocator) previousUnavailable(prev string, req AllocationRequest, exclusions []*net.IPNet, kept []ReservationRequest) string {
+	network, err := ParseCIDR(prev)
+	if err != nil {
+		return "it is not a valid CIDR"
+	}
+	if ones, _ := network.Mask.Size(); ones != req.PrefixLength {
+		return fmt.Sprintf("the request is now a /%d", req.PrefixLength)
+	}
+	if base := a.forRequest(req).baseCIDR; !ContainsNetwork(base, network) {
+		return fmt.Sprintf("it is outside base CIDR %s", base.String())
+	}
+	for _, exclusion := range append(append([]*net.IPNet{}, exclusions...), req.Exclusions...) {
+		if networksOverlap(network, exclusion) {
+			return fmt.Sprintf("it overlaps exclusion %s", exclusion.String())
+		}
+	}
+	for _, other := range kept {
+		if networksOverlap(network, other.CIDR) {
+			return fmt.Sprintf("it overlaps %s, kept for %q", other.CIDR.String(), other.Name)
+		}
+	}
+	return ""
+}
+
+// NextFree returns the first free block of the given prefix length in base,
+// avoiding the exclusions. It places the block exactly where Allocate would
+// place a single request, so allocating blocks one at a time, adding each
+// result to the exclusions, matches allocating them together.
+func NextFree(base string, prefixLength int, exclusions []*net.IPNet) (*net.IPNet, error) {
+	allocator, err := NewAllocator(base)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := allocator.Allocate([]AllocationRequest{{Name: "next_free", PrefixLength: prefixLength}}, exclusions)
+	if err != nil {
+		return nil, err
+	}
+	return ParseCIDR(results["next_free"])
+}
+
+// ExpandCIDR returns the supernet one bit wider than base that contains it,
+// e.g. 10.0.0.0/8 -> 10.0.0.0/7.
+func ExpandCIDR(base *net.IPNet) (*net.IPNet, error) {
+	ones, bits := base.Mask.Size()
+	if ones == 0 {
+		return nil, fmt.Errorf("cannot expand %s: already the whole address space", base.String())
+	}
+	mask := net.CIDRMask(ones-1, bits)
+	return &net.IPNet{IP: base.IP.Mask(mask), Mask: mask}, nil
 }
 
 // networksOverlap returns true if two CIDR blocks overlap.
@@ -1,6 +1,8 @@
 package cidr
 
 import (
+	"errors"
+	"fmt"
 	"net"
 	"testing"
 )
@@ -183,6 +185,9 @@ func TestAllocator_Allocate_ExhaustedSpace(t *testing.T) {
 	if err == nil {
 		t.Error("Allocate() should have returned an error for exhausted space")
 	}
+	if !errors.Is(err, ErrNoSpace) {
+		t.Errorf("Allocate() error = %v, want ErrNoSpace", err)
+	}
 }
 
 func TestAllocator_Allocate_PrefixTooSmall(t *testing.T) {
@@ -198,7 +203,14 @@ func TestAllocator_Allocate_PrefixTooSmall(t *testing.T) {
 
 	_, err = allocator.Allocate(requests, nil)
 	if err == nil {
-		t.Error("Allocate() should have returned an error for prefix smaller than base")
+		t.Fatal("Allocate() should have returned an error for prefix smaller than base")
+	}
+	var prefixErr *PrefixLengthError
+	if !errors.As(err, &prefixErr) || prefixErr.Name != "too_big" || prefixErr.BasePrefixLength != 16 {
+		t.Errorf("Allocate() error = %#v, want a *PrefixLengthError for too_big", err)
+	}
+	if want := `requested prefix length /8 for "too_big" is smaller than base CIDR prefix /16`; err.Error() != want {
+		t.Errorf("Allocate() error = %q, want %q", err.Error(), want)
 	}
 }
 
@@ -258,6 +270,200 @@ func TestAllocator_Allocate_SkipPartialOverlap(t *testing.T) {
 	}
 }
 
+func TestAllocator_Allocate_LargeRequestSmallExclusion(t *testing.T) {
+	allocator, err := NewAllocator("10.0.0.0/8")
+	if err != nil {
+		t.Fatalf("NewAllocator() error = %v", err)
+	}
+
+	// The exclusion is far smaller than the request and doesn't start at the
+	// beginning of the first candidate
+	exclusions := []*net.IPNet{
+		mustParseCIDR("10.0.0.128/25"),
+	}
+
+	requests := []AllocationRequest{
+		{Name: "vpc", PrefixLength: 16},
+		{Name: "subnet", PrefixLength: 24},
+		{Name: "small", PrefixLength: 25},
+	}
+
+	results, err := allocator.Allocate(requests, exclusions)
+	if err != nil {
+		t.Fatalf("Allocate() error = %v", err)
+	}
+
+	// The whole of 10.0.0.0/16 is skipped because it contains the exclusion.
+	// Smaller requests still use the rest of it.
+	want := map[string]string{
+		"vpc":    "10.1.0.0/16",
+		"subnet": "10.0.1.0/24",
+		"small":  "10.0.0.0/25",
+	}
+	for name, cidr := range want {
+		if results[name] != cidr {
+			t.Errorf("%s = %v, want %v", name, results[name], cidr)
+		}
+	}
+}
+
+func TestAllocator_Allocate_RecheckEarlierExclusions(t *testing.T) {
+	allocator, err := NewAllocator("10.0.0.0/16")
+	if err != nil {
+		t.Fatalf("NewAllocator() error = %v", err)
+	}
+
+	// Exclusions are listed out of address order, so advancing past one
+	// exclusion lands the candidate on an exclusion earlier in the list.
+	exclusions := []*net.IPNet{
+		mustParseCIDR("10.0.2.0/24"),
+		mustParseCIDR("10.0.1.0/24"),
+		mustParseCIDR("10.0.0.128/25"),
+	}
+
+	requests := []AllocationRequest{
+		{Name: "subnet", PrefixLength: 24},
+	}
+
+	results, err := allocator.Allocate(requests, exclusions)
+	if err != nil {
+		t.Fatalf("Allocate() error = %v", err)
+	}
+
+	// 10.0.0.0/24 overlaps 10.0.0.128/25, and the next candidates overlap the
+	// earlier exclusions 10.0.1.0/24 and 10.0.2.0/24 in turn.
+	if results["subnet"] != "10.0.3.0/24" {
+		t.Errorf("subnet = %v, want 10.0.3.0/24", results["subnet"])
+	}
+
+	for _, exclusion := range exclusions {
+		if networksOverlap(mustParseCIDR(results["subnet"]), exclusion) {
+			t.Errorf("subnet %s overlaps exclusion %s", results["subnet"], exclusion)
+		}
+	}
+}
+
+func TestAllocator_Allocate_LargeAddressSpace(t *testing.T) {
+	allocator, err := NewAllocator("0.0.0.0/1")
+	if err != nil {
+		t.Fatalf("NewAllocator() error = %v", err)
+	}
+
+	results, err := allocator.Allocate([]AllocationRequest{{Name: "subnet", PrefixLength: 24}}, nil)
+	if err != nil {
+		t.Fatalf("Allocate() error = %v", err)
+	}
+	if results["subnet"] != "0.0.0.0/24" {
+		t.Errorf("subnet = %v, want 0.0.0.0/24", results["subnet"])
+	}
+}
+
+func TestAllocator_Allocate_TopOfAddressSpace(t *testing.T) {
+	tests := []struct {
+		name         string
+		base         string
+		prefixLength int
+		exclusions   []string
+		want         string
+	}{
+		{name: "/0 base", base: "0.0.0.0/0", prefixLength: 24, want: "0.0.0.0/24"},
+		{name: "/0 base, /0 block", base: "0.0.0.0/0", prefixLength: 0, want: "0.0.0.0/0"},
+		{name: "/0 base past exclusion", base: "0.0.0.0/0", prefixLength: 8, exclusions: []string{"0.0.0.0/2"}, want: "64.0.0.0/8"},
+		{name: "lower /1 base", base: "0.0.0.0/1", prefixLength: 16, exclusions: []string{"0.0.0.0/9"}, want: "0.128.0.0/16"},
+		{name: "upper /1 base", base: "128.0.0.0/1", prefixLength: 24, want: "128.0.0.0/24"},
+		{name: "upper /1 base, /1 block", base: "128.0.0.0/1", prefixLength: 1, want: "128.0.0.0/1"},
+		{name: "top /2 base", base: "192.0.0.0/2", prefixLength: 16, want: "192.0.0.0/16"},
+		{name: "top /2 base, last block", base: "192.0.0.0/2", prefixLength: 3, exclusions: []string{"192.0.0.0/3"}, want: "224.0.0.0/3"},
+		{name: "last /24", base: "255.255.255.0/24", prefixLength: 25, exclusions: []string{"255.255.255.0/25"}, want: "255.255.255.128/25"},
+		{name: "top fully excluded", base: "255.255.255.0/24", prefixLength: 25, exclusions: []string{"255.255.255.0/25", "255.255.255.128/25"}},
+		{name: "/0 fully excluded", base: "0.0.0.0/0", prefixLength: 1, exclusions: []string{"0.0.0.0/0"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			allocator, err := NewAllocator(tt.base)
+			if err != nil {
+				t.Fatalf("NewAllocator() error = %v", err)
+			}
+
+			var exclusions []*net.IPNet
+			for _, e := range tt.exclusions {
+				exclusions = append(exclusions, mustParseCIDR(e))
+			}
+
+			results, err := allocator.Allocate([]AllocationRequest{{Name: "block", PrefixLength: tt.prefixLength}}, exclusions)
+			if tt.want == "" {
+				if !errors.Is(err, ErrNoSpace) {
+					t.Errorf("Allocate() = %v, %v, want ErrNoSpace", results, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Allocate() error = %v", err)
+			}
+			if results["block"] != tt.want {
+				t.Errorf("block = %v, want %v", results["block"], tt.want)
+			}
+		})
+	}
+}
+
+func TestAllocator_ExclusionOutsideBase(t *testing.T) {
+	tests := []struct {
+		name       string
+		base       string
+		exclusions []string
+		want       string
+	}{
+		{
+			name:       "exclusion in another private range",
+			base:       "10.0.0.0/8",
+			exclusions: []string{"192.168.0.0/16"},
+			want:       "10.0.0.0/16",
+		},
+		{
+			name:       "exclusion after the base at the top of the address space",
+			base:       "10.0.0.0/8",
+			exclusions: []string{"255.255.255.0/24"},
+			want:       "10.0.0.0/16",
+		},
+		{
+			name:       "exclusion starting before the base that covers part of it",
+			base:       "10.1.0.0/16",
+			exclusions: []string{"10.0.0.0/15"},
+			want:       "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			allocator, err := NewAllocator(tt.base)
+			if err != nil {
+				t.Fatalf("NewAllocator() error = %v", err)
+			}
+
+			var exclusions []*net.IPNet
+			for _, e := range tt.exclusions {
+				exclusions = append(exclusions, mustParseCIDR(e))
+			}
+
+			results, err := allocator.Allocate([]AllocationRequest{{Name: "vpc", PrefixLength: 16}}, exclusions)
+			if tt.want == "" {
+				if err == nil {
+					t.Errorf("Allocate() = %v, want error since the base is fully excluded", results)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Allocate() error = %v", err)
+			}
+			if results["vpc"] != tt.want {
+				t.Errorf("vpc = %v, want %v", results["vpc"], tt.want)
+			}
+		})
+	}
+}
+
 func TestAllocator_Allocate_EmptyRequests(t *testing.T) {
 	allocator, err := NewAllocator("10.0.0.0/8")
 	if err != nil {
@@ -274,6 +480,51 @@ func TestAllocator_Allocate_EmptyRequests(t *testing.T) {
 	}
 }
 
+func TestAllocator_Allocate_WithNilExclusions(t *testing.T) {
+	allocator, err := NewAllocator("10.0.0.0/16")
+	if err != nil {
+		t.Fatalf("NewAllocator() error = %v", err)
+	}
+	requests := []AllocationRequest{
+		{Name: "a", PrefixLength: 24},
+		{Name: "b", PrefixLength: 20},
+	}
+
+	// nil and empty exclusions both mean no exclusions
+	withNil, err := allocator.Allocate(requests, nil)
+	if err != nil {
+		t.Fatalf("Allocate(nil exclusions) error = %v", err)
+	}
+	withEmpty, err := allocator.Allocate(requests, []*net.IPNet{})
+	if err != nil {
+		t.Fatalf("Allocate(empty exclusions) error = %v", err)
+	}
+
+	want := map[string]string{"a": "10.0.0.0/24", "b": "10.0.16.0/20"}
+	for name, cidr := range want {
+		if withNil[name] != cidr {
+			t.Errorf("with nil exclusions %s = %s, want %s", name, withNil[name], cidr)
+		}
+		if withEmpty[name] != cidr {
+			t.Errorf("with empty exclusions %s = %s, want %s", name, withEmpty[name], cidr)
+		}
+	}
+
+	reserved, err := allocator.AllocateWithReservations(nil, requests, nil)
+	if err != nil {
+		t.Fatalf("AllocateWithReservations(nil, nil) error = %v", err)
+	}
+	for name, cidr := range want {
+		if reserved[name] != cidr {
+			t.Errorf("AllocateWithReservations() %s = %s, want %s", name, reserved[name], cidr)
+		}
+	}
+
+	if next, err := NextFree("10.0.0.0/16", 24, nil); err != nil || next.String() != "10.0.0.0/24" {
+		t.Errorf("NextFree(nil exclusions) = %v, %v, want 10.0.0.0/24", next, err)
+	}
+}
+
 func TestNetworksOverlap(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -325,6 +576,441 @@ func TestNetworksOverlap(t *testing.T) {
 	}
 }
 
+func TestAllocator_Allocate_RequestExclusions(t *testing.T) {
+	allocator, err := NewAllocator("10.96.0.0/11")
+	if err != nil {
+		t.Fatalf("NewAllocator() error = %v", err)
+	}
+	services := AllocationRequest{Name: "doks_services", PrefixLength: 16, Exclusions: []*net.IPNet{mustParseCIDR("10.96.0.0/12")}}
+	vpc := AllocationRequest{Name: "vpc", PrefixLength: 16}
+
+	tests := []struct {
+		name     string
+		requests []AllocationRequest
+	}{
+		{name: "constrained first", requests: []AllocationRequest{services, vpc}},
+		{name: "unconstrained first", requests: []AllocationRequest{vpc, services}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := allocator.Allocate(tt.requests, nil)
+			if err != nil {
+				t.Fatalf("Allocate() error = %v", err)
+			}
+			// The constrained request skips 10.96.0.0/12 while the
+			// unconstrained one takes it
+			want := map[string]string{"doks_services": "10.112.0.0/16", "vpc": "10.96.0.0/16"}
+			for name, cidr := range want {
+				if got[name] != cidr {
+					t.Errorf("%s = %s, want %s", name, got[name], cidr)
+				}
+			}
+		})
+	}
+
+	if _, err := allocator.Allocate([]AllocationRequest{{Name: "a", PrefixLength: 12, Exclusions: []*net.IPNet{mustParseCIDR("10.96.0.0/11")}}}, nil); !errors.Is(err, ErrNoSpace) {
+		t.Errorf("Allocate() with the whole base excluded error = %v, want ErrNoSpace", err)
+	}
+}
+
+func TestAllocator_Allocate_RequestBaseCIDR(t *testing.T) {
+	allocator, err := NewAllocator("10.64.0.0/10")
+	if err != nil {
+		t.Fatalf("NewAllocator() error = %v", err)
+	}
+	requests := []AllocationRequest{
+		{Name: "vpc", PrefixLength: 16},
+		{Name: "transit", PrefixLength: 24, BaseCIDR: mustParseCIDR("172.31.0.0/16")},
+		{Name: "k8s", PrefixLength: 16},
+		// A base overlapping the allocator's avoids its allocations too
+		{Name: "inner", PrefixLength: 16, BaseCIDR: mustParseCIDR("10.64.0.0/14")},
+	}
+	exclusions := []*net.IPNet{mustParseCIDR("172.31.0.0/24")}
+
+	got, err := allocator.Allocate(requests, exclusions)
+	if err != nil {
+		t.Fatalf("Allocate() error = %v", err)
+	}
+	want := map[string]string{
+		"vpc":     "10.64.0.0/16",
+		"transit": "172.31.1.0/24",
+		"k8s":     "10.65.0.0/16",
+		"inner":   "10.66.0.0/16",
+	}
+	for name, cidr := range want {
+		if got[name] != cidr {
+			t.Errorf("%s = %s, want %s", name, got[name], cidr)
+		}
+	}
+
+	// Only the allocator's own base is widened when it runs out of space
+	_, err = allocator.Allocate([]AllocationRequest{{Name: "full", PrefixLength: 16, BaseCIDR: mustParseCIDR("172.31.0.0/16")}}, exclusions)
+	if err == nil || errors.Is(err, ErrNoSpace) {
+		t.Errorf("Allocate() with a full request base error = %v, want an error not wrapping ErrNoSpace", err)
+	}
+	var allocErr *AllocationError
+	if !errors.As(err, &allocErr) || allocErr.Name != "full" || allocErr.BaseCIDR.String() != "172.31.0.0/16" || !allocErr.OwnBase {
+		t.Errorf("Allocate() with a full request base error = %#v, want an AllocationError for its own base 172.31.0.0/16", err)
+	}
+
+	// Reservations are checked against the request's base
+	reservations := []ReservationRequest{{Name: "transit", CIDR: mustParseCIDR("172.31.5.0/24")}}
+	got, err = allocator.AllocateWithReservations(reservations, requests[:2], nil)
+	if err != nil {
+		t.Fatalf("AllocateWithReservations() error = %v", err)
+	}
+	if got["transit"] != "172.31.5.0/24" {
+		t.Errorf("transit = %s, want the reserved 172.31.5.0/24", got["transit"])
+	}
+}
+
+func TestAllocator_Allocate_RandomPlacement(t *testing.T) {
+	base, err := NewAllocator("10.0.0.0/8")
+	if err != nil {
+		t.Fatalf("NewAllocator() error = %v", err)
+	}
+	requests := []AllocationRequest{
+		{Name: "vpc", PrefixLength: 16},
+		{Name: "k8s", PrefixLength: 20},
+		{Name: "db", PrefixLength: 24},
+	}
+
+	allocate := func(seed string) map[string]string {
+		t.Helper()
+		allocator := base.WithRandomPlacement([]byte(seed))
+		if allocator.Placement() != PlacementRandom {
+			t.Fatalf("Placement() = %s, want %s", allocator.Placement(), PlacementRandom)
+		}
+		results, err := allocator.Allocate(requests, nil)
+		if err != nil {
+			t.Fatalf("Allocate() error = %v", err)
+		}
+		return results
+	}
+
+	// The same seed always gives the same blocks
+	first := allocate("pool-a")
+	want := map[string]string{"vpc": "10.80.0.0/16", "k8s": "10.51.144.0/20", "db": "10.61.150.0/24"}
+	for name, cidr := range want {
+		if first[name] != cidr {
+			t.Errorf("%s = %s, want %s", name, first[name], cidr)
+		}
+	}
+	again := allocate("pool-a")
+	for name := range want {
+		if again[name] != first[name] {
+			t.Errorf("%s = %s on the second run, want %s", name, again[name], first[name])
+		}
+	}
+
+	// Another seed starts elsewhere
+	other := allocate("pool-b")
+	if other["vpc"] == first["vpc"] {
+		t.Errorf("vpc = %s for both seeds, want different blocks", other["vpc"])
+	}
+
+	if base.Placement() != PlacementFirstFit {
+		t.Errorf("Placement() = %s, want %s", base.Placement(), PlacementFirstFit)
+	}
+}
+
+func TestAllocator_Allocate_RandomPlacementExhaustion(t *testing.T) {
+	base, err := NewAllocator("10.0.0.0/24")
+	if err != nil {
+		t.Fatalf("NewAllocator() error = %v", err)
+	}
+
+	for i := 0; i < 32; i++ {
+		allocator := base.WithRandomPlacement([]byte(fmt.Sprintf("seed-%d", i)))
+
+		// Every block is found, whichever block each search starts at
+		requests := []AllocationRequest{
+			{Name: "a", PrefixLength: 26},
+			{Name: "b", PrefixLength: 26},
+			{Name: "c", PrefixLength: 26},
+			{Name: "d", PrefixLength: 26},
+		}
+		results, err := allocator.Allocate(requests, nil)
+		if err != nil {
+			t.Fatalf("seed-%d: Allocate() error = %v", i, err)
+		}
+		seen := map[string]bool{}
+		for _, block := range results {
+			seen[block] = true
+		}
+		if len(seen) != 4 {
+			t.Errorf("seed-%d: Allocate() = %v, want all four /26 blocks", i, results)
+		}
+
+		// Only the first block is free, so the search wraps around to it
+		exclusions := []*net.IPNet{mustParseCIDR("10.0.0.64/26"), mustParseCIDR("10.0.0.128/25")}
+		results, err = allocator.Allocate([]AllocationRequest{{Name: "a", PrefixLength: 26}}, exclusions)
+		if err != nil || results["a"] != "10.0.0.0/26" {
+			t.Errorf("seed-%d: Allocate() = %v, %v, want 10.0.0.0/26", i, results, err)
+		}
+
+		// With no block free there is no space, after trying them all
+		exclusions = append(exclusions, mustParseCIDR("10.0.0.0/26"))
+		if _, err := allocator.Allocate([]AllocationRequest{{Name: "a", PrefixLength: 26}}, exclusions); !errors.Is(err, ErrNoSpace) {
+			t.Errorf("seed-%d: Allocate() error = %v, want ErrNoSpace", i, err)
+		}
+	}
+
+	// Expanding keeps the placement
+	expanded, err := base.WithRandomPlacement([]byte("seed")).Expanded()
+	if err != nil {
+		t.Fatalf("Expanded() error = %v", err)
+	}
+	if expanded.BaseCIDR().String() != "10.0.0.0/23" || expanded.Placement() != PlacementRandom {
+		t.Errorf("Expanded() = %s with %s placement, want 10.0.0.0/23 with random placement", expanded.BaseCIDR(), expanded.Placement())
+	}
+}
+
+func TestAllocator_AllocateWithReservations(t *testing.T) {
+	allocator, err := NewAllocator("10.0.0.0/16")
+	if err != nil {
+		t.Fatalf("NewAllocator() error = %v", err)
+	}
+
+	// "b" keeps its block even though it was declared after "a", and the
+	// new "c" is allocated around it.
+	reservations := []ReservationRequest{{Name: "b", CIDR: mustParseCIDR("10.0.0.0/24")}}
+	requests := []AllocationRequest{
+		{Name: "a", PrefixLength: 24},
+		{Name: "b", PrefixLength: 24},
+		{Name: "c", PrefixLength: 24},
+	}
+	exclusions := []*net.IPNet{mustParseCIDR("10.0.1.0/24")}
+
+	got, err := allocator.AllocateWithReservations(reservations, requests, exclusions)
+	if err != nil {
+		t.Fatalf("AllocateWithReservations() error = %v", err)
+	}
+	want := map[string]string{"a": "10.0.2.0/24", "b": "10.0.0.0/24", "c": "10.0.3.0/24"}
+	for name, cidr := range want {
+		if got[name] != cidr {
+			t.Errorf("%s = %s, want %s", name, got[name], cidr)
+		}
+	}
+}
+
+func TestAllocator_AllocateWithReservations_Errors(t *testing.T) {
+	allocator, err := NewAllocator("10.0.0.0/16")
+	if err != nil {
+		t.Fatalf("NewAllocator() error = %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		reservations []ReservationRequest
+		wantName     string
+	}{
+		{
+			name:         "outside base",
+			reservations: []ReservationRequest{{Name: "a", CIDR: mustParseCIDR("10.1.0.0/24")}},
+			wantName:     "a",
+		},
+		{
+			name: "overlapping reservations",
+			reservations: []ReservationRequest{
+				{Name: "a", CIDR: mustParseCIDR("10.0.0.0/20")},
+				{Name: "b", CIDR: mustParseCIDR("10.0.1.0/24")},
+			},
+			wantName: "b",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := allocator.AllocateWithReservations(tt.reservations, nil, nil)
+			if err == nil {
+				t.Fatal("AllocateWithReservations() expected error")
+			}
+			var reservationErr *ReservationError
+			if !errors.As(err, &reservationErr) || reservationErr.Name != tt.wantName {
+				t.Errorf("AllocateWithReservations() error = %#v, want a *ReservationError for %s", err, tt.wantName)
+			}
+		})
+	}
+}
+
+func TestAllocator_PreferPrevious(t *testing.T) {
+	allocator, err := NewAllocator("10.0.0.0/16")
+	if err != nil {
+		t.Fatalf("NewAllocator() error = %v", err)
+	}
+
+	previous := map[string]string{
+		"kept":      "10.0.0.0/24",
+		"resized":   "10.0.1.0/24",
+		"outside":   "10.1.0.0/24",
+		"excluded":  "10.0.2.0/24",
+		"invalid":   "not-a-cidr",
+		"own":       "10.0.4.0/24",
+		"clash":     "10.0.0.0/24",
+		"not_asked": "10.0.3.0/24",
+	}
+	requests := []AllocationRequest{
+		{Name: "kept", PrefixLength: 24},
+		{Name: "resized", PrefixLength: 23},
+		{Name: "outside", PrefixLength: 24},
+		{Name: "excluded", PrefixLength: 24},
+		{Name: "invalid", PrefixLength: 24},
+		{Name: "own", PrefixLength: 24, Exclusions: []*net.IPNet{mustParseCIDR("10.0.4.0/22")}},
+		{Name: "clash", PrefixLength: 24},
+		{Name: "new", PrefixLength: 24},
+	}
+	exclusions := []*net.IPNet{mustParseCIDR("10.0.2.0/25")}
+
+	kept, moved := allocator.PreferPrevious(previous, requests, exclusions)
+	if len(kept) != 1 || kept[0].Name != "kept" || kept[0].CIDR.String() != "10.0.0.0/24" {
+		t.Errorf("kept = %+v, want only kept at 10.0.0.0/24", kept)
+	}
+
+	want := []MovedAllocation{
+		{Name: "resized", Previous: "10.0.1.0/24", Reason: "the request is now a /23"},
+		{Name: "outside", Previous: "10.1.0.0/24", Reason: "it is outside base CIDR 10.0.0.0/16"},
+		{Name: "excluded", Previous: "10.0.2.0/24", Reason: "it overlaps exclusion 10.0.2.0/25"},
+		{Name: "invalid", Previous: "not-a-cidr", Reason: "it is not a valid CIDR"},
+		{Name: "own", Previous: "10.0.4.0/24", Reason: "it overlaps exclusion 10.0.4.0/22"},
+		{Name: "clash", Previous: "10.0.0.0/24", Reason: `it overlaps 10.0.0.0/24, kept for "kept"`},
+	}
+	if len(moved) != len(want) {
+		t.Fatalf("moved = %+v, want %+v", moved, want)
+	}
+	for i := range want {
+		if moved[i] != want[i] {
+			t.Errorf("moved[%d] = %+v, want %+v", i, moved[i], want[i])
+		}
+	}
+}
+
+func TestAllocator_PreferPrevious_RemovedExclusion(t *testing.T) {
+	allocator, err := NewAllocator("10.0.0.0/16")
+	if err != nil {
+		t.Fatalf("NewAllocator() error = %v", err)
+	}
+	requests := []AllocationRequest{
+		{Name: "a", PrefixLength: 24},
+		{Name: "b", PrefixLength: 24},
+	}
+
+	// Allocated around an exclusion that has since been removed
+	previous, err := allocator.Allocate(requests, []*net.IPNet{mustParseCIDR("10.0.0.0/24")})
+	if err != nil {
+		t.Fatalf("Allocate() error = %v", err)
+	}
+
+	reservations, moved := allocator.PreferPrevious(previous, requests, nil)
+	if len(moved) != 0 {
+		t.Errorf("moved = %+v, want none", moved)
+	}
+	got, err := allocator.AllocateWithReservations(reservations, requests, nil)
+	if err != nil {
+		t.Fatalf("AllocateWithReservations() error = %v", err)
+	}
+	for name, want := range previous {
+		if got[name] != want {
+			t.Errorf("%s = %s, want it to stay at %s", name, got[name], want)
+		}
+	}
+}
+
+func TestNextFree_MatchesAllocate(t *testing.T) {
+	tests := []struct {
+		name       string
+		base       string
+		requests   []AllocationRequest
+		exclusions []string
+	}{
+		{
+			name:     "empty base",
+			base:     "10.0.0.0/8",
+			requests: []AllocationRequest{{Name: "vpc", PrefixLength: 16}, {Name: "k8s", PrefixLength: 20}},
+		},
+		{
+			name:       "with exclusions",
+			base:       "10.0.0.0/16",
+			requests:   []AllocationRequest{{Name: "a", PrefixLength: 24}, {Name: "b", PrefixLength: 22}, {Name: "c", PrefixLength: 24}},
+			exclusions: []string{"10.0.0.0/24", "10.0.2.0/23", "10.0.4.0/22"},
+		},
+		{
+			name:       "mixed sizes around a hole",
+			base:       "192.168.0.0/16",
+			requests:   []AllocationRequest{{Name: "small", PrefixLength: 28}, {Name: "big", PrefixLength: 20}, {Name: "small2", PrefixLength: 28}},
+			exclusions: []string{"192.168.0.16/28"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var exclusions []*net.IPNet
+			for _, e := range tt.exclusions {
+				exclusions = append(exclusions, mustParseCIDR(e))
+			}
+
+			allocator, err := NewAllocator(tt.base)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := allocator.Allocate(tt.requests, exclusions)
+			if err != nil {
+				t.Fatalf("Allocate() error = %v", err)
+			}
+
+			for _, req := range tt.requests {
+				got, err := NextFree(tt.base, req.PrefixLength, exclusions)
+				if err != nil {
+					t.Fatalf("NextFree(%s, %d) error = %v", tt.base, req.PrefixLength, err)
+				}
+				if got.String() != want[req.Name] {
+					t.Errorf("NextFree() for %q = %s, Allocate() = %s", req.Name, got, want[req.Name])
+				}
+				exclusions = append(exclusions, got)
+			}
+		})
+	}
+}
+
+func TestNextFree_Errors(t *testing.T) {
+	if _, err := NextFree("10.0.0.0/24", 25, []*net.IPNet{mustParseCIDR("10.0.0.0/24")}); !errors.Is(err, ErrNoSpace) {
+		t.Errorf("NextFree() error = %v, want ErrNoSpace", err)
+	}
+	if _, err := NextFree("not-a-cidr", 24, nil); err == nil {
+		t.Error("NextFree() expected error for invalid base")
+	}
+	if _, err := NextFree("10.0.0.0/16", 8, nil); err == nil {
+		t.Error("NextFree() expected error for prefix larger than the base")
+	}
+}
+
+func TestExpandCIDR(t *testing.T) {
+	tests := []struct {
+		base    string
+		want    string
+		wantErr bool
+	}{
+		{base: "10.0.0.0/8", want: "10.0.0.0/7"},
+		{base: "10.0.1.0/24", want: "10.0.0.0/23"},
+		{base: "10.0.3.0/24", want: "10.0.2.0/23"},
+		{base: "fd00:0:1::/48", want: "fd00::/47"},
+		{base: "0.0.0.0/0", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.base, func(t *testing.T) {
+			got, err := ExpandCIDR(mustParseCIDR(tt.base))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ExpandCIDR() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got.String() != tt.want {
+				t.Errorf("ExpandCIDR() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestParseCIDR(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -0,0 +1,254 @@
+package cidr
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"math/bits"
+	"net"
+	"sort"
+)
+
+// Covered reports whether every address in network falls within at least one of
+// the given networks. Networks of a different address family are ignored.
+func Covered(network *net.IPNet, by []*net.IPNet) bool {
+	overlapping := false
+	for _, other := range by {
+		if ContainsNetwork(other, network) {
+			return true
+		}
+		if sameFamily(network, other) && networksOverlap(network, other) {
+			overlapping = true
+		}
+	}
+	if !overlapping {
+		return false
+	}
+
+	// Only part of the network is covered by any single entry, so check
+	// each half separately.
+	lower, upper := splitNetwork(network)
+	return Covered(lower, by) && Covered(upper, by)
+}
+
+// Intersection returns the overlap between two networks, which is the more
+// specific of the two, or false if they don't overlap.
+func Intersection(a, b *net.IPNet) (*net.IPNet, bool) {
+	switch {
+	case ContainsNetwork(a, b):
+		return b, true
+	case ContainsNetwork(b, a):
+		return a, true
+	default:
+		return nil, false
+	}
+}
+
+// Relationship describes how one network relates to another.
+type Relationship string
+
+const (
+	// RelationshipNone means the networks share no addresses.
+	RelationshipNone Relationship = ""
+	// RelationshipEqual means the networks are the same block.
+	RelationshipEqual Relationship = "equal"
+	// RelationshipContains means the first network contains the second.
+	RelationshipContains Relationship = "contains"
+	// RelationshipContained means the first network is within the second.
+	RelationshipContained Relationship = "contained"
+	// RelationshipOverlaps means the networks share addresses but neither
+	// contains the other, as with an IPv4 block and an IPv4-mapped IPv6 block.
+	RelationshipOverlaps Relationship = "overlaps"
+)
+
+// Classify returns how network a relates to network b.
+func Classify(a, b *net.IPNet) Relationship {
+	aContainsB, bContainsA := ContainsNetwork(a, b), ContainsNetwork(b, a)
+	switch {
+	case aContainsB && bContainsA:
+		return RelationshipEqual
+	case aContainsB:
+		return RelationshipContains
+	case bContainsA:
+		return RelationshipContained
+	case networksOverlap(a, b):
+		return RelationshipOverlaps
+	default:
+		return RelationshipNone
+	}
+}
+
+// CoveringSupernet returns the smallest single network that contains every one
+// of the given networks, in any order. The result may also contain addresses
+// that are in none of them; use Merge for an exact cover. All networks must be
+// of the same address family, and at least one must be given.
+func CoveringSupernet(networks []*net.IPNet) (*net.IPNet, error) {
+	if len(networks) == 0 {
+		return nil, errors.New("no networks given")
+	}
+
+	first := networks[0]
+	prefixLength, addressBits := first.Mask.Size()
+	ip := networkIP(first)
+	for _, network := range networks[1:] {
+		if !sameFamily(first, network) {
+			return nil, fmt.Errorf("cannot mix address families: %s and %s", first, network)
+		}
+		ones, _ := network.Mask.Size()
+		if ones < prefixLength {
+			prefixLength = ones
+		}
+		if common := commonPrefixLength(ip, networkIP(network)); common < prefixLength {
+			prefixLength = common
+		}
+	}
+
+	mask := net.CIDRMask(prefixLength, addressBits)
+	return &net.IPNet{IP: ip.Mask(mask), Mask: mask}, nil
+}
+
+// Merge returns the smallest set of networks that contains exactly the
+// addresses in the given networks. Duplicates and networks nested in another
+// are dropped, and aligned sibling blocks, such as 10.0.0.0/25 and
+// 10.0.0.128/25, are joined into their parent, repeatedly. The input may be
+// in any order and may mix address families; the result is sorted with IPv4
+// networks first, then by address. Host bits in the input are ignored. The
+// input is not modified.
+func Merge(networks []*net.IPNet) []*net.IPNet {
+	blocks := make([]*net.IPNet, 0, len(networks))
+	for _, network := range networks {
+		ones, addressBits := network.Mask.Size()
+		blocks = append(blocks, &net.IPNet{IP: networkIP(network), Mask: net.CIDRMask(ones, addressBits)})
+	}
+
+	// A network sorts before any network it contains
+	sort.Slice(blocks, func(i, j int) bool {
+		_, iBits := blocks[i].Mask.Size()
+		_, jBits := blocks[j].Mask.Size()
+		if iBits != jBits {
+			return iBits < jBits
+		}
+		if c := bytes.Compare(blocks[i].IP, blocks[j].IP); c != 0 {
+			return c < 0
+		}
+		iOnes, _ := blocks[i].Mask.Size()
+		jOnes, _ := blocks[j].Mask.Size()
+		return iOnes < jOnes
+	})
+
+	var result []*net.IPNet
+	for _, block := range blocks {
+		if n := len(result); n > 0 && ContainsNetwork(result[n-1], block) {
+			continue
+		}
+		result = append(result, block)
+
+		// Joining two siblings can make their parent a sibling of the
+		// block before it
+		for n := len(result); n >= 2; n = len(result) {
+			parent, ok := mergeSiblings(result[n-2], result[n-1])
+			if !ok {
+				break
+			}
+			result = append(result[:n-2], parent)
+		}
+	}
+	return result
+}
+
+// mergeSiblings returns the parent of a and b if they are the two halves of
+// it, in that order.
+func mergeSiblings(a, b *net.IPNet) (*net.IPNet, bool) {
+	aOnes, aBits := a.Mask.Size()
+	bOnes, bBits := b.Mask.Size()
+	if aBits != bBits || aOnes != bOnes || aOnes == 0 {
+		return nil, false
+	}
+
+	mask := net.CIDRMask(aOnes-1, aBits)
+	parent := &net.IPNet{IP: a.IP.Mask(mask), Mask: mask}
+	if !parent.IP.Equal(a.IP) || !parent.IP.Equal(b.IP.Mask(mask)) || a.IP.Equal(b.IP) {
+		return nil, false
+	}
+	return parent, true
+}
+
+// UncoveredAddressCount returns the number of addresses in network that are
+// not in any of the given networks.
+func UncoveredAddressCount(network *net.IPNet, by []*net.IPNet) *big.Int {
+	total := new(big.Int)
+	for _, block := range FreeBlocks(network, by) {
+		total.Add(total, AddressCount(block))
+	}
+	return total
+}
+
+// networkIP returns the network address, as 4 bytes for IPv4 networks.
+func networkIP(network *net.IPNet) net.IP {
+	ip := network.IP.Mask(network.Mask)
+	if _, addressBits := network.Mask.Size(); addressBits == 32 {
+		return ip.To4()
+	}
+	return ip
+}
+
+// commonPrefixLength returns the number of leading bits two addresses of the
+// same length share.
+func commonPrefixLength(a, b net.IP) int {
+	for i := range a {
+		if x := a[i] ^ b[i]; x != 0 {
+			return i*8 + bits.LeadingZeros8(x)
+		}
+	}
+	return len(a) * 8
+}
+
+// splitNetwork divides a network into its two halves. The network must be
+// larger than a single address.
+func splitNetwork(network *net.IPNet) (*net.IPNet, *net.IPNet) {
+	ones, bits := network.Mask.Size()
+	mask := net.CIDRMask(ones+1, bits)
+
+	ip := network.IP.Mask(network.Mask)
+	if bits == 32 {
+		ip = ip.To4()
+	}
+	lower := &net.IPNet{IP: ip, Mask: mask}
+
+	n := new(big.Int).SetBytes(ip)
+	n.SetBit(n, bits-ones-1, 1)
+	upperIP := make(net.IP, len(ip))
+	n.FillBytes(upperIP)
+	upper := &net.IPNet{IP: upperIP, Mask: mask}
+
+	return lower, upper
+}
+
+// sameFamily reports whether two networks are both IPv4 or both IPv6.
+func sameFamily(a, b *net.IPNet) bool {
+	_, aBits := a.Mask.Size()
+	_, bBits := b.Mask.Size()
+	return aBits == bBits
+}
+
+// FreeBlocks returns the largest aligned blocks within network that don't
+// overlap any of the used networks, in address order. Networks of a different
+// address family are ignored.
+func FreeBlocks(network *net.IPNet, used []*net.IPNet) []*net.IPNet {
+	overlapping := false
+	for _, other := range used {
+		if ContainsNetwork(other, network) {
+			return nil
+		}
+		if sameFamily(network, other) && networksOverlap(network, other) {
+			overlapping = true
+		}
+	}
+	if !overlapping {
+		return []*net.IPNet{network}
+	}
+
+	lower, upper := splitNetwork(network)
+	return append(FreeBlocks(lower, used), FreeBlocks(upper, used)...)
+}
@@ -0,0 +1,410 @@
+package cidr
+
+import (
+	"net"
+	"testing"
+)
+
+func TestCovered(t *testing.T) {
+	tests := []struct {
+		name    string
+		network string
+		by      []string
+		want    bool
+	}{
+		{
+			name:    "no networks",
+			network: "10.0.0.0/16",
+			want:    false,
+		},
+		{
+			name:    "contained by single network",
+			network: "10.1.0.0/16",
+			by:      []string{"10.0.0.0/8"},
+			want:    true,
+		},
+		{
+			name:    "covered by halves",
+			network: "10.0.0.0/16",
+			by:      []string{"10.0.128.0/17", "10.0.0.0/17"},
+			want:    true,
+		},
+		{
+			name:    "covered by mixed sizes",
+			network: "10.0.0.0/16",
+			by:      []string{"10.0.0.0/17", "10.0.128.0/18", "10.0.192.0/18"},
+			want:    true,
+		},
+		{
+			name:    "partially covered",
+			network: "10.0.0.0/16",
+			by:      []string{"10.0.0.0/17", "10.0.128.0/18"},
+			want:    false,
+		},
+		{
+			name:    "disjoint",
+			network: "10.0.0.0/16",
+			by:      []string{"10.1.0.0/16"},
+			want:    false,
+		},
+		{
+			name:    "ipv6",
+			network: "fd00::/63",
+			by:      []string{"fd00::/64", "fd00:0:0:1::/64"},
+			want:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var by []*net.IPNet
+			for _, c := range tt.by {
+				by = append(by, mustParseCIDR(c))
+			}
+			if got := Covered(mustParseCIDR(tt.network), by); got != tt.want {
+				t.Errorf("Covered(%s, %v) = %v, want %v", tt.network, tt.by, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIntersection(t *testing.T) {
+	tests := []struct {
+		a, b   string
+		want   string
+		wantOK bool
+	}{
+		{"10.0.0.0/8", "10.1.0.0/16", "10.1.0.0/16", true},
+		{"10.1.0.0/16", "10.0.0.0/8", "10.1.0.0/16", true},
+		{"10.0.0.0/16", "10.0.0.0/16", "10.0.0.0/16", true},
+		{"10.0.0.0/16", "10.1.0.0/16", "", false},
+	}
+
+	for _, tt := range tests {
+		got, ok := Intersection(mustParseCIDR(tt.a), mustParseCIDR(tt.b))
+		if ok != tt.wantOK {
+			t.Errorf("Intersection(%s, %s) ok = %v, want %v", tt.a, tt.b, ok, tt.wantOK)
+			continue
+		}
+		if ok && got.String() != tt.want {
+			t.Errorf("Intersection(%s, %s) = %s, want %s", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want Relationship
+	}{
+		{"10.0.0.0/16", "10.0.0.0/16", RelationshipEqual},
+		{"10.0.0.0/8", "10.1.0.0/16", RelationshipContains},
+		{"10.1.0.0/16", "10.0.0.0/8", RelationshipContained},
+		{"10.0.0.0/8", "::ffff:10.1.0.0/112", RelationshipOverlaps},
+		{"10.0.0.0/16", "10.1.0.0/16", RelationshipNone},
+		{"10.0.0.0/8", "fd00::/8", RelationshipNone},
+		{"fd00::/8", "fd00:1::/32", RelationshipContains},
+	}
+
+	for _, tt := range tests {
+		if got := Classify(mustParseCIDR(tt.a), mustParseCIDR(tt.b)); got != tt.want {
+			t.Errorf("Classify(%s, %s) = %q, want %q", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestFreeBlocks(t *testing.T) {
+	tests := []struct {
+		name    string
+		network string
+		used    []string
+		want    []string
+	}{
+		{
+			name:    "nothing used",
+			network: "10.0.0.0/16",
+			want:    []string{"10.0.0.0/16"},
+		},
+		{
+			name:    "fully used",
+			network: "10.1.0.0/16",
+			used:    []string{"10.0.0.0/8"},
+		},
+		{
+			name:    "first quarter used",
+			network: "10.0.0.0/16",
+			used:    []string{"10.0.0.0/18"},
+			want:    []string{"10.0.64.0/18", "10.0.128.0/17"},
+		},
+		{
+			name:    "single address in the middle",
+			network: "192.168.0.0/30",
+			used:    []string{"192.168.0.1/32"},
+			want:    []string{"192.168.0.0/32", "192.168.0.2/31"},
+		},
+		{
+			name:    "used outside network",
+			network: "10.0.0.0/16",
+			used:    []string{"172.16.0.0/12"},
+			want:    []string{"10.0.0.0/16"},
+		},
+		{
+			name:    "other family ignored",
+			network: "10.0.0.0/16",
+			used:    []string{"fd00::/8"},
+			want:    []string{"10.0.0.0/16"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			network := mustParseCIDR(tt.network)
+			var used []*net.IPNet
+			for _, u := range tt.used {
+				used = append(used, mustParseCIDR(u))
+			}
+
+			got := FreeBlocks(network, used)
+			if len(got) != len(tt.want) {
+				t.Fatalf("FreeBlocks() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i].String() != tt.want[i] {
+					t.Errorf("FreeBlocks()[%d] = %s, want %s", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestCoveringSupernet(t *testing.T) {
+	tests := []struct {
+		name     string
+		networks []string
+		want     string
+	}{
+		{
+			name:     "single network",
+			networks: []string{"10.1.0.0/16"},
+			want:     "10.1.0.0/16",
+		},
+		{
+			name:     "adjacent halves",
+			networks: []string{"10.0.0.0/17", "10.0.128.0/17"},
+			want:     "10.0.0.0/16",
+		},
+		{
+			name:     "pool allocations",
+			networks: []string{"10.0.0.0/16", "10.1.0.0/20", "10.1.16.0/20"},
+			want:     "10.0.0.0/15",
+		},
+		{
+			name:     "nested",
+			networks: []string{"10.0.0.0/8", "10.20.30.0/24"},
+			want:     "10.0.0.0/8",
+		},
+		{
+			name:     "unaligned pair",
+			networks: []string{"10.0.255.0/24", "10.1.0.0/24"},
+			want:     "10.0.0.0/15",
+		},
+		{
+			name:     "identical",
+			networks: []string{"192.168.1.0/24", "192.168.1.0/24"},
+			want:     "192.168.1.0/24",
+		},
+		{
+			name:     "up to the IPv4 base",
+			networks: []string{"0.0.0.0/32", "255.255.255.255/32"},
+			want:     "0.0.0.0/0",
+		},
+		{
+			name:     "up to /1",
+			networks: []string{"10.0.0.0/8", "127.255.255.0/24"},
+			want:     "0.0.0.0/1",
+		},
+		{
+			name:     "unsorted",
+			networks: []string{"10.3.0.0/16", "10.0.0.0/24", "10.1.128.0/17"},
+			want:     "10.0.0.0/14",
+		},
+		{
+			name:     "IPv6",
+			networks: []string{"fd00:0:1::/48", "fd00:0:2::/48"},
+			want:     "fd00::/46",
+		},
+		{
+			name:     "up to the IPv6 base",
+			networks: []string{"::/128", "8000::/1"},
+			want:     "::/0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var networks []*net.IPNet
+			for _, n := range tt.networks {
+				networks = append(networks, mustParseCIDR(n))
+			}
+
+			got, err := CoveringSupernet(networks)
+			if err != nil {
+				t.Fatalf("CoveringSupernet() error = %v", err)
+			}
+			if got.String() != tt.want {
+				t.Errorf("CoveringSupernet() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMerge(t *testing.T) {
+	tests := []struct {
+		name     string
+		networks []string
+		want     []string
+	}{
+		{
+			name:     "empty",
+			networks: nil,
+			want:     nil,
+		},
+		{
+			name:     "single network",
+			networks: []string{"10.1.0.0/16"},
+			want:     []string{"10.1.0.0/16"},
+		},
+		{
+			name:     "adjacent siblings merge",
+			networks: []string{"10.0.0.0/25", "10.0.0.128/25"},
+			want:     []string{"10.0.0.0/24"},
+		},
+		{
+			name:     "adjacent but not siblings",
+			networks: []string{"10.0.0.128/25", "10.0.1.0/25"},
+			want:     []string{"10.0.0.128/25", "10.0.1.0/25"},
+		},
+		{
+			name:     "adjacent different sizes",
+			networks: []string{"10.0.0.0/24", "10.0.1.0/25"},
+			want:     []string{"10.0.0.0/24", "10.0.1.0/25"},
+		},
+		{
+			name:     "not adjacent",
+			networks: []string{"10.0.0.0/24", "10.0.2.0/24"},
+			want:     []string{"10.0.0.0/24", "10.0.2.0/24"},
+		},
+		{
+			name:     "duplicates",
+			networks: []string{"192.168.1.0/24", "192.168.1.0/24"},
+			want:     []string{"192.168.1.0/24"},
+		},
+		{
+			name:     "nested",
+			networks: []string{"10.20.30.0/24", "10.0.0.0/8", "10.20.0.0/16"},
+			want:     []string{"10.0.0.0/8"},
+		},
+		{
+			name:     "repeated merging",
+			networks: []string{"10.0.0.0/26", "10.0.0.64/26", "10.0.0.128/25", "10.0.1.0/24"},
+			want:     []string{"10.0.0.0/23"},
+		},
+		{
+			name:     "merged parent joins earlier block",
+			networks: []string{"10.0.1.128/25", "10.0.0.0/24", "10.0.1.0/25"},
+			want:     []string{"10.0.0.0/23"},
+		},
+		{
+			name:     "unsorted",
+			networks: []string{"172.16.0.0/12", "10.0.1.0/24", "10.0.0.0/24", "192.168.0.0/16"},
+			want:     []string{"10.0.0.0/23", "172.16.0.0/12", "192.168.0.0/16"},
+		},
+		{
+			name:     "whole IPv4 space",
+			networks: []string{"128.0.0.0/1", "0.0.0.0/1"},
+			want:     []string{"0.0.0.0/0"},
+		},
+		{
+			name:     "IPv6",
+			networks: []string{"fd00:0:1::/48", "fd00::/48", "fd00:0:3::/48"},
+			want:     []string{"fd00::/47", "fd00:0:3::/48"},
+		},
+		{
+			name:     "mixed families",
+			networks: []string{"fd00::/49", "10.0.1.0/24", "fd00:0:0:8000::/49", "10.0.0.0/24"},
+			want:     []string{"10.0.0.0/23", "fd00::/48"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var networks []*net.IPNet
+			for _, n := range tt.networks {
+				networks = append(networks, mustParseCIDR(n))
+			}
+
+			got := Merge(networks)
+			if len(got) != len(tt.want) {
+				t.Fatalf("Merge() = %v, want %v", got, tt.want)
+			}
+			for i := range tt.want {
+				if got[i].String() != tt.want[i] {
+					t.Errorf("Merge()[%d] = %s, want %s", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestMerge_DoesNotModifyInput(t *testing.T) {
+	networks := []*net.IPNet{mustParseCIDR("10.0.1.0/24"), mustParseCIDR("10.0.0.0/24")}
+	Merge(networks)
+	if networks[0].String() != "10.0.1.0/24" || networks[1].String() != "10.0.0.0/24" {
+		t.Errorf("input changed to %v", networks)
+	}
+}
+
+func TestMerge_HostBits(t *testing.T) {
+	networks := []*net.IPNet{
+		{IP: net.ParseIP("10.0.0.9").To4(), Mask: net.CIDRMask(25, 32)},
+		{IP: net.ParseIP("10.0.0.200"), Mask: net.CIDRMask(25, 32)},
+	}
+	if got := Merge(networks); len(got) != 1 || got[0].String() != "10.0.0.0/24" {
+		t.Errorf("Merge() = %v, want [10.0.0.0/24]", got)
+	}
+}
+
+func TestCoveringSupernet_Errors(t *testing.T) {
+	if _, err := CoveringSupernet(nil); err == nil {
+		t.Error("expected error for no networks")
+	}
+
+	mixed := []*net.IPNet{mustParseCIDR("10.0.0.0/8"), mustParseCIDR("fd00::/8")}
+	if _, err := CoveringSupernet(mixed); err == nil {
+		t.Error("expected error for mixed address families")
+	}
+}
+
+func TestUncoveredAddressCount(t *testing.T) {
+	tests := []struct {
+		network string
+		by      []string
+		want    string
+	}{
+		{network: "10.0.0.0/16", want: "65536"},
+		{network: "10.0.0.0/16", by: []string{"10.0.0.0/16"}, want: "0"},
+		{network: "10.0.0.0/15", by: []string{"10.0.0.0/16", "10.1.0.0/20", "10.1.16.0/20"}, want: "57344"},
+		{network: "10.0.0.0/16", by: []string{"10.0.0.0/17", "10.0.0.0/18"}, want: "32768"},
+		{network: "::/0", by: []string{"::/1"}, want: "170141183460469231731687303715884105728"},
+	}
+
+	for _, tt := range tests {
+		var by []*net.IPNet
+		for _, n := range tt.by {
+			by = append(by, mustParseCIDR(n))
+		}
+		if got := UncoveredAddressCount(mustParseCIDR(tt.network), by); got.String() != tt.want {
+			t.Errorf("UncoveredAddressCount(%s, %v) = %s, want %s", tt.network, tt.by, got, tt.want)
+		}
+	}
+}
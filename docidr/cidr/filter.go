@@ -0,0 +1,29 @@
+package cidr
+
+import "net"
+
+// NetworksContainedBy returns the networks that are subnets of parent or equal
+// to it, in their original order. Networks of a different address family are
+// never contained.
+func NetworksContainedBy(parent *net.IPNet, networks []*net.IPNet) []*net.IPNet {
+	var result []*net.IPNet
+	for _, network := range networks {
+		if ContainsNetwork(parent, network) {
+			result = append(result, network)
+		}
+	}
+	return result
+}
+
+// NetworksOutside returns the networks that NetworksContainedBy leaves out, in
+// their original order. These include supernets of parent, which overlap it
+// without being contained by it.
+func NetworksOutside(parent *net.IPNet, networks []*net.IPNet) []*net.IPNet {
+	var result []*net.IPNet
+	for _, network := range networks {
+		if !ContainsNetwork(parent, network) {
+			result = append(result, network)
+		}
+	}
+	return result
+}
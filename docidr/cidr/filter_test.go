@@ -0,0 +1,65 @@
+package cidr
+
+import (
+	"net"
+	"testing"
+)
+
+func TestNetworksContainedBy(t *testing.T) {
+	parent := mustParseCIDR("10.0.0.0/16")
+	networks := []*net.IPNet{
+		mustParseCIDR("10.0.1.0/24"),
+		mustParseCIDR("192.168.0.0/16"),
+		mustParseCIDR("10.0.0.0/16"),
+		mustParseCIDR("10.0.0.0/8"),
+		mustParseCIDR("10.1.0.0/24"),
+		mustParseCIDR("fd00::/64"),
+		mustParseCIDR("10.0.255.255/32"),
+	}
+
+	tests := []struct {
+		name string
+		got  []*net.IPNet
+		want []string
+	}{
+		{
+			name: "contained",
+			got:  NetworksContainedBy(parent, networks),
+			want: []string{"10.0.1.0/24", "10.0.0.0/16", "10.0.255.255/32"},
+		},
+		{
+			name: "outside",
+			got:  NetworksOutside(parent, networks),
+			want: []string{"192.168.0.0/16", "10.0.0.0/8", "10.1.0.0/24", "fd00::/64"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if len(tt.got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", tt.got, tt.want)
+			}
+			for i, want := range tt.want {
+				if tt.got[i].String() != want {
+					t.Errorf("[%d] = %s, want %s", i, tt.got[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestNetworksContainedBy_Empty(t *testing.T) {
+	parent := mustParseCIDR("10.0.0.0/16")
+
+	if got := NetworksContainedBy(parent, nil); len(got) != 0 {
+		t.Errorf("NetworksContainedBy(nil) = %v, want empty", got)
+	}
+	if got := NetworksOutside(parent, nil); len(got) != 0 {
+		t.Errorf("NetworksOutside(nil) = %v, want empty", got)
+	}
+
+	networks := []*net.IPNet{mustParseCIDR("172.16.0.0/12")}
+	if got := NetworksContainedBy(parent, networks); len(got) != 0 {
+		t.Errorf("NetworksContainedBy() = %v, want empty", got)
+	}
+}
@@ -0,0 +1,131 @@
+package cidr
+
+import (
+	"encoding/csv"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// FormatTerraformLocals renders the allocations as an HCL locals block, with
+// one local named <allocation>_cidr per allocation, sorted by name and aligned
+// as terraform fmt would. If poolName is not empty, it is noted in a leading
+// comment.
+//
+// Example output:
+//
+//	# Allocations from docidr_pool 3f8a1c2b9d4e5f60
+//	locals {
+//	  cluster_cidr  = "10.1.0.0/20"
+//	  main_vpc_cidr = "10.0.0.0/16"
+//	}
+func FormatTerraformLocals(allocations map[string]string, poolName string) string {
+	names := make([]string, 0, len(allocations))
+	width := 0
+	for name := range allocations {
+		names = append(names, name)
+		if len(name) > width {
+			width = len(name)
+		}
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	if poolName != "" {
+		fmt.Fprintf(&sb, "# Allocations from docidr_pool %s\n", strings.ReplaceAll(poolName, "\n", " "))
+	}
+	sb.WriteString("locals {\n")
+	for _, name := range names {
+		fmt.Fprintf(&sb, "  %-*s = %s\n", width+len("_cidr"), name+"_cidr", quoteHCLString(allocations[name]))
+	}
+	sb.WriteString("}\n")
+	return sb.String()
+}
+
+// quoteHCLString returns s as a quoted HCL string literal. In addition to the
+// usual escapes, template sequences are escaped so that s is not interpolated.
+func quoteHCLString(s string) string {
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch c {
+		case '"':
+			sb.WriteString(`\"`)
+		case '\\':
+			sb.WriteString(`\\`)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\r':
+			sb.WriteString(`\r`)
+		case '\t':
+			sb.WriteString(`\t`)
+		case '$', '%':
+			sb.WriteByte(c)
+			if i+1 < len(s) && s[i+1] == '{' {
+				sb.WriteByte(c)
+			}
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
+
+// csvHeader is the header row of FormatCSV.
+var csvHeader = []string{"name", "cidr", "prefix_length", "network_address", "broadcast_address", "first_host", "last_host", "host_count"}
+
+// FormatCSV renders the allocations as CSV with a header row, one row per
+// allocation in request order, for importing into spreadsheets and CMDBs.
+// Host addresses and counts are those of UsableHosts, so a /31 has two hosts
+// and a /32 one. Requests that weren't allocated are skipped. Rows end in a
+// newline.
+//
+// Example output:
+//
+//	name,cidr,prefix_length,network_address,broadcast_address,first_host,last_host,host_count
+//	main_vpc,10.0.0.0/16,16,10.0.0.0,10.0.255.255,10.0.0.1,10.0.255.254,65534
+func FormatCSV(allocations map[string]string, requests []AllocationRequest) (string, error) {
+	var sb strings.Builder
+	w := csv.NewWriter(&sb)
+	if err := w.Write(csvHeader); err != nil {
+		return "", err
+	}
+
+	for _, req := range requests {
+		block, ok := allocations[req.Name]
+		if !ok {
+			continue
+		}
+		network, err := ParseCIDR(block)
+		if err != nil {
+			return "", fmt.Errorf("allocation %q: %w", req.Name, err)
+		}
+		prefixLength, _ := network.Mask.Size()
+		firstHost, lastHost, hostCount := UsableHosts(network)
+		if firstHost == nil {
+			return "", fmt.Errorf("allocation %q: %s is not an IPv4 network", req.Name, block)
+		}
+		row := []string{
+			req.Name,
+			network.String(),
+			strconv.Itoa(prefixLength),
+			networkIP(network).String(),
+			LastAddress(network).String(),
+			firstHost.String(),
+			lastHost.String(),
+			strconv.Itoa(hostCount),
+		}
+		if err := w.Write(row); err != nil {
+			return "", err
+		}
+	}
+
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
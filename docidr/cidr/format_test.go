@@ -0,0 +1,205 @@
+package cidr
+
+import (
+	"encoding/csv"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+)
+
+// parseLocals parses src as HCL and returns the value of each local.
+func parseLocals(t *testing.T, src string) map[string]string {
+	t.Helper()
+
+	file, diags := hclsyntax.ParseConfig([]byte(src), "locals.tf", hcl.InitialPos)
+	if diags.HasErrors() {
+		t.Fatalf("generated HCL does not parse: %s\n%s", diags.Error(), src)
+	}
+
+	content, diags := file.Body.Content(&hcl.BodySchema{
+		Blocks: []hcl.BlockHeaderSchema{{Type: "locals"}},
+	})
+	if diags.HasErrors() {
+		t.Fatalf("unexpected content: %s", diags.Error())
+	}
+	if len(content.Blocks) != 1 {
+		t.Fatalf("expected 1 locals block, got %d", len(content.Blocks))
+	}
+
+	attrs, diags := content.Blocks[0].Body.JustAttributes()
+	if diags.HasErrors() {
+		t.Fatalf("unexpected attributes: %s", diags.Error())
+	}
+
+	result := make(map[string]string, len(attrs))
+	for name, attr := range attrs {
+		value, diags := attr.Expr.Value(nil)
+		if diags.HasErrors() {
+			t.Fatalf("local %s: %s", name, diags.Error())
+		}
+		result[name] = value.AsString()
+	}
+	return result
+}
+
+func TestFormatTerraformLocals(t *testing.T) {
+	allocations := map[string]string{
+		"main_vpc": "10.0.0.0/16",
+		"cluster":  "10.1.0.0/20",
+	}
+
+	got := FormatTerraformLocals(allocations, "")
+	want := `locals {
+  cluster_cidr  = "10.1.0.0/20"
+  main_vpc_cidr = "10.0.0.0/16"
+}
+`
+	if got != want {
+		t.Errorf("FormatTerraformLocals() =\n%s\nwant:\n%s", got, want)
+	}
+
+	locals := parseLocals(t, got)
+	if len(locals) != 2 || locals["main_vpc_cidr"] != "10.0.0.0/16" || locals["cluster_cidr"] != "10.1.0.0/20" {
+		t.Errorf("parsed locals = %v", locals)
+	}
+}
+
+func TestFormatTerraformLocals_PoolName(t *testing.T) {
+	got := FormatTerraformLocals(map[string]string{"vpc": "10.0.0.0/16"}, "abc123")
+	want := `# Allocations from docidr_pool abc123
+locals {
+  vpc_cidr = "10.0.0.0/16"
+}
+`
+	if got != want {
+		t.Errorf("FormatTerraformLocals() =\n%s\nwant:\n%s", got, want)
+	}
+	parseLocals(t, got)
+
+	// A newline in the name must not end the comment early.
+	parseLocals(t, FormatTerraformLocals(map[string]string{"vpc": "10.0.0.0/16"}, "abc\nlocals {"))
+}
+
+func TestFormatTerraformLocals_Empty(t *testing.T) {
+	got := FormatTerraformLocals(nil, "")
+	if got != "locals {\n}\n" {
+		t.Errorf("FormatTerraformLocals(nil) = %q", got)
+	}
+	if locals := parseLocals(t, got); len(locals) != 0 {
+		t.Errorf("parsed locals = %v, want none", locals)
+	}
+}
+
+func TestFormatTerraformLocals_Quoting(t *testing.T) {
+	values := []string{
+		`quote"d`,
+		`back\slash`,
+		"new\nline",
+		"${interpolation}",
+		"%{ if true }directive%{ endif }",
+		"$$ and %% and $ and %",
+	}
+
+	allocations := make(map[string]string, len(values))
+	for i, v := range values {
+		allocations[string(rune('a'+i))] = v
+	}
+
+	locals := parseLocals(t, FormatTerraformLocals(allocations, ""))
+	for name, want := range allocations {
+		if got := locals[name+"_cidr"]; got != want {
+			t.Errorf("%s_cidr = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestFormatCSV(t *testing.T) {
+	allocations := map[string]string{
+		"main_vpc":   "10.0.0.0/16",
+		"k8s":        "10.1.0.0/20",
+		"link":       "10.2.0.0/31",
+		"loopback":   "10.2.0.2/32",
+		"odd,name":   "10.3.0.0/24",
+		"not_wanted": "10.4.0.0/24",
+	}
+	requests := []AllocationRequest{
+		{Name: "main_vpc", PrefixLength: 16},
+		{Name: "k8s", PrefixLength: 20},
+		{Name: "unallocated", PrefixLength: 24},
+		{Name: "link", PrefixLength: 31},
+		{Name: "loopback", PrefixLength: 32},
+		{Name: "odd,name", PrefixLength: 24},
+	}
+
+	out, err := FormatCSV(allocations, requests)
+	if err != nil {
+		t.Fatalf("FormatCSV() error = %v", err)
+	}
+	if !strings.HasSuffix(out, "\n") || strings.Contains(out, "\r") {
+		t.Errorf("FormatCSV() = %q, want newline-terminated rows", out)
+	}
+
+	records, err := csv.NewReader(strings.NewReader(out)).ReadAll()
+	if err != nil {
+		t.Fatalf("generated CSV does not parse: %v\n%s", err, out)
+	}
+	if len(records) != 6 {
+		t.Fatalf("FormatCSV() has %d rows, want a header and 5 allocations:\n%s", len(records), out)
+	}
+	if got := strings.Join(records[0], ","); got != "name,cidr,prefix_length,network_address,broadcast_address,first_host,last_host,host_count" {
+		t.Errorf("header = %s", got)
+	}
+
+	for _, record := range records[1:] {
+		if len(record) != 8 {
+			t.Fatalf("row %v has %d columns, want 8", record, len(record))
+		}
+		network := mustParseCIDR(record[1])
+		if prefixLength, err := strconv.Atoi(record[2]); err != nil {
+			t.Errorf("%s prefix_length %q is not an integer", record[0], record[2])
+		} else if ones, _ := network.Mask.Size(); prefixLength != ones {
+			t.Errorf("%s prefix_length = %d, want %d", record[0], prefixLength, ones)
+		}
+		for i, column := range []string{"network_address", "broadcast_address", "first_host", "last_host"} {
+			ip := net.ParseIP(record[3+i])
+			if ip == nil || ip.To4() == nil {
+				t.Errorf("%s %s %q is not an IPv4 address", record[0], column, record[3+i])
+			} else if !network.Contains(ip) {
+				t.Errorf("%s %s %s is outside %s", record[0], column, ip, network)
+			}
+		}
+		if _, err := strconv.Atoi(record[7]); err != nil {
+			t.Errorf("%s host_count %q is not an integer", record[0], record[7])
+		}
+	}
+
+	want := [][]string{
+		{"main_vpc", "10.0.0.0/16", "16", "10.0.0.0", "10.0.255.255", "10.0.0.1", "10.0.255.254", "65534"},
+		{"k8s", "10.1.0.0/20", "20", "10.1.0.0", "10.1.15.255", "10.1.0.1", "10.1.15.254", "4094"},
+		{"link", "10.2.0.0/31", "31", "10.2.0.0", "10.2.0.1", "10.2.0.0", "10.2.0.1", "2"},
+		{"loopback", "10.2.0.2/32", "32", "10.2.0.2", "10.2.0.2", "10.2.0.2", "10.2.0.2", "1"},
+		{"odd,name", "10.3.0.0/24", "24", "10.3.0.0", "10.3.0.255", "10.3.0.1", "10.3.0.254", "254"},
+	}
+	if !reflect.DeepEqual(records[1:], want) {
+		t.Errorf("FormatCSV() rows = %v, want %v", records[1:], want)
+	}
+}
+
+func TestFormatCSV_Errors(t *testing.T) {
+	if _, err := FormatCSV(map[string]string{"bad": "not-a-cidr"}, []AllocationRequest{{Name: "bad"}}); err == nil {
+		t.Error("FormatCSV() with an invalid CIDR error = nil, want error")
+	}
+
+	out, err := FormatCSV(nil, nil)
+	if err != nil {
+		t.Fatalf("FormatCSV(nil, nil) error = %v", err)
+	}
+	if want := strings.Join(csvHeader, ",") + "\n"; out != want {
+		t.Errorf("FormatCSV(nil, nil) = %q, want only the header %q", out, want)
+	}
+}
@@ -0,0 +1,172 @@
+package cidr
+
+import (
+	"fmt"
+	"math/big"
+	"net"
+)
+
+// AddressCount returns the number of addresses in the network.
+func AddressCount(network *net.IPNet) *big.Int {
+	ones, bits := network.Mask.Size()
+	return new(big.Int).Lsh(big.NewInt(1), uint(bits-ones))
+}
+
+// Utilization returns the percentage of the base CIDR consumed by the
+// allocations. It is the sum of the values returned by UtilizationBreakdown.
+func Utilization(baseCIDR string, allocations map[string]string) (float64, error) {
+	fractions, err := utilizationFractions(baseCIDR, allocations)
+	if err != nil {
+		return 0, err
+	}
+
+	total := new(big.Rat)
+	for _, fraction := range fractions {
+		total.Add(total, fraction)
+	}
+	return ratToPercent(total), nil
+}
+
+// UtilizationBreakdown returns the percentage of the base CIDR consumed by each
+// allocation, keyed by allocation name. Every allocation must fall within the
+// base CIDR.
+func UtilizationBreakdown(baseCIDR string, allocations map[string]string) (map[string]float64, error) {
+	fractions, err := utilizationFractions(baseCIDR, allocations)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]float64, len(fractions))
+	for name, fraction := range fractions {
+		result[name] = ratToPercent(fraction)
+	}
+	return result, nil
+}
+
+// utilizationFractions returns the exact fraction of the base CIDR consumed by
+// each allocation.
+func utilizationFractions(baseCIDR string, allocations map[string]string) (map[string]*big.Rat, error) {
+	base, err := ParseCIDR(baseCIDR)
+	if err != nil {
+		return nil, err
+	}
+	baseSize := AddressCount(base)
+
+	result := make(map[string]*big.Rat, len(allocations))
+	for name, cidrBlock := range allocations {
+		network, err := ParseCIDR(cidrBlock)
+		if err != nil {
+			return nil, fmt.Errorf("allocation %q: %w", name, err)
+		}
+		if !ContainsNetwork(base, network) {
+			return nil, fmt.Errorf("allocation %q (%s) is not within base CIDR %s", name, cidrBlock, baseCIDR)
+		}
+		result[name] = new(big.Rat).SetFrac(AddressCount(network), baseSize)
+	}
+	return result, nil
+}
+
+// ratToPercent converts a fraction to a percentage.
+func ratToPercent(fraction *big.Rat) float64 {
+	percent, _ := new(big.Rat).Mul(fraction, big.NewRat(100, 1)).Float64()
+	return percent
+}
+
+// LastAddress returns the highest address in the network. For IPv4 networks
+// larger than a /31, this is the broadcast address.
+func LastAddress(network *net.IPNet) net.IP {
+	ip := networkIP(network)
+	last := make(net.IP, len(ip))
+	for i := range ip {
+		last[i] = ip[i] | ^network.Mask[i]
+	}
+	return last
+}
+
+// WildcardMask returns the inverse of the network's mask, as used by ACLs.
+func WildcardMask(network *net.IPNet) net.IPMask {
+	mask := make(net.IPMask, len(network.Mask))
+	for i := range network.Mask {
+		mask[i] = ^network.Mask[i]
+	}
+	return mask
+}
+
+// UsableHosts returns the first and last addresses that can be assigned to
+// hosts in an IPv4 network, and how many there are. The network and broadcast
+// addresses are excluded, except in a /31, where both addresses are usable
+// (RFC 3021), and a /32, which is a single host. IPv6 networks return nil
+// addresses and a count of 0.
+func UsableHosts(network *net.IPNet) (firstHost net.IP, lastHost net.IP, count int) {
+	ones, bits := network.Mask.Size()
+	if bits != 32 {
+		return nil, nil, 0
+	}
+
+	first := ipToUint32(network.IP.Mask(network.Mask))
+	last := ipToUint32(LastAddress(network))
+	if ones <= 30 {
+		first, last = first+1, last-1
+	}
+	return uint32ToIP(first), uint32ToIP(last), int(last-first) + 1
+}
+
+// AdjacentNetwork returns the network of the same size that is offset blocks
+// after network, or before it when offset is negative. For example, the next
+// network after 10.0.0.0/24 is 10.0.1.0/24. It returns an error if the result
+// would fall outside the address space.
+func AdjacentNetwork(network *net.IPNet, offset int) (*net.IPNet, error) {
+	ip := networkIP(network)
+	ones, bits := network.Mask.Size()
+
+	start := new(big.Int).SetBytes(ip)
+	start.Add(start, new(big.Int).Mul(AddressCount(network), big.NewInt(int64(offset))))
+	limit := new(big.Int).Lsh(big.NewInt(1), uint(bits))
+	if start.Sign() < 0 || start.Cmp(limit) >= 0 {
+		return nil, fmt.Errorf("no network %d blocks from %s within the address space", offset, network.String())
+	}
+
+	next := make(net.IP, len(ip))
+	start.FillBytes(next)
+	return &net.IPNet{IP: next, Mask: net.CIDRMask(ones, bits)}, nil
+}
+
+// RebaseNetwork returns the network at the same offset within to as network
+// is within from. For example, 10.0.4.0/24 in 10.0.0.0/12 rebases to
+// 10.64.4.0/24 in 10.64.0.0/12. It returns an error if network isn't within
+// from, or the offset doesn't fit within to.
+func RebaseNetwork(network, from, to *net.IPNet) (*net.IPNet, error) {
+	if !sameFamily(network, to) || !ContainsNetwork(from, network) {
+		return nil, fmt.Errorf("%s is not within %s", network.String(), from.String())
+	}
+
+	offset := new(big.Int).Sub(new(big.Int).SetBytes(networkIP(network)), new(big.Int).SetBytes(networkIP(from)))
+	if new(big.Int).Add(offset, AddressCount(network)).Cmp(AddressCount(to)) > 0 {
+		return nil, fmt.Errorf("the offset of %s within %s is outside %s", network.String(), from.String(), to.String())
+	}
+
+	ones, bits := network.Mask.Size()
+	start := new(big.Int).Add(new(big.Int).SetBytes(networkIP(to)), offset)
+	rebased := make(net.IP, len(networkIP(to)))
+	start.FillBytes(rebased)
+	return &net.IPNet{IP: rebased, Mask: net.CIDRMask(ones, bits)}, nil
+}
+
+// CIDRSubnetArgs returns the newbits and netnum arguments for which
+// Terraform's cidrsubnet(base, newbits, netnum) returns network. For example,
+// 10.0.4.0/24 in 10.0.0.0/16 is cidrsubnet("10.0.0.0/16", 8, 4). It returns an
+// error if network isn't within base, or netnum is too large for a number.
+func CIDRSubnetArgs(base, network *net.IPNet) (newbits int, netnum int64, err error) {
+	if !ContainsNetwork(base, network) {
+		return 0, 0, fmt.Errorf("%s is not within %s", network.String(), base.String())
+	}
+
+	baseOnes, _ := base.Mask.Size()
+	ones, bits := network.Mask.Size()
+	offset := new(big.Int).Sub(new(big.Int).SetBytes(networkIP(network)), new(big.Int).SetBytes(networkIP(base)))
+	index := offset.Rsh(offset, uint(bits-ones))
+	if !index.IsInt64() {
+		return 0, 0, fmt.Errorf("the netnum of %s within %s is too large", network.String(), base.String())
+	}
+	return ones - baseOnes, index.Int64(), nil
+}
@@ -0,0 +1,337 @@
+package cidr
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestUtilizationBreakdown_SingleAllocation(t *testing.T) {
+	got, err := UtilizationBreakdown("10.0.0.0/16", map[string]string{"subnet": "10.0.1.0/24"})
+	if err != nil {
+		t.Fatalf("UtilizationBreakdown() error = %v", err)
+	}
+	if got["subnet"] != 0.390625 {
+		t.Errorf("subnet = %v, want 0.390625", got["subnet"])
+	}
+}
+
+func TestUtilizationBreakdown_SumsToUtilization(t *testing.T) {
+	allocations := map[string]string{
+		"vpc":     "10.0.0.0/16",
+		"cluster": "10.1.0.0/20",
+		"subnet":  "10.2.0.0/24",
+	}
+	for i := 0; i < 100; i++ {
+		allocations[fmt.Sprintf("subnet%d", i)] = fmt.Sprintf("10.3.%d.0/24", i)
+	}
+
+	breakdown, err := UtilizationBreakdown("10.0.0.0/8", allocations)
+	if err != nil {
+		t.Fatalf("UtilizationBreakdown() error = %v", err)
+	}
+	total, err := Utilization("10.0.0.0/8", allocations)
+	if err != nil {
+		t.Fatalf("Utilization() error = %v", err)
+	}
+
+	var sum float64
+	for _, percent := range breakdown {
+		sum += percent
+	}
+	if math.Abs(sum-total) > 1e-9 {
+		t.Errorf("sum of breakdown = %v, want %v", sum, total)
+	}
+
+	// /16 + /20 + /24 + 100 * /24 out of a /8
+	want := (65536.0 + 4096 + 256 + 100*256) / 16777216 * 100
+	if math.Abs(total-want) > 1e-12 {
+		t.Errorf("Utilization() = %v, want %v", total, want)
+	}
+}
+
+func TestUtilizationBreakdown_Empty(t *testing.T) {
+	got, err := UtilizationBreakdown("10.0.0.0/8", nil)
+	if err != nil {
+		t.Fatalf("UtilizationBreakdown() error = %v", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("UtilizationBreakdown() = %v, want empty", got)
+	}
+
+	total, err := Utilization("10.0.0.0/8", nil)
+	if err != nil {
+		t.Fatalf("Utilization() error = %v", err)
+	}
+	if total != 0 {
+		t.Errorf("Utilization() = %v, want 0", total)
+	}
+}
+
+func TestUtilizationBreakdown_Errors(t *testing.T) {
+	tests := []struct {
+		name        string
+		base        string
+		allocations map[string]string
+		wantErr     string
+	}{
+		{
+			name:    "invalid base",
+			base:    "10.0.0.0/33",
+			wantErr: "invalid CIDR",
+		},
+		{
+			name:        "invalid allocation",
+			base:        "10.0.0.0/8",
+			allocations: map[string]string{"vpc": "bogus"},
+			wantErr:     `allocation "vpc"`,
+		},
+		{
+			name:        "allocation outside base",
+			base:        "10.0.0.0/16",
+			allocations: map[string]string{"vpc": "10.1.0.0/24"},
+			wantErr:     "not within base CIDR",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := UtilizationBreakdown(tt.base, tt.allocations)
+			if err == nil {
+				t.Fatal("UtilizationBreakdown() expected error, got nil")
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("UtilizationBreakdown() error = %q, want it to contain %q", err.Error(), tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLastAddress(t *testing.T) {
+	tests := []struct {
+		network string
+		want    string
+	}{
+		{network: "10.0.0.0/8", want: "10.255.255.255"},
+		{network: "192.168.1.0/24", want: "192.168.1.255"},
+		{network: "192.168.1.4/31", want: "192.168.1.5"},
+		{network: "192.168.1.7/32", want: "192.168.1.7"},
+		{network: "0.0.0.0/0", want: "255.255.255.255"},
+		{network: "fd00::/64", want: "fd00::ffff:ffff:ffff:ffff"},
+	}
+
+	for _, tt := range tests {
+		if got := LastAddress(mustParseCIDR(tt.network)); got.String() != tt.want {
+			t.Errorf("LastAddress(%s) = %s, want %s", tt.network, got, tt.want)
+		}
+	}
+}
+
+func TestWildcardMask(t *testing.T) {
+	tests := []struct {
+		network string
+		want    string
+	}{
+		{network: "10.0.0.0/8", want: "0.255.255.255"},
+		{network: "192.168.1.0/24", want: "0.0.0.255"},
+		{network: "192.168.1.0/30", want: "0.0.0.3"},
+		{network: "192.168.1.7/32", want: "0.0.0.0"},
+	}
+
+	for _, tt := range tests {
+		if got := net.IP(WildcardMask(mustParseCIDR(tt.network))).String(); got != tt.want {
+			t.Errorf("WildcardMask(%s) = %s, want %s", tt.network, got, tt.want)
+		}
+	}
+}
+
+func TestUsableHosts(t *testing.T) {
+	tests := []struct {
+		network   string
+		wantFirst string
+		wantLast  string
+		wantCount int
+	}{
+		{network: "192.168.1.7/32", wantFirst: "192.168.1.7", wantLast: "192.168.1.7", wantCount: 1},
+		{network: "192.168.1.4/31", wantFirst: "192.168.1.4", wantLast: "192.168.1.5", wantCount: 2},
+		{network: "192.168.1.4/30", wantFirst: "192.168.1.5", wantLast: "192.168.1.6", wantCount: 2},
+		{network: "192.168.1.0/24", wantFirst: "192.168.1.1", wantLast: "192.168.1.254", wantCount: 254},
+		{network: "10.0.0.0/8", wantFirst: "10.0.0.1", wantLast: "10.255.255.254", wantCount: 16777214},
+	}
+
+	for _, tt := range tests {
+		first, last, count := UsableHosts(mustParseCIDR(tt.network))
+		if first.String() != tt.wantFirst || last.String() != tt.wantLast || count != tt.wantCount {
+			t.Errorf("UsableHosts(%s) = %s, %s, %d, want %s, %s, %d",
+				tt.network, first, last, count, tt.wantFirst, tt.wantLast, tt.wantCount)
+		}
+	}
+
+	if first, last, count := UsableHosts(mustParseCIDR("fd00::/64")); first != nil || last != nil || count != 0 {
+		t.Errorf("UsableHosts(fd00::/64) = %s, %s, %d, want nil, nil, 0", first, last, count)
+	}
+}
+
+func TestAdjacentNetwork(t *testing.T) {
+	tests := []struct {
+		network string
+		offset  int
+		want    string
+		wantErr bool
+	}{
+		{network: "10.0.0.0/24", offset: 1, want: "10.0.1.0/24"},
+		{network: "10.0.0.0/24", offset: -1, want: "9.255.255.0/24"},
+		{network: "10.0.0.0/24", offset: 4, want: "10.0.4.0/24"},
+		{network: "10.0.0.7/24", offset: 1, want: "10.0.1.0/24"},
+		{network: "10.0.0.0/8", offset: 1, want: "11.0.0.0/8"},
+		{network: "255.255.255.0/24", offset: 1, wantErr: true},
+		{network: "0.0.0.0/24", offset: -1, wantErr: true},
+		{network: "0.0.0.0/0", offset: 1, wantErr: true},
+		{network: "fd00::/64", offset: 1, want: "fd00:0:0:1::/64"},
+		{network: "fd00::/64", offset: -1, want: "fcff:ffff:ffff:ffff::/64"},
+	}
+
+	for _, tt := range tests {
+		got, err := AdjacentNetwork(mustParseCIDR(tt.network), tt.offset)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("AdjacentNetwork(%s, %d) = %s, want error", tt.network, tt.offset, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("AdjacentNetwork(%s, %d) error = %v", tt.network, tt.offset, err)
+			continue
+		}
+		if got.String() != tt.want {
+			t.Errorf("AdjacentNetwork(%s, %d) = %s, want %s", tt.network, tt.offset, got, tt.want)
+		}
+	}
+}
+
+func TestRebaseNetwork(t *testing.T) {
+	tests := []struct {
+		network string
+		from    string
+		to      string
+		want    string
+		wantErr bool
+	}{
+		{network: "10.0.4.0/24", from: "10.0.0.0/12", to: "10.64.0.0/12", want: "10.64.4.0/24"},
+		{network: "10.0.0.0/16", from: "10.0.0.0/12", to: "10.64.0.0/12", want: "10.64.0.0/16"},
+		{network: "10.15.255.0/24", from: "10.0.0.0/12", to: "10.64.0.0/12", want: "10.79.255.0/24"},
+		{network: "10.0.4.0/24", from: "10.0.0.0/12", to: "172.16.0.0/16", want: "172.16.4.0/24"},
+		{network: "10.0.4.0/24", from: "10.0.0.0/12", to: "192.168.0.0/8", want: "192.0.4.0/24"},
+		{network: "10.0.0.0/12", from: "10.0.0.0/12", to: "10.64.0.0/12", want: "10.64.0.0/12"},
+		{network: "fd00:0:0:1::/64", from: "fd00::/48", to: "fd01::/48", want: "fd01:0:0:1::/64"},
+		// The offset is past the end of the smaller base
+		{network: "10.1.0.0/24", from: "10.0.0.0/12", to: "172.16.0.0/16", wantErr: true},
+		// The network is larger than the new base
+		{network: "10.0.0.0/16", from: "10.0.0.0/12", to: "172.16.0.0/20", wantErr: true},
+		{network: "10.16.0.0/24", from: "10.0.0.0/12", to: "10.64.0.0/12", wantErr: true},
+		{network: "10.0.4.0/24", from: "10.0.0.0/12", to: "fd00::/48", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := RebaseNetwork(mustParseCIDR(tt.network), mustParseCIDR(tt.from), mustParseCIDR(tt.to))
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("RebaseNetwork(%s, %s, %s) = %s, want error", tt.network, tt.from, tt.to, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("RebaseNetwork(%s, %s, %s) error = %v", tt.network, tt.from, tt.to, err)
+			continue
+		}
+		if got.String() != tt.want {
+			t.Errorf("RebaseNetwork(%s, %s, %s) = %s, want %s", tt.network, tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+// cidrsubnet reimplements Terraform's cidrsubnet function, to check
+// CIDRSubnetArgs against.
+func cidrsubnet(t *testing.T, base *net.IPNet, newbits int, netnum int64) *net.IPNet {
+	t.Helper()
+
+	ones, bits := base.Mask.Size()
+	if ones+newbits > bits {
+		t.Fatalf("cidrsubnet(%s, %d, %d): insufficient address space", base, newbits, netnum)
+	}
+	if netnum < 0 || big.NewInt(netnum).BitLen() > newbits {
+		t.Fatalf("cidrsubnet(%s, %d, %d): netnum doesn't fit in newbits", base, newbits, netnum)
+	}
+
+	ip := new(big.Int).SetBytes(networkIP(base))
+	ip.Or(ip, new(big.Int).Lsh(big.NewInt(netnum), uint(bits-ones-newbits)))
+	result := make(net.IP, len(networkIP(base)))
+	ip.FillBytes(result)
+	return &net.IPNet{IP: result, Mask: net.CIDRMask(ones+newbits, bits)}
+}
+
+func TestCIDRSubnetArgs(t *testing.T) {
+	tests := []struct {
+		base        string
+		network     string
+		wantNewbits int
+		wantNetnum  int64
+		wantErr     bool
+	}{
+		{base: "10.0.0.0/16", network: "10.0.4.0/24", wantNewbits: 8, wantNetnum: 4},
+		{base: "10.0.0.0/16", network: "10.0.0.0/20", wantNewbits: 4, wantNetnum: 0},
+		{base: "10.0.0.0/16", network: "10.0.240.0/20", wantNewbits: 4, wantNetnum: 15},
+		{base: "10.0.0.0/16", network: "10.0.0.0/16", wantNewbits: 0, wantNetnum: 0},
+		{base: "10.0.0.0/8", network: "10.255.255.255/32", wantNewbits: 24, wantNetnum: 16777215},
+		{base: "172.16.0.0/12", network: "172.31.2.0/23", wantNewbits: 11, wantNetnum: 1921},
+		{base: "10.0.0.0/16", network: "10.0.16.4/31", wantNewbits: 15, wantNetnum: 2050},
+		{base: "0.0.0.0/0", network: "192.168.1.0/24", wantNewbits: 24, wantNetnum: 12625921},
+		{base: "fd00::/48", network: "fd00:0:0:ff::/64", wantNewbits: 16, wantNetnum: 255},
+		{base: "10.0.0.0/16", network: "10.1.0.0/24", wantErr: true},
+		{base: "10.0.0.0/16", network: "10.0.0.0/8", wantErr: true},
+		{base: "10.0.0.0/16", network: "fd00::/64", wantErr: true},
+		// netnum would need more than 63 bits
+		{base: "fd00::/48", network: "fd00:0:0:ffff::/128", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		base, network := mustParseCIDR(tt.base), mustParseCIDR(tt.network)
+		newbits, netnum, err := CIDRSubnetArgs(base, network)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("CIDRSubnetArgs(%s, %s) = %d, %d, want error", tt.base, tt.network, newbits, netnum)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("CIDRSubnetArgs(%s, %s) error = %v", tt.base, tt.network, err)
+			continue
+		}
+		if newbits != tt.wantNewbits || netnum != tt.wantNetnum {
+			t.Errorf("CIDRSubnetArgs(%s, %s) = %d, %d, want %d, %d", tt.base, tt.network, newbits, netnum, tt.wantNewbits, tt.wantNetnum)
+		}
+		if got := cidrsubnet(t, base, newbits, netnum); got.String() != network.String() {
+			t.Errorf("cidrsubnet(%s, %d, %d) = %s, want %s", tt.base, newbits, netnum, got, tt.network)
+		}
+	}
+}
+
+func TestCIDRSubnetArgs_RoundTrip(t *testing.T) {
+	// Every block of each size within the base reproduces itself
+	base := mustParseCIDR("10.20.0.0/20")
+	for newbits := 0; newbits <= 8; newbits++ {
+		for netnum := int64(0); netnum < 1<<newbits; netnum++ {
+			network := cidrsubnet(t, base, newbits, netnum)
+			gotNewbits, gotNetnum, err := CIDRSubnetArgs(base, network)
+			if err != nil {
+				t.Fatalf("CIDRSubnetArgs(%s, %s) error = %v", base, network, err)
+			}
+			if gotNewbits != newbits || gotNetnum != netnum {
+				t.Errorf("CIDRSubnetArgs(%s, %s) = %d, %d, want %d, %d", base, network, gotNewbits, gotNetnum, newbits, netnum)
+			}
+		}
+	}
+}
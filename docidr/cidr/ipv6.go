@@ -0,0 +1,28 @@
+package cidr
+
+import (
+	"crypto/rand"
+	"fmt"
+	"net"
+)
+
+// ULABase is the fd00::/8 block that locally assigned RFC 4193 unique local
+// addresses are drawn from.
+var ULABase = &net.IPNet{IP: net.ParseIP("fd00::"), Mask: net.CIDRMask(8, 128)}
+
+// GenerateULABase returns an RFC 4193 unique local /48 prefix. With a seed, the
+// Global ID is derived from it as ULAPrefix does, so the same seed always
+// yields the same prefix, and docidr_ula with the same seed agrees. Without
+// one, the Global ID is 40 random bits from crypto/rand, as RFC 4193 intends.
+func GenerateULABase(seed []byte) (string, error) {
+	if len(seed) > 0 {
+		return ULAPrefix(string(seed)).String(), nil
+	}
+
+	ip := make(net.IP, net.IPv6len)
+	ip[0] = 0xfd
+	if _, err := rand.Read(ip[1:6]); err != nil {
+		return "", fmt.Errorf("error generating ULA Global ID: %w", err)
+	}
+	return (&net.IPNet{IP: ip, Mask: net.CIDRMask(ULAPrefixLength, 128)}).String(), nil
+}
@@ -0,0 +1,65 @@
+package cidr
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestGenerateULABase(t *testing.T) {
+	seen := map[string]bool{}
+	for i := 0; i < 1000; i++ {
+		seed := []byte(fmt.Sprintf("pool-%d", i))
+		got, err := GenerateULABase(seed)
+		if err != nil {
+			t.Fatalf("GenerateULABase(%q) error = %v", seed, err)
+		}
+		checkULABase(t, got)
+
+		again, _ := GenerateULABase(seed)
+		if again != got {
+			t.Errorf("GenerateULABase(%q) = %s, then %s, want the same prefix", seed, got, again)
+		}
+		seen[got] = true
+	}
+	if len(seen) != 1000 {
+		t.Errorf("1000 seeds gave %d distinct prefixes", len(seen))
+	}
+
+	if got, _ := GenerateULABase([]byte("production")); got != ULAPrefix("production").String() {
+		t.Errorf("GenerateULABase(production) = %s, want ULAPrefix %s", got, ULAPrefix("production"))
+	}
+}
+
+func TestGenerateULABase_Random(t *testing.T) {
+	seen := map[string]bool{}
+	for i := 0; i < 100; i++ {
+		got, err := GenerateULABase(nil)
+		if err != nil {
+			t.Fatalf("GenerateULABase(nil) error = %v", err)
+		}
+		checkULABase(t, got)
+		seen[got] = true
+	}
+	if len(seen) < 99 {
+		t.Errorf("100 random prefixes had only %d distinct values", len(seen))
+	}
+}
+
+// checkULABase fails the test unless s is a /48 within fd00::/8.
+func checkULABase(t *testing.T, s string) {
+	t.Helper()
+
+	network, err := ParseCIDR(s)
+	if err != nil {
+		t.Fatalf("GenerateULABase() = %q: %v", s, err)
+	}
+	if network.String() != s {
+		t.Errorf("GenerateULABase() = %s, not in canonical form %s", s, network)
+	}
+	if ones, bits := network.Mask.Size(); ones != ULAPrefixLength || bits != 128 {
+		t.Errorf("GenerateULABase() = %s, want a /%d", s, ULAPrefixLength)
+	}
+	if !ContainsNetwork(ULABase, network) {
+		t.Errorf("GenerateULABase() = %s, not within %s", s, ULABase)
+	}
+}
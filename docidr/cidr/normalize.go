@@ -0,0 +1,76 @@
+package cidr
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// Address families reported by Normalize.
+const (
+	FamilyIPv4 = "ipv4"
+	FamilyIPv6 = "ipv6"
+)
+
+// NormalizedCIDR is a CIDR parsed by Normalize.
+type NormalizedCIDR struct {
+	// Network is the canonical network, with any host bits cleared.
+	Network *net.IPNet
+	// IP is the address as given, including any host bits.
+	IP           net.IP
+	PrefixLength int
+	// HadHostBits is true when IP had bits set beyond the prefix length.
+	HadHostBits bool
+	Family      string
+}
+
+// Normalize parses a CIDR from a messy source. Surrounding whitespace is
+// trimmed, IPv6 hex digits may be in either case, host bits may be set, and a
+// bare IP address is read as a single-address network (/32 or /128). Anything
+// else, including IPv4-mapped IPv6 addresses and IPv6 zones, is an error
+// naming the part of the input that is wrong.
+func Normalize(input string) (*NormalizedCIDR, error) {
+	s := strings.TrimSpace(input)
+	if s == "" {
+		return nil, fmt.Errorf("invalid CIDR %q: empty", input)
+	}
+
+	address, prefix, hasPrefix := strings.Cut(s, "/")
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid CIDR %q: %q is not an IP address", input, address)
+	}
+
+	family, bits := FamilyIPv4, 32
+	if strings.Contains(address, ":") {
+		if ip.To4() != nil {
+			return nil, fmt.Errorf("invalid CIDR %q: %q is an IPv4-mapped IPv6 address; use the IPv4 address %s", input, address, ip.To4())
+		}
+		family, bits = FamilyIPv6, 128
+	} else {
+		ip = ip.To4()
+	}
+
+	ones := bits
+	if hasPrefix {
+		if prefix == "" || strings.Trim(prefix, "0123456789") != "" {
+			return nil, fmt.Errorf("invalid CIDR %q: prefix length %q is not a number", input, prefix)
+		}
+		n, err := strconv.Atoi(prefix)
+		if err != nil || n > bits {
+			return nil, fmt.Errorf("invalid CIDR %q: prefix length %s is out of range 0-%d for %s", input, prefix, bits, family)
+		}
+		ones = n
+	}
+
+	mask := net.CIDRMask(ones, bits)
+	network := &net.IPNet{IP: ip.Mask(mask), Mask: mask}
+	return &NormalizedCIDR{
+		Network:      network,
+		IP:           ip,
+		PrefixLength: ones,
+		HadHostBits:  !ip.Equal(network.IP),
+		Family:       family,
+	}, nil
+}
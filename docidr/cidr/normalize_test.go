@@ -0,0 +1,89 @@
+package cidr
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name         string
+		input        string
+		wantCIDR     string
+		wantIP       string
+		wantPrefix   int
+		wantHostBits bool
+		wantFamily   string
+	}{
+		{name: "canonical", input: "10.0.0.0/16", wantCIDR: "10.0.0.0/16", wantIP: "10.0.0.0", wantPrefix: 16, wantFamily: FamilyIPv4},
+		{name: "host bits", input: "10.0.1.5/16", wantCIDR: "10.0.0.0/16", wantIP: "10.0.1.5", wantPrefix: 16, wantHostBits: true, wantFamily: FamilyIPv4},
+		{name: "whitespace", input: "  172.16.0.0/12\n", wantCIDR: "172.16.0.0/12", wantIP: "172.16.0.0", wantPrefix: 12, wantFamily: FamilyIPv4},
+		{name: "bare IPv4", input: "192.168.1.1", wantCIDR: "192.168.1.1/32", wantIP: "192.168.1.1", wantPrefix: 32, wantFamily: FamilyIPv4},
+		{name: "zero prefix", input: "0.0.0.0/0", wantCIDR: "0.0.0.0/0", wantIP: "0.0.0.0", wantPrefix: 0, wantFamily: FamilyIPv4},
+		{name: "uppercase IPv6", input: "FD00:ABCD::/48", wantCIDR: "fd00:abcd::/48", wantIP: "fd00:abcd::", wantPrefix: 48, wantFamily: FamilyIPv6},
+		{name: "IPv6 host bits", input: "2001:DB8::1/64", wantCIDR: "2001:db8::/64", wantIP: "2001:db8::1", wantPrefix: 64, wantHostBits: true, wantFamily: FamilyIPv6},
+		{name: "expanded IPv6", input: "2001:0db8:0000:0000:0000:0000:0000:0000/32", wantCIDR: "2001:db8::/32", wantIP: "2001:db8::", wantPrefix: 32, wantFamily: FamilyIPv6},
+		{name: "bare IPv6", input: "\t::1 ", wantCIDR: "::1/128", wantIP: "::1", wantPrefix: 128, wantFamily: FamilyIPv6},
+		{name: "leading zero prefix", input: "10.0.0.0/08", wantCIDR: "10.0.0.0/8", wantIP: "10.0.0.0", wantPrefix: 8, wantFamily: FamilyIPv4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Normalize(tt.input)
+			if err != nil {
+				t.Fatalf("Normalize(%q) error = %v", tt.input, err)
+			}
+			if got.Network.String() != tt.wantCIDR {
+				t.Errorf("Network = %s, want %s", got.Network, tt.wantCIDR)
+			}
+			if got.IP.String() != tt.wantIP {
+				t.Errorf("IP = %s, want %s", got.IP, tt.wantIP)
+			}
+			if got.PrefixLength != tt.wantPrefix {
+				t.Errorf("PrefixLength = %d, want %d", got.PrefixLength, tt.wantPrefix)
+			}
+			if got.HadHostBits != tt.wantHostBits {
+				t.Errorf("HadHostBits = %v, want %v", got.HadHostBits, tt.wantHostBits)
+			}
+			if got.Family != tt.wantFamily {
+				t.Errorf("Family = %s, want %s", got.Family, tt.wantFamily)
+			}
+		})
+	}
+}
+
+func TestNormalize_Errors(t *testing.T) {
+	tests := []struct {
+		input   string
+		wantErr string
+	}{
+		{input: "", wantErr: "empty"},
+		{input: "   ", wantErr: "empty"},
+		{input: "not-a-cidr", wantErr: `"not-a-cidr" is not an IP address`},
+		{input: "10.0.0.256/24", wantErr: `"10.0.0.256" is not an IP address`},
+		{input: "10.0.0/24", wantErr: `"10.0.0" is not an IP address`},
+		{input: "010.0.0.0/8", wantErr: `"010.0.0.0" is not an IP address`},
+		{input: "10.0.0.0 /16", wantErr: `"10.0.0.0 " is not an IP address`},
+		{input: "fe80::1%eth0/64", wantErr: `"fe80::1%eth0" is not an IP address`},
+		{input: "10.0.0.0/", wantErr: `prefix length "" is not a number`},
+		{input: "10.0.0.0/abc", wantErr: `prefix length "abc" is not a number`},
+		{input: "10.0.0.0/-1", wantErr: `prefix length "-1" is not a number`},
+		{input: "10.0.0.0/+8", wantErr: `prefix length "+8" is not a number`},
+		{input: "10.0.0.0/16/24", wantErr: `prefix length "16/24" is not a number`},
+		{input: "10.0.0.0/33", wantErr: "prefix length 33 is out of range 0-32 for ipv4"},
+		{input: "fd00::/129", wantErr: "prefix length 129 is out of range 0-128 for ipv6"},
+		{input: "::ffff:10.0.0.1/120", wantErr: "IPv4-mapped IPv6 address; use the IPv4 address 10.0.0.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := Normalize(tt.input)
+			if err == nil {
+				t.Fatalf("Normalize(%q) = %+v, want error", tt.input, got)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Normalize(%q) error = %v, want it to contain %q", tt.input, err, tt.wantErr)
+			}
+		})
+	}
+}
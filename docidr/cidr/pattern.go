@@ -0,0 +1,78 @@
+package cidr
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// PatternToCIDR converts an IPv4 wildcard pattern to the CIDR block it covers.
+// Each trailing octet may be a wildcard, written "*" or "x":
+//
+//	10.1.*.*        -> 10.1.0.0/16
+//	10.x.x.x        -> 10.0.0.0/8
+//	10.x.0.0/16     -> 10.0.0.0/8 (every /16 in 10.0.0.0/8)
+//
+// With an explicit prefix length, octets after the last wildcard that fall
+// outside the prefix must be 0. Patterns with a wildcard before a fixed
+// octet, such as 10.*.1.0, don't describe a single block and are rejected.
+func PatternToCIDR(pattern string) (*net.IPNet, error) {
+	address, prefix, hasPrefix := strings.Cut(strings.TrimSpace(pattern), "/")
+
+	octets := strings.Split(address, ".")
+	if len(octets) != 4 {
+		return nil, fmt.Errorf("invalid pattern %q: expected four dot-separated octets", pattern)
+	}
+
+	prefixLength := 32
+	if hasPrefix {
+		n, err := strconv.Atoi(prefix)
+		if err != nil || n < 0 || n > 32 {
+			return nil, fmt.Errorf("invalid pattern %q: invalid prefix length %q", pattern, prefix)
+		}
+		prefixLength = n
+	}
+
+	fixed := 0
+	wildcard := false
+	values := make([]string, 4)
+	for i, octet := range octets {
+		if octet == "*" || octet == "x" {
+			wildcard = true
+			values[i] = "0"
+			continue
+		}
+
+		value, err := strconv.Atoi(octet)
+		if err != nil || value < 0 || value > 255 || strconv.Itoa(value) != octet {
+			return nil, fmt.Errorf("invalid pattern %q: invalid octet %q", pattern, octet)
+		}
+		if wildcard {
+			// A fixed octet after a wildcard is only allowed as the zero host
+			// bits of an explicit prefix, as in 10.x.0.0/16.
+			if !hasPrefix || value != 0 || i*8 < prefixLength {
+				return nil, fmt.Errorf("invalid pattern %q: ambiguous fixed octet %q after a wildcard", pattern, octet)
+			}
+		} else {
+			fixed++
+		}
+		values[i] = octet
+	}
+
+	if !wildcard {
+		if !hasPrefix {
+			return nil, fmt.Errorf("invalid pattern %q: no wildcard or prefix length", pattern)
+		}
+		return ParseCIDR(address + "/" + prefix)
+	}
+	if hasPrefix && prefixLength < fixed*8 {
+		return nil, fmt.Errorf("invalid pattern %q: prefix length /%d is shorter than the fixed octets", pattern, prefixLength)
+	}
+
+	network, err := ParseCIDR(fmt.Sprintf("%s/%d", strings.Join(values, "."), fixed*8))
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+	}
+	return network, nil
+}
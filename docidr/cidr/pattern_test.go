@@ -0,0 +1,61 @@
+package cidr
+
+import "testing"
+
+func TestPatternToCIDR(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    string
+	}{
+		{pattern: "10.1.*.*", want: "10.1.0.0/16"},
+		{pattern: "10.1.x.x", want: "10.1.0.0/16"},
+		{pattern: "10.*.*.*", want: "10.0.0.0/8"},
+		{pattern: "192.168.5.*", want: "192.168.5.0/24"},
+		{pattern: "*.*.*.*", want: "0.0.0.0/0"},
+		{pattern: "10.x.0.0/16", want: "10.0.0.0/8"},
+		{pattern: "172.16.x.0/24", want: "172.16.0.0/16"},
+		{pattern: "10.1.*.*/16", want: "10.1.0.0/16"},
+		{pattern: " 10.1.*.* ", want: "10.1.0.0/16"},
+		{pattern: "10.1.0.0/16", want: "10.1.0.0/16"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			got, err := PatternToCIDR(tt.pattern)
+			if err != nil {
+				t.Fatalf("PatternToCIDR(%q) error = %v", tt.pattern, err)
+			}
+			if got.String() != tt.want {
+				t.Errorf("PatternToCIDR(%q) = %s, want %s", tt.pattern, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPatternToCIDR_Errors(t *testing.T) {
+	patterns := []string{
+		"",
+		"10.1.*",
+		"10.1.*.*.*",
+		"10.*.1.0",
+		"10.*.0.0",
+		"10.x.0.0/24",
+		"10.x.1.0/16",
+		"10.1.*.*/8",
+		"10.1.2.3",
+		"10.1*.*.*",
+		"10.256.*.*",
+		"10.01.*.*",
+		"10.-1.*.*",
+		"10.1.*.*/33",
+		"10.1.*.*/abc",
+	}
+
+	for _, pattern := range patterns {
+		t.Run(pattern, func(t *testing.T) {
+			if got, err := PatternToCIDR(pattern); err == nil {
+				t.Errorf("PatternToCIDR(%q) = %s, want error", pattern, got)
+			}
+		})
+	}
+}
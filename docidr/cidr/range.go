@@ -0,0 +1,48 @@
+package cidr
+
+import (
+	"fmt"
+	"math/bits"
+	"net"
+)
+
+// IPRange returns the fewest CIDR blocks that cover the IPv4 range from first
+// to last, inclusive, in address order. Each block is the largest that starts
+// at the next uncovered address, is aligned to its own size and does not pass
+// last, so 10.0.0.0 to 10.0.0.6 becomes 10.0.0.0/30, 10.0.0.4/31 and
+// 10.0.0.6/32. It returns an error if either address is not IPv4 or first is
+// after last.
+func IPRange(first, last net.IP) ([]*net.IPNet, error) {
+	if first.To4() == nil || last.To4() == nil {
+		return nil, fmt.Errorf("IP range %s-%s must be IPv4", first, last)
+	}
+	start, end := uint64(ipToUint32(first)), uint64(ipToUint32(last))
+	if start > end {
+		return nil, fmt.Errorf("IP range %s-%s starts after it ends", first, last)
+	}
+
+	var networks []*net.IPNet
+	for start <= end {
+		// The block may be no larger than start's alignment allows, then
+		// shrinks until it fits before end
+		hostBits := 32
+		if start != 0 {
+			hostBits = bits.TrailingZeros64(start)
+		}
+		for start+(1<<hostBits)-1 > end {
+			hostBits--
+		}
+		networks = append(networks, &net.IPNet{
+			IP:   uint32ToIP(uint32(start)),
+			Mask: net.CIDRMask(32-hostBits, 32),
+		})
+		start += 1 << hostBits
+	}
+	return networks, nil
+}
+
+// CIDRToRange returns the first and last addresses of network, the inverse of
+// IPRange for a single block.
+func CIDRToRange(network *net.IPNet) (net.IP, net.IP) {
+	return networkIP(network), LastAddress(network)
+}
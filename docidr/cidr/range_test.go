@@ -0,0 +1,94 @@
+package cidr
+
+import (
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestIPRange(t *testing.T) {
+	tests := []struct {
+		first string
+		last  string
+		want  []string
+	}{
+		{first: "10.0.0.5", last: "10.0.0.5", want: []string{"10.0.0.5/32"}},
+		{first: "10.0.0.0", last: "10.0.0.255", want: []string{"10.0.0.0/24"}},
+		{first: "10.0.0.0", last: "10.0.0.6", want: []string{"10.0.0.0/30", "10.0.0.4/31", "10.0.0.6/32"}},
+		{first: "10.0.0.1", last: "10.0.0.127", want: []string{
+			"10.0.0.1/32", "10.0.0.2/31", "10.0.0.4/30", "10.0.0.8/29",
+			"10.0.0.16/28", "10.0.0.32/27", "10.0.0.64/26",
+		}},
+		{first: "192.168.0.100", last: "192.168.1.10", want: []string{
+			"192.168.0.100/30", "192.168.0.104/29", "192.168.0.112/28", "192.168.0.128/25",
+			"192.168.1.0/29", "192.168.1.8/31", "192.168.1.10/32",
+		}},
+		{first: "0.0.0.0", last: "255.255.255.255", want: []string{"0.0.0.0/0"}},
+		{first: "255.255.255.254", last: "255.255.255.255", want: []string{"255.255.255.254/31"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.first+"-"+tt.last, func(t *testing.T) {
+			networks, err := IPRange(net.ParseIP(tt.first), net.ParseIP(tt.last))
+			if err != nil {
+				t.Fatalf("IPRange() error = %v", err)
+			}
+			var got []string
+			for _, network := range networks {
+				got = append(got, network.String())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("IPRange() = %v, want %v", got, tt.want)
+			}
+
+			// The blocks convert back to a contiguous range
+			first, _ := CIDRToRange(networks[0])
+			_, last := CIDRToRange(networks[len(networks)-1])
+			if !first.Equal(net.ParseIP(tt.first)) || !last.Equal(net.ParseIP(tt.last)) {
+				t.Errorf("CIDRToRange() covers %s-%s, want %s-%s", first, last, tt.first, tt.last)
+			}
+		})
+	}
+}
+
+func TestIPRange_Invalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		first string
+		last  string
+	}{
+		{name: "reversed", first: "10.0.0.10", last: "10.0.0.1"},
+		{name: "IPv6", first: "fd00::1", last: "fd00::ff"},
+		{name: "mixed", first: "10.0.0.1", last: "fd00::ff"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := IPRange(net.ParseIP(tt.first), net.ParseIP(tt.last)); err == nil {
+				t.Errorf("IPRange(%s, %s) expected error, got none", tt.first, tt.last)
+			}
+		})
+	}
+}
+
+func TestCIDRToRange(t *testing.T) {
+	tests := []struct {
+		cidr      string
+		wantFirst string
+		wantLast  string
+	}{
+		{cidr: "10.0.0.0/24", wantFirst: "10.0.0.0", wantLast: "10.0.0.255"},
+		{cidr: "10.0.0.7/32", wantFirst: "10.0.0.7", wantLast: "10.0.0.7"},
+		{cidr: "172.16.0.0/12", wantFirst: "172.16.0.0", wantLast: "172.31.255.255"},
+		{cidr: "fd00::/64", wantFirst: "fd00::", wantLast: "fd00::ffff:ffff:ffff:ffff"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.cidr, func(t *testing.T) {
+			first, last := CIDRToRange(mustParseCIDR(tt.cidr))
+			if first.String() != tt.wantFirst || last.String() != tt.wantLast {
+				t.Errorf("CIDRToRange(%s) = %s-%s, want %s-%s", tt.cidr, first, last, tt.wantFirst, tt.wantLast)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package cidr
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// ReverseZones returns the reverse DNS zones covering the network, in address
+// order. IPv4 zones fall on octet boundaries under in-addr.arpa, from /8 to
+// /24, and IPv6 zones fall on nibble boundaries under ip6.arpa, from /4 to
+// /124. A network between two boundaries is covered by each zone of the next
+// boundary within it, such as the sixteen /24 zones of a /20. A network
+// smaller than the smallest zone is covered by the zone containing it, such as
+// the /24 zone of a /28.
+func ReverseZones(network *net.IPNet) []string {
+	ones, bits := network.Mask.Size()
+	unit := 8
+	if bits == 128 {
+		unit = 4
+	}
+
+	zoneBits := (ones + unit - 1) / unit * unit
+	if zoneBits < unit {
+		zoneBits = unit
+	}
+	if zoneBits > bits-unit {
+		zoneBits = bits - unit
+	}
+	count := 1
+	if zoneBits > ones {
+		count = 1 << (zoneBits - ones)
+	}
+
+	mask := net.CIDRMask(zoneBits, bits)
+	zone := &net.IPNet{IP: networkIP(network).Mask(mask), Mask: mask}
+	zones := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		if i > 0 {
+			// The zones are within the network, so the next one always exists
+			zone, _ = AdjacentNetwork(zone, 1)
+		}
+		zones = append(zones, reverseZoneName(networkIP(zone), zoneBits, unit))
+	}
+	return zones
+}
+
+// reverseZoneName returns the name of the reverse DNS zone for the first
+// zoneBits of ip, which is a multiple of unit: octets for IPv4 and nibbles for
+// IPv6.
+func reverseZoneName(ip net.IP, zoneBits, unit int) string {
+	labels := make([]string, 0, zoneBits/unit+1)
+	for i := zoneBits/unit - 1; i >= 0; i-- {
+		if unit == 8 {
+			labels = append(labels, fmt.Sprintf("%d", ip[i]))
+			continue
+		}
+		nibble := ip[i/2] >> 4
+		if i%2 == 1 {
+			nibble = ip[i/2] & 0x0f
+		}
+		labels = append(labels, fmt.Sprintf("%x", nibble))
+	}
+	if unit == 8 {
+		labels = append(labels, "in-addr.arpa")
+	} else {
+		labels = append(labels, "ip6.arpa")
+	}
+	return strings.Join(labels, ".")
+}
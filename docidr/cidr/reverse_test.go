@@ -0,0 +1,83 @@
+package cidr
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReverseZones(t *testing.T) {
+	tests := []struct {
+		network string
+		want    []string
+	}{
+		{network: "10.1.0.0/16", want: []string{"1.10.in-addr.arpa"}},
+		{network: "10.1.32.0/20", want: []string{
+			"32.1.10.in-addr.arpa",
+			"33.1.10.in-addr.arpa",
+			"34.1.10.in-addr.arpa",
+			"35.1.10.in-addr.arpa",
+			"36.1.10.in-addr.arpa",
+			"37.1.10.in-addr.arpa",
+			"38.1.10.in-addr.arpa",
+			"39.1.10.in-addr.arpa",
+			"40.1.10.in-addr.arpa",
+			"41.1.10.in-addr.arpa",
+			"42.1.10.in-addr.arpa",
+			"43.1.10.in-addr.arpa",
+			"44.1.10.in-addr.arpa",
+			"45.1.10.in-addr.arpa",
+			"46.1.10.in-addr.arpa",
+			"47.1.10.in-addr.arpa",
+		}},
+		{network: "10.1.2.0/24", want: []string{"2.1.10.in-addr.arpa"}},
+		{network: "10.1.2.48/28", want: []string{"2.1.10.in-addr.arpa"}},
+		{network: "10.1.2.0/28", want: []string{"2.1.10.in-addr.arpa"}},
+		{network: "10.1.2.255/32", want: []string{"2.1.10.in-addr.arpa"}},
+		{network: "10.0.0.0/8", want: []string{"10.in-addr.arpa"}},
+		{network: "172.16.0.0/12", want: []string{
+			"16.172.in-addr.arpa", "17.172.in-addr.arpa", "18.172.in-addr.arpa", "19.172.in-addr.arpa",
+			"20.172.in-addr.arpa", "21.172.in-addr.arpa", "22.172.in-addr.arpa", "23.172.in-addr.arpa",
+			"24.172.in-addr.arpa", "25.172.in-addr.arpa", "26.172.in-addr.arpa", "27.172.in-addr.arpa",
+			"28.172.in-addr.arpa", "29.172.in-addr.arpa", "30.172.in-addr.arpa", "31.172.in-addr.arpa",
+		}},
+		{network: "192.168.254.0/23", want: []string{"254.168.192.in-addr.arpa", "255.168.192.in-addr.arpa"}},
+		{network: "fd00:1:2::/48", want: []string{"2.0.0.0.1.0.0.0.0.0.d.f.ip6.arpa"}},
+		{network: "fd00:1:2::/47", want: []string{"2.0.0.0.1.0.0.0.0.0.d.f.ip6.arpa", "3.0.0.0.1.0.0.0.0.0.d.f.ip6.arpa"}},
+		{network: "fd00::/7", want: []string{"c.f.ip6.arpa", "d.f.ip6.arpa"}},
+	}
+
+	for _, tt := range tests {
+		if got := ReverseZones(mustParseCIDR(tt.network)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ReverseZones(%s) = %v, want %v", tt.network, got, tt.want)
+		}
+	}
+}
+
+func TestReverseZones_Count(t *testing.T) {
+	tests := []struct {
+		network string
+		want    int
+		first   string
+		last    string
+	}{
+		{network: "0.0.0.0/0", want: 256, first: "0.in-addr.arpa", last: "255.in-addr.arpa"},
+		{network: "10.0.0.0/17", want: 128, first: "0.0.10.in-addr.arpa", last: "127.0.10.in-addr.arpa"},
+		// A /126 is covered by the /124 zone containing it
+		{network: "fd00::/126", want: 1, first: strings.Repeat("0.", 29) + "d.f.ip6.arpa"},
+	}
+
+	for _, tt := range tests {
+		got := ReverseZones(mustParseCIDR(tt.network))
+		if len(got) != tt.want {
+			t.Errorf("ReverseZones(%s) returned %d zones, want %d", tt.network, len(got), tt.want)
+			continue
+		}
+		if got[0] != tt.first {
+			t.Errorf("ReverseZones(%s)[0] = %s, want %s", tt.network, got[0], tt.first)
+		}
+		if tt.last != "" && got[len(got)-1] != tt.last {
+			t.Errorf("ReverseZones(%s) last = %s, want %s", tt.network, got[len(got)-1], tt.last)
+		}
+	}
+}
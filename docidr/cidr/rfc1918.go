@@ -0,0 +1,24 @@
+package cidr
+
+import (
+	"net"
+)
+
+// RFC1918Blocks lists the private IPv4 address ranges defined by RFC 1918.
+var RFC1918Blocks = []string{
+	"10.0.0.0/8",
+	"172.16.0.0/12",
+	"192.168.0.0/16",
+}
+
+// RFC1918Block returns the RFC 1918 range that fully contains the network, or
+// false if the network is not private address space.
+func RFC1918Block(network *net.IPNet) (*net.IPNet, bool) {
+	for _, block := range RFC1918Blocks {
+		_, private, _ := net.ParseCIDR(block)
+		if ContainsNetwork(private, network) {
+			return private, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,33 @@
+package cidr
+
+import (
+	"testing"
+)
+
+func TestRFC1918Block(t *testing.T) {
+	tests := []struct {
+		network string
+		want    string
+		ok      bool
+	}{
+		{"10.20.0.0/16", "10.0.0.0/8", true},
+		{"10.0.0.0/8", "10.0.0.0/8", true},
+		{"172.20.0.0/16", "172.16.0.0/12", true},
+		{"172.32.0.0/16", "", false},
+		{"192.168.10.0/24", "192.168.0.0/16", true},
+		{"8.0.0.0/8", "", false},
+		{"10.0.0.0/7", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.network, func(t *testing.T) {
+			got, ok := RFC1918Block(mustParseCIDR(tt.network))
+			if ok != tt.ok {
+				t.Fatalf("RFC1918Block(%s) ok = %v, want %v", tt.network, ok, tt.ok)
+			}
+			if ok && got.String() != tt.want {
+				t.Errorf("RFC1918Block(%s) = %s, want %s", tt.network, got.String(), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package cidr
+
+import (
+	"net"
+	"strings"
+)
+
+// RouteEntry is a static route to install in an allocation's network.
+type RouteEntry struct {
+	// AllocationName is the allocation whose network the route is for.
+	AllocationName string
+	// DestinationCIDR is the CIDR of the allocation the route reaches.
+	DestinationCIDR string
+	// ViaCIDR is the gateway's address, as a /32.
+	ViaCIDR string
+}
+
+// GenerateRouteTable returns the static routes for a hub-and-spoke topology.
+// The hub is the allocation with Hub set, or without one the first VPC
+// allocation in request order, where a VPC allocation is one whose name has
+// vpc as an underscore-separated word, such as vpc, vpc_nyc1 or nyc1_vpc.
+// Every other allocation is a spoke, reaching each other spoke through the
+// first usable host of the hub. Spokes reach the hub directly, so no routes
+// are generated for it. Routes are returned in request order of the spoke and
+// then the destination. Without a hub there are no routes.
+func GenerateRouteTable(allocations map[string]string, requests []AllocationRequest) []RouteEntry {
+	hubName := routeTableHub(allocations, requests)
+	if hubName == "" {
+		return nil
+	}
+	hub, err := ParseCIDR(allocations[hubName])
+	if err != nil {
+		return nil
+	}
+
+	gateway, _, _ := UsableHosts(hub)
+	via := (&net.IPNet{IP: gateway, Mask: net.CIDRMask(32, 32)}).String()
+
+	var spokes []AllocationRequest
+	for _, req := range requests {
+		if _, ok := allocations[req.Name]; ok && req.Name != hubName {
+			spokes = append(spokes, req)
+		}
+	}
+
+	var routes []RouteEntry
+	for _, from := range spokes {
+		for _, to := range spokes {
+			if to.Name == from.Name {
+				continue
+			}
+			routes = append(routes, RouteEntry{
+				AllocationName:  from.Name,
+				DestinationCIDR: allocations[to.Name],
+				ViaCIDR:         via,
+			})
+		}
+	}
+	return routes
+}
+
+// routeTableHub returns the name of the allocation GenerateRouteTable uses as
+// the hub: the allocated request with Hub set, or without one the first
+// allocated VPC allocation. It returns "" if there is none.
+func routeTableHub(allocations map[string]string, requests []AllocationRequest) string {
+	for _, req := range requests {
+		if _, ok := allocations[req.Name]; ok && req.Hub {
+			return req.Name
+		}
+	}
+	for _, req := range requests {
+		if _, ok := allocations[req.Name]; ok && isVPCAllocation(req.Name) {
+			return req.Name
+		}
+	}
+	return ""
+}
+
+// isVPCAllocation reports whether name has vpc as an underscore-separated
+// word.
+func isVPCAllocation(name string) bool {
+	for _, word := range strings.Split(name, "_") {
+		if word == "vpc" {
+			return true
+		}
+	}
+	return false
+}
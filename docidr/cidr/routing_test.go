@@ -0,0 +1,79 @@
+package cidr
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGenerateRouteTable(t *testing.T) {
+	allocations := map[string]string{
+		"vpc_hub":  "10.0.0.0/16",
+		"nyc1_vpc": "10.1.0.0/16",
+		"sfo3_vpc": "10.2.0.0/16",
+	}
+	requests := []AllocationRequest{
+		{Name: "vpc_hub", PrefixLength: 16},
+		{Name: "nyc1_vpc", PrefixLength: 16, Region: "nyc1"},
+		{Name: "sfo3_vpc", PrefixLength: 16, Region: "sfo3"},
+	}
+
+	got := GenerateRouteTable(allocations, requests)
+	want := []RouteEntry{
+		{AllocationName: "nyc1_vpc", DestinationCIDR: "10.2.0.0/16", ViaCIDR: "10.0.0.1/32"},
+		{AllocationName: "sfo3_vpc", DestinationCIDR: "10.1.0.0/16", ViaCIDR: "10.0.0.1/32"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GenerateRouteTable() = %+v, want %+v", got, want)
+	}
+}
+
+func TestGenerateRouteTable_Hub(t *testing.T) {
+	tests := []struct {
+		name        string
+		allocations map[string]string
+		requests    []AllocationRequest
+		want        []RouteEntry
+	}{
+		{
+			name:        "first vpc allocation is the hub",
+			allocations: map[string]string{"k8s": "10.0.0.0/20", "vpc": "10.0.16.0/24", "vpc_b": "10.0.17.0/24"},
+			requests:    []AllocationRequest{{Name: "k8s"}, {Name: "vpc"}, {Name: "vpc_b"}},
+			want: []RouteEntry{
+				{AllocationName: "k8s", DestinationCIDR: "10.0.17.0/24", ViaCIDR: "10.0.16.1/32"},
+				{AllocationName: "vpc_b", DestinationCIDR: "10.0.0.0/20", ViaCIDR: "10.0.16.1/32"},
+			},
+		},
+		{
+			name:        "hub set explicitly",
+			allocations: map[string]string{"vpc": "10.0.0.0/24", "transit": "10.0.1.0/24", "k8s": "10.0.2.0/24"},
+			requests:    []AllocationRequest{{Name: "vpc"}, {Name: "transit", Hub: true}, {Name: "k8s"}},
+			want: []RouteEntry{
+				{AllocationName: "vpc", DestinationCIDR: "10.0.2.0/24", ViaCIDR: "10.0.1.1/32"},
+				{AllocationName: "k8s", DestinationCIDR: "10.0.0.0/24", ViaCIDR: "10.0.1.1/32"},
+			},
+		},
+		{
+			name:        "no vpc allocation",
+			allocations: map[string]string{"vpcx": "10.0.0.0/24", "k8s": "10.0.1.0/24"},
+			requests:    []AllocationRequest{{Name: "vpcx"}, {Name: "k8s"}},
+		},
+		{
+			name:        "hub only",
+			allocations: map[string]string{"vpc": "10.0.0.0/24"},
+			requests:    []AllocationRequest{{Name: "vpc"}},
+		},
+		{
+			name:        "unallocated requests are skipped",
+			allocations: map[string]string{"vpc_b": "10.0.1.0/24", "a": "10.0.2.0/24"},
+			requests:    []AllocationRequest{{Name: "vpc_a"}, {Name: "vpc_b"}, {Name: "a"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := GenerateRouteTable(tt.allocations, tt.requests); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GenerateRouteTable() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package cidr
+
+import (
+	"bytes"
+	"net"
+	"sort"
+	"strings"
+)
+
+// treeNode is a network in a CIDR tree along with the networks nested inside it.
+type treeNode struct {
+	name     string
+	network  *net.IPNet
+	children []*treeNode
+}
+
+// FormatCIDRTree renders the allocations as an indented tree rooted at the base
+// CIDR. Allocations are nested under any other allocation that contains them and
+// are ordered by network address. Entries that are not valid CIDRs are ignored.
+//
+// Example output:
+//
+//	10.0.0.0/8
+//	├── 10.0.0.0/16 (main_vpc)
+//	│   ├── 10.0.0.0/20 (cluster)
+//	│   └── 10.0.16.0/20 (services)
+//	└── 10.1.0.0/16 (other_vpc)
+func FormatCIDRTree(base *net.IPNet, allocations map[string]string) string {
+	nodes := make([]*treeNode, 0, len(allocations))
+	for name, cidrBlock := range allocations {
+		_, network, err := net.ParseCIDR(cidrBlock)
+		if err != nil {
+			continue
+		}
+		nodes = append(nodes, &treeNode{name: name, network: network})
+	}
+
+	// Sort by address, then larger blocks first so parents precede their children.
+	sort.Slice(nodes, func(i, j int) bool {
+		if c := bytes.Compare(nodes[i].network.IP.To16(), nodes[j].network.IP.To16()); c != 0 {
+			return c < 0
+		}
+		pi, _ := nodes[i].network.Mask.Size()
+		pj, _ := nodes[j].network.Mask.Size()
+		if pi != pj {
+			return pi < pj
+		}
+		return nodes[i].name < nodes[j].name
+	})
+
+	root := &treeNode{network: base}
+	stack := []*treeNode{root}
+	for _, node := range nodes {
+		for len(stack) > 1 && !ContainsNetwork(stack[len(stack)-1].network, node.network) {
+			stack = stack[:len(stack)-1]
+		}
+		parent := stack[len(stack)-1]
+		parent.children = append(parent.children, node)
+		stack = append(stack, node)
+	}
+
+	var sb strings.Builder
+	sb.WriteString(base.String())
+	sb.WriteString("\n")
+	writeTreeChildren(&sb, root, "")
+	return sb.String()
+}
+
+// writeTreeChildren writes the children of node using box-drawing characters.
+func writeTreeChildren(sb *strings.Builder, node *treeNode, indent string) {
+	for i, child := range node.children {
+		last := i == len(node.children)-1
+
+		branch, childIndent := "├── ", "│   "
+		if last {
+			branch, childIndent = "└── ", "    "
+		}
+
+		sb.WriteString(indent)
+		sb.WriteString(branch)
+		sb.WriteString(child.network.String())
+		sb.WriteString(" (")
+		sb.WriteString(child.name)
+		sb.WriteString(")\n")
+
+		writeTreeChildren(sb, child, indent+childIndent)
+	}
+}
+
+// ContainsNetwork returns true if outer fully contains inner. Networks of
+// different address families never contain each other.
+func ContainsNetwork(outer, inner *net.IPNet) bool {
+	outerLen, outerBits := outer.Mask.Size()
+	innerLen, innerBits := inner.Mask.Size()
+	return outerBits == innerBits && outerLen <= innerLen && outer.Contains(inner.IP)
+}
@@ -0,0 +1,68 @@
+package cidr
+
+import (
+	"testing"
+)
+
+func TestFormatCIDRTree_Basic(t *testing.T) {
+	allocations := map[string]string{
+		"main_vpc": "10.0.0.0/16",
+		"cluster":  "10.0.0.0/20",
+		"services": "10.0.16.0/20",
+	}
+
+	got := FormatCIDRTree(mustParseCIDR("10.0.0.0/8"), allocations)
+	want := "10.0.0.0/8\n" +
+		"└── 10.0.0.0/16 (main_vpc)\n" +
+		"    ├── 10.0.0.0/20 (cluster)\n" +
+		"    └── 10.0.16.0/20 (services)\n"
+
+	if got != want {
+		t.Errorf("FormatCIDRTree() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestFormatCIDRTree_Siblings(t *testing.T) {
+	allocations := map[string]string{
+		"vpc":      "10.0.0.0/16",
+		"cluster":  "10.1.0.0/20",
+		"services": "10.1.16.0/20",
+	}
+
+	got := FormatCIDRTree(mustParseCIDR("10.0.0.0/8"), allocations)
+	want := "10.0.0.0/8\n" +
+		"├── 10.0.0.0/16 (vpc)\n" +
+		"├── 10.1.0.0/20 (cluster)\n" +
+		"└── 10.1.16.0/20 (services)\n"
+
+	if got != want {
+		t.Errorf("FormatCIDRTree() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestFormatCIDRTree_NestedWithTrailingSibling(t *testing.T) {
+	allocations := map[string]string{
+		"main_vpc":  "10.0.0.0/16",
+		"cluster":   "10.0.0.0/20",
+		"services":  "10.0.16.0/20",
+		"other_vpc": "10.1.0.0/16",
+	}
+
+	got := FormatCIDRTree(mustParseCIDR("10.0.0.0/8"), allocations)
+	want := "10.0.0.0/8\n" +
+		"├── 10.0.0.0/16 (main_vpc)\n" +
+		"│   ├── 10.0.0.0/20 (cluster)\n" +
+		"│   └── 10.0.16.0/20 (services)\n" +
+		"└── 10.1.0.0/16 (other_vpc)\n"
+
+	if got != want {
+		t.Errorf("FormatCIDRTree() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestFormatCIDRTree_Empty(t *testing.T) {
+	got := FormatCIDRTree(mustParseCIDR("10.0.0.0/8"), nil)
+	if got != "10.0.0.0/8\n" {
+		t.Errorf("FormatCIDRTree() = %q, want %q", got, "10.0.0.0/8\n")
+	}
+}
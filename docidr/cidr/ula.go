@@ -0,0 +1,44 @@
+package cidr
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"net"
+)
+
+// ULAPrefixLength is the prefix length of an RFC 4193 unique local address
+// prefix: the fd00::/8 L-bit prefix followed by a 40-bit Global ID.
+const ULAPrefixLength = 48
+
+// ULAPrefix returns the RFC 4193 unique local /48 derived from seed. RFC 4193
+// section 3.2.2 computes the SHA-1 digest of a timestamp and EUI-64 and uses
+// its least significant 40 bits as the Global ID; the seed takes the place of
+// those inputs, so the same seed always yields the same prefix.
+func ULAPrefix(seed string) *net.IPNet {
+	digest := sha1.Sum([]byte(seed))
+
+	ip := make(net.IP, net.IPv6len)
+	ip[0] = 0xfd
+	copy(ip[1:6], digest[len(digest)-5:])
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(ULAPrefixLength, 128)}
+}
+
+// ULASubnets returns the first count /64 subnets of a ULA /48 prefix.
+func ULASubnets(prefix *net.IPNet, count int) ([]*net.IPNet, error) {
+	if ones, bits := prefix.Mask.Size(); bits != 128 || ones != ULAPrefixLength {
+		return nil, fmt.Errorf("%s is not an IPv6 /%d", prefix, ULAPrefixLength)
+	}
+	if count < 0 || count > 1<<16 {
+		return nil, fmt.Errorf("count must be between 0 and %d, got %d", 1<<16, count)
+	}
+
+	result := make([]*net.IPNet, 0, count)
+	for i := 0; i < count; i++ {
+		ip := make(net.IP, net.IPv6len)
+		copy(ip, prefix.IP.To16()[:6])
+		ip[6] = byte(i >> 8)
+		ip[7] = byte(i)
+		result = append(result, &net.IPNet{IP: ip, Mask: net.CIDRMask(64, 128)})
+	}
+	return result, nil
+}
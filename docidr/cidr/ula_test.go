@@ -0,0 +1,63 @@
+package cidr
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestULAPrefix(t *testing.T) {
+	tests := []struct {
+		seed string
+		want string
+	}{
+		{"production", "fd08:1ad7:3d3c::/48"},
+		{"staging", "fd00:bf9e:5c7b::/48"},
+		{"", "fd90:afd8:709::/48"},
+	}
+
+	ula := mustParseCIDR("fd00::/8")
+	for _, tt := range tests {
+		t.Run(tt.seed, func(t *testing.T) {
+			got := ULAPrefix(tt.seed)
+			if got.String() != tt.want {
+				t.Errorf("ULAPrefix(%q) = %s, want %s", tt.seed, got, tt.want)
+			}
+			if !ContainsNetwork(ula, got) {
+				t.Errorf("ULAPrefix(%q) = %s, want it inside fd00::/8", tt.seed, got)
+			}
+			if again := ULAPrefix(tt.seed); again.String() != got.String() {
+				t.Errorf("ULAPrefix(%q) not deterministic: %s then %s", tt.seed, got, again)
+			}
+		})
+	}
+}
+
+func TestULASubnets(t *testing.T) {
+	subnets, err := ULASubnets(mustParseCIDR("fd08:1ad7:3d3c::/48"), 3)
+	if err != nil {
+		t.Fatalf("ULASubnets() error = %v", err)
+	}
+	var got []string
+	for _, subnet := range subnets {
+		got = append(got, subnet.String())
+	}
+	want := "fd08:1ad7:3d3c::/64,fd08:1ad7:3d3c:1::/64,fd08:1ad7:3d3c:2::/64"
+	if strings.Join(got, ",") != want {
+		t.Errorf("ULASubnets() = %v, want %s", got, want)
+	}
+
+	last, err := ULASubnets(mustParseCIDR("fd08:1ad7:3d3c::/48"), 1<<16)
+	if err != nil {
+		t.Fatalf("ULASubnets() error = %v", err)
+	}
+	if s := last[len(last)-1].String(); s != "fd08:1ad7:3d3c:ffff::/64" {
+		t.Errorf("last subnet = %s, want fd08:1ad7:3d3c:ffff::/64", s)
+	}
+
+	if _, err := ULASubnets(mustParseCIDR("fd00::/56"), 1); err == nil {
+		t.Error("ULASubnets() of a /56 succeeded, want error")
+	}
+	if _, err := ULASubnets(mustParseCIDR("fd00::/48"), 1<<16+1); err == nil {
+		t.Error("ULASubnets() of too many subnets succeeded, want error")
+	}
+}
@@ -0,0 +1,57 @@
+package cidr
+
+import (
+	"fmt"
+	"net"
+)
+
+// Allocation visibilities. Internal allocations are only reachable within
+// the organization's own networks; external ones may be routed to peers.
+const (
+	VisibilityInternal = "internal"
+	VisibilityExternal = "external"
+)
+
+// HomeNetworkBlock is the range used by many home and lab networks, which
+// internal allocations avoid so they don't conflict with them.
+const HomeNetworkBlock = "192.168.0.0/16"
+
+// FilterAllocationsByVisibility returns the allocations whose request has the
+// given visibility. Requests without a visibility are internal.
+func FilterAllocationsByVisibility(allocations map[string]string, requests []AllocationRequest, visibility string) map[string]string {
+	result := make(map[string]string)
+	for _, req := range requests {
+		reqVisibility := req.Visibility
+		if reqVisibility == "" {
+			reqVisibility = VisibilityInternal
+		}
+		if reqVisibility != visibility {
+			continue
+		}
+		if block, ok := allocations[req.Name]; ok {
+			result[req.Name] = block
+		}
+	}
+	return result
+}
+
+// CheckVisibility returns an error if network can't be used for an allocation
+// with the given visibility: external allocations must lie within an RFC 1918
+// range, and internal allocations must not overlap HomeNetworkBlock. An empty
+// visibility is internal.
+func CheckVisibility(network *net.IPNet, visibility string) error {
+	switch visibility {
+	case VisibilityExternal:
+		if _, ok := RFC1918Block(network); !ok {
+			return fmt.Errorf("external allocation %s is not within an RFC 1918 range", network.String())
+		}
+	case VisibilityInternal, "":
+		_, home, _ := net.ParseCIDR(HomeNetworkBlock)
+		if networksOverlap(network, home) {
+			return fmt.Errorf("internal allocation %s overlaps %s, which is used by many home and lab networks", network.String(), HomeNetworkBlock)
+		}
+	default:
+		return fmt.Errorf("unknown visibility %q", visibility)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package cidr
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFilterAllocationsByVisibility(t *testing.T) {
+	allocations := map[string]string{
+		"vpc":     "10.0.0.0/16",
+		"peering": "10.1.0.0/24",
+		"k8s":     "10.2.0.0/20",
+		"missing": "10.3.0.0/24",
+	}
+	requests := []AllocationRequest{
+		{Name: "vpc", PrefixLength: 16},
+		{Name: "peering", PrefixLength: 24, Visibility: VisibilityExternal},
+		{Name: "k8s", PrefixLength: 20, Visibility: VisibilityInternal},
+		{Name: "unallocated", PrefixLength: 24, Visibility: VisibilityExternal},
+	}
+
+	tests := []struct {
+		visibility string
+		want       map[string]string
+	}{
+		{
+			visibility: VisibilityInternal,
+			want:       map[string]string{"vpc": "10.0.0.0/16", "k8s": "10.2.0.0/20"},
+		},
+		{
+			visibility: VisibilityExternal,
+			want:       map[string]string{"peering": "10.1.0.0/24"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.visibility, func(t *testing.T) {
+			got := FilterAllocationsByVisibility(allocations, requests, tt.visibility)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FilterAllocationsByVisibility() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckVisibility(t *testing.T) {
+	tests := []struct {
+		name       string
+		network    string
+		visibility string
+		wantErr    bool
+	}{
+		{name: "external private", network: "172.16.4.0/24", visibility: VisibilityExternal},
+		{name: "external home network", network: "192.168.1.0/24", visibility: VisibilityExternal},
+		{name: "external public", network: "100.64.0.0/24", visibility: VisibilityExternal, wantErr: true},
+		{name: "external straddling", network: "10.0.0.0/7", visibility: VisibilityExternal, wantErr: true},
+		{name: "internal private", network: "10.0.0.0/16", visibility: VisibilityInternal},
+		{name: "internal public", network: "100.64.0.0/24", visibility: VisibilityInternal},
+		{name: "internal home network", network: "192.168.1.0/24", visibility: VisibilityInternal, wantErr: true},
+		{name: "internal containing home network", network: "192.0.0.0/8", visibility: VisibilityInternal, wantErr: true},
+		{name: "unset home network", network: "192.168.0.0/16", visibility: "", wantErr: true},
+		{name: "unset public", network: "100.64.0.0/24", visibility: ""},
+		{name: "unknown", network: "10.0.0.0/16", visibility: "public", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckVisibility(mustParseCIDR(tt.network), tt.visibility)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CheckVisibility(%s, %q) error = %v, wantErr %v", tt.network, tt.visibility, err, tt.wantErr)
+			}
+		})
+	}
+}
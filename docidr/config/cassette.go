@@ -0,0 +1,301 @@
+package config
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Environment variables controlling the recording and replay of API
+// responses for acceptance tests.
+const (
+	// CassetteEnvVar is the path of the cassette file. Recording and replay
+	// are off unless it is set.
+	CassetteEnvVar = "DOCIDR_CASSETTE"
+	// RecordEnvVar records responses from the API to the cassette when set
+	// to 1. Otherwise the cassette is replayed and the API isn't contacted.
+	RecordEnvVar = "DOCIDR_RECORD"
+)
+
+// pageParams are the query parameters used to match a request to a recorded
+// interaction. Any other parameters are ignored.
+var pageParams = []string{"page", "per_page"}
+
+// recordedHeaders are the response headers kept in a cassette.
+var recordedHeaders = []string{"Content-Type", "Ratelimit-Limit", "Ratelimit-Remaining", "Ratelimit-Reset"}
+
+var (
+	uuidRegexp  = regexp.MustCompile(`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`)
+	emailRegexp = regexp.MustCompile(`[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}`)
+)
+
+// Interaction is a recorded API request and its response.
+type Interaction struct {
+	Method string            `json:"method"`
+	Path   string            `json:"path"`
+	Query  string            `json:"query,omitempty"`
+	Status int               `json:"status"`
+	Header map[string]string `json:"headers,omitempty"`
+	Body   string            `json:"body"`
+}
+
+// key identifies the requests the interaction is replayed for.
+func (i *Interaction) key() string {
+	return interactionKey(i.Method, i.Path, i.Query)
+}
+
+func interactionKey(method, path, query string) string {
+	if query == "" {
+		return method + " " + path
+	}
+	return method + " " + path + "?" + query
+}
+
+// cassetteFile is the JSON format of a cassette file. Interactions are sorted
+// by method, path and query so that re-recording the same responses doesn't
+// change the file.
+type cassetteFile struct {
+	Interactions []*Interaction `json:"interactions"`
+}
+
+// Cassette records API responses to a file, or replays them from it, so that
+// acceptance tests can run without a DigitalOcean account. Requests are
+// matched on their method, path and page parameters, and each is answered
+// with the response first recorded for it. Responses are sanitized before
+// they are recorded: UUIDs are replaced with stable placeholders, so that
+// requests for a replayed ID still match, and email addresses with
+// user@example.com. The token is never recorded.
+type Cassette struct {
+	path      string
+	recording bool
+
+	mu           sync.Mutex
+	interactions map[string]*Interaction
+}
+
+var (
+	cassettesMu sync.Mutex
+	cassettes   = map[string]*Cassette{}
+)
+
+// cassetteFromEnv returns the cassette named by CassetteEnvVar, or nil if it
+// isn't set. The provider is configured for every acceptance test step, so
+// the cassette for a path is opened once and shared by all of them.
+func cassetteFromEnv() (*Cassette, error) {
+	path := os.Getenv(CassetteEnvVar)
+	if path == "" {
+		return nil, nil
+	}
+	recording := os.Getenv(RecordEnvVar) == "1"
+
+	cassettesMu.Lock()
+	defer cassettesMu.Unlock()
+	if c, ok := cassettes[path]; ok && c.recording == recording {
+		return c, nil
+	}
+
+	var c *Cassette
+	var err error
+	if recording {
+		c = NewRecordingCassette(path)
+		log.Printf("[WARN] Recording API responses to %s", path)
+	} else {
+		c, err = LoadCassette(path)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("[WARN] Replaying API responses from %s; the API is not contacted", path)
+	}
+	cassettes[path] = c
+	return c, nil
+}
+
+// NewRecordingCassette returns an empty cassette that records responses to
+// path, replacing any existing file.
+func NewRecordingCassette(path string) *Cassette {
+	return &Cassette{
+		path:         path,
+		recording:    true,
+		interactions: map[string]*Interaction{},
+	}
+}
+
+// LoadCassette returns a cassette replaying the responses recorded in path.
+func LoadCassette(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading cassette: %w", err)
+	}
+
+	var file cassetteFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("error parsing cassette %s: %w", path, err)
+	}
+
+	c := &Cassette{
+		path:         path,
+		interactions: make(map[string]*Interaction, len(file.Interactions)),
+	}
+	for _, i := range file.Interactions {
+		if _, ok := c.interactions[i.key()]; !ok {
+			c.interactions[i.key()] = i
+		}
+	}
+	return c, nil
+}
+
+// Transport returns a transport that records the responses of base to the
+// cassette, or replays the recorded responses without calling base.
+func (c *Cassette) Transport(base http.RoundTripper) http.RoundTripper {
+	return &cassetteTransport{cassette: c, base: base}
+}
+
+// cassetteTransport records or replays API responses.
+type cassetteTransport struct {
+	cassette *Cassette
+	base     http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *cassetteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	c := t.cassette
+	key := interactionKey(req.Method, sanitize(req.URL.Path), pageQuery(req.URL.Query()))
+
+	if !c.recording {
+		c.mu.Lock()
+		i, ok := c.interactions[key]
+		c.mu.Unlock()
+		if !ok {
+			return nil, fmt.Errorf("no response recorded for %s in cassette %s; record it with %s=1", key, c.path, RecordEnvVar)
+		}
+		return i.response(req), nil
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("error reading response body: %w", err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	i := &Interaction{
+		Method: req.Method,
+		Path:   sanitize(req.URL.Path),
+		Query:  pageQuery(req.URL.Query()),
+		Status: resp.StatusCode,
+		Header: map[string]string{},
+		Body:   sanitize(string(body)),
+	}
+	for _, name := range recordedHeaders {
+		if value := resp.Header.Get(name); value != "" {
+			i.Header[name] = value
+		}
+	}
+	if err := c.record(i); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// record adds an interaction to the cassette and writes the cassette file.
+// The file is written after every new interaction because tests have no hook
+// that runs once the provider is done with the cassette.
+func (c *Cassette) record(i *Interaction) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.interactions[i.key()]; ok {
+		return nil
+	}
+	c.interactions[i.key()] = i
+	return c.save()
+}
+
+// save writes the cassette file. The caller must hold c.mu.
+func (c *Cassette) save() error {
+	file := cassetteFile{Interactions: make([]*Interaction, 0, len(c.interactions))}
+	for _, i := range c.interactions {
+		file.Interactions = append(file.Interactions, i)
+	}
+	sort.Slice(file.Interactions, func(a, b int) bool {
+		return file.Interactions[a].key() < file.Interactions[b].key()
+	})
+
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o755); err != nil {
+		return fmt.Errorf("error writing cassette: %w", err)
+	}
+	if err := os.WriteFile(c.path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("error writing cassette: %w", err)
+	}
+	return nil
+}
+
+// response returns the recorded response to req.
+func (i *Interaction) response(req *http.Request) *http.Response {
+	header := make(http.Header, len(i.Header))
+	for name, value := range i.Header {
+		header.Set(name, value)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", i.Status, http.StatusText(i.Status)),
+		StatusCode:    i.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader(i.Body)),
+		ContentLength: int64(len(i.Body)),
+		Request:       req,
+	}
+}
+
+// pageQuery returns the page parameters of a query, encoded in a fixed order.
+func pageQuery(query url.Values) string {
+	matched := url.Values{}
+	for _, name := range pageParams {
+		if value := query.Get(name); value != "" {
+			matched.Set(name, value)
+		}
+	}
+	return matched.Encode()
+}
+
+// sanitize replaces account identifiers in s: each UUID with a placeholder
+// derived from it, so the same UUID is always replaced the same way, and each
+// email address with user@example.com.
+func sanitize(s string) string {
+	s = uuidRegexp.ReplaceAllStringFunc(s, placeholderUUID)
+	return emailRegexp.ReplaceAllString(s, "user@example.com")
+}
+
+// placeholderUUID returns the placeholder for a UUID. Placeholders are
+// recognizable by their all-zero first group, and are left unchanged so that
+// sanitizing is idempotent.
+func placeholderUUID(uuid string) string {
+	if strings.HasPrefix(uuid, "00000000-") {
+		return uuid
+	}
+	hash := sha256.Sum256([]byte(strings.ToLower(uuid)))
+	h := hex.EncodeToString(hash[:])
+	return "00000000-" + h[0:4] + "-" + h[4:8] + "-" + h[8:12] + "-" + h[12:24]
+}
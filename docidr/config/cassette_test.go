@@ -0,0 +1,144 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/digitalocean/godo"
+)
+
+const accountUUID = "b8a1e0f3-4c2d-4e8b-9f6a-2d7c5e1a3b90"
+
+// newCassetteClient returns a client for srv using the cassette at path.
+func newCassetteClient(t *testing.T, path string, record bool, endpoint string) *godo.Client {
+	t.Helper()
+
+	t.Setenv(CassetteEnvVar, path)
+	if record {
+		t.Setenv(RecordEnvVar, "1")
+	} else {
+		t.Setenv(RecordEnvVar, "")
+	}
+	combined, err := (&Config{Token: "secret-token", APIEndpoint: endpoint + "/"}).Client()
+	if err != nil {
+		t.Fatalf("Client() error = %v", err)
+	}
+	return combined.GodoClient()
+}
+
+func TestCassette_RecordAndReplay(t *testing.T) {
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Ratelimit-Remaining", "4999")
+		w.Header().Set("X-Request-Id", "request-1")
+		switch r.URL.Path {
+		case "/v2/account":
+			fmt.Fprintf(w, `{"account": {"uuid": %q, "email": "admin@corp.example", "status": "active"}}`, accountUUID)
+		case "/v2/vpcs":
+			fmt.Fprintf(w, `{"vpcs": [{"id": "vpc-%s", "ip_range": "10.0.0.0/16"}], "links": {}, "meta": {"total": 1}}`, r.URL.Query().Get("page"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	path := filepath.Join(t.TempDir(), "testdata", "cassette.json")
+	client := newCassetteClient(t, path, true, srv.URL)
+	account, _, err := client.Account.Get(context.Background())
+	if err != nil {
+		t.Fatalf("Account.Get() error = %v", err)
+	}
+	if account.UUID != accountUUID {
+		t.Errorf("recorded UUID = %q, want the real UUID %q", account.UUID, accountUUID)
+	}
+	for page := 1; page <= 2; page++ {
+		if _, _, err := client.VPCs.List(context.Background(), &godo.ListOptions{Page: page, PerPage: 50}); err != nil {
+			t.Fatalf("VPCs.List() error = %v", err)
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	for _, secret := range []string{"secret-token", accountUUID, "admin@corp.example", "X-Request-Id"} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("cassette contains %q:\n%s", secret, data)
+		}
+	}
+
+	// Replay against an endpoint that can't be reached
+	recorded := atomic.LoadInt32(&requests)
+	client = newCassetteClient(t, path, false, "http://127.0.0.1:1")
+	account, resp, err := client.Account.Get(context.Background())
+	if err != nil {
+		t.Fatalf("replayed Account.Get() error = %v", err)
+	}
+	if want := placeholderUUID(accountUUID); account.UUID != want {
+		t.Errorf("replayed UUID = %q, want %q", account.UUID, want)
+	}
+	if account.Email != "user@example.com" {
+		t.Errorf("replayed email = %q, want user@example.com", account.Email)
+	}
+	if resp.Rate.Remaining != 4999 {
+		t.Errorf("replayed rate limit remaining = %d, want 4999", resp.Rate.Remaining)
+	}
+
+	vpcs, _, err := client.VPCs.List(context.Background(), &godo.ListOptions{Page: 2, PerPage: 50})
+	if err != nil {
+		t.Fatalf("replayed VPCs.List() error = %v", err)
+	}
+	if len(vpcs) != 1 || vpcs[0].ID != "vpc-2" {
+		t.Errorf("replayed page 2 = %+v, want vpc-2", vpcs)
+	}
+
+	_, _, err = client.VPCs.List(context.Background(), &godo.ListOptions{Page: 3, PerPage: 50})
+	if err == nil || !strings.Contains(err.Error(), "no response recorded for GET /v2/vpcs?page=3&per_page=50") {
+		t.Errorf("unrecorded request error = %v", err)
+	}
+
+	if got := atomic.LoadInt32(&requests); got != recorded {
+		t.Errorf("replay made %d requests to the API, want none", got-recorded)
+	}
+}
+
+func TestCassette_Disabled(t *testing.T) {
+	t.Setenv(CassetteEnvVar, "")
+
+	c, err := cassetteFromEnv()
+	if err != nil || c != nil {
+		t.Errorf("cassetteFromEnv() = %v, %v, want nil", c, err)
+	}
+}
+
+func TestCassette_Missing(t *testing.T) {
+	t.Setenv(CassetteEnvVar, filepath.Join(t.TempDir(), "missing.json"))
+	t.Setenv(RecordEnvVar, "")
+
+	if _, err := (&Config{Token: "secret-token"}).Client(); err == nil {
+		t.Error("Client() error = nil, want an error for the missing cassette")
+	}
+}
+
+func TestSanitize(t *testing.T) {
+	in := `{"uuid": "B8A1E0F3-4C2D-4E8B-9F6A-2D7C5E1A3B90", "owner": "b8a1e0f3-4c2d-4e8b-9f6a-2d7c5e1a3b90", "email": "jane.doe+ops@corp.example"}`
+
+	got := sanitize(in)
+	placeholder := placeholderUUID(accountUUID)
+	want := fmt.Sprintf(`{"uuid": %q, "owner": %q, "email": "user@example.com"}`, placeholder, placeholder)
+	if got != want {
+		t.Errorf("sanitize() = %s, want %s", got, want)
+	}
+	if again := sanitize(got); again != got {
+		t.Errorf("sanitize() isn't idempotent: %s", again)
+	}
+}
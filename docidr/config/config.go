@@ -4,27 +4,138 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"net/url"
+	"sort"
+	"sync"
+	"time"
 
+	"github.com/DO-Solutions/terraform-provider-docidr/docidr/cidr"
 	"github.com/digitalocean/godo"
+	"github.com/hashicorp/go-retryablehttp"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/logging"
 	"golang.org/x/oauth2"
 )
 
+// Exclusion source error policies.
+const (
+	ExclusionSourceErrorFail = "error"
+	ExclusionSourceErrorWarn = "warn"
+)
+
+// DefaultAPIPageSize is the number of items requested per page when listing
+// resources from the DigitalOcean API, and the largest page size it allows.
+const DefaultAPIPageSize = 200
+
 // Config holds the provider configuration.
 type Config struct {
-	Token            string
-	APIEndpoint      string
-	TerraformVersion string
-	HTTPRetryMax     int
-	HTTPRetryWaitMax float64
-	HTTPRetryWaitMin float64
+	Token                  string
+	APIEndpoint            string
+	TerraformVersion       string
+	ProviderVersion        string
+	HTTPRetryMax           int
+	HTTPRetryWaitMax       float64
+	HTTPRetryWaitMin       float64
+	HTTPTimeout            float64
+	ExclusionURLs          []string
+	OnExclusionSourceError string
+	AppPlatformRangesURL   string
+	EnvExclusions          []*net.IPNet
+	APIPageSize            int
+	// Headers are added to every API request.
+	Headers map[string]string
+	// SizePresets maps the names usable as an allocation's size to prefix
+	// lengths.
+	SizePresets map[string]int
+	// IPAMSource, if set, is an IPAM system whose prefixes every pool
+	// excludes.
+	IPAMSource *IPAMSource
+
+	ComputeAllocationsAtPlanTime bool
+}
+
+// IPAM source types.
+const (
+	IPAMSourceNetbox = "netbox"
+)
+
+// IPAMSource is the provider's ipam_source block.
+type IPAMSource struct {
+	Type  string
+	URL   string
+	Token string
+	// Filter holds query parameters added to every request for prefixes.
+	Filter map[string]string
+	// Timeout is the timeout, in seconds, of each request attempt.
+	Timeout float64
+}
+
+// IPAMPrefix is a prefix read from the ipam_source.
+type IPAMPrefix struct {
+	// ID identifies the prefix in the IPAM system.
+	ID      string
+	Network *net.IPNet
 }
 
 // CombinedConfig wraps the godo client for use by resources.
 type CombinedConfig struct {
-	client *godo.Client
+	client     *godo.Client
+	httpClient *http.Client
+
+	exclusionURLs          []string
+	onExclusionSourceError string
+	appPlatformRangesURL   string
+	envExclusions          []*net.IPNet
+	apiPageSize            int
+	sizePresets            map[string]int
+	terraformVersion       string
+	providerVersion        string
+	accountUUID            string
+
+	computeAllocationsAtPlanTime bool
+
+	ipamSource     *IPAMSource
+	ipamHTTPClient *http.Client
+
+	remoteExclusionsMu sync.Mutex
+	remoteExclusions   map[string][]*net.IPNet
+	ipamPrefixes       []IPAMPrefix
+	ipamPrefixesCached bool
+
+	poolsMu                  sync.Mutex
+	pools                    map[string]PoolRegistration
+	poolAllocations          map[string]*net.IPNet
+	excludingPoolAllocations map[string]*net.IPNet
+
+	poolAllocationMu sync.Mutex
+}
+
+// PoolRegistration records the address space planned for a docidr_pool.
+type PoolRegistration struct {
+	// Key identifies the pool across repeated plans by this provider instance.
+	Key string
+	// IdempotentID is the pool's idempotent_id, if set, which no other pool
+	// may use.
+	IdempotentID string
+	BaseCIDR     *net.IPNet
+	Exclusions   []*net.IPNet
+	// ExcludeOverlappingPools is set when the pool excludes the allocations
+	// of other pools, so it may share address space with any other pool.
+	ExcludeOverlappingPools bool
+}
+
+// PoolConflictError is returned when a pool's address space overlaps a pool
+// that was already registered.
+type PoolConflictError struct {
+	BaseCIDR      *net.IPNet
+	OtherBaseCIDR *net.IPNet
+	Overlap       *net.IPNet
+}
+
+func (e *PoolConflictError) Error() string {
+	return fmt.Sprintf("base_cidr %s overlaps base_cidr %s of another docidr_pool in this configuration (%s is not excluded by either pool); "+
+		"use disjoint base CIDRs or exclude the shared range from one of the pools", e.BaseCIDR, e.OtherBaseCIDR, e.Overlap)
 }
 
 // GodoClient returns the underlying godo client.
@@ -32,6 +143,233 @@ func (c *CombinedConfig) GodoClient() *godo.Client {
 	return c.client
 }
 
+// HTTPClient returns an unauthenticated HTTP client for fetching remote
+// resources. It honors the provider's retry and timeout settings and the
+// standard proxy environment variables.
+func (c *CombinedConfig) HTTPClient() *http.Client {
+	return c.httpClient
+}
+
+// ExclusionURLs returns the URLs of remote exclusion lists.
+func (c *CombinedConfig) ExclusionURLs() []string {
+	return c.exclusionURLs
+}
+
+// OnExclusionSourceError returns the policy for exclusion source failures,
+// either ExclusionSourceErrorFail or ExclusionSourceErrorWarn.
+func (c *CombinedConfig) OnExclusionSourceError() string {
+	if c.onExclusionSourceError == "" {
+		return ExclusionSourceErrorFail
+	}
+	return c.onExclusionSourceError
+}
+
+// AppPlatformRangesURL returns the URL of the App Platform internal network
+// ranges document, or an empty string if none is configured.
+func (c *CombinedConfig) AppPlatformRangesURL() string {
+	return c.appPlatformRangesURL
+}
+
+// EnvExclusions returns the exclusions read from the DOCIDR_EXCLUDE environment
+// variable at configure time.
+func (c *CombinedConfig) EnvExclusions() []*net.IPNet {
+	return c.envExclusions
+}
+
+// APIPageSize returns the number of items to request per page when listing
+// resources from the DigitalOcean API.
+func (c *CombinedConfig) APIPageSize() int {
+	if c.apiPageSize <= 0 {
+		return DefaultAPIPageSize
+	}
+	return c.apiPageSize
+}
+
+// SizePresets returns the prefix lengths of the size presets configured on the
+// provider, by name.
+func (c *CombinedConfig) SizePresets() map[string]int {
+	return c.sizePresets
+}
+
+// TerraformVersion returns the version of Terraform running the provider.
+func (c *CombinedConfig) TerraformVersion() string {
+	return c.terraformVersion
+}
+
+// ProviderVersion returns the release version of the provider.
+func (c *CombinedConfig) ProviderVersion() string {
+	return c.providerVersion
+}
+
+// LoadAccount fetches the account the token belongs to and records its UUID.
+func (c *CombinedConfig) LoadAccount(ctx context.Context) error {
+	account, _, err := c.client.Account.Get(ctx)
+	if err != nil {
+		return err
+	}
+	c.accountUUID = account.UUID
+	return nil
+}
+
+// AccountUUID returns the UUID of the account the token belongs to, or an
+// empty string if it hasn't been loaded.
+func (c *CombinedConfig) AccountUUID() string {
+	return c.accountUUID
+}
+
+// ComputeAllocationsAtPlanTime reports whether pools should query the
+// DigitalOcean API during plan so that allocations are known before apply.
+func (c *CombinedConfig) ComputeAllocationsAtPlanTime() bool {
+	return c.computeAllocationsAtPlanTime
+}
+
+// CachedRemoteExclusions returns the exclusions previously fetched from url
+// by this provider instance, if any.
+func (c *CombinedConfig) CachedRemoteExclusions(url string) ([]*net.IPNet, bool) {
+	c.remoteExclusionsMu.Lock()
+	defer c.remoteExclusionsMu.Unlock()
+	networks, ok := c.remoteExclusions[url]
+	return networks, ok
+}
+
+// IPAMSource returns the provider's ipam_source, or nil if it has none.
+func (c *CombinedConfig) IPAMSource() *IPAMSource {
+	return c.ipamSource
+}
+
+// IPAMHTTPClient returns the HTTP client for the ipam_source. It is like
+// HTTPClient, but with the source's timeout.
+func (c *CombinedConfig) IPAMHTTPClient() *http.Client {
+	return c.ipamHTTPClient
+}
+
+// CachedIPAMPrefixes returns the prefixes previously read from the
+// ipam_source by this provider instance, if any.
+func (c *CombinedConfig) CachedIPAMPrefixes() ([]IPAMPrefix, bool) {
+	c.remoteExclusionsMu.Lock()
+	defer c.remoteExclusionsMu.Unlock()
+	return c.ipamPrefixes, c.ipamPrefixesCached
+}
+
+// CacheIPAMPrefixes stores the prefixes read from the ipam_source for the
+// lifetime of this provider instance.
+func (c *CombinedConfig) CacheIPAMPrefixes(prefixes []IPAMPrefix) {
+	c.remoteExclusionsMu.Lock()
+	defer c.remoteExclusionsMu.Unlock()
+	c.ipamPrefixes = prefixes
+	c.ipamPrefixesCached = true
+}
+
+// CacheRemoteExclusions stores the exclusions fetched from url for the
+// lifetime of this provider instance.
+func (c *CombinedConfig) CacheRemoteExclusions(url string, networks []*net.IPNet) {
+	c.remoteExclusionsMu.Lock()
+	defer c.remoteExclusionsMu.Unlock()
+	if c.remoteExclusions == nil {
+		c.remoteExclusions = make(map[string][]*net.IPNet)
+	}
+	c.remoteExclusions[url] = networks
+}
+
+// RegisterPool records the address space of a pool planned by this provider
+// instance. It returns a *PoolConflictError, without registering the pool, if
+// any part of that space is also available to a different registered pool,
+// and an error if a different registered pool has the same idempotent_id.
+// Registering the same key again replaces the previous registration.
+func (c *CombinedConfig) RegisterPool(reg PoolRegistration) error {
+	c.poolsMu.Lock()
+	defer c.poolsMu.Unlock()
+
+	for key, other := range c.pools {
+		if key == reg.Key {
+			continue
+		}
+		if reg.IdempotentID != "" && other.IdempotentID == reg.IdempotentID {
+			return fmt.Errorf("idempotent_id %q is also used by another docidr_pool in this configuration; each pool needs its own, or they would be created with the same ID", reg.IdempotentID)
+		}
+		if reg.ExcludeOverlappingPools || other.ExcludeOverlappingPools {
+			continue
+		}
+		overlap, ok := cidr.Intersection(reg.BaseCIDR, other.BaseCIDR)
+		if !ok {
+			continue
+		}
+		exclusions := append(append([]*net.IPNet{}, reg.Exclusions...), other.Exclusions...)
+		if !cidr.Covered(overlap, exclusions) {
+			return &PoolConflictError{
+				BaseCIDR:      reg.BaseCIDR,
+				OtherBaseCIDR: other.BaseCIDR,
+				Overlap:       overlap,
+			}
+		}
+	}
+
+	if c.pools == nil {
+		c.pools = make(map[string]PoolRegistration)
+	}
+	c.pools[reg.Key] = reg
+	return nil
+}
+
+// RecordPoolAllocations records CIDRs allocated by a docidr_pool, so pools
+// sharing its address space can avoid them. excludesOverlapping is set when
+// the pool has exclude_overlapping_pools set.
+func (c *CombinedConfig) RecordPoolAllocations(networks []*net.IPNet, excludesOverlapping bool) {
+	c.poolsMu.Lock()
+	defer c.poolsMu.Unlock()
+	if c.poolAllocations == nil {
+		c.poolAllocations = make(map[string]*net.IPNet)
+		c.excludingPoolAllocations = make(map[string]*net.IPNet)
+	}
+	for _, network := range networks {
+		c.poolAllocations[network.String()] = network
+		if excludesOverlapping {
+			c.excludingPoolAllocations[network.String()] = network
+		}
+	}
+}
+
+// PoolAllocations returns every CIDR recorded by RecordPoolAllocations, in
+// a stable order.
+func (c *CombinedConfig) PoolAllocations() []*net.IPNet {
+	c.poolsMu.Lock()
+	defer c.poolsMu.Unlock()
+	return sortedNetworks(c.poolAllocations)
+}
+
+// ExcludingPoolAllocations returns the CIDRs recorded by RecordPoolAllocations
+// for pools with exclude_overlapping_pools set, in a stable order. A pool
+// without it set avoids these, since the registry lets it share address
+// space with those pools.
+func (c *CombinedConfig) ExcludingPoolAllocations() []*net.IPNet {
+	c.poolsMu.Lock()
+	defer c.poolsMu.Unlock()
+	return sortedNetworks(c.excludingPoolAllocations)
+}
+
+// sortedNetworks returns the networks of a map keyed by their string form,
+// sorted by key.
+func sortedNetworks(m map[string]*net.IPNet) []*net.IPNet {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	networks := make([]*net.IPNet, 0, len(keys))
+	for _, key := range keys {
+		networks = append(networks, m[key])
+	}
+	return networks
+}
+
+// LockPoolAllocations serializes the allocations of pools, so each one sees
+// the CIDRs of pools allocated before it. It returns the function that
+// releases the lock.
+func (c *CombinedConfig) LockPoolAllocations() func() {
+	c.poolAllocationMu.Lock()
+	return c.poolAllocationMu.Unlock
+}
+
 // Client creates a new godo client from the configuration.
 func (c *Config) Client() (*CombinedConfig, error) {
 	tokenSrc := oauth2.StaticTokenSource(&oauth2.Token{
@@ -41,7 +379,11 @@ func (c *Config) Client() (*CombinedConfig, error) {
 	userAgent := fmt.Sprintf("Terraform/%s", c.TerraformVersion)
 	var godoOpts []godo.ClientOpt
 
+	// http_timeout covers API requests as well as the provider's other fetches
 	client := oauth2.NewClient(context.Background(), tokenSrc)
+	if c.HTTPTimeout > 0 {
+		client.Timeout = secondsToDuration(c.HTTPTimeout)
+	}
 
 	if c.HTTPRetryMax > 0 {
 		retryConfig := godo.RetryConfig{
@@ -60,6 +402,26 @@ func (c *Config) Client() (*CombinedConfig, error) {
 	if err != nil {
 		return nil, err
 	}
+	if retrying := retryingClient(godoClient); retrying != nil {
+		bufferRetriedResponses(retrying)
+		countRetries(retrying)
+	}
+
+	// Custom headers are added inside the logging transport so that their
+	// values aren't logged
+	if len(c.Headers) > 0 {
+		godoClient.HTTPClient.Transport = newHeaderTransport(c.Headers, godoClient.HTTPClient.Transport)
+	}
+
+	// Recorded responses are replayed beneath the logging transport so that
+	// they are logged like responses from the API
+	cassette, err := cassetteFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	if cassette != nil {
+		godoClient.HTTPClient.Transport = cassette.Transport(godoClient.HTTPClient.Transport)
+	}
 
 	// Add logging transport for debugging
 	// TODO: logging.NewTransport is deprecated and should be replaced with
@@ -67,7 +429,14 @@ func (c *Config) Client() (*CombinedConfig, error) {
 	//
 	//nolint:staticcheck
 	clientTransport := logging.NewTransport("DigitalOcean", godoClient.HTTPClient.Transport)
-	godoClient.HTTPClient.Transport = clientTransport
+
+	// Count each request once, however many times it is retried
+	godoClient.HTTPClient.Transport = &metricsTransport{base: clientTransport}
+
+	// Without a token, requests fail before they are retried or sent
+	if c.Token == "" {
+		godoClient.HTTPClient.Transport = missingTokenTransport{}
+	}
 
 	if c.APIEndpoint != "" {
 		apiURL, err := url.Parse(c.APIEndpoint)
@@ -79,11 +448,74 @@ func (c *Config) Client() (*CombinedConfig, error) {
 
 	log.Printf("[INFO] DigitalOcean Client configured for URL: %s", godoClient.BaseURL.String())
 
+	var ipamHTTPClient *http.Client
+	if c.IPAMSource != nil {
+		ipamHTTPClient = c.unloggedHTTPClient(c.IPAMSource.Timeout)
+	}
+
 	return &CombinedConfig{
-		client: godoClient,
+		client:                 godoClient,
+		httpClient:             c.httpClient(),
+		exclusionURLs:          c.ExclusionURLs,
+		onExclusionSourceError: c.OnExclusionSourceError,
+		appPlatformRangesURL:   c.AppPlatformRangesURL,
+		envExclusions:          c.EnvExclusions,
+		apiPageSize:            c.APIPageSize,
+		sizePresets:            c.SizePresets,
+		terraformVersion:       c.TerraformVersion,
+		providerVersion:        c.ProviderVersion,
+
+		computeAllocationsAtPlanTime: c.ComputeAllocationsAtPlanTime,
+
+		ipamSource:     c.IPAMSource,
+		ipamHTTPClient: ipamHTTPClient,
 	}, nil
 }
 
+// httpClient builds an unauthenticated, retrying HTTP client using the
+// provider's retry and timeout settings. The timeout applies to each attempt.
+func (c *Config) httpClient() *http.Client {
+	//nolint:staticcheck
+	return c.retryingHTTPClient(c.HTTPTimeout, func(base http.RoundTripper) http.RoundTripper {
+		return logging.NewTransport("docidr", base)
+	})
+}
+
+// unloggedHTTPClient is like httpClient, with a timeout in seconds of its own,
+// but doesn't log requests and responses at DEBUG level. It is for APIs whose
+// credentials are set on each request, which would otherwise be logged; the
+// DigitalOcean token is added beneath the logging instead.
+func (c *Config) unloggedHTTPClient(timeout float64) *http.Client {
+	return c.retryingHTTPClient(timeout, func(base http.RoundTripper) http.RoundTripper {
+		return base
+	})
+}
+
+// retryingHTTPClient builds a retrying HTTP client whose transport is wrapped
+// by wrap. A timeout of zero disables it.
+func (c *Config) retryingHTTPClient(timeout float64, wrap func(http.RoundTripper) http.RoundTripper) *http.Client {
+	retryClient := retryablehttp.NewClient()
+	retryClient.RetryMax = c.HTTPRetryMax
+	retryClient.RetryWaitMin = secondsToDuration(c.HTTPRetryWaitMin)
+	retryClient.RetryWaitMax = secondsToDuration(c.HTTPRetryWaitMax)
+	retryClient.Logger = log.Default()
+	retryClient.ErrorHandler = retryablehttp.PassthroughErrorHandler
+	if timeout > 0 {
+		retryClient.HTTPClient.Timeout = secondsToDuration(timeout)
+	}
+
+	retryClient.HTTPClient.Transport = &bufferedBodyTransport{
+		base: wrap(retryClient.HTTPClient.Transport),
+	}
+
+	return retryClient.StandardClient()
+}
+
+// secondsToDuration converts fractional seconds to a time.Duration.
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
+
 // DefaultHTTPClient returns a basic HTTP client for simple API calls.
 func DefaultHTTPClient(token string) *http.Client {
 	tokenSrc := oauth2.StaticTokenSource(&oauth2.Token{
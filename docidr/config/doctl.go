@@ -0,0 +1,71 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// doctlDefaultContext is the auth context whose token is doctl's top-level
+// access-token rather than an entry of auth-contexts.
+const doctlDefaultContext = "default"
+
+// doctlConfig is the part of doctl's config.yaml holding credentials.
+type doctlConfig struct {
+	AccessToken  string            `yaml:"access-token"`
+	AuthContexts map[string]string `yaml:"auth-contexts"`
+	Context      string            `yaml:"context"`
+}
+
+// DefaultDoctlConfigPath returns where doctl keeps its configuration: the
+// doctl directory of the user's configuration directory, which is
+// $XDG_CONFIG_HOME or ~/.config on Linux.
+func DefaultDoctlConfigPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("error locating the doctl config: %w", err)
+	}
+	return filepath.Join(dir, "doctl", "config.yaml"), nil
+}
+
+// DoctlToken returns the access token of the current auth context in the doctl
+// config at path, as set by doctl auth init and doctl auth switch. Errors
+// never include the token.
+func DoctlToken(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", fmt.Errorf("doctl config %s not found; run doctl auth init, or set doctl_config_path", path)
+	}
+	if err != nil {
+		return "", fmt.Errorf("error reading doctl config %s: %w", path, err)
+	}
+
+	// Type errors quote the values they couldn't decode, which may be tokens
+	var cfg doctlConfig
+	var typeErr *yaml.TypeError
+	if err := yaml.Unmarshal(data, &cfg); errors.As(err, &typeErr) {
+		return "", fmt.Errorf("error parsing doctl config %s: access-token, auth-contexts or context has the wrong type", path)
+	} else if err != nil {
+		return "", fmt.Errorf("error parsing doctl config %s: %w", path, err)
+	}
+
+	context := cfg.Context
+	if context == "" {
+		context = doctlDefaultContext
+	}
+	token := cfg.AccessToken
+	if context != doctlDefaultContext {
+		var ok bool
+		if token, ok = cfg.AuthContexts[context]; !ok {
+			return "", fmt.Errorf("doctl config %s has no auth context %q; run doctl auth init --context %s", path, context, context)
+		}
+	}
+	if token == "" {
+		return "", fmt.Errorf("doctl config %s has no access token for auth context %q; run doctl auth init --context %s", path, context, context)
+	}
+	return token, nil
+}
@@ -0,0 +1,63 @@
+package config
+
+import (
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestDoctlToken(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		want    string
+		wantErr string
+	}{
+		{name: "default context", file: "default.yaml", want: "dop_v1_default_fixture"},
+		{name: "named context", file: "named.yaml", want: "dop_v1_staging_fixture"},
+		{name: "missing file", file: "absent.yaml", wantErr: "not found; run doctl auth init"},
+		{name: "missing context", file: "missing_context.yaml", wantErr: `has no auth context "staging"`},
+		{name: "malformed", file: "malformed.yaml", wantErr: "error parsing doctl config"},
+		{name: "wrong type", file: "wrong_type.yaml", wantErr: "access-token, auth-contexts or context has the wrong type"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join("testdata", "doctl", tt.file)
+			got, err := DoctlToken(path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("DoctlToken(%s) error = %v, want it to contain %q", path, err, tt.wantErr)
+				}
+				if strings.Contains(err.Error(), "fixture") {
+					t.Errorf("DoctlToken(%s) error = %v, which leaks a token", path, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("DoctlToken(%s) error = %v", path, err)
+			}
+			if got != tt.want {
+				t.Errorf("DoctlToken(%s) = %q, want %q", path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDefaultDoctlConfigPath(t *testing.T) {
+	// XDG_CONFIG_HOME is only honored on Linux and other Unix systems
+	if runtime.GOOS != "linux" {
+		t.Skipf("XDG_CONFIG_HOME isn't used on %s", runtime.GOOS)
+	}
+	t.Setenv("XDG_CONFIG_HOME", "/tmp/xdg")
+	t.Setenv("HOME", "/tmp/home")
+
+	got, err := DefaultDoctlConfigPath()
+	if err != nil {
+		t.Fatalf("DefaultDoctlConfigPath() error = %v", err)
+	}
+	if want := "/tmp/xdg/doctl/config.yaml"; got != want {
+		t.Errorf("DefaultDoctlConfigPath() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,153 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-retryablehttp"
+)
+
+// apiMetricsKey is the context key for the APIMetrics of an operation.
+type apiMetricsKey struct{}
+
+// APIMetrics accumulates statistics about the DigitalOcean API requests made
+// during one resource operation. It is safe for concurrent use.
+type APIMetrics struct {
+	mu                 sync.Mutex
+	calls              int
+	retries            int
+	pages              map[string]int
+	httpTime           time.Duration
+	rateLimitRemaining int
+}
+
+// APIMetricsSummary is a snapshot of APIMetrics.
+type APIMetricsSummary struct {
+	// Calls is the number of API requests made, not counting retries.
+	Calls int
+	// Retries is the number of times a request was retried.
+	Retries int
+	// Pages is the number of GET requests made to each API path.
+	Pages map[string]int
+	// HTTPTime is the total time spent in requests, including waits between
+	// retries.
+	HTTPTime time.Duration
+	// RateLimitRemaining is the number of requests left in the rate limit
+	// window after the last request, or -1 if no response reported it.
+	RateLimitRemaining int
+}
+
+// WithAPIMetrics returns a context that records the API requests made with it
+// in a new APIMetrics, so each operation's requests are counted separately.
+func WithAPIMetrics(ctx context.Context) (context.Context, *APIMetrics) {
+	metrics := &APIMetrics{pages: make(map[string]int), rateLimitRemaining: -1}
+	return context.WithValue(ctx, apiMetricsKey{}, metrics), metrics
+}
+
+// apiMetricsFromContext returns the APIMetrics of ctx, or nil if it has none.
+func apiMetricsFromContext(ctx context.Context) *APIMetrics {
+	metrics, _ := ctx.Value(apiMetricsKey{}).(*APIMetrics)
+	return metrics
+}
+
+// Summary returns the statistics recorded so far.
+func (m *APIMetrics) Summary() APIMetricsSummary {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	pages := make(map[string]int, len(m.pages))
+	for path, count := range m.pages {
+		pages[path] = count
+	}
+	return APIMetricsSummary{
+		Calls:              m.calls,
+		Retries:            m.retries,
+		Pages:              pages,
+		HTTPTime:           m.httpTime,
+		RateLimitRemaining: m.rateLimitRemaining,
+	}
+}
+
+// recordCall records a request that took elapsed and got resp, which is nil
+// if it failed.
+func (m *APIMetrics) recordCall(req *http.Request, resp *http.Response, elapsed time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.calls++
+	if req.Method == http.MethodGet {
+		m.pages[req.URL.Path]++
+	}
+	m.httpTime += elapsed
+	if resp == nil {
+		return
+	}
+	if remaining, err := strconv.Atoi(resp.Header.Get("Ratelimit-Remaining")); err == nil {
+		m.rateLimitRemaining = remaining
+	}
+}
+
+// recordRetry records that a request was retried.
+func (m *APIMetrics) recordRetry() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.retries++
+}
+
+// String returns the summary on one line, with paths in alphabetical order.
+func (s APIMetricsSummary) String() string {
+	paths := make([]string, 0, len(s.Pages))
+	for path := range s.Pages {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	pages := make([]string, 0, len(paths))
+	for _, path := range paths {
+		pages = append(pages, fmt.Sprintf("%s=%d", path, s.Pages[path]))
+	}
+
+	rateLimit := "unknown"
+	if s.RateLimitRemaining >= 0 {
+		rateLimit = strconv.Itoa(s.RateLimitRemaining)
+	}
+	return fmt.Sprintf("%d API calls, %d retries, %s in HTTP, rate limit remaining %s, pages: [%s]",
+		s.Calls, s.Retries, s.HTTPTime.Round(time.Millisecond), rateLimit, strings.Join(pages, " "))
+}
+
+// metricsTransport records each request in the APIMetrics of its context, if
+// any.
+type metricsTransport struct {
+	base http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *metricsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	metrics := apiMetricsFromContext(req.Context())
+	if metrics == nil {
+		return t.base.RoundTrip(req)
+	}
+
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	metrics.recordCall(req, resp, time.Since(start))
+	return resp, err
+}
+
+// countRetries records the godo client's retries in the APIMetrics of each
+// request's context. It has no effect on clients without retries configured.
+func countRetries(client *retryablehttp.Client) {
+	client.RequestLogHook = func(_ retryablehttp.Logger, req *http.Request, attempt int) {
+		if attempt == 0 {
+			return
+		}
+		if metrics := apiMetricsFromContext(req.Context()); metrics != nil {
+			metrics.recordRetry()
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/digitalocean/godo"
+)
+
+// newPagedVPCServer serves pages pages of VPCs, reporting the rate limit left
+// as 5000 minus the number of requests. If failFirst is set, the first
+// request fails with a 500 so that it is retried.
+func newPagedVPCServer(t *testing.T, pages int, failFirst bool) *httptest.Server {
+	t.Helper()
+
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&requests, 1)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Ratelimit-Remaining", fmt.Sprint(5000-n))
+		if failFirst && n == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprint(w, `{"id": "server_error", "message": "try again"}`)
+			return
+		}
+
+		page := 1
+		fmt.Sscan(r.URL.Query().Get("page"), &page)
+		links := "{}"
+		if page < pages {
+			links = fmt.Sprintf(`{"pages": {"next": "http://example.com/v2/vpcs?page=%d"}}`, page+1)
+		}
+		fmt.Fprintf(w, `{"vpcs": [{"id": "vpc-%d", "ip_range": "10.%d.0.0/16"}], "links": %s, "meta": {"total": %d}}`, page, page, links, pages)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// listAllVPCs lists every page of VPCs.
+func listAllVPCs(ctx context.Context, t *testing.T, combined *CombinedConfig) {
+	t.Helper()
+
+	opt := &godo.ListOptions{Page: 1}
+	for {
+		_, resp, err := combined.GodoClient().VPCs.List(ctx, opt)
+		if err != nil {
+			t.Fatalf("VPCs.List() error = %v", err)
+		}
+		if resp.Links == nil || resp.Links.IsLastPage() {
+			return
+		}
+		opt.Page++
+	}
+}
+
+func TestAPIMetrics(t *testing.T) {
+	tests := []struct {
+		name        string
+		failFirst   bool
+		wantCalls   int
+		wantRetries int
+	}{
+		{name: "no retries", wantCalls: 3},
+		{name: "retried", failFirst: true, wantCalls: 3, wantRetries: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newPagedVPCServer(t, 3, tt.failFirst)
+			combined, err := (&Config{
+				Token:            "test-token",
+				APIEndpoint:      srv.URL + "/",
+				HTTPRetryMax:     2,
+				HTTPRetryWaitMin: 0.001,
+				HTTPRetryWaitMax: 0.001,
+			}).Client()
+			if err != nil {
+				t.Fatalf("Client() error = %v", err)
+			}
+
+			ctx, metrics := WithAPIMetrics(context.Background())
+			listAllVPCs(ctx, t, combined)
+
+			got := metrics.Summary()
+			if got.Calls != tt.wantCalls {
+				t.Errorf("Calls = %d, want %d", got.Calls, tt.wantCalls)
+			}
+			if got.Retries != tt.wantRetries {
+				t.Errorf("Retries = %d, want %d", got.Retries, tt.wantRetries)
+			}
+			if len(got.Pages) != 1 || got.Pages["/v2/vpcs"] != 3 {
+				t.Errorf("Pages = %v, want /v2/vpcs: 3", got.Pages)
+			}
+			if want := 5000 - tt.wantCalls - tt.wantRetries; got.RateLimitRemaining != want {
+				t.Errorf("RateLimitRemaining = %d, want %d", got.RateLimitRemaining, want)
+			}
+			if got.HTTPTime <= 0 {
+				t.Errorf("HTTPTime = %s, want more than 0", got.HTTPTime)
+			}
+			if s := got.String(); !strings.Contains(s, fmt.Sprintf("%d API calls, %d retries", tt.wantCalls, tt.wantRetries)) || !strings.Contains(s, "/v2/vpcs=3") {
+				t.Errorf("String() = %q", s)
+			}
+
+			// Requests made with another context aren't counted
+			listAllVPCs(context.Background(), t, combined)
+			if again := metrics.Summary(); again.Calls != got.Calls {
+				t.Errorf("Calls after listing without metrics = %d, want %d", again.Calls, got.Calls)
+			}
+		})
+	}
+}
+
+func TestAPIMetricsSummary_String(t *testing.T) {
+	_, metrics := WithAPIMetrics(context.Background())
+	if got, want := metrics.Summary().String(), "0 API calls, 0 retries, 0s in HTTP, rate limit remaining unknown, pages: []"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
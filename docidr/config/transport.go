@@ -0,0 +1,116 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/digitalocean/godo"
+	"github.com/hashicorp/go-retryablehttp"
+	"golang.org/x/oauth2"
+)
+
+// bufferedBodyTransport reads each response body in full before returning the
+// response. A connection that is reset or closed part way through the body
+// then fails the round trip, where the retrying client retries it like any
+// other transport error, instead of failing later when the caller decodes the
+// body.
+type bufferedBodyTransport struct {
+	base http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *bufferedBodyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("error reading response body: %w", err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	return resp, nil
+}
+
+// retryingClient returns the retrying client beneath the godo client's
+// authentication, or nil if the godo client has no retries configured.
+func retryingClient(client *godo.Client) *retryablehttp.Client {
+	source, ok := client.HTTPClient.Transport.(*oauth2.Transport)
+	if !ok {
+		return nil
+	}
+	retrying, ok := source.Base.(*retryablehttp.RoundTripper)
+	if !ok {
+		return nil
+	}
+	return retrying.Client
+}
+
+// bufferRetriedResponses makes the retrying client's retries cover errors
+// reading response bodies.
+func bufferRetriedResponses(client *retryablehttp.Client) {
+	client.HTTPClient.Transport = &bufferedBodyTransport{base: client.HTTPClient.Transport}
+}
+
+// ReservedHeaders are set by the client itself and can't be configured as
+// custom headers.
+var ReservedHeaders = []string{"Authorization", "User-Agent"}
+
+// headerTransport adds a fixed set of headers to every request. Headers the
+// request already has, such as Authorization, are left unchanged.
+type headerTransport struct {
+	headers http.Header
+	base    http.RoundTripper
+}
+
+// newHeaderTransport returns a transport adding headers to every request made
+// through base.
+func newHeaderTransport(headers map[string]string, base http.RoundTripper) *headerTransport {
+	h := make(http.Header, len(headers))
+	names := make([]string, 0, len(headers))
+	for name, value := range headers {
+		h.Set(name, value)
+		names = append(names, http.CanonicalHeaderKey(name))
+	}
+	sort.Strings(names)
+	log.Printf("[DEBUG] Adding headers %s to API requests", strings.Join(names, ", "))
+
+	return &headerTransport{headers: h, base: base}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	for name, values := range t.headers {
+		if _, ok := req.Header[name]; ok {
+			continue
+		}
+		req.Header[name] = values
+	}
+	return t.base.RoundTrip(req)
+}
+
+// ErrMissingToken is returned by requests to the DigitalOcean API when no
+// token is configured. Configuring the provider doesn't need one, so pools
+// and data sources that don't query the API work without it.
+var ErrMissingToken = errors.New("DigitalOcean token must be configured. Set the token in the provider configuration, use the DIGITALOCEAN_TOKEN environment variable, or set use_doctl_config to use doctl's token.")
+
+// missingTokenTransport fails every request with ErrMissingToken without
+// sending it.
+type missingTokenTransport struct{}
+
+// RoundTrip implements http.RoundTripper.
+func (missingTokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	return nil, ErrMissingToken
+}
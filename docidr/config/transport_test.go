@@ -0,0 +1,156 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// dropFirst serves vpcsJSON, except that the first request is failed by
+// drop, which must break the connection.
+func dropFirst(t *testing.T, drop func(w http.ResponseWriter)) (*httptest.Server, *int32) {
+	t.Helper()
+
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			drop(w)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, vpcsJSON)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &requests
+}
+
+const vpcsJSON = `{"vpcs": [{"id": "vpc-1", "ip_range": "10.0.0.0/16"}], "links": {}, "meta": {"total": 1}}`
+
+// closeConnection closes the connection without writing a response.
+func closeConnection(w http.ResponseWriter) {
+	conn, _, err := w.(http.Hijacker).Hijack()
+	if err == nil {
+		conn.Close()
+	}
+}
+
+// truncateBody writes half of the response body and closes the connection.
+func truncateBody(w http.ResponseWriter) {
+	conn, buf, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(buf, "HTTP/1.1 200 OK\r\nContent-Type: application/json\r\nContent-Length: %d\r\n\r\n%s", len(vpcsJSON), vpcsJSON[:len(vpcsJSON)/2])
+	buf.Flush()
+	conn.Close()
+}
+
+func TestClientRetriesTransportErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		drop func(w http.ResponseWriter)
+	}{
+		{name: "connection closed", drop: closeConnection},
+		{name: "body truncated", drop: truncateBody},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, requests := dropFirst(t, tt.drop)
+			combined, err := (&Config{
+				Token:            "test-token",
+				APIEndpoint:      srv.URL + "/",
+				HTTPRetryMax:     2,
+				HTTPRetryWaitMin: 0.001,
+				HTTPRetryWaitMax: 0.001,
+			}).Client()
+			if err != nil {
+				t.Fatalf("Client() error = %v", err)
+			}
+
+			vpcs, _, err := combined.GodoClient().VPCs.List(context.Background(), nil)
+			if err != nil {
+				t.Fatalf("VPCs.List() error = %v", err)
+			}
+			if len(vpcs) != 1 || vpcs[0].ID != "vpc-1" {
+				t.Errorf("VPCs.List() = %v, want vpc-1", vpcs)
+			}
+			if got := atomic.LoadInt32(requests); got != 2 {
+				t.Errorf("server saw %d requests, want 2", got)
+			}
+		})
+	}
+}
+
+func TestClientRetriesTransportErrors_Disabled(t *testing.T) {
+	srv, requests := dropFirst(t, truncateBody)
+	combined, err := (&Config{Token: "test-token", APIEndpoint: srv.URL + "/"}).Client()
+	if err != nil {
+		t.Fatalf("Client() error = %v", err)
+	}
+
+	if _, _, err := combined.GodoClient().VPCs.List(context.Background(), nil); err == nil {
+		t.Error("VPCs.List() expected error with retries disabled, got nil")
+	}
+	if got := atomic.LoadInt32(requests); got != 1 {
+		t.Errorf("server saw %d requests, want 1", got)
+	}
+}
+
+func TestHTTPClientRetriesTransportErrors(t *testing.T) {
+	srv, requests := dropFirst(t, truncateBody)
+	client := (&Config{HTTPRetryMax: 2, HTTPRetryWaitMin: 0.001, HTTPRetryWaitMax: 0.001}).httpClient()
+
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	defer resp.Body.Close()
+	if got := atomic.LoadInt32(requests); got != 2 {
+		t.Errorf("server saw %d requests, want 2", got)
+	}
+}
+
+func TestClientHeaders(t *testing.T) {
+	var got http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, vpcsJSON)
+	}))
+	t.Cleanup(srv.Close)
+
+	combined, err := (&Config{
+		Token:        "test-token",
+		APIEndpoint:  srv.URL + "/",
+		HTTPRetryMax: 1,
+		Headers: map[string]string{
+			"X-Gateway-Key": "secret",
+			"x-route":       "docidr",
+			"Authorization": "Bearer other",
+		},
+	}).Client()
+	if err != nil {
+		t.Fatalf("Client() error = %v", err)
+	}
+	if _, _, err := combined.GodoClient().VPCs.List(context.Background(), nil); err != nil {
+		t.Fatalf("VPCs.List() error = %v", err)
+	}
+
+	for name, want := range map[string]string{
+		"X-Gateway-Key": "secret",
+		"X-Route":       "docidr",
+		"Authorization": "Bearer test-token",
+	} {
+		if v := got.Get(name); v != want {
+			t.Errorf("%s header = %q, want %q", name, v, want)
+		}
+	}
+	if v := got.Get("User-Agent"); !strings.HasPrefix(v, "Terraform/") {
+		t.Errorf("User-Agent header = %q, want the provider's user agent", v)
+	}
+}
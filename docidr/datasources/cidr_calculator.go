@@ -0,0 +1,150 @@
+package datasources
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"net"
+	"strconv"
+
+	"github.com/DO-Solutions/terraform-provider-docidr/docidr/cidr"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// Operations supported by docidr_cidr_calculator.
+const (
+	calculatorSubnetCount = "subnet_count"
+	calculatorHostCount   = "host_count"
+	calculatorNext        = "next"
+	calculatorPrev        = "prev"
+	calculatorSupernet    = "supernet"
+	calculatorNetworkBits = "network_bits"
+)
+
+// DataSourceDocidrCIDRCalculator returns the docidr_cidr_calculator data source schema.
+func DataSourceDocidrCIDRCalculator() *schema.Resource {
+	return &schema.Resource{
+		ReadContext: dataSourceDocidrCIDRCalculatorRead,
+
+		Schema: map[string]*schema.Schema{
+			"cidr": {
+				Type:         schema.TypeString,
+				Required:     true,
+				ValidateFunc: validation.IsCIDR,
+				Description:  "CIDR block to operate on.",
+			},
+			"operation": {
+				Type:     schema.TypeString,
+				Required: true,
+				ValidateFunc: validation.StringInSlice([]string{
+					calculatorSubnetCount,
+					calculatorHostCount,
+					calculatorNext,
+					calculatorPrev,
+					calculatorSupernet,
+					calculatorNetworkBits,
+				}, false),
+				Description: "The calculation to perform: subnet_count, host_count, next, prev, supernet or network_bits.",
+			},
+			"new_prefix_length": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				ValidateFunc: validation.IntBetween(1, 128),
+				Description:  "Prefix length of the subnets to count for subnet_count (required), or of the network to return for supernet.",
+			},
+			"result": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The result of the calculation.",
+			},
+		},
+
+		Description: "Performs CIDR calculations such as counting subnets or finding the next block, without making API calls.",
+	}
+}
+
+func dataSourceDocidrCIDRCalculatorRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	network, err := cidr.ParseCIDR(d.Get("cidr").(string))
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	operation := d.Get("operation").(string)
+
+	newPrefixLength := -1
+	if v, ok := d.GetOk("new_prefix_length"); ok {
+		newPrefixLength = v.(int)
+	}
+
+	result, err := calculate(network, operation, newPrefixLength)
+	if err != nil {
+		return diag.Errorf("Error calculating %s of %s: %s", operation, network, err)
+	}
+
+	if err := d.Set("result", result); err != nil {
+		return diag.FromErr(err)
+	}
+	id := network.String() + ":" + operation
+	if newPrefixLength >= 0 {
+		id += ":" + strconv.Itoa(newPrefixLength)
+	}
+	d.SetId(id)
+
+	return nil
+}
+
+// calculate performs operation on network. A newPrefixLength of -1 means
+// none was given.
+func calculate(network *net.IPNet, operation string, newPrefixLength int) (string, error) {
+	ones, bits := network.Mask.Size()
+
+	switch operation {
+	case calculatorSubnetCount:
+		if newPrefixLength < 0 {
+			return "", fmt.Errorf("new_prefix_length is required")
+		}
+		if newPrefixLength < ones || newPrefixLength > bits {
+			return "", fmt.Errorf("new_prefix_length must be between %d and %d", ones, bits)
+		}
+		return new(big.Int).Lsh(big.NewInt(1), uint(newPrefixLength-ones)).String(), nil
+
+	case calculatorHostCount:
+		// IPv6 has no network or broadcast address to exclude
+		if bits != 32 {
+			return cidr.AddressCount(network).String(), nil
+		}
+		_, _, count := cidr.UsableHosts(network)
+		return strconv.Itoa(count), nil
+
+	case calculatorNext, calculatorPrev:
+		offset := 1
+		if operation == calculatorPrev {
+			offset = -1
+		}
+		adjacent, err := cidr.AdjacentNetwork(network, offset)
+		if err != nil {
+			return "", err
+		}
+		return adjacent.String(), nil
+
+	case calculatorSupernet:
+		if newPrefixLength < 0 {
+			supernet, err := cidr.ExpandCIDR(network)
+			if err != nil {
+				return "", err
+			}
+			return supernet.String(), nil
+		}
+		if newPrefixLength > ones {
+			return "", fmt.Errorf("new_prefix_length must be at most %d", ones)
+		}
+		mask := net.CIDRMask(newPrefixLength, bits)
+		return (&net.IPNet{IP: network.IP.Mask(mask), Mask: mask}).String(), nil
+
+	case calculatorNetworkBits:
+		return strconv.Itoa(ones), nil
+	}
+
+	return "", fmt.Errorf("unknown operation %q", operation)
+}
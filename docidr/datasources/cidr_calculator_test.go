@@ -0,0 +1,103 @@
+package datasources
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func TestDataSourceDocidrCIDRCalculatorRead(t *testing.T) {
+	tests := []struct {
+		name            string
+		cidr            string
+		operation       string
+		newPrefixLength int
+		want            string
+	}{
+		{name: "subnet count", cidr: "10.0.0.0/16", operation: "subnet_count", newPrefixLength: 24, want: "256"},
+		{name: "subnet count same size", cidr: "10.0.0.0/16", operation: "subnet_count", newPrefixLength: 16, want: "1"},
+		{name: "subnet count IPv6", cidr: "fd00::/48", operation: "subnet_count", newPrefixLength: 112, want: "18446744073709551616"},
+		{name: "host count", cidr: "10.0.0.0/24", operation: "host_count", want: "254"},
+		{name: "host count /31", cidr: "10.0.0.0/31", operation: "host_count", want: "2"},
+		{name: "host count /32", cidr: "10.0.0.1/32", operation: "host_count", want: "1"},
+		{name: "host count IPv6", cidr: "fd00::/120", operation: "host_count", want: "256"},
+		{name: "next", cidr: "10.0.0.0/24", operation: "next", want: "10.0.1.0/24"},
+		{name: "next unaligned input", cidr: "10.0.0.9/24", operation: "next", want: "10.0.1.0/24"},
+		{name: "prev", cidr: "10.0.1.0/24", operation: "prev", want: "10.0.0.0/24"},
+		{name: "next IPv6", cidr: "fd00::/64", operation: "next", want: "fd00:0:0:1::/64"},
+		{name: "supernet", cidr: "10.1.0.0/16", operation: "supernet", want: "10.0.0.0/15"},
+		{name: "supernet to prefix", cidr: "10.1.2.0/24", operation: "supernet", newPrefixLength: 8, want: "10.0.0.0/8"},
+		{name: "network bits", cidr: "10.0.0.0/20", operation: "network_bits", want: "20"},
+		{name: "network bits IPv6", cidr: "fd00::/56", operation: "network_bits", want: "56"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw := map[string]interface{}{"cidr": tt.cidr, "operation": tt.operation}
+			if tt.newPrefixLength != 0 {
+				raw["new_prefix_length"] = tt.newPrefixLength
+			}
+			d := schema.TestResourceDataRaw(t, DataSourceDocidrCIDRCalculator().Schema, raw)
+
+			if diags := dataSourceDocidrCIDRCalculatorRead(context.Background(), d, nil); diags.HasError() {
+				t.Fatalf("Read() diags = %v", diags)
+			}
+			if got := d.Get("result").(string); got != tt.want {
+				t.Errorf("result = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDataSourceDocidrCIDRCalculatorRead_Errors(t *testing.T) {
+	tests := []struct {
+		name            string
+		cidr            string
+		operation       string
+		newPrefixLength int
+		wantErr         string
+	}{
+		{name: "subnet count without prefix", cidr: "10.0.0.0/16", operation: "subnet_count", wantErr: "new_prefix_length is required"},
+		{name: "subnet count larger prefix", cidr: "10.0.0.0/16", operation: "subnet_count", newPrefixLength: 8, wantErr: "between 16 and 32"},
+		{name: "subnet count beyond address", cidr: "10.0.0.0/16", operation: "subnet_count", newPrefixLength: 33, wantErr: "between 16 and 32"},
+		{name: "next past end", cidr: "255.255.255.0/24", operation: "next", wantErr: "address space"},
+		{name: "prev before start", cidr: "0.0.0.0/8", operation: "prev", wantErr: "address space"},
+		{name: "supernet of everything", cidr: "0.0.0.0/0", operation: "supernet", wantErr: "whole address space"},
+		{name: "supernet smaller prefix", cidr: "10.0.0.0/16", operation: "supernet", newPrefixLength: 24, wantErr: "at most 16"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw := map[string]interface{}{"cidr": tt.cidr, "operation": tt.operation}
+			if tt.newPrefixLength != 0 {
+				raw["new_prefix_length"] = tt.newPrefixLength
+			}
+			d := schema.TestResourceDataRaw(t, DataSourceDocidrCIDRCalculator().Schema, raw)
+
+			diags := dataSourceDocidrCIDRCalculatorRead(context.Background(), d, nil)
+			if !diags.HasError() {
+				t.Fatalf("Read() expected error, got result %s", d.Get("result"))
+			}
+			if !strings.Contains(diags[0].Summary, tt.wantErr) {
+				t.Errorf("Read() error = %q, want it to contain %q", diags[0].Summary, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDataSourceDocidrCIDRCalculator_OperationValidation(t *testing.T) {
+	validate := DataSourceDocidrCIDRCalculator().Schema["operation"].ValidateFunc
+
+	for _, op := range []string{"subnet_count", "host_count", "next", "prev", "supernet", "network_bits"} {
+		if _, errs := validate(op, "operation"); len(errs) > 0 {
+			t.Errorf("operation %q rejected: %v", op, errs)
+		}
+	}
+	for _, op := range []string{"", "broadcast", "Next"} {
+		if _, errs := validate(op, "operation"); len(errs) == 0 {
+			t.Errorf("operation %q accepted, want error", op)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package datasources
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/DO-Solutions/terraform-provider-docidr/docidr/cidr"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// DataSourceDocidrConflicts returns the docidr_conflicts data source schema.
+func DataSourceDocidrConflicts() *schema.Resource {
+	return &schema.Resource{
+		ReadContext: dataSourceDocidrConflictsRead,
+
+		Schema: map[string]*schema.Schema{
+			"set_a": {
+				Type:        schema.TypeList,
+				Required:    true,
+				Description: "First list of CIDR blocks.",
+				Elem: &schema.Schema{
+					Type:         schema.TypeString,
+					ValidateFunc: validation.IsCIDR,
+				},
+			},
+			"set_b": {
+				Type:        schema.TypeList,
+				Required:    true,
+				Description: "Second list of CIDR blocks.",
+				Elem: &schema.Schema{
+					Type:         schema.TypeString,
+					ValidateFunc: validation.IsCIDR,
+				},
+			},
+			"fail_on_conflict": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "Whether the read fails when any block in set_a conflicts with a block in set_b.",
+			},
+			"conflicts": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "Every pair of conflicting blocks.",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"a": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "The block from set_a.",
+						},
+						"b": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "The block from set_b.",
+						},
+						"relationship": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "How a relates to b: `equal`, `contains`, `contained` or `overlaps`.",
+						},
+					},
+				},
+			},
+			"has_conflicts": {
+				Type:        schema.TypeBool,
+				Computed:    true,
+				Description: "Whether any block in set_a conflicts with a block in set_b.",
+			},
+		},
+
+		Description: "Finds overlapping CIDR blocks between two lists of CIDRs.",
+	}
+}
+
+// cidrConflict is a pair of overlapping blocks from the two sets.
+type cidrConflict struct {
+	A, B         *net.IPNet
+	Relationship cidr.Relationship
+}
+
+func dataSourceDocidrConflictsRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	setA, err := cidr.ParseCIDRs(expandStrings(d.Get("set_a").([]interface{})))
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	setB, err := cidr.ParseCIDRs(expandStrings(d.Get("set_b").([]interface{})))
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	conflicts := findConflicts(setA, setB)
+	if d.Get("fail_on_conflict").(bool) && len(conflicts) > 0 {
+		var pairs []string
+		for _, c := range conflicts {
+			pairs = append(pairs, fmt.Sprintf("%s %s %s", c.A, c.Relationship, c.B))
+		}
+		return diag.Errorf("Found %d CIDR conflicts: %s", len(conflicts), strings.Join(pairs, ", "))
+	}
+
+	if err := d.Set("conflicts", flattenConflicts(conflicts)); err != nil {
+		return diag.FromErr(err)
+	}
+	if err := d.Set("has_conflicts", len(conflicts) > 0); err != nil {
+		return diag.FromErr(err)
+	}
+	d.SetId(conflictsID(setA, setB))
+
+	return nil
+}
+
+// findConflicts returns every pair of overlapping blocks from setA and setB,
+// in set_a then set_b order.
+func findConflicts(setA, setB []*net.IPNet) []cidrConflict {
+	var conflicts []cidrConflict
+	for _, a := range setA {
+		for _, b := range setB {
+			if relationship := cidr.Classify(a, b); relationship != cidr.RelationshipNone {
+				conflicts = append(conflicts, cidrConflict{A: a, B: b, Relationship: relationship})
+			}
+		}
+	}
+	return conflicts
+}
+
+// flattenConflicts converts conflicts to a schema-compatible format.
+func flattenConflicts(conflicts []cidrConflict) []interface{} {
+	result := make([]interface{}, 0, len(conflicts))
+	for _, c := range conflicts {
+		result = append(result, map[string]interface{}{
+			"a":            c.A.String(),
+			"b":            c.B.String(),
+			"relationship": string(c.Relationship),
+		})
+	}
+	return result
+}
+
+// conflictsID derives a stable ID from the two sets.
+func conflictsID(setA, setB []*net.IPNet) string {
+	h := sha256.New()
+	for _, set := range [][]*net.IPNet{setA, setB} {
+		for _, network := range set {
+			h.Write([]byte(network.String() + ","))
+		}
+		h.Write([]byte(";"))
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// expandStrings converts a list of interface{} values to strings.
+func expandStrings(values []interface{}) []string {
+	result := make([]string, 0, len(values))
+	for _, v := range values {
+		result = append(result, v.(string))
+	}
+	return result
+}
@@ -0,0 +1,101 @@
+package datasources
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func TestDataSourceDocidrConflictsRead(t *testing.T) {
+	tests := []struct {
+		name string
+		setA []interface{}
+		setB []interface{}
+		want []map[string]interface{}
+	}{
+		{
+			name: "no conflicts",
+			setA: []interface{}{"10.0.0.0/16"},
+			setB: []interface{}{"10.1.0.0/16", "192.168.0.0/24"},
+		},
+		{
+			name: "all relationships",
+			setA: []interface{}{"10.0.0.0/16", "10.1.0.0/16", "10.2.0.0/24", "10.3.0.0/16"},
+			setB: []interface{}{"10.0.0.0/16", "10.1.4.0/24", "10.2.0.0/16", "::ffff:10.3.0.0/120"},
+			want: []map[string]interface{}{
+				{"a": "10.0.0.0/16", "b": "10.0.0.0/16", "relationship": "equal"},
+				{"a": "10.1.0.0/16", "b": "10.1.4.0/24", "relationship": "contains"},
+				{"a": "10.2.0.0/24", "b": "10.2.0.0/16", "relationship": "contained"},
+				{"a": "10.3.0.0/16", "b": "10.3.0.0/24", "relationship": "overlaps"},
+			},
+		},
+		{
+			name: "empty set_a",
+			setB: []interface{}{"10.0.0.0/16"},
+		},
+		{
+			name: "empty set_b",
+			setA: []interface{}{"10.0.0.0/16"},
+		},
+		{
+			name: "both empty",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := schema.TestResourceDataRaw(t, DataSourceDocidrConflicts().Schema, map[string]interface{}{
+				"set_a": tt.setA,
+				"set_b": tt.setB,
+			})
+
+			if diags := dataSourceDocidrConflictsRead(context.Background(), d, nil); diags.HasError() {
+				t.Fatalf("Read() diags = %v", diags)
+			}
+
+			conflicts := d.Get("conflicts").([]interface{})
+			if len(conflicts) != len(tt.want) {
+				t.Fatalf("conflicts = %v, want %v", conflicts, tt.want)
+			}
+			for i, want := range tt.want {
+				got := conflicts[i].(map[string]interface{})
+				for key, value := range want {
+					if got[key] != value {
+						t.Errorf("conflicts[%d].%s = %v, want %v", i, key, got[key], value)
+					}
+				}
+			}
+			if got := d.Get("has_conflicts").(bool); got != (len(tt.want) > 0) {
+				t.Errorf("has_conflicts = %v, want %v", got, len(tt.want) > 0)
+			}
+			if d.Id() == "" {
+				t.Error("id should be set")
+			}
+		})
+	}
+}
+
+func TestDataSourceDocidrConflictsRead_FailOnConflict(t *testing.T) {
+	raw := map[string]interface{}{
+		"set_a":            []interface{}{"10.0.0.0/16"},
+		"set_b":            []interface{}{"10.0.4.0/24"},
+		"fail_on_conflict": true,
+	}
+
+	d := schema.TestResourceDataRaw(t, DataSourceDocidrConflicts().Schema, raw)
+	diags := dataSourceDocidrConflictsRead(context.Background(), d, nil)
+	if !diags.HasError() {
+		t.Fatal("expected an error for conflicting sets")
+	}
+	if !strings.Contains(diags[0].Summary, "10.0.0.0/16 contains 10.0.4.0/24") {
+		t.Errorf("error = %q, want it to name the conflict", diags[0].Summary)
+	}
+
+	raw["set_b"] = []interface{}{"10.1.0.0/24"}
+	d = schema.TestResourceDataRaw(t, DataSourceDocidrConflicts().Schema, raw)
+	if diags := dataSourceDocidrConflictsRead(context.Background(), d, nil); diags.HasError() {
+		t.Errorf("Read() diags = %v, want no error without conflicts", diags)
+	}
+}
@@ -0,0 +1,113 @@
+package datasources
+
+import (
+	"context"
+	"net"
+
+	"github.com/DO-Solutions/terraform-provider-docidr/docidr/cidr"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// DataSourceDocidrIPRange returns the docidr_ip_range data source schema.
+func DataSourceDocidrIPRange() *schema.Resource {
+	return &schema.Resource{
+		ReadContext: dataSourceDocidrIPRangeRead,
+
+		Schema: map[string]*schema.Schema{
+			"cidr": {
+				Type:         schema.TypeString,
+				Required:     true,
+				ValidateFunc: validation.IsCIDR,
+				Description:  "IPv4 CIDR block to describe.",
+			},
+			"network_address": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The first address of the block.",
+			},
+			"prefix_length": {
+				Type:        schema.TypeInt,
+				Computed:    true,
+				Description: "The prefix length of the block.",
+			},
+			"netmask": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The netmask in dotted-decimal notation.",
+			},
+			"wildcard_mask": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The inverse of the netmask, as used by ACLs.",
+			},
+			"broadcast_address": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The broadcast address, or empty for /31 and /32 blocks, which have none.",
+			},
+			"first_usable": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The first address that can be assigned to a host.",
+			},
+			"last_usable": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The last address that can be assigned to a host.",
+			},
+			"address_count": {
+				Type:        schema.TypeInt,
+				Computed:    true,
+				Description: "The total number of addresses in the block.",
+			},
+			"host_count": {
+				Type:        schema.TypeInt,
+				Computed:    true,
+				Description: "The number of addresses that can be assigned to hosts.",
+			},
+		},
+
+		Description: "Breaks an IPv4 CIDR block into its network address, masks, broadcast address and usable host range.",
+	}
+}
+
+func dataSourceDocidrIPRangeRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	network, err := cidr.ParseCIDR(d.Get("cidr").(string))
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	ones, bits := network.Mask.Size()
+	if bits != 32 {
+		return diag.Errorf("docidr_ip_range only supports IPv4 CIDR blocks, got %s", network)
+	}
+
+	// /31 blocks are point-to-point links (RFC 3021) and a /32 is a single
+	// host. Neither has a broadcast address.
+	broadcast := ""
+	if ones <= 30 {
+		broadcast = cidr.LastAddress(network).String()
+	}
+	firstUsable, lastUsable, hostCount := cidr.UsableHosts(network)
+
+	values := map[string]interface{}{
+		"network_address":   network.IP.Mask(network.Mask).String(),
+		"prefix_length":     ones,
+		"netmask":           net.IP(network.Mask).String(),
+		"wildcard_mask":     net.IP(cidr.WildcardMask(network)).String(),
+		"broadcast_address": broadcast,
+		"first_usable":      firstUsable.String(),
+		"last_usable":       lastUsable.String(),
+		"address_count":     int(cidr.AddressCount(network).Int64()),
+		"host_count":        hostCount,
+	}
+	for k, v := range values {
+		if err := d.Set(k, v); err != nil {
+			return diag.FromErr(err)
+		}
+	}
+	d.SetId(network.String())
+
+	return nil
+}
@@ -0,0 +1,128 @@
+package datasources
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func TestDataSourceDocidrIPRangeRead(t *testing.T) {
+	tests := []struct {
+		cidr string
+		want map[string]interface{}
+	}{
+		{
+			cidr: "10.0.0.0/8",
+			want: map[string]interface{}{
+				"network_address":   "10.0.0.0",
+				"prefix_length":     8,
+				"netmask":           "255.0.0.0",
+				"wildcard_mask":     "0.255.255.255",
+				"broadcast_address": "10.255.255.255",
+				"first_usable":      "10.0.0.1",
+				"last_usable":       "10.255.255.254",
+				"address_count":     16777216,
+				"host_count":        16777214,
+			},
+		},
+		{
+			cidr: "172.16.4.0/22",
+			want: map[string]interface{}{
+				"network_address":   "172.16.4.0",
+				"prefix_length":     22,
+				"netmask":           "255.255.252.0",
+				"wildcard_mask":     "0.0.3.255",
+				"broadcast_address": "172.16.7.255",
+				"first_usable":      "172.16.4.1",
+				"last_usable":       "172.16.7.254",
+				"address_count":     1024,
+				"host_count":        1022,
+			},
+		},
+		{
+			// Host bits are ignored
+			cidr: "192.168.1.77/24",
+			want: map[string]interface{}{
+				"network_address":   "192.168.1.0",
+				"netmask":           "255.255.255.0",
+				"broadcast_address": "192.168.1.255",
+				"first_usable":      "192.168.1.1",
+				"last_usable":       "192.168.1.254",
+				"host_count":        254,
+			},
+		},
+		{
+			cidr: "192.168.1.8/30",
+			want: map[string]interface{}{
+				"network_address":   "192.168.1.8",
+				"wildcard_mask":     "0.0.0.3",
+				"broadcast_address": "192.168.1.11",
+				"first_usable":      "192.168.1.9",
+				"last_usable":       "192.168.1.10",
+				"address_count":     4,
+				"host_count":        2,
+			},
+		},
+		{
+			cidr: "192.168.1.4/31",
+			want: map[string]interface{}{
+				"network_address":   "192.168.1.4",
+				"netmask":           "255.255.255.254",
+				"broadcast_address": "",
+				"first_usable":      "192.168.1.4",
+				"last_usable":       "192.168.1.5",
+				"address_count":     2,
+				"host_count":        2,
+			},
+		},
+		{
+			cidr: "192.168.1.7/32",
+			want: map[string]interface{}{
+				"network_address":   "192.168.1.7",
+				"netmask":           "255.255.255.255",
+				"wildcard_mask":     "0.0.0.0",
+				"broadcast_address": "",
+				"first_usable":      "192.168.1.7",
+				"last_usable":       "192.168.1.7",
+				"address_count":     1,
+				"host_count":        1,
+			},
+		},
+		{
+			cidr: "0.0.0.0/0",
+			want: map[string]interface{}{
+				"network_address":   "0.0.0.0",
+				"netmask":           "0.0.0.0",
+				"broadcast_address": "255.255.255.255",
+				"first_usable":      "0.0.0.1",
+				"last_usable":       "255.255.255.254",
+				"address_count":     4294967296,
+				"host_count":        4294967294,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.cidr, func(t *testing.T) {
+			d := schema.TestResourceDataRaw(t, DataSourceDocidrIPRange().Schema, map[string]interface{}{"cidr": tt.cidr})
+
+			if diags := dataSourceDocidrIPRangeRead(context.Background(), d, nil); diags.HasError() {
+				t.Fatalf("Read() diags = %v", diags)
+			}
+			for k, want := range tt.want {
+				if got := d.Get(k); got != want {
+					t.Errorf("%s = %v, want %v", k, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestDataSourceDocidrIPRangeRead_IPv6(t *testing.T) {
+	d := schema.TestResourceDataRaw(t, DataSourceDocidrIPRange().Schema, map[string]interface{}{"cidr": "fd00::/64"})
+
+	if diags := dataSourceDocidrIPRangeRead(context.Background(), d, nil); !diags.HasError() {
+		t.Error("Read() expected error for IPv6 block, got none")
+	}
+}
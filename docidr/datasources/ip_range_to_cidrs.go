@@ -0,0 +1,62 @@
+package datasources
+
+import (
+	"context"
+	"net"
+
+	"github.com/DO-Solutions/terraform-provider-docidr/docidr/cidr"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// DataSourceDocidrIPRangeToCIDRs returns the docidr_ip_range_to_cidrs data
+// source schema.
+func DataSourceDocidrIPRangeToCIDRs() *schema.Resource {
+	return &schema.Resource{
+		ReadContext: dataSourceDocidrIPRangeToCIDRsRead,
+
+		Schema: map[string]*schema.Schema{
+			"first": {
+				Type:         schema.TypeString,
+				Required:     true,
+				ValidateFunc: validation.IsIPv4Address,
+				Description:  "The first IPv4 address of the range.",
+			},
+			"last": {
+				Type:         schema.TypeString,
+				Required:     true,
+				ValidateFunc: validation.IsIPv4Address,
+				Description:  "The last IPv4 address of the range, inclusive.",
+			},
+			"cidrs": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Description: "The fewest CIDR blocks that cover the range exactly, in address order.",
+			},
+		},
+
+		Description: "Converts an IPv4 address range to the CIDR blocks that cover it exactly.",
+	}
+}
+
+func dataSourceDocidrIPRangeToCIDRsRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	first := net.ParseIP(d.Get("first").(string))
+	last := net.ParseIP(d.Get("last").(string))
+	networks, err := cidr.IPRange(first, last)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	cidrs := make([]string, 0, len(networks))
+	for _, network := range networks {
+		cidrs = append(cidrs, network.String())
+	}
+	if err := d.Set("cidrs", cidrs); err != nil {
+		return diag.FromErr(err)
+	}
+	d.SetId(first.String() + "-" + last.String())
+
+	return nil
+}
@@ -0,0 +1,44 @@
+package datasources
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func TestDataSourceDocidrIPRangeToCIDRsRead(t *testing.T) {
+	tests := []struct {
+		first string
+		last  string
+		want  []interface{}
+	}{
+		{first: "10.0.0.0", last: "10.0.0.255", want: []interface{}{"10.0.0.0/24"}},
+		{first: "10.0.0.0", last: "10.0.0.6", want: []interface{}{"10.0.0.0/30", "10.0.0.4/31", "10.0.0.6/32"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.first+"-"+tt.last, func(t *testing.T) {
+			d := schema.TestResourceDataRaw(t, DataSourceDocidrIPRangeToCIDRs().Schema, map[string]interface{}{"first": tt.first, "last": tt.last})
+
+			if diags := dataSourceDocidrIPRangeToCIDRsRead(context.Background(), d, nil); diags.HasError() {
+				t.Fatalf("Read() diags = %v", diags)
+			}
+			if got := d.Get("cidrs").([]interface{}); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("cidrs = %v, want %v", got, tt.want)
+			}
+			if want := tt.first + "-" + tt.last; d.Id() != want {
+				t.Errorf("Id() = %s, want %s", d.Id(), want)
+			}
+		})
+	}
+}
+
+func TestDataSourceDocidrIPRangeToCIDRsRead_Reversed(t *testing.T) {
+	d := schema.TestResourceDataRaw(t, DataSourceDocidrIPRangeToCIDRs().Schema, map[string]interface{}{"first": "10.0.0.10", "last": "10.0.0.1"})
+
+	if diags := dataSourceDocidrIPRangeToCIDRsRead(context.Background(), d, nil); !diags.HasError() {
+		t.Error("Read() expected error for reversed range, got none")
+	}
+}
@@ -0,0 +1,78 @@
+package datasources
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/DO-Solutions/terraform-provider-docidr/docidr/cidr"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// DataSourceDocidrParse returns the docidr_parse data source schema.
+func DataSourceDocidrParse() *schema.Resource {
+	return &schema.Resource{
+		ReadContext: dataSourceDocidrParseRead,
+
+		Schema: map[string]*schema.Schema{
+			"input": {
+				Type:        schema.TypeString,
+				Required:    true,
+				Description: "CIDR or bare IP address to parse. Surrounding whitespace, host bits and uppercase IPv6 hex digits are accepted.",
+			},
+			"cidr": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The canonical network, with host bits cleared.",
+			},
+			"ip": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The address from input, including any host bits.",
+			},
+			"prefix_length": {
+				Type:        schema.TypeInt,
+				Computed:    true,
+				Description: "The prefix length; 32 or 128 for a bare IP address.",
+			},
+			"had_host_bits": {
+				Type:        schema.TypeBool,
+				Computed:    true,
+				Description: "Whether input had bits set beyond the prefix length.",
+			},
+			"family": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The address family: ipv4 or ipv6.",
+			},
+		},
+
+		Description: "Normalizes a CIDR from a variable or remote system and reports what was changed, without making API calls.",
+	}
+}
+
+func dataSourceDocidrParseRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	parsed, err := cidr.Normalize(d.Get("input").(string))
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	if err := d.Set("cidr", parsed.Network.String()); err != nil {
+		return diag.FromErr(err)
+	}
+	if err := d.Set("ip", parsed.IP.String()); err != nil {
+		return diag.FromErr(err)
+	}
+	if err := d.Set("prefix_length", parsed.PrefixLength); err != nil {
+		return diag.FromErr(err)
+	}
+	if err := d.Set("had_host_bits", parsed.HadHostBits); err != nil {
+		return diag.FromErr(err)
+	}
+	if err := d.Set("family", parsed.Family); err != nil {
+		return diag.FromErr(err)
+	}
+	d.SetId(parsed.IP.String() + "/" + strconv.Itoa(parsed.PrefixLength))
+
+	return nil
+}
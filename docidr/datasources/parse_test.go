@@ -0,0 +1,63 @@
+package datasources
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func TestDataSourceDocidrParseRead(t *testing.T) {
+	tests := []struct {
+		input string
+		want  map[string]interface{}
+		id    string
+	}{
+		{
+			input: " 10.0.1.5/16 ",
+			want:  map[string]interface{}{"cidr": "10.0.0.0/16", "ip": "10.0.1.5", "prefix_length": 16, "had_host_bits": true, "family": "ipv4"},
+			id:    "10.0.1.5/16",
+		},
+		{
+			input: "192.168.0.10",
+			want:  map[string]interface{}{"cidr": "192.168.0.10/32", "ip": "192.168.0.10", "prefix_length": 32, "had_host_bits": false, "family": "ipv4"},
+			id:    "192.168.0.10/32",
+		},
+		{
+			input: "FD00:ABCD::/48",
+			want:  map[string]interface{}{"cidr": "fd00:abcd::/48", "ip": "fd00:abcd::", "prefix_length": 48, "had_host_bits": false, "family": "ipv6"},
+			id:    "fd00:abcd::/48",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			d := schema.TestResourceDataRaw(t, DataSourceDocidrParse().Schema, map[string]interface{}{"input": tt.input})
+
+			if diags := dataSourceDocidrParseRead(context.Background(), d, nil); diags.HasError() {
+				t.Fatalf("Read() diags = %v", diags)
+			}
+			for key, want := range tt.want {
+				if got := d.Get(key); got != want {
+					t.Errorf("%s = %v, want %v", key, got, want)
+				}
+			}
+			if got := d.Id(); got != tt.id {
+				t.Errorf("id = %s, want %s", got, tt.id)
+			}
+		})
+	}
+}
+
+func TestDataSourceDocidrParseRead_Invalid(t *testing.T) {
+	d := schema.TestResourceDataRaw(t, DataSourceDocidrParse().Schema, map[string]interface{}{"input": "10.0.0.0/33"})
+
+	diags := dataSourceDocidrParseRead(context.Background(), d, nil)
+	if !diags.HasError() {
+		t.Fatal("Read() diags has no error")
+	}
+	if !strings.Contains(diags[0].Summary, "prefix length 33 is out of range 0-32 for ipv4") {
+		t.Errorf("Read() error = %s", diags[0].Summary)
+	}
+}
@@ -0,0 +1,69 @@
+package datasources
+
+import (
+	"context"
+	"net"
+
+	"github.com/DO-Solutions/terraform-provider-docidr/docidr/cidr"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// DataSourceDocidrSupernet returns the docidr_supernet data source schema.
+func DataSourceDocidrSupernet() *schema.Resource {
+	return &schema.Resource{
+		ReadContext: dataSourceDocidrSupernetRead,
+
+		Schema: map[string]*schema.Schema{
+			"cidrs": {
+				Type:        schema.TypeList,
+				Required:    true,
+				MinItems:    1,
+				Description: "CIDR blocks to summarize.",
+				Elem: &schema.Schema{
+					Type:         schema.TypeString,
+					ValidateFunc: validation.IsCIDR,
+				},
+			},
+			"supernet": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The smallest CIDR block containing every input.",
+			},
+			"wasted_addresses": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "Number of addresses in the supernet not covered by any input, as a decimal string since IPv6 counts can exceed a number.",
+			},
+		},
+
+		Description: "Computes the smallest CIDR block containing a list of CIDRs.",
+	}
+}
+
+func dataSourceDocidrSupernetRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	var networks []*net.IPNet
+	for _, v := range d.Get("cidrs").([]interface{}) {
+		network, err := cidr.ParseCIDR(v.(string))
+		if err != nil {
+			return diag.FromErr(err)
+		}
+		networks = append(networks, network)
+	}
+
+	supernet, err := cidr.CoveringSupernet(networks)
+	if err != nil {
+		return diag.Errorf("Error computing supernet: %s", err)
+	}
+
+	if err := d.Set("supernet", supernet.String()); err != nil {
+		return diag.FromErr(err)
+	}
+	if err := d.Set("wasted_addresses", cidr.UncoveredAddressCount(supernet, networks).String()); err != nil {
+		return diag.FromErr(err)
+	}
+	d.SetId(supernet.String())
+
+	return nil
+}
@@ -0,0 +1,71 @@
+package datasources
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func TestDataSourceDocidrSupernetRead(t *testing.T) {
+	tests := []struct {
+		name       string
+		cidrs      []interface{}
+		want       string
+		wantWasted string
+	}{
+		{
+			name:       "single input",
+			cidrs:      []interface{}{"10.1.0.0/16"},
+			want:       "10.1.0.0/16",
+			wantWasted: "0",
+		},
+		{
+			name:       "pool allocations",
+			cidrs:      []interface{}{"10.0.0.0/16", "10.1.0.0/20", "10.1.16.0/20"},
+			want:       "10.0.0.0/15",
+			wantWasted: "57344",
+		},
+		{
+			name:       "overlapping inputs counted once",
+			cidrs:      []interface{}{"10.0.0.0/17", "10.0.0.0/18", "10.0.128.0/18"},
+			want:       "10.0.0.0/16",
+			wantWasted: "16384",
+		},
+		{
+			name:       "IPv6",
+			cidrs:      []interface{}{"fd00::/64", "fd00:0:0:1::/64"},
+			want:       "fd00::/63",
+			wantWasted: "0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := schema.TestResourceDataRaw(t, DataSourceDocidrSupernet().Schema, map[string]interface{}{"cidrs": tt.cidrs})
+
+			if diags := dataSourceDocidrSupernetRead(context.Background(), d, nil); diags.HasError() {
+				t.Fatalf("Read() diags = %v", diags)
+			}
+			if got := d.Get("supernet").(string); got != tt.want {
+				t.Errorf("supernet = %s, want %s", got, tt.want)
+			}
+			if got := d.Get("wasted_addresses").(string); got != tt.wantWasted {
+				t.Errorf("wasted_addresses = %s, want %s", got, tt.wantWasted)
+			}
+			if d.Id() != tt.want {
+				t.Errorf("id = %s, want %s", d.Id(), tt.want)
+			}
+		})
+	}
+}
+
+func TestDataSourceDocidrSupernetRead_MixedFamilies(t *testing.T) {
+	d := schema.TestResourceDataRaw(t, DataSourceDocidrSupernet().Schema, map[string]interface{}{
+		"cidrs": []interface{}{"10.0.0.0/8", "fd00::/8"},
+	})
+
+	if diags := dataSourceDocidrSupernetRead(context.Background(), d, nil); !diags.HasError() {
+		t.Error("Read() expected error for mixed address families, got none")
+	}
+}
@@ -0,0 +1,83 @@
+package datasources
+
+import (
+	"context"
+	"encoding/hex"
+
+	"github.com/DO-Solutions/terraform-provider-docidr/docidr/cidr"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// maxULASubnetCount limits how many /64s docidr_ula lists.
+const maxULASubnetCount = 256
+
+// DataSourceDocidrULA returns the docidr_ula data source schema.
+func DataSourceDocidrULA() *schema.Resource {
+	return &schema.Resource{
+		ReadContext: dataSourceDocidrULARead,
+
+		Schema: map[string]*schema.Schema{
+			"seed": {
+				Type:         schema.TypeString,
+				Required:     true,
+				ValidateFunc: validation.StringIsNotEmpty,
+				Description:  "Value the Global ID is derived from. The same seed always produces the same prefix.",
+			},
+			"subnet_count": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      0,
+				ValidateFunc: validation.IntBetween(0, maxULASubnetCount),
+				Description:  "Number of /64 subnets of the prefix to return in subnets.",
+			},
+			"cidr": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The unique local /48 prefix.",
+			},
+			"global_id": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The 40-bit Global ID of the prefix, as 10 hexadecimal digits.",
+			},
+			"subnets": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "The first subnet_count /64 subnets of the prefix, in order.",
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+		},
+
+		Description: "Generates a deterministic RFC 4193 unique local IPv6 /48 prefix from a seed, without making API calls.",
+	}
+}
+
+func dataSourceDocidrULARead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	prefix := cidr.ULAPrefix(d.Get("seed").(string))
+
+	subnets, err := cidr.ULASubnets(prefix, d.Get("subnet_count").(int))
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	subnetStrings := make([]string, 0, len(subnets))
+	for _, subnet := range subnets {
+		subnetStrings = append(subnetStrings, subnet.String())
+	}
+
+	if err := d.Set("cidr", prefix.String()); err != nil {
+		return diag.FromErr(err)
+	}
+	if err := d.Set("global_id", hex.EncodeToString(prefix.IP[1:6])); err != nil {
+		return diag.FromErr(err)
+	}
+	if err := d.Set("subnets", subnetStrings); err != nil {
+		return diag.FromErr(err)
+	}
+	d.SetId(prefix.String())
+
+	return nil
+}
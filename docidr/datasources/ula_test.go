@@ -0,0 +1,51 @@
+package datasources
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func TestDataSourceDocidrULARead(t *testing.T) {
+	raw := map[string]interface{}{"seed": "production", "subnet_count": 2}
+	d := schema.TestResourceDataRaw(t, DataSourceDocidrULA().Schema, raw)
+
+	if diags := dataSourceDocidrULARead(context.Background(), d, nil); diags.HasError() {
+		t.Fatalf("Read() diags = %v", diags)
+	}
+	if got := d.Get("cidr").(string); got != "fd08:1ad7:3d3c::/48" {
+		t.Errorf("cidr = %s, want fd08:1ad7:3d3c::/48", got)
+	}
+	if got := d.Get("global_id").(string); got != "081ad73d3c" {
+		t.Errorf("global_id = %s, want 081ad73d3c", got)
+	}
+	if got := d.Id(); got != "fd08:1ad7:3d3c::/48" {
+		t.Errorf("id = %s, want fd08:1ad7:3d3c::/48", got)
+	}
+
+	subnets := d.Get("subnets").([]interface{})
+	want := []string{"fd08:1ad7:3d3c::/64", "fd08:1ad7:3d3c:1::/64"}
+	if len(subnets) != len(want) {
+		t.Fatalf("subnets = %v, want %v", subnets, want)
+	}
+	for i := range want {
+		if subnets[i].(string) != want[i] {
+			t.Errorf("subnets[%d] = %s, want %s", i, subnets[i], want[i])
+		}
+	}
+}
+
+func TestDataSourceDocidrULARead_NoSubnets(t *testing.T) {
+	d := schema.TestResourceDataRaw(t, DataSourceDocidrULA().Schema, map[string]interface{}{"seed": "staging"})
+
+	if diags := dataSourceDocidrULARead(context.Background(), d, nil); diags.HasError() {
+		t.Fatalf("Read() diags = %v", diags)
+	}
+	if got := d.Get("cidr").(string); got != "fd00:bf9e:5c7b::/48" {
+		t.Errorf("cidr = %s, want fd00:bf9e:5c7b::/48", got)
+	}
+	if got := d.Get("subnets").([]interface{}); len(got) != 0 {
+		t.Errorf("subnets = %v, want none", got)
+	}
+}
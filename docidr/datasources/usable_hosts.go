@@ -0,0 +1,67 @@
+package datasources
+
+import (
+	"context"
+
+	"github.com/DO-Solutions/terraform-provider-docidr/docidr/cidr"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// DataSourceDocidrUsableHosts returns the docidr_usable_hosts data source schema.
+func DataSourceDocidrUsableHosts() *schema.Resource {
+	return &schema.Resource{
+		ReadContext: dataSourceDocidrUsableHostsRead,
+
+		Schema: map[string]*schema.Schema{
+			"cidr": {
+				Type:         schema.TypeString,
+				Required:     true,
+				ValidateFunc: validation.IsCIDR,
+				Description:  "IPv4 CIDR block to describe.",
+			},
+			"first_host": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The first address that can be assigned to a host.",
+			},
+			"last_host": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The last address that can be assigned to a host.",
+			},
+			"host_count": {
+				Type:        schema.TypeInt,
+				Computed:    true,
+				Description: "The number of addresses that can be assigned to hosts.",
+			},
+		},
+
+		Description: "Returns the range of host addresses in an IPv4 CIDR block.",
+	}
+}
+
+func dataSourceDocidrUsableHostsRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	network, err := cidr.ParseCIDR(d.Get("cidr").(string))
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	if network.IP.To4() == nil {
+		return diag.Errorf("docidr_usable_hosts only supports IPv4 CIDR blocks, got %s", network)
+	}
+
+	first, last, count := cidr.UsableHosts(network)
+	if err := d.Set("first_host", first.String()); err != nil {
+		return diag.FromErr(err)
+	}
+	if err := d.Set("last_host", last.String()); err != nil {
+		return diag.FromErr(err)
+	}
+	if err := d.Set("host_count", count); err != nil {
+		return diag.FromErr(err)
+	}
+	d.SetId(network.String())
+
+	return nil
+}
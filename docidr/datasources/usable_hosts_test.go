@@ -0,0 +1,48 @@
+package datasources
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func TestDataSourceDocidrUsableHostsRead(t *testing.T) {
+	tests := []struct {
+		cidr      string
+		wantFirst string
+		wantLast  string
+		wantCount int
+	}{
+		{cidr: "10.0.0.5/32", wantFirst: "10.0.0.5", wantLast: "10.0.0.5", wantCount: 1},
+		{cidr: "10.0.0.4/31", wantFirst: "10.0.0.4", wantLast: "10.0.0.5", wantCount: 2},
+		{cidr: "10.0.0.0/24", wantFirst: "10.0.0.1", wantLast: "10.0.0.254", wantCount: 254},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.cidr, func(t *testing.T) {
+			d := schema.TestResourceDataRaw(t, DataSourceDocidrUsableHosts().Schema, map[string]interface{}{"cidr": tt.cidr})
+
+			if diags := dataSourceDocidrUsableHostsRead(context.Background(), d, nil); diags.HasError() {
+				t.Fatalf("Read() diags = %v", diags)
+			}
+			if got := d.Get("first_host").(string); got != tt.wantFirst {
+				t.Errorf("first_host = %s, want %s", got, tt.wantFirst)
+			}
+			if got := d.Get("last_host").(string); got != tt.wantLast {
+				t.Errorf("last_host = %s, want %s", got, tt.wantLast)
+			}
+			if got := d.Get("host_count").(int); got != tt.wantCount {
+				t.Errorf("host_count = %d, want %d", got, tt.wantCount)
+			}
+		})
+	}
+}
+
+func TestDataSourceDocidrUsableHostsRead_IPv6(t *testing.T) {
+	d := schema.TestResourceDataRaw(t, DataSourceDocidrUsableHosts().Schema, map[string]interface{}{"cidr": "fd00::/64"})
+
+	if diags := dataSourceDocidrUsableHostsRead(context.Background(), d, nil); !diags.HasError() {
+		t.Error("Read() expected error for IPv6 block, got none")
+	}
+}
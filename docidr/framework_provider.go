@@ -0,0 +1,177 @@
+package docidr
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/DO-Solutions/terraform-provider-docidr/docidr/functions"
+	"github.com/DO-Solutions/terraform-provider-docidr/docidr/pool"
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/ephemeral"
+	"github.com/hashicorp/terraform-plugin-framework/function"
+	"github.com/hashicorp/terraform-plugin-framework/provider"
+	fwschema "github.com/hashicorp/terraform-plugin-framework/provider/schema"
+	"github.com/hashicorp/terraform-plugin-framework/providerserver"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-go/tfprotov6"
+	"github.com/hashicorp/terraform-plugin-mux/tf5to6server"
+	"github.com/hashicorp/terraform-plugin-mux/tf6muxserver"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// ProviderServerFactory returns the protocol 6 server of the provider: the
+// SDKv2 provider, which serves the resources and data sources, muxed with a
+// plugin-framework provider for the features SDKv2 doesn't support: provider
+// functions and ephemeral resources.
+func ProviderServerFactory(ctx context.Context) (func() tfprotov6.ProviderServer, error) {
+	sdk := Provider()
+
+	upgraded, err := tf5to6server.UpgradeServer(ctx, sdk.GRPCProvider)
+	if err != nil {
+		return nil, fmt.Errorf("error upgrading the SDKv2 provider to protocol 6: %w", err)
+	}
+
+	mux, err := tf6muxserver.NewMuxServer(ctx,
+		func() tfprotov6.ProviderServer { return upgraded },
+		providerserver.NewProtocol6(newFrameworkProvider(sdk)),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error muxing the provider servers: %w", err)
+	}
+	return mux.ProviderServer, nil
+}
+
+// frameworkProvider is the plugin-framework half of the provider. Muxed
+// providers must have the same provider schema, so it serves the schema of
+// sdk.
+type frameworkProvider struct {
+	sdk *schema.Provider
+}
+
+var (
+	_ provider.Provider                       = (*frameworkProvider)(nil)
+	_ provider.ProviderWithFunctions          = (*frameworkProvider)(nil)
+	_ provider.ProviderWithEphemeralResources = (*frameworkProvider)(nil)
+)
+
+// newFrameworkProvider returns the framework half of the provider whose SDKv2
+// half is sdk.
+func newFrameworkProvider(sdk *schema.Provider) provider.Provider {
+	return &frameworkProvider{sdk: sdk}
+}
+
+func (p *frameworkProvider) Metadata(_ context.Context, _ provider.MetadataRequest, resp *provider.MetadataResponse) {
+	resp.TypeName = "docidr"
+	resp.Version = Version
+}
+
+func (p *frameworkProvider) Schema(_ context.Context, _ provider.SchemaRequest, resp *provider.SchemaResponse) {
+	resp.Schema = frameworkProviderSchema(p.sdk.Schema)
+}
+
+// Configure hands the ephemeral resources the configuration of the SDKv2
+// half. The mux configures the halves in order, so sdk is configured by now.
+func (p *frameworkProvider) Configure(_ context.Context, _ provider.ConfigureRequest, resp *provider.ConfigureResponse) {
+	resp.EphemeralResourceData = p.sdk.Meta()
+}
+
+func (p *frameworkProvider) Resources(context.Context) []func() resource.Resource {
+	return nil
+}
+
+func (p *frameworkProvider) DataSources(context.Context) []func() datasource.DataSource {
+	return nil
+}
+
+func (p *frameworkProvider) EphemeralResources(context.Context) []func() ephemeral.EphemeralResource {
+	return []func() ephemeral.EphemeralResource{
+		pool.NewScanEphemeralResource,
+	}
+}
+
+func (p *frameworkProvider) Functions(context.Context) []func() function.Function {
+	return []func() function.Function{
+		functions.NewNextFreeFunction,
+	}
+}
+
+// frameworkProviderSchema converts the SDKv2 provider schema to the same
+// schema in the framework's types, so both halves of the mux agree on it.
+func frameworkProviderSchema(sdk map[string]*schema.Schema) fwschema.Schema {
+	attributes, blocks := frameworkAttributes(sdk)
+	return fwschema.Schema{Attributes: attributes, Blocks: blocks}
+}
+
+// frameworkAttributes converts SDKv2 schemas to framework attributes, and
+// lists of resources to nested blocks, as SDKv2 presents them to Terraform.
+func frameworkAttributes(sdk map[string]*schema.Schema) (map[string]fwschema.Attribute, map[string]fwschema.Block) {
+	attributes := make(map[string]fwschema.Attribute)
+	blocks := make(map[string]fwschema.Block)
+	for name, s := range sdk {
+		if r, ok := s.Elem.(*schema.Resource); ok {
+			nestedAttributes, nestedBlocks := frameworkAttributes(r.Schema)
+			blocks[name] = fwschema.ListNestedBlock{
+				Description:        s.Description,
+				DeprecationMessage: s.Deprecated,
+				NestedObject: fwschema.NestedBlockObject{
+					Attributes: nestedAttributes,
+					Blocks:     nestedBlocks,
+				},
+			}
+			continue
+		}
+		attributes[name] = frameworkAttribute(s)
+	}
+	return attributes, blocks
+}
+
+// frameworkAttribute converts an SDKv2 schema of a primitive or a collection
+// of primitives to a framework attribute. As in SDKv2, a required attribute
+// whose DefaultFunc gives a value is optional.
+func frameworkAttribute(s *schema.Schema) fwschema.Attribute {
+	required := s.Required
+	if required && s.DefaultFunc != nil {
+		if v, err := s.DefaultFunc(); err != nil || v != nil {
+			required = false
+		}
+	}
+	optional := !required
+
+	switch s.Type {
+	case schema.TypeString:
+		return fwschema.StringAttribute{Required: required, Optional: optional, Sensitive: s.Sensitive, Description: s.Description, DeprecationMessage: s.Deprecated}
+	case schema.TypeBool:
+		return fwschema.BoolAttribute{Required: required, Optional: optional, Sensitive: s.Sensitive, Description: s.Description, DeprecationMessage: s.Deprecated}
+	case schema.TypeInt:
+		return fwschema.Int64Attribute{Required: required, Optional: optional, Sensitive: s.Sensitive, Description: s.Description, DeprecationMessage: s.Deprecated}
+	case schema.TypeFloat:
+		return fwschema.Float64Attribute{Required: required, Optional: optional, Sensitive: s.Sensitive, Description: s.Description, DeprecationMessage: s.Deprecated}
+	case schema.TypeList:
+		return fwschema.ListAttribute{ElementType: frameworkElementType(s), Required: required, Optional: optional, Sensitive: s.Sensitive, Description: s.Description, DeprecationMessage: s.Deprecated}
+	case schema.TypeSet:
+		return fwschema.SetAttribute{ElementType: frameworkElementType(s), Required: required, Optional: optional, Sensitive: s.Sensitive, Description: s.Description, DeprecationMessage: s.Deprecated}
+	case schema.TypeMap:
+		return fwschema.MapAttribute{ElementType: frameworkElementType(s), Required: required, Optional: optional, Sensitive: s.Sensitive, Description: s.Description, DeprecationMessage: s.Deprecated}
+	}
+	panic(fmt.Sprintf("unsupported provider schema type %s", s.Type))
+}
+
+// frameworkElementType returns the element type of an SDKv2 collection.
+// SDKv2 collections without an Elem hold strings.
+func frameworkElementType(s *schema.Schema) attr.Type {
+	elem, ok := s.Elem.(*schema.Schema)
+	if !ok {
+		return types.StringType
+	}
+	switch elem.Type {
+	case schema.TypeBool:
+		return types.BoolType
+	case schema.TypeInt:
+		return types.Int64Type
+	case schema.TypeFloat:
+		return types.Float64Type
+	}
+	return types.StringType
+}
@@ -0,0 +1,99 @@
+package docidr
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-go/tfprotov6"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+)
+
+// newProviderServer returns the muxed protocol 6 server main serves.
+func newProviderServer(t *testing.T) tfprotov6.ProviderServer {
+	t.Helper()
+
+	factory, err := ProviderServerFactory(context.Background())
+	if err != nil {
+		t.Fatalf("ProviderServerFactory() error = %v", err)
+	}
+	return factory()
+}
+
+func TestProviderServer_Schema(t *testing.T) {
+	server := newProviderServer(t)
+
+	// The mux fails when the two halves disagree on the provider schema
+	resp, err := server.GetProviderSchema(context.Background(), &tfprotov6.GetProviderSchemaRequest{})
+	if err != nil {
+		t.Fatalf("GetProviderSchema() error = %v", err)
+	}
+	for _, d := range resp.Diagnostics {
+		if d.Severity == tfprotov6.DiagnosticSeverityError {
+			t.Errorf("GetProviderSchema() error: %s: %s", d.Summary, d.Detail)
+		}
+	}
+
+	if _, ok := resp.ResourceSchemas["docidr_pool"]; !ok {
+		t.Error("GetProviderSchema() is missing the docidr_pool resource")
+	}
+	if _, ok := resp.EphemeralResourceSchemas["docidr_scan"]; !ok {
+		t.Error("GetProviderSchema() is missing the docidr_scan ephemeral resource")
+	}
+	if _, ok := resp.Functions["next_free"]; !ok {
+		t.Error("GetProviderSchema() is missing the next_free function")
+	}
+}
+
+func TestProviderServer_NextFree(t *testing.T) {
+	server := newProviderServer(t)
+
+	call := func(base string, prefixLength int, exclusions ...string) *tfprotov6.CallFunctionResponse {
+		t.Helper()
+
+		elements := make([]tftypes.Value, 0, len(exclusions))
+		for _, e := range exclusions {
+			elements = append(elements, tftypes.NewValue(tftypes.String, e))
+		}
+		listType := tftypes.List{ElementType: tftypes.String}
+		args := []tftypes.Value{
+			tftypes.NewValue(tftypes.String, base),
+			tftypes.NewValue(tftypes.Number, prefixLength),
+			tftypes.NewValue(listType, elements),
+		}
+		var arguments []*tfprotov6.DynamicValue
+		for _, arg := range args {
+			value, err := tfprotov6.NewDynamicValue(arg.Type(), arg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			arguments = append(arguments, &value)
+		}
+
+		resp, err := server.CallFunction(context.Background(), &tfprotov6.CallFunctionRequest{Name: "next_free", Arguments: arguments})
+		if err != nil {
+			t.Fatalf("CallFunction() error = %v", err)
+		}
+		return resp
+	}
+
+	resp := call("10.0.0.0/16", 24, "10.0.0.0/24")
+	if resp.Error != nil {
+		t.Fatalf("next_free() error = %s", resp.Error.Text)
+	}
+	value, err := resp.Result.Unmarshal(tftypes.String)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got string
+	if err := value.As(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got != "10.0.1.0/24" {
+		t.Errorf("next_free() = %s, want 10.0.1.0/24", got)
+	}
+
+	// Exhaustion is a function error
+	if resp := call("10.0.0.0/24", 24, "10.0.0.0/24"); resp.Error == nil {
+		t.Error("next_free() of a full base expected error, got none")
+	}
+}
@@ -0,0 +1,108 @@
+// Package functions implements the provider functions of the docidr provider.
+// They are pure calculations, made without calling any API.
+package functions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/DO-Solutions/terraform-provider-docidr/docidr/cidr"
+	"github.com/hashicorp/terraform-plugin-framework/function"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// Positions of the next_free parameters, for argument errors.
+const (
+	nextFreeBaseArg = iota
+	nextFreePrefixLengthArg
+	nextFreeExclusionsArg
+)
+
+// nextFreeFunction is provider::docidr::next_free.
+type nextFreeFunction struct{}
+
+var _ function.Function = nextFreeFunction{}
+
+// NewNextFreeFunction returns the next_free provider function.
+func NewNextFreeFunction() function.Function {
+	return nextFreeFunction{}
+}
+
+func (nextFreeFunction) Metadata(_ context.Context, _ function.MetadataRequest, resp *function.MetadataResponse) {
+	resp.Name = "next_free"
+}
+
+func (nextFreeFunction) Definition(_ context.Context, _ function.DefinitionRequest, resp *function.DefinitionResponse) {
+	resp.Definition = function.Definition{
+		Summary: "Returns the first free block of a base CIDR.",
+		Description: "Returns the first block of prefix_length in base that doesn't overlap any of exclusions, " +
+			"picked the same way docidr_pool allocates with first_fit placement. Fails when there is no such block.",
+		Parameters: []function.Parameter{
+			function.StringParameter{
+				Name:        "base",
+				Description: "The IPv4 CIDR to find a block in, such as 10.0.0.0/16.",
+			},
+			function.Int64Parameter{
+				Name:        "prefix_length",
+				Description: "The prefix length of the block, such as 24. It must be between the prefix length of base and 32.",
+			},
+			function.ListParameter{
+				Name:        "exclusions",
+				ElementType: types.StringType,
+				Description: "CIDRs the block must not overlap. May be empty.",
+			},
+		},
+		Return: function.StringReturn{},
+	}
+}
+
+func (nextFreeFunction) Run(ctx context.Context, req function.RunRequest, resp *function.RunResponse) {
+	var base string
+	var prefixLength int64
+	var rawExclusions []string
+	resp.Error = req.Arguments.Get(ctx, &base, &prefixLength, &rawExclusions)
+	if resp.Error != nil {
+		return
+	}
+
+	// The allocator only places IPv4 blocks
+	baseNetwork, err := cidr.ParseCIDR(base)
+	if err != nil {
+		resp.Error = function.NewArgumentFuncError(nextFreeBaseArg, err.Error())
+		return
+	}
+	if baseNetwork.IP.To4() == nil {
+		resp.Error = function.NewArgumentFuncError(nextFreeBaseArg, fmt.Sprintf("base %s isn't an IPv4 CIDR", base))
+		return
+	}
+	if prefixLength > 32 {
+		resp.Error = function.NewArgumentFuncError(nextFreePrefixLengthArg, fmt.Sprintf("prefix length /%d is longer than /32", prefixLength))
+		return
+	}
+
+	exclusions := make([]*net.IPNet, 0, len(rawExclusions))
+	for _, raw := range rawExclusions {
+		network, err := cidr.ParseCIDR(raw)
+		if err != nil {
+			resp.Error = function.NewArgumentFuncError(nextFreeExclusionsArg, err.Error())
+			return
+		}
+		exclusions = append(exclusions, network)
+	}
+
+	next, err := cidr.NextFree(base, int(prefixLength), exclusions)
+	var prefixErr *cidr.PrefixLengthError
+	switch {
+	case errors.As(err, &prefixErr):
+		resp.Error = function.NewArgumentFuncError(nextFreePrefixLengthArg,
+			fmt.Sprintf("prefix length /%d is shorter than the /%d of base", prefixErr.PrefixLength, prefixErr.BasePrefixLength))
+		return
+	case err != nil:
+		resp.Error = function.NewFuncError(err.Error())
+		return
+	}
+
+	resp.Error = resp.Result.Set(ctx, next.String())
+}
@@ -0,0 +1,130 @@
+package functions
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/DO-Solutions/terraform-provider-docidr/docidr/cidr"
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/function"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// runNextFree calls next_free with the given arguments.
+func runNextFree(t *testing.T, base string, prefixLength int64, exclusions []string) (string, *function.FuncError) {
+	t.Helper()
+
+	elements := make([]attr.Value, 0, len(exclusions))
+	for _, e := range exclusions {
+		elements = append(elements, types.StringValue(e))
+	}
+	req := function.RunRequest{
+		Arguments: function.NewArgumentsData([]attr.Value{
+			types.StringValue(base),
+			types.Int64Value(prefixLength),
+			types.ListValueMust(types.StringType, elements),
+		}),
+	}
+	resp := &function.RunResponse{Result: function.NewResultData(types.StringUnknown())}
+
+	NewNextFreeFunction().Run(context.Background(), req, resp)
+	if resp.Error != nil {
+		return "", resp.Error
+	}
+	return resp.Result.Value().(types.String).ValueString(), nil
+}
+
+func TestNextFreeFunction_MatchesAllocate(t *testing.T) {
+	tests := []struct {
+		name       string
+		base       string
+		requests   []cidr.AllocationRequest
+		exclusions []string
+	}{
+		{
+			name:     "empty base",
+			base:     "10.0.0.0/8",
+			requests: []cidr.AllocationRequest{{Name: "vpc", PrefixLength: 16}, {Name: "k8s", PrefixLength: 20}, {Name: "lb", PrefixLength: 24}},
+		},
+		{
+			name:       "with exclusions",
+			base:       "10.0.0.0/16",
+			requests:   []cidr.AllocationRequest{{Name: "a", PrefixLength: 24}, {Name: "b", PrefixLength: 22}, {Name: "c", PrefixLength: 24}},
+			exclusions: []string{"10.0.0.0/24", "10.0.2.0/23", "10.0.4.0/22"},
+		},
+		{
+			name:       "mixed sizes around a hole",
+			base:       "192.168.0.0/16",
+			requests:   []cidr.AllocationRequest{{Name: "small", PrefixLength: 28}, {Name: "big", PrefixLength: 20}, {Name: "small2", PrefixLength: 28}},
+			exclusions: []string{"192.168.0.16/28"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			networks, err := cidr.ParseCIDRs(tt.exclusions)
+			if err != nil {
+				t.Fatal(err)
+			}
+			allocator, err := cidr.NewAllocator(tt.base)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := allocator.Allocate(tt.requests, networks)
+			if err != nil {
+				t.Fatalf("Allocate() error = %v", err)
+			}
+
+			// Calling next_free once per request, excluding the blocks
+			// already returned, places them as Allocate does
+			exclusions := append([]string{}, tt.exclusions...)
+			for _, req := range tt.requests {
+				got, funcErr := runNextFree(t, tt.base, int64(req.PrefixLength), exclusions)
+				if funcErr != nil {
+					t.Fatalf("next_free(%s, %d) error = %v", tt.base, req.PrefixLength, funcErr)
+				}
+				if got != want[req.Name] {
+					t.Errorf("next_free() for %q = %s, Allocate() = %s", req.Name, got, want[req.Name])
+				}
+				exclusions = append(exclusions, got)
+			}
+		})
+	}
+}
+
+func TestNextFreeFunction_Errors(t *testing.T) {
+	tests := []struct {
+		name         string
+		base         string
+		prefixLength int64
+		exclusions   []string
+		wantArgument int64 // -1 for an error that isn't about one argument
+		wantErr      string
+	}{
+		{name: "invalid base", base: "not-a-cidr", prefixLength: 24, wantArgument: 0, wantErr: "invalid CIDR"},
+		{name: "IPv6 base", base: "fd00::/48", prefixLength: 64, wantArgument: 0, wantErr: "IPv4"},
+		{name: "prefix shorter than base", base: "10.0.0.0/16", prefixLength: 8, wantArgument: 1, wantErr: "shorter"},
+		{name: "prefix longer than /32", base: "10.0.0.0/16", prefixLength: 33, wantArgument: 1, wantErr: "/32"},
+		{name: "invalid exclusion", base: "10.0.0.0/16", prefixLength: 24, exclusions: []string{"10.0.0.0/33"}, wantArgument: 2, wantErr: "invalid CIDR"},
+		{name: "exhausted", base: "10.0.0.0/24", prefixLength: 25, exclusions: []string{"10.0.0.0/25", "10.0.0.128/25"}, wantArgument: -1, wantErr: "no available space"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, funcErr := runNextFree(t, tt.base, tt.prefixLength, tt.exclusions)
+			if funcErr == nil {
+				t.Fatal("next_free() expected error, got none")
+			}
+			if !strings.Contains(funcErr.Text, tt.wantErr) {
+				t.Errorf("next_free() error = %q, want it to contain %q", funcErr.Text, tt.wantErr)
+			}
+			switch {
+			case tt.wantArgument < 0 && funcErr.FunctionArgument != nil:
+				t.Errorf("next_free() error is about argument %d, want a function error", *funcErr.FunctionArgument)
+			case tt.wantArgument >= 0 && (funcErr.FunctionArgument == nil || *funcErr.FunctionArgument != tt.wantArgument):
+				t.Errorf("next_free() error argument = %v, want %d", funcErr.FunctionArgument, tt.wantArgument)
+			}
+		})
+	}
+}
@@ -0,0 +1,318 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"time"
+
+	"github.com/DO-Solutions/terraform-provider-docidr/docidr/cidr"
+	"github.com/DO-Solutions/terraform-provider-docidr/docidr/config"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+)
+
+// poolAllocation is the outcome of allocating a pool's CIDRs.
+type poolAllocation struct {
+	BaseCIDR           string
+	Requests           []cidr.AllocationRequest
+	Results            map[string]string
+	ExclusionsFileHash string
+	Report             *scanReport
+	PoolExclusions     []*net.IPNet
+	Allocator          *cidr.Allocator
+	// ExternalIDs are the IDs of the allocations in the external IPAM system
+	// they came from, if any.
+	ExternalIDs map[string]string
+}
+
+// allocatePool resolves the base CIDR, gathers every exclusion, scans the
+// DigitalOcean account and allocates the requested blocks. get reads the pool
+// configuration, so the same allocation runs at apply time from ResourceData
+// and at plan time from a ResourceDiff.
+func allocatePool(ctx context.Context, get func(string) interface{}, combined *config.CombinedConfig) (*poolAllocation, diag.Diagnostics) {
+	client := combined.GodoClient()
+
+	baseCIDR, diags := resolvePoolBaseCIDR(ctx, get, combined)
+	if diags.HasError() {
+		return nil, diags
+	}
+
+	// A migrating pool allocates from the base it is migrating to
+	sourceBaseCIDR := baseCIDR
+	if target := get("migrate_to_base").(string); target != "" {
+		baseCIDR = target
+	}
+
+	allocationBlocks, err := resolveAllocationSizes(get("allocation").([]interface{}), combined.SizePresets(), baseCIDR)
+	if err != nil {
+		return nil, diag.FromErr(err)
+	}
+	allocationRequests := expandAllocations(allocationBlocks, get("auto_generate_names").(bool))
+	diags = append(diags, oversizedAllocationWarnings(baseCIDR, allocationRequests, get("oversize_warning_threshold").(float64))...)
+
+	// Collect user-specified exclusions
+	userExclusions, err := expandExclusions(get("exclude").([]interface{}))
+	if err != nil {
+		return nil, diag.FromErr(err)
+	}
+
+	// Collect exclusions from wildcard patterns
+	patternExclusions, err := expandExcludePatterns(get("exclude_patterns").([]interface{}))
+	if err != nil {
+		return nil, diag.FromErr(err)
+	}
+	userExclusions = append(userExclusions, newExclusions(patternExclusions, exclusionSourcePattern)...)
+
+	// Collect ranges managed elsewhere in the configuration
+	selfManaged, err := cidr.ParseCIDRs(expandStringList(get("exclude_self_managed_ranges").([]interface{})))
+	if err != nil {
+		return nil, diag.FromErr(err)
+	}
+	userExclusions = append(userExclusions, newExclusions(selfManaged, exclusionSourceSelfManaged)...)
+
+	// Collect exclusions from the exclusions file
+	var exclusionsFileHash string
+	if path := get("exclusions_file").(string); path != "" {
+		fileExclusions, hash, err := loadExclusionsFile(path)
+		if err != nil {
+			return nil, diag.FromErr(err)
+		}
+		log.Printf("[DEBUG] Loaded %d exclusions from %s", len(fileExclusions), path)
+		userExclusions = append(userExclusions, newExclusions(fileExclusions, exclusionSourceFile)...)
+		exclusionsFileHash = hash
+	}
+
+	// Collect exclusions from remote exclusion lists configured on the provider
+	remoteExclusions, remoteDiags := collectRemoteExclusions(ctx, combined)
+	diags = append(diags, remoteDiags...)
+	if diags.HasError() {
+		return nil, diags
+	}
+	userExclusions = append(userExclusions, newExclusions(remoteExclusions, exclusionSourceURL)...)
+
+	// Collect the prefixes of the provider's IPAM source
+	ipamExclusions, ipamDiags := collectIPAMSourceExclusions(ctx, combined)
+	diags = append(diags, ipamDiags...)
+	if diags.HasError() {
+		return nil, diags
+	}
+	userExclusions = append(userExclusions, ipamExclusions...)
+
+	// Collect App Platform internal network ranges
+	if get("include_app_platform_ranges").(bool) {
+		appPlatformExclusions, err := collectAppPlatformCIDRs(ctx, combined)
+		if err != nil {
+			return nil, append(diags, diag.Errorf("Error collecting App Platform ranges: %s", err)...)
+		}
+		userExclusions = append(userExclusions, newExclusions(appPlatformExclusions, exclusionSourceAppPlatform)...)
+	}
+
+	// Collect exclusions from the DOCIDR_EXCLUDE environment variable
+	envExclusions := combined.EnvExclusions()
+	for _, network := range envExclusions {
+		log.Printf("[DEBUG] Excluding %s from DOCIDR_EXCLUDE environment variable", network.String())
+	}
+	userExclusions = append(userExclusions, newExclusions(envExclusions, exclusionSourceEnv)...)
+
+	// Collect the allocations of other pools, both those seen during plan and
+	// those created since
+	planned, err := cidr.ParseCIDRs(expandStringList(get("overlapping_pool_cidrs").([]interface{})))
+	if err != nil {
+		return nil, append(diags, diag.FromErr(err)...)
+	}
+	poolExclusions := cidr.Merge(append(planned, overlappingPoolAllocations(combined, get("exclude_overlapping_pools").(bool))...))
+	for _, network := range poolExclusions {
+		log.Printf("[DEBUG] Excluding %s allocated by another docidr_pool", network.String())
+	}
+	userExclusions = append(userExclusions, newExclusions(poolExclusions, exclusionSourcePool)...)
+
+	// Create allocator
+	allocator, err := cidr.NewAllocator(baseCIDR)
+	if err != nil {
+		return nil, append(diags, diag.Errorf("Error creating CIDR allocator: %s", err)...)
+	}
+	if get("placement").(string) == cidr.PlacementRandom {
+		// Seeded from the pool's ID, so retries and the plan-time
+		// allocation pick the same blocks, and the salt, so identical
+		// configurations can still differ
+		seed := poolID(get, baseCIDR, allocationRequests, exclusionsFileHash)
+		if salt := get("placement_salt").(string); salt != "" {
+			seed += ":" + salt
+		}
+		allocator = allocator.WithRandomPlacement([]byte(seed))
+	}
+
+	// Keep the CIDRs of the pool being replaced. They are likely in use by
+	// the resources built from them, so only the user's own exclusions can
+	// move them.
+	// A migrating pool instead moves them to the same offsets in the new
+	// base where it can.
+	var reservations []cidr.ReservationRequest
+	var moved []cidr.MovedAllocation
+	var migrated []migrationMove
+	if get("migrate_to_base").(string) != "" {
+		reservations, migrated, err = migrationReservations(get("previous_allocations").(map[string]interface{}), sourceBaseCIDR, allocationRequests, allocator, exclusionNetworks(userExclusions))
+		if err != nil {
+			return nil, append(diags, diag.FromErr(err)...)
+		}
+	} else if get("stable_allocation").(bool) {
+		reservations, moved = expandReservations(get("previous_allocations").(map[string]interface{}), allocationRequests, allocator, exclusionNetworks(userExclusions))
+	}
+
+	sortedRequests := sortAllocationRequests(allocationRequests, get("sort_strategy").(string))
+	maxExpansions := 0
+	if get("base_cidr_expansion").(bool) {
+		maxExpansions = maxBaseCIDRExpansions
+	}
+
+	// Collect existing CIDRs from DigitalOcean account and perform the
+	// allocations, repeating both if either fails and the pool allows it
+	nameFilter, err := expandScanNameFilter(get)
+	if err != nil {
+		return nil, append(diags, diag.FromErr(err)...)
+	}
+	scanOpts := expandScanOptions(get)
+	scanOpts.PageSize = combined.APIPageSize()
+	retryDelay := time.Duration(get("scan_retry_delay").(float64) * float64(time.Second))
+	retryBudget := get("allocation_retry_budget").(int)
+
+	// Without the API, only the user's exclusions are avoided. Nothing
+	// changes between attempts, so failed allocations aren't retried.
+	skipAPIQuery := get("skip_api_query").(bool)
+	if skipAPIQuery {
+		log.Printf("[DEBUG] Skipping the existing CIDR scan: skip_api_query is set")
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Warning,
+			Summary:  "Existing DigitalOcean resources not considered",
+			Detail: "skip_api_query is set, so the DigitalOcean account was not scanned and allocations may overlap " +
+				"existing VPCs, Kubernetes clusters and other resources. Only the configured exclusions were avoided.",
+		})
+		retryBudget = 0
+	}
+	var existingCIDRs []*net.IPNet
+	var exclusions []exclusion
+	var skippedSources []string
+	var nameFiltered int
+	var results map[string]string
+	cycleDiags := retryAllocation(ctx, retryBudget, func() diag.Diagnostics {
+		var existing []existingCIDR
+		var skipped []string
+		var attemptDiags diag.Diagnostics
+		if !skipAPIQuery {
+			existing, skipped, attemptDiags = consistentScan(ctx, get("scan_retries").(int), retryDelay, func() ([]existingCIDR, []string, diag.Diagnostics) {
+				return collectExistingCIDRs(ctx, client, scanOpts)
+			})
+			if attemptDiags.HasError() {
+				return attemptDiags
+			}
+		}
+
+		existing, nameFiltered = nameFilter.apply(existing)
+		existing = dedupeExistingCIDRs(existing)
+		log.Printf("[DEBUG] Found %d existing CIDRs in DigitalOcean account", len(existing))
+		for _, e := range existing {
+			log.Printf("[DEBUG]   - %s", e.String())
+			for _, child := range e.Children {
+				log.Printf("[DEBUG]       - %s", child.String())
+			}
+		}
+		existingCIDRs = existingNetworks(existing)
+		skippedSources = skipped
+
+		// Combine exclusions
+		exclusions = append(existingExclusions(existing), userExclusions...)
+		allExclusions := append(append([]*net.IPNet{}, existingCIDRs...), exclusionNetworks(userExclusions)...)
+
+		expanded, allocated, expansionDiags, err := allocateExpanding(allocator, reservations, sortedRequests, allExclusions, maxExpansions)
+		attemptDiags = append(attemptDiags, expansionDiags...)
+		if err != nil {
+			err = describeAllocationError(err, exclusions, allocationRequests)
+			return append(attemptDiags, allocationErrorDiagnostics(fmt.Sprintf("Error allocating CIDRs: %s", err), err, allocationRequests)...)
+		}
+		allocator, results = expanded, allocated
+		return attemptDiags
+	})
+	diags = append(diags, cycleDiags...)
+	if diags.HasError() {
+		return nil, diags
+	}
+	if err := validateAllocationVisibility(results, allocationRequests); err != nil {
+		return nil, append(diags, diag.FromErr(err)...)
+	}
+	diags = append(diags, movedAllocationWarnings(moved, results)...)
+	diags = append(diags, migrationWarnings(migrated, results)...)
+
+	return &poolAllocation{
+		BaseCIDR:           allocator.BaseCIDR().String(),
+		Requests:           allocationRequests,
+		Results:            results,
+		ExclusionsFileHash: exclusionsFileHash,
+		Report: &scanReport{
+			ExistingCIDRs:  existingCIDRs,
+			EnvExclusions:  envExclusions,
+			Exclusions:     exclusions,
+			SkippedSources: skippedSources,
+			NameFiltered:   nameFiltered,
+		},
+		PoolExclusions: poolExclusions,
+		Allocator:      allocator,
+	}, diags
+}
+
+// resolvePoolBaseCIDR returns the base CIDR the pool allocates from before
+// any migration or expansion: the address_space preset, the base detected
+// from the region, base_cidr or the default, in that order.
+func resolvePoolBaseCIDR(ctx context.Context, get func(string) interface{}, combined *config.CombinedConfig) (string, diag.Diagnostics) {
+	baseCIDR := get("base_cidr").(string)
+	if get("detect_base_cidr_from_region").(bool) {
+		region := get("region").(string)
+		detected, err := detectRegionBaseCIDR(ctx, combined.GodoClient(), region, combined.APIPageSize())
+		if err != nil {
+			return "", diag.Errorf("Error detecting base CIDR for region %s: %s", region, err)
+		}
+		if detected == "" {
+			log.Printf("[DEBUG] No private VPCs found in region %s, using default base CIDR", region)
+		}
+		baseCIDR = detected
+	}
+	if space := get("address_space").(string); space != "" {
+		baseCIDR = addressSpaces[space]
+	}
+	if baseCIDR == "" {
+		baseCIDR = defaultBaseCIDR
+	}
+	return baseCIDR, nil
+}
+
+// maxBaseCIDRExpansions is how many times base_cidr_expansion may widen the
+// base CIDR, so a /24 grows to at most a /21.
+const maxBaseCIDRExpansions = 3
+
+// allocateExpanding makes the reservations and allocates requests from
+// allocator's base CIDR. While the base is out of space it widens the base by
+// one bit, up to maxExpansions times, and retries. It returns the allocator
+// the results came from along with a warning for each expansion.
+func allocateExpanding(allocator *cidr.Allocator, reservations []cidr.ReservationRequest, requests []cidr.AllocationRequest, exclusions []*net.IPNet, maxExpansions int) (*cidr.Allocator, map[string]string, diag.Diagnostics, error) {
+	var diags diag.Diagnostics
+	for expansions := 0; ; expansions++ {
+		results, err := allocator.AllocateWithReservations(reservations, requests, exclusions)
+		if err == nil || !errors.Is(err, cidr.ErrNoSpace) || expansions == maxExpansions {
+			return allocator, results, diags, err
+		}
+
+		expanded, expandErr := allocator.Expanded()
+		if expandErr != nil {
+			return allocator, nil, diags, err
+		}
+		log.Printf("[DEBUG] Base CIDR %s is exhausted, expanding to %s", allocator.BaseCIDR().String(), expanded.BaseCIDR().String())
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Warning,
+			Summary:  "Base CIDR expanded",
+			Detail: fmt.Sprintf("Base CIDR %s has no room for every allocation, so it was widened to %s. "+
+				"Set a larger base_cidr to silence this warning.", allocator.BaseCIDR().String(), expanded.BaseCIDR().String()),
+		})
+		allocator = expanded
+	}
+}
@@ -0,0 +1,95 @@
+package pool
+
+import (
+	"errors"
+
+	"github.com/DO-Solutions/terraform-provider-docidr/docidr/cidr"
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+)
+
+// allocationPath returns the path of attribute in the allocation block at
+// index, such as allocation.3.prefix_length.
+func allocationPath(index int, attribute string) cty.Path {
+	return cty.GetAttrPath("allocation").IndexInt(index).GetAttr(attribute)
+}
+
+// allocationErrorPath returns the path of the allocation block attribute err
+// is about, so Terraform can point at the block, or nil if err isn't about a
+// single allocation. requests are in the order of the allocation blocks.
+func allocationErrorPath(err error, requests []cidr.AllocationRequest) cty.Path {
+	var duplicateErr *DuplicateNameError
+	var mismatchErr *NameMismatchError
+	var boundsErr *PrefixLengthBoundsError
+	var presetErr *UnknownSizePresetError
+	var blockErr *AllocationBlockError
+	switch {
+	case errors.As(err, &duplicateErr):
+		return allocationPath(duplicateErr.Index, "name")
+	case errors.As(err, &mismatchErr):
+		return allocationPath(mismatchErr.Index, "name")
+	case errors.As(err, &boundsErr):
+		return allocationPath(boundsErr.Index, "prefix_length")
+	case errors.As(err, &presetErr):
+		return allocationPath(presetErr.Index, "size")
+	case errors.As(err, &blockErr):
+		return allocationPath(blockErr.Index, blockErr.Attribute)
+	}
+
+	var name, attribute string
+	var allocErr *cidr.AllocationError
+	var prefixErr *cidr.PrefixLengthError
+	var reservationErr *cidr.ReservationError
+	switch {
+	case errors.As(err, &allocErr):
+		name, attribute = allocErr.Name, "prefix_length"
+	case errors.As(err, &prefixErr):
+		name, attribute = prefixErr.Name, "prefix_length"
+	case errors.As(err, &reservationErr):
+		name, attribute = reservationErr.Name, "name"
+	default:
+		return nil
+	}
+	for i, req := range requests {
+		if req.Name == name {
+			return allocationPath(i, attribute)
+		}
+	}
+	return nil
+}
+
+// allocationErrorDiagnostics returns an error diagnostic with summary, set
+// against the allocation block attribute err is about, if any.
+func allocationErrorDiagnostics(summary string, err error, requests []cidr.AllocationRequest) diag.Diagnostics {
+	return diag.Diagnostics{{
+		Severity:      diag.Error,
+		Summary:       summary,
+		AttributePath: allocationErrorPath(err, requests),
+	}}
+}
+
+// withAllocationPath returns err as a cty.PathError against the allocation
+// block attribute it is about, for errors returned from CustomizeDiff, which
+// can't return diagnostics. Other errors are returned unchanged.
+func withAllocationPath(err error, requests []cidr.AllocationRequest) error {
+	if path := allocationErrorPath(err, requests); path != nil {
+		return path.NewError(err)
+	}
+	return err
+}
+
+// AllocationBlockError is an error about Attribute of the allocation block at
+// Index, for checks that have no error type of their own.
+type AllocationBlockError struct {
+	Index     int
+	Attribute string
+	Err       error
+}
+
+func (e *AllocationBlockError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *AllocationBlockError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,252 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DO-Solutions/terraform-provider-docidr/docidr/cidr"
+	"github.com/DO-Solutions/terraform-provider-docidr/docidr/config"
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func TestAllocationErrorPath(t *testing.T) {
+	requests := []cidr.AllocationRequest{{Name: "vpc"}, {Name: "k8s"}, {Name: "lb"}}
+
+	tests := []struct {
+		name string
+		err  error
+		want cty.Path
+	}{
+		{
+			name: "duplicate name",
+			err:  &DuplicateNameError{Name: "vpc", Index: 2},
+			want: allocationPath(2, "name"),
+		},
+		{
+			name: "no space",
+			err:  &cidr.AllocationError{Name: "k8s", PrefixLength: 20, Err: cidr.ErrNoSpace},
+			want: allocationPath(1, "prefix_length"),
+		},
+		{
+			name: "no space with blocking exclusions",
+			err:  fmt.Errorf("%w; blocked by exclusion 10.0.0.0/8", &cidr.AllocationError{Name: "lb", PrefixLength: 24, Err: cidr.ErrNoSpace}),
+			want: allocationPath(2, "prefix_length"),
+		},
+		{
+			name: "prefix length shorter than base",
+			err:  &cidr.PrefixLengthError{Name: "vpc", PrefixLength: 8, BasePrefixLength: 16},
+			want: allocationPath(0, "prefix_length"),
+		},
+		{
+			name: "reservation conflict",
+			err:  &cidr.ReservationError{Name: "k8s", Err: errors.New("overlaps")},
+			want: allocationPath(1, "name"),
+		},
+		{
+			name: "name not matching allocation_names_regex",
+			err:  &NameMismatchError{Pattern: "^[a-z]+$", Names: []string{"K8S", "LB"}, Index: 1},
+			want: allocationPath(1, "name"),
+		},
+		{
+			name: "prefix length out of bounds",
+			err:  &PrefixLengthBoundsError{Index: 2, Name: "lb", PrefixLength: 30, Max: 28},
+			want: allocationPath(2, "prefix_length"),
+		},
+		{
+			name: "unknown size preset",
+			err:  &UnknownSizePresetError{Index: 0, Name: "vpc", Size: "huge"},
+			want: allocationPath(0, "size"),
+		},
+		{
+			name: "allocation block",
+			err:  &AllocationBlockError{Index: 1, Attribute: "name", Err: errors.New("allocation 1: name is required unless auto_generate_names is true")},
+			want: allocationPath(1, "name"),
+		},
+		{
+			name: "unknown allocation",
+			err:  &cidr.AllocationError{Name: "missing", Err: cidr.ErrNoSpace},
+		},
+		{
+			name: "not about an allocation",
+			err:  errors.New("invalid base CIDR"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := allocationErrorPath(tt.err, requests)
+			if (got == nil) != (tt.want == nil) || (got != nil && !got.Equals(tt.want)) {
+				t.Errorf("allocationErrorPath() = %#v, want %#v", got, tt.want)
+			}
+
+			wrapped := withAllocationPath(tt.err, requests)
+			var pathErr cty.PathError
+			if tt.want == nil {
+				if wrapped != tt.err {
+					t.Errorf("withAllocationPath() = %#v, want the error unchanged", wrapped)
+				}
+				return
+			}
+			if !errors.As(wrapped, &pathErr) || !pathErr.Path.Equals(tt.want) {
+				t.Errorf("withAllocationPath() = %#v, want a cty.PathError at %#v", wrapped, tt.want)
+			}
+			if wrapped.Error() != tt.err.Error() {
+				t.Errorf("withAllocationPath() message = %q, want %q", wrapped.Error(), tt.err.Error())
+			}
+		})
+	}
+}
+
+func TestResourceDocidrPool_AllocationErrorPaths(t *testing.T) {
+	srv := httptest.NewServer(newFakeAccountMux([]interface{}{}, []interface{}{}))
+	t.Cleanup(srv.Close)
+	meta := func(planTime bool) *config.CombinedConfig {
+		return newTestCombinedConfig(t, &config.Config{APIEndpoint: srv.URL + "/", ComputeAllocationsAtPlanTime: planTime})
+	}
+
+	t.Run("duplicate name at plan time", func(t *testing.T) {
+		_, err := planPool(t, map[string]interface{}{
+			"allocation": []interface{}{
+				map[string]interface{}{"name": "vpc", "prefix_length": 24},
+				map[string]interface{}{"name": "k8s", "prefix_length": 24},
+				map[string]interface{}{"name": "vpc", "prefix_length": 24},
+			},
+		}, meta(false))
+		var pathErr cty.PathError
+		if !errors.As(err, &pathErr) || !pathErr.Path.Equals(allocationPath(2, "name")) {
+			t.Errorf("Diff() error = %#v, want a cty.PathError at allocation.2.name", err)
+		}
+	})
+
+	t.Run("no space at plan time", func(t *testing.T) {
+		_, err := planPool(t, map[string]interface{}{
+			"base_cidr": "10.0.0.0/24",
+			"allocation": []interface{}{
+				map[string]interface{}{"name": "a", "prefix_length": 25},
+				map[string]interface{}{"name": "b", "prefix_length": 25},
+				map[string]interface{}{"name": "c", "prefix_length": 25},
+			},
+		}, meta(true))
+		var pathErr cty.PathError
+		if !errors.As(err, &pathErr) || !pathErr.Path.Equals(allocationPath(2, "prefix_length")) {
+			t.Errorf("Diff() error = %#v, want a cty.PathError at allocation.2.prefix_length", err)
+		}
+	})
+
+	planTests := []struct {
+		name string
+		raw  map[string]interface{}
+		want cty.Path
+	}{
+		{
+			name: "missing name",
+			raw: map[string]interface{}{
+				"allocation": []interface{}{
+					map[string]interface{}{"name": "vpc", "prefix_length": 24},
+					map[string]interface{}{"prefix_length": 24},
+				},
+			},
+			want: allocationPath(1, "name"),
+		},
+		{
+			name: "name not matching allocation_names_regex",
+			raw: map[string]interface{}{
+				"allocation_names_regex": "^[a-z]+$",
+				"allocation": []interface{}{
+					map[string]interface{}{"name": "vpc", "prefix_length": 24},
+					map[string]interface{}{"name": "K8S", "prefix_length": 20},
+				},
+			},
+			want: allocationPath(1, "name"),
+		},
+		{
+			name: "missing size",
+			raw: map[string]interface{}{
+				"allocation": []interface{}{
+					map[string]interface{}{"name": "vpc"},
+				},
+			},
+			want: allocationPath(0, "prefix_length"),
+		},
+		{
+			name: "prefix length out of bounds",
+			raw: map[string]interface{}{
+				"max_prefix_length": 24,
+				"allocation": []interface{}{
+					map[string]interface{}{"name": "vpc", "prefix_length": 24},
+					map[string]interface{}{"name": "lb", "prefix_length": 28},
+				},
+			},
+			want: allocationPath(1, "prefix_length"),
+		},
+	}
+
+	for _, tt := range planTests {
+		t.Run(tt.name+" at plan time", func(t *testing.T) {
+			_, err := planPool(t, tt.raw, meta(false))
+			var pathErr cty.PathError
+			if !errors.As(err, &pathErr) || !pathErr.Path.Equals(tt.want) {
+				t.Errorf("Diff() error = %#v, want a cty.PathError at %#v", err, tt.want)
+			}
+		})
+	}
+
+	t.Run("prefix length out of bounds in docidr_pool_plan", func(t *testing.T) {
+		d := schema.TestResourceDataRaw(t, DataSourceDocidrPoolPlan().Schema, planTests[3].raw)
+		diags := dataSourceDocidrPoolPlanRead(context.Background(), d, meta(false))
+		if !diags.HasError() {
+			t.Fatalf("Read() diags = %v, want error", diags)
+		}
+		if got := diags[0].AttributePath; !got.Equals(planTests[3].want) {
+			t.Errorf("Read() error path = %#v, want %#v", got, planTests[3].want)
+		}
+	})
+
+	tests := []struct {
+		name string
+		raw  map[string]interface{}
+		want cty.Path
+	}{
+		{
+			name: "no space",
+			raw: map[string]interface{}{
+				"base_cidr": "10.0.0.0/24",
+				"allocation": []interface{}{
+					map[string]interface{}{"name": "a", "prefix_length": 25},
+					map[string]interface{}{"name": "b", "prefix_length": 24},
+				},
+			},
+			want: allocationPath(1, "prefix_length"),
+		},
+		{
+			name: "prefix length shorter than base",
+			raw: map[string]interface{}{
+				"base_cidr": "10.0.0.0/16",
+				"allocation": []interface{}{
+					map[string]interface{}{"name": "a", "prefix_length": 24},
+					map[string]interface{}{"name": "b", "prefix_length": 24},
+					map[string]interface{}{"name": "c", "prefix_length": 8},
+				},
+			},
+			want: allocationPath(2, "prefix_length"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name+" at apply time", func(t *testing.T) {
+			d := schema.TestResourceDataRaw(t, ResourceDocidrPool().Schema, tt.raw)
+			diags := resourceDocidrPoolCreate(context.Background(), d, meta(false))
+			if !diags.HasError() {
+				t.Fatalf("Create() diags = %v, want error", diags)
+			}
+			got := diags[len(diags)-1].AttributePath
+			if !got.Equals(tt.want) {
+				t.Errorf("Create() error path = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
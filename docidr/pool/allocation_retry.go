@@ -0,0 +1,49 @@
+package pool
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+)
+
+// maxAllocationRetryBudget is the largest allowed allocation_retry_budget.
+const maxAllocationRetryBudget = 5
+
+// allocationRetryBaseDelay is the wait before the first allocation retry. It
+// doubles with each further retry.
+var allocationRetryBaseDelay = time.Second
+
+// retryAllocation runs attempt, and while it returns errors runs it again up
+// to budget more times, with exponential backoff between runs. It stops early
+// once ctx is done or its deadline would pass during the wait. The
+// diagnostics of the last run are returned.
+func retryAllocation(ctx context.Context, budget int, attempt func() diag.Diagnostics) diag.Diagnostics {
+	diags := attempt()
+	for retry := 1; retry <= budget && diags.HasError(); retry++ {
+		delay := allocationRetryBaseDelay << (retry - 1)
+		if ctx.Err() != nil {
+			return diags
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			log.Printf("[DEBUG] Not retrying allocation: the operation times out in less than %s", delay)
+			return diags
+		}
+
+		log.Printf("[DEBUG] Retrying allocation (%d/%d) in %s after: %s", retry, budget, delay, firstError(diags))
+		time.Sleep(delay)
+		diags = attempt()
+	}
+	return diags
+}
+
+// firstError returns the summary of the first error in diags.
+func firstError(diags diag.Diagnostics) string {
+	for _, d := range diags {
+		if d.Severity == diag.Error {
+			return d.Summary
+		}
+	}
+	return ""
+}
@@ -0,0 +1,148 @@
+package pool
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+)
+
+// failingAttempt returns an attempt that fails the first failures runs, and
+// counts its runs in calls.
+func failingAttempt(failures int, calls *int) func() diag.Diagnostics {
+	return func() diag.Diagnostics {
+		*calls++
+		if *calls <= failures {
+			return diag.Errorf("attempt %d failed", *calls)
+		}
+		return nil
+	}
+}
+
+func TestRetryAllocation(t *testing.T) {
+	defer func(d time.Duration) { allocationRetryBaseDelay = d }(allocationRetryBaseDelay)
+	allocationRetryBaseDelay = time.Millisecond
+
+	tests := []struct {
+		name      string
+		budget    int
+		failures  int
+		wantCalls int
+		wantErr   bool
+	}{
+		{name: "succeeds first time", budget: 3, failures: 0, wantCalls: 1},
+		{name: "succeeds after failures", budget: 3, failures: 2, wantCalls: 3},
+		{name: "succeeds on last retry", budget: 3, failures: 3, wantCalls: 4},
+		{name: "budget exhausted", budget: 3, failures: 4, wantCalls: 4, wantErr: true},
+		{name: "no budget", budget: 0, failures: 1, wantCalls: 1, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int
+			diags := retryAllocation(context.Background(), tt.budget, failingAttempt(tt.failures, &calls))
+			if calls != tt.wantCalls {
+				t.Errorf("attempts = %d, want %d", calls, tt.wantCalls)
+			}
+			if diags.HasError() != tt.wantErr {
+				t.Errorf("retryAllocation() diags = %v, wantErr %v", diags, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRetryAllocation_Backoff(t *testing.T) {
+	defer func(d time.Duration) { allocationRetryBaseDelay = d }(allocationRetryBaseDelay)
+	allocationRetryBaseDelay = 10 * time.Millisecond
+
+	var calls int
+	start := time.Now()
+	retryAllocation(context.Background(), 3, failingAttempt(3, &calls))
+
+	// Waits of 10ms, 20ms and 40ms
+	if elapsed := time.Since(start); elapsed < 70*time.Millisecond {
+		t.Errorf("retries took %s, want at least 70ms", elapsed)
+	}
+}
+
+func TestRetryAllocation_Deadline(t *testing.T) {
+	defer func(d time.Duration) { allocationRetryBaseDelay = d }(allocationRetryBaseDelay)
+	allocationRetryBaseDelay = time.Hour
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	var calls int
+	diags := retryAllocation(ctx, 5, failingAttempt(5, &calls))
+	if calls != 1 || !diags.HasError() {
+		t.Errorf("attempts = %d, diags = %v, want one failed attempt", calls, diags)
+	}
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	calls = 0
+	if diags := retryAllocation(cancelled, 5, failingAttempt(5, &calls)); calls != 1 || !diags.HasError() {
+		t.Errorf("attempts = %d, diags = %v, want one failed attempt", calls, diags)
+	}
+}
+
+func TestAllocationRetryBudget(t *testing.T) {
+	defer func(d time.Duration) { allocationRetryBaseDelay = d }(allocationRetryBaseDelay)
+	allocationRetryBaseDelay = time.Millisecond
+
+	tests := []struct {
+		name     string
+		budget   int
+		failures int32
+		wantErr  bool
+	}{
+		{name: "recovers", budget: 2, failures: 2},
+		{name: "budget exhausted", budget: 2, failures: 3, wantErr: true},
+		{name: "no budget", budget: 0, failures: 1, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The VPC listing fails the first failures times. The client
+			// doesn't retry requests itself.
+			vpcs := newFakeAccountMux([]interface{}{
+				map[string]interface{}{"id": "vpc-1", "ip_range": "10.0.0.0/24"},
+			}, []interface{}{})
+			var requests int32
+			mux := http.NewServeMux()
+			mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/v2/vpcs" && atomic.AddInt32(&requests, 1) <= tt.failures {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				vpcs.ServeHTTP(w, r)
+			})
+			meta := newFakeCombinedConfig(t, mux)
+
+			raw := map[string]interface{}{
+				"base_cidr":               "10.0.0.0/16",
+				"allocation_retry_budget": tt.budget,
+				"allocation": []interface{}{
+					map[string]interface{}{"name": "vpc", "prefix_length": 24},
+				},
+			}
+			diff, err := planPool(t, raw, meta)
+			if err != nil {
+				t.Fatalf("Diff() error = %v", err)
+			}
+			state, diags := ResourceDocidrPool().Apply(context.Background(), nil, diff, meta)
+			if diags.HasError() != tt.wantErr {
+				t.Fatalf("Apply() diags = %v, wantErr %v", diags, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := state.Attributes["allocations.vpc"]; got != "10.0.1.0/24" {
+				t.Errorf("allocations.vpc = %s, want 10.0.1.0/24", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,242 @@
+package pool
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/DO-Solutions/terraform-provider-docidr/docidr/cidr"
+	"github.com/DO-Solutions/terraform-provider-docidr/docidr/config"
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// resolveAllocationSizes returns the allocation blocks with the prefix_length
+// of each block that sets size taken from presets, and of each block that sets
+// new_bits added to the prefix length of its base CIDR: its own base_cidr, or
+// the pool's baseCIDR. Sizes that aren't known yet read as empty and are left
+// unresolved, as are new_bits while the base CIDR is empty.
+func resolveAllocationSizes(allocations []interface{}, presets map[string]int, baseCIDR string) ([]interface{}, error) {
+	result := make([]interface{}, 0, len(allocations))
+	for i, alloc := range allocations {
+		m := alloc.(map[string]interface{})
+		name, _ := m["name"].(string)
+		prefixLength, _ := m["prefix_length"].(int)
+		size, _ := m["size"].(string)
+		newBits, _ := m["new_bits"].(int)
+
+		set := 0
+		for _, isSet := range []bool{prefixLength != 0, size != "", newBits != 0} {
+			if isSet {
+				set++
+			}
+		}
+		if set > 1 {
+			return nil, fmt.Errorf("%s: only one of prefix_length, size and new_bits may be set", describeAllocation(i, name))
+		}
+
+		switch {
+		case size != "":
+			resolved, ok := presets[size]
+			if !ok {
+				return nil, &UnknownSizePresetError{Index: i, Name: name, Size: size, Presets: presets}
+			}
+			prefixLength = resolved
+		case newBits != 0:
+			base := baseCIDR
+			if own, _ := m["base_cidr"].(string); own != "" {
+				base = own
+			}
+			if base == "" {
+				result = append(result, m)
+				continue
+			}
+			network, err := cidr.ParseCIDR(base)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", describeAllocation(i, name), err)
+			}
+			baseLen, _ := network.Mask.Size()
+			prefixLength = baseLen + newBits
+			if prefixLength < 16 || prefixLength > 28 {
+				return nil, fmt.Errorf("%s: new_bits %d on base %s gives a /%d, outside the valid range of 16-28", describeAllocation(i, name), newBits, base, prefixLength)
+			}
+		default:
+			result = append(result, m)
+			continue
+		}
+
+		resolved := make(map[string]interface{}, len(m))
+		for k, v := range m {
+			resolved[k] = v
+		}
+		resolved["prefix_length"] = prefixLength
+		result = append(result, resolved)
+	}
+	return result, nil
+}
+
+// UnknownSizePresetError is returned when an allocation's size isn't one of
+// the provider's size_presets.
+type UnknownSizePresetError struct {
+	Index   int
+	Name    string
+	Size    string
+	Presets map[string]int
+}
+
+func (e *UnknownSizePresetError) Error() string {
+	allocation := describeAllocation(e.Index, e.Name)
+	if len(e.Presets) == 0 {
+		return fmt.Sprintf("%s: size %q is not defined; the provider has no size_presets", allocation, e.Size)
+	}
+
+	names := make([]string, 0, len(e.Presets))
+	for name := range e.Presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return fmt.Sprintf("%s: size %q is not defined in the provider's size_presets (%s)", allocation, e.Size, strings.Join(names, ", "))
+}
+
+// validateAllocationSizesSet returns an error if an allocation sets none of
+// prefix_length, size and new_bits.
+func validateAllocationSizesSet(allocations []interface{}) error {
+	for i, alloc := range allocations {
+		m := alloc.(map[string]interface{})
+		prefixLength, _ := m["prefix_length"].(int)
+		size, _ := m["size"].(string)
+		newBits, _ := m["new_bits"].(int)
+		if prefixLength == 0 && size == "" && newBits == 0 {
+			name, _ := m["name"].(string)
+			return &AllocationBlockError{Index: i, Attribute: "prefix_length", Err: fmt.Errorf("%s: one of prefix_length, size or new_bits must be set", describeAllocation(i, name))}
+		}
+	}
+	return nil
+}
+
+// configAllocationBlocks returns the pool's allocation blocks with
+// prefix_length, size and new_bits as written in the configuration. Changes to those are
+// suppressed for existing pools by suppressAllocationSizeChange, so the
+// planned blocks may still hold the values in state. The blocks are returned
+// as planned when the configuration isn't available.
+func configAllocationBlocks(diff *schema.ResourceDiff) []interface{} {
+	blocks := diff.Get("allocation").([]interface{})
+	raw := diff.GetRawConfig()
+	if raw.IsNull() || !raw.IsKnown() || !raw.Type().IsObjectType() || !raw.Type().HasAttribute("allocation") {
+		return blocks
+	}
+	list := raw.GetAttr("allocation")
+	if list.IsNull() || !list.IsKnown() || list.LengthInt() != len(blocks) {
+		return blocks
+	}
+
+	result := make([]interface{}, 0, len(blocks))
+	for i, block := range list.AsValueSlice() {
+		m := make(map[string]interface{}, len(blocks[i].(map[string]interface{})))
+		for k, v := range blocks[i].(map[string]interface{}) {
+			m[k] = v
+		}
+		m["size"], m["prefix_length"], m["new_bits"] = "", 0, 0
+		if v := configAttr(block, "size"); v.Type() == cty.String {
+			m["size"] = v.AsString()
+		}
+		for _, key := range []string{"prefix_length", "new_bits"} {
+			if v := configAttr(block, key); v.Type() == cty.Number {
+				n, _ := v.AsBigFloat().Int64()
+				m[key] = int(n)
+			}
+		}
+		result = append(result, m)
+	}
+	return result
+}
+
+// configAttr returns the attribute name of the configuration block, or
+// cty.NilVal if it isn't set or known.
+func configAttr(block cty.Value, name string) cty.Value {
+	if block.IsNull() || !block.IsKnown() || !block.Type().IsObjectType() || !block.Type().HasAttribute(name) {
+		return cty.NilVal
+	}
+	v := block.GetAttr(name)
+	if v.IsNull() || !v.IsKnown() {
+		return cty.NilVal
+	}
+	return v
+}
+
+// allocationSizesKnown reports whether the prefix_length, size and new_bits of
+// each of the first count allocation blocks are known.
+func allocationSizesKnown(diff *schema.ResourceDiff, count int) bool {
+	for i := 0; i < count; i++ {
+		for _, key := range []string{"prefix_length", "size", "new_bits"} {
+			if !diff.NewValueKnown(fmt.Sprintf("allocation.%d.%s", i, key)) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// metaSizePresets returns the size presets configured on the provider, if
+// meta is its configuration.
+func metaSizePresets(meta interface{}) map[string]int {
+	if combined, ok := meta.(*config.CombinedConfig); ok {
+		return combined.SizePresets()
+	}
+	return nil
+}
+
+// flattenPrefixLengths returns the allocation_prefix_lengths map for requests.
+func flattenPrefixLengths(requests []cidr.AllocationRequest) map[string]interface{} {
+	result := make(map[string]interface{}, len(requests))
+	for _, req := range requests {
+		result[req.Name] = req.PrefixLength
+	}
+	return result
+}
+
+// prefixLengthsResolved reports whether every request has a prefix length,
+// which those given by new_bits don't until their base CIDR is known.
+func prefixLengthsResolved(requests []cidr.AllocationRequest) bool {
+	for _, req := range requests {
+		if req.PrefixLength == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// samePrefixLengths reports whether two allocation_prefix_lengths maps are
+// equal.
+func samePrefixLengths(a, b map[string]interface{}) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name, prefixLength := range a {
+		if other, ok := b[name]; !ok || other != prefixLength {
+			return false
+		}
+	}
+	return true
+}
+
+// suppressAllocationSizeChange suppresses changes to an existing pool's
+// prefix_length, size and new_bits that only change how an allocation's size
+// is given, such as switching to a renamed size preset or from prefix_length
+// to the equivalent new_bits, as well as those suppressed by
+// suppressAllocationReorder. CustomizeDiff replaces the pool if a resolved
+// prefix length changes. Pools created before allocation_prefix_lengths was
+// recorded are left to the plain diff.
+func suppressAllocationSizeChange(k, old, new string, d *schema.ResourceData) bool {
+	if suppressAllocationReorder(k, old, new, d) {
+		return true
+	}
+	if d.Id() == "" || len(d.Get("allocation_prefix_lengths").(map[string]interface{})) == 0 {
+		return false
+	}
+
+	block := k[:strings.LastIndex(k, ".")]
+	oldSize, newSize := d.GetChange(block + ".size")
+	oldNewBits, newNewBits := d.GetChange(block + ".new_bits")
+	return oldSize.(string) != "" || newSize.(string) != "" || oldNewBits.(int) != 0 || newNewBits.(int) != 0
+}
@@ -0,0 +1,97 @@
+package pool
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/DO-Solutions/terraform-provider-docidr/docidr/cidr"
+)
+
+// allocationsDocumentVersion is the version of the allocations_json format.
+// It is increased only when a change would break existing readers.
+const allocationsDocumentVersion = 1
+
+// allocationsDocument is the allocations_json attribute. Fields are marshaled
+// in declaration order, so the output is stable.
+type allocationsDocument struct {
+	Version     int                  `json:"version"`
+	ID          string               `json:"id"`
+	BaseCIDR    string               `json:"base_cidr"`
+	CreatedAt   string               `json:"created_at"`
+	Allocations []allocationDocument `json:"allocations"`
+}
+
+// allocationDocument is a single allocation in the allocations_json attribute.
+type allocationDocument struct {
+	Name         string   `json:"name"`
+	CIDR         string   `json:"cidr"`
+	PrefixLength int      `json:"prefix_length"`
+	Region       string   `json:"region"`
+	Tags         []string `json:"tags"`
+}
+
+// buildAllocationsJSON returns the allocations_json document for a pool
+// created at createdAt. Allocations are sorted by name.
+func buildAllocationsJSON(id, baseCIDR string, createdAt time.Time, results map[string]string, requests []cidr.AllocationRequest, tags []string) (string, error) {
+	if tags == nil {
+		tags = []string{}
+	}
+
+	byName := make(map[string]cidr.AllocationRequest, len(requests))
+	for _, req := range requests {
+		byName[req.Name] = req
+	}
+
+	names := make([]string, 0, len(results))
+	for name := range results {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	doc := allocationsDocument{
+		Version:     allocationsDocumentVersion,
+		ID:          id,
+		BaseCIDR:    baseCIDR,
+		CreatedAt:   createdAt.UTC().Format(time.RFC3339),
+		Allocations: make([]allocationDocument, 0, len(names)),
+	}
+	for _, name := range names {
+		network, err := cidr.ParseCIDR(results[name])
+		if err != nil {
+			return "", err
+		}
+		prefixLength, _ := network.Mask.Size()
+		doc.Allocations = append(doc.Allocations, allocationDocument{
+			Name:         name,
+			CIDR:         results[name],
+			PrefixLength: prefixLength,
+			Region:       byName[name].Region,
+			Tags:         tags,
+		})
+	}
+
+	out, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+// rebaseAllocationsJSON returns the allocations_json document doc with its
+// base CIDR replaced, keeping the rest of the document, including when the
+// pool was created.
+func rebaseAllocationsJSON(doc, baseCIDR string) (string, error) {
+	var parsed allocationsDocument
+	if err := json.Unmarshal([]byte(doc), &parsed); err != nil {
+		return "", fmt.Errorf("error parsing allocations_json: %w", err)
+	}
+	parsed.BaseCIDR = baseCIDR
+
+	out, err := json.MarshalIndent(parsed, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
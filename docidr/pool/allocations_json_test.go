@@ -0,0 +1,138 @@
+package pool
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/DO-Solutions/terraform-provider-docidr/docidr/cidr"
+)
+
+func TestBuildAllocationsJSON(t *testing.T) {
+	requests := []cidr.AllocationRequest{
+		{Name: "vpc", PrefixLength: 20, Region: "nyc1"},
+		{Name: "k8s", PrefixLength: 24},
+	}
+	results := map[string]string{
+		"vpc": "10.0.0.0/20",
+		"k8s": "10.0.16.0/24",
+	}
+	createdAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.FixedZone("EST", -5*60*60))
+
+	got, err := buildAllocationsJSON("pool-id", "10.0.0.0/16", createdAt, results, requests, []string{"env:prod"})
+	if err != nil {
+		t.Fatalf("buildAllocationsJSON() error = %v", err)
+	}
+
+	want := `{
+  "version": 1,
+  "id": "pool-id",
+  "base_cidr": "10.0.0.0/16",
+  "created_at": "2024-05-01T17:00:00Z",
+  "allocations": [
+    {
+      "name": "k8s",
+      "cidr": "10.0.16.0/24",
+      "prefix_length": 24,
+      "region": "",
+      "tags": [
+        "env:prod"
+      ]
+    },
+    {
+      "name": "vpc",
+      "cidr": "10.0.0.0/20",
+      "prefix_length": 20,
+      "region": "nyc1",
+      "tags": [
+        "env:prod"
+      ]
+    }
+  ]
+}`
+	if got != want {
+		t.Errorf("buildAllocationsJSON() =\n%s\nwant\n%s", got, want)
+	}
+
+	// The output must not depend on map iteration order
+	for i := 0; i < 10; i++ {
+		again, err := buildAllocationsJSON("pool-id", "10.0.0.0/16", createdAt, results, requests, []string{"env:prod"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if again != got {
+			t.Fatalf("buildAllocationsJSON() is not deterministic:\n%s\n%s", got, again)
+		}
+	}
+}
+
+func TestBuildAllocationsJSON_NoTags(t *testing.T) {
+	got, err := buildAllocationsJSON("pool-id", "10.0.0.0/16", time.Now(), map[string]string{"vpc": "10.0.0.0/20"}, nil, nil)
+	if err != nil {
+		t.Fatalf("buildAllocationsJSON() error = %v", err)
+	}
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal([]byte(got), &doc); err != nil {
+		t.Fatal(err)
+	}
+	tags := doc["allocations"].([]interface{})[0].(map[string]interface{})["tags"]
+	if list, ok := tags.([]interface{}); !ok || len(list) != 0 {
+		t.Errorf("tags = %#v, want an empty array", tags)
+	}
+}
+
+func TestResourceDocidrPoolCreate_AllocationsJSON(t *testing.T) {
+	meta := newFakeCombinedConfig(t, newFakeAccountMux([]interface{}{}, []interface{}{}))
+
+	before := time.Now().UTC().Truncate(time.Second)
+	state := applyPool(t, nil, map[string]interface{}{
+		"base_cidr":            "10.0.0.0/16",
+		"auto_tag_allocations": []interface{}{"env:prod"},
+		"allocation": []interface{}{
+			map[string]interface{}{"name": "vpc", "prefix_length": 20, "region": "nyc1"},
+			map[string]interface{}{"name": "k8s", "prefix_length": 24},
+		},
+	}, meta)
+
+	var doc allocationsDocument
+	if err := json.Unmarshal([]byte(state.Attributes["allocations_json"]), &doc); err != nil {
+		t.Fatalf("allocations_json is not valid JSON: %v\n%s", err, state.Attributes["allocations_json"])
+	}
+
+	if doc.Version != allocationsDocumentVersion {
+		t.Errorf("version = %d, want %d", doc.Version, allocationsDocumentVersion)
+	}
+	if doc.ID != state.ID {
+		t.Errorf("id = %q, want %q", doc.ID, state.ID)
+	}
+	if doc.BaseCIDR != "10.0.0.0/16" {
+		t.Errorf("base_cidr = %q, want 10.0.0.0/16", doc.BaseCIDR)
+	}
+	createdAt, err := time.Parse(time.RFC3339, doc.CreatedAt)
+	if err != nil || createdAt.Before(before) {
+		t.Errorf("created_at = %q, want an RFC 3339 time no earlier than %s", doc.CreatedAt, before.Format(time.RFC3339))
+	}
+
+	if len(doc.Allocations) != 2 {
+		t.Fatalf("allocations = %+v, want 2", doc.Allocations)
+	}
+	for i, name := range []string{"k8s", "vpc"} {
+		alloc := doc.Allocations[i]
+		if alloc.Name != name {
+			t.Errorf("allocations[%d].name = %q, want %q", i, alloc.Name, name)
+		}
+		if alloc.CIDR != state.Attributes["allocations."+name] {
+			t.Errorf("allocations[%d].cidr = %q, want %q", i, alloc.CIDR, state.Attributes["allocations."+name])
+		}
+		if len(alloc.Tags) != 1 || alloc.Tags[0] != "env:prod" {
+			t.Errorf("allocations[%d].tags = %v, want [env:prod]", i, alloc.Tags)
+		}
+	}
+	if doc.Allocations[0].PrefixLength != 24 || doc.Allocations[1].PrefixLength != 20 {
+		t.Errorf("prefix lengths = %d, %d, want 24, 20", doc.Allocations[0].PrefixLength, doc.Allocations[1].PrefixLength)
+	}
+	if doc.Allocations[1].Region != "nyc1" {
+		t.Errorf("allocations[1].region = %q, want nyc1", doc.Allocations[1].Region)
+	}
+}
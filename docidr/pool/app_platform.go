@@ -0,0 +1,39 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+
+	"github.com/DO-Solutions/terraform-provider-docidr/docidr/config"
+)
+
+// errMissingAppPlatformRangesURL is returned for pools with
+// include_app_platform_ranges when the provider has no ranges document to fetch.
+var errMissingAppPlatformRangesURL = errors.New("include_app_platform_ranges requires app_platform_ranges_url to be set on the provider")
+
+// collectAppPlatformCIDRs fetches the App Platform internal network ranges from
+// the document configured on the provider. The document is public, so it is
+// fetched without authentication, and the result is cached for the lifetime of
+// the provider instance.
+func collectAppPlatformCIDRs(ctx context.Context, meta *config.CombinedConfig) ([]*net.IPNet, error) {
+	url := meta.AppPlatformRangesURL()
+	if url == "" {
+		return nil, errMissingAppPlatformRangesURL
+	}
+
+	if networks, ok := meta.CachedRemoteExclusions(url); ok {
+		log.Printf("[DEBUG] Using %d cached App Platform ranges from %s", len(networks), url)
+		return networks, nil
+	}
+
+	networks, err := fetchExclusionURL(ctx, meta.HTTPClient(), url)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("[DEBUG] Fetched %d App Platform ranges from %s", len(networks), url)
+	meta.CacheRemoteExclusions(url, networks)
+	return networks, nil
+}
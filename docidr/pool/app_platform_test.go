@@ -0,0 +1,98 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/DO-Solutions/terraform-provider-docidr/docidr/config"
+)
+
+func TestCollectAppPlatformCIDRs(t *testing.T) {
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		if r.Header.Get("Authorization") != "" {
+			t.Errorf("unexpected Authorization header %q", r.Header.Get("Authorization"))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`["10.244.0.0/16", "10.245.0.0/16"]`))
+	}))
+	t.Cleanup(srv.Close)
+
+	meta := newTestCombinedConfig(t, &config.Config{AppPlatformRangesURL: srv.URL})
+
+	for i := 0; i < 2; i++ {
+		got, err := collectAppPlatformCIDRs(context.Background(), meta)
+		if err != nil {
+			t.Fatalf("collectAppPlatformCIDRs() error = %v", err)
+		}
+		if len(got) != 2 || got[0].String() != "10.244.0.0/16" || got[1].String() != "10.245.0.0/16" {
+			t.Errorf("collectAppPlatformCIDRs() = %v, want [10.244.0.0/16 10.245.0.0/16]", got)
+		}
+	}
+
+	if got := atomic.LoadInt32(&hits); got != 1 {
+		t.Errorf("server hits = %d, want 1 (second call should be cached)", got)
+	}
+}
+
+func TestCollectAppPlatformCIDRs_Errors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	t.Cleanup(srv.Close)
+
+	tests := []struct {
+		name    string
+		url     string
+		wantErr string
+	}{
+		{
+			name:    "url not configured",
+			wantErr: "app_platform_ranges_url",
+		},
+		{
+			name:    "not found",
+			url:     srv.URL,
+			wantErr: "404",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			meta := newTestCombinedConfig(t, &config.Config{AppPlatformRangesURL: tt.url})
+
+			_, err := collectAppPlatformCIDRs(context.Background(), meta)
+			if err == nil {
+				t.Fatal("collectAppPlatformCIDRs() expected error, got nil")
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("collectAppPlatformCIDRs() error = %q, want it to contain %q", err.Error(), tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestResourceDocidrPoolCustomizeDiff_AppPlatformRangesURL(t *testing.T) {
+	raw := map[string]interface{}{
+		"base_cidr":                   "10.0.0.0/16",
+		"include_app_platform_ranges": true,
+		"allocation": []interface{}{
+			map[string]interface{}{"name": "vpc", "prefix_length": 24},
+		},
+	}
+
+	_, err := planPool(t, raw, newTestCombinedConfig(t, &config.Config{}))
+	if !errors.Is(err, errMissingAppPlatformRangesURL) {
+		t.Errorf("Diff() error = %v, want %v", err, errMissingAppPlatformRangesURL)
+	}
+
+	if _, err := planPool(t, raw, newTestCombinedConfig(t, &config.Config{AppPlatformRangesURL: "https://example.com/ranges.txt"})); err != nil {
+		t.Errorf("Diff() with app_platform_ranges_url error = %v", err)
+	}
+}
@@ -0,0 +1,79 @@
+package pool
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+)
+
+// Operations recorded in the audit log.
+const (
+	auditOperationCreate = "create"
+	auditOperationUpdate = "update"
+	auditOperationDelete = "delete"
+)
+
+// auditLogEntry is a single line of the audit_log_file.
+type auditLogEntry struct {
+	Timestamp   string            `json:"timestamp"`
+	Operation   string            `json:"operation"`
+	ResourceID  string            `json:"resource_id"`
+	BaseCIDR    string            `json:"base_cidr"`
+	Allocations map[string]string `json:"allocations"`
+	Actor       string            `json:"actor"`
+}
+
+// newAuditLogEntry returns an entry for an operation on the pool, timestamped
+// now. The actor is the Terraform version running the provider.
+func newAuditLogEntry(operation, id, baseCIDR string, allocations map[string]interface{}, terraformVersion string) auditLogEntry {
+	entry := auditLogEntry{
+		Timestamp:   time.Now().UTC().Format(time.RFC3339),
+		Operation:   operation,
+		ResourceID:  id,
+		BaseCIDR:    baseCIDR,
+		Allocations: make(map[string]string, len(allocations)),
+		Actor:       "Terraform/" + terraformVersion,
+	}
+	for name, cidrBlock := range allocations {
+		entry.Allocations[name] = cidrBlock.(string)
+	}
+	return entry
+}
+
+// appendAuditLog appends entry to the file at path as a JSON line. The
+// operation itself has already succeeded, so failures are returned as
+// warnings.
+func appendAuditLog(path string, entry auditLogEntry) diag.Diagnostics {
+	if err := writeAuditLogLine(path, entry); err != nil {
+		log.Printf("[WARN] Unable to write audit log entry for %s: %v", entry.ResourceID, err)
+		return diag.Diagnostics{{
+			Severity: diag.Warning,
+			Summary:  "Unable to write audit log entry",
+			Detail:   fmt.Sprintf("The %s of %s was not recorded in %s: %s", entry.Operation, entry.ResourceID, path, err),
+		}}
+	}
+	log.Printf("[DEBUG] Recorded %s of %s in %s", entry.Operation, entry.ResourceID, path)
+	return nil
+}
+
+// writeAuditLogLine appends entry to the file at path, creating it if needed.
+func writeAuditLogLine(path string, entry auditLogEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,104 @@
+package pool
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DO-Solutions/terraform-provider-docidr/docidr/config"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+func TestAuditLogFile(t *testing.T) {
+	srv := httptest.NewServer(newFakeAccountMux([]interface{}{}, []interface{}{}))
+	t.Cleanup(srv.Close)
+	meta := newTestCombinedConfig(t, &config.Config{APIEndpoint: srv.URL + "/", TerraformVersion: "1.9.0"})
+
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	state := applyPool(t, nil, map[string]interface{}{
+		"base_cidr":      "10.0.0.0/16",
+		"audit_log_file": path,
+		"allocation": []interface{}{
+			map[string]interface{}{"name": "vpc", "prefix_length": 24},
+		},
+	}, meta)
+
+	d := ResourceDocidrPool().Data(state)
+	if diags := resourceDocidrPoolDelete(context.Background(), d, meta); len(diags) > 0 {
+		t.Fatalf("Delete() diags = %v", diags)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var entries []auditLogEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry auditLogEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("audit log line %q is not valid JSON: %v", scanner.Text(), err)
+		}
+		entries = append(entries, entry)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("audit log has %d entries, want 2: %+v", len(entries), entries)
+	}
+
+	for i, operation := range []string{auditOperationCreate, auditOperationDelete} {
+		entry := entries[i]
+		if entry.Operation != operation {
+			t.Errorf("entry %d operation = %q, want %q", i, entry.Operation, operation)
+		}
+		if entry.ResourceID != state.ID {
+			t.Errorf("entry %d resource_id = %q, want %q", i, entry.ResourceID, state.ID)
+		}
+		if entry.BaseCIDR != "10.0.0.0/16" {
+			t.Errorf("entry %d base_cidr = %q, want 10.0.0.0/16", i, entry.BaseCIDR)
+		}
+		if got := entry.Allocations["vpc"]; got != "10.0.0.0/24" {
+			t.Errorf("entry %d allocations.vpc = %q, want 10.0.0.0/24", i, got)
+		}
+		if entry.Actor != "Terraform/1.9.0" {
+			t.Errorf("entry %d actor = %q, want Terraform/1.9.0", i, entry.Actor)
+		}
+		if _, err := time.Parse(time.RFC3339, entry.Timestamp); err != nil {
+			t.Errorf("entry %d timestamp %q is not RFC 3339: %v", i, entry.Timestamp, err)
+		}
+	}
+}
+
+func TestAuditLogFile_WriteErrorIsWarning(t *testing.T) {
+	meta := newFakeCombinedConfig(t, newFakeAccountMux([]interface{}{}, []interface{}{}))
+
+	r := ResourceDocidrPool()
+	diff, err := r.Diff(context.Background(), nil, terraform.NewResourceConfigRaw(map[string]interface{}{
+		"base_cidr":      "10.0.0.0/16",
+		"audit_log_file": filepath.Join(t.TempDir(), "missing", "audit.jsonl"),
+		"allocation": []interface{}{
+			map[string]interface{}{"name": "vpc", "prefix_length": 24},
+		},
+	}), meta)
+	if err != nil {
+		t.Fatalf("Diff() error = %v", err)
+	}
+
+	state, diags := r.Apply(context.Background(), nil, diff, meta)
+	if diags.HasError() {
+		t.Fatalf("Apply() diags = %v", diags)
+	}
+	if state.Attributes["allocations.vpc"] != "10.0.0.0/24" {
+		t.Errorf("allocations.vpc = %q, want 10.0.0.0/24", state.Attributes["allocations.vpc"])
+	}
+	if len(diags) != 1 || diags[0].Severity != diag.Warning {
+		t.Errorf("Apply() diags = %v, want a single warning", diags)
+	}
+}
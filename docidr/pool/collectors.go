@@ -0,0 +1,893 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/DO-Solutions/terraform-provider-docidr/docidr/cidr"
+	"github.com/DO-Solutions/terraform-provider-docidr/docidr/config"
+	"github.com/digitalocean/godo"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+)
+
+// Sources of CIDRs found in the DigitalOcean account.
+const (
+	sourceVPC                     = "vpc"
+	sourceKubernetesClusterSubnet = "kubernetes_cluster_subnet"
+	sourceKubernetesServiceSubnet = "kubernetes_service_subnet"
+	sourceReservedIP              = "reserved_ip"
+	sourceLoadBalancer            = "load_balancer"
+	sourceVPCPeering              = "vpc-peering"
+	sourceBYOIPPrefix             = "byoip_prefix"
+	sourceTaggedResource          = "tagged_resource"
+
+	// sourceInterconnectPrefix is followed by the attachment name, or by
+	// "manual" for routes from interconnect_routes.
+	sourceInterconnectPrefix = "interconnect:"
+)
+
+// Collectors that can be skipped by a partial scan, as recorded in the scan report.
+const (
+	scanSourceVPCs          = "vpcs"
+	scanSourceKubernetes    = "kubernetes_clusters"
+	scanSourceReservedIPs   = "reserved_ips"
+	scanSourceLoadBalancers = "load_balancers"
+	scanSourceBYOIP         = "byoip_prefixes"
+	scanSourceInterconnects = "interconnects"
+	scanSourceVPCPeerings   = "vpc_peerings"
+	scanSourceTagged        = "tagged_resources"
+)
+
+// existingCIDR is a CIDR in use in the DigitalOcean account, along with the
+// resource it belongs to.
+type existingCIDR struct {
+	Network      *net.IPNet
+	Source       string
+	ResourceID   string
+	ResourceName string
+	Region       string
+
+	// Children are other existing CIDRs contained within this one, kept so
+	// their provenance isn't lost when they are deduplicated.
+	Children []existingCIDR
+}
+
+// String returns a description of the CIDR and the resource it belongs to.
+func (e existingCIDR) String() string {
+	s := fmt.Sprintf("%s (%s %s %q)", e.Network.String(), e.Source, e.ResourceID, e.ResourceName)
+	if len(e.Children) > 0 {
+		s += fmt.Sprintf(" containing %d more", len(e.Children))
+	}
+	return s
+}
+
+// invalidCIDR is a CIDR or address returned by the API that couldn't be
+// parsed, and so was left out of the scan.
+type invalidCIDR struct {
+	What       string
+	Value      string
+	ResourceID string
+	Err        error
+}
+
+// Resources returns this CIDR, without its children, followed by its children.
+func (e existingCIDR) Resources() []existingCIDR {
+	self := e
+	self.Children = nil
+	return append([]existingCIDR{self}, e.Children...)
+}
+
+// dedupeExistingCIDRs reduces the existing CIDRs to the minimal set that covers
+// them all. CIDRs contained within another are attached to it as children. The
+// remaining CIDRs keep their original order.
+func dedupeExistingCIDRs(existing []existingCIDR) []existingCIDR {
+	// Visit larger networks first so every network is seen after any
+	// network that contains it.
+	order := make([]int, len(existing))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		iLen, _ := existing[order[i]].Network.Mask.Size()
+		jLen, _ := existing[order[j]].Network.Mask.Size()
+		return iLen < jLen
+	})
+
+	parent := make(map[int]int, len(existing))
+	var covering []int
+	for _, i := range order {
+		nested := false
+		for _, c := range covering {
+			if cidr.ContainsNetwork(existing[c].Network, existing[i].Network) {
+				parent[i] = c
+				nested = true
+				break
+			}
+		}
+		if !nested {
+			covering = append(covering, i)
+		}
+	}
+	sort.Ints(covering)
+
+	result := make([]existingCIDR, 0, len(covering))
+	index := make(map[int]int, len(covering))
+	for _, c := range covering {
+		index[c] = len(result)
+		result = append(result, existing[c])
+	}
+	for i := range existing {
+		if c, ok := parent[i]; ok {
+			result[index[c]].Children = append(result[index[c]].Children, existing[i])
+		}
+	}
+	return result
+}
+
+// scanOptions controls which optional collectors run during a scan.
+type scanOptions struct {
+	ScanReservedIPs   bool
+	ScanLoadBalancers bool
+	ScanVPCPeerings   bool
+	ScanBYOIP         bool
+	ScanInterconnects bool
+
+	// IgnoreVPCs and IgnoreKubernetes skip listing VPCs and Kubernetes
+	// clusters, which are otherwise always scanned.
+	IgnoreVPCs       bool
+	IgnoreKubernetes bool
+
+	// AllowPartialScan skips collectors that fail with 401 or 403 instead of
+	// failing the scan.
+	AllowPartialScan bool
+
+	// WarnOnCIDRErrors returns a warning for each CIDR the API returned that
+	// couldn't be parsed, rather than only logging it.
+	WarnOnCIDRErrors bool
+
+	// PeeringRanges maps peer VPC IDs to their IP ranges, for peers whose
+	// range isn't visible in this account.
+	PeeringRanges map[string]string
+
+	// InterconnectRoutes are remote route prefixes configured manually, for
+	// attachments whose routes aren't exposed by the API.
+	InterconnectRoutes []string
+
+	// CheckTags, when set, also collects the CIDRs of resources that have
+	// every one of these tags.
+	CheckTags []string
+
+	// PageSize is the number of items to request per page. Zero uses
+	// config.DefaultAPIPageSize.
+	PageSize int
+}
+
+// expandScanOptions reads the scan options from the resource configuration.
+func expandScanOptions(get func(string) interface{}) scanOptions {
+	return scanOptions{
+		ScanReservedIPs:    get("scan_reserved_ips").(bool),
+		ScanLoadBalancers:  get("scan_load_balancers").(bool),
+		ScanVPCPeerings:    get("scan_vpc_peerings").(bool),
+		ScanBYOIP:          get("scan_byoip").(bool),
+		ScanInterconnects:  get("scan_interconnects").(bool),
+		IgnoreVPCs:         get("ignore_existing_vpc_cidrs").(bool),
+		IgnoreKubernetes:   get("ignore_existing_k8s_cidrs").(bool),
+		AllowPartialScan:   get("allow_partial_scan").(bool),
+		WarnOnCIDRErrors:   get("warn_on_existing_cidr_errors").(bool),
+		PeeringRanges:      expandPeeringRanges(get("peering_ranges").(map[string]interface{})),
+		InterconnectRoutes: expandStringList(get("interconnect_routes").([]interface{})),
+		CheckTags:          expandStringList(get("check_tags").([]interface{})),
+	}
+}
+
+// expandPeeringRanges converts the peering_ranges map to a map of strings.
+func expandPeeringRanges(raw map[string]interface{}) map[string]string {
+	result := make(map[string]string, len(raw))
+	for id, ipRange := range raw {
+		result[id] = ipRange.(string)
+	}
+	return result
+}
+
+// expandStringList converts a list of strings from the resource configuration.
+func expandStringList(raw []interface{}) []string {
+	result := make([]string, 0, len(raw))
+	for _, v := range raw {
+		result = append(result, v.(string))
+	}
+	return result
+}
+
+// existingNetworks returns the networks of the given existing CIDRs.
+func existingNetworks(existing []existingCIDR) []*net.IPNet {
+	result := make([]*net.IPNet, 0, len(existing))
+	for _, e := range existing {
+		result = append(result, e.Network)
+	}
+	return result
+}
+
+// collectExistingCIDRs queries the DigitalOcean API for all CIDRs currently in use.
+// Ranges that are known to be in use but can't be resolved are returned as warnings,
+// as are CIDRs the API returned that can't be parsed when opts.WarnOnCIDRErrors
+// is set. When partial scans are allowed, collectors the token isn't permitted to query
+// are skipped with a warning and returned in skipped.
+func collectExistingCIDRs(ctx context.Context, client *godo.Client, opts scanOptions) (cidrs []existingCIDR, skipped []string, diags diag.Diagnostics) {
+	// skip reports whether a collector error can be tolerated, recording the
+	// skipped source if so.
+	skip := func(source, what string, err error) bool {
+		if !opts.AllowPartialScan || !isPermissionError(err) {
+			return false
+		}
+		log.Printf("[WARN] Skipping %s: %v", what, err)
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Warning,
+			Summary:  fmt.Sprintf("Skipping %s in the existing CIDR scan", what),
+			Detail: fmt.Sprintf("The token is not permitted to list %s (%s). Allocations may overlap any %s "+
+				"in the account; exclude them explicitly if needed.", what, err, what),
+		})
+		skipped = append(skipped, source)
+		return true
+	}
+
+	// CIDRs the API returned that couldn't be parsed, which are left out of
+	// the scan
+	var invalid []invalidCIDR
+
+	// Collect VPC CIDRs. With ignore_existing_vpc_cidrs set, VPCs are still
+	// listed to tell the account's own side of VPC peerings from the other.
+	var vpcCIDRs []existingCIDR
+	if opts.IgnoreVPCs && !opts.ScanVPCPeerings {
+		log.Printf("[DEBUG] Skipping VPC CIDRs, ignore_existing_vpc_cidrs is set")
+	} else {
+		var vpcInvalid []invalidCIDR
+		var err error
+		vpcCIDRs, vpcInvalid, err = collectVPCCIDRs(ctx, client, opts.PageSize)
+		if err != nil && !skip(scanSourceVPCs, "VPC CIDRs", err) {
+			return nil, nil, scanError("VPC CIDRs", err)
+		}
+		if opts.IgnoreVPCs {
+			log.Printf("[DEBUG] Listed VPCs only to resolve VPC peerings, ignore_existing_vpc_cidrs is set")
+		} else {
+			cidrs = append(cidrs, vpcCIDRs...)
+			invalid = append(invalid, vpcInvalid...)
+		}
+	}
+
+	// Collect Kubernetes cluster CIDRs
+	if opts.IgnoreKubernetes {
+		log.Printf("[DEBUG] Skipping Kubernetes CIDRs, ignore_existing_k8s_cidrs is set")
+	} else {
+		k8sCIDRs, k8sInvalid, err := collectKubernetesCIDRs(ctx, client, opts.PageSize)
+		if err != nil && !skip(scanSourceKubernetes, "Kubernetes CIDRs", err) {
+			return nil, nil, scanError("Kubernetes CIDRs", err)
+		}
+		cidrs = append(cidrs, k8sCIDRs...)
+		invalid = append(invalid, k8sInvalid...)
+	}
+
+	// Collect reserved IP addresses
+	if opts.ScanReservedIPs {
+		reservedIPCIDRs, reservedIPInvalid, err := collectReservedIPCIDRs(ctx, client, opts.PageSize)
+		if err != nil && !skip(scanSourceReservedIPs, "reserved IPs", err) {
+			return nil, nil, scanError("reserved IPs", err)
+		}
+		cidrs = append(cidrs, reservedIPCIDRs...)
+		invalid = append(invalid, reservedIPInvalid...)
+	}
+
+	// Collect load balancer addresses
+	if opts.ScanLoadBalancers {
+		lbCIDRs, lbInvalid, err := collectLoadBalancerCIDRs(ctx, client, opts.PageSize)
+		if err != nil && !skip(scanSourceLoadBalancers, "load balancer IPs", err) {
+			return nil, nil, scanError("load balancer IPs", err)
+		}
+		cidrs = append(cidrs, lbCIDRs...)
+		invalid = append(invalid, lbInvalid...)
+	}
+
+	// Collect bring-your-own-IP prefixes
+	if opts.ScanBYOIP {
+		byoipCIDRs, byoipInvalid, err := collectBYOIPCIDRs(ctx, client, opts.PageSize)
+		if err != nil && !skip(scanSourceBYOIP, "BYOIP prefixes", err) {
+			return nil, nil, scanError("BYOIP prefixes", err)
+		}
+		cidrs = append(cidrs, byoipCIDRs...)
+		invalid = append(invalid, byoipInvalid...)
+	}
+
+	// Collect partner interconnect remote routes
+	if opts.ScanInterconnects {
+		interconnectCIDRs, interconnectInvalid, err := collectInterconnectCIDRs(ctx, client, opts.PageSize)
+		if err != nil && !skip(scanSourceInterconnects, "interconnect routes", err) {
+			return nil, nil, scanError("interconnect routes", err)
+		}
+		cidrs = append(cidrs, interconnectCIDRs...)
+		invalid = append(invalid, interconnectInvalid...)
+	}
+	for _, route := range opts.InterconnectRoutes {
+		network, err := cidr.ParseCIDR(route)
+		if err != nil {
+			return nil, nil, diag.Errorf("invalid interconnect_routes entry: %s", err)
+		}
+		cidrs = append(cidrs, existingCIDR{
+			Network: network,
+			Source:  sourceInterconnectPrefix + "manual",
+		})
+	}
+
+	// Collect the CIDRs of resources with the given tags
+	if len(opts.CheckTags) > 0 {
+		taggedCIDRs, err := collectTaggedResourceCIDRs(ctx, client, opts.CheckTags, opts.PageSize)
+		if err != nil && !skip(scanSourceTagged, "tagged resources", err) {
+			return nil, nil, scanError("tagged resources", err)
+		}
+		for _, network := range taggedCIDRs {
+			cidrs = append(cidrs, existingCIDR{
+				Network:      network,
+				Source:       sourceTaggedResource,
+				ResourceName: strings.Join(opts.CheckTags, ","),
+			})
+		}
+	}
+
+	// Collect the remote side of VPC peerings
+	if opts.ScanVPCPeerings {
+		peeringCIDRs, unresolved, err := collectVPCPeeringCIDRs(ctx, client, vpcCIDRs, opts.PeeringRanges, opts.PageSize)
+		if err != nil && !skip(scanSourceVPCPeerings, "VPC peerings", err) {
+			return nil, nil, scanError("VPC peerings", err)
+		}
+		cidrs = append(cidrs, peeringCIDRs...)
+		for _, u := range unresolved {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Warning,
+				Summary:  "Unable to resolve VPC peering range",
+				Detail: fmt.Sprintf("VPC peering %s (%q) connects to VPC %s, which is not in this account. "+
+					"Its IP range will not be excluded; add it to peering_ranges to exclude it.", u.PeeringID, u.PeeringName, u.VPCID),
+			})
+		}
+	}
+
+	if opts.WarnOnCIDRErrors {
+		diags = append(diags, invalidCIDRWarnings(invalid)...)
+	}
+
+	return cidrs, skipped, diags
+}
+
+// isPermissionError reports whether err is a 401 or 403 response from the API.
+func isPermissionError(err error) bool {
+	var errResp *godo.ErrorResponse
+	if !errors.As(err, &errResp) || errResp.Response == nil {
+		return false
+	}
+	return errResp.Response.StatusCode == http.StatusUnauthorized || errResp.Response.StatusCode == http.StatusForbidden
+}
+
+// listPages calls list with each page of results in turn until the last page
+// has been listed. A pageSize of zero uses config.DefaultAPIPageSize.
+func listPages(what string, pageSize int, list func(opt *godo.ListOptions) (*godo.Response, error)) error {
+	if pageSize <= 0 {
+		pageSize = config.DefaultAPIPageSize
+	}
+
+	opt := &godo.ListOptions{PerPage: pageSize}
+	pages := 0
+	for {
+		resp, err := list(opt)
+		if err != nil {
+			return err
+		}
+		pages++
+
+		if resp.Links == nil || resp.Links.IsLastPage() {
+			break
+		}
+
+		page, err := resp.Links.CurrentPage()
+		if err != nil {
+			return err
+		}
+		opt.Page = page + 1
+	}
+
+	log.Printf("[DEBUG] Listed %s in %d page(s) of up to %d", what, pages, pageSize)
+	return nil
+}
+
+// collectVPCCIDRs retrieves all VPC IP ranges from the DigitalOcean account.
+func collectVPCCIDRs(ctx context.Context, client *godo.Client, pageSize int) ([]existingCIDR, []invalidCIDR, error) {
+	var cidrs []existingCIDR
+	var invalid []invalidCIDR
+
+	err := listPages("VPCs", pageSize, func(opt *godo.ListOptions) (*godo.Response, error) {
+		vpcs, resp, err := client.VPCs.List(ctx, opt)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, vpc := range vpcs {
+			if vpc.IPRange != "" {
+				network, err := cidr.ParseCIDR(vpc.IPRange)
+				if err != nil {
+					log.Printf("[WARN] Skipping invalid VPC CIDR %q from VPC %s: %v", vpc.IPRange, vpc.ID, err)
+					invalid = append(invalid, invalidCIDR{What: "VPC CIDR", Value: vpc.IPRange, ResourceID: vpc.ID, Err: err})
+					continue
+				}
+				cidrs = append(cidrs, existingCIDR{
+					Network:      network,
+					Source:       sourceVPC,
+					ResourceID:   vpc.ID,
+					ResourceName: vpc.Name,
+					Region:       vpc.RegionSlug,
+				})
+				log.Printf("[DEBUG] Found VPC %s with CIDR %s", vpc.Name, vpc.IPRange)
+			}
+		}
+		return resp, nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return cidrs, invalid, nil
+}
+
+// collectKubernetesCIDRs retrieves all Kubernetes cluster and service subnets.
+func collectKubernetesCIDRs(ctx context.Context, client *godo.Client, pageSize int) ([]existingCIDR, []invalidCIDR, error) {
+	var cidrs []existingCIDR
+	var invalid []invalidCIDR
+
+	err := listPages("Kubernetes clusters", pageSize, func(opt *godo.ListOptions) (*godo.Response, error) {
+		clusters, resp, err := client.Kubernetes.List(ctx, opt)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, cluster := range clusters {
+			if cluster.ClusterSubnet != "" {
+				network, err := cidr.ParseCIDR(cluster.ClusterSubnet)
+				if err != nil {
+					log.Printf("[WARN] Skipping invalid cluster subnet %q from cluster %s: %v", cluster.ClusterSubnet, cluster.ID, err)
+					invalid = append(invalid, invalidCIDR{What: "Kubernetes cluster subnet", Value: cluster.ClusterSubnet, ResourceID: cluster.ID, Err: err})
+				} else {
+					cidrs = append(cidrs, existingCIDR{
+						Network:      network,
+						Source:       sourceKubernetesClusterSubnet,
+						ResourceID:   cluster.ID,
+						ResourceName: cluster.Name,
+						Region:       cluster.RegionSlug,
+					})
+					log.Printf("[DEBUG] Found Kubernetes cluster %s with cluster subnet %s", cluster.Name, cluster.ClusterSubnet)
+				}
+			}
+
+			if cluster.ServiceSubnet != "" {
+				network, err := cidr.ParseCIDR(cluster.ServiceSubnet)
+				if err != nil {
+					log.Printf("[WARN] Skipping invalid service subnet %q from cluster %s: %v", cluster.ServiceSubnet, cluster.ID, err)
+					invalid = append(invalid, invalidCIDR{What: "Kubernetes service subnet", Value: cluster.ServiceSubnet, ResourceID: cluster.ID, Err: err})
+				} else {
+					cidrs = append(cidrs, existingCIDR{
+						Network:      network,
+						Source:       sourceKubernetesServiceSubnet,
+						ResourceID:   cluster.ID,
+						ResourceName: cluster.Name,
+						Region:       cluster.RegionSlug,
+					})
+					log.Printf("[DEBUG] Found Kubernetes cluster %s with service subnet %s", cluster.Name, cluster.ServiceSubnet)
+				}
+			}
+		}
+		return resp, nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return cidrs, invalid, nil
+}
+
+// collectReservedIPCIDRs retrieves all reserved IPv4 addresses as /32 networks.
+func collectReservedIPCIDRs(ctx context.Context, client *godo.Client, pageSize int) ([]existingCIDR, []invalidCIDR, error) {
+	var cidrs []existingCIDR
+	var invalid []invalidCIDR
+
+	err := listPages("reserved IPs", pageSize, func(opt *godo.ListOptions) (*godo.Response, error) {
+		reservedIPs, resp, err := client.ReservedIPs.List(ctx, opt)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, reservedIP := range reservedIPs {
+			network, err := hostNetwork(reservedIP.IP)
+			if err != nil {
+				log.Printf("[WARN] Skipping invalid reserved IP %q: %v", reservedIP.IP, err)
+				invalid = append(invalid, invalidCIDR{What: "reserved IP", Value: reservedIP.IP, Err: err})
+				continue
+			}
+
+			var region string
+			if reservedIP.Region != nil {
+				region = reservedIP.Region.Slug
+			}
+			cidrs = append(cidrs, existingCIDR{
+				Network:    network,
+				Source:     sourceReservedIP,
+				ResourceID: reservedIP.IP,
+				Region:     region,
+			})
+			log.Printf("[DEBUG] Found reserved IP %s", reservedIP.IP)
+		}
+		return resp, nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return cidrs, invalid, nil
+}
+
+// collectLoadBalancerCIDRs retrieves all load balancer IPv4 addresses as /32 networks.
+func collectLoadBalancerCIDRs(ctx context.Context, client *godo.Client, pageSize int) ([]existingCIDR, []invalidCIDR, error) {
+	var cidrs []existingCIDR
+	var invalid []invalidCIDR
+
+	err := listPages("load balancers", pageSize, func(opt *godo.ListOptions) (*godo.Response, error) {
+		lbs, resp, err := client.LoadBalancers.List(ctx, opt)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, lb := range lbs {
+			if lb.IP == "" {
+				continue
+			}
+
+			network, err := hostNetwork(lb.IP)
+			if err != nil {
+				log.Printf("[WARN] Skipping invalid load balancer IP %q from load balancer %s: %v", lb.IP, lb.ID, err)
+				invalid = append(invalid, invalidCIDR{What: "load balancer IP", Value: lb.IP, ResourceID: lb.ID, Err: err})
+				continue
+			}
+
+			var region string
+			if lb.Region != nil {
+				region = lb.Region.Slug
+			}
+			cidrs = append(cidrs, existingCIDR{
+				Network:      network,
+				Source:       sourceLoadBalancer,
+				ResourceID:   lb.ID,
+				ResourceName: lb.Name,
+				Region:       region,
+			})
+			log.Printf("[DEBUG] Found load balancer %s with IP %s", lb.Name, lb.IP)
+		}
+		return resp, nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return cidrs, invalid, nil
+}
+
+// collectBYOIPCIDRs retrieves all bring-your-own-IP prefixes. Accounts without
+// the BYOIP feature respond with 404, which is treated as having no prefixes.
+func collectBYOIPCIDRs(ctx context.Context, client *godo.Client, pageSize int) ([]existingCIDR, []invalidCIDR, error) {
+	var cidrs []existingCIDR
+	var invalid []invalidCIDR
+
+	err := listPages("BYOIP prefixes", pageSize, func(opt *godo.ListOptions) (*godo.Response, error) {
+		prefixes, resp, err := client.BYOIPPrefixes.List(ctx, opt)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, prefix := range prefixes {
+			network, err := cidr.ParseCIDR(prefix.Prefix)
+			if err != nil {
+				log.Printf("[WARN] Skipping invalid BYOIP prefix %q (%s): %v", prefix.Prefix, prefix.UUID, err)
+				invalid = append(invalid, invalidCIDR{What: "BYOIP prefix", Value: prefix.Prefix, ResourceID: prefix.UUID, Err: err})
+				continue
+			}
+			cidrs = append(cidrs, existingCIDR{
+				Network:    network,
+				Source:     sourceBYOIPPrefix,
+				ResourceID: prefix.UUID,
+				Region:     prefix.Region,
+			})
+			log.Printf("[DEBUG] Found BYOIP prefix %s", prefix.Prefix)
+		}
+		return resp, nil
+	})
+	if err != nil {
+		var errResp *godo.ErrorResponse
+		if errors.As(err, &errResp) && errResp.Response.StatusCode == http.StatusNotFound {
+			log.Printf("[DEBUG] BYOIP prefixes are not available for this account")
+			return nil, nil, nil
+		}
+		return nil, nil, err
+	}
+
+	return cidrs, invalid, nil
+}
+
+// collectInterconnectCIDRs retrieves the remote routes advertised over every
+// partner interconnect attachment.
+func collectInterconnectCIDRs(ctx context.Context, client *godo.Client, pageSize int) ([]existingCIDR, []invalidCIDR, error) {
+	var cidrs []existingCIDR
+	var invalid []invalidCIDR
+
+	err := listPages("partner interconnect attachments", pageSize, func(opt *godo.ListOptions) (*godo.Response, error) {
+		attachments, resp, err := client.PartnerAttachment.List(ctx, opt)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, attachment := range attachments {
+			routes, invalidRoutes, err := collectInterconnectRoutes(ctx, client, attachment, pageSize)
+			if err != nil {
+				return nil, fmt.Errorf("error listing remote routes for %s: %w", attachment.Name, err)
+			}
+			cidrs = append(cidrs, routes...)
+			invalid = append(invalid, invalidRoutes...)
+		}
+		return resp, nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return cidrs, invalid, nil
+}
+
+// collectInterconnectRoutes retrieves the remote routes of a single partner
+// interconnect attachment. Attachments whose routes aren't exposed by the API
+// return 404, which is treated as having no routes.
+func collectInterconnectRoutes(ctx context.Context, client *godo.Client, attachment *godo.PartnerAttachment, pageSize int) ([]existingCIDR, []invalidCIDR, error) {
+	var cidrs []existingCIDR
+	var invalid []invalidCIDR
+
+	err := listPages("remote routes of "+attachment.Name, pageSize, func(opt *godo.ListOptions) (*godo.Response, error) {
+		routes, resp, err := client.PartnerAttachment.ListRoutes(ctx, attachment.ID, opt)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, route := range routes {
+			network, err := cidr.ParseCIDR(route.Cidr)
+			if err != nil {
+				log.Printf("[WARN] Skipping invalid remote route %q from interconnect %s: %v", route.Cidr, attachment.ID, err)
+				invalid = append(invalid, invalidCIDR{What: "interconnect remote route", Value: route.Cidr, ResourceID: attachment.ID, Err: err})
+				continue
+			}
+			cidrs = append(cidrs, existingCIDR{
+				Network:      network,
+				Source:       sourceInterconnectPrefix + attachment.Name,
+				ResourceID:   attachment.ID,
+				ResourceName: attachment.Name,
+				Region:       attachment.Region,
+			})
+			log.Printf("[DEBUG] Found interconnect %s with remote route %s", attachment.Name, route.Cidr)
+		}
+		return resp, nil
+	})
+	if err != nil {
+		var errResp *godo.ErrorResponse
+		if errors.As(err, &errResp) && errResp.Response.StatusCode == http.StatusNotFound {
+			log.Printf("[DEBUG] Remote routes are not available for interconnect %s", attachment.Name)
+			return nil, nil, nil
+		}
+		return nil, nil, err
+	}
+
+	return cidrs, invalid, nil
+}
+
+// invalidCIDRWarnings returns a warning for each CIDR the API returned that
+// couldn't be parsed.
+func invalidCIDRWarnings(invalid []invalidCIDR) diag.Diagnostics {
+	var diags diag.Diagnostics
+	for _, i := range invalid {
+		from := ""
+		if i.ResourceID != "" {
+			from = " from " + i.ResourceID
+		}
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Warning,
+			Summary:  "Skipping invalid CIDR from DigitalOcean",
+			Detail: fmt.Sprintf("The %s %q%s could not be parsed (%s), so it was left out of the existing CIDR scan. "+
+				"Allocations may overlap it; exclude it explicitly if needed.", i.What, i.Value, from, i.Err),
+		})
+	}
+	return diags
+}
+
+// collectTaggedResourceCIDRs retrieves the CIDRs of the resources that have
+// every one of tags, in any region: the cluster and service subnets and VPC of
+// each such Kubernetes cluster, and the VPC of each such Droplet. VPCs can't be
+// tagged themselves, so they are found through the resources in them.
+func collectTaggedResourceCIDRs(ctx context.Context, client *godo.Client, tags []string, pageSize int) ([]*net.IPNet, error) {
+	if len(tags) == 0 {
+		return nil, nil
+	}
+
+	var cidrs []*net.IPNet
+	var vpcIDs []string
+	seenVPCs := make(map[string]bool)
+	addVPC := func(id string) {
+		if id != "" && !seenVPCs[id] {
+			seenVPCs[id] = true
+			vpcIDs = append(vpcIDs, id)
+		}
+	}
+
+	err := listPages("Kubernetes clusters", pageSize, func(opt *godo.ListOptions) (*godo.Response, error) {
+		clusters, resp, err := client.Kubernetes.List(ctx, opt)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, cluster := range clusters {
+			if !hasAllTags(cluster.Tags, tags) {
+				continue
+			}
+			for _, subnet := range []string{cluster.ClusterSubnet, cluster.ServiceSubnet} {
+				if subnet == "" {
+					continue
+				}
+				network, err := cidr.ParseCIDR(subnet)
+				if err != nil {
+					log.Printf("[WARN] Skipping invalid subnet %q from tagged cluster %s: %v", subnet, cluster.ID, err)
+					continue
+				}
+				cidrs = append(cidrs, network)
+				log.Printf("[DEBUG] Found tagged Kubernetes cluster %s with subnet %s", cluster.Name, subnet)
+			}
+			addVPC(cluster.VPCUUID)
+		}
+		return resp, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Droplets are listed by a single tag, and filtered by the rest
+	err = listPages("Droplets tagged "+tags[0], pageSize, func(opt *godo.ListOptions) (*godo.Response, error) {
+		droplets, resp, err := client.Droplets.ListByTag(ctx, tags[0], opt)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, droplet := range droplets {
+			if hasAllTags(droplet.Tags, tags) {
+				addVPC(droplet.VPCUUID)
+			}
+		}
+		return resp, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, id := range vpcIDs {
+		vpc, _, err := client.VPCs.Get(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("error getting VPC %s: %w", id, err)
+		}
+		network, err := cidr.ParseCIDR(vpc.IPRange)
+		if err != nil {
+			log.Printf("[WARN] Skipping invalid VPC CIDR %q from VPC %s: %v", vpc.IPRange, vpc.ID, err)
+			continue
+		}
+		cidrs = append(cidrs, network)
+		log.Printf("[DEBUG] Found VPC %s with CIDR %s containing tagged resources", vpc.Name, vpc.IPRange)
+	}
+
+	return cidrs, nil
+}
+
+// hasAllTags reports whether resourceTags includes every one of tags.
+func hasAllTags(resourceTags, tags []string) bool {
+	have := make(map[string]bool, len(resourceTags))
+	for _, tag := range resourceTags {
+		have[tag] = true
+	}
+	for _, tag := range tags {
+		if !have[tag] {
+			return false
+		}
+	}
+	return true
+}
+
+// scanError returns an error diagnostic for a collector that failed.
+func scanError(what string, err error) diag.Diagnostics {
+	return diag.Diagnostics{{
+		Severity: diag.Error,
+		Summary:  "Error querying existing CIDRs from DigitalOcean",
+		Detail:   fmt.Sprintf("error collecting %s: %s", what, err),
+	}}
+}
+
+// unresolvedPeer is a peer VPC whose IP range couldn't be determined.
+type unresolvedPeer struct {
+	PeeringID   string
+	PeeringName string
+	VPCID       string
+}
+
+// collectVPCPeeringCIDRs retrieves the IP ranges of peer VPCs. Peers in this
+// account are already covered by the VPC scan, so only peers missing from vpcs
+// are returned. Their ranges are taken from peeringRanges; peers without an
+// entry there are returned as unresolved.
+func collectVPCPeeringCIDRs(ctx context.Context, client *godo.Client, vpcs []existingCIDR, peeringRanges map[string]string, pageSize int) ([]existingCIDR, []unresolvedPeer, error) {
+	var cidrs []existingCIDR
+	var unresolved []unresolvedPeer
+
+	known := make(map[string]bool, len(vpcs))
+	for _, vpc := range vpcs {
+		known[vpc.ResourceID] = true
+	}
+
+	err := listPages("VPC peerings", pageSize, func(opt *godo.ListOptions) (*godo.Response, error) {
+		peerings, resp, err := client.VPCs.ListVPCPeerings(ctx, opt)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, peering := range peerings {
+			for _, vpcID := range peering.VPCIDs {
+				if known[vpcID] {
+					continue
+				}
+
+				ipRange, ok := peeringRanges[vpcID]
+				if !ok {
+					log.Printf("[WARN] Unable to resolve IP range of VPC %s peered by %s", vpcID, peering.ID)
+					unresolved = append(unresolved, unresolvedPeer{
+						PeeringID:   peering.ID,
+						PeeringName: peering.Name,
+						VPCID:       vpcID,
+					})
+					continue
+				}
+
+				network, err := cidr.ParseCIDR(ipRange)
+				if err != nil {
+					return nil, fmt.Errorf("invalid peering_ranges entry for VPC %s: %w", vpcID, err)
+				}
+				cidrs = append(cidrs, existingCIDR{
+					Network:      network,
+					Source:       sourceVPCPeering,
+					ResourceID:   peering.ID,
+					ResourceName: peering.Name,
+				})
+				log.Printf("[DEBUG] Found VPC peering %s to VPC %s with CIDR %s", peering.Name, vpcID, ipRange)
+			}
+		}
+		return resp, nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return cidrs, unresolved, nil
+}
+
+// hostNetwork returns a /32 network for an IPv4 address.
+func hostNetwork(address string) (*net.IPNet, error) {
+	ip := net.ParseIP(address).To4()
+	if ip == nil {
+		return nil, fmt.Errorf("not an IPv4 address")
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(32, 32)}, nil
+}
@@ -0,0 +1,822 @@
+package pool
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/DO-Solutions/terraform-provider-docidr/docidr/cidr"
+	"github.com/DO-Solutions/terraform-provider-docidr/docidr/config"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+)
+
+// newFakeAccountMux returns a mux serving the VPC and Kubernetes endpoints used
+// by every scan, with the given VPCs and clusters.
+func newFakeAccountMux(vpcs, clusters []interface{}) *http.ServeMux {
+	mux := http.NewServeMux()
+	servePages(mux, "/v2/vpcs", "vpcs", vpcs)
+	servePages(mux, "/v2/kubernetes/clusters", "kubernetes_clusters", clusters)
+	return mux
+}
+
+func TestCollectExistingCIDRs_Default(t *testing.T) {
+	mux := newFakeAccountMux(
+		[]interface{}{
+			map[string]interface{}{"id": "vpc-1", "name": "prod", "ip_range": "10.0.0.0/16", "region": "nyc1"},
+			map[string]interface{}{"id": "vpc-2", "name": "broken", "ip_range": "not-a-cidr", "region": "nyc1"},
+		},
+		[]interface{}{
+			map[string]interface{}{"id": "k8s-1", "name": "cluster", "region": "nyc1", "cluster_subnet": "10.244.0.0/16", "service_subnet": "10.245.0.0/16"},
+		},
+	)
+	client := newFakeGodoClient(t, mux)
+
+	got, _, diags := collectExistingCIDRs(context.Background(), client, scanOptions{})
+	if diags.HasError() {
+		t.Fatalf("collectExistingCIDRs() diags = %v", diags)
+	}
+
+	want := []existingCIDR{
+		{Source: sourceVPC, ResourceID: "vpc-1", ResourceName: "prod", Region: "nyc1"},
+		{Source: sourceKubernetesClusterSubnet, ResourceID: "k8s-1", ResourceName: "cluster", Region: "nyc1"},
+		{Source: sourceKubernetesServiceSubnet, ResourceID: "k8s-1", ResourceName: "cluster", Region: "nyc1"},
+	}
+	wantCIDRs := []string{"10.0.0.0/16", "10.244.0.0/16", "10.245.0.0/16"}
+
+	if len(got) != len(want) {
+		t.Fatalf("collectExistingCIDRs() returned %d CIDRs, want %d: %v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i].Network.String() != wantCIDRs[i] {
+			t.Errorf("CIDR[%d] = %s, want %s", i, got[i].Network.String(), wantCIDRs[i])
+		}
+		if got[i].Source != want[i].Source || got[i].ResourceID != want[i].ResourceID ||
+			got[i].ResourceName != want[i].ResourceName || got[i].Region != want[i].Region {
+			t.Errorf("CIDR[%d] metadata = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestCollectExistingCIDRs_InvalidCIDRWarnings(t *testing.T) {
+	mux := newFakeAccountMux(
+		[]interface{}{
+			map[string]interface{}{"id": "vpc-1", "name": "prod", "ip_range": "10.0.0.0/16", "region": "nyc1"},
+			map[string]interface{}{"id": "vpc-2", "name": "broken", "ip_range": "not-a-cidr", "region": "nyc1"},
+		},
+		[]interface{}{
+			map[string]interface{}{"id": "k8s-1", "name": "cluster", "region": "nyc1", "cluster_subnet": "10.244.0.0/33", "service_subnet": "10.245.0.0/16"},
+		},
+	)
+	client := newFakeGodoClient(t, mux)
+
+	tests := []struct {
+		name string
+		warn bool
+		want []string
+	}{
+		{name: "warnings", warn: true, want: []string{`"not-a-cidr" from vpc-2`, `"10.244.0.0/33" from k8s-1`}},
+		{name: "logged only", warn: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _, diags := collectExistingCIDRs(context.Background(), client, scanOptions{WarnOnCIDRErrors: tt.warn})
+			if diags.HasError() {
+				t.Fatalf("collectExistingCIDRs() diags = %v", diags)
+			}
+			if len(got) != 2 {
+				t.Errorf("collectExistingCIDRs() = %v, want the valid VPC and service subnet", got)
+			}
+
+			if len(diags) != len(tt.want) {
+				t.Fatalf("collectExistingCIDRs() returned %d diagnostics, want %d: %v", len(diags), len(tt.want), diags)
+			}
+			for i, want := range tt.want {
+				if diags[i].Severity != diag.Warning || !strings.Contains(diags[i].Detail, want) {
+					t.Errorf("diags[%d] = %+v, want a warning containing %s", i, diags[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestCollectExistingCIDRs_ReservedIPsAndLoadBalancers(t *testing.T) {
+	mux := newFakeAccountMux(nil, nil)
+	servePages(mux, "/v2/reserved_ips", "reserved_ips",
+		[]interface{}{
+			map[string]interface{}{"ip": "203.0.113.10", "region": map[string]interface{}{"slug": "nyc1"}},
+		},
+		[]interface{}{
+			map[string]interface{}{"ip": "203.0.113.11", "region": map[string]interface{}{"slug": "sfo3"}},
+		},
+	)
+	servePages(mux, "/v2/load_balancers", "load_balancers",
+		[]interface{}{
+			map[string]interface{}{"id": "lb-1", "name": "web", "ip": "198.51.100.5", "region": map[string]interface{}{"slug": "nyc1"}},
+			map[string]interface{}{"id": "lb-2", "name": "pending"},
+		},
+	)
+	client := newFakeGodoClient(t, mux)
+
+	tests := []struct {
+		name string
+		opts scanOptions
+		want map[string]string
+	}{
+		{
+			name: "disabled by default",
+			opts: scanOptions{},
+			want: map[string]string{},
+		},
+		{
+			name: "reserved IPs across pages",
+			opts: scanOptions{ScanReservedIPs: true},
+			want: map[string]string{
+				"203.0.113.10/32": sourceReservedIP,
+				"203.0.113.11/32": sourceReservedIP,
+			},
+		},
+		{
+			name: "load balancers",
+			opts: scanOptions{ScanLoadBalancers: true},
+			want: map[string]string{
+				"198.51.100.5/32": sourceLoadBalancer,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _, diags := collectExistingCIDRs(context.Background(), client, tt.opts)
+			if diags.HasError() {
+				t.Fatalf("collectExistingCIDRs() diags = %v", diags)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("collectExistingCIDRs() returned %d CIDRs, want %d: %v", len(got), len(tt.want), got)
+			}
+			for _, e := range got {
+				if source, ok := tt.want[e.Network.String()]; !ok || source != e.Source {
+					t.Errorf("unexpected CIDR %s", e.String())
+				}
+			}
+		})
+	}
+}
+
+func TestCollectExistingCIDRs_Error(t *testing.T) {
+	mux := http.NewServeMux()
+	servePages(mux, "/v2/vpcs", "vpcs", nil)
+	serveError(mux, "/v2/kubernetes/clusters", http.StatusInternalServerError)
+	client := newFakeGodoClient(t, mux)
+
+	if _, _, diags := collectExistingCIDRs(context.Background(), client, scanOptions{}); !diags.HasError() {
+		t.Error("collectExistingCIDRs() expected error, got none")
+	}
+}
+
+func TestCollectExistingCIDRs_IgnoreExisting(t *testing.T) {
+	vpcs := []interface{}{
+		map[string]interface{}{"id": "vpc-1", "name": "prod", "ip_range": "10.0.0.0/16", "region": "nyc1"},
+	}
+	clusters := []interface{}{
+		map[string]interface{}{"id": "k8s-1", "name": "cluster", "region": "nyc1", "cluster_subnet": "10.244.0.0/16", "service_subnet": "10.245.0.0/16"},
+	}
+
+	tests := []struct {
+		name string
+		opts scanOptions
+		// failing are the endpoints that fail if they are listed
+		failing map[string]bool
+		want    map[string]string
+	}{
+		{
+			name:    "ignore VPCs",
+			opts:    scanOptions{IgnoreVPCs: true},
+			failing: map[string]bool{"/v2/vpcs": true},
+			want: map[string]string{
+				"10.244.0.0/16": sourceKubernetesClusterSubnet,
+				"10.245.0.0/16": sourceKubernetesServiceSubnet,
+			},
+		},
+		{
+			name:    "ignore Kubernetes",
+			opts:    scanOptions{IgnoreKubernetes: true},
+			failing: map[string]bool{"/v2/kubernetes/clusters": true},
+			want: map[string]string{
+				"10.0.0.0/16": sourceVPC,
+			},
+		},
+		{
+			name:    "ignore both",
+			opts:    scanOptions{IgnoreVPCs: true, IgnoreKubernetes: true},
+			failing: map[string]bool{"/v2/vpcs": true, "/v2/kubernetes/clusters": true},
+			want:    map[string]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mux := http.NewServeMux()
+			for _, endpoint := range []struct {
+				path, key string
+				items     []interface{}
+			}{
+				{"/v2/vpcs", "vpcs", vpcs},
+				{"/v2/kubernetes/clusters", "kubernetes_clusters", clusters},
+			} {
+				if tt.failing[endpoint.path] {
+					serveError(mux, endpoint.path, http.StatusForbidden)
+					continue
+				}
+				servePages(mux, endpoint.path, endpoint.key, endpoint.items)
+			}
+			client := newFakeGodoClient(t, mux)
+
+			got, skipped, diags := collectExistingCIDRs(context.Background(), client, tt.opts)
+			if diags.HasError() {
+				t.Fatalf("collectExistingCIDRs() diags = %v", diags)
+			}
+			if len(skipped) != 0 {
+				t.Errorf("collectExistingCIDRs() skipped = %v, want none", skipped)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("collectExistingCIDRs() returned %d CIDRs, want %d: %v", len(got), len(tt.want), got)
+			}
+			for _, e := range got {
+				if source, ok := tt.want[e.Network.String()]; !ok || source != e.Source {
+					t.Errorf("unexpected CIDR %s", e.String())
+				}
+			}
+		})
+	}
+}
+
+func TestCollectExistingCIDRs_VPCPeerings(t *testing.T) {
+	mux := newFakeAccountMux(
+		[]interface{}{
+			map[string]interface{}{"id": "vpc-1", "name": "prod", "ip_range": "10.0.0.0/16", "region": "nyc1"},
+			map[string]interface{}{"id": "vpc-2", "name": "staging", "ip_range": "10.1.0.0/16", "region": "nyc1"},
+		},
+		nil,
+	)
+	servePages(mux, "/v2/vpc_peerings", "vpc_peerings",
+		[]interface{}{
+			map[string]interface{}{"id": "peer-1", "name": "prod-staging", "vpc_ids": []string{"vpc-1", "vpc-2"}},
+			map[string]interface{}{"id": "peer-2", "name": "prod-partner", "vpc_ids": []string{"vpc-1", "vpc-partner"}},
+		},
+		[]interface{}{
+			map[string]interface{}{"id": "peer-3", "name": "prod-vendor", "vpc_ids": []string{"vpc-1", "vpc-vendor"}},
+		},
+	)
+	client := newFakeGodoClient(t, mux)
+
+	tests := []struct {
+		name           string
+		opts           scanOptions
+		wantPeerings   []string
+		wantUnresolved []string
+	}{
+		{
+			name: "disabled by default",
+			opts: scanOptions{},
+		},
+		{
+			name:           "unresolved peers warn",
+			opts:           scanOptions{ScanVPCPeerings: true},
+			wantUnresolved: []string{"vpc-partner", "vpc-vendor"},
+		},
+		{
+			name: "peering_ranges resolves cross-account peers",
+			opts: scanOptions{
+				ScanVPCPeerings: true,
+				PeeringRanges:   map[string]string{"vpc-partner": "172.16.0.0/16"},
+			},
+			wantPeerings:   []string{"172.16.0.0/16"},
+			wantUnresolved: []string{"vpc-vendor"},
+		},
+		{
+			name:           "ignored VPCs still resolve the account's side",
+			opts:           scanOptions{ScanVPCPeerings: true, IgnoreVPCs: true},
+			wantUnresolved: []string{"vpc-partner", "vpc-vendor"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _, diags := collectExistingCIDRs(context.Background(), client, tt.opts)
+			if diags.HasError() {
+				t.Fatalf("collectExistingCIDRs() diags = %v", diags)
+			}
+
+			var peerings []string
+			for _, e := range got {
+				if e.Source == sourceVPCPeering {
+					peerings = append(peerings, e.Network.String())
+				}
+				if tt.opts.IgnoreVPCs && e.Source == sourceVPC {
+					t.Errorf("unexpected VPC CIDR %s with ignore_existing_vpc_cidrs", e.String())
+				}
+			}
+			if strings.Join(peerings, ",") != strings.Join(tt.wantPeerings, ",") {
+				t.Errorf("peering CIDRs = %v, want %v", peerings, tt.wantPeerings)
+			}
+
+			if len(diags) != len(tt.wantUnresolved) {
+				t.Fatalf("got %d warnings, want %d: %v", len(diags), len(tt.wantUnresolved), diags)
+			}
+			for i, vpcID := range tt.wantUnresolved {
+				if diags[i].Severity != diag.Warning || !strings.Contains(diags[i].Detail, vpcID) {
+					t.Errorf("warning[%d] = %+v, want a warning mentioning %s", i, diags[i], vpcID)
+				}
+			}
+		})
+	}
+}
+
+func TestCollectVPCPeeringCIDRs_InvalidRange(t *testing.T) {
+	mux := http.NewServeMux()
+	servePages(mux, "/v2/vpc_peerings", "vpc_peerings", []interface{}{
+		map[string]interface{}{"id": "peer-1", "name": "partner", "vpc_ids": []string{"vpc-1", "vpc-partner"}},
+	})
+	client := newFakeGodoClient(t, mux)
+
+	vpcs := []existingCIDR{{Source: sourceVPC, ResourceID: "vpc-1"}}
+	_, _, err := collectVPCPeeringCIDRs(context.Background(), client, vpcs, map[string]string{"vpc-partner": "not-a-cidr"}, 0)
+	if err == nil {
+		t.Error("collectVPCPeeringCIDRs() expected error for invalid peering range, got nil")
+	}
+}
+
+func TestCollectExistingCIDRs_AllowPartialScan(t *testing.T) {
+	vpcs := []interface{}{
+		map[string]interface{}{"id": "vpc-1", "name": "prod", "ip_range": "10.0.0.0/16", "region": "nyc1"},
+	}
+
+	tests := []struct {
+		name        string
+		status      int
+		allow       bool
+		wantErr     bool
+		wantSkipped []string
+	}{
+		{
+			name:    "strict by default",
+			status:  http.StatusForbidden,
+			wantErr: true,
+		},
+		{
+			name:        "forbidden skipped",
+			status:      http.StatusForbidden,
+			allow:       true,
+			wantSkipped: []string{scanSourceKubernetes},
+		},
+		{
+			name:        "unauthorized skipped",
+			status:      http.StatusUnauthorized,
+			allow:       true,
+			wantSkipped: []string{scanSourceKubernetes},
+		},
+		{
+			name:    "server errors still fail",
+			status:  http.StatusInternalServerError,
+			allow:   true,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mux := http.NewServeMux()
+			servePages(mux, "/v2/vpcs", "vpcs", vpcs)
+			serveError(mux, "/v2/kubernetes/clusters", tt.status)
+			client := newFakeGodoClient(t, mux)
+
+			got, skipped, diags := collectExistingCIDRs(context.Background(), client, scanOptions{AllowPartialScan: tt.allow})
+			if diags.HasError() != tt.wantErr {
+				t.Fatalf("collectExistingCIDRs() diags = %v, wantErr %v", diags, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if len(got) != 1 || got[0].Network.String() != "10.0.0.0/16" {
+				t.Errorf("collectExistingCIDRs() = %v, want the VPC CIDR", got)
+			}
+			if strings.Join(skipped, ",") != strings.Join(tt.wantSkipped, ",") {
+				t.Errorf("skipped = %v, want %v", skipped, tt.wantSkipped)
+			}
+			if len(diags) != 1 || diags[0].Severity != diag.Warning || !strings.Contains(diags[0].Summary, "Kubernetes") {
+				t.Errorf("expected a single warning naming Kubernetes, got %v", diags)
+			}
+		})
+	}
+}
+
+func TestHostNetwork(t *testing.T) {
+	network, err := hostNetwork("203.0.113.10")
+	if err != nil {
+		t.Fatalf("hostNetwork() error = %v", err)
+	}
+	if network.String() != "203.0.113.10/32" {
+		t.Errorf("hostNetwork() = %s, want 203.0.113.10/32", network.String())
+	}
+
+	if _, err := hostNetwork("2001:db8::1"); err == nil {
+		t.Error("hostNetwork() expected error for IPv6 address")
+	}
+}
+
+func TestCollectExistingCIDRs_BYOIP(t *testing.T) {
+	tests := []struct {
+		name  string
+		serve func(mux *http.ServeMux)
+		want  []string
+	}{
+		{
+			name: "prefixes across pages",
+			serve: func(mux *http.ServeMux) {
+				servePages(mux, "/v2/byoip_prefixes", "byoip_prefixes",
+					[]interface{}{
+						map[string]interface{}{"uuid": "byoip-1", "prefix": "192.0.2.0/24", "region": "nyc3"},
+					},
+					[]interface{}{
+						map[string]interface{}{"uuid": "byoip-2", "prefix": "198.51.100.0/24", "region": "sfo3"},
+					},
+				)
+			},
+			want: []string{"192.0.2.0/24", "198.51.100.0/24"},
+		},
+		{
+			name: "empty",
+			serve: func(mux *http.ServeMux) {
+				servePages(mux, "/v2/byoip_prefixes", "byoip_prefixes", nil)
+			},
+		},
+		{
+			name: "feature not enabled",
+			serve: func(mux *http.ServeMux) {
+				serveError(mux, "/v2/byoip_prefixes", http.StatusNotFound)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mux := newFakeAccountMux(nil, nil)
+			tt.serve(mux)
+			client := newFakeGodoClient(t, mux)
+
+			got, _, diags := collectExistingCIDRs(context.Background(), client, scanOptions{ScanBYOIP: true})
+			if diags.HasError() {
+				t.Fatalf("collectExistingCIDRs() diags = %v", diags)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("collectExistingCIDRs() returned %d CIDRs, want %d: %v", len(got), len(tt.want), got)
+			}
+			for i, want := range tt.want {
+				if got[i].Network.String() != want || got[i].Source != sourceBYOIPPrefix {
+					t.Errorf("CIDR[%d] = %s, want %s from %s", i, got[i].String(), want, sourceBYOIPPrefix)
+				}
+			}
+		})
+	}
+}
+
+func TestCollectExistingCIDRs_BYOIPError(t *testing.T) {
+	mux := newFakeAccountMux(nil, nil)
+	serveError(mux, "/v2/byoip_prefixes", http.StatusInternalServerError)
+	client := newFakeGodoClient(t, mux)
+
+	if _, _, diags := collectExistingCIDRs(context.Background(), client, scanOptions{ScanBYOIP: true}); !diags.HasError() {
+		t.Error("collectExistingCIDRs() expected error, got none")
+	}
+}
+
+func TestCollectExistingCIDRs_Interconnects(t *testing.T) {
+	const attachmentsPath = "/v2/partner_network_connect/attachments"
+
+	mux := newFakeAccountMux(nil, nil)
+	servePages(mux, attachmentsPath, "partner_attachments",
+		[]interface{}{
+			map[string]interface{}{"id": "ic-zero", "name": "idle", "region": "nyc"},
+			map[string]interface{}{"id": "ic-one", "name": "dc1", "region": "nyc"},
+		},
+		[]interface{}{
+			map[string]interface{}{"id": "ic-many", "name": "dc2", "region": "sfo"},
+			map[string]interface{}{"id": "ic-hidden", "name": "legacy", "region": "sfo"},
+		},
+	)
+	servePages(mux, attachmentsPath+"/ic-zero/remote_routes", "remote_routes", nil)
+	servePages(mux, attachmentsPath+"/ic-one/remote_routes", "remote_routes",
+		[]interface{}{
+			map[string]interface{}{"cidr": "192.168.0.0/16"},
+		},
+	)
+	servePages(mux, attachmentsPath+"/ic-many/remote_routes", "remote_routes",
+		[]interface{}{
+			map[string]interface{}{"cidr": "172.16.0.0/16"},
+			map[string]interface{}{"cidr": "172.17.0.0/16"},
+		},
+		[]interface{}{
+			map[string]interface{}{"cidr": "172.18.0.0/16"},
+		},
+	)
+	serveError(mux, attachmentsPath+"/ic-hidden/remote_routes", http.StatusNotFound)
+	client := newFakeGodoClient(t, mux)
+
+	tests := []struct {
+		name string
+		opts scanOptions
+		want map[string]string
+	}{
+		{
+			name: "disabled by default",
+			opts: scanOptions{},
+			want: map[string]string{},
+		},
+		{
+			name: "zero, one and many routes across pages",
+			opts: scanOptions{ScanInterconnects: true},
+			want: map[string]string{
+				"192.168.0.0/16": "interconnect:dc1",
+				"172.16.0.0/16":  "interconnect:dc2",
+				"172.17.0.0/16":  "interconnect:dc2",
+				"172.18.0.0/16":  "interconnect:dc2",
+			},
+		},
+		{
+			name: "manual routes",
+			opts: scanOptions{InterconnectRoutes: []string{"10.200.0.0/16"}},
+			want: map[string]string{
+				"10.200.0.0/16": "interconnect:manual",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _, diags := collectExistingCIDRs(context.Background(), client, tt.opts)
+			if diags.HasError() {
+				t.Fatalf("collectExistingCIDRs() diags = %v", diags)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("collectExistingCIDRs() returned %d CIDRs, want %d: %v", len(got), len(tt.want), got)
+			}
+			for _, e := range got {
+				if source, ok := tt.want[e.Network.String()]; !ok || source != e.Source {
+					t.Errorf("unexpected CIDR %s", e.String())
+				}
+			}
+		})
+	}
+}
+
+func TestCollectExistingCIDRs_InterconnectRoutesError(t *testing.T) {
+	const attachmentsPath = "/v2/partner_network_connect/attachments"
+
+	mux := newFakeAccountMux(nil, nil)
+	servePages(mux, attachmentsPath, "partner_attachments", []interface{}{
+		map[string]interface{}{"id": "ic-1", "name": "dc1"},
+	})
+	serveError(mux, attachmentsPath+"/ic-1/remote_routes", http.StatusInternalServerError)
+	client := newFakeGodoClient(t, mux)
+
+	_, _, diags := collectExistingCIDRs(context.Background(), client, scanOptions{ScanInterconnects: true})
+	if !diags.HasError() {
+		t.Fatal("collectExistingCIDRs() expected error, got none")
+	}
+	if !strings.Contains(diags[0].Detail, "dc1") {
+		t.Errorf("error detail = %q, want it to name the attachment", diags[0].Detail)
+	}
+}
+
+func TestCollectTaggedResourceCIDRs(t *testing.T) {
+	mux := newFakeAccountMux(nil, []interface{}{
+		map[string]interface{}{"id": "k8s-1", "name": "tagged", "region": "sfo3", "vpc_uuid": "vpc-sfo3",
+			"cluster_subnet": "10.244.0.0/16", "service_subnet": "10.245.0.0/16", "tags": []string{"prod", "team-a"}},
+		map[string]interface{}{"id": "k8s-2", "name": "partial", "region": "nyc1", "vpc_uuid": "vpc-nyc1",
+			"cluster_subnet": "10.246.0.0/16", "tags": []string{"prod"}},
+	})
+	mux.HandleFunc("/v2/droplets", func(w http.ResponseWriter, r *http.Request) {
+		if got := r.URL.Query().Get("tag_name"); got != "prod" {
+			t.Errorf("droplets listed with tag_name %q, want prod", got)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"droplets": [
+			{"id": 1, "name": "web", "vpc_uuid": "vpc-ams3", "tags": ["team-a", "prod"]},
+			{"id": 2, "name": "db", "vpc_uuid": "vpc-sfo3", "tags": ["prod", "team-a"]},
+			{"id": 3, "name": "other", "vpc_uuid": "vpc-nyc1", "tags": ["prod"]}
+		], "links": {}, "meta": {"total": 3}}`)
+	})
+	vpcs := map[string]string{"vpc-sfo3": "10.10.0.0/16", "vpc-ams3": "10.20.0.0/16", "vpc-nyc1": "10.30.0.0/16"}
+	mux.HandleFunc("/v2/vpcs/", func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimPrefix(r.URL.Path, "/v2/vpcs/")
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"vpc": {"id": %q, "ip_range": %q}}`, id, vpcs[id])
+	})
+	client := newFakeGodoClient(t, mux)
+
+	got, err := collectTaggedResourceCIDRs(context.Background(), client, []string{"prod", "team-a"}, 0)
+	if err != nil {
+		t.Fatalf("collectTaggedResourceCIDRs() error = %v", err)
+	}
+	var gotCIDRs []string
+	for _, network := range got {
+		gotCIDRs = append(gotCIDRs, network.String())
+	}
+	want := []string{"10.244.0.0/16", "10.245.0.0/16", "10.10.0.0/16", "10.20.0.0/16"}
+	if strings.Join(gotCIDRs, ",") != strings.Join(want, ",") {
+		t.Errorf("collectTaggedResourceCIDRs() = %v, want %v", gotCIDRs, want)
+	}
+
+	existing, _, diags := collectExistingCIDRs(context.Background(), client, scanOptions{CheckTags: []string{"prod", "team-a"}})
+	if diags.HasError() {
+		t.Fatalf("collectExistingCIDRs() diags = %v", diags)
+	}
+	tagged := 0
+	for _, e := range existing {
+		if e.Source == sourceTaggedResource {
+			tagged++
+		}
+	}
+	if tagged != len(want) {
+		t.Errorf("collectExistingCIDRs() returned %d tagged CIDRs, want %d: %v", tagged, len(want), existing)
+	}
+}
+
+func TestDedupeExistingCIDRs(t *testing.T) {
+	existing := []existingCIDR{
+		{Network: mustParseTestCIDR(t, "10.0.0.0/16"), Source: sourceVPC, ResourceID: "vpc-1"},
+		{Network: mustParseTestCIDR(t, "10.0.16.0/20"), Source: sourceKubernetesClusterSubnet, ResourceID: "k8s-1"},
+		{Network: mustParseTestCIDR(t, "10.1.0.0/16"), Source: sourceVPC, ResourceID: "vpc-2"},
+		{Network: mustParseTestCIDR(t, "10.0.32.0/20"), Source: sourceKubernetesClusterSubnet, ResourceID: "k8s-2"},
+	}
+
+	got := dedupeExistingCIDRs(existing)
+	if len(got) != 2 {
+		t.Fatalf("dedupeExistingCIDRs() returned %d CIDRs, want 2: %v", len(got), got)
+	}
+	if got[0].Network.String() != "10.0.0.0/16" || got[1].Network.String() != "10.1.0.0/16" {
+		t.Errorf("dedupeExistingCIDRs() = %v, want [10.0.0.0/16 10.1.0.0/16]", got)
+	}
+
+	var ids []string
+	for _, r := range got[0].Resources() {
+		ids = append(ids, r.ResourceID)
+	}
+	if strings.Join(ids, ",") != "vpc-1,k8s-1,k8s-2" {
+		t.Errorf("Resources() = %v, want [vpc-1 k8s-1 k8s-2]", ids)
+	}
+	if len(got[1].Children) != 0 {
+		t.Errorf("10.1.0.0/16 children = %v, want none", got[1].Children)
+	}
+}
+
+func TestDedupeExistingCIDRs_NestedBeforeCovering(t *testing.T) {
+	existing := []existingCIDR{
+		{Network: mustParseTestCIDR(t, "10.0.0.0/24"), Source: sourceReservedIP, ResourceID: "small"},
+		{Network: mustParseTestCIDR(t, "10.0.0.0/16"), Source: sourceVPC, ResourceID: "vpc-1"},
+		{Network: mustParseTestCIDR(t, "10.0.0.0/16"), Source: sourceVPCPeering, ResourceID: "peer-1"},
+	}
+
+	got := dedupeExistingCIDRs(existing)
+	if len(got) != 1 {
+		t.Fatalf("dedupeExistingCIDRs() returned %d CIDRs, want 1: %v", len(got), got)
+	}
+	if got[0].ResourceID != "vpc-1" {
+		t.Errorf("covering CIDR = %s, want vpc-1", got[0].String())
+	}
+	if len(got[0].Children) != 2 {
+		t.Errorf("children = %v, want small and peer-1", got[0].Children)
+	}
+}
+
+func mustParseTestCIDR(t *testing.T, s string) *net.IPNet {
+	t.Helper()
+	network, err := cidr.ParseCIDR(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return network
+}
+
+func TestCollectExistingCIDRs_PageSize(t *testing.T) {
+	const attachmentsPath = "/v2/partner_network_connect/attachments"
+
+	mux := newFakeAccountMux(nil, nil)
+	servePages(mux, "/v2/reserved_ips", "reserved_ips", nil)
+	servePages(mux, "/v2/load_balancers", "load_balancers", nil)
+	servePages(mux, "/v2/byoip_prefixes", "byoip_prefixes", nil)
+	servePages(mux, "/v2/vpc_peerings", "vpc_peerings", nil)
+	servePages(mux, attachmentsPath, "partner_attachments", []interface{}{
+		map[string]interface{}{"id": "ic-one", "name": "dc1", "region": "nyc"},
+	})
+	servePages(mux, attachmentsPath+"/ic-one/remote_routes", "remote_routes", nil)
+
+	// Record the page size requested from each endpoint
+	var mu sync.Mutex
+	perPage := map[string]string{}
+	recording := http.NewServeMux()
+	recording.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		perPage[r.URL.Path] = r.URL.Query().Get("per_page")
+		mu.Unlock()
+		mux.ServeHTTP(w, r)
+	})
+
+	tests := []struct {
+		name     string
+		pageSize int
+		want     string
+	}{
+		{name: "default", pageSize: 0, want: "200"},
+		{name: "configured", pageSize: 25, want: "25"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			perPage = map[string]string{}
+			client := newFakeGodoClient(t, recording)
+
+			opts := scanOptions{
+				ScanReservedIPs:   true,
+				ScanLoadBalancers: true,
+				ScanBYOIP:         true,
+				ScanInterconnects: true,
+				ScanVPCPeerings:   true,
+				PageSize:          tt.pageSize,
+			}
+			if _, _, diags := collectExistingCIDRs(context.Background(), client, opts); diags.HasError() {
+				t.Fatalf("collectExistingCIDRs() diags = %v", diags)
+			}
+
+			for _, path := range []string{
+				"/v2/vpcs",
+				"/v2/kubernetes/clusters",
+				"/v2/reserved_ips",
+				"/v2/load_balancers",
+				"/v2/byoip_prefixes",
+				"/v2/vpc_peerings",
+				attachmentsPath,
+				attachmentsPath + "/ic-one/remote_routes",
+			} {
+				got, ok := perPage[path]
+				if !ok {
+					t.Errorf("%s was not requested", path)
+					continue
+				}
+				if got != tt.want {
+					t.Errorf("%s per_page = %q, want %q", path, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestCollectVPCCIDRs_ResumesAfterDroppedConnection(t *testing.T) {
+	mux := http.NewServeMux()
+	servePages(mux, "/v2/vpcs", "vpcs",
+		[]interface{}{
+			map[string]interface{}{"id": "vpc-1", "name": "a", "ip_range": "10.0.0.0/16", "region": "nyc1"},
+		},
+		[]interface{}{
+			map[string]interface{}{"id": "vpc-2", "name": "b", "ip_range": "10.1.0.0/16", "region": "nyc1"},
+		},
+	)
+
+	// Drop the connection on the first request for the second page
+	var dropped int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") == "2" && atomic.AddInt32(&dropped, 1) == 1 {
+			if conn, _, err := w.(http.Hijacker).Hijack(); err == nil {
+				conn.Close()
+			}
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	meta := newTestCombinedConfig(t, &config.Config{
+		APIEndpoint:      srv.URL + "/",
+		HTTPRetryMax:     2,
+		HTTPRetryWaitMin: 0.001,
+		HTTPRetryWaitMax: 0.001,
+	})
+
+	got, _, err := collectVPCCIDRs(context.Background(), meta.GodoClient(), 1)
+	if err != nil {
+		t.Fatalf("collectVPCCIDRs() error = %v", err)
+	}
+	if len(got) != 2 || got[0].ResourceID != "vpc-1" || got[1].ResourceID != "vpc-2" {
+		t.Errorf("collectVPCCIDRs() = %v, want vpc-1 and vpc-2 once each", got)
+	}
+	if n := atomic.LoadInt32(&dropped); n != 2 {
+		t.Errorf("second page requested %d times, want 2", n)
+	}
+}
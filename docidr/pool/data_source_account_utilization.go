@@ -0,0 +1,278 @@
+package pool
+
+import (
+	"context"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/DO-Solutions/terraform-provider-docidr/docidr/cidr"
+	"github.com/DO-Solutions/terraform-provider-docidr/docidr/config"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// DataSourceDocidrAccountUtilization returns the docidr_account_utilization data source schema.
+func DataSourceDocidrAccountUtilization() *schema.Resource {
+	return &schema.Resource{
+		ReadContext: dataSourceDocidrAccountUtilizationRead,
+
+		Schema: map[string]*schema.Schema{
+			"base_cidrs": {
+				Type:        schema.TypeList,
+				Required:    true,
+				MinItems:    1,
+				Description: "IPv4 ranges making up the private address plan.",
+				Elem: &schema.Schema{
+					Type:         schema.TypeString,
+					ValidateFunc: validation.IsCIDR,
+				},
+			},
+			"top_n": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      5,
+				ValidateFunc: validation.IntBetween(0, 100),
+				Description:  "Number of the largest consuming resources to return in top_consumers.",
+			},
+			"bases": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "Utilization of each base CIDR.",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"cidr": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "The base CIDR.",
+						},
+						"used_addresses": {
+							Type:        schema.TypeInt,
+							Computed:    true,
+							Description: "Number of addresses used by VPCs and Kubernetes clusters.",
+						},
+						"free_addresses": {
+							Type:        schema.TypeInt,
+							Computed:    true,
+							Description: "Number of addresses not in use.",
+						},
+						"used_percent": {
+							Type:        schema.TypeFloat,
+							Computed:    true,
+							Description: "Percentage of the base CIDR in use.",
+						},
+						"resource_count": {
+							Type:        schema.TypeInt,
+							Computed:    true,
+							Description: "Number of VPCs and Kubernetes subnets overlapping the base CIDR.",
+						},
+					},
+				},
+			},
+			"total_addresses": {
+				Type:        schema.TypeInt,
+				Computed:    true,
+				Description: "Number of addresses in all base CIDRs, counting overlapping bases once.",
+			},
+			"used_addresses": {
+				Type:        schema.TypeInt,
+				Computed:    true,
+				Description: "Number of addresses in use across all base CIDRs.",
+			},
+			"free_addresses": {
+				Type:        schema.TypeInt,
+				Computed:    true,
+				Description: "Number of addresses not in use across all base CIDRs.",
+			},
+			"used_percent": {
+				Type:        schema.TypeFloat,
+				Computed:    true,
+				Description: "Percentage of all base CIDRs in use.",
+			},
+			"resource_count": {
+				Type:        schema.TypeInt,
+				Computed:    true,
+				Description: "Number of VPCs and Kubernetes subnets overlapping any base CIDR.",
+			},
+			"top_consumers": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "The resources with the largest ranges overlapping any base CIDR, largest first.",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"cidr": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "The resource's range.",
+						},
+						"source": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "The kind of resource the range belongs to.",
+						},
+						"resource_id": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "The ID of the resource.",
+						},
+						"resource_name": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "The name of the resource.",
+						},
+						"address_count": {
+							Type:        schema.TypeInt,
+							Computed:    true,
+							Description: "Number of addresses in the range.",
+						},
+					},
+				},
+			},
+		},
+
+		Description: "Reports how much of the private address plan is used across the DigitalOcean account.",
+	}
+}
+
+func dataSourceDocidrAccountUtilizationRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	combined := meta.(*config.CombinedConfig)
+	client := combined.GodoClient()
+
+	baseCIDRs := expandStringList(d.Get("base_cidrs").([]interface{}))
+	bases, err := cidr.ParseCIDRs(baseCIDRs)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	for _, base := range bases {
+		if base.IP.To4() == nil {
+			return diag.Errorf("base_cidrs entry %s is not an IPv4 range", base.String())
+		}
+	}
+
+	existing, _, diags := collectExistingCIDRs(ctx, client, scanOptions{PageSize: combined.APIPageSize()})
+	if diags.HasError() {
+		return diags
+	}
+	existing = dedupeExistingCIDRs(existing)
+	used := existingNetworks(existing)
+	var resources []existingCIDR
+	for _, e := range existing {
+		resources = append(resources, e.Resources()...)
+	}
+
+	var perBase []interface{}
+	for _, base := range bases {
+		space := freeSpace(base, used)
+		total := cidr.AddressCount(base).Int64()
+		perBase = append(perBase, map[string]interface{}{
+			"cidr":           base.String(),
+			"used_addresses": int(total - space.FreeAddresses),
+			"free_addresses": int(space.FreeAddresses),
+			"used_percent":   space.UsedPercent,
+			"resource_count": len(overlappingResources(resources, []*net.IPNet{base})),
+		})
+	}
+	if err := d.Set("bases", perBase); err != nil {
+		return append(diags, diag.FromErr(err)...)
+	}
+
+	// Bases nested in another base would be counted twice in the totals
+	var total, free int64
+	for _, base := range outermostNetworks(bases) {
+		total += cidr.AddressCount(base).Int64()
+		free += freeSpace(base, used).FreeAddresses
+	}
+	consumers := overlappingResources(resources, bases)
+
+	if err := d.Set("total_addresses", int(total)); err != nil {
+		return append(diags, diag.FromErr(err)...)
+	}
+	if err := d.Set("used_addresses", int(total-free)); err != nil {
+		return append(diags, diag.FromErr(err)...)
+	}
+	if err := d.Set("free_addresses", int(free)); err != nil {
+		return append(diags, diag.FromErr(err)...)
+	}
+	if err := d.Set("used_percent", float64(total-free)/float64(total)*100); err != nil {
+		return append(diags, diag.FromErr(err)...)
+	}
+	if err := d.Set("resource_count", len(consumers)); err != nil {
+		return append(diags, diag.FromErr(err)...)
+	}
+	if err := d.Set("top_consumers", flattenTopConsumers(consumers, d.Get("top_n").(int))); err != nil {
+		return append(diags, diag.FromErr(err)...)
+	}
+	d.SetId(strings.Join(baseCIDRs, ","))
+
+	return diags
+}
+
+// overlappingResources returns the resources whose range overlaps any of the
+// given networks.
+func overlappingResources(resources []existingCIDR, networks []*net.IPNet) []existingCIDR {
+	var result []existingCIDR
+	for _, r := range resources {
+		for _, network := range networks {
+			if cidr.Classify(r.Network, network) != cidr.RelationshipNone {
+				result = append(result, r)
+				break
+			}
+		}
+	}
+	return result
+}
+
+// outermostNetworks returns the networks not contained in another of the
+// networks. Identical networks are returned once.
+func outermostNetworks(networks []*net.IPNet) []*net.IPNet {
+	var result []*net.IPNet
+	for i, network := range networks {
+		nested := false
+		for j, other := range networks {
+			if i == j {
+				continue
+			}
+			switch cidr.Classify(network, other) {
+			case cidr.RelationshipContained:
+				nested = true
+			case cidr.RelationshipEqual:
+				nested = j < i
+			}
+			if nested {
+				break
+			}
+		}
+		if !nested {
+			result = append(result, network)
+		}
+	}
+	return result
+}
+
+// flattenTopConsumers returns the n resources with the largest ranges, largest
+// first, in a schema-compatible format. Ties keep scan order.
+func flattenTopConsumers(resources []existingCIDR, n int) []interface{} {
+	sorted := make([]existingCIDR, len(resources))
+	copy(sorted, resources)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		iLen, _ := sorted[i].Network.Mask.Size()
+		jLen, _ := sorted[j].Network.Mask.Size()
+		return iLen < jLen
+	})
+	if len(sorted) > n {
+		sorted = sorted[:n]
+	}
+
+	result := make([]interface{}, 0, len(sorted))
+	for _, r := range sorted {
+		result = append(result, map[string]interface{}{
+			"cidr":          r.Network.String(),
+			"source":        r.Source,
+			"resource_id":   r.ResourceID,
+			"resource_name": r.ResourceName,
+			"address_count": int(cidr.AddressCount(r.Network).Int64()),
+		})
+	}
+	return result
+}
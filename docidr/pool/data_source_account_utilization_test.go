@@ -0,0 +1,121 @@
+package pool
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func TestDataSourceDocidrAccountUtilizationRead(t *testing.T) {
+	// The cluster subnet is nested in a VPC, and one base is nested in the
+	// other, so neither may be counted twice.
+	meta := newFakeCombinedConfig(t, newFakeAccountMux(
+		[]interface{}{
+			map[string]interface{}{"id": "vpc-1", "name": "prod", "ip_range": "10.0.0.0/16", "region": "nyc1"},
+			map[string]interface{}{"id": "vpc-2", "name": "dev", "ip_range": "10.1.0.0/24", "region": "nyc1"},
+		},
+		[]interface{}{
+			map[string]interface{}{"id": "k8s-1", "name": "apps", "region": "nyc1", "cluster_subnet": "10.0.16.0/20", "service_subnet": "172.16.0.0/20"},
+		},
+	))
+
+	d := schema.TestResourceDataRaw(t, DataSourceDocidrAccountUtilization().Schema, map[string]interface{}{
+		"base_cidrs": []interface{}{"10.0.0.0/15", "10.1.0.0/16"},
+		"top_n":      2,
+	})
+	if diags := dataSourceDocidrAccountUtilizationRead(context.Background(), d, meta); diags.HasError() {
+		t.Fatalf("Read() diags = %v", diags)
+	}
+
+	wantBases := []map[string]interface{}{
+		{"cidr": "10.0.0.0/15", "used_addresses": 65792, "free_addresses": 65280, "used_percent": 50.1953125, "resource_count": 3},
+		{"cidr": "10.1.0.0/16", "used_addresses": 256, "free_addresses": 65280, "used_percent": 0.390625, "resource_count": 1},
+	}
+	bases := d.Get("bases").([]interface{})
+	if len(bases) != len(wantBases) {
+		t.Fatalf("bases = %v, want %d entries", bases, len(wantBases))
+	}
+	for i, want := range wantBases {
+		got := bases[i].(map[string]interface{})
+		for k, v := range want {
+			if got[k] != v {
+				t.Errorf("bases[%d].%s = %v, want %v", i, k, got[k], v)
+			}
+		}
+	}
+
+	for k, want := range map[string]interface{}{
+		"total_addresses": 131072,
+		"used_addresses":  65792,
+		"free_addresses":  65280,
+		"used_percent":    50.1953125,
+		"resource_count":  3,
+	} {
+		if got := d.Get(k); got != want {
+			t.Errorf("%s = %v, want %v", k, got, want)
+		}
+	}
+
+	wantTop := []map[string]interface{}{
+		{"cidr": "10.0.0.0/16", "source": sourceVPC, "resource_id": "vpc-1", "address_count": 65536},
+		{"cidr": "10.0.16.0/20", "source": sourceKubernetesClusterSubnet, "resource_id": "k8s-1", "address_count": 4096},
+	}
+	top := d.Get("top_consumers").([]interface{})
+	if len(top) != len(wantTop) {
+		t.Fatalf("top_consumers = %v, want %d entries", top, len(wantTop))
+	}
+	for i, want := range wantTop {
+		got := top[i].(map[string]interface{})
+		for k, v := range want {
+			if got[k] != v {
+				t.Errorf("top_consumers[%d].%s = %v, want %v", i, k, got[k], v)
+			}
+		}
+	}
+}
+
+func TestDataSourceDocidrAccountUtilizationRead_Errors(t *testing.T) {
+	t.Run("IPv6 base", func(t *testing.T) {
+		meta := newFakeCombinedConfig(t, newFakeAccountMux([]interface{}{}, []interface{}{}))
+		d := schema.TestResourceDataRaw(t, DataSourceDocidrAccountUtilization().Schema, map[string]interface{}{
+			"base_cidrs": []interface{}{"fd00::/8"},
+		})
+		if diags := dataSourceDocidrAccountUtilizationRead(context.Background(), d, meta); !diags.HasError() {
+			t.Error("expected an error for an IPv6 base CIDR")
+		}
+	})
+
+	t.Run("scan error", func(t *testing.T) {
+		mux := http.NewServeMux()
+		serveError(mux, "/v2/vpcs", http.StatusInternalServerError)
+		d := schema.TestResourceDataRaw(t, DataSourceDocidrAccountUtilization().Schema, map[string]interface{}{
+			"base_cidrs": []interface{}{"10.0.0.0/8"},
+		})
+		if diags := dataSourceDocidrAccountUtilizationRead(context.Background(), d, newFakeCombinedConfig(t, mux)); !diags.HasError() {
+			t.Error("expected an error when the account can't be scanned")
+		}
+	})
+}
+
+func TestOutermostNetworks(t *testing.T) {
+	networks := []*net.IPNet{
+		mustParseTestCIDR(t, "10.1.0.0/16"),
+		mustParseTestCIDR(t, "10.0.0.0/15"),
+		mustParseTestCIDR(t, "192.168.0.0/16"),
+		mustParseTestCIDR(t, "192.168.0.0/16"),
+	}
+
+	got := outermostNetworks(networks)
+	want := []string{"10.0.0.0/15", "192.168.0.0/16"}
+	if len(got) != len(want) {
+		t.Fatalf("outermostNetworks() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i].String() != want[i] {
+			t.Errorf("outermostNetworks()[%d] = %s, want %s", i, got[i], want[i])
+		}
+	}
+}
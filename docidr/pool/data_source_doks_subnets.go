@@ -0,0 +1,130 @@
+package pool
+
+import (
+	"context"
+	"log"
+
+	"github.com/DO-Solutions/terraform-provider-docidr/docidr/cidr"
+	"github.com/DO-Solutions/terraform-provider-docidr/docidr/config"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// doksDefaultSubnets are the cluster and service subnets DOKS assigns when a
+// cluster is created without explicit subnets.
+var doksDefaultSubnets = []string{"10.244.0.0/16", "10.245.0.0/16"}
+
+// DataSourceDocidrDOKSSubnets returns the docidr_doks_subnets data source schema.
+func DataSourceDocidrDOKSSubnets() *schema.Resource {
+	return &schema.Resource{
+		ReadContext: dataSourceDocidrDOKSSubnetsRead,
+
+		Schema: map[string]*schema.Schema{
+			"base_cidr": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      defaultBaseCIDR,
+				ValidateFunc: validation.IsCIDR,
+				Description:  "The parent CIDR range the subnets are chosen from.",
+			},
+			"cluster_prefix_length": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      20,
+				ValidateFunc: validation.IntBetween(16, 24),
+				Description:  "Prefix length of the cluster (pod) subnet.",
+			},
+			"service_prefix_length": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      22,
+				ValidateFunc: validation.IntBetween(16, 24),
+				Description:  "Prefix length of the service subnet.",
+			},
+			"region": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "Only consider VPCs and clusters in this region when scanning the account.",
+			},
+			"exclude": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				Description: "Additional CIDR ranges the subnets must not overlap.",
+				Elem: &schema.Schema{
+					Type:         schema.TypeString,
+					ValidateFunc: validation.IsCIDR,
+				},
+			},
+			"cluster_subnet": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "Recommended cluster subnet.",
+			},
+			"service_subnet": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "Recommended service subnet.",
+			},
+		},
+
+		Description: "Recommends non-conflicting cluster and service subnets for a new DOKS cluster.",
+	}
+}
+
+func dataSourceDocidrDOKSSubnetsRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	combined := meta.(*config.CombinedConfig)
+	client := combined.GodoClient()
+
+	exclusions, err := cidr.ParseCIDRs(append(append([]string{}, doksDefaultSubnets...), expandStringList(d.Get("exclude").([]interface{}))...))
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	existing, _, diags := collectExistingCIDRs(ctx, client, scanOptions{PageSize: combined.APIPageSize()})
+	if diags.HasError() {
+		return diags
+	}
+	region := d.Get("region").(string)
+	for _, e := range existing {
+		if region != "" && e.Region != "" && e.Region != region {
+			continue
+		}
+		log.Printf("[DEBUG] Excluding %s", e.String())
+		exclusions = append(exclusions, e.Network)
+	}
+
+	allocator, err := cidr.NewAllocator(d.Get("base_cidr").(string))
+	if err != nil {
+		return append(diags, diag.Errorf("Error creating CIDR allocator: %s", err)...)
+	}
+
+	results, err := allocator.Allocate([]cidr.AllocationRequest{
+		{Name: "cluster_subnet", PrefixLength: d.Get("cluster_prefix_length").(int)},
+		{Name: "service_subnet", PrefixLength: d.Get("service_prefix_length").(int)},
+	}, exclusions)
+	if err != nil {
+		return append(diags, diag.Errorf("Error allocating DOKS subnets: %s", err)...)
+	}
+
+	if err := d.Set("cluster_subnet", results["cluster_subnet"]); err != nil {
+		return append(diags, diag.FromErr(err)...)
+	}
+	if err := d.Set("service_subnet", results["service_subnet"]); err != nil {
+		return append(diags, diag.FromErr(err)...)
+	}
+	d.SetId(results["cluster_subnet"] + "," + results["service_subnet"])
+
+	return append(diags, unreservedRecommendationWarning("docidr_doks_subnets"))
+}
+
+// unreservedRecommendationWarning explains that a data source recommendation
+// is recomputed on every read and is not reserved.
+func unreservedRecommendationWarning(dataSource string) diag.Diagnostic {
+	return diag.Diagnostic{
+		Severity: diag.Warning,
+		Summary:  "Recommended CIDRs are not reserved",
+		Detail: dataSource + " recomputes its result on every plan. Once the recommended ranges are in use the result moves to the next free ranges, " +
+			"and anything created from it will be planned for replacement. Use a docidr_pool resource, or ignore_changes on the consuming resource, to keep the ranges stable.",
+	}
+}
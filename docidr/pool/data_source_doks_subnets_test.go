@@ -0,0 +1,123 @@
+package pool
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func TestDataSourceDocidrDOKSSubnetsRead(t *testing.T) {
+	crowded := newFakeAccountMux(
+		[]interface{}{
+			map[string]interface{}{"id": "vpc-1", "name": "prod", "ip_range": "10.0.0.0/16", "region": "nyc1"},
+			map[string]interface{}{"id": "vpc-2", "name": "staging", "ip_range": "10.1.0.0/17", "region": "nyc1"},
+			map[string]interface{}{"id": "vpc-3", "name": "dr", "ip_range": "10.1.160.0/19", "region": "sfo3"},
+		},
+		[]interface{}{
+			map[string]interface{}{"id": "k8s-1", "name": "apps", "region": "nyc1", "cluster_subnet": "10.1.128.0/20", "service_subnet": "10.1.144.0/22"},
+		},
+	)
+	crowdedMeta := newFakeCombinedConfig(t, crowded)
+	emptyMeta := newFakeCombinedConfig(t, newFakeAccountMux([]interface{}{}, []interface{}{}))
+
+	tests := []struct {
+		name        string
+		raw         map[string]interface{}
+		empty       bool
+		wantCluster string
+		wantService string
+	}{
+		{
+			name:        "crowded account",
+			raw:         map[string]interface{}{"base_cidr": "10.0.0.0/14"},
+			wantCluster: "10.1.192.0/20",
+			wantService: "10.1.148.0/22",
+		},
+		{
+			name:        "other regions ignored",
+			raw:         map[string]interface{}{"base_cidr": "10.0.0.0/14", "region": "nyc1"},
+			wantCluster: "10.1.160.0/20",
+			wantService: "10.1.148.0/22",
+		},
+		{
+			name:        "exclusions",
+			raw:         map[string]interface{}{"base_cidr": "10.0.0.0/14", "exclude": []interface{}{"10.1.148.0/22"}},
+			wantCluster: "10.1.192.0/20",
+			wantService: "10.1.152.0/22",
+		},
+		{
+			name:        "custom prefix lengths",
+			raw:         map[string]interface{}{"base_cidr": "10.0.0.0/14", "cluster_prefix_length": 18, "service_prefix_length": 24},
+			wantCluster: "10.1.192.0/18",
+			wantService: "10.1.148.0/24",
+		},
+		{
+			name:        "DOKS default ranges",
+			raw:         map[string]interface{}{"base_cidr": "10.244.0.0/14"},
+			empty:       true,
+			wantCluster: "10.246.0.0/20",
+			wantService: "10.246.16.0/22",
+		},
+		{
+			name:        "default base",
+			raw:         map[string]interface{}{},
+			empty:       true,
+			wantCluster: "10.0.0.0/20",
+			wantService: "10.0.16.0/22",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			meta := crowdedMeta
+			if tt.empty {
+				meta = emptyMeta
+			}
+			d := schema.TestResourceDataRaw(t, DataSourceDocidrDOKSSubnets().Schema, tt.raw)
+
+			diags := dataSourceDocidrDOKSSubnetsRead(context.Background(), d, meta)
+			if diags.HasError() {
+				t.Fatalf("Read() diags = %v", diags)
+			}
+			if got := d.Get("cluster_subnet").(string); got != tt.wantCluster {
+				t.Errorf("cluster_subnet = %s, want %s", got, tt.wantCluster)
+			}
+			if got := d.Get("service_subnet").(string); got != tt.wantService {
+				t.Errorf("service_subnet = %s, want %s", got, tt.wantService)
+			}
+			if len(diags) != 1 || diags[0].Severity != diag.Warning {
+				t.Errorf("diags = %v, want a single stability warning", diags)
+			}
+		})
+	}
+}
+
+func TestDataSourceDocidrDOKSSubnetsRead_Errors(t *testing.T) {
+	t.Run("scan error", func(t *testing.T) {
+		mux := http.NewServeMux()
+		serveError(mux, "/v2/vpcs", http.StatusInternalServerError)
+		meta := newFakeCombinedConfig(t, mux)
+
+		d := schema.TestResourceDataRaw(t, DataSourceDocidrDOKSSubnets().Schema, map[string]interface{}{})
+		if diags := dataSourceDocidrDOKSSubnetsRead(context.Background(), d, meta); !diags.HasError() {
+			t.Error("Read() expected error, got none")
+		}
+	})
+
+	t.Run("no space", func(t *testing.T) {
+		meta := newFakeCombinedConfig(t, newFakeAccountMux(
+			[]interface{}{
+				map[string]interface{}{"id": "vpc-1", "name": "prod", "ip_range": "10.0.0.0/16", "region": "nyc1"},
+			},
+			[]interface{}{},
+		))
+
+		d := schema.TestResourceDataRaw(t, DataSourceDocidrDOKSSubnets().Schema, map[string]interface{}{"base_cidr": "10.0.0.0/16"})
+		if diags := dataSourceDocidrDOKSSubnetsRead(context.Background(), d, meta); !diags.HasError() {
+			t.Error("Read() expected error, got none")
+		}
+	})
+}
@@ -0,0 +1,200 @@
+package pool
+
+import (
+	"context"
+	"errors"
+
+	"github.com/DO-Solutions/terraform-provider-docidr/docidr/cidr"
+	"github.com/DO-Solutions/terraform-provider-docidr/docidr/config"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// poolPlanResourceOnlyAttributes are the docidr_pool arguments that only
+// matter to a pool that exists, so docidr_pool_plan doesn't accept them.
+var poolPlanResourceOnlyAttributes = map[string]bool{
+	"audit_log_file":          true,
+	"auto_tag_allocations":    true,
+	"external_allocation_api": true,
+	"migrate_to_base":         true,
+	"netbox_defaults":         true,
+	"plan_only":               true,
+	"stable_allocation":       true,
+	"telemetry":               true,
+	"use_ipv6_ula_base":       true,
+}
+
+// poolPlanAttributes are the computed docidr_pool attributes that
+// docidr_pool_plan also returns.
+var poolPlanAttributes = []string{"allocations", "allocations_first_usable", "allocations_last_usable", "allocations_reverse_zones", "allocation_hash_ids", "allocations_cidrsubnet", "allocation_prefix_lengths", "region_cidrs", "summary", "utilization_percent", "utilization_breakdown", "scan_report"}
+
+// DataSourceDocidrPoolPlan returns the docidr_pool_plan data source schema.
+func DataSourceDocidrPoolPlan() *schema.Resource {
+	return &schema.Resource{
+		ReadContext: dataSourceDocidrPoolPlanRead,
+
+		Schema: poolPlanSchema(),
+
+		Description: "Previews the CIDRs a docidr_pool with the same arguments would allocate, without reserving them.",
+	}
+}
+
+// poolPlanSchema returns the arguments of docidr_pool, less those that only
+// matter to a pool that exists, and the computed attributes describing the
+// allocation. The schemas are shared with the resource so the two accept the
+// same configuration.
+func poolPlanSchema() map[string]*schema.Schema {
+	result := make(map[string]*schema.Schema)
+	for key, s := range poolSchema() {
+		if (!s.Optional && !s.Required) || poolPlanResourceOnlyAttributes[key] {
+			continue
+		}
+		copied := *s
+		copied.ForceNew = false
+		result[key] = &copied
+	}
+	for _, key := range poolPlanAttributes {
+		result[key] = poolSchema()[key]
+	}
+	return result
+}
+
+// poolPlanGet reads the pool configuration from d. The docidr_pool attributes
+// the data source doesn't have read as their zero value, as they do for a new
+// pool.
+func poolPlanGet(d *schema.ResourceData) func(string) interface{} {
+	resourceSchema := poolSchema()
+	planSchema := poolPlanSchema()
+	return func(key string) interface{} {
+		if _, ok := planSchema[key]; !ok {
+			return resourceSchema[key].ZeroValue()
+		}
+		return d.Get(key)
+	}
+}
+
+func dataSourceDocidrPoolPlanRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	combined := meta.(*config.CombinedConfig)
+	ctx, metrics := config.WithAPIMetrics(ctx)
+	get := poolPlanGet(d)
+
+	if err := validatePoolPlanConfig(get, combined); err != nil {
+		requests := expandAllocations(get("allocation").([]interface{}), get("auto_generate_names").(bool))
+		return allocationErrorDiagnostics(err.Error(), err, requests)
+	}
+
+	// The same allocation a docidr_pool makes at apply time, so identical
+	// configuration and account snapshots give identical results
+	allocation, diags := allocatePool(ctx, get, combined)
+	if diags.HasError() {
+		return diags
+	}
+	allocation.Report.APIMetrics = metrics.Summary()
+	baseCIDR, results := allocation.BaseCIDR, allocation.Results
+	diags = append(diags, routeTableWarnings(ctx, combined.HTTPClient(), get("validate_against_asn_route_table").(string), results)...)
+
+	d.SetId(poolID(get, baseCIDR, allocation.Requests, allocation.ExclusionsFileHash))
+
+	if err := d.Set("base_cidr", baseCIDR); err != nil {
+		return append(diags, diag.FromErr(err)...)
+	}
+	if err := d.Set("allocations", flattenAllocations(results)); err != nil {
+		return append(diags, diag.FromErr(err)...)
+	}
+	if err := setUsableHosts(d, flattenAllocations(results)); err != nil {
+		return append(diags, diag.FromErr(err)...)
+	}
+	if err := d.Set("allocations_reverse_zones", flattenReverseZones(flattenAllocations(results))); err != nil {
+		return append(diags, diag.FromErr(err)...)
+	}
+	if err := d.Set("allocation_hash_ids", flattenAllocationHashIDs(d.Id(), flattenAllocations(results))); err != nil {
+		return append(diags, diag.FromErr(err)...)
+	}
+	cidrSubnets, cidrSubnetWarnings := flattenCIDRSubnets(baseCIDR, results, allocation.Requests)
+	diags = append(diags, cidrSubnetWarnings...)
+	if err := d.Set("allocations_cidrsubnet", cidrSubnets); err != nil {
+		return append(diags, diag.FromErr(err)...)
+	}
+	if err := d.Set("allocation_prefix_lengths", flattenPrefixLengths(allocation.Requests)); err != nil {
+		return append(diags, diag.FromErr(err)...)
+	}
+	if err := d.Set("region_cidrs", flattenAllocations(groupAllocationsByRegion(results, allocation.Requests))); err != nil {
+		return append(diags, diag.FromErr(err)...)
+	}
+	if err := d.Set("summary", cidr.FormatCIDRTree(allocation.Allocator.BaseCIDR(), results)); err != nil {
+		return append(diags, diag.FromErr(err)...)
+	}
+	if err := d.Set("scan_report", flattenScanReport(allocation.Report)); err != nil {
+		return append(diags, diag.FromErr(err)...)
+	}
+
+	// Allocations with their own base_cidr don't use the pool's base
+	inBase := poolBaseAllocations(results, allocation.Requests)
+	utilization, err := cidr.Utilization(baseCIDR, inBase)
+	if err != nil {
+		return append(diags, diag.Errorf("Error calculating utilization: %s", err)...)
+	}
+	if err := d.Set("utilization_percent", utilization); err != nil {
+		return append(diags, diag.FromErr(err)...)
+	}
+	breakdown, err := cidr.UtilizationBreakdown(baseCIDR, inBase)
+	if err != nil {
+		return append(diags, diag.Errorf("Error calculating utilization: %s", err)...)
+	}
+	if err := d.Set("utilization_breakdown", breakdown); err != nil {
+		return append(diags, diag.FromErr(err)...)
+	}
+
+	return diags
+}
+
+// validatePoolPlanConfig makes the checks docidr_pool makes at plan time that
+// allocatePool doesn't repeat. Data sources are read with their whole
+// configuration known, so none of them are skipped.
+func validatePoolPlanConfig(get func(string) interface{}, combined *config.CombinedConfig) error {
+	if get("skip_api_query").(bool) && get("detect_base_cidr_from_region").(bool) {
+		return errors.New("skip_api_query can't be used with detect_base_cidr_from_region, which queries the DigitalOcean API")
+	}
+
+	allocations := get("allocation").([]interface{})
+	if err := validateAllocationCount(len(allocations), get("allocation_count_limit").(int)); err != nil {
+		return err
+	}
+	if err := validateAllocationNamesSet(allocations, get("auto_generate_names").(bool)); err != nil {
+		return err
+	}
+	if err := validateUniqueAllocationNames(allocations); err != nil {
+		return err
+	}
+	if err := validateSingleHub(allocations); err != nil {
+		return err
+	}
+	if err := validateAllocationNamesRegex(get("allocation_names_regex").(string), allocations); err != nil {
+		return err
+	}
+	if err := validateAllocationSizesSet(allocations); err != nil {
+		return err
+	}
+
+	baseCIDR := ""
+	if !get("detect_base_cidr_from_region").(bool) {
+		baseCIDR = get("base_cidr").(string)
+		if space := get("address_space").(string); space != "" {
+			baseCIDR = addressSpaces[space]
+		}
+		if baseCIDR == "" {
+			baseCIDR = defaultBaseCIDR
+		}
+	}
+	blocks, err := resolveAllocationSizes(allocations, combined.SizePresets(), baseCIDR)
+	if err != nil {
+		return err
+	}
+	if err := validatePrefixLengthBounds(blocks, get("min_prefix_length").(int), get("max_prefix_length").(int)); err != nil {
+		return err
+	}
+	if baseCIDR != "" {
+		return validateBaseCIDRVisibility(baseCIDR, expandAllocations(blocks, get("auto_generate_names").(bool)))
+	}
+	return nil
+}
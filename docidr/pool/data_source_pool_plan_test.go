@@ -0,0 +1,171 @@
+package pool
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/DO-Solutions/terraform-provider-docidr/docidr/cidr"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func TestDataSourceDocidrPoolPlanRead_MatchesResource(t *testing.T) {
+	mux := newFakeAccountMux(
+		[]interface{}{
+			map[string]interface{}{"id": "vpc-1", "name": "prod", "ip_range": "10.0.0.0/16", "region": "nyc1"},
+			map[string]interface{}{"id": "vpc-2", "name": "dev", "ip_range": "10.2.0.0/20", "region": "sfo3"},
+		},
+		[]interface{}{
+			map[string]interface{}{"id": "k8s-1", "name": "apps", "region": "nyc1", "cluster_subnet": "10.1.0.0/20", "service_subnet": "10.1.16.0/20"},
+		},
+	)
+
+	tests := []struct {
+		name string
+		raw  map[string]interface{}
+	}{
+		{
+			name: "first fit",
+			raw: map[string]interface{}{
+				"base_cidr": "10.0.0.0/8",
+				"allocation": []interface{}{
+					map[string]interface{}{"name": "vpc", "prefix_length": 16},
+					map[string]interface{}{"name": "k8s", "prefix_length": 20},
+					map[string]interface{}{"name": "lb", "prefix_length": 24},
+				},
+			},
+		},
+		{
+			name: "exclusions and size sort",
+			raw: map[string]interface{}{
+				"base_cidr":     "10.0.0.0/12",
+				"sort_strategy": SortStrategyLargestFirst,
+				"exclude": []interface{}{
+					map[string]interface{}{"cidr": "10.3.0.0/16", "reason": "on-prem"},
+				},
+				"exclude_patterns": []interface{}{"10.4.*.*"},
+				"allocation": []interface{}{
+					map[string]interface{}{"name": "small", "prefix_length": 24},
+					map[string]interface{}{"name": "large", "prefix_length": 16},
+				},
+			},
+		},
+		{
+			name: "random placement",
+			raw: map[string]interface{}{
+				"address_space": AddressSpaceRFC1918_172,
+				"placement":     cidr.PlacementRandom,
+				"allocation": []interface{}{
+					map[string]interface{}{"name": "vpc", "prefix_length": 20},
+					map[string]interface{}{"name": "db", "new_bits": 8, "region": "nyc1"},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			meta := newFakeCombinedConfig(t, mux)
+			d := schema.TestResourceDataRaw(t, DataSourceDocidrPoolPlan().Schema, tt.raw)
+			if diags := dataSourceDocidrPoolPlanRead(context.Background(), d, meta); diags.HasError() {
+				t.Fatalf("Read() diags = %v", diags)
+			}
+
+			// Planning doesn't reserve anything for later pools
+			if got := meta.PoolAllocations(); len(got) != 0 {
+				t.Errorf("PoolAllocations() = %v after Read, want none", got)
+			}
+
+			state := applyPool(t, nil, tt.raw, newFakeCombinedConfig(t, mux))
+			pool := ResourceDocidrPool().Data(state)
+
+			if d.Id() != pool.Id() {
+				t.Errorf("id = %s, want the pool's %s", d.Id(), pool.Id())
+			}
+			for _, key := range []string{"base_cidr", "allocations", "allocations_first_usable", "allocations_last_usable", "allocations_reverse_zones", "allocation_hash_ids", "allocations_cidrsubnet", "allocation_prefix_lengths", "region_cidrs", "summary", "utilization_percent", "utilization_breakdown"} {
+				if got, want := d.Get(key), pool.Get(key); !reflect.DeepEqual(got, want) {
+					t.Errorf("%s = %v, want the pool's %v", key, got, want)
+				}
+			}
+			if len(d.Get("allocations").(map[string]interface{})) == 0 {
+				t.Error("allocations is empty")
+			}
+		})
+	}
+}
+
+func TestDataSourceDocidrPoolPlanRead_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     map[string]interface{}
+		wantErr string
+	}{
+		{
+			name: "duplicate names",
+			raw: map[string]interface{}{
+				"allocation": []interface{}{
+					map[string]interface{}{"name": "vpc", "prefix_length": 24},
+					map[string]interface{}{"name": "vpc", "prefix_length": 24},
+				},
+			},
+			wantErr: "duplicate",
+		},
+		{
+			name: "too many allocations",
+			raw: map[string]interface{}{
+				"allocation_count_limit": 1,
+				"allocation": []interface{}{
+					map[string]interface{}{"name": "a", "prefix_length": 24},
+					map[string]interface{}{"name": "b", "prefix_length": 24},
+				},
+			},
+			wantErr: "too many allocations",
+		},
+		{
+			name: "no size",
+			raw: map[string]interface{}{
+				"allocation": []interface{}{
+					map[string]interface{}{"name": "a"},
+				},
+			},
+			wantErr: "one of prefix_length, size or new_bits must be set",
+		},
+		{
+			name: "skip_api_query with detect_base_cidr_from_region",
+			raw: map[string]interface{}{
+				"skip_api_query":               true,
+				"detect_base_cidr_from_region": true,
+				"region":                       "nyc1",
+				"allocation": []interface{}{
+					map[string]interface{}{"name": "a", "prefix_length": 24},
+				},
+			},
+			wantErr: "skip_api_query can't be used with detect_base_cidr_from_region",
+		},
+		{
+			name: "no space",
+			raw: map[string]interface{}{
+				"base_cidr": "10.0.0.0/24",
+				"allocation": []interface{}{
+					map[string]interface{}{"name": "a", "prefix_length": 16},
+				},
+			},
+			wantErr: "Error allocating CIDRs",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			meta := newFakeCombinedConfig(t, newFakeAccountMux([]interface{}{}, []interface{}{}))
+			d := schema.TestResourceDataRaw(t, DataSourceDocidrPoolPlan().Schema, tt.raw)
+			diags := dataSourceDocidrPoolPlanRead(context.Background(), d, meta)
+			if !diags.HasError() {
+				t.Fatalf("Read() diags = %v, want error containing %q", diags, tt.wantErr)
+			}
+			if !strings.Contains(diags[len(diags)-1].Summary, tt.wantErr) {
+				t.Errorf("Read() error = %q, want it to contain %q", diags[len(diags)-1].Summary, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package pool
+
+import (
+	"context"
+	"net"
+
+	"github.com/DO-Solutions/terraform-provider-docidr/docidr/cidr"
+	"github.com/DO-Solutions/terraform-provider-docidr/docidr/config"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// DataSourceDocidrRFC1918Free returns the docidr_rfc1918_free data source schema.
+func DataSourceDocidrRFC1918Free() *schema.Resource {
+	return &schema.Resource{
+		ReadContext: dataSourceDocidrRFC1918FreeRead,
+
+		Schema: map[string]*schema.Schema{
+			"prefix_length": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      16,
+				ValidateFunc: validation.IntBetween(8, 32),
+				Description:  "Prefix length of the block the recommended supernet must have room for.",
+			},
+			"supernets": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "Free space in each RFC 1918 supernet.",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"cidr": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "The RFC 1918 supernet.",
+						},
+						"free_addresses": {
+							Type:        schema.TypeInt,
+							Computed:    true,
+							Description: "Number of addresses not used by any VPC or Kubernetes cluster.",
+						},
+						"largest_free_block": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "The largest aligned CIDR block that is entirely free, or empty if the supernet is full.",
+						},
+						"used_percent": {
+							Type:        schema.TypeFloat,
+							Computed:    true,
+							Description: "Percentage of the supernet in use.",
+						},
+					},
+				},
+			},
+			"recommended": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The supernet with the largest free block that fits prefix_length, or empty if none fits.",
+			},
+		},
+
+		Description: "Reports the free space in each RFC 1918 range and recommends one for a new environment.",
+	}
+}
+
+// supernetFreeSpace summarizes the unused space in a supernet.
+type supernetFreeSpace struct {
+	Supernet         *net.IPNet
+	FreeAddresses    int64
+	LargestFreeBlock *net.IPNet
+	UsedPercent      float64
+}
+
+func dataSourceDocidrRFC1918FreeRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	combined := meta.(*config.CombinedConfig)
+	client := combined.GodoClient()
+
+	existing, _, diags := collectExistingCIDRs(ctx, client, scanOptions{PageSize: combined.APIPageSize()})
+	if diags.HasError() {
+		return diags
+	}
+	used := existingNetworks(existing)
+
+	var spaces []supernetFreeSpace
+	for _, block := range cidr.RFC1918Blocks {
+		supernet, err := cidr.ParseCIDR(block)
+		if err != nil {
+			return append(diags, diag.FromErr(err)...)
+		}
+		spaces = append(spaces, freeSpace(supernet, used))
+	}
+
+	if err := d.Set("supernets", flattenSupernetFreeSpace(spaces)); err != nil {
+		return append(diags, diag.FromErr(err)...)
+	}
+	if err := d.Set("recommended", recommendSupernet(spaces, d.Get("prefix_length").(int))); err != nil {
+		return append(diags, diag.FromErr(err)...)
+	}
+	d.SetId("rfc1918")
+
+	return diags
+}
+
+// freeSpace summarizes the space in supernet that isn't used.
+func freeSpace(supernet *net.IPNet, used []*net.IPNet) supernetFreeSpace {
+	result := supernetFreeSpace{Supernet: supernet}
+	largest := 33
+	for _, block := range cidr.FreeBlocks(supernet, used) {
+		result.FreeAddresses += cidr.AddressCount(block).Int64()
+		if ones, _ := block.Mask.Size(); ones < largest {
+			largest = ones
+			result.LargestFreeBlock = block
+		}
+	}
+
+	total := cidr.AddressCount(supernet).Int64()
+	result.UsedPercent = float64(total-result.FreeAddresses) / float64(total) * 100
+	return result
+}
+
+// recommendSupernet returns the supernet whose largest free block is biggest,
+// provided it can hold a block of prefixLength. Ties go to the supernet with
+// more free addresses, then to the first in RFC 1918 order.
+func recommendSupernet(spaces []supernetFreeSpace, prefixLength int) string {
+	var best *supernetFreeSpace
+	for i := range spaces {
+		s := &spaces[i]
+		if s.LargestFreeBlock == nil {
+			continue
+		}
+		ones, _ := s.LargestFreeBlock.Mask.Size()
+		if ones > prefixLength {
+			continue
+		}
+		if best == nil {
+			best = s
+			continue
+		}
+		bestOnes, _ := best.LargestFreeBlock.Mask.Size()
+		if ones < bestOnes || (ones == bestOnes && s.FreeAddresses > best.FreeAddresses) {
+			best = s
+		}
+	}
+
+	if best == nil {
+		return ""
+	}
+	return best.Supernet.String()
+}
+
+// flattenSupernetFreeSpace converts supernet summaries to a schema-compatible format.
+func flattenSupernetFreeSpace(spaces []supernetFreeSpace) []interface{} {
+	result := make([]interface{}, 0, len(spaces))
+	for _, s := range spaces {
+		largest := ""
+		if s.LargestFreeBlock != nil {
+			largest = s.LargestFreeBlock.String()
+		}
+		result = append(result, map[string]interface{}{
+			"cidr":               s.Supernet.String(),
+			"free_addresses":     int(s.FreeAddresses),
+			"largest_free_block": largest,
+			"used_percent":       s.UsedPercent,
+		})
+	}
+	return result
+}
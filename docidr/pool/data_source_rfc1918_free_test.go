@@ -0,0 +1,156 @@
+package pool
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DO-Solutions/terraform-provider-docidr/docidr/config"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// fragmentedTenVPCs returns a /24 VPC at the start of every /12 in 10.0.0.0/8,
+// leaving most of the range free but no free block larger than a /13.
+func fragmentedTenVPCs() []interface{} {
+	var vpcs []interface{}
+	for i := 0; i < 16; i++ {
+		vpcs = append(vpcs, map[string]interface{}{
+			"id":       fmt.Sprintf("vpc-%d", i),
+			"name":     fmt.Sprintf("vpc-%d", i),
+			"ip_range": fmt.Sprintf("10.%d.0.0/24", i*16),
+			"region":   "nyc1",
+		})
+	}
+	return vpcs
+}
+
+func TestDataSourceDocidrRFC1918FreeRead(t *testing.T) {
+	tests := []struct {
+		name            string
+		vpcs            []interface{}
+		clusters        []interface{}
+		prefixLength    int
+		wantRecommended string
+		wantSupernets   []map[string]interface{}
+	}{
+		{
+			name:            "empty account",
+			prefixLength:    16,
+			wantRecommended: "10.0.0.0/8",
+			wantSupernets: []map[string]interface{}{
+				{"cidr": "10.0.0.0/8", "free_addresses": 16777216, "largest_free_block": "10.0.0.0/8", "used_percent": 0.0},
+				{"cidr": "172.16.0.0/12", "free_addresses": 1048576, "largest_free_block": "172.16.0.0/12", "used_percent": 0.0},
+				{"cidr": "192.168.0.0/16", "free_addresses": 65536, "largest_free_block": "192.168.0.0/16", "used_percent": 0.0},
+			},
+		},
+		{
+			// 10.0.0.0/8 has by far the most free addresses and the lowest
+			// utilization, but 172.16.0.0/12 has the largest free block.
+			name:            "most free addresses is not most contiguous",
+			vpcs:            fragmentedTenVPCs(),
+			prefixLength:    12,
+			wantRecommended: "172.16.0.0/12",
+			wantSupernets: []map[string]interface{}{
+				{"cidr": "10.0.0.0/8", "free_addresses": 16773120, "largest_free_block": "10.8.0.0/13", "used_percent": 0.0244140625},
+				{"cidr": "172.16.0.0/12", "free_addresses": 1048576, "largest_free_block": "172.16.0.0/12", "used_percent": 0.0},
+			},
+		},
+		{
+			name:     "tie on largest block goes to more free addresses",
+			vpcs:     fragmentedTenVPCs(),
+			clusters: []interface{}{map[string]interface{}{"id": "k8s-1", "name": "apps", "region": "nyc1", "cluster_subnet": "172.16.0.0/24", "service_subnet": "192.168.0.0/24"}},
+			// Both 10.0.0.0/8 and 172.16.0.0/12 now have a free /13.
+			prefixLength:    16,
+			wantRecommended: "10.0.0.0/8",
+			wantSupernets: []map[string]interface{}{
+				{"cidr": "172.16.0.0/12", "largest_free_block": "172.24.0.0/13"},
+				{"cidr": "192.168.0.0/16", "free_addresses": 65280, "largest_free_block": "192.168.128.0/17", "used_percent": 0.390625},
+			},
+		},
+		{
+			name:            "nothing fits",
+			vpcs:            fragmentedTenVPCs(),
+			clusters:        []interface{}{map[string]interface{}{"id": "k8s-1", "name": "apps", "region": "nyc1", "cluster_subnet": "172.16.0.0/24", "service_subnet": "192.168.0.0/24"}},
+			prefixLength:    12,
+			wantRecommended: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vpcs, clusters := tt.vpcs, tt.clusters
+			if vpcs == nil {
+				vpcs = []interface{}{}
+			}
+			if clusters == nil {
+				clusters = []interface{}{}
+			}
+			meta := newFakeCombinedConfig(t, newFakeAccountMux(vpcs, clusters))
+			d := schema.TestResourceDataRaw(t, DataSourceDocidrRFC1918Free().Schema, map[string]interface{}{"prefix_length": tt.prefixLength})
+
+			if diags := dataSourceDocidrRFC1918FreeRead(context.Background(), d, meta); diags.HasError() {
+				t.Fatalf("Read() diags = %v", diags)
+			}
+
+			if got := d.Get("recommended").(string); got != tt.wantRecommended {
+				t.Errorf("recommended = %q, want %q", got, tt.wantRecommended)
+			}
+
+			supernets := map[string]map[string]interface{}{}
+			for _, s := range d.Get("supernets").([]interface{}) {
+				m := s.(map[string]interface{})
+				supernets[m["cidr"].(string)] = m
+			}
+			if len(supernets) != 3 {
+				t.Fatalf("supernets = %v, want 3 entries", supernets)
+			}
+			for _, want := range tt.wantSupernets {
+				got := supernets[want["cidr"].(string)]
+				for k, v := range want {
+					if got[k] != v {
+						t.Errorf("%s %s = %v, want %v", want["cidr"], k, got[k], v)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestDataSourceDocidrRFC1918FreeRead_Error(t *testing.T) {
+	mux := http.NewServeMux()
+	serveError(mux, "/v2/vpcs", http.StatusInternalServerError)
+	meta := newFakeCombinedConfig(t, mux)
+
+	d := schema.TestResourceDataRaw(t, DataSourceDocidrRFC1918Free().Schema, map[string]interface{}{})
+	if diags := dataSourceDocidrRFC1918FreeRead(context.Background(), d, meta); !diags.HasError() {
+		t.Error("Read() expected error, got none")
+	}
+}
+
+func TestDataSourceDocidrRFC1918FreeRead_APIPageSize(t *testing.T) {
+	var perPage []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		perPage = append(perPage, r.URL.Query().Get("per_page"))
+		newFakeAccountMux(nil, nil).ServeHTTP(w, r)
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	meta := newTestCombinedConfig(t, &config.Config{APIEndpoint: srv.URL + "/", APIPageSize: 50})
+
+	d := schema.TestResourceDataRaw(t, DataSourceDocidrRFC1918Free().Schema, map[string]interface{}{})
+	if diags := dataSourceDocidrRFC1918FreeRead(context.Background(), d, meta); diags.HasError() {
+		t.Fatalf("Read() diags = %v", diags)
+	}
+
+	if len(perPage) == 0 {
+		t.Fatal("no API requests were made")
+	}
+	for _, got := range perPage {
+		if got != "50" {
+			t.Errorf("per_page = %q, want %q", got, "50")
+		}
+	}
+}
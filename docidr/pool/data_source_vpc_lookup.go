@@ -0,0 +1,155 @@
+package pool
+
+import (
+	"context"
+	"net"
+
+	"github.com/DO-Solutions/terraform-provider-docidr/docidr/cidr"
+	"github.com/DO-Solutions/terraform-provider-docidr/docidr/config"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// Relationships between a looked-up range and the range of its owner.
+const (
+	lookupRelationContains = "contains"
+	lookupRelationOverlaps = "overlaps"
+)
+
+// DataSourceDocidrVPCLookup returns the docidr_vpc_lookup data source schema.
+func DataSourceDocidrVPCLookup() *schema.Resource {
+	return &schema.Resource{
+		ReadContext: dataSourceDocidrVPCLookupRead,
+
+		Schema: map[string]*schema.Schema{
+			"cidr": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ExactlyOneOf: []string{"cidr", "ip"},
+				ValidateFunc: validation.IsCIDR,
+				Description:  "CIDR block to look up.",
+			},
+			"ip": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ExactlyOneOf: []string{"cidr", "ip"},
+				ValidateFunc: validation.IsIPv4Address,
+				Description:  "IP address to look up.",
+			},
+			"matches": {
+				Type:        schema.TypeList,
+				Computed:    true,
+				Description: "VPCs and Kubernetes clusters whose ranges contain or overlap the lookup.",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"resource_type": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "Type of the owning resource: `vpc` or `kubernetes_cluster`.",
+						},
+						"id": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "ID of the owning resource.",
+						},
+						"name": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "Name of the owning resource.",
+						},
+						"region": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "Region of the owning resource.",
+						},
+						"field": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "Range that matched: `ip_range`, `cluster_subnet`, or `service_subnet`.",
+						},
+						"cidr": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "The owning resource's range that matched.",
+						},
+						"relation": {
+							Type:        schema.TypeString,
+							Computed:    true,
+							Description: "`contains` if the owning range contains the lookup, otherwise `overlaps`.",
+						},
+					},
+				},
+			},
+		},
+
+		Description: "Looks up the VPCs and Kubernetes clusters that own a CIDR block or IP address.",
+	}
+}
+
+func dataSourceDocidrVPCLookupRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	combined := meta.(*config.CombinedConfig)
+	client := combined.GodoClient()
+
+	query := d.Get("cidr").(string)
+	if ip, ok := d.GetOk("ip"); ok {
+		query = ip.(string) + "/32"
+	}
+	target, err := cidr.ParseCIDR(query)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	existing, _, diags := collectExistingCIDRs(ctx, client, scanOptions{PageSize: combined.APIPageSize()})
+	if diags.HasError() {
+		return diags
+	}
+
+	if err := d.Set("matches", flattenLookupMatches(lookupCIDROwners(existing, target), target)); err != nil {
+		return append(diags, diag.FromErr(err)...)
+	}
+	d.SetId(target.String())
+
+	return diags
+}
+
+// lookupCIDROwners returns the existing CIDRs that overlap target, in scan order.
+func lookupCIDROwners(existing []existingCIDR, target *net.IPNet) []existingCIDR {
+	var result []existingCIDR
+	for _, e := range existing {
+		if _, ok := cidr.Intersection(e.Network, target); ok {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+// flattenLookupMatches converts matching existing CIDRs to a schema-compatible format.
+func flattenLookupMatches(matches []existingCIDR, target *net.IPNet) []interface{} {
+	result := make([]interface{}, 0, len(matches))
+	for _, m := range matches {
+		resourceType, field := "vpc", "ip_range"
+		switch m.Source {
+		case sourceKubernetesClusterSubnet:
+			resourceType, field = "kubernetes_cluster", "cluster_subnet"
+		case sourceKubernetesServiceSubnet:
+			resourceType, field = "kubernetes_cluster", "service_subnet"
+		}
+
+		relation := lookupRelationOverlaps
+		if cidr.ContainsNetwork(m.Network, target) {
+			relation = lookupRelationContains
+		}
+
+		result = append(result, map[string]interface{}{
+			"resource_type": resourceType,
+			"id":            m.ResourceID,
+			"name":          m.ResourceName,
+			"region":        m.Region,
+			"field":         field,
+			"cidr":          m.Network.String(),
+			"relation":      relation,
+		})
+	}
+	return result
+}
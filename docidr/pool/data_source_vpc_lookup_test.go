@@ -0,0 +1,94 @@
+package pool
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func TestDataSourceDocidrVPCLookupRead(t *testing.T) {
+	mux := newFakeAccountMux(
+		[]interface{}{
+			map[string]interface{}{"id": "vpc-1", "name": "prod", "ip_range": "10.37.0.0/16", "region": "nyc1"},
+			map[string]interface{}{"id": "vpc-2", "name": "staging", "ip_range": "10.38.0.0/16", "region": "sfo3"},
+		},
+		[]interface{}{
+			map[string]interface{}{"id": "k8s-1", "name": "apps", "region": "nyc1", "cluster_subnet": "10.37.128.0/20", "service_subnet": "10.100.0.0/16"},
+		},
+	)
+	meta := newFakeCombinedConfig(t, mux)
+
+	tests := []struct {
+		name string
+		raw  map[string]interface{}
+		want []map[string]string
+	}{
+		{
+			name: "contained by VPC and cluster subnet",
+			raw:  map[string]interface{}{"ip": "10.37.130.5"},
+			want: []map[string]string{
+				{"resource_type": "vpc", "id": "vpc-1", "name": "prod", "region": "nyc1", "field": "ip_range", "cidr": "10.37.0.0/16", "relation": "contains"},
+				{"resource_type": "kubernetes_cluster", "id": "k8s-1", "name": "apps", "region": "nyc1", "field": "cluster_subnet", "cidr": "10.37.128.0/20", "relation": "contains"},
+			},
+		},
+		{
+			name: "overlaps several ranges",
+			raw:  map[string]interface{}{"cidr": "10.36.0.0/14"},
+			want: []map[string]string{
+				{"resource_type": "vpc", "id": "vpc-1", "field": "ip_range", "cidr": "10.37.0.0/16", "relation": "overlaps"},
+				{"resource_type": "vpc", "id": "vpc-2", "field": "ip_range", "cidr": "10.38.0.0/16", "relation": "overlaps"},
+				{"resource_type": "kubernetes_cluster", "id": "k8s-1", "field": "cluster_subnet", "cidr": "10.37.128.0/20", "relation": "overlaps"},
+			},
+		},
+		{
+			name: "service subnet",
+			raw:  map[string]interface{}{"cidr": "10.100.4.0/24"},
+			want: []map[string]string{
+				{"resource_type": "kubernetes_cluster", "id": "k8s-1", "field": "service_subnet", "cidr": "10.100.0.0/16", "relation": "contains"},
+			},
+		},
+		{
+			name: "no match",
+			raw:  map[string]interface{}{"cidr": "192.168.0.0/24"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := schema.TestResourceDataRaw(t, DataSourceDocidrVPCLookup().Schema, tt.raw)
+
+			if diags := dataSourceDocidrVPCLookupRead(context.Background(), d, meta); diags.HasError() {
+				t.Fatalf("Read() diags = %v", diags)
+			}
+			if d.Id() == "" {
+				t.Error("expected ID to be set")
+			}
+
+			matches := d.Get("matches").([]interface{})
+			if len(matches) != len(tt.want) {
+				t.Fatalf("matches = %v, want %d matches", matches, len(tt.want))
+			}
+			for i, want := range tt.want {
+				got := matches[i].(map[string]interface{})
+				for k, v := range want {
+					if got[k] != v {
+						t.Errorf("matches[%d].%s = %v, want %v", i, k, got[k], v)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestDataSourceDocidrVPCLookupRead_Error(t *testing.T) {
+	mux := http.NewServeMux()
+	serveError(mux, "/v2/vpcs", http.StatusInternalServerError)
+	meta := newFakeCombinedConfig(t, mux)
+
+	d := schema.TestResourceDataRaw(t, DataSourceDocidrVPCLookup().Schema, map[string]interface{}{"cidr": "10.0.0.0/16"})
+	if diags := dataSourceDocidrVPCLookupRead(context.Background(), d, meta); !diags.HasError() {
+		t.Error("Read() expected error, got none")
+	}
+}
@@ -0,0 +1,292 @@
+package pool
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/DO-Solutions/terraform-provider-docidr/docidr/cidr"
+	"github.com/DO-Solutions/terraform-provider-docidr/docidr/config"
+	fwdiag "github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/ephemeral"
+	"github.com/hashicorp/terraform-plugin-framework/ephemeral/schema"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+)
+
+// scanRenewInterval is how often Terraform renews an open docidr_scan while
+// it is still in use. Each renewal scans the account again, to warn when the
+// result has gone stale.
+const scanRenewInterval = 5 * time.Minute
+
+// scanPrivateKey is the private data key holding an open scan's scanLease.
+const scanPrivateKey = "scan"
+
+// scanLease is what an open docidr_scan keeps between Open and Renew, which
+// doesn't see the configuration.
+type scanLease struct {
+	Options  scanOptions `json:"options"`
+	Networks []string    `json:"networks"`
+}
+
+// scanEphemeralResource is the docidr_scan ephemeral resource.
+type scanEphemeralResource struct {
+	combined *config.CombinedConfig
+}
+
+var (
+	_ ephemeral.EphemeralResourceWithConfigure = (*scanEphemeralResource)(nil)
+	_ ephemeral.EphemeralResourceWithRenew     = (*scanEphemeralResource)(nil)
+	_ ephemeral.EphemeralResourceWithClose     = (*scanEphemeralResource)(nil)
+)
+
+// NewScanEphemeralResource returns the docidr_scan ephemeral resource.
+func NewScanEphemeralResource() ephemeral.EphemeralResource {
+	return &scanEphemeralResource{}
+}
+
+// scanModel is the docidr_scan configuration and result.
+type scanModel struct {
+	ScanReservedIPs   types.Bool      `tfsdk:"scan_reserved_ips"`
+	ScanLoadBalancers types.Bool      `tfsdk:"scan_load_balancers"`
+	ScanVPCPeerings   types.Bool      `tfsdk:"scan_vpc_peerings"`
+	ScanBYOIP         types.Bool      `tfsdk:"scan_byoip"`
+	ScanInterconnects types.Bool      `tfsdk:"scan_interconnects"`
+	AllowPartialScan  types.Bool      `tfsdk:"allow_partial_scan"`
+	CIDRs             []scanCIDRModel `tfsdk:"cidrs"`
+	Networks          []types.String  `tfsdk:"networks"`
+	SkippedSources    []types.String  `tfsdk:"skipped_sources"`
+	ScannedAt         types.String    `tfsdk:"scanned_at"`
+}
+
+// scanCIDRModel is a CIDR in the cidrs attribute of docidr_scan.
+type scanCIDRModel struct {
+	CIDR         types.String `tfsdk:"cidr"`
+	Source       types.String `tfsdk:"source"`
+	ResourceID   types.String `tfsdk:"resource_id"`
+	ResourceName types.String `tfsdk:"resource_name"`
+	Region       types.String `tfsdk:"region"`
+}
+
+func (r *scanEphemeralResource) Metadata(_ context.Context, req ephemeral.MetadataRequest, resp *ephemeral.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_scan"
+}
+
+func (r *scanEphemeralResource) Schema(_ context.Context, _ ephemeral.SchemaRequest, resp *ephemeral.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Description: "Scans the DigitalOcean account for the CIDRs in use each time it is opened, without storing them in state.",
+		Attributes: map[string]schema.Attribute{
+			"scan_reserved_ips": schema.BoolAttribute{
+				Optional:    true,
+				Description: "Whether reserved IPv4 addresses are scanned, as /32 networks.",
+			},
+			"scan_load_balancers": schema.BoolAttribute{
+				Optional:    true,
+				Description: "Whether load balancer IPv4 addresses are scanned, as /32 networks.",
+			},
+			"scan_vpc_peerings": schema.BoolAttribute{
+				Optional:    true,
+				Description: "Whether the ranges of peer VPCs in other accounts are scanned.",
+			},
+			"scan_byoip": schema.BoolAttribute{
+				Optional:    true,
+				Description: "Whether bring-your-own-IP prefixes are scanned.",
+			},
+			"scan_interconnects": schema.BoolAttribute{
+				Optional:    true,
+				Description: "Whether the remote routes of partner interconnect attachments are scanned.",
+			},
+			"allow_partial_scan": schema.BoolAttribute{
+				Optional:    true,
+				Description: "Whether collectors the token isn't permitted to query are skipped with a warning instead of failing the scan.",
+			},
+			"cidrs": schema.ListNestedAttribute{
+				Computed:    true,
+				Description: "The CIDRs in use, in scan order, with the resources they belong to.",
+				NestedObject: schema.NestedAttributeObject{
+					Attributes: map[string]schema.Attribute{
+						"cidr": schema.StringAttribute{
+							Computed:    true,
+							Description: "The CIDR.",
+						},
+						"source": schema.StringAttribute{
+							Computed:    true,
+							Description: "The kind of resource the CIDR belongs to, such as vpc or kubernetes_cluster_subnet.",
+						},
+						"resource_id": schema.StringAttribute{
+							Computed:    true,
+							Description: "The ID of the resource, if any.",
+						},
+						"resource_name": schema.StringAttribute{
+							Computed:    true,
+							Description: "The name of the resource, if any.",
+						},
+						"region": schema.StringAttribute{
+							Computed:    true,
+							Description: "The region of the resource, if any.",
+						},
+					},
+				},
+			},
+			"networks": schema.ListAttribute{
+				Computed:    true,
+				ElementType: types.StringType,
+				Description: "The smallest sorted list of CIDRs covering every CIDR in use, for exclude_cidrs.",
+			},
+			"skipped_sources": schema.ListAttribute{
+				Computed:    true,
+				ElementType: types.StringType,
+				Description: "The collectors skipped by allow_partial_scan.",
+			},
+			"scanned_at": schema.StringAttribute{
+				Computed:    true,
+				Description: "When the scan was made, as an RFC 3339 UTC timestamp.",
+			},
+		},
+	}
+}
+
+func (r *scanEphemeralResource) Configure(_ context.Context, req ephemeral.ConfigureRequest, resp *ephemeral.ConfigureResponse) {
+	// The provider isn't configured yet while the configuration is validated
+	if req.ProviderData == nil {
+		return
+	}
+	combined, ok := req.ProviderData.(*config.CombinedConfig)
+	if !ok {
+		resp.Diagnostics.AddError("Unexpected provider data", fmt.Sprintf("Expected *config.CombinedConfig, got %T.", req.ProviderData))
+		return
+	}
+	r.combined = combined
+}
+
+func (r *scanEphemeralResource) Open(ctx context.Context, req ephemeral.OpenRequest, resp *ephemeral.OpenResponse) {
+	if r.combined == nil {
+		resp.Diagnostics.AddError("Provider not configured", "The docidr_scan ephemeral resource needs a configured provider to scan the account.")
+		return
+	}
+	var model scanModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &model)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	opts := scanOptions{
+		ScanReservedIPs:   model.ScanReservedIPs.ValueBool(),
+		ScanLoadBalancers: model.ScanLoadBalancers.ValueBool(),
+		ScanVPCPeerings:   model.ScanVPCPeerings.ValueBool(),
+		ScanBYOIP:         model.ScanBYOIP.ValueBool(),
+		ScanInterconnects: model.ScanInterconnects.ValueBool(),
+		AllowPartialScan:  model.AllowPartialScan.ValueBool(),
+		PageSize:          r.combined.APIPageSize(),
+	}
+	existing, skipped, diags := collectExistingCIDRs(ctx, r.combined.GodoClient(), opts)
+	resp.Diagnostics.Append(frameworkDiagnostics(diags)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	networks := scanNetworks(existing)
+	model.CIDRs = make([]scanCIDRModel, 0, len(existing))
+	for _, e := range existing {
+		model.CIDRs = append(model.CIDRs, scanCIDRModel{
+			CIDR:         types.StringValue(e.Network.String()),
+			Source:       types.StringValue(e.Source),
+			ResourceID:   types.StringValue(e.ResourceID),
+			ResourceName: types.StringValue(e.ResourceName),
+			Region:       types.StringValue(e.Region),
+		})
+	}
+	model.Networks = stringValues(networks)
+	model.SkippedSources = stringValues(skipped)
+	model.ScannedAt = types.StringValue(time.Now().UTC().Format(time.RFC3339))
+	resp.Diagnostics.Append(resp.Result.Set(ctx, &model)...)
+
+	lease, err := json.Marshal(scanLease{Options: opts, Networks: networks})
+	if err != nil {
+		resp.Diagnostics.AddError("Error recording the scan", err.Error())
+		return
+	}
+	resp.Diagnostics.Append(resp.Private.SetKey(ctx, scanPrivateKey, lease)...)
+	resp.RenewAt = time.Now().Add(scanRenewInterval)
+}
+
+// Renew scans the account again, with the options the scan was opened with,
+// and warns when the CIDRs in use have changed. The result of an open
+// ephemeral resource can't be replaced, so the warning is all it can do.
+func (r *scanEphemeralResource) Renew(ctx context.Context, req ephemeral.RenewRequest, resp *ephemeral.RenewResponse) {
+	if r.combined == nil {
+		resp.Diagnostics.AddError("Provider not configured", "The docidr_scan ephemeral resource needs a configured provider to scan the account.")
+		return
+	}
+	raw, diags := req.Private.GetKey(ctx, scanPrivateKey)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	var lease scanLease
+	if err := json.Unmarshal(raw, &lease); err != nil {
+		resp.Diagnostics.AddError("Error reading the scan", err.Error())
+		return
+	}
+
+	existing, _, scanDiags := collectExistingCIDRs(ctx, r.combined.GodoClient(), lease.Options)
+	if scanDiags.HasError() {
+		// The scan that was opened is still usable, so a failure to check
+		// it only warns
+		for _, d := range scanDiags {
+			resp.Diagnostics.AddWarning("Unable to renew the docidr_scan", d.Summary+": "+d.Detail)
+		}
+	} else if networks := scanNetworks(existing); strings.Join(networks, ",") != strings.Join(lease.Networks, ",") {
+		resp.Diagnostics.AddWarning("The docidr_scan is out of date",
+			fmt.Sprintf("The CIDRs in use in the account changed after the scan was opened: %s then, %s now. "+
+				"Values derived from the scan in this run don't reflect the change.",
+				strings.Join(lease.Networks, ", "), strings.Join(networks, ", ")))
+	}
+
+	resp.Private = req.Private
+	resp.RenewAt = time.Now().Add(scanRenewInterval)
+}
+
+// Close releases nothing, as a scan holds no remote resources, but logs the
+// end of the scan's use.
+func (r *scanEphemeralResource) Close(ctx context.Context, req ephemeral.CloseRequest, resp *ephemeral.CloseResponse) {
+	log.Printf("[DEBUG] Closing docidr_scan")
+}
+
+// scanNetworks returns the smallest sorted list of CIDRs covering existing.
+func scanNetworks(existing []existingCIDR) []string {
+	merged := cidr.Merge(existingNetworks(existing))
+	networks := make([]string, 0, len(merged))
+	for _, network := range merged {
+		networks = append(networks, network.String())
+	}
+	sort.Strings(networks)
+	return networks
+}
+
+// stringValues converts strings to framework string values.
+func stringValues(values []string) []types.String {
+	result := make([]types.String, 0, len(values))
+	for _, v := range values {
+		result = append(result, types.StringValue(v))
+	}
+	return result
+}
+
+// frameworkDiagnostics converts SDKv2 diagnostics, as returned by the
+// collectors, to framework diagnostics.
+func frameworkDiagnostics(diags diag.Diagnostics) fwdiag.Diagnostics {
+	var result fwdiag.Diagnostics
+	for _, d := range diags {
+		if d.Severity == diag.Error {
+			result.AddError(d.Summary, d.Detail)
+		} else {
+			result.AddWarning(d.Summary, d.Detail)
+		}
+	}
+	return result
+}
@@ -0,0 +1,257 @@
+package pool
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DO-Solutions/terraform-provider-docidr/docidr/config"
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/ephemeral"
+	"github.com/hashicorp/terraform-plugin-framework/provider"
+	"github.com/hashicorp/terraform-plugin-framework/providerserver"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-go/tfprotov6"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+)
+
+// scanTestProvider is a framework provider serving only docidr_scan, with
+// combined as its configuration.
+type scanTestProvider struct {
+	combined *config.CombinedConfig
+}
+
+func (p *scanTestProvider) Metadata(_ context.Context, _ provider.MetadataRequest, resp *provider.MetadataResponse) {
+	resp.TypeName = "docidr"
+}
+
+func (p *scanTestProvider) Schema(context.Context, provider.SchemaRequest, *provider.SchemaResponse) {
+}
+
+func (p *scanTestProvider) Configure(_ context.Context, _ provider.ConfigureRequest, resp *provider.ConfigureResponse) {
+	resp.EphemeralResourceData = p.combined
+}
+
+func (p *scanTestProvider) Resources(context.Context) []func() resource.Resource { return nil }
+
+func (p *scanTestProvider) DataSources(context.Context) []func() datasource.DataSource { return nil }
+
+func (p *scanTestProvider) EphemeralResources(context.Context) []func() ephemeral.EphemeralResource {
+	return []func() ephemeral.EphemeralResource{NewScanEphemeralResource}
+}
+
+// newScanTestServer returns a configured protocol 6 server for docidr_scan
+// against the fake API served by handler, and the type of its configuration.
+func newScanTestServer(t *testing.T, handler http.Handler) (tfprotov6.ProviderServer, tftypes.Object) {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.Handle("/", handler)
+	server := providerserver.NewProtocol6(&scanTestProvider{combined: newFakeCombinedConfig(t, mux)})()
+	ctx := context.Background()
+
+	schemaResp, err := server.GetProviderSchema(ctx, &tfprotov6.GetProviderSchemaRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	scanSchema, ok := schemaResp.EphemeralResourceSchemas["docidr_scan"]
+	if !ok {
+		t.Fatal("GetProviderSchema() is missing the docidr_scan ephemeral resource")
+	}
+
+	providerConfig, err := tfprotov6.NewDynamicValue(tftypes.Object{}, tftypes.NewValue(tftypes.Object{}, map[string]tftypes.Value{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	configureResp, err := server.ConfigureProvider(ctx, &tfprotov6.ConfigureProviderRequest{Config: &providerConfig})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertNoProtocolErrors(t, "ConfigureProvider()", configureResp.Diagnostics)
+
+	return server, scanSchema.ValueType().(tftypes.Object)
+}
+
+// scanConfig returns a docidr_scan configuration of type typ with the given
+// arguments set and everything else null.
+func scanConfig(t *testing.T, typ tftypes.Object, args map[string]tftypes.Value) *tfprotov6.DynamicValue {
+	t.Helper()
+
+	values := make(map[string]tftypes.Value, len(typ.AttributeTypes))
+	for name, attrType := range typ.AttributeTypes {
+		if v, ok := args[name]; ok {
+			values[name] = v
+			continue
+		}
+		values[name] = tftypes.NewValue(attrType, nil)
+	}
+	config, err := tfprotov6.NewDynamicValue(typ, tftypes.NewValue(typ, values))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &config
+}
+
+func assertNoProtocolErrors(t *testing.T, call string, diags []*tfprotov6.Diagnostic) {
+	t.Helper()
+
+	for _, d := range diags {
+		if d.Severity == tfprotov6.DiagnosticSeverityError {
+			t.Fatalf("%s error: %s: %s", call, d.Summary, d.Detail)
+		}
+	}
+}
+
+// swappableHandler serves the API of whichever account is current, so a
+// test can change the account between calls.
+type swappableHandler struct {
+	current http.Handler
+}
+
+func (h *swappableHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.current.ServeHTTP(w, r)
+}
+
+func TestScanEphemeralResource_Lifecycle(t *testing.T) {
+	vpc := map[string]interface{}{"id": "vpc-1", "name": "prod", "ip_range": "10.0.0.0/16", "region": "nyc1"}
+	cluster := map[string]interface{}{
+		"id": "k8s-1", "name": "prod-k8s", "region": "nyc1",
+		"cluster_subnet": "10.1.0.0/16", "service_subnet": "10.2.0.0/16",
+	}
+	handler := &swappableHandler{current: newFakeAccountMux([]interface{}{vpc}, []interface{}{cluster})}
+	server, typ := newScanTestServer(t, handler)
+	ctx := context.Background()
+
+	openResp, err := server.OpenEphemeralResource(ctx, &tfprotov6.OpenEphemeralResourceRequest{
+		TypeName: "docidr_scan",
+		Config:   scanConfig(t, typ, nil),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertNoProtocolErrors(t, "OpenEphemeralResource()", openResp.Diagnostics)
+
+	result, err := openResp.Result.Unmarshal(typ)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var attrs map[string]tftypes.Value
+	if err := result.As(&attrs); err != nil {
+		t.Fatal(err)
+	}
+	var networks []tftypes.Value
+	if err := attrs["networks"].As(&networks); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, n := range networks {
+		var s string
+		if err := n.As(&s); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, s)
+	}
+	// The adjacent 10.0.0.0/16 and 10.1.0.0/16 merge
+	if want := "10.0.0.0/15,10.2.0.0/16"; strings.Join(got, ",") != want {
+		t.Errorf("networks = %v, want %s", got, want)
+	}
+	var cidrs []tftypes.Value
+	if err := attrs["cidrs"].As(&cidrs); err != nil {
+		t.Fatal(err)
+	}
+	if len(cidrs) != 3 {
+		t.Errorf("len(cidrs) = %d, want 3", len(cidrs))
+	}
+	if openResp.RenewAt.Before(time.Now()) {
+		t.Errorf("RenewAt = %s, want a time in the future", openResp.RenewAt)
+	}
+
+	renew := func() *tfprotov6.RenewEphemeralResourceResponse {
+		t.Helper()
+
+		resp, err := server.RenewEphemeralResource(ctx, &tfprotov6.RenewEphemeralResourceRequest{
+			TypeName: "docidr_scan",
+			Private:  openResp.Private,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertNoProtocolErrors(t, "RenewEphemeralResource()", resp.Diagnostics)
+		return resp
+	}
+
+	// Renewing an unchanged account is quiet
+	if resp := renew(); len(resp.Diagnostics) != 0 {
+		t.Errorf("RenewEphemeralResource() of an unchanged account diagnostics = %v, want none", resp.Diagnostics)
+	} else if resp.RenewAt.Before(time.Now()) {
+		t.Errorf("RenewEphemeralResource() RenewAt = %v, want a time in the future", resp.RenewAt)
+	}
+
+	// A VPC created after the scan was opened makes it stale
+	created := map[string]interface{}{"id": "vpc-2", "name": "new", "ip_range": "172.16.0.0/20", "region": "nyc1"}
+	handler.current = newFakeAccountMux([]interface{}{vpc, created}, []interface{}{cluster})
+	resp := renew()
+	if len(resp.Diagnostics) != 1 || resp.Diagnostics[0].Severity != tfprotov6.DiagnosticSeverityWarning ||
+		!strings.Contains(resp.Diagnostics[0].Detail, "172.16.0.0/20") {
+		t.Errorf("RenewEphemeralResource() of a changed account diagnostics = %v, want a warning naming 172.16.0.0/20", resp.Diagnostics)
+	}
+
+	closeResp, err := server.CloseEphemeralResource(ctx, &tfprotov6.CloseEphemeralResourceRequest{
+		TypeName: "docidr_scan",
+		Private:  openResp.Private,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertNoProtocolErrors(t, "CloseEphemeralResource()", closeResp.Diagnostics)
+}
+
+func TestScanEphemeralResource_OpenError(t *testing.T) {
+	mux := http.NewServeMux()
+	serveError(mux, "/v2/vpcs", http.StatusForbidden)
+	servePages(mux, "/v2/kubernetes/clusters", "kubernetes_clusters", []interface{}{})
+	server, typ := newScanTestServer(t, mux)
+	ctx := context.Background()
+
+	resp, err := server.OpenEphemeralResource(ctx, &tfprotov6.OpenEphemeralResourceRequest{
+		TypeName: "docidr_scan",
+		Config:   scanConfig(t, typ, nil),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var failed bool
+	for _, d := range resp.Diagnostics {
+		failed = failed || d.Severity == tfprotov6.DiagnosticSeverityError
+	}
+	if !failed {
+		t.Errorf("OpenEphemeralResource() with a forbidden VPC list diagnostics = %v, want an error", resp.Diagnostics)
+	}
+
+	// allow_partial_scan skips the forbidden collector instead
+	resp, err = server.OpenEphemeralResource(ctx, &tfprotov6.OpenEphemeralResourceRequest{
+		TypeName: "docidr_scan",
+		Config:   scanConfig(t, typ, map[string]tftypes.Value{"allow_partial_scan": tftypes.NewValue(tftypes.Bool, true)}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertNoProtocolErrors(t, "OpenEphemeralResource()", resp.Diagnostics)
+	result, err := resp.Result.Unmarshal(typ)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var attrs map[string]tftypes.Value
+	if err := result.As(&attrs); err != nil {
+		t.Fatal(err)
+	}
+	var skipped []tftypes.Value
+	if err := attrs["skipped_sources"].As(&skipped); err != nil {
+		t.Fatal(err)
+	}
+	if len(skipped) != 1 {
+		t.Errorf("skipped_sources = %v, want one source", skipped)
+	}
+}
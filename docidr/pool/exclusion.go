@@ -0,0 +1,155 @@
+package pool
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/DO-Solutions/terraform-provider-docidr/docidr/cidr"
+)
+
+// Exclusion sources name where an exclusion came from. Ranges found in the
+// account use the source of the existing CIDR, such as sourceVPC.
+const (
+	exclusionSourceExclude           = "exclude"
+	exclusionSourcePattern           = "exclude_patterns"
+	exclusionSourceSelfManaged       = "exclude_self_managed_ranges"
+	exclusionSourceFile              = "exclusions_file"
+	exclusionSourceURL               = "exclusion_urls"
+	exclusionSourceAppPlatform       = "app_platform"
+	exclusionSourceEnv               = "DOCIDR_EXCLUDE"
+	exclusionSourcePool              = "docidr_pool"
+	exclusionSourceAllocationExclude = "allocation.exclude"
+)
+
+// maxBlockingExclusions is how many blocking exclusions an allocation error
+// lists before summarizing the rest.
+const maxBlockingExclusions = 10
+
+// exclusion is a range allocations must avoid, with why and where it came
+// from.
+type exclusion struct {
+	Network *net.IPNet
+	Reason  string
+	Source  string
+}
+
+// String returns the range and its reason, or its source when it has no
+// reason.
+func (e exclusion) String() string {
+	if e.Reason != "" {
+		return fmt.Sprintf("%s (reason: %s)", e.Network.String(), e.Reason)
+	}
+	return fmt.Sprintf("%s (source: %s)", e.Network.String(), e.Source)
+}
+
+// newExclusions returns an exclusion from source for each network.
+func newExclusions(networks []*net.IPNet, source string) []exclusion {
+	result := make([]exclusion, 0, len(networks))
+	for _, network := range networks {
+		result = append(result, exclusion{Network: network, Source: source})
+	}
+	return result
+}
+
+// existingExclusions returns an exclusion for each existing CIDR and the CIDRs
+// nested within it, giving the resource using it as the reason.
+func existingExclusions(existing []existingCIDR) []exclusion {
+	var result []exclusion
+	for _, e := range existing {
+		reason := "in use by " + e.ResourceID
+		if e.ResourceName != "" {
+			reason += fmt.Sprintf(" %q", e.ResourceName)
+		}
+		result = append(result, exclusion{Network: e.Network, Reason: reason, Source: e.Source})
+		result = append(result, existingExclusions(e.Children)...)
+	}
+	return result
+}
+
+// exclusionNetworks returns the networks of the given exclusions.
+func exclusionNetworks(exclusions []exclusion) []*net.IPNet {
+	result := make([]*net.IPNet, 0, len(exclusions))
+	for _, e := range exclusions {
+		result = append(result, e.Network)
+	}
+	return result
+}
+
+// blockingExclusions returns the exclusions overlapping network, in address
+// order with supernets before the ranges they contain. Every overlapping
+// exclusion is returned, as each takes away part of network and the same range
+// may be excluded for several reasons; only exact duplicates are dropped.
+func blockingExclusions(network *net.IPNet, exclusions []exclusion) []exclusion {
+	var result []exclusion
+	seen := make(map[string]bool)
+	for _, e := range exclusions {
+		if cidr.Classify(network, e.Network) == cidr.RelationshipNone {
+			continue
+		}
+		key := e.Network.String() + "\x00" + e.Reason + "\x00" + e.Source
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		result = append(result, e)
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		if c := bytes.Compare(result[i].Network.IP.To16(), result[j].Network.IP.To16()); c != 0 {
+			return c < 0
+		}
+		iLen, _ := result[i].Network.Mask.Size()
+		jLen, _ := result[j].Network.Mask.Size()
+		return iLen < jLen
+	})
+	return result
+}
+
+// describeAllocationError adds the exclusions that blocked a failed allocation
+// to its error, so the user can see why there was no room. Errors other than
+// a *cidr.AllocationError are returned unchanged. requests supply the
+// allocation's own exclusions.
+func describeAllocationError(err error, exclusions []exclusion, requests []cidr.AllocationRequest) error {
+	var allocErr *cidr.AllocationError
+	if !errors.As(err, &allocErr) {
+		return err
+	}
+
+	candidates := exclusions
+	for _, req := range requests {
+		if req.Name == allocErr.Name {
+			candidates = append(newExclusions(req.Exclusions, exclusionSourceAllocationExclude), exclusions...)
+		}
+	}
+	blocking := blockingExclusions(allocErr.BaseCIDR, candidates)
+	if len(blocking) == 0 {
+		return err
+	}
+
+	lines := make([]string, 0, maxBlockingExclusions+1)
+	for i, e := range blocking {
+		if i == maxBlockingExclusions {
+			lines = append(lines, fmt.Sprintf("and %d more exclusions", len(blocking)-i))
+			break
+		}
+		lines = append(lines, "blocked by exclusion "+e.String())
+	}
+	return fmt.Errorf("%w; %s", err, strings.Join(lines, "; "))
+}
+
+// flattenExclusions converts exclusions to a schema-compatible format.
+func flattenExclusions(exclusions []exclusion) []interface{} {
+	result := make([]interface{}, 0, len(exclusions))
+	for _, e := range exclusions {
+		result = append(result, map[string]interface{}{
+			"cidr":   e.Network.String(),
+			"reason": e.Reason,
+			"source": e.Source,
+		})
+	}
+	return result
+}
@@ -0,0 +1,194 @@
+package pool
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/DO-Solutions/terraform-provider-docidr/docidr/cidr"
+	"github.com/DO-Solutions/terraform-provider-docidr/docidr/config"
+)
+
+func TestExclusionString(t *testing.T) {
+	tests := []struct {
+		exclusion exclusion
+		want      string
+	}{
+		{
+			exclusion: exclusion{Network: mustParseTestCIDR(t, "10.2.0.0/16"), Reason: "reserved for acquisitions", Source: exclusionSourceExclude},
+			want:      "10.2.0.0/16 (reason: reserved for acquisitions)",
+		},
+		{
+			exclusion: exclusion{Network: mustParseTestCIDR(t, "10.3.0.0/16"), Source: exclusionSourceEnv},
+			want:      "10.3.0.0/16 (source: DOCIDR_EXCLUDE)",
+		},
+	}
+
+	for _, tt := range tests {
+		if got := tt.exclusion.String(); got != tt.want {
+			t.Errorf("String() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestExistingExclusions(t *testing.T) {
+	existing := dedupeExistingCIDRs([]existingCIDR{
+		{Network: mustParseTestCIDR(t, "10.0.0.0/16"), Source: sourceVPC, ResourceID: "vpc-1", ResourceName: "prod"},
+		{Network: mustParseTestCIDR(t, "10.0.1.0/24"), Source: sourceReservedIP, ResourceID: "10.0.1.7"},
+	})
+
+	got := existingExclusions(existing)
+	want := []string{
+		`10.0.0.0/16 (reason: in use by vpc-1 "prod")`,
+		"10.0.1.0/24 (reason: in use by 10.0.1.7)",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("existingExclusions() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i].String() != want[i] {
+			t.Errorf("exclusion %d = %q, want %q", i, got[i].String(), want[i])
+		}
+	}
+	if got[1].Source != sourceReservedIP {
+		t.Errorf("nested exclusion source = %q, want %q", got[1].Source, sourceReservedIP)
+	}
+}
+
+func TestBlockingExclusions(t *testing.T) {
+	exclusions := []exclusion{
+		{Network: mustParseTestCIDR(t, "10.0.8.0/24"), Source: sourceVPC, Reason: "in use by vpc-1"},
+		{Network: mustParseTestCIDR(t, "192.168.0.0/16"), Source: exclusionSourceExclude, Reason: "home"},
+		{Network: mustParseTestCIDR(t, "10.0.0.0/20"), Source: exclusionSourceExclude, Reason: "reserved"},
+		{Network: mustParseTestCIDR(t, "10.0.0.0/8"), Source: exclusionSourceEnv},
+		{Network: mustParseTestCIDR(t, "10.0.8.0/24"), Source: exclusionSourceExclude, Reason: "legacy"},
+		{Network: mustParseTestCIDR(t, "10.0.0.0/20"), Source: exclusionSourceExclude, Reason: "reserved"},
+	}
+
+	var got []string
+	for _, e := range blockingExclusions(mustParseTestCIDR(t, "10.0.0.0/16"), exclusions) {
+		got = append(got, e.String())
+	}
+	want := []string{
+		"10.0.0.0/8 (source: DOCIDR_EXCLUDE)",
+		"10.0.0.0/20 (reason: reserved)",
+		"10.0.8.0/24 (reason: in use by vpc-1)",
+		"10.0.8.0/24 (reason: legacy)",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("blockingExclusions() = %q, want %q", got, want)
+	}
+}
+
+func TestDescribeAllocationError(t *testing.T) {
+	base := mustParseTestCIDR(t, "10.2.0.0/16")
+	allocErr := &cidr.AllocationError{Name: "vpc", PrefixLength: 16, BaseCIDR: base, Err: cidr.ErrNoSpace}
+	exclusions := []exclusion{
+		{Network: mustParseTestCIDR(t, "10.2.0.0/16"), Reason: "reserved for acquisitions", Source: exclusionSourceExclude},
+		{Network: mustParseTestCIDR(t, "10.3.0.0/16"), Reason: "elsewhere", Source: exclusionSourceExclude},
+	}
+	requests := []cidr.AllocationRequest{
+		{Name: "other", Exclusions: []*net.IPNet{mustParseTestCIDR(t, "10.2.2.0/24")}},
+		{Name: "vpc", Exclusions: []*net.IPNet{mustParseTestCIDR(t, "10.2.1.0/24")}},
+	}
+
+	err := describeAllocationError(allocErr, exclusions, requests)
+	want := `failed to allocate CIDR for "vpc" (/16): no available space; ` +
+		"blocked by exclusion 10.2.0.0/16 (reason: reserved for acquisitions); " +
+		"blocked by exclusion 10.2.1.0/24 (source: allocation.exclude)"
+	if err.Error() != want {
+		t.Errorf("describeAllocationError() = %q, want %q", err.Error(), want)
+	}
+	if !errors.Is(err, cidr.ErrNoSpace) {
+		t.Errorf("describeAllocationError() = %v, want it to wrap ErrNoSpace", err)
+	}
+
+	// Long lists are summarized
+	var many []exclusion
+	for i := 0; i < maxBlockingExclusions+3; i++ {
+		many = append(many, exclusion{Network: mustParseTestCIDR(t, fmt.Sprintf("10.2.%d.0/24", i)), Source: sourceVPC})
+	}
+	err = describeAllocationError(allocErr, many, nil)
+	if got := strings.Count(err.Error(), "blocked by exclusion"); got != maxBlockingExclusions {
+		t.Errorf("describeAllocationError() lists %d exclusions, want %d: %v", got, maxBlockingExclusions, err)
+	}
+	if !strings.HasSuffix(err.Error(), "; and 3 more exclusions") {
+		t.Errorf("describeAllocationError() = %v, want it to end with the remaining count", err)
+	}
+
+	// Other errors are unchanged
+	other := errors.New("requested prefix length /8 is smaller than base CIDR prefix /16")
+	if got := describeAllocationError(other, exclusions, requests); got != other {
+		t.Errorf("describeAllocationError() = %v, want %v", got, other)
+	}
+}
+
+func TestResourceDocidrPoolCustomizeDiff_BlockingExclusions(t *testing.T) {
+	srv := httptest.NewServer(newFakeAccountMux(
+		[]interface{}{
+			map[string]interface{}{"id": "vpc-1", "name": "prod", "ip_range": "10.0.0.128/26", "region": "nyc1"},
+		},
+		[]interface{}{},
+	))
+	t.Cleanup(srv.Close)
+	meta := newTestCombinedConfig(t, &config.Config{APIEndpoint: srv.URL + "/", ComputeAllocationsAtPlanTime: true})
+
+	_, err := planPool(t, map[string]interface{}{
+		"base_cidr": "10.0.0.0/24",
+		"exclude": []interface{}{
+			map[string]interface{}{"cidr": "10.0.0.0/25", "reason": "reserved for acquisitions"},
+		},
+		"allocation": []interface{}{
+			map[string]interface{}{"name": "vpc", "prefix_length": 25},
+		},
+	}, meta)
+	if err == nil {
+		t.Fatal("Diff() error = nil, want an allocation error")
+	}
+	for _, want := range []string{
+		"blocked by exclusion 10.0.0.0/25 (reason: reserved for acquisitions)",
+		`blocked by exclusion 10.0.0.128/26 (reason: in use by vpc-1 "prod")`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Diff() error = %v, want it to contain %q", err, want)
+		}
+	}
+}
+
+func TestResourceDocidrPoolCreate_ScanReportExclusions(t *testing.T) {
+	meta := newFakeCombinedConfig(t, newFakeAccountMux(
+		[]interface{}{
+			map[string]interface{}{"id": "vpc-1", "name": "prod", "ip_range": "10.0.0.0/24", "region": "nyc1"},
+		},
+		[]interface{}{},
+	))
+
+	state := applyPool(t, nil, map[string]interface{}{
+		"base_cidr": "10.0.0.0/16",
+		"exclude": []interface{}{
+			map[string]interface{}{"cidr": "10.0.1.0/24", "reason": "on-prem"},
+		},
+		"allocation": []interface{}{
+			map[string]interface{}{"name": "vpc", "prefix_length": 24},
+		},
+	}, meta)
+
+	want := map[string]string{
+		"scan_report.0.exclusions.#":        "2",
+		"scan_report.0.exclusions.0.cidr":   "10.0.0.0/24",
+		"scan_report.0.exclusions.0.reason": `in use by vpc-1 "prod"`,
+		"scan_report.0.exclusions.0.source": sourceVPC,
+		"scan_report.0.exclusions.1.cidr":   "10.0.1.0/24",
+		"scan_report.0.exclusions.1.reason": "on-prem",
+		"scan_report.0.exclusions.1.source": exclusionSourceExclude,
+	}
+	for key, value := range want {
+		if got := state.Attributes[key]; got != value {
+			t.Errorf("%s = %q, want %q", key, got, value)
+		}
+	}
+}
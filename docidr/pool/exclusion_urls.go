@@ -0,0 +1,91 @@
+package pool
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+
+	"github.com/DO-Solutions/terraform-provider-docidr/docidr/config"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+)
+
+// maxExclusionListSize caps the size of a remote exclusion list response.
+const maxExclusionListSize = 10 << 20
+
+// collectRemoteExclusions fetches every configured exclusion URL, using results
+// cached by the provider where available. Failures are returned as errors or
+// warnings depending on the provider's on_exclusion_source_error setting.
+func collectRemoteExclusions(ctx context.Context, meta *config.CombinedConfig) ([]*net.IPNet, diag.Diagnostics) {
+	var diags diag.Diagnostics
+	var result []*net.IPNet
+
+	for _, url := range meta.ExclusionURLs() {
+		if networks, ok := meta.CachedRemoteExclusions(url); ok {
+			log.Printf("[DEBUG] Using %d cached exclusions from %s", len(networks), url)
+			result = append(result, networks...)
+			continue
+		}
+
+		networks, err := fetchExclusionURL(ctx, meta.HTTPClient(), url)
+		if err != nil {
+			if meta.OnExclusionSourceError() == config.ExclusionSourceErrorWarn {
+				diags = append(diags, diag.Diagnostic{
+					Severity: diag.Warning,
+					Summary:  "Skipping remote exclusion list",
+					Detail:   err.Error(),
+				})
+				continue
+			}
+			return nil, append(diags, diag.FromErr(err)...)
+		}
+
+		log.Printf("[DEBUG] Fetched %d exclusions from %s", len(networks), url)
+		meta.CacheRemoteExclusions(url, networks)
+		result = append(result, networks...)
+	}
+
+	return result, diags
+}
+
+// fetchExclusionURL downloads and parses a remote exclusion list. The body may
+// be plain text (one CIDR per line) or a JSON array of CIDR strings.
+func fetchExclusionURL(ctx context.Context, client *http.Client, url string) ([]*net.IPNet, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching exclusion list %s: %w", url, err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching exclusion list %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error fetching exclusion list %s: unexpected status %s", url, resp.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxExclusionListSize))
+	if err != nil {
+		return nil, fmt.Errorf("error reading exclusion list %s: %w", url, err)
+	}
+
+	return parseExclusionList(url, body, remoteExclusionFormat(resp.Header.Get("Content-Type"), body))
+}
+
+// remoteExclusionFormat picks the text or JSON parser based on the response
+// content type, falling back to sniffing the body for a JSON array.
+func remoteExclusionFormat(contentType string, body []byte) string {
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil && mediaType == "application/json" {
+		return exclusionFormatJSON
+	}
+	if bytes.HasPrefix(bytes.TrimSpace(body), []byte("[")) {
+		return exclusionFormatJSON
+	}
+	return exclusionFormatText
+}
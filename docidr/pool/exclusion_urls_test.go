@@ -0,0 +1,183 @@
+package pool
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/DO-Solutions/terraform-provider-docidr/docidr/config"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+)
+
+func newExclusionListServer(t *testing.T, hits *int32) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/reserved.txt", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(hits, 1)
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = w.Write([]byte("# reserved\n10.0.0.0/16\n10.1.0.0/16\n"))
+	})
+	mux.HandleFunc("/reserved.json", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(hits, 1)
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_, _ = w.Write([]byte(`["172.16.0.0/12"]`))
+	})
+	mux.HandleFunc("/error", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(hits, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	mux.HandleFunc("/malformed", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(hits, 1)
+		_, _ = w.Write([]byte("10.0.0.0/16\n<html>oops</html>\n"))
+	})
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func newTestCombinedConfig(t *testing.T, cfg *config.Config) *config.CombinedConfig {
+	t.Helper()
+
+	if cfg.Token == "" {
+		cfg.Token = "test-token"
+	}
+	combined, err := cfg.Client()
+	if err != nil {
+		t.Fatalf("Client() error = %v", err)
+	}
+	return combined
+}
+
+func TestFetchExclusionURL(t *testing.T) {
+	var hits int32
+	srv := newExclusionListServer(t, &hits)
+
+	tests := []struct {
+		name    string
+		path    string
+		want    []string
+		wantErr string
+	}{
+		{
+			name: "plain text",
+			path: "/reserved.txt",
+			want: []string{"10.0.0.0/16", "10.1.0.0/16"},
+		},
+		{
+			name: "json",
+			path: "/reserved.json",
+			want: []string{"172.16.0.0/12"},
+		},
+		{
+			name:    "server error",
+			path:    "/error",
+			wantErr: "500",
+		},
+		{
+			name:    "malformed body",
+			path:    "/malformed",
+			wantErr: "/malformed:2:",
+		},
+	}
+
+	client := newTestCombinedConfig(t, &config.Config{}).HTTPClient()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := fetchExclusionURL(context.Background(), client, srv.URL+tt.path)
+			if tt.wantErr != "" {
+				if err == nil {
+					t.Fatal("fetchExclusionURL() expected error, got nil")
+				}
+				if !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("fetchExclusionURL() error = %q, want it to contain %q", err.Error(), tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("fetchExclusionURL() error = %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("fetchExclusionURL() returned %d networks, want %d", len(got), len(tt.want))
+			}
+			for i, want := range tt.want {
+				if got[i].String() != want {
+					t.Errorf("network[%d] = %s, want %s", i, got[i].String(), want)
+				}
+			}
+		})
+	}
+}
+
+func TestCollectRemoteExclusions_Cached(t *testing.T) {
+	var hits int32
+	srv := newExclusionListServer(t, &hits)
+
+	combined := newTestCombinedConfig(t, &config.Config{
+		ExclusionURLs: []string{srv.URL + "/reserved.txt", srv.URL + "/reserved.json"},
+	})
+
+	for i := 0; i < 2; i++ {
+		networks, diags := collectRemoteExclusions(context.Background(), combined)
+		if diags.HasError() {
+			t.Fatalf("collectRemoteExclusions() diags = %v", diags)
+		}
+		if len(networks) != 3 {
+			t.Fatalf("collectRemoteExclusions() returned %d networks, want 3", len(networks))
+		}
+	}
+
+	if got := atomic.LoadInt32(&hits); got != 2 {
+		t.Errorf("server hits = %d, want 2 (second call should be cached)", got)
+	}
+}
+
+func TestCollectRemoteExclusions_ErrorPolicy(t *testing.T) {
+	var hits int32
+	srv := newExclusionListServer(t, &hits)
+
+	tests := []struct {
+		name        string
+		policy      string
+		wantErr     bool
+		wantWarning bool
+		wantCount   int
+	}{
+		{
+			name:    "error policy fails",
+			policy:  config.ExclusionSourceErrorFail,
+			wantErr: true,
+		},
+		{
+			name:        "warn policy continues",
+			policy:      config.ExclusionSourceErrorWarn,
+			wantWarning: true,
+			wantCount:   1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			combined := newTestCombinedConfig(t, &config.Config{
+				ExclusionURLs:          []string{srv.URL + "/malformed", srv.URL + "/reserved.json"},
+				OnExclusionSourceError: tt.policy,
+			})
+
+			networks, diags := collectRemoteExclusions(context.Background(), combined)
+			if diags.HasError() != tt.wantErr {
+				t.Fatalf("collectRemoteExclusions() diags = %v, wantErr %v", diags, tt.wantErr)
+			}
+			if tt.wantWarning && (len(diags) != 1 || diags[0].Severity != diag.Warning) {
+				t.Errorf("expected a single warning, got %v", diags)
+			}
+			if len(networks) != tt.wantCount {
+				t.Errorf("collectRemoteExclusions() returned %d networks, want %d", len(networks), tt.wantCount)
+			}
+		})
+	}
+}
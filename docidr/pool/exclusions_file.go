@@ -0,0 +1,216 @@
+package pool
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/DO-Solutions/terraform-provider-docidr/docidr/cidr"
+)
+
+// Supported exclusion list formats.
+const (
+	exclusionFormatText = "text"
+	exclusionFormatJSON = "json"
+	exclusionFormatCSV  = "csv"
+)
+
+// loadExclusionsFile reads and parses an exclusions file, returning the parsed
+// networks along with a SHA-256 hash of the file contents.
+func loadExclusionsFile(path string) ([]*net.IPNet, string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", fmt.Errorf("error reading exclusions file: %w", err)
+	}
+
+	networks, err := parseExclusionList(path, data, detectExclusionFormat(path, data))
+	if err != nil {
+		return nil, "", err
+	}
+
+	return networks, hashContent(data), nil
+}
+
+// hashContent returns the hex-encoded SHA-256 hash of data.
+func hashContent(data []byte) string {
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
+
+// detectExclusionFormat picks a parser based on the file extension, falling back
+// to sniffing the content for a JSON array. Anything else is treated as plain text.
+func detectExclusionFormat(path string, data []byte) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return exclusionFormatJSON
+	case ".csv":
+		return exclusionFormatCSV
+	}
+
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("[")) {
+		return exclusionFormatJSON
+	}
+	return exclusionFormatText
+}
+
+// parseExclusionList parses an exclusion list in the given format. The source is
+// used to prefix error messages, which also include the offending line number.
+func parseExclusionList(source string, data []byte, format string) ([]*net.IPNet, error) {
+	switch format {
+	case exclusionFormatText:
+		return parseTextExclusions(source, data)
+	case exclusionFormatJSON:
+		return parseJSONExclusions(source, data)
+	case exclusionFormatCSV:
+		return parseCSVExclusions(source, data)
+	default:
+		return nil, fmt.Errorf("%s: unsupported exclusion list format %q", source, format)
+	}
+}
+
+// parseTextExclusions parses one CIDR per line. Blank lines are ignored and
+// everything after a '#' is treated as a comment.
+func parseTextExclusions(source string, data []byte) ([]*net.IPNet, error) {
+	var result []*net.IPNet
+	for i, line := range strings.Split(string(data), "\n") {
+		if idx := strings.Index(line, "#"); idx >= 0 {
+			line = line[:idx]
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		network, err := cidr.ParseCIDR(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", source, i+1, err)
+		}
+		result = append(result, network)
+	}
+	return result, nil
+}
+
+// parseJSONExclusions parses a JSON array of CIDR strings.
+func parseJSONExclusions(source string, data []byte) ([]*net.IPNet, error) {
+	// Validate the whole document first so syntax errors carry an absolute offset.
+	var raw []json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			return nil, fmt.Errorf("%s:%d: %w", source, lineAt(data, syntaxErr.Offset), err)
+		}
+		return nil, fmt.Errorf("%s:1: expected a JSON array of CIDR strings", source)
+	}
+
+	// Walk the array token by token to track the line of each element.
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if _, err := dec.Token(); err != nil {
+		return nil, fmt.Errorf("%s:1: %w", source, err)
+	}
+
+	result := make([]*net.IPNet, 0, len(raw))
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", source, lineAt(data, dec.InputOffset()), err)
+		}
+
+		value, ok := tok.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: expected a CIDR string, got %v", source, lineAt(data, dec.InputOffset()), tok)
+		}
+
+		network, err := cidr.ParseCIDR(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", source, lineAt(data, dec.InputOffset()), err)
+		}
+		result = append(result, network)
+	}
+	return result, nil
+}
+
+// parseCSVExclusions parses CSV data with a header row containing a "cidr"
+// column. Other columns are ignored and lines starting with '#' are comments.
+func parseCSVExclusions(source string, data []byte) ([]*net.IPNet, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.Comment = '#'
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+
+	header, err := r.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("%s:1: missing CSV header row", source)
+	}
+	if err != nil {
+		return nil, csvExclusionError(source, err)
+	}
+
+	col := -1
+	for i, name := range header {
+		if strings.EqualFold(strings.TrimSpace(name), "cidr") {
+			col = i
+			break
+		}
+	}
+	if col < 0 {
+		line, _ := r.FieldPos(0)
+		return nil, fmt.Errorf("%s:%d: CSV header has no \"cidr\" column", source, line)
+	}
+
+	var result []*net.IPNet
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, csvExclusionError(source, err)
+		}
+
+		line, _ := r.FieldPos(0)
+		if col >= len(record) {
+			return nil, fmt.Errorf("%s:%d: missing value for \"cidr\" column", source, line)
+		}
+
+		value := strings.TrimSpace(record[col])
+		if value == "" {
+			continue
+		}
+
+		network, err := cidr.ParseCIDR(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", source, line, err)
+		}
+		result = append(result, network)
+	}
+	return result, nil
+}
+
+// csvExclusionError formats a CSV reader error with the line it occurred on.
+func csvExclusionError(source string, err error) error {
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return fmt.Errorf("%s:%d: %w", source, parseErr.Line, parseErr.Err)
+	}
+	return fmt.Errorf("%s: %w", source, err)
+}
+
+// lineAt returns the 1-based line number of the given byte offset in data.
+func lineAt(data []byte, offset int64) int {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	return bytes.Count(data[:offset], []byte("\n")) + 1
+}
@@ -0,0 +1,219 @@
+package pool
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/DO-Solutions/terraform-provider-docidr/docidr/cidr"
+)
+
+func TestParseExclusionList(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		data   string
+		want   []string
+	}{
+		{
+			name:   "text with comments and blank lines",
+			format: exclusionFormatText,
+			data:   "# corporate reserved ranges\n10.0.0.0/16\n\n172.16.0.0/12 # VPN\n  192.168.0.0/24  \n",
+			want:   []string{"10.0.0.0/16", "172.16.0.0/12", "192.168.0.0/24"},
+		},
+		{
+			name:   "text empty",
+			format: exclusionFormatText,
+			data:   "# nothing here\n",
+			want:   nil,
+		},
+		{
+			name:   "json array",
+			format: exclusionFormatJSON,
+			data:   `["10.0.0.0/16", "172.16.0.0/12"]`,
+			want:   []string{"10.0.0.0/16", "172.16.0.0/12"},
+		},
+		{
+			name:   "json empty array",
+			format: exclusionFormatJSON,
+			data:   `[]`,
+			want:   nil,
+		},
+		{
+			name:   "csv with cidr column",
+			format: exclusionFormatCSV,
+			data:   "name,cidr,owner\nlegacy,10.0.0.0/16,netops\n# comment\nvpn,172.16.0.0/12,netops\n",
+			want:   []string{"10.0.0.0/16", "172.16.0.0/12"},
+		},
+		{
+			name:   "csv header case insensitive",
+			format: exclusionFormatCSV,
+			data:   "CIDR\n10.0.0.0/16\n",
+			want:   []string{"10.0.0.0/16"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseExclusionList("exclusions", []byte(tt.data), tt.format)
+			if err != nil {
+				t.Fatalf("parseExclusionList() error = %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("parseExclusionList() returned %d networks, want %d", len(got), len(tt.want))
+			}
+			for i, want := range tt.want {
+				if got[i].String() != want {
+					t.Errorf("network[%d] = %s, want %s", i, got[i].String(), want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseExclusionList_Malformed(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		data    string
+		wantErr string
+	}{
+		{
+			name:    "text invalid CIDR",
+			format:  exclusionFormatText,
+			data:    "10.0.0.0/16\n# comment\nnot-a-cidr\n",
+			wantErr: "reserved.txt:3:",
+		},
+		{
+			name:    "json invalid CIDR",
+			format:  exclusionFormatJSON,
+			data:    "[\n  \"10.0.0.0/16\",\n  \"10.0.0.0/33\"\n]",
+			wantErr: "reserved.txt:3:",
+		},
+		{
+			name:    "json syntax error",
+			format:  exclusionFormatJSON,
+			data:    "[\n  \"10.0.0.0/16\",\n  10.1.0.0/16\n]",
+			wantErr: "reserved.txt:3:",
+		},
+		{
+			name:    "json not an array",
+			format:  exclusionFormatJSON,
+			data:    `{"cidr": "10.0.0.0/16"}`,
+			wantErr: "expected a JSON array",
+		},
+		{
+			name:    "json non-string element",
+			format:  exclusionFormatJSON,
+			data:    "[\n  42\n]",
+			wantErr: "reserved.txt:2:",
+		},
+		{
+			name:    "json truncated",
+			format:  exclusionFormatJSON,
+			data:    "[\n  \"10.0.0.0/16\",",
+			wantErr: "reserved.txt:2:",
+		},
+		{
+			name:    "csv missing cidr column",
+			format:  exclusionFormatCSV,
+			data:    "name,range\nlegacy,10.0.0.0/16\n",
+			wantErr: "reserved.txt:1: CSV header has no \"cidr\" column",
+		},
+		{
+			name:    "csv invalid CIDR",
+			format:  exclusionFormatCSV,
+			data:    "name,cidr\nlegacy,10.0.0.0/16\nbroken,10.0.0/16\n",
+			wantErr: "reserved.txt:3:",
+		},
+		{
+			name:    "csv short row",
+			format:  exclusionFormatCSV,
+			data:    "name,cidr\nlegacy\n",
+			wantErr: "reserved.txt:2: missing value",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseExclusionList("reserved.txt", []byte(tt.data), tt.format)
+			if err == nil {
+				t.Fatal("parseExclusionList() expected error, got nil")
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("parseExclusionList() error = %q, want it to contain %q", err.Error(), tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDetectExclusionFormat(t *testing.T) {
+	tests := []struct {
+		path string
+		data string
+		want string
+	}{
+		{"reserved.json", "", exclusionFormatJSON},
+		{"reserved.CSV", "", exclusionFormatCSV},
+		{"reserved.txt", "10.0.0.0/8\n", exclusionFormatText},
+		{"reserved", "  [\"10.0.0.0/8\"]", exclusionFormatJSON},
+		{"reserved", "10.0.0.0/8\n", exclusionFormatText},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := detectExclusionFormat(tt.path, []byte(tt.data)); got != tt.want {
+				t.Errorf("detectExclusionFormat(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadExclusionsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "reserved.txt")
+	if err := os.WriteFile(path, []byte("10.0.0.0/16\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	networks, hash, err := loadExclusionsFile(path)
+	if err != nil {
+		t.Fatalf("loadExclusionsFile() error = %v", err)
+	}
+	if len(networks) != 1 || networks[0].String() != "10.0.0.0/16" {
+		t.Errorf("loadExclusionsFile() networks = %v, want [10.0.0.0/16]", networks)
+	}
+
+	if err := os.WriteFile(path, []byte("10.0.0.0/16\n10.1.0.0/16\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	_, updatedHash, err := loadExclusionsFile(path)
+	if err != nil {
+		t.Fatalf("loadExclusionsFile() error = %v", err)
+	}
+	if hash == updatedHash {
+		t.Error("expected hash to change when file contents change")
+	}
+}
+
+func TestLoadExclusionsFile_Missing(t *testing.T) {
+	_, _, err := loadExclusionsFile(filepath.Join(t.TempDir(), "missing.txt"))
+	if err == nil {
+		t.Error("expected error for missing file, got nil")
+	}
+}
+
+func TestGenerateResourceID_ExclusionsFileHash(t *testing.T) {
+	allocations := []cidr.AllocationRequest{{Name: "vpc", PrefixLength: 16}}
+
+	withoutFile := generateResourceID("10.0.0.0/8", allocations, nil, "")
+	withFile := generateResourceID("10.0.0.0/8", allocations, nil, "abc")
+	withEditedFile := generateResourceID("10.0.0.0/8", allocations, nil, "def")
+
+	if withoutFile == withFile {
+		t.Error("expected exclusions file hash to change the resource ID")
+	}
+	if withFile == withEditedFile {
+		t.Error("expected different file hashes to produce different resource IDs")
+	}
+}
@@ -0,0 +1,454 @@
+package pool
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/DO-Solutions/terraform-provider-docidr/docidr/cidr"
+	"github.com/DO-Solutions/terraform-provider-docidr/docidr/config"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+)
+
+// External IPAM systems accepted by external_allocation_api's api_type.
+const (
+	ExternalIPAMNetbox      = "netbox"
+	ExternalIPAMPHPIPAM     = "phpipam"
+	ExternalIPAMGenericJSON = "generic_json"
+)
+
+// externalIPAMTimeout bounds each request to an external IPAM system.
+const externalIPAMTimeout = 30 * time.Second
+
+// maxExternalIPAMResponseSize caps the size of an external IPAM response.
+const maxExternalIPAMResponseSize = 10 << 20
+
+// externalIPAMHTTPClient talks to external IPAM systems. It doesn't retry,
+// since a retried allocation request could reserve a second prefix.
+var externalIPAMHTTPClient = &http.Client{Timeout: externalIPAMTimeout}
+
+// externalIPAMConfig is a pool's external_allocation_api block.
+type externalIPAMConfig struct {
+	URL       string
+	AuthToken string
+	APIType   string
+}
+
+// expandExternalIPAMConfig reads the external_allocation_api block. ok is
+// false if the pool doesn't have one.
+func expandExternalIPAMConfig(raw []interface{}) (externalIPAMConfig, bool) {
+	if len(raw) == 0 || raw[0] == nil {
+		return externalIPAMConfig{}, false
+	}
+	m := raw[0].(map[string]interface{})
+	return externalIPAMConfig{
+		URL:       strings.TrimSuffix(m["url"].(string), "/"),
+		AuthToken: m["auth_token"].(string),
+		APIType:   m["api_type"].(string),
+	}, true
+}
+
+// ExternalPrefix is a prefix allocated by an external IPAM system.
+type ExternalPrefix struct {
+	// ID identifies the prefix in the external system.
+	ID   string
+	CIDR string
+}
+
+// ExternalIPAMClient allocates a pool's prefixes from an external IPAM system
+// instead of the built-in allocator.
+type ExternalIPAMClient interface {
+	// AllocatePrefix reserves a free block of prefixLength within parent,
+	// which must already be known to the external system, and describes it
+	// by the allocation's name.
+	AllocatePrefix(ctx context.Context, parent *net.IPNet, name string, prefixLength int) (ExternalPrefix, error)
+	// SyncPrefix records in the external system that the docidr_pool poolID
+	// holds the prefix as the allocation name.
+	SyncPrefix(ctx context.Context, prefix ExternalPrefix, name, poolID string) error
+	// ReleasePrefix removes a prefix allocated by AllocatePrefix, so its
+	// space is free again.
+	ReleasePrefix(ctx context.Context, id string) error
+}
+
+// newExternalIPAMClient returns the client for the configured api_type.
+func newExternalIPAMClient(api externalIPAMConfig) (ExternalIPAMClient, error) {
+	switch api.APIType {
+	case ExternalIPAMNetbox:
+		return &netboxIPAMClient{
+			api:     &ipamAPI{client: externalIPAMHTTPClient, baseURL: api.URL, header: "Authorization", token: "Token " + api.AuthToken},
+			parents: make(map[string]int),
+		}, nil
+	case ExternalIPAMPHPIPAM:
+		return &phpipamClient{
+			api:     &ipamAPI{client: externalIPAMHTTPClient, baseURL: api.URL, header: "token", token: api.AuthToken},
+			parents: make(map[string]string),
+		}, nil
+	case ExternalIPAMGenericJSON:
+		return &genericJSONIPAMClient{
+			api: &ipamAPI{client: externalIPAMHTTPClient, baseURL: api.URL, header: "Authorization", token: "Bearer " + api.AuthToken},
+		}, nil
+	}
+	return nil, fmt.Errorf("unsupported external_allocation_api api_type %q", api.APIType)
+}
+
+// ipamAPI sends authenticated JSON requests to an external IPAM system.
+type ipamAPI struct {
+	client  *http.Client
+	baseURL string
+	// header is set to token on every request.
+	header string
+	token  string
+}
+
+// ipamStatusError is returned for a response other than 2xx, with the start
+// of its body.
+type ipamStatusError struct {
+	Method     string
+	Path       string
+	Status     string
+	StatusCode int
+	Detail     string
+}
+
+func (e *ipamStatusError) Error() string {
+	return fmt.Sprintf("%s %s: unexpected status %s: %s", e.Method, e.Path, e.Status, e.Detail)
+}
+
+// do sends body, if any, as JSON to path and decodes the response into out,
+// if any. Responses other than 2xx are returned as an *ipamStatusError.
+func (a *ipamAPI) do(ctx context.Context, method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(encoded)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, a.baseURL+path, reader)
+	if err != nil {
+		return fmt.Errorf("%s %s: %w", method, path, err)
+	}
+	req.Header.Set(a.header, a.token)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s %s: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxExternalIPAMResponseSize))
+	if err != nil {
+		return fmt.Errorf("%s %s: error reading response: %w", method, path, err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		detail := strings.TrimSpace(string(data))
+		if len(detail) > 256 {
+			detail = detail[:256] + "..."
+		}
+		return &ipamStatusError{Method: method, Path: path, Status: resp.Status, StatusCode: resp.StatusCode, Detail: detail}
+	}
+	if out == nil || len(data) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("%s %s: error decoding response: %w", method, path, err)
+	}
+	return nil
+}
+
+// netboxIPAMClient allocates child prefixes of NetBox prefixes through the
+// available-prefixes endpoint. url is the root of the NetBox instance.
+type netboxIPAMClient struct {
+	api *ipamAPI
+	// parents caches the NetBox IDs of parent prefixes by CIDR.
+	parents map[string]int
+}
+
+// netboxPrefixObject is the part of a NetBox prefix object the client reads.
+type netboxPrefixObject struct {
+	ID     int    `json:"id"`
+	Prefix string `json:"prefix"`
+}
+
+func (c *netboxIPAMClient) parentID(ctx context.Context, parent *net.IPNet) (int, error) {
+	if id, ok := c.parents[parent.String()]; ok {
+		return id, nil
+	}
+
+	var list struct {
+		Results []netboxPrefixObject `json:"results"`
+	}
+	if err := c.api.do(ctx, http.MethodGet, "/api/ipam/prefixes/?prefix="+url.QueryEscape(parent.String()), nil, &list); err != nil {
+		return 0, err
+	}
+	switch len(list.Results) {
+	case 0:
+		return 0, fmt.Errorf("prefix %s not found in NetBox; create it to allocate from it", parent)
+	case 1:
+	default:
+		return 0, fmt.Errorf("prefix %s exists %d times in NetBox, so the one to allocate from is ambiguous", parent, len(list.Results))
+	}
+	c.parents[parent.String()] = list.Results[0].ID
+	return list.Results[0].ID, nil
+}
+
+func (c *netboxIPAMClient) AllocatePrefix(ctx context.Context, parent *net.IPNet, name string, prefixLength int) (ExternalPrefix, error) {
+	id, err := c.parentID(ctx, parent)
+	if err != nil {
+		return ExternalPrefix{}, err
+	}
+
+	var created netboxPrefixObject
+	body := map[string]interface{}{"prefix_length": prefixLength, "description": name}
+	if err := c.api.do(ctx, http.MethodPost, fmt.Sprintf("/api/ipam/prefixes/%d/available-prefixes/", id), body, &created); err != nil {
+		return ExternalPrefix{}, err
+	}
+	return ExternalPrefix{ID: fmt.Sprintf("%d", created.ID), CIDR: created.Prefix}, nil
+}
+
+func (c *netboxIPAMClient) SyncPrefix(ctx context.Context, prefix ExternalPrefix, name, poolID string) error {
+	body := map[string]interface{}{
+		"description": name,
+		"comments":    fmt.Sprintf("Allocated by docidr_pool %s", poolID),
+	}
+	return c.api.do(ctx, http.MethodPatch, "/api/ipam/prefixes/"+url.PathEscape(prefix.ID)+"/", body, nil)
+}
+
+func (c *netboxIPAMClient) ReleasePrefix(ctx context.Context, id string) error {
+	return c.api.do(ctx, http.MethodDelete, "/api/ipam/prefixes/"+url.PathEscape(id)+"/", nil, nil)
+}
+
+// phpipamClient allocates subnets of phpIPAM subnets through the
+// first_subnet endpoint. url is the root of the API application, such as
+// https://ipam.example.com/api/terraform, and auth_token is the
+// application's code.
+type phpipamClient struct {
+	api *ipamAPI
+	// parents caches the phpIPAM IDs of parent subnets by CIDR.
+	parents map[string]string
+}
+
+// phpipamResponse is the envelope of every phpIPAM API response. IDs are
+// sent as strings or numbers depending on the version.
+type phpipamResponse struct {
+	ID   json.Number     `json:"id"`
+	Data json.RawMessage `json:"data"`
+}
+
+func (c *phpipamClient) parentID(ctx context.Context, parent *net.IPNet) (string, error) {
+	if id, ok := c.parents[parent.String()]; ok {
+		return id, nil
+	}
+
+	var resp phpipamResponse
+	if err := c.api.do(ctx, http.MethodGet, "/subnets/cidr/"+parent.String()+"/", nil, &resp); err != nil {
+		return "", fmt.Errorf("subnet %s not found in phpIPAM: %w", parent, err)
+	}
+	var subnets []struct {
+		ID json.Number `json:"id"`
+	}
+	if err := json.Unmarshal(resp.Data, &subnets); err != nil {
+		return "", fmt.Errorf("error decoding phpIPAM subnets: %w", err)
+	}
+	switch len(subnets) {
+	case 0:
+		return "", fmt.Errorf("subnet %s not found in phpIPAM; create it to allocate from it", parent)
+	case 1:
+	default:
+		return "", fmt.Errorf("subnet %s exists %d times in phpIPAM, so the one to allocate from is ambiguous", parent, len(subnets))
+	}
+	c.parents[parent.String()] = subnets[0].ID.String()
+	return subnets[0].ID.String(), nil
+}
+
+func (c *phpipamClient) AllocatePrefix(ctx context.Context, parent *net.IPNet, name string, prefixLength int) (ExternalPrefix, error) {
+	id, err := c.parentID(ctx, parent)
+	if err != nil {
+		return ExternalPrefix{}, err
+	}
+
+	var resp phpipamResponse
+	body := map[string]interface{}{"description": name}
+	if err := c.api.do(ctx, http.MethodPost, fmt.Sprintf("/subnets/%s/first_subnet/%d/", url.PathEscape(id), prefixLength), body, &resp); err != nil {
+		return ExternalPrefix{}, err
+	}
+	var created string
+	if err := json.Unmarshal(resp.Data, &created); err != nil {
+		return ExternalPrefix{}, fmt.Errorf("error decoding phpIPAM subnet: %w", err)
+	}
+	return ExternalPrefix{ID: resp.ID.String(), CIDR: created}, nil
+}
+
+func (c *phpipamClient) SyncPrefix(ctx context.Context, prefix ExternalPrefix, name, poolID string) error {
+	body := map[string]interface{}{"description": fmt.Sprintf("%s (docidr_pool %s)", name, poolID)}
+	return c.api.do(ctx, http.MethodPatch, "/subnets/"+url.PathEscape(prefix.ID)+"/", body, nil)
+}
+
+func (c *phpipamClient) ReleasePrefix(ctx context.Context, id string) error {
+	return c.api.do(ctx, http.MethodDelete, "/subnets/"+url.PathEscape(id)+"/", nil, nil)
+}
+
+// genericJSONIPAMClient speaks a minimal JSON protocol for IPAM systems
+// without a built-in client, usually through a small adapter service:
+//
+//   - POST {url}/allocations with {"parent", "name", "prefix_length"} returns
+//     {"id", "cidr"}
+//   - PATCH {url}/allocations/{id} with {"name", "cidr", "pool_id"}
+//   - DELETE {url}/allocations/{id}
+//
+// auth_token is sent as a bearer token.
+type genericJSONIPAMClient struct {
+	api *ipamAPI
+}
+
+func (c *genericJSONIPAMClient) AllocatePrefix(ctx context.Context, parent *net.IPNet, name string, prefixLength int) (ExternalPrefix, error) {
+	var created struct {
+		ID   string `json:"id"`
+		CIDR string `json:"cidr"`
+	}
+	body := map[string]interface{}{"parent": parent.String(), "name": name, "prefix_length": prefixLength}
+	if err := c.api.do(ctx, http.MethodPost, "/allocations", body, &created); err != nil {
+		return ExternalPrefix{}, err
+	}
+	return ExternalPrefix{ID: created.ID, CIDR: created.CIDR}, nil
+}
+
+func (c *genericJSONIPAMClient) SyncPrefix(ctx context.Context, prefix ExternalPrefix, name, poolID string) error {
+	body := map[string]interface{}{"name": name, "cidr": prefix.CIDR, "pool_id": poolID}
+	return c.api.do(ctx, http.MethodPatch, "/allocations/"+url.PathEscape(prefix.ID), body, nil)
+}
+
+func (c *genericJSONIPAMClient) ReleasePrefix(ctx context.Context, id string) error {
+	return c.api.do(ctx, http.MethodDelete, "/allocations/"+url.PathEscape(id), nil, nil)
+}
+
+// allocateExternalPool allocates the pool's blocks from an external IPAM
+// system. The external system tracks which space is free, so the DigitalOcean
+// account isn't scanned and exclusions don't apply. Each block is allocated
+// from the pool's base CIDR, or the allocation's own base_cidr, which must
+// exist in the external system. If any allocation fails, the blocks already
+// allocated are released. The result's ExternalIDs holds the external ID of
+// each allocation.
+func allocateExternalPool(ctx context.Context, get func(string) interface{}, combined *config.CombinedConfig, client ExternalIPAMClient) (*poolAllocation, diag.Diagnostics) {
+	baseCIDR, diags := resolvePoolBaseCIDR(ctx, get, combined)
+	if diags.HasError() {
+		return nil, diags
+	}
+	base, err := cidr.ParseCIDR(baseCIDR)
+	if err != nil {
+		return nil, append(diags, diag.FromErr(err)...)
+	}
+
+	allocationBlocks, err := resolveAllocationSizes(get("allocation").([]interface{}), combined.SizePresets(), baseCIDR)
+	if err != nil {
+		return nil, append(diags, diag.FromErr(err)...)
+	}
+	allocationRequests := expandAllocations(allocationBlocks, get("auto_generate_names").(bool))
+	diags = append(diags, oversizedAllocationWarnings(baseCIDR, allocationRequests, get("oversize_warning_threshold").(float64))...)
+
+	results := make(map[string]string, len(allocationRequests))
+	externalIDs := make(map[string]string, len(allocationRequests))
+	release := func() {
+		for name, id := range externalIDs {
+			if err := client.ReleasePrefix(ctx, id); err != nil {
+				log.Printf("[WARN] Error releasing %s (%s) from external IPAM: %s", name, id, err)
+			}
+		}
+	}
+	for _, req := range sortAllocationRequests(allocationRequests, get("sort_strategy").(string)) {
+		parent := base
+		if req.BaseCIDR != nil {
+			parent = req.BaseCIDR
+		}
+
+		prefix, err := client.AllocatePrefix(ctx, parent, req.Name, req.PrefixLength)
+		if err != nil {
+			release()
+			return nil, append(diags, diag.Errorf("Error allocating %s from external IPAM: %s", req.Name, err)...)
+		}
+		externalIDs[req.Name] = prefix.ID
+		network, err := cidr.ParseCIDR(prefix.CIDR)
+		if err == nil {
+			if ones, _ := network.Mask.Size(); ones != req.PrefixLength || !cidr.ContainsNetwork(parent, network) {
+				err = fmt.Errorf("expected a /%d within %s", req.PrefixLength, parent)
+			}
+		}
+		if err != nil {
+			release()
+			return nil, append(diags, diag.Errorf("External IPAM allocated %q to %s: %s", prefix.CIDR, req.Name, err)...)
+		}
+		log.Printf("[DEBUG] External IPAM allocated %s to %s (%s)", network.String(), req.Name, prefix.ID)
+		results[req.Name] = network.String()
+	}
+
+	allocator, err := cidr.NewAllocator(baseCIDR)
+	if err != nil {
+		release()
+		return nil, append(diags, diag.Errorf("Error creating CIDR allocator: %s", err)...)
+	}
+	return &poolAllocation{
+		BaseCIDR:    baseCIDR,
+		Requests:    allocationRequests,
+		Results:     results,
+		Report:      &scanReport{},
+		Allocator:   allocator,
+		ExternalIDs: externalIDs,
+	}, diags
+}
+
+// syncExternalAllocations records the pool's allocations in the external IPAM
+// system they came from. The prefixes are already allocated, so failures are
+// returned as warnings.
+func syncExternalAllocations(ctx context.Context, client ExternalIPAMClient, poolID string, results, externalIDs map[string]string) diag.Diagnostics {
+	names := make([]string, 0, len(results))
+	for name := range results {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var diags diag.Diagnostics
+	for _, name := range names {
+		prefix := ExternalPrefix{ID: externalIDs[name], CIDR: results[name]}
+		if err := client.SyncPrefix(ctx, prefix, name, poolID); err != nil {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Warning,
+				Summary:  "Allocation not synced to external IPAM",
+				Detail:   fmt.Sprintf("%s (%s) was allocated, but recording docidr_pool %s in the external IPAM system failed: %s", name, results[name], poolID, err),
+			})
+		}
+	}
+	return diags
+}
+
+// releaseExternalAllocations releases the prefixes of a pool allocated from an
+// external IPAM system. Prefixes already removed from the system are ignored.
+func releaseExternalAllocations(ctx context.Context, client ExternalIPAMClient, externalIDs map[string]interface{}) error {
+	for name, id := range externalIDs {
+		err := client.ReleasePrefix(ctx, id.(string))
+		var statusErr *ipamStatusError
+		if errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound {
+			log.Printf("[DEBUG] %s (%s) was already removed from external IPAM", name, id)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("error releasing %s (%s) from external IPAM: %w", name, id, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,310 @@
+package pool
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/DO-Solutions/terraform-provider-docidr/docidr/cidr"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+// fakeNetbox is a NetBox instance holding one parent prefix, which allocates
+// child prefixes first-fit.
+type fakeNetbox struct {
+	t      *testing.T
+	parent string
+
+	mu       sync.Mutex
+	nextID   int
+	prefixes map[int]string
+	comments map[int]string
+}
+
+func newFakeNetbox(t *testing.T, parent string) (*fakeNetbox, *httptest.Server) {
+	nb := &fakeNetbox{t: t, parent: parent, nextID: 100, prefixes: make(map[int]string), comments: make(map[int]string)}
+	srv := httptest.NewServer(nb)
+	t.Cleanup(srv.Close)
+	return nb, srv
+}
+
+func (nb *fakeNetbox) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	nb.mu.Lock()
+	defer nb.mu.Unlock()
+
+	if got := r.Header.Get("Authorization"); got != "Token nb-token" {
+		http.Error(w, `{"detail":"Invalid token"}`, http.StatusForbidden)
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/api/ipam/prefixes/":
+		results := []interface{}{}
+		if r.URL.Query().Get("prefix") == nb.parent {
+			results = append(results, map[string]interface{}{"id": 1, "prefix": nb.parent})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"count": len(results), "results": results})
+	case r.Method == http.MethodPost && r.URL.Path == "/api/ipam/prefixes/1/available-prefixes/":
+		var body struct {
+			PrefixLength int    `json:"prefix_length"`
+			Description  string `json:"description"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			nb.t.Errorf("decoding available-prefixes request: %v", err)
+		}
+		var taken []string
+		for _, prefix := range nb.prefixes {
+			taken = append(taken, prefix)
+		}
+		exclusions, _ := cidr.ParseCIDRs(taken)
+		allocator, _ := cidr.NewAllocator(nb.parent)
+		results, err := allocator.AllocateWithReservations(nil, []cidr.AllocationRequest{{Name: body.Description, PrefixLength: body.PrefixLength}}, exclusions)
+		if err != nil {
+			w.WriteHeader(http.StatusConflict)
+			fmt.Fprint(w, `{"detail":"Insufficient space is available to accommodate the requested prefix size(s)"}`)
+			return
+		}
+		nb.nextID++
+		nb.prefixes[nb.nextID] = results[body.Description]
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]interface{}{"id": nb.nextID, "prefix": results[body.Description], "description": body.Description})
+	case strings.HasPrefix(r.URL.Path, "/api/ipam/prefixes/"):
+		id, err := strconv.Atoi(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/ipam/prefixes/"), "/"))
+		if _, ok := nb.prefixes[id]; err != nil || !ok {
+			http.NotFound(w, r)
+			return
+		}
+		switch r.Method {
+		case http.MethodPatch:
+			var body struct {
+				Comments string `json:"comments"`
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			nb.comments[id] = body.Comments
+			json.NewEncoder(w).Encode(map[string]interface{}{"id": id, "prefix": nb.prefixes[id]})
+		case http.MethodDelete:
+			delete(nb.prefixes, id)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestNetboxIPAMClient(t *testing.T) {
+	nb, srv := newFakeNetbox(t, "10.0.0.0/16")
+	client, err := newExternalIPAMClient(externalIPAMConfig{URL: srv.URL, AuthToken: "nb-token", APIType: ExternalIPAMNetbox})
+	if err != nil {
+		t.Fatalf("newExternalIPAMClient() error = %v", err)
+	}
+	ctx := context.Background()
+
+	first, err := client.AllocatePrefix(ctx, mustParseTestCIDR(t, "10.0.0.0/16"), "vpc", 20)
+	if err != nil {
+		t.Fatalf("AllocatePrefix() error = %v", err)
+	}
+	second, err := client.AllocatePrefix(ctx, mustParseTestCIDR(t, "10.0.0.0/16"), "k8s", 24)
+	if err != nil {
+		t.Fatalf("AllocatePrefix() error = %v", err)
+	}
+	if first.CIDR != "10.0.0.0/20" || second.CIDR != "10.0.16.0/24" {
+		t.Errorf("AllocatePrefix() = %s, %s, want 10.0.0.0/20, 10.0.16.0/24", first.CIDR, second.CIDR)
+	}
+
+	if err := client.SyncPrefix(ctx, first, "vpc", "pool-abc"); err != nil {
+		t.Fatalf("SyncPrefix() error = %v", err)
+	}
+	if got := nb.comments[101]; got != "Allocated by docidr_pool pool-abc" {
+		t.Errorf("comments after SyncPrefix() = %q", got)
+	}
+
+	if err := client.ReleasePrefix(ctx, first.ID); err != nil {
+		t.Fatalf("ReleasePrefix() error = %v", err)
+	}
+	if _, ok := nb.prefixes[101]; ok {
+		t.Error("ReleasePrefix() left the prefix in NetBox")
+	}
+
+	// The parent must exist in NetBox
+	if _, err := client.AllocatePrefix(ctx, mustParseTestCIDR(t, "192.168.0.0/16"), "vpc", 24); err == nil || !strings.Contains(err.Error(), "not found in NetBox") {
+		t.Errorf("AllocatePrefix() from an unknown parent error = %v, want not found", err)
+	}
+
+	unauthorized, _ := newExternalIPAMClient(externalIPAMConfig{URL: srv.URL, AuthToken: "wrong", APIType: ExternalIPAMNetbox})
+	if _, err := unauthorized.AllocatePrefix(ctx, mustParseTestCIDR(t, "10.0.0.0/16"), "vpc", 24); err == nil || !strings.Contains(err.Error(), "403") {
+		t.Errorf("AllocatePrefix() with a bad token error = %v, want 403", err)
+	}
+}
+
+func TestPhpipamClient(t *testing.T) {
+	var synced string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/tf/subnets/cidr/10.0.0.0/16/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("token") != "app-code" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `{"code":200,"success":true,"data":[{"id":"7","subnet":"10.0.0.0","mask":"16"}]}`)
+	})
+	mux.HandleFunc("/api/tf/subnets/7/first_subnet/24/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{"code":201,"success":true,"message":"Subnet created","id":12,"data":"10.0.0.0/24"}`)
+	})
+	mux.HandleFunc("/api/tf/subnets/12/", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		synced = body["description"]
+		fmt.Fprint(w, `{"code":200,"success":true}`)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	client, err := newExternalIPAMClient(externalIPAMConfig{URL: srv.URL + "/api/tf", AuthToken: "app-code", APIType: ExternalIPAMPHPIPAM})
+	if err != nil {
+		t.Fatalf("newExternalIPAMClient() error = %v", err)
+	}
+	prefix, err := client.AllocatePrefix(context.Background(), mustParseTestCIDR(t, "10.0.0.0/16"), "vpc", 24)
+	if err != nil {
+		t.Fatalf("AllocatePrefix() error = %v", err)
+	}
+	if prefix != (ExternalPrefix{ID: "12", CIDR: "10.0.0.0/24"}) {
+		t.Errorf("AllocatePrefix() = %+v", prefix)
+	}
+	if err := client.SyncPrefix(context.Background(), prefix, "vpc", "pool-abc"); err != nil {
+		t.Fatalf("SyncPrefix() error = %v", err)
+	}
+	if synced != "vpc (docidr_pool pool-abc)" {
+		t.Errorf("description after SyncPrefix() = %q", synced)
+	}
+}
+
+func TestGenericJSONIPAMClient(t *testing.T) {
+	var allocateBody, syncBody map[string]interface{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/allocations", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&allocateBody)
+		fmt.Fprint(w, `{"id":"a-1","cidr":"10.0.4.0/22"}`)
+	})
+	mux.HandleFunc("/allocations/a-1", func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&syncBody)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	client, err := newExternalIPAMClient(externalIPAMConfig{URL: srv.URL, AuthToken: "secret", APIType: ExternalIPAMGenericJSON})
+	if err != nil {
+		t.Fatalf("newExternalIPAMClient() error = %v", err)
+	}
+	prefix, err := client.AllocatePrefix(context.Background(), mustParseTestCIDR(t, "10.0.0.0/16"), "vpc", 22)
+	if err != nil {
+		t.Fatalf("AllocatePrefix() error = %v", err)
+	}
+	if prefix != (ExternalPrefix{ID: "a-1", CIDR: "10.0.4.0/22"}) {
+		t.Errorf("AllocatePrefix() = %+v", prefix)
+	}
+	if allocateBody["parent"] != "10.0.0.0/16" || allocateBody["name"] != "vpc" || allocateBody["prefix_length"] != float64(22) {
+		t.Errorf("allocation request = %v", allocateBody)
+	}
+	if err := client.SyncPrefix(context.Background(), prefix, "vpc", "pool-abc"); err != nil {
+		t.Fatalf("SyncPrefix() error = %v", err)
+	}
+	if syncBody["pool_id"] != "pool-abc" || syncBody["cidr"] != "10.0.4.0/22" {
+		t.Errorf("sync request = %v", syncBody)
+	}
+}
+
+func TestResourceDocidrPool_ExternalAllocationAPI(t *testing.T) {
+	nb, srv := newFakeNetbox(t, "10.0.0.0/16")
+	raw := map[string]interface{}{
+		"base_cidr": "10.0.0.0/16",
+		"external_allocation_api": []interface{}{
+			map[string]interface{}{"url": srv.URL + "/", "auth_token": "nb-token", "api_type": "netbox"},
+		},
+		"allocation": []interface{}{
+			map[string]interface{}{"name": "vpc", "prefix_length": 20},
+			map[string]interface{}{"name": "k8s", "prefix_length": 24},
+		},
+	}
+
+	// Existing VPCs don't matter: NetBox decides which space is free
+	vpcs := []interface{}{map[string]interface{}{"id": "v1", "name": "existing", "ip_range": "10.0.0.0/20"}}
+	state := applyPool(t, nil, raw, newFakeCombinedConfig(t, newFakeAccountMux(vpcs, []interface{}{})))
+
+	if got := state.Attributes["allocations.vpc"]; got != "10.0.0.0/20" {
+		t.Errorf("allocations.vpc = %s, want 10.0.0.0/20", got)
+	}
+	if got := state.Attributes["allocations.k8s"]; got != "10.0.16.0/24" {
+		t.Errorf("allocations.k8s = %s, want 10.0.16.0/24", got)
+	}
+	if state.Attributes["external_allocation_ids.vpc"] != "101" || state.Attributes["external_allocation_ids.k8s"] != "102" {
+		t.Errorf("external_allocation_ids = %s, %s, want 101, 102", state.Attributes["external_allocation_ids.vpc"], state.Attributes["external_allocation_ids.k8s"])
+	}
+	for id := range nb.prefixes {
+		if want := "Allocated by docidr_pool " + state.ID; nb.comments[id] != want {
+			t.Errorf("comments of prefix %d = %q, want %q", id, nb.comments[id], want)
+		}
+	}
+
+	// Rotating the token updates the pool in place
+	raw["external_allocation_api"] = []interface{}{
+		map[string]interface{}{"url": srv.URL + "/", "auth_token": "rotated", "api_type": "netbox"},
+	}
+	diff, err := ResourceDocidrPool().Diff(context.Background(), state, terraform.NewResourceConfigRaw(raw), newFakeCombinedConfig(t, newFakeAccountMux(vpcs, []interface{}{})))
+	if err != nil {
+		t.Fatalf("Diff() error = %v", err)
+	}
+	if diff == nil || diff.RequiresNew() {
+		t.Errorf("Diff() with a new auth_token = %+v, want an in-place update", diff)
+	}
+
+	// Destroying the pool releases its prefixes, including any already gone
+	delete(nb.prefixes, 102)
+	d := ResourceDocidrPool().Data(state)
+	if diags := resourceDocidrPoolDelete(context.Background(), d, newFakeCombinedConfig(t, newFakeAccountMux(vpcs, []interface{}{}))); diags.HasError() {
+		t.Fatalf("Delete() diags = %v", diags)
+	}
+	if len(nb.prefixes) != 0 {
+		t.Errorf("prefixes left in NetBox after Delete() = %v", nb.prefixes)
+	}
+}
+
+func TestResourceDocidrPool_ExternalAllocationAPIReleasesOnFailure(t *testing.T) {
+	nb, srv := newFakeNetbox(t, "10.0.0.0/16")
+	raw := map[string]interface{}{
+		"base_cidr": "10.0.0.0/16",
+		"external_allocation_api": []interface{}{
+			map[string]interface{}{"url": srv.URL, "auth_token": "nb-token", "api_type": "netbox"},
+		},
+		"allocation": []interface{}{
+			map[string]interface{}{"name": "vpc", "prefix_length": 17},
+			map[string]interface{}{"name": "k8s", "prefix_length": 17},
+			map[string]interface{}{"name": "db", "prefix_length": 24},
+		},
+	}
+
+	r := ResourceDocidrPool()
+	meta := newFakeCombinedConfig(t, newFakeAccountMux([]interface{}{}, []interface{}{}))
+	diff, err := r.Diff(context.Background(), nil, terraform.NewResourceConfigRaw(raw), meta)
+	if err != nil {
+		t.Fatalf("Diff() error = %v", err)
+	}
+	_, diags := r.Apply(context.Background(), nil, diff, meta)
+	if !diags.HasError() || !strings.Contains(diags[len(diags)-1].Summary, "Error allocating db from external IPAM") {
+		t.Fatalf("Apply() diags = %v, want an allocation error for db", diags)
+	}
+	if len(nb.prefixes) != 0 {
+		t.Errorf("prefixes left in NetBox after a failed allocation = %v", nb.prefixes)
+	}
+}
@@ -0,0 +1,91 @@
+package pool
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/DO-Solutions/terraform-provider-docidr/docidr/config"
+	"github.com/digitalocean/godo"
+)
+
+// newFakeGodoClient returns a godo client whose requests are served by mux.
+func newFakeGodoClient(t *testing.T, mux *http.ServeMux) *godo.Client {
+	t.Helper()
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	client := godo.NewClient(nil)
+	baseURL, err := url.Parse(srv.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.BaseURL = baseURL
+	return client
+}
+
+// newFakeCombinedConfig returns provider metadata whose godo client requests
+// are served by mux.
+func newFakeCombinedConfig(t *testing.T, mux *http.ServeMux) *config.CombinedConfig {
+	t.Helper()
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	return newTestCombinedConfig(t, &config.Config{APIEndpoint: srv.URL + "/"})
+}
+
+// servePages registers a list endpoint at path that returns each element of
+// pages as a separate page under the given JSON key, with godo-style links.
+func servePages(mux *http.ServeMux, path, key string, pages ...[]interface{}) {
+	mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+		page := 1
+		if p := r.URL.Query().Get("page"); p != "" {
+			page, _ = strconv.Atoi(p)
+		}
+
+		var items []interface{}
+		if page >= 1 && page <= len(pages) {
+			items = pages[page-1]
+		}
+		if items == nil {
+			items = []interface{}{}
+		}
+
+		links := map[string]interface{}{}
+		pageLinks := map[string]string{}
+		if page > 1 {
+			pageLinks["prev"] = fmt.Sprintf("http://example.com%s?page=%d", path, page-1)
+		}
+		if page < len(pages) {
+			pageLinks["next"] = fmt.Sprintf("http://example.com%s?page=%d", path, page+1)
+		}
+		if len(pageLinks) > 0 {
+			links["pages"] = pageLinks
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			key:     items,
+			"links": links,
+			"meta":  map[string]interface{}{"total": len(items)},
+		})
+	})
+}
+
+// serveError registers an endpoint at path that always fails with status.
+func serveError(mux *http.ServeMux, path string, status int) {
+	mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"id":      http.StatusText(status),
+			"message": "fake error",
+		})
+	})
+}
@@ -0,0 +1,110 @@
+package pool
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+
+	"github.com/DO-Solutions/terraform-provider-docidr/docidr/cidr"
+	"github.com/DO-Solutions/terraform-provider-docidr/docidr/config"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+)
+
+// netboxPageSize is the number of prefixes requested per page from NetBox,
+// which is NetBox's default maximum. Smaller pages are followed just the same.
+const netboxPageSize = 1000
+
+// collectIPAMSourceExclusions reads the prefixes of the provider's
+// ipam_source, using the prefixes cached by the provider where available.
+// Each is excluded with the source "<type>:<id>", so allocation errors name
+// the prefix that blocked them. Failures are returned as errors or warnings
+// depending on the provider's on_exclusion_source_error setting.
+func collectIPAMSourceExclusions(ctx context.Context, meta *config.CombinedConfig) ([]exclusion, diag.Diagnostics) {
+	source := meta.IPAMSource()
+	if source == nil {
+		return nil, nil
+	}
+
+	prefixes, ok := meta.CachedIPAMPrefixes()
+	if ok {
+		log.Printf("[DEBUG] Using %d cached prefixes from %s", len(prefixes), source.URL)
+	} else {
+		var err error
+		prefixes, err = fetchNetboxPrefixes(ctx, meta.IPAMHTTPClient(), source)
+		if err != nil {
+			if meta.OnExclusionSourceError() == config.ExclusionSourceErrorWarn {
+				return nil, diag.Diagnostics{{
+					Severity: diag.Warning,
+					Summary:  "Skipping IPAM exclusion source",
+					Detail:   err.Error(),
+				}}
+			}
+			return nil, diag.FromErr(err)
+		}
+		log.Printf("[DEBUG] Read %d prefixes from %s", len(prefixes), source.URL)
+		meta.CacheIPAMPrefixes(prefixes)
+	}
+
+	result := make([]exclusion, 0, len(prefixes))
+	for _, prefix := range prefixes {
+		result = append(result, exclusion{Network: prefix.Network, Source: source.Type + ":" + prefix.ID})
+	}
+	return result, nil
+}
+
+// fetchNetboxPrefixes pages through NetBox's prefixes API with the source's
+// filter, and returns every prefix but containers, which hold other prefixes
+// rather than being allocated themselves.
+func fetchNetboxPrefixes(ctx context.Context, client *http.Client, source *config.IPAMSource) ([]config.IPAMPrefix, error) {
+	api := &ipamAPI{client: client, baseURL: source.URL, header: "Authorization", token: "Token " + source.Token}
+
+	query := url.Values{}
+	keys := make([]string, 0, len(source.Filter))
+	for key := range source.Filter {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		query.Set(key, source.Filter[key])
+	}
+	query.Set("limit", strconv.Itoa(netboxPageSize))
+
+	var prefixes []config.IPAMPrefix
+	for offset := 0; ; {
+		query.Set("offset", strconv.Itoa(offset))
+		var page struct {
+			Count   int `json:"count"`
+			Results []struct {
+				ID     int    `json:"id"`
+				Prefix string `json:"prefix"`
+				Status struct {
+					Value string `json:"value"`
+				} `json:"status"`
+			} `json:"results"`
+		}
+		if err := api.do(ctx, http.MethodGet, "/api/ipam/prefixes/?"+query.Encode(), nil, &page); err != nil {
+			return nil, fmt.Errorf("error reading prefixes from NetBox %s: %w", source.URL, err)
+		}
+
+		for _, result := range page.Results {
+			if result.Status.Value == NetboxStatusContainer {
+				log.Printf("[DEBUG] Not excluding NetBox container prefix %s (%d)", result.Prefix, result.ID)
+				continue
+			}
+			network, err := cidr.ParseCIDR(result.Prefix)
+			if err != nil {
+				return nil, fmt.Errorf("error parsing prefix %d from NetBox %s: %w", result.ID, source.URL, err)
+			}
+			prefixes = append(prefixes, config.IPAMPrefix{ID: strconv.Itoa(result.ID), Network: network})
+		}
+
+		offset += len(page.Results)
+		if len(page.Results) == 0 || offset >= page.Count {
+			return prefixes, nil
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package pool
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/DO-Solutions/terraform-provider-docidr/docidr/config"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+)
+
+// newNetboxPrefixesServer serves NetBox's prefixes API with prefixes, two to
+// a page whatever limit is asked for, counting the requests in hits.
+func newNetboxPrefixesServer(t *testing.T, hits *int32, prefixes []map[string]interface{}) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/ipam/prefixes/", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(hits, 1)
+		if r.Header.Get("Authorization") != "Token nb-token" {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"detail":"Invalid token"}`))
+			return
+		}
+		if got := r.URL.Query().Get("vrf_id"); got != "3" {
+			t.Errorf("vrf_id = %q, want the filter's 3", got)
+		}
+
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		end := offset + 2
+		if end > len(prefixes) {
+			end = len(prefixes)
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"count":   len(prefixes),
+			"results": prefixes[offset:end],
+		})
+	})
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func netboxPrefixResult(id int, prefix, status string) map[string]interface{} {
+	return map[string]interface{}{
+		"id":     id,
+		"prefix": prefix,
+		"status": map[string]interface{}{"value": status, "label": strings.ToUpper(status[:1]) + status[1:]},
+	}
+}
+
+func TestCollectIPAMSourceExclusions(t *testing.T) {
+	var hits int32
+	srv := newNetboxPrefixesServer(t, &hits, []map[string]interface{}{
+		netboxPrefixResult(1, "10.0.0.0/8", "container"),
+		netboxPrefixResult(7, "10.0.0.0/16", "active"),
+		netboxPrefixResult(8, "10.1.0.0/20", "reserved"),
+		netboxPrefixResult(12, "10.2.0.0/24", "deprecated"),
+		netboxPrefixResult(15, "fd00:1::/48", "active"),
+	})
+
+	combined := newTestCombinedConfig(t, &config.Config{
+		IPAMSource: &config.IPAMSource{Type: config.IPAMSourceNetbox, URL: srv.URL, Token: "nb-token", Filter: map[string]string{"vrf_id": "3"}},
+	})
+
+	want := []string{
+		"10.0.0.0/16 (source: netbox:7)",
+		"10.1.0.0/20 (source: netbox:8)",
+		"10.2.0.0/24 (source: netbox:12)",
+		"fd00:1::/48 (source: netbox:15)",
+	}
+	for i := 0; i < 2; i++ {
+		exclusions, diags := collectIPAMSourceExclusions(context.Background(), combined)
+		if diags.HasError() {
+			t.Fatalf("collectIPAMSourceExclusions() diags = %v", diags)
+		}
+		var got []string
+		for _, e := range exclusions {
+			got = append(got, e.String())
+		}
+		if strings.Join(got, ", ") != strings.Join(want, ", ") {
+			t.Errorf("collectIPAMSourceExclusions() = %v, want %v", got, want)
+		}
+	}
+
+	// Three pages are read once, then cached
+	if got := atomic.LoadInt32(&hits); got != 3 {
+		t.Errorf("server hits = %d, want 3", got)
+	}
+}
+
+func TestCollectIPAMSourceExclusions_ErrorPolicy(t *testing.T) {
+	var hits int32
+	srv := newNetboxPrefixesServer(t, &hits, nil)
+
+	for _, policy := range []string{config.ExclusionSourceErrorFail, config.ExclusionSourceErrorWarn} {
+		combined := newTestCombinedConfig(t, &config.Config{
+			OnExclusionSourceError: policy,
+			IPAMSource:             &config.IPAMSource{Type: config.IPAMSourceNetbox, URL: srv.URL, Token: "wrong"},
+		})
+
+		exclusions, diags := collectIPAMSourceExclusions(context.Background(), combined)
+		if len(exclusions) != 0 || len(diags) != 1 || !strings.Contains(diags[0].Summary+diags[0].Detail, "403") {
+			t.Fatalf("collectIPAMSourceExclusions() with policy %s = %v, %v, want a 403 diagnostic", policy, exclusions, diags)
+		}
+		wantSeverity := diag.Error
+		if policy == config.ExclusionSourceErrorWarn {
+			wantSeverity = diag.Warning
+		}
+		if diags[0].Severity != wantSeverity {
+			t.Errorf("collectIPAMSourceExclusions() with policy %s severity = %v, want %v", policy, diags[0].Severity, wantSeverity)
+		}
+	}
+
+	// Without a source nothing is read
+	if exclusions, diags := collectIPAMSourceExclusions(context.Background(), newTestCombinedConfig(t, &config.Config{})); len(exclusions) != 0 || len(diags) != 0 {
+		t.Errorf("collectIPAMSourceExclusions() without a source = %v, %v", exclusions, diags)
+	}
+}
+
+func TestCollectIPAMSourceExclusions_TokenNotLogged(t *testing.T) {
+	var hits int32
+	srv := newNetboxPrefixesServer(t, &hits, []map[string]interface{}{
+		netboxPrefixResult(7, "10.0.0.0/16", "active"),
+	})
+
+	t.Setenv("TF_LOG", "DEBUG")
+	var logged bytes.Buffer
+	log.SetOutput(&logged)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	combined := newTestCombinedConfig(t, &config.Config{
+		IPAMSource: &config.IPAMSource{Type: config.IPAMSourceNetbox, URL: srv.URL, Token: "nb-token", Filter: map[string]string{"vrf_id": "3"}},
+	})
+	if _, diags := collectIPAMSourceExclusions(context.Background(), combined); diags.HasError() {
+		t.Fatalf("collectIPAMSourceExclusions() diags = %v", diags)
+	}
+
+	if !strings.Contains(logged.String(), "Read 1 prefixes") {
+		t.Fatalf("log = %q, want the prefixes read logged", logged.String())
+	}
+	if strings.Contains(logged.String(), "nb-token") {
+		t.Errorf("log contains the ipam_source token:\n%s", logged.String())
+	}
+}
+
+func TestResourceDocidrPoolCreate_IPAMSource(t *testing.T) {
+	var hits int32
+	netbox := newNetboxPrefixesServer(t, &hits, []map[string]interface{}{
+		netboxPrefixResult(1, "10.0.0.0/16", "container"),
+		netboxPrefixResult(7, "10.0.0.0/20", "active"),
+	})
+	api := httptest.NewServer(newFakeAccountMux([]interface{}{}, []interface{}{}))
+	t.Cleanup(api.Close)
+
+	meta := newTestCombinedConfig(t, &config.Config{
+		APIEndpoint: api.URL + "/",
+		IPAMSource:  &config.IPAMSource{Type: config.IPAMSourceNetbox, URL: netbox.URL, Token: "nb-token", Filter: map[string]string{"vrf_id": "3"}},
+	})
+	state := applyPool(t, nil, map[string]interface{}{
+		"base_cidr": "10.0.0.0/16",
+		"allocation": []interface{}{
+			map[string]interface{}{"name": "vpc", "prefix_length": 20},
+		},
+	}, meta)
+
+	if got := state.Attributes["allocations.vpc"]; got != "10.0.16.0/20" {
+		t.Errorf("allocations.vpc = %s, want 10.0.16.0/20 after the NetBox prefix", got)
+	}
+}
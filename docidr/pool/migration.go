@@ -0,0 +1,121 @@
+package pool
+
+import (
+	"fmt"
+	"log"
+	"net"
+
+	"github.com/DO-Solutions/terraform-provider-docidr/docidr/cidr"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// migrationMove is an allocation that couldn't keep its offset when its pool
+// was migrated to a new base. Target is the CIDR at the same offset in the new
+// base, or empty if there is none.
+type migrationMove struct {
+	Name     string
+	Previous string
+	Target   string
+	Reason   string
+}
+
+// migrationReservations returns reservations that move each request's
+// previous CIDR to the same offset in the allocator's base CIDR as it had in
+// sourceBase, and the requests that can't keep their offset. Requests with
+// their own base_cidr aren't migrated, so they keep their previous CIDR if
+// it's still available. The rest of the requests are allocated as usual.
+func migrationReservations(previous map[string]interface{}, sourceBase string, requests []cidr.AllocationRequest, allocator *cidr.Allocator, exclusions []*net.IPNet) ([]cidr.ReservationRequest, []migrationMove, error) {
+	source, err := cidr.ParseCIDR(sourceBase)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	targets := make(map[string]string, len(previous))
+	var moved []migrationMove
+	for _, req := range requests {
+		block, ok := previous[req.Name]
+		if !ok {
+			continue
+		}
+		if req.BaseCIDR != nil {
+			targets[req.Name] = block.(string)
+			continue
+		}
+		network, err := cidr.ParseCIDR(block.(string))
+		if err == nil {
+			network, err = cidr.RebaseNetwork(network, source, allocator.BaseCIDR())
+		}
+		if err != nil {
+			moved = append(moved, migrationMove{Name: req.Name, Previous: block.(string), Reason: err.Error()})
+			continue
+		}
+		targets[req.Name] = network.String()
+	}
+
+	reservations, unavailable := allocator.PreferPrevious(targets, requests, exclusions)
+	for _, m := range unavailable {
+		moved = append(moved, migrationMove{Name: m.Name, Previous: previous[m.Name].(string), Target: m.Previous, Reason: m.Reason})
+	}
+	for _, r := range reservations {
+		log.Printf("[INFO] Migrating %q from %s to %s", r.Name, previous[r.Name], r.CIDR.String())
+	}
+	return reservations, moved, nil
+}
+
+// migrationWarnings returns a warning for each allocation that couldn't keep
+// its offset in the new base, naming the CIDR it was given instead.
+func migrationWarnings(moved []migrationMove, results map[string]string) diag.Diagnostics {
+	var diags diag.Diagnostics
+	for _, m := range moved {
+		detail := fmt.Sprintf("Allocation %q couldn't keep the offset of %s in the new base because %s, so it was allocated %s.", m.Name, m.Previous, m.Reason, results[m.Name])
+		if m.Target != "" {
+			detail = fmt.Sprintf("Allocation %q couldn't move from %s to %s, at the same offset in the new base, because %s, so it was allocated %s.", m.Name, m.Previous, m.Target, m.Reason, results[m.Name])
+		}
+		log.Printf("[INFO] %s", detail)
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Warning,
+			Summary:  "Allocation offset not kept",
+			Detail:   detail,
+		})
+	}
+	return diags
+}
+
+// flattenMigrationMap returns the old and new CIDR of each request with a
+// previous CIDR, in request order.
+func flattenMigrationMap(previous map[string]interface{}, results map[string]string, requests []cidr.AllocationRequest) []interface{} {
+	result := make([]interface{}, 0, len(previous))
+	for _, req := range requests {
+		old, ok := previous[req.Name]
+		if !ok {
+			continue
+		}
+		result = append(result, map[string]interface{}{
+			"name":     req.Name,
+			"old_cidr": old,
+			"new_cidr": results[req.Name],
+		})
+	}
+	return result
+}
+
+// finishesMigration reports whether the only change planned for an existing
+// pool, other than to inPlaceAttributes, is clearing migrate_to_base once
+// base_cidr contains the base it migrated to, possibly along with growing
+// base_cidr, which is made in place.
+func finishesMigration(diff *schema.ResourceDiff) bool {
+	if diff.Id() == "" || !diff.NewValueKnown("migrate_to_base") || !diff.NewValueKnown("base_cidr") {
+		return false
+	}
+	old, new := diff.GetChange("migrate_to_base")
+	if old.(string) == "" || new.(string) != "" {
+		return false
+	}
+	for _, key := range replacingChanges(diff) {
+		if key != "base_cidr" && key != "migrate_to_base" {
+			return false
+		}
+	}
+	return isGrownBaseCIDR(old.(string), diff.Get("base_cidr").(string))
+}
@@ -0,0 +1,285 @@
+package pool
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/DO-Solutions/terraform-provider-docidr/docidr/cidr"
+	"github.com/DO-Solutions/terraform-provider-docidr/docidr/config"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+func TestMigrationReservations(t *testing.T) {
+	allocator, err := cidr.NewAllocator("172.16.0.0/16")
+	if err != nil {
+		t.Fatalf("NewAllocator() error = %v", err)
+	}
+	previous := map[string]interface{}{
+		"kept":        "10.0.4.0/24",
+		"excluded":    "10.0.5.0/24",
+		"past_end":    "10.1.0.0/24",
+		"resized":     "10.0.6.0/24",
+		"own_base":    "192.168.1.0/24",
+		"not_asked":   "10.0.7.0/24",
+		"outside_old": "10.16.0.0/24",
+	}
+	requests := []cidr.AllocationRequest{
+		{Name: "kept", PrefixLength: 24},
+		{Name: "excluded", PrefixLength: 24},
+		{Name: "past_end", PrefixLength: 24},
+		{Name: "resized", PrefixLength: 23},
+		{Name: "own_base", PrefixLength: 24, BaseCIDR: mustParseTestCIDR(t, "192.168.0.0/16")},
+		{Name: "outside_old", PrefixLength: 24},
+		{Name: "new", PrefixLength: 24},
+	}
+
+	reservations, moved, err := migrationReservations(previous, "10.0.0.0/12", requests, allocator, []*net.IPNet{mustParseTestCIDR(t, "172.16.5.0/25")})
+	if err != nil {
+		t.Fatalf("migrationReservations() error = %v", err)
+	}
+
+	got := make(map[string]string)
+	for _, r := range reservations {
+		got[r.Name] = r.CIDR.String()
+	}
+	want := map[string]string{"kept": "172.16.4.0/24", "own_base": "192.168.1.0/24"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("migrationReservations() = %v, want %v", got, want)
+	}
+
+	wantMoved := []migrationMove{
+		{Name: "past_end", Previous: "10.1.0.0/24", Reason: "the offset of 10.1.0.0/24 within 10.0.0.0/12 is outside 172.16.0.0/16"},
+		{Name: "outside_old", Previous: "10.16.0.0/24", Reason: "10.16.0.0/24 is not within 10.0.0.0/12"},
+		{Name: "excluded", Previous: "10.0.5.0/24", Target: "172.16.5.0/24", Reason: "it overlaps exclusion 172.16.5.0/25"},
+		{Name: "resized", Previous: "10.0.6.0/24", Target: "172.16.6.0/24", Reason: "the request is now a /23"},
+	}
+	if !reflect.DeepEqual(moved, wantMoved) {
+		t.Errorf("migrationReservations() moved = %+v, want %+v", moved, wantMoved)
+	}
+}
+
+func TestFlattenMigrationMap(t *testing.T) {
+	previous := map[string]interface{}{"b": "10.0.1.0/24", "a": "10.0.0.0/24", "gone": "10.0.2.0/24"}
+	results := map[string]string{"a": "10.64.0.0/24", "b": "10.64.1.0/24", "new": "10.64.2.0/24"}
+	requests := []cidr.AllocationRequest{{Name: "b"}, {Name: "a"}, {Name: "new"}}
+
+	want := []interface{}{
+		map[string]interface{}{"name": "b", "old_cidr": "10.0.1.0/24", "new_cidr": "10.64.1.0/24"},
+		map[string]interface{}{"name": "a", "old_cidr": "10.0.0.0/24", "new_cidr": "10.64.0.0/24"},
+	}
+	if got := flattenMigrationMap(previous, results, requests); !reflect.DeepEqual(got, want) {
+		t.Errorf("flattenMigrationMap() = %v, want %v", got, want)
+	}
+}
+
+func TestResourceDocidrPool_MigrateToBase(t *testing.T) {
+	srv := httptest.NewServer(newFakeAccountMux([]interface{}{}, []interface{}{}))
+	t.Cleanup(srv.Close)
+	newMeta := func() *config.CombinedConfig {
+		return newTestCombinedConfig(t, &config.Config{APIEndpoint: srv.URL + "/"})
+	}
+	pool := func(baseCIDR, migrateToBase string, exclude []string, names ...string) map[string]interface{} {
+		allocations := []interface{}{
+			map[string]interface{}{"name": "vpc", "prefix_length": 16},
+			map[string]interface{}{"name": "k8s", "prefix_length": 20},
+			map[string]interface{}{"name": "lb", "prefix_length": 24},
+		}
+		for _, name := range names {
+			allocations = append(allocations, map[string]interface{}{"name": name, "prefix_length": 24})
+		}
+		var blocks []interface{}
+		for _, c := range exclude {
+			blocks = append(blocks, map[string]interface{}{"cidr": c})
+		}
+		raw := map[string]interface{}{
+			"base_cidr":  baseCIDR,
+			"exclude":    blocks,
+			"allocation": allocations,
+		}
+		if migrateToBase != "" {
+			raw["migrate_to_base"] = migrateToBase
+		}
+		return raw
+	}
+	plan := func(state *terraform.InstanceState, raw map[string]interface{}) *terraform.InstanceDiff {
+		t.Helper()
+		diff, err := ResourceDocidrPool().Diff(context.Background(), state, terraform.NewResourceConfigRaw(raw), newMeta())
+		if err != nil {
+			t.Fatalf("Diff() error = %v", err)
+		}
+		return diff
+	}
+	apply := func(state *terraform.InstanceState, raw map[string]interface{}) (*terraform.InstanceState, diag.Diagnostics) {
+		t.Helper()
+		meta := newMeta()
+		r := ResourceDocidrPool()
+		diff, err := r.Diff(context.Background(), state, terraform.NewResourceConfigRaw(raw), meta)
+		if err != nil {
+			t.Fatalf("Diff() error = %v", err)
+		}
+		newState, diags := r.Apply(context.Background(), state, diff, meta)
+		if diags.HasError() {
+			t.Fatalf("Apply() diags = %v", diags)
+		}
+		return newState, diags
+	}
+	checkAllocations := func(t *testing.T, state *terraform.InstanceState, prefix string, want map[string]string) {
+		t.Helper()
+		for name, block := range want {
+			if got := state.Attributes[prefix+"."+name]; got != block {
+				t.Errorf("%s.%s = %q, want %q", prefix, name, got, block)
+			}
+		}
+		if got := state.Attributes[prefix+".%"]; got != "" && got != "0" && len(want) == 0 {
+			t.Errorf("%s has %s entries, want none", prefix, got)
+		}
+	}
+
+	// The first /16 is excluded, so first fit wouldn't keep the offsets
+	exclude := []string{"10.0.0.0/16", "10.64.0.0/16"}
+	original, _ := apply(nil, pool("10.0.0.0/12", "", exclude))
+	old := map[string]string{"vpc": "10.1.0.0/16", "k8s": "10.2.0.0/20", "lb": "10.2.16.0/24"}
+	checkAllocations(t, original, "allocations", old)
+
+	// Migrating replaces the pool, keeping every allocation's offset
+	if diff := plan(original, pool("10.0.0.0/12", "10.64.0.0/12", exclude)); !diff.RequiresNew() {
+		t.Error("setting migrate_to_base RequiresNew() = false, want true")
+	}
+	migrated, diags := apply(original, pool("10.0.0.0/12", "10.64.0.0/12", exclude))
+	if len(diags) != 0 {
+		t.Errorf("migration diags = %v, want none", diags)
+	}
+	offsets := map[string]string{"vpc": "10.65.0.0/16", "k8s": "10.66.0.0/20", "lb": "10.66.16.0/24"}
+	checkAllocations(t, migrated, "allocations", offsets)
+	checkAllocations(t, migrated, "previous_allocations", old)
+	if got := migrated.Attributes["base_cidr"]; got != "10.64.0.0/12" {
+		t.Errorf("base_cidr = %q, want 10.64.0.0/12", got)
+	}
+	var migrationMap []string
+	for i := 0; i < 3; i++ {
+		prefix := fmt.Sprintf("migration_map.%d.", i)
+		migrationMap = append(migrationMap, migrated.Attributes[prefix+"name"]+" "+migrated.Attributes[prefix+"old_cidr"]+" "+migrated.Attributes[prefix+"new_cidr"])
+	}
+	wantMap := []string{"vpc 10.1.0.0/16 10.65.0.0/16", "k8s 10.2.0.0/20 10.66.0.0/20", "lb 10.2.16.0/24 10.66.16.0/24"}
+	if migrated.Attributes["migration_map.#"] != "3" || !reflect.DeepEqual(migrationMap, wantMap) {
+		t.Errorf("migration_map = %v (%s entries), want %v", migrationMap, migrated.Attributes["migration_map.#"], wantMap)
+	}
+
+	// Planning the migrated pool again shows no changes
+	if diff := plan(migrated, pool("10.0.0.0/12", "10.64.0.0/12", exclude)); diff != nil && !diff.Empty() {
+		t.Errorf("Diff() after migrating = %v, want no changes", diff.Attributes)
+	}
+
+	// Nothing else that would replace the pool can change until the
+	// migration is finished or abandoned
+	for _, raw := range []map[string]interface{}{
+		pool("10.0.0.0/12", "10.64.0.0/12", exclude, "extra"),
+		pool("10.0.0.0/12", "10.128.0.0/12", exclude),
+	} {
+		_, err := ResourceDocidrPool().Diff(context.Background(), migrated, terraform.NewResourceConfigRaw(raw), newMeta())
+		if err == nil || !strings.Contains(err.Error(), "docidr_pool is migrating to 10.64.0.0/12; finish the migration by setting base_cidr to 10.64.0.0/12") {
+			t.Errorf("Diff() changing a migrating pool error = %v, want the migration to be finished first", err)
+		}
+	}
+
+	// Finishing the migration is made in place and drops the old allocations
+	finished := pool("10.64.0.0/12", "", exclude)
+	if diff := plan(migrated, finished); diff == nil || diff.RequiresNew() {
+		t.Errorf("Diff() finishing the migration = %v, want an in-place update", diff)
+	}
+	done, _ := apply(migrated, finished)
+	if done.ID != migrated.ID {
+		t.Errorf("ID = %s after finishing the migration, want %s", done.ID, migrated.ID)
+	}
+	checkAllocations(t, done, "allocations", offsets)
+	checkAllocations(t, done, "previous_allocations", nil)
+	if got := done.Attributes["migration_map.#"]; got != "" && got != "0" {
+		t.Errorf("migration_map has %s entries after finishing the migration, want none", got)
+	}
+	if diff := plan(done, finished); diff != nil && !diff.Empty() {
+		t.Errorf("Diff() after finishing the migration = %v, want no changes", diff.Attributes)
+	}
+
+	// Clearing migrate_to_base without moving base_cidr replaces the pool
+	if diff := plan(migrated, pool("10.0.0.0/12", "", exclude)); !diff.RequiresNew() {
+		t.Error("abandoning the migration RequiresNew() = false, want true")
+	}
+}
+
+func TestResourceDocidrPool_MigrateToBaseConflict(t *testing.T) {
+	srv := httptest.NewServer(newFakeAccountMux([]interface{}{}, []interface{}{}))
+	t.Cleanup(srv.Close)
+	newMeta := func() *config.CombinedConfig {
+		return newTestCombinedConfig(t, &config.Config{APIEndpoint: srv.URL + "/"})
+	}
+	pool := func(migrateToBase string, exclude ...string) map[string]interface{} {
+		var blocks []interface{}
+		for _, c := range exclude {
+			blocks = append(blocks, map[string]interface{}{"cidr": c})
+		}
+		raw := map[string]interface{}{
+			"base_cidr": "10.0.0.0/16",
+			"exclude":   blocks,
+			"allocation": []interface{}{
+				map[string]interface{}{"name": "a", "prefix_length": 24},
+				map[string]interface{}{"name": "b", "prefix_length": 24},
+				map[string]interface{}{"name": "c", "prefix_length": 24},
+			},
+		}
+		if migrateToBase != "" {
+			raw["migrate_to_base"] = migrateToBase
+		}
+		return raw
+	}
+
+	original := applyPool(t, nil, pool(""), newMeta())
+
+	// b's offset is taken in the new base, so only b moves, with a warning
+	r := ResourceDocidrPool()
+	meta := newMeta()
+	diff, err := r.Diff(context.Background(), original, terraform.NewResourceConfigRaw(pool("172.16.0.0/16", "172.16.1.0/24")), meta)
+	if err != nil {
+		t.Fatalf("Diff() error = %v", err)
+	}
+	migrated, diags := r.Apply(context.Background(), original, diff, meta)
+	if diags.HasError() {
+		t.Fatalf("Apply() diags = %v", diags)
+	}
+	want := map[string]string{"a": "172.16.0.0/24", "b": "172.16.3.0/24", "c": "172.16.2.0/24"}
+	for name, block := range want {
+		if got := migrated.Attributes["allocations."+name]; got != block {
+			t.Errorf("allocations.%s = %q, want %q", name, got, block)
+		}
+	}
+	if len(diags) != 1 || diags[0].Summary != "Allocation offset not kept" ||
+		!strings.Contains(diags[0].Detail, `"b" couldn't move from 10.0.1.0/24 to 172.16.1.0/24, at the same offset in the new base, because it overlaps exclusion 172.16.1.0/24, so it was allocated 172.16.3.0/24`) {
+		t.Errorf("diags = %v, want an Allocation offset not kept warning for b", diags)
+	}
+	if got := migrated.Attributes["migration_map.1.new_cidr"]; got != "172.16.3.0/24" {
+		t.Errorf("migration_map.1.new_cidr = %q, want 172.16.3.0/24", got)
+	}
+
+	// An offset past the end of a smaller new base moves that allocation too
+	original = applyPool(t, nil, pool("", "10.0.2.0/23"), newMeta())
+	meta = newMeta()
+	diff, err = r.Diff(context.Background(), original, terraform.NewResourceConfigRaw(pool("172.16.0.0/22", "10.0.2.0/23")), meta)
+	if err != nil {
+		t.Fatalf("Diff() error = %v", err)
+	}
+	migrated, diags = r.Apply(context.Background(), original, diff, meta)
+	if diags.HasError() {
+		t.Fatalf("Apply() diags = %v", diags)
+	}
+	if got := migrated.Attributes["allocations.c"]; got != "172.16.2.0/24" {
+		t.Errorf("allocations.c = %q, want 172.16.2.0/24", got)
+	}
+	if len(diags) != 1 || !strings.Contains(diags[0].Detail, `"c" couldn't keep the offset of 10.0.4.0/24 in the new base because the offset of 10.0.4.0/24 within 10.0.0.0/16 is outside 172.16.0.0/22`) {
+		t.Errorf("diags = %v, want an Allocation offset not kept warning for c", diags)
+	}
+}
@@ -0,0 +1,144 @@
+package pool
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/DO-Solutions/terraform-provider-docidr/docidr/cidr"
+)
+
+// NetBox prefix statuses accepted by netbox_defaults.
+const (
+	NetboxStatusActive     = "active"
+	NetboxStatusReserved   = "reserved"
+	NetboxStatusContainer  = "container"
+	NetboxStatusDeprecated = "deprecated"
+)
+
+// NetBox object types a prefix may be scoped to with netbox_defaults.
+const (
+	NetboxScopeRegion    = "dcim.region"
+	NetboxScopeSiteGroup = "dcim.sitegroup"
+	NetboxScopeSite      = "dcim.site"
+	NetboxScopeLocation  = "dcim.location"
+)
+
+// netboxDefaults are the fields of netbox_defaults, copied into every prefix
+// of the netbox_export attribute.
+type netboxDefaults struct {
+	Site      string
+	ScopeType string
+	ScopeID   int
+	Tenant    string
+	Role      string
+	Status    string
+}
+
+// expandNetboxDefaults reads the netbox_defaults block. Prefixes are active
+// unless another status is configured.
+func expandNetboxDefaults(raw []interface{}) netboxDefaults {
+	defaults := netboxDefaults{Status: NetboxStatusActive}
+	if len(raw) == 0 || raw[0] == nil {
+		return defaults
+	}
+	m := raw[0].(map[string]interface{})
+	defaults.Site = m["site"].(string)
+	defaults.ScopeType = m["scope_type"].(string)
+	defaults.ScopeID = m["scope_id"].(int)
+	defaults.Tenant = m["tenant"].(string)
+	defaults.Role = m["role"].(string)
+	if status := m["status"].(string); status != "" {
+		defaults.Status = status
+	}
+	return defaults
+}
+
+// netboxRef refers to an existing NetBox object by name, as NetBox's REST API
+// accepts in place of an ID for related objects and tags.
+type netboxRef struct {
+	Name string `json:"name"`
+}
+
+// newNetboxRef returns a reference to the NetBox object called name, or nil
+// when name is empty, so the field is left out.
+func newNetboxRef(name string) *netboxRef {
+	if name == "" {
+		return nil
+	}
+	return &netboxRef{Name: name}
+}
+
+// netboxPrefix is a prefix as written to NetBox 4.2 and later by a POST to
+// /api/ipam/prefixes/, as in the netbox_export attribute. Fields are marshaled
+// in declaration order, so the output is stable. The scope is an object type
+// and ID, since NetBox 4.2 replaced a prefix's site with a scope; a site
+// configured by name is a dcim.site scope whose Scope names the site instead,
+// for the importer to resolve to its ID. Tenant, role and tags name existing
+// NetBox objects. Fields that aren't configured are left out.
+type netboxPrefix struct {
+	Prefix      string      `json:"prefix"`
+	Status      string      `json:"status"`
+	ScopeType   string      `json:"scope_type,omitempty"`
+	ScopeID     int         `json:"scope_id,omitempty"`
+	Scope       *netboxRef  `json:"scope,omitempty"`
+	Tenant      *netboxRef  `json:"tenant,omitempty"`
+	Role        *netboxRef  `json:"role,omitempty"`
+	Description string      `json:"description"`
+	Tags        []netboxRef `json:"tags"`
+}
+
+// buildNetboxExport returns the netbox_export attribute: a prefix for each
+// allocation, sorted by name, described by the allocation's name and tagged
+// with tags followed by its region and group, if set.
+func buildNetboxExport(results map[string]string, requests []cidr.AllocationRequest, tags []string, defaults netboxDefaults) (string, error) {
+	byName := make(map[string]cidr.AllocationRequest, len(requests))
+	for _, req := range requests {
+		byName[req.Name] = req
+	}
+
+	names := make([]string, 0, len(results))
+	for name := range results {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	prefixes := make([]netboxPrefix, 0, len(names))
+	for _, name := range names {
+		network, err := cidr.ParseCIDR(results[name])
+		if err != nil {
+			return "", err
+		}
+
+		prefixTags := make([]netboxRef, 0, len(tags)+2)
+		for _, tag := range tags {
+			prefixTags = append(prefixTags, netboxRef{Name: tag})
+		}
+		for _, tag := range []string{byName[name].Region, byName[name].Group} {
+			if tag != "" {
+				prefixTags = append(prefixTags, netboxRef{Name: tag})
+			}
+		}
+
+		prefix := netboxPrefix{
+			Prefix:      network.String(),
+			Status:      defaults.Status,
+			ScopeType:   defaults.ScopeType,
+			ScopeID:     defaults.ScopeID,
+			Tenant:      newNetboxRef(defaults.Tenant),
+			Role:        newNetboxRef(defaults.Role),
+			Description: name,
+			Tags:        prefixTags,
+		}
+		if defaults.Site != "" {
+			prefix.ScopeType = NetboxScopeSite
+			prefix.Scope = newNetboxRef(defaults.Site)
+		}
+		prefixes = append(prefixes, prefix)
+	}
+
+	out, err := json.MarshalIndent(prefixes, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
@@ -0,0 +1,185 @@
+package pool
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/DO-Solutions/terraform-provider-docidr/docidr/cidr"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+// netboxPrefixFields are the writable fields of a prefix in NetBox 4.2's REST
+// API, from its PrefixSerializer.
+var netboxPrefixFields = map[string]bool{
+	"prefix": true, "vrf": true, "scope_type": true, "scope_id": true,
+	"tenant": true, "vlan": true, "status": true, "role": true,
+	"is_pool": true, "mark_utilized": true, "description": true,
+	"comments": true, "tags": true, "custom_fields": true,
+}
+
+// netboxSiteScopeField names the site of a prefix whose netbox_defaults set
+// site. It isn't a NetBox field; importers replace it with scope_id.
+const netboxSiteScopeField = "scope"
+
+// assertNetboxExport checks that doc is the JSON of want, a request body for
+// POST /api/ipam/prefixes/ in NetBox 4.2, and that every prefix only sets
+// fields NetBox accepts, besides a site scope to resolve.
+func assertNetboxExport(t *testing.T, doc, want string) {
+	t.Helper()
+
+	var got, wanted []map[string]interface{}
+	if err := json.Unmarshal([]byte(doc), &got); err != nil {
+		t.Fatalf("netbox_export isn't a JSON array of prefixes: %v\n%s", err, doc)
+	}
+	if err := json.Unmarshal([]byte(want), &wanted); err != nil {
+		t.Fatalf("invalid expected netbox_export: %v", err)
+	}
+
+	for _, prefix := range got {
+		for field := range prefix {
+			if !netboxPrefixFields[field] && field != netboxSiteScopeField {
+				t.Errorf("netbox_export sets %q, which NetBox prefixes don't have", field)
+			}
+		}
+	}
+	if !reflect.DeepEqual(got, wanted) {
+		t.Errorf("netbox_export = %s, want %s", doc, want)
+	}
+}
+
+func TestBuildNetboxExport(t *testing.T) {
+	requests := []cidr.AllocationRequest{
+		{Name: "vpc", PrefixLength: 20, Region: "nyc1", Group: "production"},
+		{Name: "k8s", PrefixLength: 24},
+		{Name: "db", PrefixLength: 28, Region: "sfo3"},
+	}
+	results := map[string]string{
+		"vpc": "10.0.0.0/20",
+		"k8s": "10.0.16.0/24",
+		"db":  "10.0.17.0/28",
+	}
+	defaults := netboxDefaults{ScopeType: NetboxScopeSite, ScopeID: 12, Tenant: "Platform", Role: "Production", Status: NetboxStatusReserved}
+
+	got, err := buildNetboxExport(results, requests, []string{"managed-by-docidr"}, defaults)
+	if err != nil {
+		t.Fatalf("buildNetboxExport() error = %v", err)
+	}
+
+	assertNetboxExport(t, got, `[
+		{"prefix": "10.0.17.0/28", "status": "reserved", "scope_type": "dcim.site", "scope_id": 12, "tenant": {"name": "Platform"}, "role": {"name": "Production"},
+		 "description": "db", "tags": [{"name": "managed-by-docidr"}, {"name": "sfo3"}]},
+		{"prefix": "10.0.16.0/24", "status": "reserved", "scope_type": "dcim.site", "scope_id": 12, "tenant": {"name": "Platform"}, "role": {"name": "Production"},
+		 "description": "k8s", "tags": [{"name": "managed-by-docidr"}]},
+		{"prefix": "10.0.0.0/20", "status": "reserved", "scope_type": "dcim.site", "scope_id": 12, "tenant": {"name": "Platform"}, "role": {"name": "Production"},
+		 "description": "vpc", "tags": [{"name": "managed-by-docidr"}, {"name": "nyc1"}, {"name": "production"}]}
+	]`)
+
+	// The output must not depend on map iteration or request order
+	reversed := []cidr.AllocationRequest{requests[2], requests[1], requests[0]}
+	for i := 0; i < 10; i++ {
+		again, err := buildNetboxExport(results, reversed, []string{"managed-by-docidr"}, defaults)
+		if err != nil {
+			t.Fatalf("buildNetboxExport() error = %v", err)
+		}
+		if again != got {
+			t.Fatalf("buildNetboxExport() is not deterministic:\n%s\nvs\n%s", again, got)
+		}
+	}
+}
+
+func TestBuildNetboxExport_Site(t *testing.T) {
+	got, err := buildNetboxExport(map[string]string{"vpc": "10.0.0.0/20"}, []cidr.AllocationRequest{{Name: "vpc", PrefixLength: 20}}, nil,
+		netboxDefaults{Site: "NYC1", Status: NetboxStatusActive})
+	if err != nil {
+		t.Fatalf("buildNetboxExport() error = %v", err)
+	}
+
+	// A site named in netbox_defaults is a dcim.site scope, left for the
+	// importer to resolve to an ID
+	assertNetboxExport(t, got, `[{"prefix": "10.0.0.0/20", "status": "active", "scope_type": "dcim.site", "scope": {"name": "NYC1"},
+		"description": "vpc", "tags": []}]`)
+}
+
+func TestBuildNetboxExport_Defaults(t *testing.T) {
+	got, err := buildNetboxExport(map[string]string{"vpc": "10.0.0.0/20"}, []cidr.AllocationRequest{{Name: "vpc", PrefixLength: 20}}, nil, expandNetboxDefaults(nil))
+	if err != nil {
+		t.Fatalf("buildNetboxExport() error = %v", err)
+	}
+
+	// Unconfigured fields are left out rather than sent empty, and tags are
+	// an empty list
+	assertNetboxExport(t, got, `[{"prefix": "10.0.0.0/20", "status": "active", "description": "vpc", "tags": []}]`)
+
+	empty, err := buildNetboxExport(map[string]string{}, nil, nil, expandNetboxDefaults(nil))
+	if err != nil {
+		t.Fatalf("buildNetboxExport() error = %v", err)
+	}
+	if empty != "[]" {
+		t.Errorf("buildNetboxExport() with no allocations = %s, want []", empty)
+	}
+
+	if _, err := buildNetboxExport(map[string]string{"vpc": "not-a-cidr"}, nil, nil, expandNetboxDefaults(nil)); err == nil {
+		t.Error("buildNetboxExport() with an invalid CIDR expected error, got none")
+	}
+}
+
+func TestExpandNetboxDefaults(t *testing.T) {
+	got := expandNetboxDefaults([]interface{}{
+		map[string]interface{}{"site": "", "scope_type": "dcim.region", "scope_id": 3, "tenant": "", "role": "Edge", "status": ""},
+	})
+	want := netboxDefaults{ScopeType: NetboxScopeRegion, ScopeID: 3, Role: "Edge", Status: NetboxStatusActive}
+	if got != want {
+		t.Errorf("expandNetboxDefaults() = %+v, want %+v", got, want)
+	}
+}
+
+func TestResourceDocidrPoolCreate_NetboxExport(t *testing.T) {
+	meta := newFakeCombinedConfig(t, newFakeAccountMux([]interface{}{}, []interface{}{}))
+
+	state := applyPool(t, nil, map[string]interface{}{
+		"base_cidr":            "10.0.0.0/16",
+		"auto_tag_allocations": []interface{}{"env:prod"},
+		"netbox_defaults": []interface{}{
+			map[string]interface{}{"scope_type": "dcim.site", "scope_id": 7, "role": "Production"},
+		},
+		"allocation": []interface{}{
+			map[string]interface{}{"name": "vpc", "prefix_length": 20, "region": "nyc1"},
+		},
+	}, meta)
+
+	assertNetboxExport(t, state.Attributes["netbox_export"], `[{"prefix": "10.0.0.0/20", "status": "active", "scope_type": "dcim.site", "scope_id": 7, "role": {"name": "Production"},
+		"description": "vpc", "tags": [{"name": "env:prod"}, {"name": "nyc1"}]}]`)
+}
+
+func TestNetboxDefaultsSchema(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     map[string]interface{}
+		wantErr bool
+	}{
+		{name: "scope", raw: map[string]interface{}{"scope_type": "dcim.site", "scope_id": 7}},
+		{name: "no scope", raw: map[string]interface{}{"role": "Production"}},
+		{name: "site", raw: map[string]interface{}{"site": "NYC1"}},
+		{name: "site and scope", raw: map[string]interface{}{"site": "NYC1", "scope_type": "dcim.site", "scope_id": 7}, wantErr: true},
+		{name: "scope_type without scope_id", raw: map[string]interface{}{"scope_type": "dcim.site"}, wantErr: true},
+		{name: "scope_id without scope_type", raw: map[string]interface{}{"scope_id": 7}, wantErr: true},
+		{name: "unknown scope_type", raw: map[string]interface{}{"scope_type": "dcim.rack", "scope_id": 7}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw := map[string]interface{}{
+				"netbox_defaults": []interface{}{tt.raw},
+				"allocation": []interface{}{
+					map[string]interface{}{"name": "vpc", "prefix_length": 16},
+				},
+			}
+
+			diags := ResourceDocidrPool().Validate(terraform.NewResourceConfigRaw(raw))
+			if diags.HasError() != tt.wantErr {
+				t.Errorf("Validate() diags = %v, wantErr %v", diags, tt.wantErr)
+			}
+		})
+	}
+}
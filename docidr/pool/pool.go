@@ -1,10 +1,21 @@
 package pool
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"math"
 	"net"
 	"regexp"
+	"sort"
+	"strings"
+	"sync"
 
 	"github.com/DO-Solutions/terraform-provider-docidr/docidr/cidr"
+	"github.com/DO-Solutions/terraform-provider-docidr/docidr/config"
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
 )
@@ -21,10 +32,11 @@ func poolSchema() map[string]*schema.Schema {
 			Elem: &schema.Resource{
 				Schema: map[string]*schema.Schema{
 					"name": {
-						Type:        schema.TypeString,
-						Required:    true,
-						ForceNew:    true,
-						Description: "Unique identifier for this allocation. Used as the key in the allocations output map.",
+						Type:             schema.TypeString,
+						Optional:         true,
+						ForceNew:         true,
+						Description:      "Unique identifier for this allocation. Used as the key in the allocations output map. Required unless auto_generate_names is set.",
+						DiffSuppressFunc: suppressAllocationReorder,
 						ValidateFunc: validation.All(
 							validation.StringLenBetween(1, 64),
 							validation.StringMatch(
@@ -34,22 +46,280 @@ func poolSchema() map[string]*schema.Schema {
 						),
 					},
 					"prefix_length": {
-						Type:         schema.TypeInt,
-						Required:     true,
-						ForceNew:     true,
-						Description:  "The prefix length for the CIDR block (e.g., 24 for /24). Valid range: 16-28.",
-						ValidateFunc: validation.IntBetween(16, 28),
+						Type:             schema.TypeInt,
+						Optional:         true,
+						ForceNew:         true,
+						Description:      "The prefix length for the CIDR block (e.g., 24 for /24). Valid range: 16-28. Exactly one of prefix_length, size and new_bits must be set.",
+						DiffSuppressFunc: suppressAllocationSizeChange,
+						ValidateFunc:     validation.IntBetween(16, 28),
+					},
+					"new_bits": {
+						Type:             schema.TypeInt,
+						Optional:         true,
+						ForceNew:         true,
+						Description:      "The prefix length for the CIDR block as the number of bits added to the prefix length of its base CIDR, as in cidrsubnet. Exactly one of prefix_length, size and new_bits must be set.",
+						DiffSuppressFunc: suppressAllocationSizeChange,
+						ValidateFunc:     validation.IntBetween(1, 28),
+					},
+					"size": {
+						Type:             schema.TypeString,
+						Optional:         true,
+						ForceNew:         true,
+						Description:      "The name of one of the provider's size_presets to use as the prefix length. Exactly one of prefix_length, size and new_bits must be set.",
+						DiffSuppressFunc: suppressAllocationSizeChange,
+						ValidateFunc:     validation.StringIsNotEmpty,
+					},
+					"region": {
+						Type:             schema.TypeString,
+						Optional:         true,
+						ForceNew:         true,
+						Description:      "The region slug the allocation is intended for, such as nyc1. Used to group allocations in region_cidrs.",
+						DiffSuppressFunc: suppressAllocationReorder,
+					},
+					"group": {
+						Type:             schema.TypeString,
+						Optional:         true,
+						Description:      "The name of the group the allocation is organized under, such as production. Allocations without a group are in the default group. Changing it updates the pool in place.",
+						DiffSuppressFunc: suppressAllocationReorder,
+					},
+					"hub": {
+						Type:             schema.TypeBool,
+						Optional:         true,
+						Default:          false,
+						Description:      "Whether the allocation is the hub of route_table. At most one allocation may set it; without one, the first allocation with vpc as a word of its name is the hub. Changing it updates the pool in place.",
+						DiffSuppressFunc: suppressAllocationReorder,
+					},
+					"base_cidr": {
+						Type:             schema.TypeString,
+						Optional:         true,
+						ForceNew:         true,
+						Description:      "The range to allocate this allocation from instead of the pool's base_cidr. It doesn't need to be within the pool's base_cidr.",
+						DiffSuppressFunc: suppressAllocationReorder,
+						ValidateFunc:     validation.IsCIDR,
+					},
+					"exclude_cidrs": {
+						Type:             schema.TypeList,
+						Optional:         true,
+						ForceNew:         true,
+						Description:      "CIDR blocks this allocation must avoid, in addition to the pool's exclusions. Other allocations may still use them.",
+						DiffSuppressFunc: suppressAllocationReorder,
+						Elem: &schema.Schema{
+							Type:         schema.TypeString,
+							ValidateFunc: validation.IsCIDR,
+						},
+					},
+					"visibility": {
+						Type:             schema.TypeString,
+						Optional:         true,
+						ForceNew:         true,
+						Description:      "Whether the allocation is internal or external. External allocations must be within an RFC 1918 range, and internal allocations must not use 192.168.0.0/16. Unset allocations are internal.",
+						DiffSuppressFunc: suppressAllocationReorder,
+						ValidateFunc:     validation.StringInSlice([]string{cidr.VisibilityInternal, cidr.VisibilityExternal}, false),
+					},
+					"allowed_prefix_range": {
+						Type:             schema.TypeList,
+						Optional:         true,
+						ForceNew:         true,
+						MaxItems:         1,
+						Description:      "The range of prefix lengths this allocation may use, overriding the pool's min_prefix_length and max_prefix_length.",
+						DiffSuppressFunc: suppressAllocationReorder,
+						Elem: &schema.Resource{
+							Schema: map[string]*schema.Schema{
+								"min": {
+									Type:             schema.TypeInt,
+									Required:         true,
+									ForceNew:         true,
+									Description:      "The smallest prefix length (largest block) allowed.",
+									DiffSuppressFunc: suppressAllocationReorder,
+									ValidateFunc:     validation.IntBetween(16, 28),
+								},
+								"max": {
+									Type:             schema.TypeInt,
+									Required:         true,
+									ForceNew:         true,
+									Description:      "The largest prefix length (smallest block) allowed.",
+									DiffSuppressFunc: suppressAllocationReorder,
+									ValidateFunc:     validation.IntBetween(16, 28),
+								},
+							},
+						},
 					},
 				},
 			},
 		},
-		"base_cidr": {
+		"auto_generate_names": {
+			Type:        schema.TypeBool,
+			Optional:    true,
+			ForceNew:    true,
+			Default:     false,
+			Description: "Whether allocations without a name are named `alloc_0`, `alloc_1`, and so on in declaration order.",
+		},
+		"stable_allocation": {
+			Type:        schema.TypeBool,
+			Optional:    true,
+			ForceNew:    true,
+			Default:     false,
+			Description: "Whether reordering allocation blocks is ignored, and allocations keep their CIDRs when the pool is replaced.",
+		},
+		"idempotent_id": {
+			Type:         schema.TypeString,
+			Optional:     true,
+			ForceNew:     true,
+			Description:  "A fixed ID for the pool, kept across replacements, for external systems that track it. Generated from the configuration when unset.",
+			ValidateFunc: validation.StringMatch(regexp.MustCompile(`^[a-zA-Z0-9]+$`), "must be a non-empty alphanumeric string"),
+		},
+		"plan_only": {
+			Type:        schema.TypeBool,
+			Optional:    true,
+			ForceNew:    true,
+			Default:     false,
+			Description: "Whether the pool is a proposal for review, which is replaced on every apply until plan_only is set to false.",
+		},
+		"migrate_to_base": {
 			Type:         schema.TypeString,
 			Optional:     true,
-			Default:      "10.0.0.0/8",
+			Description:  "A new base CIDR to move the pool to. The pool is replaced with every allocation made from it, at the same offset as in base_cidr where that is free, and the old allocations are kept in previous_allocations. Clearing it once base_cidr is set to the new base finishes the migration in place.",
+			ValidateFunc: validation.IsCIDR,
+		},
+		"allocation_names_regex": {
+			Type:        schema.TypeString,
+			Optional:    true,
+			ForceNew:    true,
+			Description: "Regular expression that every allocation name must match. Validated at plan time.",
+		},
+		"auto_tag_allocations": {
+			Type:        schema.TypeList,
+			Optional:    true,
+			ForceNew:    true,
+			Description: "DigitalOcean tags to recommend for the resources created from each allocation, returned in tag_recommendations.",
+			Elem: &schema.Schema{
+				Type:         schema.TypeString,
+				ValidateFunc: validation.StringMatch(doTagRegexp, "must be a DigitalOcean tag: up to 255 letters, numbers, colons, dashes and underscores"),
+			},
+		},
+		"min_prefix_length": {
+			Type:         schema.TypeInt,
+			Optional:     true,
+			ForceNew:     true,
+			ValidateFunc: validation.IntBetween(16, 28),
+			Description:  "Smallest prefix length (largest block) any allocation in this pool may request. Narrows the global 16-28 range.",
+		},
+		"max_prefix_length": {
+			Type:         schema.TypeInt,
+			Optional:     true,
 			ForceNew:     true,
-			Description:  "The parent CIDR range from which allocations are made. All allocated blocks will be subnets of this range.",
+			ValidateFunc: validation.IntBetween(16, 28),
+			Description:  "Largest prefix length (smallest block) any allocation in this pool may request. Narrows the global 16-28 range.",
+		},
+		"base_cidr": {
+			Type:         schema.TypeString,
+			Optional:     true,
+			Computed:     true,
+			Description:  "The parent CIDR range from which allocations are made. All allocated blocks will be subnets of this range. Defaults to " + defaultBaseCIDR + ". Growing it to a range containing the old one updates the pool in place; any other change replaces it.",
 			ValidateFunc: validation.IsCIDR,
+			DiffSuppressFunc: func(k, old, new string, d *schema.ResourceData) bool {
+				// A migrating pool allocates from migrate_to_base instead.
+				// docidr_pool_plan shares this schema without it.
+				if target, _ := d.Get("migrate_to_base").(string); target != "" {
+					if old == target {
+						return true
+					}
+					new = target
+				}
+				return d.Get("base_cidr_expansion").(bool) && isExpandedBaseCIDR(old, new)
+			},
+		},
+		"address_space": {
+			Type:     schema.TypeString,
+			Optional: true,
+			ForceNew: true,
+			ValidateFunc: validation.StringInSlice([]string{
+				AddressSpaceRFC1918_10,
+				AddressSpaceRFC1918_172,
+				AddressSpaceRFC1918_192,
+			}, false),
+			ConflictsWith: []string{"base_cidr", "detect_base_cidr_from_region"},
+			Description:   "An RFC 1918 range to use as base_cidr: rfc1918_10 (10.0.0.0/8), rfc1918_172 (172.16.0.0/12) or rfc1918_192 (192.168.0.0/16).",
+			DiffSuppressFunc: func(k, old, new string, d *schema.ResourceData) bool {
+				return equivalentAddressSpace(old, new, d.Get("base_cidr").(string))
+			},
+		},
+		"use_ipv6_ula_base": {
+			Type:        schema.TypeBool,
+			Optional:    true,
+			ForceNew:    true,
+			Default:     false,
+			Description: "Whether to generate an RFC 4193 unique local IPv6 /48, derived from the pool's ID, in ipv6_base_cidr.",
+		},
+		"ipv6_base_cidr": {
+			Type:        schema.TypeString,
+			Computed:    true,
+			Description: "The unique local IPv6 /48 generated when use_ipv6_ula_base is set. Empty otherwise.",
+		},
+		"base_cidr_expansion": {
+			Type:        schema.TypeBool,
+			Optional:    true,
+			ForceNew:    true,
+			Default:     false,
+			Description: "Whether to widen base_cidr by one prefix bit at a time, up to 3 times, when the allocations don't fit.",
+		},
+		"region": {
+			Type:        schema.TypeString,
+			Optional:    true,
+			ForceNew:    true,
+			Description: "The DigitalOcean region slug the allocations are intended for.",
+		},
+		"detect_base_cidr_from_region": {
+			Type:          schema.TypeBool,
+			Optional:      true,
+			ForceNew:      true,
+			Default:       false,
+			RequiredWith:  []string{"region"},
+			ConflictsWith: []string{"base_cidr"},
+			Description:   "Whether to set base_cidr to the RFC 1918 range used by existing VPCs in region.",
+		},
+		"sort_strategy": {
+			Type:     schema.TypeString,
+			Optional: true,
+			ForceNew: true,
+			Default:  SortStrategyDeclaration,
+			ValidateFunc: validation.StringInSlice([]string{
+				SortStrategyDeclaration,
+				SortStrategyLargestFirst,
+				SortStrategySmallestFirst,
+			}, false),
+			Description: "Order in which allocations are processed: `declaration` (as written), `largest_first` (reduces fragmentation), or `smallest_first`.",
+		},
+		"placement": {
+			Type:     schema.TypeString,
+			Optional: true,
+			ForceNew: true,
+			Default:  cidr.PlacementFirstFit,
+			ValidateFunc: validation.StringInSlice([]string{
+				cidr.PlacementFirstFit,
+				cidr.PlacementRandom,
+			}, false),
+			Description: "Where each allocation is looked for first: `first_fit` (the lowest free block) or `random` (a block picked from the pool's ID, wrapping around).",
+		},
+		"placement_salt": {
+			Type:        schema.TypeString,
+			Optional:    true,
+			ForceNew:    true,
+			Description: "A value mixed into the pool's ID to pick the starting blocks of `random` placement, so identical configurations in different accounts or workspaces start apart.",
+		},
+		"oversize_warning_threshold": {
+			Type:         schema.TypeFloat,
+			Optional:     true,
+			Default:      0.5,
+			ValidateFunc: validation.FloatBetween(0, 1),
+			Description:  "Fraction of the base CIDR at or above which a single allocation produces a warning. Set to 0 to disable.",
+		},
+		"allocation_count_limit": {
+			Type:         schema.TypeInt,
+			Optional:     true,
+			Default:      defaultAllocationCountLimit,
+			ValidateFunc: validation.IntBetween(1, maxAllocationCountLimit),
+			Description:  "Maximum number of allocation blocks the pool may have, to catch a configuration generating far more than intended.",
 		},
 		"exclude": {
 			Type:        schema.TypeList,
@@ -69,11 +339,326 @@ func poolSchema() map[string]*schema.Schema {
 						Type:        schema.TypeString,
 						Optional:    true,
 						ForceNew:    true,
-						Description: "Optional documentation explaining why this range is excluded.",
+						Description: "Why this range is excluded, shown in allocation errors and the scan report.",
+					},
+				},
+			},
+		},
+		"exclude_patterns": {
+			Type:        schema.TypeList,
+			Optional:    true,
+			ForceNew:    true,
+			Description: "Wildcard patterns of ranges to exclude from allocation, such as `10.1.*.*` for 10.1.0.0/16.",
+			Elem: &schema.Schema{
+				Type:         schema.TypeString,
+				ValidateFunc: validateExcludePattern,
+			},
+		},
+		"exclude_self_managed_ranges": {
+			Type:        schema.TypeList,
+			Optional:    true,
+			ForceNew:    true,
+			Description: "CIDRs managed in this configuration, such as another pool's allocations, to exclude even though they may not exist in the account yet.",
+			Elem: &schema.Schema{
+				Type:         schema.TypeString,
+				ValidateFunc: validation.IsCIDR,
+			},
+		},
+		"exclusions_file": {
+			Type:        schema.TypeString,
+			Optional:    true,
+			ForceNew:    true,
+			Description: "Path to a local file listing CIDR ranges to exclude from allocation. Supports plain text (one CIDR per line, '#' comments), a JSON array of strings, or CSV with a 'cidr' column.",
+		},
+		"exclusions_file_hash": {
+			Type:        schema.TypeString,
+			Computed:    true,
+			Description: "SHA-256 hash of the exclusions file contents. Changes to the file force replacement.",
+		},
+		"ignore_existing_vpc_cidrs": {
+			Type:        schema.TypeBool,
+			Optional:    true,
+			ForceNew:    true,
+			Default:     false,
+			Description: "Whether to skip listing the VPCs in the account, so their IP ranges aren't excluded.",
+		},
+		"ignore_existing_k8s_cidrs": {
+			Type:        schema.TypeBool,
+			Optional:    true,
+			ForceNew:    true,
+			Default:     false,
+			Description: "Whether to skip listing the Kubernetes clusters in the account, so their cluster and service subnets aren't excluded.",
+		},
+		"scan_reserved_ips": {
+			Type:        schema.TypeBool,
+			Optional:    true,
+			ForceNew:    true,
+			Default:     false,
+			Description: "Whether to exclude reserved IP addresses in the account as /32 blocks.",
+		},
+		"scan_load_balancers": {
+			Type:        schema.TypeBool,
+			Optional:    true,
+			ForceNew:    true,
+			Default:     false,
+			Description: "Whether to exclude load balancer IP addresses in the account as /32 blocks.",
+		},
+		"scan_byoip": {
+			Type:        schema.TypeBool,
+			Optional:    true,
+			ForceNew:    true,
+			Default:     false,
+			Description: "Whether to exclude bring-your-own-IP prefixes in the account.",
+		},
+		"scan_interconnects": {
+			Type:        schema.TypeBool,
+			Optional:    true,
+			ForceNew:    true,
+			Default:     false,
+			Description: "Whether to exclude remote routes advertised over partner interconnect attachments.",
+		},
+		"interconnect_routes": {
+			Type:        schema.TypeList,
+			Optional:    true,
+			ForceNew:    true,
+			Description: "Remote route prefixes to exclude for interconnects whose routes aren't exposed by the API.",
+			Elem: &schema.Schema{
+				Type:         schema.TypeString,
+				ValidateFunc: validation.IsCIDR,
+			},
+		},
+		"check_tags": {
+			Type:        schema.TypeList,
+			Optional:    true,
+			ForceNew:    true,
+			Description: "Tags whose Kubernetes clusters and Droplets, in any region, also have their CIDRs and VPCs excluded. A resource must have every tag.",
+			Elem: &schema.Schema{
+				Type:         schema.TypeString,
+				ValidateFunc: validation.StringIsNotEmpty,
+			},
+		},
+		"skip_api_query": {
+			Type:        schema.TypeBool,
+			Optional:    true,
+			ForceNew:    true,
+			Default:     false,
+			Description: "Whether to skip scanning the DigitalOcean account, for air-gapped deployments. Only the configured exclusions are avoided.",
+		},
+		"scan_name_regex": {
+			Type:         schema.TypeString,
+			Optional:     true,
+			ForceNew:     true,
+			ValidateFunc: validation.StringIsValidRegExp,
+			Description:  "Regular expression a VPC or Kubernetes cluster name must match for its CIDRs to be excluded from allocation. Other resources are ignored.",
+		},
+		"scan_name_exclude_regex": {
+			Type:         schema.TypeString,
+			Optional:     true,
+			ForceNew:     true,
+			ValidateFunc: validation.StringIsValidRegExp,
+			Description:  "Regular expression matching the names of VPCs and Kubernetes clusters whose CIDRs are ignored, even if they match scan_name_regex.",
+		},
+		"allow_partial_scan": {
+			Type:        schema.TypeBool,
+			Optional:    true,
+			ForceNew:    true,
+			Default:     false,
+			Description: "Whether to skip, with a warning, sources the token isn't permitted to list instead of failing.",
+		},
+		"warn_on_existing_cidr_errors": {
+			Type:        schema.TypeBool,
+			Optional:    true,
+			Default:     true,
+			Description: "Whether CIDRs returned by the DigitalOcean API that can't be parsed are reported as warnings, rather than only logged.",
+		},
+		"include_app_platform_ranges": {
+			Type:        schema.TypeBool,
+			Optional:    true,
+			ForceNew:    true,
+			Default:     false,
+			Description: "Whether to exclude App Platform internal network ranges, fetched from the provider's app_platform_ranges_url.",
+		},
+		"exclude_overlapping_pools": {
+			Type:        schema.TypeBool,
+			Optional:    true,
+			Default:     false,
+			Description: "Whether to exclude the allocations of other docidr_pool resources in this configuration.",
+		},
+		"audit_log_file": {
+			Type:        schema.TypeString,
+			Optional:    true,
+			ForceNew:    true,
+			Description: "Path to a file that a JSON line is appended to whenever the pool is created or destroyed.",
+		},
+		"telemetry": {
+			Type:        schema.TypeList,
+			Optional:    true,
+			MaxItems:    1,
+			Description: "Opt-in reporting of anonymous usage after each allocation. No allocation names or CIDRs are sent.",
+			Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					"enabled": {
+						Type:        schema.TypeBool,
+						Optional:    true,
+						Default:     false,
+						Description: "Whether to send usage reports.",
+					},
+					"endpoint": {
+						Type:         schema.TypeString,
+						Required:     true,
+						Description:  "The HTTP or HTTPS URL usage reports are posted to.",
+						ValidateFunc: validation.IsURLWithHTTPorHTTPS,
+					},
+				},
+			},
+		},
+		"netbox_defaults": {
+			Type:        schema.TypeList,
+			Optional:    true,
+			ForceNew:    true,
+			MaxItems:    1,
+			Description: "Fields copied into every prefix of netbox_export.",
+			Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					"site": {
+						Type:          schema.TypeString,
+						Optional:      true,
+						ForceNew:      true,
+						ConflictsWith: []string{"netbox_defaults.0.scope_type", "netbox_defaults.0.scope_id"},
+						Description:   "The name of the NetBox site of the prefixes, exported as a dcim.site scope.",
+					},
+					"scope_type": {
+						Type:     schema.TypeString,
+						Optional: true,
+						ForceNew: true,
+						ValidateFunc: validation.StringInSlice([]string{
+							NetboxScopeRegion,
+							NetboxScopeSiteGroup,
+							NetboxScopeSite,
+							NetboxScopeLocation,
+						}, false),
+						RequiredWith: []string{"netbox_defaults.0.scope_id"},
+						Description:  "The type of NetBox object the prefixes are scoped to: dcim.region, dcim.sitegroup, dcim.site or dcim.location.",
+					},
+					"scope_id": {
+						Type:         schema.TypeInt,
+						Optional:     true,
+						ForceNew:     true,
+						ValidateFunc: validation.IntAtLeast(1),
+						RequiredWith: []string{"netbox_defaults.0.scope_type"},
+						Description:  "The ID of the NetBox object of scope_type the prefixes are scoped to.",
+					},
+					"tenant": {
+						Type:        schema.TypeString,
+						Optional:    true,
+						ForceNew:    true,
+						Description: "The name of the NetBox tenant of the prefixes.",
+					},
+					"role": {
+						Type:        schema.TypeString,
+						Optional:    true,
+						ForceNew:    true,
+						Description: "The name of the NetBox role of the prefixes.",
+					},
+					"status": {
+						Type:     schema.TypeString,
+						Optional: true,
+						ForceNew: true,
+						Default:  NetboxStatusActive,
+						ValidateFunc: validation.StringInSlice([]string{
+							NetboxStatusActive,
+							NetboxStatusReserved,
+							NetboxStatusContainer,
+							NetboxStatusDeprecated,
+						}, false),
+						Description: "The NetBox status of the prefixes: active, reserved, container or deprecated.",
+					},
+				},
+			},
+		},
+		"external_allocation_api": {
+			Type:          schema.TypeList,
+			Optional:      true,
+			ForceNew:      true,
+			MaxItems:      1,
+			ConflictsWith: []string{"stable_allocation", "migrate_to_base"},
+			Description:   "An external IPAM system to allocate the pool's blocks from instead of the built-in allocator. The DigitalOcean account isn't scanned and exclusions don't apply.",
+			Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					"url": {
+						Type:         schema.TypeString,
+						Required:     true,
+						ForceNew:     true,
+						Description:  "The root URL of the external IPAM system's API.",
+						ValidateFunc: validation.IsURLWithHTTPorHTTPS,
+					},
+					"auth_token": {
+						Type:        schema.TypeString,
+						Required:    true,
+						Sensitive:   true,
+						Description: "The token to authenticate with. Changing it updates the pool in place.",
+					},
+					"api_type": {
+						Type:     schema.TypeString,
+						Required: true,
+						ForceNew: true,
+						ValidateFunc: validation.StringInSlice([]string{
+							ExternalIPAMNetbox,
+							ExternalIPAMPHPIPAM,
+							ExternalIPAMGenericJSON,
+						}, false),
+						Description: "The API the external IPAM system speaks: netbox, phpipam or generic_json.",
 					},
 				},
 			},
 		},
+		"validate_against_asn_route_table": {
+			Type:         schema.TypeString,
+			Optional:     true,
+			ValidateFunc: validation.IsURLWithHTTPorHTTPS,
+			Description:  "URL of a JSON array of announced prefixes, such as a route collector's view of an upstream AS. Allocations overlapping an announced prefix are reported as warnings.",
+		},
+		"scan_retries": {
+			Type:         schema.TypeInt,
+			Optional:     true,
+			ForceNew:     true,
+			Default:      0,
+			ValidateFunc: validation.IntBetween(0, 10),
+			Description:  "Number of times to rescan the account until two consecutive scans agree.",
+		},
+		"scan_retry_delay": {
+			Type:         schema.TypeFloat,
+			Optional:     true,
+			ForceNew:     true,
+			Default:      2,
+			ValidateFunc: validation.FloatAtLeast(0),
+			Description:  "Seconds to wait between scans when scan_retries is set.",
+		},
+		"allocation_retry_budget": {
+			Type:         schema.TypeInt,
+			Optional:     true,
+			ForceNew:     true,
+			Default:      0,
+			ValidateFunc: validation.IntBetween(0, maxAllocationRetryBudget),
+			Description:  "Number of times to repeat the account scan and allocation, with exponential backoff, when either fails.",
+		},
+		"scan_vpc_peerings": {
+			Type:        schema.TypeBool,
+			Optional:    true,
+			ForceNew:    true,
+			Default:     false,
+			Description: "Whether to exclude the IP ranges of VPCs peered with VPCs in the account.",
+		},
+		"peering_ranges": {
+			Type:             schema.TypeMap,
+			Optional:         true,
+			ForceNew:         true,
+			Description:      "Map of peer VPC IDs to their IP ranges, for peer VPCs in other accounts.",
+			ValidateDiagFunc: validatePeeringRanges,
+			Elem: &schema.Schema{
+				Type: schema.TypeString,
+			},
+		},
 		"allocations": {
 			Type:        schema.TypeMap,
 			Computed:    true,
@@ -82,66 +667,1161 @@ func poolSchema() map[string]*schema.Schema {
 				Type: schema.TypeString,
 			},
 		},
-	}
-}
-
-// AllocationConfig represents an allocation request parsed from the schema.
-type AllocationConfig struct {
-	Name         string
-	PrefixLength int
-}
-
-// ExclusionConfig represents an exclusion parsed from the schema.
-type ExclusionConfig struct {
-	CIDR   string
-	Reason string
-}
-
-// expandAllocations converts the allocation list from the schema to AllocationConfig slice.
-func expandAllocations(allocations []interface{}) []cidr.AllocationRequest {
-	result := make([]cidr.AllocationRequest, 0, len(allocations))
-	for _, alloc := range allocations {
-		m := alloc.(map[string]interface{})
-		result = append(result, cidr.AllocationRequest{
-			Name:         m["name"].(string),
-			PrefixLength: m["prefix_length"].(int),
-		})
-	}
-	return result
-}
-
-// expandExclusions converts the exclude list from the schema to a slice of net.IPNet.
-func expandExclusions(exclusions []interface{}) ([]*net.IPNet, error) {
-	result := make([]*net.IPNet, 0, len(exclusions))
-	for _, excl := range exclusions {
-		m := excl.(map[string]interface{})
-		cidrStr := m["cidr"].(string)
-		network, err := cidr.ParseCIDR(cidrStr)
-		if err != nil {
-			return nil, err
-		}
-		result = append(result, network)
-	}
-	return result, nil
-}
-
-// flattenAllocations converts the allocation results map to a schema-compatible format.
-func flattenAllocations(allocations map[string]string) map[string]interface{} {
-	result := make(map[string]interface{})
-	for name, cidrBlock := range allocations {
-		result[name] = cidrBlock
-	}
-	return result
-}
-
-// validateUniqueAllocationNames checks that all allocation names are unique.
-func validateUniqueAllocationNames(allocations []interface{}) error {
-	seen := make(map[string]bool)
+		"groups": {
+			Type:        schema.TypeMap,
+			Computed:    true,
+			Description: "Map of allocation groups to the comma-separated CIDR blocks of their allocations, in configuration order.",
+			Elem: &schema.Schema{
+				Type: schema.TypeString,
+			},
+		},
+		"group_summaries": {
+			Type:        schema.TypeMap,
+			Computed:    true,
+			Description: "Map of allocation groups to the smallest CIDR block containing all of their allocations.",
+			Elem: &schema.Schema{
+				Type: schema.TypeString,
+			},
+		},
+		"allocations_first_usable": {
+			Type:        schema.TypeMap,
+			Computed:    true,
+			Description: "Map of allocation names to the first address in their CIDR block that can be assigned to a host.",
+			Elem: &schema.Schema{
+				Type: schema.TypeString,
+			},
+		},
+		"allocations_last_usable": {
+			Type:        schema.TypeMap,
+			Computed:    true,
+			Description: "Map of allocation names to the last address in their CIDR block that can be assigned to a host.",
+			Elem: &schema.Schema{
+				Type: schema.TypeString,
+			},
+		},
+		"allocation_hash_ids": {
+			Type:        schema.TypeMap,
+			Computed:    true,
+			Description: "Map of allocation names to short IDs derived from the pool ID and the name, for for_each keys that don't change when an allocation's CIDR does.",
+			Elem: &schema.Schema{
+				Type: schema.TypeString,
+			},
+		},
+		"allocations_reverse_zones": {
+			Type:        schema.TypeMap,
+			Computed:    true,
+			Description: "Map of allocation names to the comma-separated reverse DNS zones covering their CIDR blocks.",
+			Elem: &schema.Schema{
+				Type: schema.TypeString,
+			},
+		},
+		"allocations_cidrsubnet": {
+			Type:        schema.TypeList,
+			Computed:    true,
+			Description: "The cidrsubnet newbits and netnum of each allocation relative to base_cidr, in configuration order.",
+			Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					"name": {
+						Type:        schema.TypeString,
+						Computed:    true,
+						Description: "The allocation name.",
+					},
+					"newbits": {
+						Type:        schema.TypeInt,
+						Computed:    true,
+						Description: "The number of bits to add to the base_cidr prefix length.",
+					},
+					"netnum": {
+						Type:        schema.TypeInt,
+						Computed:    true,
+						Description: "The index of the allocation among the blocks of its size in base_cidr.",
+					},
+				},
+			},
+		},
+		"allocation_prefix_lengths": {
+			Type:        schema.TypeMap,
+			Computed:    true,
+			Description: "Map of allocation names to their prefix lengths, with size presets resolved.",
+			Elem: &schema.Schema{
+				Type: schema.TypeInt,
+			},
+		},
+		"region_cidrs": {
+			Type:        schema.TypeMap,
+			Computed:    true,
+			Description: "Map of region slugs to the CIDR of the first allocation, in configuration order, in that region. Later allocations in the same region are left out; use allocations for them. Allocations without a region are keyed global.",
+			Elem: &schema.Schema{
+				Type: schema.TypeString,
+			},
+		},
+		"internal_allocations": {
+			Type:        schema.TypeMap,
+			Computed:    true,
+			Description: "Map of the internal allocations' names to their CIDR blocks.",
+			Elem: &schema.Schema{
+				Type: schema.TypeString,
+			},
+		},
+		"external_allocations": {
+			Type:        schema.TypeMap,
+			Computed:    true,
+			Description: "Map of the external allocations' names to their CIDR blocks.",
+			Elem: &schema.Schema{
+				Type: schema.TypeString,
+			},
+		},
+		"tag_recommendations": {
+			Type:        schema.TypeMap,
+			Computed:    true,
+			Description: "Map of allocation names to the auto_tag_allocations tags, comma-separated. Empty unless auto_tag_allocations is set.",
+			Elem: &schema.Schema{
+				Type: schema.TypeString,
+			},
+		},
+		"state_valid": {
+			Type:        schema.TypeBool,
+			Computed:    true,
+			Description: "Whether the allocations in state are valid CIDRs that don't overlap. Checked on every refresh; an invalid state, such as after a manual state edit, forces replacement.",
+		},
+		"digest": {
+			Type:        schema.TypeString,
+			Computed:    true,
+			Description: "SHA-256 of the allocations, which changes only when an allocation's name or CIDR changes. For use in triggers of dependent resources.",
+		},
+		"route_table": {
+			Type:        schema.TypeList,
+			Computed:    true,
+			Description: "Static routes between the allocations for a hub-and-spoke topology, with the allocation marked hub as the hub, or without one the first vpc allocation.",
+			Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					"allocation_name": {
+						Type:        schema.TypeString,
+						Computed:    true,
+						Description: "The allocation whose network the route is for.",
+					},
+					"destination_cidr": {
+						Type:        schema.TypeString,
+						Computed:    true,
+						Description: "The CIDR of the allocation the route reaches.",
+					},
+					"via_cidr": {
+						Type:        schema.TypeString,
+						Computed:    true,
+						Description: "The first usable host of the hub allocation, as a /32.",
+					},
+				},
+			},
+		},
+		"scan_report": {
+			Type:        schema.TypeList,
+			Computed:    true,
+			Description: "Details of the exclusions considered when the allocations were made.",
+			Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					"existing_cidrs": {
+						Type:        schema.TypeList,
+						Computed:    true,
+						Description: "CIDRs found in use in the DigitalOcean account.",
+						Elem:        &schema.Schema{Type: schema.TypeString},
+					},
+					"env_exclusions": {
+						Type:        schema.TypeList,
+						Computed:    true,
+						Description: "CIDRs excluded via the DOCIDR_EXCLUDE environment variable.",
+						Elem:        &schema.Schema{Type: schema.TypeString},
+					},
+					"exclusions": {
+						Type:        schema.TypeList,
+						Computed:    true,
+						Description: "Every range allocations had to avoid, with why.",
+						Elem: &schema.Resource{
+							Schema: map[string]*schema.Schema{
+								"cidr": {
+									Type:        schema.TypeString,
+									Computed:    true,
+									Description: "The excluded range.",
+								},
+								"reason": {
+									Type:        schema.TypeString,
+									Computed:    true,
+									Description: "Why the range was excluded: the reason given on an `exclude` block, or the resource using a range found in the account.",
+								},
+								"source": {
+									Type:        schema.TypeString,
+									Computed:    true,
+									Description: "Where the exclusion came from, such as `exclude`, `DOCIDR_EXCLUDE` or `vpc`.",
+								},
+							},
+						},
+					},
+					"skipped_sources": {
+						Type:        schema.TypeList,
+						Computed:    true,
+						Description: "Sources skipped by allow_partial_scan because the token isn't permitted to list them.",
+						Elem:        &schema.Schema{Type: schema.TypeString},
+					},
+					"name_filtered": {
+						Type:        schema.TypeInt,
+						Computed:    true,
+						Description: "Number of VPCs and Kubernetes clusters ignored because of scan_name_regex or scan_name_exclude_regex.",
+					},
+					"api_metrics": {
+						Type:        schema.TypeList,
+						Computed:    true,
+						Description: "DigitalOcean API requests made while creating the pool.",
+						Elem: &schema.Resource{
+							Schema: map[string]*schema.Schema{
+								"calls": {
+									Type:        schema.TypeInt,
+									Computed:    true,
+									Description: "Number of API requests, not counting retries.",
+								},
+								"retries": {
+									Type:        schema.TypeInt,
+									Computed:    true,
+									Description: "Number of times a request was retried.",
+								},
+								"pages": {
+									Type:        schema.TypeMap,
+									Computed:    true,
+									Description: "Number of pages fetched from each API path.",
+									Elem:        &schema.Schema{Type: schema.TypeInt},
+								},
+								"http_time_seconds": {
+									Type:        schema.TypeFloat,
+									Computed:    true,
+									Description: "Total time spent in API requests, including waits between retries.",
+								},
+								"rate_limit_remaining": {
+									Type:        schema.TypeInt,
+									Computed:    true,
+									Description: "Requests left in the API rate limit after the last request, or -1 if unknown.",
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		"summary": {
+			Type:        schema.TypeString,
+			Computed:    true,
+			Description: "Human-readable tree of the allocations within the base CIDR.",
+		},
+		"allocations_json": {
+			Type:        schema.TypeString,
+			Computed:    true,
+			Description: "JSON document describing the pool and its allocations, for tools outside Terraform.",
+		},
+		"netbox_export": {
+			Type:        schema.TypeString,
+			Computed:    true,
+			Description: "JSON array of the allocations as prefixes to create with NetBox 4.2's REST API.",
+		},
+		"external_allocation_ids": {
+			Type:        schema.TypeMap,
+			Computed:    true,
+			Description: "A map from allocation names to their IDs in the external IPAM system of external_allocation_api.",
+			Elem:        &schema.Schema{Type: schema.TypeString},
+		},
+		"export_terraform_locals": {
+			Type:        schema.TypeString,
+			Computed:    true,
+			Description: "HCL locals block defining an <allocation>_cidr local for each allocation, for copying into other configurations.",
+		},
+		"output_csv": {
+			Type:        schema.TypeString,
+			Computed:    true,
+			Description: "CSV with a header row and one row per allocation, for spreadsheets and CMDBs.",
+		},
+		"previous_allocations": {
+			Type:        schema.TypeMap,
+			Computed:    true,
+			Description: "Allocations of the pool being replaced, kept when stable_allocation is set, or the allocations from before the migration while migrate_to_base is set.",
+			Elem:        &schema.Schema{Type: schema.TypeString},
+		},
+		"migration_map": {
+			Type:        schema.TypeList,
+			Computed:    true,
+			Description: "The old and new CIDR of each allocation moved by migrate_to_base, in configuration order.",
+			Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					"name": {
+						Type:        schema.TypeString,
+						Computed:    true,
+						Description: "The allocation name.",
+					},
+					"old_cidr": {
+						Type:        schema.TypeString,
+						Computed:    true,
+						Description: "The allocation's CIDR before the migration.",
+					},
+					"new_cidr": {
+						Type:        schema.TypeString,
+						Computed:    true,
+						Description: "The allocation's CIDR within migrate_to_base.",
+					},
+				},
+			},
+		},
+		"overlapping_pool_cidrs": {
+			Type:        schema.TypeList,
+			Computed:    true,
+			Description: "Allocations of other docidr_pool resources excluded because this pool or they set exclude_overlapping_pools.",
+			Elem:        &schema.Schema{Type: schema.TypeString},
+		},
+		"utilization_percent": {
+			Type:        schema.TypeFloat,
+			Computed:    true,
+			Description: "Percentage of the base CIDR consumed by the allocations.",
+		},
+		"utilization_breakdown": {
+			Type:        schema.TypeMap,
+			Computed:    true,
+			Description: "Map of allocation names to the percentage of the base CIDR each consumes.",
+			Elem: &schema.Schema{
+				Type: schema.TypeFloat,
+			},
+		},
+	}
+}
+
+// defaultBaseCIDR is the base CIDR used when none is configured.
+const defaultBaseCIDR = "10.0.0.0/8"
+
+// The default and largest allocation_count_limit.
+const (
+	defaultAllocationCountLimit = 50
+	maxAllocationCountLimit     = 500
+)
+
+// Address space presets for address_space.
+const (
+	AddressSpaceRFC1918_10  = "rfc1918_10"
+	AddressSpaceRFC1918_172 = "rfc1918_172"
+	AddressSpaceRFC1918_192 = "rfc1918_192"
+)
+
+// addressSpaces maps each address_space preset to the base CIDR it stands for.
+var addressSpaces = map[string]string{
+	AddressSpaceRFC1918_10:  "10.0.0.0/8",
+	AddressSpaceRFC1918_172: "172.16.0.0/12",
+	AddressSpaceRFC1918_192: "192.168.0.0/16",
+}
+
+// ipv6BaseCIDR returns the ipv6_base_cidr attribute of the pool with the given
+// ID. The ID is derived from the pool's configuration, so the prefix stays the
+// same across applies and only changes when the pool is replaced with a
+// different configuration.
+func ipv6BaseCIDR(useULABase bool, id string) (string, error) {
+	if !useULABase {
+		return "", nil
+	}
+	return cidr.GenerateULABase([]byte(id))
+}
+
+// equivalentAddressSpace reports whether a change to address_space from old
+// to new only switches between a preset and the base_cidr it stands for, so
+// that the pool isn't replaced for it.
+func equivalentAddressSpace(old, new, baseCIDR string) bool {
+	switch {
+	case old == "" && new != "":
+		return addressSpaces[new] == baseCIDR
+	case old != "" && new == "":
+		return addressSpaces[old] == baseCIDR
+	}
+	return false
+}
+
+// Allocation sort strategies.
+const (
+	SortStrategyDeclaration   = "declaration"
+	SortStrategyLargestFirst  = "largest_first"
+	SortStrategySmallestFirst = "smallest_first"
+)
+
+// AllocationConfig represents an allocation request parsed from the schema.
+type AllocationConfig struct {
+	Name         string
+	PrefixLength int
+}
+
+// expandAllocations converts the allocation list from the schema to AllocationConfig slice.
+func expandAllocations(allocations []interface{}, autoGenerateNames bool) []cidr.AllocationRequest {
+	result := make([]cidr.AllocationRequest, 0, len(allocations))
 	for _, alloc := range allocations {
+		m := alloc.(map[string]interface{})
+		region, _ := m["region"].(string)
+		visibility, _ := m["visibility"].(string)
+		group, _ := m["group"].(string)
+		hub, _ := m["hub"].(bool)
+		result = append(result, cidr.AllocationRequest{
+			Name:         m["name"].(string),
+			PrefixLength: m["prefix_length"].(int),
+			Region:       region,
+			Group:        group,
+			Hub:          hub,
+			Visibility:   visibility,
+			Exclusions:   expandAllocationExclusions(m["exclude_cidrs"]),
+			BaseCIDR:     expandAllocationBaseCIDR(m["base_cidr"]),
+		})
+	}
+
+	if autoGenerateNames {
+		generateAllocationNames(result)
+	}
+	return result
+}
+
+// expandAllocationExclusions parses an allocation's exclude_cidrs. Entries
+// that don't parse are skipped: invalid CIDRs are rejected by validation, and
+// values not known until apply read as empty during plan.
+func expandAllocationExclusions(raw interface{}) []*net.IPNet {
+	list, _ := raw.([]interface{})
+	var result []*net.IPNet
+	for _, v := range list {
+		s, _ := v.(string)
+		network, err := cidr.ParseCIDR(s)
+		if err != nil {
+			continue
+		}
+		result = append(result, network)
+	}
+	return result
+}
+
+// expandAllocationBaseCIDR parses an allocation's base_cidr, returning nil if
+// it isn't set or isn't known yet.
+func expandAllocationBaseCIDR(raw interface{}) *net.IPNet {
+	s, _ := raw.(string)
+	if s == "" {
+		return nil
+	}
+	network, err := cidr.ParseCIDR(s)
+	if err != nil {
+		return nil
+	}
+	return network
+}
+
+// requestBaseCIDR returns the base CIDR req is allocated from: its own
+// base_cidr, or the pool's baseCIDR.
+func requestBaseCIDR(baseCIDR string, req cidr.AllocationRequest) string {
+	if req.BaseCIDR != nil {
+		return req.BaseCIDR.String()
+	}
+	return baseCIDR
+}
+
+// poolBaseAllocations returns the results of the requests allocated from the
+// pool's own base CIDR, leaving out those with their own base_cidr.
+func poolBaseAllocations(results map[string]string, requests []cidr.AllocationRequest) map[string]string {
+	overridden := make(map[string]bool)
+	for _, req := range requests {
+		if req.BaseCIDR != nil {
+			overridden[req.Name] = true
+		}
+	}
+	filtered := make(map[string]string, len(results))
+	for name, block := range results {
+		if !overridden[name] {
+			filtered[name] = block
+		}
+	}
+	return filtered
+}
+
+// generateAllocationNames names unnamed allocations alloc_0, alloc_1, and so
+// on in declaration order, skipping names already given to other allocations.
+func generateAllocationNames(requests []cidr.AllocationRequest) {
+	taken := make(map[string]bool, len(requests))
+	for _, req := range requests {
+		taken[req.Name] = true
+	}
+
+	next := 0
+	for i := range requests {
+		if requests[i].Name != "" {
+			continue
+		}
+		for taken[fmt.Sprintf("alloc_%d", next)] {
+			next++
+		}
+		requests[i].Name = fmt.Sprintf("alloc_%d", next)
+		next++
+	}
+}
+
+// validateSingleHub returns an error if more than one allocation sets hub.
+func validateSingleHub(allocations []interface{}) error {
+	first := -1
+	for i, alloc := range allocations {
+		if hub, _ := alloc.(map[string]interface{})["hub"].(bool); !hub {
+			continue
+		}
+		if first >= 0 {
+			return &AllocationBlockError{Index: i, Attribute: "hub", Err: fmt.Errorf("allocation %d: hub is already set on allocation %d; route_table has one hub", i, first)}
+		}
+		first = i
+	}
+	return nil
+}
+
+// validateAllocationNamesSet returns an error if an allocation has no name
+// and names aren't generated automatically.
+func validateAllocationNamesSet(allocations []interface{}, autoGenerateNames bool) error {
+	if autoGenerateNames {
+		return nil
+	}
+	for i, alloc := range allocations {
+		m := alloc.(map[string]interface{})
+		if m["name"].(string) == "" {
+			return &AllocationBlockError{Index: i, Attribute: "name", Err: fmt.Errorf("allocation %d: name is required unless auto_generate_names is true", i)}
+		}
+	}
+	return nil
+}
+
+// expandExclusions converts the exclude list from the schema to exclusions,
+// keeping each one's reason.
+func expandExclusions(exclusions []interface{}) ([]exclusion, error) {
+	result := make([]exclusion, 0, len(exclusions))
+	for _, excl := range exclusions {
+		m := excl.(map[string]interface{})
+		cidrStr := m["cidr"].(string)
+		network, err := cidr.ParseCIDR(cidrStr)
+		if err != nil {
+			return nil, err
+		}
+		reason, _ := m["reason"].(string)
+		result = append(result, exclusion{Network: network, Reason: reason, Source: exclusionSourceExclude})
+	}
+	return result, nil
+}
+
+// oversizedAllocationWarnings returns a warning for each allocation that would
+// consume at least threshold of the base CIDR. A threshold of 0 disables the check.
+func oversizedAllocationWarnings(baseCIDR string, requests []cidr.AllocationRequest, threshold float64) diag.Diagnostics {
+	var diags diag.Diagnostics
+	if threshold <= 0 {
+		return diags
+	}
+
+	for _, req := range requests {
+		baseCIDR := requestBaseCIDR(baseCIDR, req)
+		base, err := cidr.ParseCIDR(baseCIDR)
+		if err != nil {
+			continue
+		}
+		basePrefixLen, _ := base.Mask.Size()
+		if req.PrefixLength < basePrefixLen {
+			continue
+		}
+		fraction := math.Ldexp(1, basePrefixLen-req.PrefixLength)
+		if fraction >= threshold {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Warning,
+				Summary:  fmt.Sprintf("Allocation %q consumes a large fraction of the base CIDR", req.Name),
+				Detail: fmt.Sprintf("A /%d allocation uses %g%% of base CIDR %s. If this is intended, raise "+
+					"oversize_warning_threshold to silence this warning.", req.PrefixLength, fraction*100, baseCIDR),
+			})
+		}
+	}
+	return diags
+}
+
+// sortAllocationRequests returns the requests ordered according to the sort
+// strategy. Requests of equal size keep their declaration order.
+func sortAllocationRequests(requests []cidr.AllocationRequest, strategy string) []cidr.AllocationRequest {
+	sorted := make([]cidr.AllocationRequest, len(requests))
+	copy(sorted, requests)
+
+	switch strategy {
+	case SortStrategyLargestFirst:
+		sort.SliceStable(sorted, func(i, j int) bool {
+			return sorted[i].PrefixLength < sorted[j].PrefixLength
+		})
+	case SortStrategySmallestFirst:
+		sort.SliceStable(sorted, func(i, j int) bool {
+			return sorted[i].PrefixLength > sorted[j].PrefixLength
+		})
+	}
+
+	return sorted
+}
+
+// flattenAllocations converts the allocation results map to a schema-compatible format.
+func flattenAllocations(allocations map[string]string) map[string]interface{} {
+	result := make(map[string]interface{})
+	for name, cidrBlock := range allocations {
+		result[name] = cidrBlock
+	}
+	return result
+}
+
+// flattenUsableHosts returns the allocations_first_usable and
+// allocations_last_usable maps for the allocations map. Both are derived from
+// allocations whenever it is set, so they can't disagree with it. A /31 has
+// both addresses usable and a /32 is a single host, so its first and last
+// usable addresses are the same. Allocations that don't parse are left out.
+func flattenUsableHosts(allocations map[string]interface{}) (first, last map[string]interface{}) {
+	first = make(map[string]interface{}, len(allocations))
+	last = make(map[string]interface{}, len(allocations))
+	for name, cidrBlock := range allocations {
+		network, err := cidr.ParseCIDR(cidrBlock.(string))
+		if err != nil {
+			continue
+		}
+		firstHost, lastHost, _ := cidr.UsableHosts(network)
+		if firstHost == nil {
+			continue
+		}
+		first[name] = firstHost.String()
+		last[name] = lastHost.String()
+	}
+	return first, last
+}
+
+// allocationHashIDLength is the number of hex characters of an allocation's
+// hash ID.
+const allocationHashIDLength = 8
+
+// allocationHashID returns the hash ID of the allocation name in the pool
+// poolID: the start of the SHA-256 of "<poolID>:<name>". It depends on
+// neither the allocation's CIDR nor its position in the configuration.
+func allocationHashID(poolID, name string) string {
+	sum := sha256.Sum256([]byte(poolID + ":" + name))
+	return hex.EncodeToString(sum[:])[:allocationHashIDLength]
+}
+
+// flattenAllocationHashIDs returns the allocation_hash_ids map for the
+// allocations map of the pool poolID.
+func flattenAllocationHashIDs(poolID string, allocations map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(allocations))
+	for name := range allocations {
+		result[name] = allocationHashID(poolID, name)
+	}
+	return result
+}
+
+// flattenReverseZones returns the allocations_reverse_zones map for the
+// allocations map, with each allocation's zones comma-separated in address
+// order. Like the usable host maps, it is derived from allocations whenever
+// that is set. Allocations that don't parse are left out.
+func flattenReverseZones(allocations map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(allocations))
+	for name, cidrBlock := range allocations {
+		network, err := cidr.ParseCIDR(cidrBlock.(string))
+		if err != nil {
+			continue
+		}
+		result[name] = strings.Join(cidr.ReverseZones(network), ",")
+	}
+	return result
+}
+
+// flattenCIDRSubnets returns the allocations_cidrsubnet list for the results,
+// in request order, with the cidrsubnet arguments that reproduce each
+// allocation from baseCIDR. Allocations cidrsubnet can't reproduce, such as
+// those with their own base_cidr outside the pool's, are left out with a
+// warning.
+func flattenCIDRSubnets(baseCIDR string, results map[string]string, requests []cidr.AllocationRequest) ([]interface{}, diag.Diagnostics) {
+	result := make([]interface{}, 0, len(results))
+	base, err := cidr.ParseCIDR(baseCIDR)
+	if err != nil {
+		return result, nil
+	}
+
+	var diags diag.Diagnostics
+	for _, req := range requests {
+		block, ok := results[req.Name]
+		if !ok {
+			continue
+		}
+		network, err := cidr.ParseCIDR(block)
+		if err != nil {
+			continue
+		}
+		newbits, netnum, err := cidr.CIDRSubnetArgs(base, network)
+		if err != nil {
+			log.Printf("[INFO] Leaving %q out of allocations_cidrsubnet: %s", req.Name, err)
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Warning,
+				Summary:  "Allocation not expressible with cidrsubnet",
+				Detail:   fmt.Sprintf("Allocation %q (%s) is left out of allocations_cidrsubnet because %s.", req.Name, block, err),
+			})
+			continue
+		}
+		result = append(result, map[string]interface{}{
+			"name":    req.Name,
+			"newbits": newbits,
+			"netnum":  int(netnum),
+		})
+	}
+	return result, diags
+}
+
+// globalRegion is the region_cidrs key for allocations without a region.
+const globalRegion = "global"
+
+// groupAllocationsByRegion maps each region to the CIDR of the first request
+// in that region, in request order. Later requests in the same region are
+// left out, so that region_cidrs has one CIDR per region for for_each.
+// Requests without a region are grouped under globalRegion.
+func groupAllocationsByRegion(results map[string]string, requests []cidr.AllocationRequest) map[string]string {
+	byRegion := make(map[string]string)
+	for _, req := range requests {
+		region := req.Region
+		if region == "" {
+			region = globalRegion
+		}
+		if _, ok := byRegion[region]; ok {
+			continue
+		}
+		if cidrBlock, ok := results[req.Name]; ok {
+			byRegion[region] = cidrBlock
+		}
+	}
+	return byRegion
+}
+
+// defaultGroup is the group of allocations without a group.
+const defaultGroup = "default"
+
+// GroupAllocations maps each allocation group to the CIDR blocks of its
+// allocations, in request order. Requests without a group are in the default
+// group, and requests that weren't allocated are skipped.
+func GroupAllocations(results map[string]string, requests []cidr.AllocationRequest) map[string][]string {
+	groups := make(map[string][]string)
+	for _, req := range requests {
+		cidrBlock, ok := results[req.Name]
+		if !ok {
+			continue
+		}
+		group := req.Group
+		if group == "" {
+			group = defaultGroup
+		}
+		groups[group] = append(groups[group], cidrBlock)
+	}
+	return groups
+}
+
+// flattenGroups converts the result of GroupAllocations to the groups
+// attribute. Map values must be strings, so each group's CIDRs are
+// comma-separated.
+func flattenGroups(groups map[string][]string) map[string]interface{} {
+	result := make(map[string]interface{}, len(groups))
+	for group, cidrBlocks := range groups {
+		result[group] = strings.Join(cidrBlocks, ",")
+	}
+	return result
+}
+
+// summarizeGroups returns the smallest CIDR block containing every allocation
+// of each group.
+func summarizeGroups(groups map[string][]string) (map[string]interface{}, error) {
+	result := make(map[string]interface{}, len(groups))
+	for group, cidrBlocks := range groups {
+		networks, err := cidr.ParseCIDRs(cidrBlocks)
+		if err != nil {
+			return nil, err
+		}
+		supernet, err := cidr.CoveringSupernet(networks)
+		if err != nil {
+			return nil, fmt.Errorf("error summarizing group %s: %w", group, err)
+		}
+		result[group] = supernet.String()
+	}
+	return result, nil
+}
+
+// validateBaseCIDRVisibility returns an error if no allocation in baseCIDR
+// could meet the visibility of one of the requests: an external request needs
+// a base overlapping an RFC 1918 range, and an internal request a base that
+// isn't entirely within cidr.HomeNetworkBlock. Requests without a visibility
+// are internal. Allocations in a base that only partly qualifies are
+// checked by validateAllocationVisibility once made.
+func validateBaseCIDRVisibility(baseCIDR string, requests []cidr.AllocationRequest) error {
+	home, _ := cidr.ParseCIDR(cidr.HomeNetworkBlock)
+	for _, req := range requests {
+		baseCIDR := requestBaseCIDR(baseCIDR, req)
+		base, err := cidr.ParseCIDR(baseCIDR)
+		if err != nil {
+			return err
+		}
+		switch req.Visibility {
+		case cidr.VisibilityExternal:
+			private := false
+			for _, block := range cidr.RFC1918Blocks {
+				network, _ := cidr.ParseCIDR(block)
+				if cidr.Classify(base, network) != cidr.RelationshipNone {
+					private = true
+					break
+				}
+			}
+			if !private {
+				return fmt.Errorf("allocation %q is external but base_cidr %s is not RFC 1918 address space", req.Name, baseCIDR)
+			}
+		case cidr.VisibilityInternal, "":
+			if cidr.ContainsNetwork(home, base) {
+				return fmt.Errorf("allocation %q is internal but base_cidr %s is within %s; set its visibility to external or use another base_cidr", req.Name, baseCIDR, cidr.HomeNetworkBlock)
+			}
+		}
+	}
+	return nil
+}
+
+// validateAllocationVisibility returns an error naming every allocation whose
+// CIDR doesn't meet its request's visibility.
+func validateAllocationVisibility(results map[string]string, requests []cidr.AllocationRequest) error {
+	var problems []string
+	for _, req := range requests {
+		block, ok := results[req.Name]
+		if !ok {
+			continue
+		}
+		network, err := cidr.ParseCIDR(block)
+		if err != nil {
+			return err
+		}
+		if err := cidr.CheckVisibility(network, req.Visibility); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %s", req.Name, err))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("allocations don't meet their visibility: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// doTagRegexp matches a valid DigitalOcean tag name.
+var doTagRegexp = regexp.MustCompile(`^[a-zA-Z0-9_:-]{1,255}$`)
+
+// tagRecommendations maps each requested allocation to the tags recommended
+// for it, comma-separated since map values must be strings. DigitalOcean
+// tags can't contain commas.
+func tagRecommendations(requests []cidr.AllocationRequest, tags []string) map[string]string {
+	result := make(map[string]string)
+	if len(tags) == 0 {
+		return result
+	}
+	joined := strings.Join(tags, ",")
+	for _, req := range requests {
+		result[req.Name] = joined
+	}
+	return result
+}
+
+// allocationsDigest returns the digest attribute: the hex-encoded SHA-256 of
+// a "name=cidr\n" line for each allocation, sorted by name. It depends only on
+// the allocations, so a replacement pool with the same CIDRs keeps its digest.
+func allocationsDigest(allocations map[string]string) string {
+	names := make([]string, 0, len(allocations))
+	for name := range allocations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	hash := sha256.New()
+	for _, name := range names {
+		fmt.Fprintf(hash, "%s=%s\n", name, allocations[name])
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// scanReport records the exclusions considered while allocating.
+type scanReport struct {
+	ExistingCIDRs  []*net.IPNet
+	EnvExclusions  []*net.IPNet
+	Exclusions     []exclusion
+	SkippedSources []string
+	// NameFiltered is the number of VPCs and Kubernetes clusters ignored by
+	// the scan name filter.
+	NameFiltered int
+	APIMetrics   config.APIMetricsSummary
+}
+
+// flattenScanReport converts a scan report to a schema-compatible format.
+func flattenScanReport(report *scanReport) []interface{} {
+	return []interface{}{
+		map[string]interface{}{
+			"existing_cidrs":  flattenNetworks(report.ExistingCIDRs),
+			"env_exclusions":  flattenNetworks(report.EnvExclusions),
+			"exclusions":      flattenExclusions(report.Exclusions),
+			"skipped_sources": flattenStrings(report.SkippedSources),
+			"name_filtered":   report.NameFiltered,
+			"api_metrics":     flattenAPIMetrics(report.APIMetrics),
+		},
+	}
+}
+
+// flattenRouteTable converts route entries to a schema-compatible format.
+func flattenRouteTable(routes []cidr.RouteEntry) []interface{} {
+	result := make([]interface{}, 0, len(routes))
+	for _, route := range routes {
+		result = append(result, map[string]interface{}{
+			"allocation_name":  route.AllocationName,
+			"destination_cidr": route.DestinationCIDR,
+			"via_cidr":         route.ViaCIDR,
+		})
+	}
+	return result
+}
+
+// flattenAPIMetrics converts an API metrics summary to a schema-compatible
+// format.
+func flattenAPIMetrics(summary config.APIMetricsSummary) []interface{} {
+	pages := make(map[string]interface{}, len(summary.Pages))
+	for path, count := range summary.Pages {
+		pages[path] = count
+	}
+	return []interface{}{
+		map[string]interface{}{
+			"calls":                summary.Calls,
+			"retries":              summary.Retries,
+			"pages":                pages,
+			"http_time_seconds":    summary.HTTPTime.Seconds(),
+			"rate_limit_remaining": summary.RateLimitRemaining,
+		},
+	}
+}
+
+// flattenNetworks converts a slice of networks to a list of CIDR strings.
+func flattenNetworks(networks []*net.IPNet) []interface{} {
+	result := make([]interface{}, 0, len(networks))
+	for _, network := range networks {
+		result = append(result, network.String())
+	}
+	return result
+}
+
+// flattenStrings converts a slice of strings to a schema-compatible list.
+func flattenStrings(values []string) []interface{} {
+	result := make([]interface{}, 0, len(values))
+	for _, v := range values {
+		result = append(result, v)
+	}
+	return result
+}
+
+// validatePeeringRanges checks that every peering_ranges value is a valid CIDR.
+func validatePeeringRanges(v interface{}, path cty.Path) diag.Diagnostics {
+	var diags diag.Diagnostics
+	for vpcID, ipRange := range v.(map[string]interface{}) {
+		if _, err := cidr.ParseCIDR(ipRange.(string)); err != nil {
+			diags = append(diags, diag.Diagnostic{
+				Severity:      diag.Error,
+				Summary:       "Invalid peering range",
+				Detail:        fmt.Sprintf("peering_ranges entry for VPC %s: %s", vpcID, err),
+				AttributePath: path.IndexString(vpcID),
+			})
+		}
+	}
+	return diags
+}
+
+// suppressAllocationReorder suppresses changes to allocation blocks of a pool
+// with stable_allocation set when the blocks were only reordered.
+func suppressAllocationReorder(k, old, new string, d *schema.ResourceData) bool {
+	if d.Id() == "" || !d.Get("stable_allocation").(bool) {
+		return false
+	}
+	before, after := d.GetChange("allocation")
+	autoGenerateNames := d.Get("auto_generate_names").(bool)
+	beforeRequests := expandAllocations(before.([]interface{}), autoGenerateNames)
+	afterRequests := expandAllocations(after.([]interface{}), autoGenerateNames)
+	return sameAllocationRequests(beforeRequests, afterRequests) &&
+		sameAllowedPrefixRanges(before.([]interface{}), beforeRequests, after.([]interface{}), afterRequests)
+}
+
+// sameAllowedPrefixRanges reports whether the allocation blocks a and b, whose
+// expanded requests are aRequests and bRequests, give each name the same
+// allowed_prefix_range.
+func sameAllowedPrefixRanges(a []interface{}, aRequests []cidr.AllocationRequest, b []interface{}, bRequests []cidr.AllocationRequest) bool {
+	ranges := func(allocations []interface{}, requests []cidr.AllocationRequest) map[string]prefixRange {
+		result := make(map[string]prefixRange, len(requests))
+		for i, alloc := range allocations {
+			if r, ok := expandAllowedPrefixRange(alloc.(map[string]interface{})); ok {
+				result[requests[i].Name] = r
+			}
+		}
+		return result
+	}
+
+	aRanges, bRanges := ranges(a, aRequests), ranges(b, bRequests)
+	if len(aRanges) != len(bRanges) {
+		return false
+	}
+	for name, r := range aRanges {
+		if other, ok := bRanges[name]; !ok || other != r {
+			return false
+		}
+	}
+	return true
+}
+
+// sameAllocationRequests reports whether a and b request the same names,
+// sizes, regions, visibilities and exclusions, in any order.
+func sameAllocationRequests(a, b []cidr.AllocationRequest) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	byName := make(map[string]cidr.AllocationRequest, len(a))
+	for _, req := range a {
+		byName[req.Name] = req
+	}
+	for _, req := range b {
+		if prev, ok := byName[req.Name]; !ok || !sameAllocationRequest(prev, req) {
+			return false
+		}
+	}
+	return true
+}
+
+// sameAllocationRequest reports whether a and b are the same request. Their
+// exclusions may be listed in any order.
+func sameAllocationRequest(a, b cidr.AllocationRequest) bool {
+	if a.Name != b.Name || a.PrefixLength != b.PrefixLength || a.Region != b.Region || a.Group != b.Group || a.Hub != b.Hub || a.Visibility != b.Visibility {
+		return false
+	}
+	if requestBaseCIDR("", a) != requestBaseCIDR("", b) {
+		return false
+	}
+	return strings.Join(sortedNetworkStrings(a.Exclusions), ",") == strings.Join(sortedNetworkStrings(b.Exclusions), ",")
+}
+
+// sortedNetworkStrings returns the networks as sorted CIDR strings.
+func sortedNetworkStrings(networks []*net.IPNet) []string {
+	result := make([]string, 0, len(networks))
+	for _, network := range networks {
+		result = append(result, network.String())
+	}
+	sort.Strings(result)
+	return result
+}
+
+// expandReservations returns reservations that keep each request's previous
+// CIDR when stable_allocation is set, and the requests that can't keep theirs.
+// A previous CIDR is only kept if it is the same size as the request, lies
+// within the base CIDR and doesn't overlap any of the exclusions or another
+// kept CIDR.
+func expandReservations(previous map[string]interface{}, requests []cidr.AllocationRequest, allocator *cidr.Allocator, exclusions []*net.IPNet) ([]cidr.ReservationRequest, []cidr.MovedAllocation) {
+	prev := make(map[string]string, len(previous))
+	for name, block := range previous {
+		prev[name] = block.(string)
+	}
+
+	reservations, moved := allocator.PreferPrevious(prev, requests, exclusions)
+	for _, r := range reservations {
+		log.Printf("[INFO] Keeping previous allocation %s for %q", r.CIDR.String(), r.Name)
+	}
+	return reservations, moved
+}
+
+// movedAllocationWarnings returns a warning for each allocation that couldn't
+// keep its previous CIDR, naming the CIDR it was given instead.
+func movedAllocationWarnings(moved []cidr.MovedAllocation, results map[string]string) diag.Diagnostics {
+	var diags diag.Diagnostics
+	for _, m := range moved {
+		log.Printf("[INFO] Moving %q from previous allocation %s to %s: %s", m.Name, m.Previous, results[m.Name], m.Reason)
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Warning,
+			Summary:  "Allocation moved",
+			Detail: fmt.Sprintf("Allocation %q couldn't keep its previous CIDR %s because %s, so it was allocated %s. "+
+				"Resources built from %s need to be moved to the new CIDR.", m.Name, m.Previous, m.Reason, results[m.Name], m.Previous),
+		})
+	}
+	return diags
+}
+
+// isGrownBaseCIDR reports whether new contains all of old, so that changing
+// base_cidr from old to new keeps every allocation made from it within the
+// base and the pool can be updated in place.
+func isGrownBaseCIDR(old, new string) bool {
+	if old == "" || new == "" {
+		return false
+	}
+	oldBase, err := cidr.ParseCIDR(old)
+	if err != nil {
+		return false
+	}
+	newBase, err := cidr.ParseCIDR(new)
+	if err != nil {
+		return false
+	}
+	return cidr.ContainsNetwork(newBase, oldBase)
+}
+
+// isExpandedBaseCIDR reports whether old is what base_cidr_expansion would
+// have widened the configured base CIDR new to, so the widening doesn't force
+// the pool to be replaced on the next plan.
+func isExpandedBaseCIDR(old, new string) bool {
+	if old == "" || new == "" {
+		return false
+	}
+	stored, err := cidr.ParseCIDR(old)
+	if err != nil {
+		return false
+	}
+	configured, err := cidr.ParseCIDR(new)
+	if err != nil {
+		return false
+	}
+	for i := 0; i < maxBaseCIDRExpansions; i++ {
+		if configured, err = cidr.ExpandCIDR(configured); err != nil {
+			return false
+		}
+		if configured.String() == stored.String() {
+			return true
+		}
+	}
+	return false
+}
+
+// validateExcludePattern validates a wildcard exclusion pattern.
+func validateExcludePattern(v interface{}, k string) ([]string, []error) {
+	if _, err := cidr.PatternToCIDR(v.(string)); err != nil {
+		return nil, []error{fmt.Errorf("%s: %w", k, err)}
+	}
+	return nil, nil
+}
+
+// expandExcludePatterns converts the exclude_patterns list to CIDR blocks.
+func expandExcludePatterns(patterns []interface{}) ([]*net.IPNet, error) {
+	result := make([]*net.IPNet, 0, len(patterns))
+	for _, p := range patterns {
+		network, err := cidr.PatternToCIDR(p.(string))
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, network)
+	}
+	return result, nil
+}
+
+// validateAllocationCount returns an error if there are more allocations than
+// limit.
+func validateAllocationCount(count, limit int) error {
+	if count > limit {
+		return fmt.Errorf("too many allocations: %d requested, limit is %d. Set allocation_count_limit to override.", count, limit)
+	}
+	return nil
+}
+
+// validateUniqueAllocationNames checks that all allocation names are unique.
+// Unnamed allocations are skipped.
+func validateUniqueAllocationNames(allocations []interface{}) error {
+	seen := make(map[string]bool)
+	for i, alloc := range allocations {
 		m := alloc.(map[string]interface{})
 		name := m["name"].(string)
+		if name == "" {
+			continue
+		}
 		if seen[name] {
-			return &DuplicateNameError{Name: name}
+			return &DuplicateNameError{Name: name, Index: i}
 		}
 		seen[name] = true
 	}
@@ -151,8 +1831,201 @@ func validateUniqueAllocationNames(allocations []interface{}) error {
 // DuplicateNameError is returned when duplicate allocation names are found.
 type DuplicateNameError struct {
 	Name string
+	// Index is the position of the allocation block repeating the name.
+	Index int
 }
 
 func (e *DuplicateNameError) Error() string {
 	return "duplicate allocation name: " + e.Name
 }
+
+// compiledNameRegexps caches compiled allocation_names_regex patterns by pattern string.
+var compiledNameRegexps sync.Map
+
+// compileNameRegex returns the compiled regular expression for pattern, compiling
+// and caching it on first use.
+func compileNameRegex(pattern string) (*regexp.Regexp, error) {
+	if re, ok := compiledNameRegexps.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid allocation_names_regex %q: %w", pattern, err)
+	}
+
+	compiledNameRegexps.Store(pattern, re)
+	return re, nil
+}
+
+// validateAllocationNamesRegex checks that all allocation names match the given
+// pattern. An empty pattern skips validation. Unnamed allocations are skipped,
+// so generated names aren't subject to the pattern.
+func validateAllocationNamesRegex(pattern string, allocations []interface{}) error {
+	if pattern == "" {
+		return nil
+	}
+
+	re, err := compileNameRegex(pattern)
+	if err != nil {
+		return err
+	}
+
+	var invalid []string
+	first := -1
+	for i, alloc := range allocations {
+		m := alloc.(map[string]interface{})
+		name := m["name"].(string)
+		if name != "" && !re.MatchString(name) {
+			invalid = append(invalid, name)
+			if first < 0 {
+				first = i
+			}
+		}
+	}
+
+	if len(invalid) > 0 {
+		return &NameMismatchError{Pattern: pattern, Names: invalid, Index: first}
+	}
+	return nil
+}
+
+// prefixRange is an inclusive range of prefix lengths. A bound of 0 is unset.
+type prefixRange struct {
+	Min int
+	Max int
+}
+
+// expandAllowedPrefixRange returns the allowed_prefix_range of an allocation
+// block, or false if it has none.
+func expandAllowedPrefixRange(m map[string]interface{}) (prefixRange, bool) {
+	blocks, _ := m["allowed_prefix_range"].([]interface{})
+	if len(blocks) == 0 || blocks[0] == nil {
+		return prefixRange{}, false
+	}
+	block := blocks[0].(map[string]interface{})
+	return prefixRange{Min: block["min"].(int), Max: block["max"].(int)}, true
+}
+
+// validatePoolState checks that the base CIDR and allocations stored in state
+// parse and that no two allocations overlap. It makes no API calls.
+func validatePoolState(baseCIDR string, allocations map[string]interface{}) error {
+	if _, err := cidr.ParseCIDR(baseCIDR); err != nil {
+		return fmt.Errorf("base_cidr: %w", err)
+	}
+
+	names := make([]string, 0, len(allocations))
+	for name := range allocations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	networks := make([]*net.IPNet, len(names))
+	for i, name := range names {
+		network, err := cidr.ParseCIDR(allocations[name].(string))
+		if err != nil {
+			return fmt.Errorf("allocation %q: %w", name, err)
+		}
+		networks[i] = network
+	}
+
+	for i := range networks {
+		for j := i + 1; j < len(networks); j++ {
+			if cidr.Classify(networks[i], networks[j]) != cidr.RelationshipNone {
+				return fmt.Errorf("allocation %q (%s) overlaps allocation %q (%s)", names[i], networks[i], names[j], networks[j])
+			}
+		}
+	}
+	return nil
+}
+
+// validatePrefixLengthBounds checks that every allocation's prefix_length is
+// within its allowed_prefix_range, or the pool's min_prefix_length and
+// max_prefix_length if it has none. A bound of 0 is unset, and values that
+// aren't known yet read as 0 and are skipped.
+func validatePrefixLengthBounds(allocations []interface{}, minPrefixLength, maxPrefixLength int) error {
+	if minPrefixLength > 0 && maxPrefixLength > 0 && minPrefixLength > maxPrefixLength {
+		return fmt.Errorf("min_prefix_length (%d) must not be greater than max_prefix_length (%d)", minPrefixLength, maxPrefixLength)
+	}
+
+	for i, alloc := range allocations {
+		m := alloc.(map[string]interface{})
+		name := m["name"].(string)
+
+		bounds, allocationRange := expandAllowedPrefixRange(m)
+		if !allocationRange {
+			bounds = prefixRange{Min: minPrefixLength, Max: maxPrefixLength}
+		} else if bounds.Min > 0 && bounds.Max > 0 && bounds.Min > bounds.Max {
+			return &AllocationBlockError{Index: i, Attribute: "allowed_prefix_range", Err: fmt.Errorf("%s: allowed_prefix_range min (%d) must not be greater than max (%d)", describeAllocation(i, name), bounds.Min, bounds.Max)}
+		}
+
+		prefixLength := m["prefix_length"].(int)
+		if prefixLength == 0 {
+			continue
+		}
+		if (bounds.Min > 0 && prefixLength < bounds.Min) || (bounds.Max > 0 && prefixLength > bounds.Max) {
+			return &PrefixLengthBoundsError{
+				Index:           i,
+				Name:            name,
+				PrefixLength:    prefixLength,
+				Min:             bounds.Min,
+				Max:             bounds.Max,
+				AllocationRange: allocationRange,
+			}
+		}
+	}
+	return nil
+}
+
+// describeAllocation names an allocation in an error message, by its name if
+// it has one and otherwise by its position.
+func describeAllocation(index int, name string) string {
+	if name != "" {
+		return fmt.Sprintf("allocation %q", name)
+	}
+	return fmt.Sprintf("allocation %d", index)
+}
+
+// PrefixLengthBoundsError is returned when an allocation's prefix_length is
+// outside its allowed_prefix_range, or the pool's min_prefix_length and
+// max_prefix_length.
+type PrefixLengthBoundsError struct {
+	Index        int
+	Name         string
+	PrefixLength int
+	Min          int
+	Max          int
+
+	// AllocationRange is true when the bounds are the allocation's own
+	// allowed_prefix_range rather than the pool's.
+	AllocationRange bool
+}
+
+func (e *PrefixLengthBoundsError) Error() string {
+	allocation := describeAllocation(e.Index, e.Name)
+	if e.AllocationRange {
+		return fmt.Sprintf("%s: prefix_length %d is outside its allowed_prefix_range %d-%d", allocation, e.PrefixLength, e.Min, e.Max)
+	}
+
+	var bounds []string
+	if e.Min > 0 {
+		bounds = append(bounds, fmt.Sprintf("min_prefix_length %d", e.Min))
+	}
+	if e.Max > 0 {
+		bounds = append(bounds, fmt.Sprintf("max_prefix_length %d", e.Max))
+	}
+	return fmt.Sprintf("%s: prefix_length %d is outside this pool's %s", allocation, e.PrefixLength, strings.Join(bounds, " and "))
+}
+
+// NameMismatchError is returned when allocation names do not match allocation_names_regex.
+type NameMismatchError struct {
+	Pattern string
+	Names   []string
+	// Index is the position of the first allocation block whose name
+	// doesn't match.
+	Index int
+}
+
+func (e *NameMismatchError) Error() string {
+	return fmt.Sprintf("allocation names do not match allocation_names_regex %q: %s", e.Pattern, strings.Join(e.Names, ", "))
+}
@@ -1,11 +1,17 @@
 package pool
 
 import (
+	"fmt"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/DO-Solutions/terraform-provider-docidr/docidr/cidr"
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
 )
 
 func TestValidateUniqueAllocationNames(t *testing.T) {
@@ -60,6 +66,242 @@ func TestValidateUniqueAllocationNames(t *testing.T) {
 	}
 }
 
+func TestValidateAllocationNamesRegex(t *testing.T) {
+	allocations := []interface{}{
+		map[string]interface{}{"name": "vpc_main", "prefix_length": 16},
+		map[string]interface{}{"name": "k8s_cluster", "prefix_length": 20},
+		map[string]interface{}{"name": "scratch", "prefix_length": 24},
+		map[string]interface{}{"name": "tmp_net", "prefix_length": 24},
+	}
+
+	tests := []struct {
+		name        string
+		pattern     string
+		allocations []interface{}
+		wantErr     bool
+		wantNames   []string
+	}{
+		{
+			name:        "all names valid",
+			pattern:     `^(vpc|k8s|db)_`,
+			allocations: allocations[:2],
+			wantErr:     false,
+		},
+		{
+			name:        "invalid names listed",
+			pattern:     `^(vpc|k8s|db)_`,
+			allocations: allocations,
+			wantErr:     true,
+			wantNames:   []string{"scratch", "tmp_net"},
+		},
+		{
+			name:        "empty pattern skips validation",
+			pattern:     "",
+			allocations: allocations,
+			wantErr:     false,
+		},
+		{
+			name:        "invalid pattern",
+			pattern:     `^(vpc|k8s`,
+			allocations: allocations,
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateAllocationNamesRegex(tt.pattern, tt.allocations)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validateAllocationNamesRegex() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantNames == nil {
+				return
+			}
+			mismatch, ok := err.(*NameMismatchError)
+			if !ok {
+				t.Fatalf("expected NameMismatchError, got %T", err)
+			}
+			if strings.Join(mismatch.Names, ",") != strings.Join(tt.wantNames, ",") {
+				t.Errorf("NameMismatchError.Names = %v, want %v", mismatch.Names, tt.wantNames)
+			}
+		})
+	}
+}
+
+func TestCompileNameRegex_Cached(t *testing.T) {
+	first, err := compileNameRegex(`^vpc_`)
+	if err != nil {
+		t.Fatalf("compileNameRegex() error = %v", err)
+	}
+	second, err := compileNameRegex(`^vpc_`)
+	if err != nil {
+		t.Fatalf("compileNameRegex() error = %v", err)
+	}
+	if first != second {
+		t.Error("expected compiled regex to be reused from cache")
+	}
+}
+
+func TestValidatePrefixLengthBounds(t *testing.T) {
+	allocations := []interface{}{
+		map[string]interface{}{"name": "vpc", "prefix_length": 16},
+		map[string]interface{}{"name": "k8s", "prefix_length": 20},
+		map[string]interface{}{"name": "", "prefix_length": 28},
+	}
+
+	tests := []struct {
+		name        string
+		allocations []interface{}
+		min         int
+		max         int
+		wantErr     string
+	}{
+		{name: "no bounds", allocations: allocations},
+		{name: "within bounds", allocations: allocations, min: 16, max: 28},
+		{name: "below min", allocations: allocations, min: 18, wantErr: `allocation "vpc": prefix_length 16 is outside this pool's min_prefix_length 18`},
+		{name: "above max", allocations: allocations[:2], max: 18, wantErr: `allocation "k8s": prefix_length 20 is outside this pool's max_prefix_length 18`},
+		{name: "unnamed allocation", allocations: allocations[1:], min: 20, max: 24, wantErr: "allocation 1: prefix_length 28 is outside this pool's min_prefix_length 20 and max_prefix_length 24"},
+		{name: "min greater than max", allocations: allocations, min: 24, max: 20, wantErr: "min_prefix_length (24) must not be greater than max_prefix_length (20)"},
+		{
+			name: "allowed_prefix_range overrides pool bounds",
+			allocations: []interface{}{
+				map[string]interface{}{"name": "backbone", "prefix_length": 16, "allowed_prefix_range": []interface{}{map[string]interface{}{"min": 16, "max": 20}}},
+				map[string]interface{}{"name": "management", "prefix_length": 26, "allowed_prefix_range": []interface{}{map[string]interface{}{"min": 24, "max": 28}}},
+			},
+			min: 20,
+			max: 24,
+		},
+		{
+			name: "outside allowed_prefix_range",
+			allocations: []interface{}{
+				map[string]interface{}{"name": "management", "prefix_length": 22, "allowed_prefix_range": []interface{}{map[string]interface{}{"min": 24, "max": 28}}},
+			},
+			wantErr: `allocation "management": prefix_length 22 is outside its allowed_prefix_range 24-28`,
+		},
+		{
+			name: "allowed_prefix_range min greater than max",
+			allocations: []interface{}{
+				map[string]interface{}{"name": "management", "prefix_length": 24, "allowed_prefix_range": []interface{}{map[string]interface{}{"min": 28, "max": 24}}},
+			},
+			wantErr: `allocation "management": allowed_prefix_range min (28) must not be greater than max (24)`,
+		},
+		{
+			name:        "unknown prefix length skipped",
+			allocations: []interface{}{map[string]interface{}{"name": "vpc", "prefix_length": 0}},
+			min:         20,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validatePrefixLengthBounds(tt.allocations, tt.min, tt.max)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("validatePrefixLengthBounds() error = %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.wantErr {
+				t.Fatalf("validatePrefixLengthBounds() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestResourceDocidrPoolCustomizeDiff_PrefixLengthBounds(t *testing.T) {
+	raw := map[string]interface{}{
+		"min_prefix_length": 20,
+		"max_prefix_length": 24,
+		"allocation": []interface{}{
+			map[string]interface{}{"name": "vpc", "prefix_length": 24},
+			map[string]interface{}{"name": "k8s", "prefix_length": 16},
+		},
+	}
+
+	_, err := planPool(t, raw, nil)
+	if err == nil || !strings.Contains(err.Error(), `allocation "k8s"`) {
+		t.Fatalf("planPool() error = %v, want it to name allocation k8s", err)
+	}
+
+	raw["allocation"] = raw["allocation"].([]interface{})[:1]
+	if _, err := planPool(t, raw, nil); err != nil {
+		t.Fatalf("planPool() error = %v", err)
+	}
+}
+
+func TestResourceDocidrPoolCustomizeDiff_AllocationCountLimit(t *testing.T) {
+	allocations := func(n int) []interface{} {
+		result := make([]interface{}, 0, n)
+		for i := 0; i < n; i++ {
+			result = append(result, map[string]interface{}{"name": fmt.Sprintf("vpc%d", i), "prefix_length": 24})
+		}
+		return result
+	}
+
+	tests := []struct {
+		name    string
+		count   int
+		limit   int
+		wantErr string
+	}{
+		{name: "at default limit", count: 50},
+		{name: "over default limit", count: 51, wantErr: "too many allocations: 51 requested, limit is 50. Set allocation_count_limit to override."},
+		{name: "raised limit", count: 120, limit: 200},
+		{name: "lowered limit", count: 4, limit: 3, wantErr: "too many allocations: 4 requested, limit is 3. Set allocation_count_limit to override."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw := map[string]interface{}{"allocation": allocations(tt.count)}
+			if tt.limit != 0 {
+				raw["allocation_count_limit"] = tt.limit
+			}
+
+			_, err := planPool(t, raw, nil)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("planPool() error = %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.wantErr {
+				t.Fatalf("planPool() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAllocationCountLimitValidation(t *testing.T) {
+	validateFunc := ResourceDocidrPool().Schema["allocation_count_limit"].ValidateFunc
+	for value, wantErr := range map[int]bool{0: true, 1: false, 50: false, 500: false, 501: true} {
+		if _, errs := validateFunc(value, "allocation_count_limit"); (len(errs) > 0) != wantErr {
+			t.Errorf("allocation_count_limit = %d: errors = %v, wantErr %v", value, errs, wantErr)
+		}
+	}
+}
+
+func TestResourceDocidrPoolCustomizeDiff_AllowedPrefixRange(t *testing.T) {
+	raw := map[string]interface{}{
+		"allocation": []interface{}{
+			map[string]interface{}{
+				"name":                 "backbone",
+				"prefix_length":        16,
+				"allowed_prefix_range": []interface{}{map[string]interface{}{"min": 16, "max": 20}},
+			},
+			map[string]interface{}{
+				"name":                 "management",
+				"prefix_length":        20,
+				"allowed_prefix_range": []interface{}{map[string]interface{}{"min": 24, "max": 28}},
+			},
+		},
+	}
+
+	_, err := planPool(t, raw, nil)
+	if err == nil || !strings.Contains(err.Error(), `allocation "management"`) || !strings.Contains(err.Error(), "24-28") {
+		t.Fatalf("planPool() error = %v, want it to name allocation management and its range", err)
+	}
+}
+
 func TestPrefixLengthValidation(t *testing.T) {
 	validateFunc := validation.IntBetween(16, 28)
 
@@ -115,13 +357,29 @@ func TestCIDRValidation(t *testing.T) {
 	}
 }
 
+func TestValidatePeeringRanges(t *testing.T) {
+	valid := map[string]interface{}{"vpc-partner": "172.16.0.0/16"}
+	if diags := validatePeeringRanges(valid, cty.Path{}); diags.HasError() {
+		t.Errorf("validatePeeringRanges(%v) diags = %v", valid, diags)
+	}
+
+	invalid := map[string]interface{}{"vpc-partner": "172.16.0.0/33"}
+	diags := validatePeeringRanges(invalid, cty.Path{})
+	if !diags.HasError() {
+		t.Fatalf("validatePeeringRanges(%v) expected error", invalid)
+	}
+	if !strings.Contains(diags[0].Detail, "vpc-partner") {
+		t.Errorf("error detail = %q, want it to mention the VPC ID", diags[0].Detail)
+	}
+}
+
 func TestExpandAllocations(t *testing.T) {
 	input := []interface{}{
 		map[string]interface{}{"name": "vpc", "prefix_length": 16},
 		map[string]interface{}{"name": "cluster", "prefix_length": 20},
 	}
 
-	result := expandAllocations(input)
+	result := expandAllocations(input, false)
 
 	if len(result) != 2 {
 		t.Fatalf("expected 2 allocations, got %d", len(result))
@@ -137,12 +395,63 @@ func TestExpandAllocations(t *testing.T) {
 }
 
 func TestExpandAllocations_Empty(t *testing.T) {
-	result := expandAllocations([]interface{}{})
+	result := expandAllocations([]interface{}{}, false)
 	if len(result) != 0 {
 		t.Errorf("expected empty slice, got %d items", len(result))
 	}
 }
 
+func TestExpandAllocations_AutoGenerateNames(t *testing.T) {
+	input := []interface{}{
+		map[string]interface{}{"name": "", "prefix_length": 24},
+		map[string]interface{}{"name": "vpc", "prefix_length": 16},
+		map[string]interface{}{"name": "", "prefix_length": 24},
+		map[string]interface{}{"name": "alloc_2", "prefix_length": 20},
+		map[string]interface{}{"name": "", "prefix_length": 24},
+	}
+	want := []string{"alloc_0", "vpc", "alloc_1", "alloc_2", "alloc_3"}
+
+	// Generated names depend only on declaration order, so repeated
+	// expansions agree.
+	for run := 0; run < 3; run++ {
+		result := expandAllocations(input, true)
+		if len(result) != len(want) {
+			t.Fatalf("expected %d allocations, got %d", len(want), len(result))
+		}
+		for i, name := range want {
+			if result[i].Name != name {
+				t.Errorf("run %d: allocation %d name = %q, want %q", run, i, result[i].Name, name)
+			}
+		}
+	}
+
+	// Without auto_generate_names, empty names are left for validation to reject
+	if result := expandAllocations(input, false); result[0].Name != "" {
+		t.Errorf("name = %q, want empty", result[0].Name)
+	}
+}
+
+func TestValidateAllocationNamesSet(t *testing.T) {
+	named := []interface{}{
+		map[string]interface{}{"name": "vpc", "prefix_length": 16},
+	}
+	unnamed := []interface{}{
+		map[string]interface{}{"name": "vpc", "prefix_length": 16},
+		map[string]interface{}{"name": "", "prefix_length": 24},
+	}
+
+	if err := validateAllocationNamesSet(named, false); err != nil {
+		t.Errorf("unexpected error for named allocations: %v", err)
+	}
+	if err := validateAllocationNamesSet(unnamed, true); err != nil {
+		t.Errorf("unexpected error with auto_generate_names: %v", err)
+	}
+	err := validateAllocationNamesSet(unnamed, false)
+	if err == nil || !strings.Contains(err.Error(), "allocation 1") {
+		t.Errorf("error = %v, want error naming allocation 1", err)
+	}
+}
+
 func TestExpandExclusions(t *testing.T) {
 	input := []interface{}{
 		map[string]interface{}{"cidr": "10.0.0.0/16", "reason": "reserved"},
@@ -158,12 +467,15 @@ func TestExpandExclusions(t *testing.T) {
 		t.Fatalf("expected 2 exclusions, got %d", len(result))
 	}
 
-	if result[0].String() != "10.0.0.0/16" {
-		t.Errorf("first exclusion = %s, want 10.0.0.0/16", result[0].String())
+	if result[0].Network.String() != "10.0.0.0/16" || result[0].Reason != "reserved" {
+		t.Errorf("first exclusion = %s, want 10.0.0.0/16 (reason: reserved)", result[0])
 	}
 
-	if result[1].String() != "172.16.0.0/12" {
-		t.Errorf("second exclusion = %s, want 172.16.0.0/12", result[1].String())
+	if result[1].Network.String() != "172.16.0.0/12" || result[1].Reason != "" {
+		t.Errorf("second exclusion = %s, want 172.16.0.0/12 without a reason", result[1])
+	}
+	if result[0].Source != exclusionSourceExclude {
+		t.Errorf("source = %q, want %q", result[0].Source, exclusionSourceExclude)
 	}
 }
 
@@ -188,6 +500,250 @@ func TestExpandExclusions_Empty(t *testing.T) {
 	}
 }
 
+func TestSortAllocationRequests(t *testing.T) {
+	requests := []cidr.AllocationRequest{
+		{Name: "small", PrefixLength: 24},
+		{Name: "medium", PrefixLength: 20},
+		{Name: "large", PrefixLength: 16},
+		{Name: "medium_two", PrefixLength: 20},
+	}
+
+	tests := []struct {
+		strategy string
+		want     []string
+	}{
+		{SortStrategyDeclaration, []string{"small", "medium", "large", "medium_two"}},
+		{SortStrategyLargestFirst, []string{"large", "medium", "medium_two", "small"}},
+		{SortStrategySmallestFirst, []string{"small", "medium", "medium_two", "large"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.strategy, func(t *testing.T) {
+			got := sortAllocationRequests(requests, tt.strategy)
+			var names []string
+			for _, req := range got {
+				names = append(names, req.Name)
+			}
+			if strings.Join(names, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("sortAllocationRequests(%s) = %v, want %v", tt.strategy, names, tt.want)
+			}
+		})
+	}
+
+	// The input slice must not be reordered
+	if requests[0].Name != "small" {
+		t.Error("sortAllocationRequests() modified its input")
+	}
+}
+
+func TestSortAllocationRequests_LargestFirstReducesFragmentation(t *testing.T) {
+	requests := []cidr.AllocationRequest{
+		{Name: "small", PrefixLength: 24},
+		{Name: "medium", PrefixLength: 20},
+		{Name: "large", PrefixLength: 16},
+	}
+
+	allocate := func(strategy string) map[string]string {
+		allocator, err := cidr.NewAllocator("10.0.0.0/8")
+		if err != nil {
+			t.Fatalf("NewAllocator() error = %v", err)
+		}
+		results, err := allocator.Allocate(sortAllocationRequests(requests, strategy), nil)
+		if err != nil {
+			t.Fatalf("Allocate() error = %v", err)
+		}
+		return results
+	}
+
+	declaration := allocate(SortStrategyDeclaration)
+	largestFirst := allocate(SortStrategyLargestFirst)
+
+	// Declaration order leaves gaps before the /20 and the /16, spilling into a second /16.
+	wantDeclaration := map[string]string{
+		"small":  "10.0.0.0/24",
+		"medium": "10.0.16.0/20",
+		"large":  "10.1.0.0/16",
+	}
+	// Largest first packs all three contiguously.
+	wantLargestFirst := map[string]string{
+		"large":  "10.0.0.0/16",
+		"medium": "10.1.0.0/20",
+		"small":  "10.1.16.0/24",
+	}
+
+	for name, want := range wantDeclaration {
+		if declaration[name] != want {
+			t.Errorf("declaration %s = %s, want %s", name, declaration[name], want)
+		}
+	}
+	for name, want := range wantLargestFirst {
+		if largestFirst[name] != want {
+			t.Errorf("largest_first %s = %s, want %s", name, largestFirst[name], want)
+		}
+	}
+}
+
+func TestOversizedAllocationWarnings(t *testing.T) {
+	tests := []struct {
+		name      string
+		base      string
+		requests  []cidr.AllocationRequest
+		threshold float64
+		want      []string
+	}{
+		{
+			name:      "typical allocation",
+			base:      "10.0.0.0/8",
+			requests:  []cidr.AllocationRequest{{Name: "vpc", PrefixLength: 16}},
+			threshold: 0.5,
+		},
+		{
+			name:      "half the base",
+			base:      "10.0.0.0/8",
+			requests:  []cidr.AllocationRequest{{Name: "vpc", PrefixLength: 16}, {Name: "huge", PrefixLength: 9}},
+			threshold: 0.5,
+			want:      []string{"huge"},
+		},
+		{
+			name:      "entire base",
+			base:      "10.0.0.0/16",
+			requests:  []cidr.AllocationRequest{{Name: "all", PrefixLength: 16}},
+			threshold: 0.5,
+			want:      []string{"all"},
+		},
+		{
+			name:      "lower threshold",
+			base:      "10.0.0.0/8",
+			requests:  []cidr.AllocationRequest{{Name: "vpc", PrefixLength: 16}, {Name: "big", PrefixLength: 12}},
+			threshold: 0.0625,
+			want:      []string{"big"},
+		},
+		{
+			name:      "disabled",
+			base:      "10.0.0.0/16",
+			requests:  []cidr.AllocationRequest{{Name: "all", PrefixLength: 16}},
+			threshold: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diags := oversizedAllocationWarnings(tt.base, tt.requests, tt.threshold)
+			if len(diags) != len(tt.want) {
+				t.Fatalf("oversizedAllocationWarnings() = %v, want %d warnings", diags, len(tt.want))
+			}
+			for i, name := range tt.want {
+				if diags[i].Severity != diag.Warning || !strings.Contains(diags[i].Summary, name) {
+					t.Errorf("warning[%d] = %+v, want a warning for %q", i, diags[i], name)
+				}
+			}
+		})
+	}
+}
+
+func TestAllocateExpanding(t *testing.T) {
+	// The first half of the /24 is taken, so two /25s only fit once it's a /23
+	exclusions, err := cidr.ParseCIDRs([]string{"10.0.0.0/25"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	requests := []cidr.AllocationRequest{
+		{Name: "first", PrefixLength: 25},
+		{Name: "second", PrefixLength: 25},
+	}
+
+	tests := []struct {
+		name          string
+		maxExpansions int
+		requests      []cidr.AllocationRequest
+		wantBase      string
+		want          map[string]string
+		wantWarnings  int
+		wantErr       bool
+	}{
+		{
+			name:          "disabled",
+			maxExpansions: 0,
+			requests:      requests,
+			wantErr:       true,
+		},
+		{
+			name:          "expands to /23",
+			maxExpansions: maxBaseCIDRExpansions,
+			requests:      requests,
+			wantBase:      "10.0.0.0/23",
+			want:          map[string]string{"first": "10.0.0.128/25", "second": "10.0.1.0/25"},
+			wantWarnings:  1,
+		},
+		{
+			name:          "fits without expanding",
+			maxExpansions: maxBaseCIDRExpansions,
+			requests:      requests[:1],
+			wantBase:      "10.0.0.0/24",
+			want:          map[string]string{"first": "10.0.0.128/25"},
+		},
+		{
+			name:          "limit reached",
+			maxExpansions: maxBaseCIDRExpansions,
+			requests:      []cidr.AllocationRequest{{Name: "huge", PrefixLength: 21}, {Name: "more", PrefixLength: 21}},
+			wantErr:       true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			allocator, err := cidr.NewAllocator("10.0.0.0/24")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			allocator, results, diags, err := allocateExpanding(allocator, nil, tt.requests, exclusions, tt.maxExpansions)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("allocateExpanding() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := allocator.BaseCIDR().String(); got != tt.wantBase {
+				t.Errorf("base CIDR = %s, want %s", got, tt.wantBase)
+			}
+			for name, want := range tt.want {
+				if results[name] != want {
+					t.Errorf("results[%q] = %q, want %q", name, results[name], want)
+				}
+			}
+			if len(diags) != tt.wantWarnings {
+				t.Errorf("diags = %v, want %d warnings", diags, tt.wantWarnings)
+			}
+			for _, d := range diags {
+				if d.Severity != diag.Warning {
+					t.Errorf("diag = %+v, want a warning", d)
+				}
+			}
+		})
+	}
+}
+
+func TestIsExpandedBaseCIDR(t *testing.T) {
+	tests := []struct {
+		old, new string
+		want     bool
+	}{
+		{old: "10.0.0.0/23", new: "10.0.0.0/24", want: true},
+		{old: "10.0.0.0/21", new: "10.0.1.0/24", want: true},
+		{old: "10.0.0.0/20", new: "10.0.0.0/24", want: false},
+		{old: "10.0.0.0/24", new: "10.0.0.0/24", want: false},
+		{old: "10.1.0.0/23", new: "10.0.0.0/24", want: false},
+		{old: "", new: "10.0.0.0/24", want: false},
+	}
+
+	for _, tt := range tests {
+		if got := isExpandedBaseCIDR(tt.old, tt.new); got != tt.want {
+			t.Errorf("isExpandedBaseCIDR(%q, %q) = %v, want %v", tt.old, tt.new, got, tt.want)
+		}
+	}
+}
+
 func TestFlattenAllocations(t *testing.T) {
 	input := map[string]string{
 		"vpc":     "10.0.0.0/16",
@@ -216,6 +772,302 @@ func TestFlattenAllocations_Empty(t *testing.T) {
 	}
 }
 
+func TestGroupAllocationsByRegion(t *testing.T) {
+	requests := []cidr.AllocationRequest{
+		{Name: "nyc_vpc", PrefixLength: 20, Region: "nyc1"},
+		{Name: "nyc_cluster", PrefixLength: 20, Region: "nyc1"},
+		{Name: "sfo_vpc", PrefixLength: 20, Region: "sfo3"},
+		{Name: "shared", PrefixLength: 24},
+	}
+	results := map[string]string{
+		"nyc_vpc":     "10.0.0.0/20",
+		"nyc_cluster": "10.0.16.0/20",
+		"sfo_vpc":     "10.0.32.0/20",
+		"shared":      "10.0.48.0/24",
+	}
+
+	got := groupAllocationsByRegion(results, requests)
+	want := map[string]string{
+		"nyc1":   "10.0.0.0/20",
+		"sfo3":   "10.0.32.0/20",
+		"global": "10.0.48.0/24",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("groupAllocationsByRegion() = %v, want %v", got, want)
+	}
+	for region, cidrBlock := range want {
+		if got[region] != cidrBlock {
+			t.Errorf("groupAllocationsByRegion()[%s] = %s, want %s", region, got[region], cidrBlock)
+		}
+	}
+
+	// The first allocation in configuration order wins, whatever its CIDR
+	reordered := []cidr.AllocationRequest{requests[1], requests[0], requests[2], requests[3]}
+	if got := groupAllocationsByRegion(results, reordered)["nyc1"]; got != "10.0.16.0/20" {
+		t.Errorf("groupAllocationsByRegion()[nyc1] with nyc_cluster first = %s, want 10.0.16.0/20", got)
+	}
+
+	// A request without a result doesn't claim its region
+	partial := map[string]string{"nyc_cluster": "10.0.16.0/20"}
+	if got := groupAllocationsByRegion(partial, requests); len(got) != 1 || got["nyc1"] != "10.0.16.0/20" {
+		t.Errorf("groupAllocationsByRegion() without nyc_vpc = %v, want nyc1 = 10.0.16.0/20 only", got)
+	}
+}
+
+func TestTagRecommendations(t *testing.T) {
+	requests := []cidr.AllocationRequest{
+		{Name: "vpc", PrefixLength: 16},
+		{Name: "k8s", PrefixLength: 20},
+	}
+
+	got := tagRecommendations(requests, []string{"env:prod", "team-network"})
+	want := map[string]string{
+		"vpc": "env:prod,team-network",
+		"k8s": "env:prod,team-network",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("tagRecommendations() = %v, want %v", got, want)
+	}
+	for name, tags := range want {
+		if got[name] != tags {
+			t.Errorf("tagRecommendations()[%s] = %q, want %q", name, got[name], tags)
+		}
+	}
+
+	if got := tagRecommendations(requests, nil); len(got) != 0 {
+		t.Errorf("tagRecommendations() without tags = %v, want empty", got)
+	}
+}
+
+func TestAddressSpaceSchema(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     map[string]interface{}
+		wantErr bool
+	}{
+		{name: "preset", raw: map[string]interface{}{"address_space": "rfc1918_192"}},
+		{name: "unknown preset", raw: map[string]interface{}{"address_space": "rfc6598"}, wantErr: true},
+		{name: "with base_cidr", raw: map[string]interface{}{"address_space": "rfc1918_10", "base_cidr": "10.0.0.0/8"}, wantErr: true},
+		{name: "with detect_base_cidr_from_region", raw: map[string]interface{}{"address_space": "rfc1918_10", "region": "nyc1", "detect_base_cidr_from_region": true}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw := map[string]interface{}{
+				"allocation": []interface{}{
+					map[string]interface{}{"name": "vpc", "prefix_length": 16},
+				},
+			}
+			for k, v := range tt.raw {
+				raw[k] = v
+			}
+
+			diags := ResourceDocidrPool().Validate(terraform.NewResourceConfigRaw(raw))
+			if diags.HasError() != tt.wantErr {
+				t.Errorf("Validate() diags = %v, wantErr %v", diags, tt.wantErr)
+			}
+		})
+	}
+
+	for space, base := range addressSpaces {
+		if _, err := cidr.ParseCIDR(base); err != nil {
+			t.Errorf("address space %s has invalid base CIDR %q: %v", space, base, err)
+		}
+	}
+}
+
+func TestEquivalentAddressSpace(t *testing.T) {
+	tests := []struct {
+		old, new, baseCIDR string
+		want               bool
+	}{
+		{old: "", new: "rfc1918_172", baseCIDR: "172.16.0.0/12", want: true},
+		{old: "rfc1918_172", new: "", baseCIDR: "172.16.0.0/12", want: true},
+		{old: "", new: "rfc1918_172", baseCIDR: "10.0.0.0/8"},
+		{old: "rfc1918_172", new: "", baseCIDR: "172.16.0.0/16"},
+		{old: "rfc1918_10", new: "rfc1918_172", baseCIDR: "172.16.0.0/12"},
+		{old: "", new: "", baseCIDR: "10.0.0.0/8"},
+	}
+
+	for _, tt := range tests {
+		if got := equivalentAddressSpace(tt.old, tt.new, tt.baseCIDR); got != tt.want {
+			t.Errorf("equivalentAddressSpace(%q, %q, %q) = %v, want %v", tt.old, tt.new, tt.baseCIDR, got, tt.want)
+		}
+	}
+}
+
+func TestValidateAllocationVisibility(t *testing.T) {
+	requests := []cidr.AllocationRequest{
+		{Name: "vpc", PrefixLength: 24, Visibility: cidr.VisibilityInternal},
+		{Name: "lab", PrefixLength: 24},
+		{Name: "peering", PrefixLength: 24, Visibility: cidr.VisibilityExternal},
+	}
+
+	tests := []struct {
+		name    string
+		results map[string]string
+		wantErr string
+	}{
+		{
+			name:    "valid",
+			results: map[string]string{"vpc": "10.0.0.0/24", "peering": "192.168.0.0/24", "lab": "10.0.1.0/24"},
+		},
+		{
+			name:    "unset is internal",
+			results: map[string]string{"lab": "192.168.1.0/24"},
+			wantErr: "allocations don't meet their visibility: lab: internal allocation 192.168.1.0/24 overlaps 192.168.0.0/16, which is used by many home and lab networks",
+		},
+		{
+			name:    "invalid",
+			results: map[string]string{"vpc": "192.168.1.0/24", "peering": "100.64.0.0/24"},
+			wantErr: "allocations don't meet their visibility: vpc: internal allocation 192.168.1.0/24 overlaps 192.168.0.0/16, which is used by many home and lab networks; peering: external allocation 100.64.0.0/24 is not within an RFC 1918 range",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateAllocationVisibility(tt.results, requests)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validateAllocationVisibility() error = %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("validateAllocationVisibility() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidatePoolState(t *testing.T) {
+	tests := []struct {
+		name        string
+		baseCIDR    string
+		allocations map[string]interface{}
+		wantErr     string
+	}{
+		{
+			name:        "valid",
+			baseCIDR:    "10.0.0.0/16",
+			allocations: map[string]interface{}{"a": "10.0.0.0/24", "b": "10.0.1.0/24"},
+		},
+		{
+			name:     "empty",
+			baseCIDR: "10.0.0.0/16",
+		},
+		{
+			name:     "invalid base",
+			baseCIDR: "10.0.0.0/33",
+			wantErr:  "base_cidr",
+		},
+		{
+			name:        "invalid allocation",
+			baseCIDR:    "10.0.0.0/16",
+			allocations: map[string]interface{}{"a": "10.0.0.0"},
+			wantErr:     `allocation "a"`,
+		},
+		{
+			name:        "overlap",
+			baseCIDR:    "10.0.0.0/16",
+			allocations: map[string]interface{}{"b": "10.0.0.128/25", "a": "10.0.0.0/24", "c": "10.0.1.0/24"},
+			wantErr:     `allocation "a" (10.0.0.0/24) overlaps allocation "b" (10.0.0.128/25)`,
+		},
+		{
+			name:        "duplicate",
+			baseCIDR:    "10.0.0.0/16",
+			allocations: map[string]interface{}{"a": "10.0.0.0/24", "b": "10.0.0.0/24"},
+			wantErr:     "overlaps",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validatePoolState(tt.baseCIDR, tt.allocations)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validatePoolState() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validatePoolState() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAllocationsDigest(t *testing.T) {
+	allocations := map[string]string{
+		"vpc": "10.0.0.0/16",
+		"k8s": "10.1.0.0/20",
+	}
+
+	// sha256sum of "k8s=10.1.0.0/20\nvpc=10.0.0.0/16\n"
+	want := "a4696d0a96ac2dae5c82c4bb1e28e67e67a81cb0d6f2e9d3c71bf6cd62ccde8e"
+	if got := allocationsDigest(allocations); got != want {
+		t.Errorf("allocationsDigest() = %q, want %q", got, want)
+	}
+
+	changed := map[string]string{
+		"vpc": "10.0.0.0/16",
+		"k8s": "10.2.0.0/20",
+	}
+	if allocationsDigest(changed) == want {
+		t.Error("allocationsDigest() unchanged after a CIDR changed")
+	}
+
+	renamed := map[string]string{
+		"vpc":     "10.0.0.0/16",
+		"cluster": "10.1.0.0/20",
+	}
+	if allocationsDigest(renamed) == want {
+		t.Error("allocationsDigest() unchanged after an allocation was renamed")
+	}
+}
+
+func TestAutoTagAllocationsValidation(t *testing.T) {
+	validate := poolSchema()["auto_tag_allocations"].Elem.(*schema.Schema).ValidateFunc
+
+	for _, tag := range []string{"prod", "env:prod", "team_network-1"} {
+		if _, errs := validate(tag, "auto_tag_allocations.0"); len(errs) > 0 {
+			t.Errorf("tag %q rejected: %v", tag, errs)
+		}
+	}
+	for _, tag := range []string{"", "env prod", "a,b", strings.Repeat("a", 256)} {
+		if _, errs := validate(tag, "auto_tag_allocations.0"); len(errs) == 0 {
+			t.Errorf("tag %q accepted, want error", tag)
+		}
+	}
+}
+
+func TestExpandExcludePatterns(t *testing.T) {
+	result, err := expandExcludePatterns([]interface{}{"10.1.*.*", "172.16.x.0/24"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(result) != 2 {
+		t.Fatalf("expected 2 exclusions, got %d", len(result))
+	}
+	if result[0].String() != "10.1.0.0/16" {
+		t.Errorf("first exclusion = %s, want 10.1.0.0/16", result[0].String())
+	}
+	if result[1].String() != "172.16.0.0/16" {
+		t.Errorf("second exclusion = %s, want 172.16.0.0/16", result[1].String())
+	}
+}
+
+func TestValidateExcludePattern(t *testing.T) {
+	if _, errs := validateExcludePattern("10.1.*.*", "exclude_patterns.0"); len(errs) != 0 {
+		t.Errorf("unexpected errors for valid pattern: %v", errs)
+	}
+	_, errs := validateExcludePattern("10.*.1.0", "exclude_patterns.0")
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "exclude_patterns.0") {
+		t.Errorf("errors = %v, want one error naming the attribute", errs)
+	}
+}
+
 func TestPoolSchema(t *testing.T) {
 	s := poolSchema()
 
@@ -243,9 +1095,12 @@ func TestPoolSchema(t *testing.T) {
 		t.Error("allocation should be ForceNew")
 	}
 
-	// Verify base_cidr has correct default
-	if s["base_cidr"].Default != "10.0.0.0/8" {
-		t.Errorf("base_cidr default = %v, want 10.0.0.0/8", s["base_cidr"].Default)
+	// Verify base_cidr is computed and falls back to the correct default
+	if !s["base_cidr"].Computed {
+		t.Error("base_cidr should be Computed")
+	}
+	if defaultBaseCIDR != "10.0.0.0/8" {
+		t.Errorf("base_cidr default = %v, want 10.0.0.0/8", defaultBaseCIDR)
 	}
 
 	// Verify allocations is Computed
@@ -285,5 +1140,270 @@ func TestPoolSchemaTypes(t *testing.T) {
 	}
 }
 
-// Suppress unused import errors
-var _ = cidr.AllocationRequest{}
+func TestPool_GenerateResourceID_Determinism(t *testing.T) {
+	type idInput struct {
+		baseCIDR    string
+		allocations []cidr.AllocationRequest
+		exclusions  []string
+		fileHash    string
+	}
+	id := func(in idInput) string {
+		var exclusions []interface{}
+		for _, e := range in.exclusions {
+			exclusions = append(exclusions, map[string]interface{}{"cidr": e, "reason": ""})
+		}
+		return generateResourceID(in.baseCIDR, in.allocations, exclusions, in.fileHash)
+	}
+
+	vpc := cidr.AllocationRequest{Name: "vpc", PrefixLength: 16}
+	k8s := cidr.AllocationRequest{Name: "k8s", PrefixLength: 20}
+	lb := cidr.AllocationRequest{Name: "lb", PrefixLength: 24}
+	base := idInput{
+		baseCIDR:    "10.0.0.0/8",
+		allocations: []cidr.AllocationRequest{vpc, k8s, lb},
+		exclusions:  []string{"10.1.0.0/16", "10.2.0.0/16", "10.3.0.0/16"},
+	}
+	with := func(change func(*idInput)) idInput {
+		in := base
+		in.allocations = append([]cidr.AllocationRequest{}, base.allocations...)
+		in.exclusions = append([]string{}, base.exclusions...)
+		change(&in)
+		return in
+	}
+
+	tests := []struct {
+		name     string
+		input    idInput
+		wantSame bool
+	}{
+		// (1) Same inputs produce the same ID
+		{name: "identical inputs", input: with(func(in *idInput) {}), wantSame: true},
+		{name: "identical copy of allocations", input: with(func(in *idInput) {
+			in.allocations = []cidr.AllocationRequest{{Name: "vpc", PrefixLength: 16}, {Name: "k8s", PrefixLength: 20}, {Name: "lb", PrefixLength: 24}}
+		}), wantSame: true},
+
+		// (2) A different base_cidr produces a different ID
+		{name: "different base", input: with(func(in *idInput) { in.baseCIDR = "172.16.0.0/12" })},
+		{name: "different base prefix", input: with(func(in *idInput) { in.baseCIDR = "10.0.0.0/9" })},
+		{name: "empty base", input: with(func(in *idInput) { in.baseCIDR = "" })},
+
+		// (3) A different allocation name produces a different ID
+		{name: "renamed allocation", input: with(func(in *idInput) { in.allocations[0].Name = "main_vpc" })},
+		{name: "renamed last allocation", input: with(func(in *idInput) { in.allocations[2].Name = "lb2" })},
+		{name: "names swapped between sizes", input: with(func(in *idInput) {
+			in.allocations[0].Name, in.allocations[1].Name = in.allocations[1].Name, in.allocations[0].Name
+		})},
+		{name: "allocation removed", input: with(func(in *idInput) { in.allocations = in.allocations[:2] })},
+		{name: "allocation added", input: with(func(in *idInput) {
+			in.allocations = append(in.allocations, cidr.AllocationRequest{Name: "db", PrefixLength: 24})
+		})},
+
+		// (4) Reordered allocations produce the same ID
+		{name: "allocations reversed", input: with(func(in *idInput) {
+			in.allocations = []cidr.AllocationRequest{lb, k8s, vpc}
+		}), wantSame: true},
+		{name: "allocations rotated", input: with(func(in *idInput) {
+			in.allocations = []cidr.AllocationRequest{k8s, lb, vpc}
+		}), wantSame: true},
+
+		// (5) Reordered exclusions produce the same ID
+		{name: "exclusions reversed", input: with(func(in *idInput) {
+			in.exclusions = []string{"10.3.0.0/16", "10.2.0.0/16", "10.1.0.0/16"}
+		}), wantSame: true},
+		{name: "exclusions rotated", input: with(func(in *idInput) {
+			in.exclusions = []string{"10.2.0.0/16", "10.3.0.0/16", "10.1.0.0/16"}
+		}), wantSame: true},
+		{name: "allocations and exclusions reordered", input: with(func(in *idInput) {
+			in.allocations = []cidr.AllocationRequest{k8s, vpc, lb}
+			in.exclusions = []string{"10.3.0.0/16", "10.1.0.0/16", "10.2.0.0/16"}
+		}), wantSame: true},
+		{name: "exclusion removed", input: with(func(in *idInput) { in.exclusions = in.exclusions[:2] })},
+		{name: "exclusion changed", input: with(func(in *idInput) { in.exclusions[1] = "10.4.0.0/16" })},
+
+		// (6) A different prefix length produces a different ID
+		{name: "larger prefix length", input: with(func(in *idInput) { in.allocations[0].PrefixLength = 17 })},
+		{name: "smaller prefix length", input: with(func(in *idInput) { in.allocations[2].PrefixLength = 20 })},
+		{name: "every prefix length changed", input: with(func(in *idInput) {
+			for i := range in.allocations {
+				in.allocations[i].PrefixLength++
+			}
+		})},
+	}
+
+	want := id(base)
+	for i := 0; i < 3; i++ {
+		if got := id(base); got != want {
+			t.Fatalf("generateResourceID() = %s on call %d, want %s", got, i+2, want)
+		}
+	}
+	if len(want) != 16 {
+		t.Errorf("generateResourceID() = %q, want 16 hex characters", want)
+	}
+
+	seen := map[string]string{want: "base"}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := id(tt.input)
+			if (got == want) != tt.wantSame {
+				t.Errorf("generateResourceID() = %s, base ID %s, want same = %v", got, want, tt.wantSame)
+			}
+			if !tt.wantSame {
+				if other, ok := seen[got]; ok {
+					t.Errorf("generateResourceID() = %s, the same ID as %q", got, other)
+				}
+				seen[got] = tt.name
+			}
+		})
+	}
+}
+
+func TestFlattenUsableHosts(t *testing.T) {
+	allocations := map[string]interface{}{
+		"vpc":       "10.0.0.0/16",
+		"subnet":    "10.1.2.0/24",
+		"tiny":      "10.1.3.0/30",
+		"p2p":       "10.1.3.4/31",
+		"loopback":  "10.1.3.6/32",
+		"corrupted": "not-a-cidr",
+	}
+
+	first, last := flattenUsableHosts(allocations)
+
+	wantFirst := map[string]interface{}{
+		"vpc":      "10.0.0.1",
+		"subnet":   "10.1.2.1",
+		"tiny":     "10.1.3.1",
+		"p2p":      "10.1.3.4",
+		"loopback": "10.1.3.6",
+	}
+	wantLast := map[string]interface{}{
+		"vpc":      "10.0.255.254",
+		"subnet":   "10.1.2.254",
+		"tiny":     "10.1.3.2",
+		"p2p":      "10.1.3.5",
+		"loopback": "10.1.3.6",
+	}
+	if !reflect.DeepEqual(first, wantFirst) {
+		t.Errorf("flattenUsableHosts() first = %v, want %v", first, wantFirst)
+	}
+	if !reflect.DeepEqual(last, wantLast) {
+		t.Errorf("flattenUsableHosts() last = %v, want %v", last, wantLast)
+	}
+
+	first, last = flattenUsableHosts(map[string]interface{}{})
+	if len(first) != 0 || len(last) != 0 {
+		t.Errorf("flattenUsableHosts(empty) = %v, %v, want empty maps", first, last)
+	}
+}
+
+func TestFlattenReverseZones(t *testing.T) {
+	got := flattenReverseZones(map[string]interface{}{
+		"vpc":       "10.0.0.0/16",
+		"k8s":       "10.1.0.0/23",
+		"db":        "10.1.2.16/28",
+		"ula":       "fd00:0:0:10::/64",
+		"corrupted": "not-a-cidr",
+	})
+
+	want := map[string]interface{}{
+		"vpc": "0.10.in-addr.arpa",
+		"k8s": "0.1.10.in-addr.arpa,1.1.10.in-addr.arpa",
+		"db":  "2.1.10.in-addr.arpa",
+		"ula": "0.1.0.0.0.0.0.0.0.0.0.0.0.0.d.f.ip6.arpa",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("flattenReverseZones() = %v, want %v", got, want)
+	}
+}
+
+func TestAllocationHashID(t *testing.T) {
+	if got := allocationHashID("pool-abc", "vpc"); got != "cc4f8b61" {
+		t.Errorf("allocationHashID() = %s, want cc4f8b61", got)
+	}
+
+	// Any change to the pool ID or the name gives another ID, and the
+	// separator keeps their boundary
+	seen := map[string]string{}
+	for _, parts := range [][2]string{{"pool-abc", "vpc"}, {"pool-abc", "k8s"}, {"pool-abd", "vpc"}, {"pool", "-abc:vpc"}, {"pool-abc:", "vpc"}} {
+		id := allocationHashID(parts[0], parts[1])
+		if len(id) != allocationHashIDLength {
+			t.Errorf("allocationHashID(%q, %q) = %s, want %d characters", parts[0], parts[1], id, allocationHashIDLength)
+		}
+		key := parts[0] + "|" + parts[1]
+		if other, ok := seen[id]; ok {
+			t.Errorf("allocationHashID() of %s and %s are both %s", other, key, id)
+		}
+		seen[id] = key
+	}
+
+	got := flattenAllocationHashIDs("pool-abc", map[string]interface{}{"vpc": "10.0.0.0/16", "k8s": "10.1.0.0/20"})
+	want := map[string]interface{}{"vpc": "cc4f8b61", "k8s": allocationHashID("pool-abc", "k8s")}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("flattenAllocationHashIDs() = %v, want %v", got, want)
+	}
+}
+
+func TestGroupAllocations(t *testing.T) {
+	results := map[string]string{
+		"prod_vpc":  "10.0.0.0/16",
+		"prod_k8s":  "10.1.0.0/20",
+		"stage_vpc": "10.2.0.0/16",
+		"shared":    "10.3.0.0/24",
+		"tools":     "10.3.1.0/24",
+	}
+	requests := []cidr.AllocationRequest{
+		{Name: "prod_vpc", Group: "production"},
+		{Name: "stage_vpc", Group: "staging"},
+		{Name: "shared"},
+		{Name: "prod_k8s", Group: "production"},
+		{Name: "tools"},
+		{Name: "unallocated", Group: "staging"},
+		{Name: "missing", Group: "empty"},
+	}
+
+	got := GroupAllocations(results, requests)
+	want := map[string][]string{
+		"production": {"10.0.0.0/16", "10.1.0.0/20"},
+		"staging":    {"10.2.0.0/16"},
+		"default":    {"10.3.0.0/24", "10.3.1.0/24"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GroupAllocations() = %v, want %v", got, want)
+	}
+
+	if got := GroupAllocations(nil, nil); len(got) != 0 {
+		t.Errorf("GroupAllocations(nil, nil) = %v, want empty", got)
+	}
+
+	wantFlat := map[string]interface{}{
+		"production": "10.0.0.0/16,10.1.0.0/20",
+		"staging":    "10.2.0.0/16",
+		"default":    "10.3.0.0/24,10.3.1.0/24",
+	}
+	if got := flattenGroups(want); !reflect.DeepEqual(got, wantFlat) {
+		t.Errorf("flattenGroups() = %v, want %v", got, wantFlat)
+	}
+}
+
+func TestSummarizeGroups(t *testing.T) {
+	got, err := summarizeGroups(map[string][]string{
+		"production": {"10.0.0.0/16", "10.1.0.0/20"},
+		"staging":    {"10.2.0.0/16"},
+		"default":    {"10.3.0.0/24", "10.3.1.0/24"},
+	})
+	if err != nil {
+		t.Fatalf("summarizeGroups() error = %v", err)
+	}
+	want := map[string]interface{}{
+		"production": "10.0.0.0/15",
+		"staging":    "10.2.0.0/16",
+		"default":    "10.3.0.0/23",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("summarizeGroups() = %v, want %v", got, want)
+	}
+
+	if _, err := summarizeGroups(map[string][]string{"bad": {"not-a-cidr"}}); err == nil {
+		t.Error("summarizeGroups() with an invalid CIDR error = nil, want error")
+	}
+}
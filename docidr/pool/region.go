@@ -0,0 +1,55 @@
+package pool
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/DO-Solutions/terraform-provider-docidr/docidr/cidr"
+	"github.com/digitalocean/godo"
+)
+
+// detectRegionBaseCIDR inspects the VPCs in the given region and returns the
+// RFC 1918 range they belong to. It returns an empty string if the region has
+// no VPCs in private address space, and an error if VPCs in the region span
+// more than one RFC 1918 range.
+func detectRegionBaseCIDR(ctx context.Context, client *godo.Client, region string, pageSize int) (string, error) {
+	vpcs, _, err := collectVPCCIDRs(ctx, client, pageSize)
+	if err != nil {
+		return "", fmt.Errorf("error collecting VPC CIDRs: %w", err)
+	}
+
+	counts := make(map[string]int)
+	for _, vpc := range vpcs {
+		if vpc.Region != region {
+			continue
+		}
+
+		block, ok := cidr.RFC1918Block(vpc.Network)
+		if !ok {
+			log.Printf("[DEBUG] Ignoring non-RFC1918 VPC %s (%s) in region %s", vpc.ResourceName, vpc.Network.String(), region)
+			continue
+		}
+		counts[block.String()]++
+	}
+
+	switch len(counts) {
+	case 0:
+		return "", nil
+	case 1:
+		for block := range counts {
+			log.Printf("[DEBUG] Detected base CIDR %s from %d VPCs in region %s", block, counts[block], region)
+			return block, nil
+		}
+	}
+
+	classes := make([]string, 0, len(counts))
+	for block, count := range counts {
+		classes = append(classes, fmt.Sprintf("%s (%d VPCs)", block, count))
+	}
+	sort.Strings(classes)
+	return "", fmt.Errorf("VPCs in region %s use multiple private address ranges: %s; set base_cidr explicitly",
+		region, strings.Join(classes, ", "))
+}
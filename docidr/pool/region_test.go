@@ -0,0 +1,47 @@
+package pool
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestDetectRegionBaseCIDR(t *testing.T) {
+	vpcs := []interface{}{
+		map[string]interface{}{"id": "vpc-1", "name": "a", "ip_range": "10.10.0.0/16", "region": "nyc1"},
+		map[string]interface{}{"id": "vpc-2", "name": "b", "ip_range": "10.20.0.0/16", "region": "nyc1"},
+		map[string]interface{}{"id": "vpc-3", "name": "c", "ip_range": "172.16.0.0/16", "region": "sfo3"},
+		map[string]interface{}{"id": "vpc-4", "name": "d", "ip_range": "192.168.0.0/20", "region": "sfo3"},
+		map[string]interface{}{"id": "vpc-5", "name": "e", "ip_range": "100.64.0.0/16", "region": "ams3"},
+	}
+	client := newFakeGodoClient(t, newFakeAccountMux(vpcs, nil))
+
+	tests := []struct {
+		region  string
+		want    string
+		wantErr string
+	}{
+		{region: "nyc1", want: "10.0.0.0/8"},
+		{region: "sfo3", wantErr: "multiple private address ranges"},
+		{region: "ams3", want: ""},
+		{region: "lon1", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.region, func(t *testing.T) {
+			got, err := detectRegionBaseCIDR(context.Background(), client, tt.region, 0)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("detectRegionBaseCIDR() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("detectRegionBaseCIDR() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("detectRegionBaseCIDR() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
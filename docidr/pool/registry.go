@@ -0,0 +1,85 @@
+package pool
+
+import (
+	"log"
+	"net"
+
+	"github.com/DO-Solutions/terraform-provider-docidr/docidr/cidr"
+	"github.com/DO-Solutions/terraform-provider-docidr/docidr/config"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// registerPool records the pool's base CIDR and exclusions with the provider so
+// that two pools in the same configuration can't be planned over the same
+// address space. Pools whose base CIDR or exclusions aren't known until apply
+// are not registered, nor are pools with exclude_self_managed_ranges set.
+func registerPool(diff *schema.ResourceDiff, meta *config.CombinedConfig, fileExclusions []*net.IPNet, exclusionsFileHash string) error {
+	if !diff.NewValueKnown("base_cidr") || !diff.NewValueKnown("exclude") || !diff.NewValueKnown("exclude_patterns") {
+		log.Printf("[DEBUG] Skipping pool conflict detection: base_cidr, exclude or exclude_patterns is unknown")
+		return nil
+	}
+
+	// Pools that list the ranges of other pools to avoid aren't checked
+	// against their base CIDRs
+	if !diff.NewValueKnown("exclude_self_managed_ranges") || len(diff.Get("exclude_self_managed_ranges").([]interface{})) > 0 {
+		log.Printf("[DEBUG] Skipping pool conflict detection: exclude_self_managed_ranges is set")
+		return nil
+	}
+
+	// A pool being replaced is planned again without its state, and the
+	// replacement is registered then. Changes made by CustomizeDiff, such as
+	// to state_valid or allocation_prefix_lengths, aren't among the changed
+	// keys, and neither is replacing a pool with plan_only set.
+	// A pool whose base_cidr is grown in place keeps its allocations, so it is
+	// registered with the new base.
+	if diff.Id() != "" && !growsBaseCIDRInPlace(diff) && (len(replacingChanges(diff)) > 0 || diff.HasChange("state_valid") || diff.HasChange("allocation_prefix_lengths") || replacesPlanOnlyPool(diff)) {
+		return nil
+	}
+
+	baseCIDR := diff.Get("base_cidr").(string)
+	if baseCIDR == "" {
+		return nil
+	}
+	base, err := cidr.ParseCIDR(baseCIDR)
+	if err != nil {
+		return err
+	}
+
+	// Invalid entries are rejected by schema validation, so a parse error here
+	// means an exclusion isn't known until apply.
+	userExclusions, err := expandExclusions(diff.Get("exclude").([]interface{}))
+	if err != nil {
+		log.Printf("[DEBUG] Skipping pool conflict detection: %v", err)
+		return nil
+	}
+	exclusions := exclusionNetworks(userExclusions)
+	patternExclusions, err := expandExcludePatterns(diff.Get("exclude_patterns").([]interface{}))
+	if err != nil {
+		log.Printf("[DEBUG] Skipping pool conflict detection: %v", err)
+		return nil
+	}
+	exclusions = append(exclusions, patternExclusions...)
+	exclusions = append(exclusions, fileExclusions...)
+	exclusions = append(exclusions, meta.EnvExclusions()...)
+
+	// Pools are identified by the ID generated from their configuration,
+	// which every plan of a pool shares. Their idempotent_id can't be used,
+	// as copies of a pool may share it; RegisterPool rejects those instead.
+	blocks, err := resolveAllocationSizes(configAllocationBlocks(diff), meta.SizePresets(), baseCIDR)
+	if err != nil {
+		return err
+	}
+	allocations := expandAllocations(blocks, diff.Get("auto_generate_names").(bool))
+	key := generateResourceID(baseCIDR, allocations, diff.Get("exclude").([]interface{}), exclusionsFileHash)
+	if diff.Id() == "" {
+		key = "new:" + key
+	}
+
+	return meta.RegisterPool(config.PoolRegistration{
+		Key:                     key,
+		IdempotentID:            diff.Get("idempotent_id").(string),
+		BaseCIDR:                base,
+		Exclusions:              exclusions,
+		ExcludeOverlappingPools: diff.Get("exclude_overlapping_pools").(bool),
+	})
+}
@@ -0,0 +1,314 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DO-Solutions/terraform-provider-docidr/docidr/config"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+func TestRegisterPool_CrossPoolConflict(t *testing.T) {
+	vpc := []interface{}{
+		map[string]interface{}{"name": "vpc", "prefix_length": 16},
+	}
+	cluster := []interface{}{
+		map[string]interface{}{"name": "cluster", "prefix_length": 20},
+	}
+
+	tests := []struct {
+		name    string
+		first   map[string]interface{}
+		second  map[string]interface{}
+		wantErr bool
+	}{
+		{
+			name:    "same base CIDR",
+			first:   map[string]interface{}{"allocation": vpc},
+			second:  map[string]interface{}{"allocation": cluster},
+			wantErr: true,
+		},
+		{
+			name:    "nested base CIDR",
+			first:   map[string]interface{}{"allocation": vpc, "base_cidr": "10.0.0.0/8"},
+			second:  map[string]interface{}{"allocation": cluster, "base_cidr": "10.20.0.0/16"},
+			wantErr: true,
+		},
+		{
+			name:   "disjoint base CIDRs",
+			first:  map[string]interface{}{"allocation": vpc, "base_cidr": "10.0.0.0/9"},
+			second: map[string]interface{}{"allocation": cluster, "base_cidr": "10.128.0.0/9"},
+		},
+		{
+			name: "nested base CIDR excluded by the larger pool",
+			first: map[string]interface{}{
+				"allocation": vpc,
+				"base_cidr":  "10.0.0.0/8",
+				"exclude": []interface{}{
+					map[string]interface{}{"cidr": "10.20.0.0/16"},
+				},
+			},
+			second: map[string]interface{}{"allocation": cluster, "base_cidr": "10.20.0.0/16"},
+		},
+		{
+			name:  "overlap only partially excluded",
+			first: map[string]interface{}{"allocation": vpc, "base_cidr": "10.0.0.0/8"},
+			second: map[string]interface{}{
+				"allocation": cluster,
+				"base_cidr":  "10.20.0.0/16",
+				"exclude": []interface{}{
+					map[string]interface{}{"cidr": "10.20.0.0/17"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "shared exclude splits the base CIDR",
+			first: map[string]interface{}{
+				"allocation": vpc,
+				"exclude": []interface{}{
+					map[string]interface{}{"cidr": "10.128.0.0/9"},
+				},
+			},
+			second: map[string]interface{}{
+				"allocation": cluster,
+				"exclude": []interface{}{
+					map[string]interface{}{"cidr": "10.0.0.0/9"},
+				},
+			},
+		},
+		{
+			name:   "different address space",
+			first:  map[string]interface{}{"allocation": vpc},
+			second: map[string]interface{}{"allocation": cluster, "base_cidr": "172.16.0.0/12"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			meta := newTestCombinedConfig(t, &config.Config{})
+
+			if _, err := planPool(t, tt.first, meta); err != nil {
+				t.Fatalf("first pool Diff() error = %v", err)
+			}
+
+			_, err := planPool(t, tt.second, meta)
+			if !tt.wantErr {
+				if err != nil {
+					t.Fatalf("second pool Diff() error = %v", err)
+				}
+				return
+			}
+
+			var conflictErr *config.PoolConflictError
+			if !errors.As(err, &conflictErr) {
+				t.Fatalf("second pool Diff() error = %v, want *config.PoolConflictError", err)
+			}
+		})
+	}
+}
+
+func TestRegisterPool_ReplanSamePool(t *testing.T) {
+	meta := newTestCombinedConfig(t, &config.Config{})
+	raw := map[string]interface{}{
+		"allocation": []interface{}{
+			map[string]interface{}{"name": "vpc", "prefix_length": 16},
+		},
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := planPool(t, raw, meta); err != nil {
+			t.Fatalf("Diff() #%d error = %v", i+1, err)
+		}
+	}
+}
+
+func TestRegisterPool_DuplicateIdempotentID(t *testing.T) {
+	meta := newTestCombinedConfig(t, &config.Config{})
+	pool := func(name, baseCIDR string) map[string]interface{} {
+		return map[string]interface{}{
+			"base_cidr":     baseCIDR,
+			"idempotent_id": "network",
+			"allocation": []interface{}{
+				map[string]interface{}{"name": name, "prefix_length": 16},
+			},
+		}
+	}
+
+	// Replanning a pool keeps its idempotent_id
+	for i := 0; i < 2; i++ {
+		if _, err := planPool(t, pool("vpc", "10.0.0.0/9"), meta); err != nil {
+			t.Fatalf("Diff() #%d error = %v", i+1, err)
+		}
+	}
+
+	// A copy with the same idempotent_id is rejected, even in disjoint space
+	_, err := planPool(t, pool("cluster", "10.128.0.0/9"), meta)
+	if err == nil || !strings.Contains(err.Error(), `idempotent_id "network" is also used by another docidr_pool`) {
+		t.Errorf("copy Diff() error = %v, want the idempotent_id reuse reported", err)
+	}
+
+	// Overlap is still checked between pools with different idempotent_ids
+	other := pool("cluster", "10.0.0.0/8")
+	other["idempotent_id"] = "other"
+	var conflictErr *config.PoolConflictError
+	if _, err := planPool(t, other, meta); !errors.As(err, &conflictErr) {
+		t.Errorf("overlapping pool Diff() error = %v, want *config.PoolConflictError", err)
+	}
+}
+
+func TestRegisterPool_UnknownBaseCIDR(t *testing.T) {
+	meta := newTestCombinedConfig(t, &config.Config{})
+
+	detected := map[string]interface{}{
+		"allocation": []interface{}{
+			map[string]interface{}{"name": "vpc", "prefix_length": 16},
+		},
+		"region":                       "nyc1",
+		"detect_base_cidr_from_region": true,
+	}
+	if _, err := planPool(t, detected, meta); err != nil {
+		t.Fatalf("detected pool Diff() error = %v", err)
+	}
+
+	other := map[string]interface{}{
+		"allocation": []interface{}{
+			map[string]interface{}{"name": "cluster", "prefix_length": 20},
+		},
+	}
+	if _, err := planPool(t, other, meta); err != nil {
+		t.Errorf("Diff() error = %v, pools with base_cidr unknown until apply should not conflict", err)
+	}
+}
+
+func TestExcludeOverlappingPools(t *testing.T) {
+	srv := httptest.NewServer(newFakeAccountMux([]interface{}{}, []interface{}{}))
+	t.Cleanup(srv.Close)
+	newMeta := func() *config.CombinedConfig {
+		return newTestCombinedConfig(t, &config.Config{APIEndpoint: srv.URL + "/"})
+	}
+
+	pool := func(name string) map[string]interface{} {
+		return map[string]interface{}{
+			"base_cidr":                 "10.0.0.0/16",
+			"exclude_overlapping_pools": true,
+			"allocation": []interface{}{
+				map[string]interface{}{"name": name, "prefix_length": 24},
+			},
+		}
+	}
+
+	// Both pools are created in the same apply
+	meta := newMeta()
+	first := applyPool(t, nil, pool("a"), meta)
+	second := applyPool(t, nil, pool("b"), meta)
+	if got := first.Attributes["allocations.a"]; got != "10.0.0.0/24" {
+		t.Errorf("first pool allocation = %s, want 10.0.0.0/24", got)
+	}
+	if got := second.Attributes["allocations.b"]; got != "10.0.1.0/24" {
+		t.Errorf("second pool allocation = %s, want 10.0.1.0/24", got)
+	}
+
+	// Adjacent allocations of other pools are merged
+	merged := applyPool(t, nil, pool("e"), meta)
+	if got := merged.Attributes["overlapping_pool_cidrs.#"]; got != "1" {
+		t.Errorf("overlapping_pool_cidrs.# = %s, want 1", got)
+	}
+	if got := merged.Attributes["overlapping_pool_cidrs.0"]; got != "10.0.0.0/23" {
+		t.Errorf("overlapping_pool_cidrs.0 = %s, want 10.0.0.0/23", got)
+	}
+	if got := merged.Attributes["allocations.e"]; got != "10.0.2.0/24" {
+		t.Errorf("merged pool allocation = %s, want 10.0.2.0/24", got)
+	}
+
+	// A pool added later sees the first pool's state during plan, and keeps
+	// avoiding it at apply time when the first pool isn't planned again
+	planMeta := newMeta()
+	if _, err := ResourceDocidrPool().Diff(context.Background(), first, terraform.NewResourceConfigRaw(pool("a")), planMeta); err != nil {
+		t.Fatalf("first pool Diff() error = %v", err)
+	}
+	diff, err := planPool(t, pool("c"), planMeta)
+	if err != nil {
+		t.Fatalf("third pool Diff() error = %v", err)
+	}
+	if attr := diff.Attributes["overlapping_pool_cidrs.0"]; attr == nil || attr.New != "10.0.0.0/24" {
+		t.Errorf("overlapping_pool_cidrs.0 = %+v, want 10.0.0.0/24", attr)
+	}
+	third, diags := ResourceDocidrPool().Apply(context.Background(), nil, diff, newMeta())
+	if diags.HasError() {
+		t.Fatalf("third pool Apply() diags = %v", diags)
+	}
+	if got := third.Attributes["allocations.c"]; got != "10.0.1.0/24" {
+		t.Errorf("third pool allocation = %s, want 10.0.1.0/24", got)
+	}
+
+	// A pool without exclude_overlapping_pools may share address space with
+	// pools that set it, and avoids their allocations
+	unset := func(name string) map[string]interface{} {
+		raw := pool(name)
+		delete(raw, "exclude_overlapping_pools")
+		return raw
+	}
+	if got := applyPool(t, nil, unset("d"), meta).Attributes["allocations.d"]; got != "10.0.3.0/24" {
+		t.Errorf("pool without exclude_overlapping_pools allocation = %s, want 10.0.3.0/24", got)
+	}
+
+	// Pools that don't exclude each other still conflict
+	var conflictErr *config.PoolConflictError
+	if _, err := planPool(t, unset("f"), meta); !errors.As(err, &conflictErr) {
+		t.Errorf("Diff() error = %v, want *config.PoolConflictError", err)
+	}
+
+	// A pool setting exclude_overlapping_pools avoids a pool without it
+	// created first
+	meta = newMeta()
+	applyPool(t, nil, unset("g"), meta)
+	if got := applyPool(t, nil, pool("h"), meta).Attributes["allocations.h"]; got != "10.0.1.0/24" {
+		t.Errorf("pool created after one without exclude_overlapping_pools allocation = %s, want 10.0.1.0/24", got)
+	}
+}
+
+// applyPool plans and creates a docidr_pool with the given raw configuration.
+func applyPool(t *testing.T, state *terraform.InstanceState, raw map[string]interface{}, meta interface{}) *terraform.InstanceState {
+	t.Helper()
+
+	r := ResourceDocidrPool()
+	diff, err := r.Diff(context.Background(), state, terraform.NewResourceConfigRaw(raw), meta)
+	if err != nil {
+		t.Fatalf("Diff() error = %v", err)
+	}
+	newState, diags := r.Apply(context.Background(), state, diff, meta)
+	if diags.HasError() {
+		t.Fatalf("Apply() diags = %v", diags)
+	}
+	return newState
+}
+
+func TestExcludeSelfManagedRanges(t *testing.T) {
+	srv := httptest.NewServer(newFakeAccountMux([]interface{}{}, []interface{}{}))
+	t.Cleanup(srv.Close)
+	meta := newTestCombinedConfig(t, &config.Config{APIEndpoint: srv.URL + "/"})
+
+	first := applyPool(t, nil, map[string]interface{}{
+		"base_cidr": "10.0.0.0/16",
+		"allocation": []interface{}{
+			map[string]interface{}{"name": "a", "prefix_length": 24},
+		},
+	}, meta)
+
+	// The second pool shares the base and avoids the first pool's allocation
+	// without conflicting with it in the registry
+	second := applyPool(t, nil, map[string]interface{}{
+		"base_cidr":                   "10.0.0.0/16",
+		"exclude_self_managed_ranges": []interface{}{first.Attributes["allocations.a"]},
+		"allocation": []interface{}{
+			map[string]interface{}{"name": "b", "prefix_length": 24},
+		},
+	}, meta)
+	if got := second.Attributes["allocations.b"]; got != "10.0.1.0/24" {
+		t.Errorf("second pool allocation = %s, want 10.0.1.0/24", got)
+	}
+}
@@ -4,15 +4,16 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"log"
 	"net"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/DO-Solutions/terraform-provider-docidr/docidr/cidr"
 	"github.com/DO-Solutions/terraform-provider-docidr/docidr/config"
-	"github.com/digitalocean/godo"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 )
@@ -22,208 +23,1168 @@ func ResourceDocidrPool() *schema.Resource {
 	return &schema.Resource{
 		CreateContext: resourceDocidrPoolCreate,
 		ReadContext:   resourceDocidrPoolRead,
+		UpdateContext: resourceDocidrPoolUpdate,
 		DeleteContext: resourceDocidrPoolDelete,
 
-		// No UpdateContext - all fields are ForceNew
+		// Every field is ForceNew except base_cidr, migrate_to_base,
+		// inPlaceAttributes and inPlaceAllocationAttributes. CustomizeDiff
+		// replaces the pool for any base_cidr change other than growing it,
+		// and any migrate_to_base change other than finishing a migration
 
 		Schema: poolSchema(),
 
-		CustomizeDiff: func(ctx context.Context, diff *schema.ResourceDiff, meta interface{}) error {
-			// Validate unique allocation names
-			if allocations, ok := diff.GetOk("allocation"); ok {
-				if err := validateUniqueAllocationNames(allocations.([]interface{})); err != nil {
-					return err
-				}
-			}
-			return nil
+		SchemaVersion: 1,
+		StateUpgraders: []schema.StateUpgrader{
+			{
+				Version: 0,
+				Type:    resourceDocidrPoolV0().CoreConfigSchema().ImpliedType(),
+				Upgrade: upgradePoolStateV0,
+			},
 		},
 
+		CustomizeDiff: resourceDocidrPoolCustomizeDiff,
+
 		Description: "Allocates non-conflicting CIDR blocks for use with DigitalOcean VPCs and Kubernetes clusters.",
 	}
 }
 
-// resourceDocidrPoolCreate handles the creation of a docidr_pool resource.
-func resourceDocidrPoolCreate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
-	client := meta.(*config.CombinedConfig).GodoClient()
+// resourceDocidrPoolCustomizeDiff validates the configuration at plan time.
+// The steps run in order, as later ones depend on the changes earlier ones
+// make to the plan.
+func resourceDocidrPoolCustomizeDiff(ctx context.Context, diff *schema.ResourceDiff, meta interface{}) error {
+	// Resolve size presets and new_bits so that invalid sizes fail the plan,
+	// and the rest of the checks see every allocation's prefix length
+	allocationBlocks, err := resolveAllocationSizes(configAllocationBlocks(diff), metaSizePresets(meta), plannedBaseCIDR(diff))
+	if err != nil {
+		return withAllocationPath(err, nil)
+	}
 
-	baseCIDR := d.Get("base_cidr").(string)
-	allocationRequests := expandAllocations(d.Get("allocation").([]interface{}))
+	if err := validatePoolOptions(diff, meta); err != nil {
+		return err
+	}
+	if err := validateAllocationBlocks(diff, allocationBlocks); err != nil {
+		return err
+	}
+	if err := planAllocationPrefixLengths(diff, allocationBlocks); err != nil {
+		return err
+	}
+	if err := planAddressSpaceBaseCIDR(diff); err != nil {
+		return err
+	}
+
+	finishingMigration := finishesMigration(diff)
+	if err := customizeMigrationDiff(diff, finishingMigration); err != nil {
+		return err
+	}
+	if err := customizeBaseCIDRDiff(diff, finishingMigration); err != nil {
+		return err
+	}
+	if err := customizeLabelDiff(diff); err != nil {
+		return err
+	}
+	if err := checkPlannedBaseCIDR(diff, allocationBlocks); err != nil {
+		return err
+	}
 
-	// Collect user-specified exclusions
-	userExclusions, err := expandExclusions(d.Get("exclude").([]interface{}))
+	fileExclusions, exclusionsFileHash, err := customizeExclusionsFileDiff(diff)
 	if err != nil {
-		return diag.FromErr(err)
+		return err
+	}
+	if err := replaceInvalidPoolState(diff); err != nil {
+		return err
 	}
 
-	// Collect existing CIDRs from DigitalOcean account
-	existingCIDRs, err := collectExistingCIDRs(ctx, client)
+	// A replacement keeps the idempotent_id, so it must describe the same pool
+	if err := checkIdempotentIDReuse(diff); err != nil {
+		return err
+	}
+
+	if err := carryOverAllocations(diff, finishingMigration); err != nil {
+		return err
+	}
+	if err := forcePlanOnlyReplacement(diff); err != nil {
+		return err
+	}
+
+	if combined, ok := meta.(*config.CombinedConfig); ok {
+		return customizePoolRegistryDiff(ctx, diff, combined, fileExclusions, exclusionsFileHash)
+	}
+	return nil
+}
+
+// validatePoolOptions returns an error for pool arguments that can't work
+// together, or without the provider configuration they need.
+func validatePoolOptions(diff *schema.ResourceDiff, meta interface{}) error {
+	// Detecting the base CIDR needs the API that skip_api_query avoids
+	if diff.Get("skip_api_query").(bool) && diff.Get("detect_base_cidr_from_region").(bool) {
+		return errors.New("skip_api_query can't be used with detect_base_cidr_from_region, which queries the DigitalOcean API")
+	}
+
+	// Without a ranges document to fetch, include_app_platform_ranges would
+	// only fail at apply time
+	if combined, ok := meta.(*config.CombinedConfig); ok && diff.Get("include_app_platform_ranges").(bool) && combined.AppPlatformRangesURL() == "" {
+		return errMissingAppPlatformRangesURL
+	}
+	return nil
+}
+
+// validateAllocationBlocks validates the allocation blocks: their count,
+// names, hub and prefix lengths. allocationBlocks are the blocks with their
+// sizes resolved. Checks that need values unknown at plan time are skipped.
+func validateAllocationBlocks(diff *schema.ResourceDiff, allocationBlocks []interface{}) error {
+	allocations, ok := diff.GetOk("allocation")
+	if !ok {
+		return nil
+	}
+	count := len(allocations.([]interface{}))
+
+	// Catch runaway dynamic allocation lists before anything else
+	if diff.NewValueKnown("allocation_count_limit") {
+		if err := validateAllocationCount(count, diff.Get("allocation_count_limit").(int)); err != nil {
+			return err
+		}
+	}
+
+	// Names that aren't known yet read as empty, so only check for missing
+	// names once every name is known
+	if allocationNamesKnown(diff, count) {
+		if err := validateAllocationNamesSet(allocations.([]interface{}), diff.Get("auto_generate_names").(bool)); err != nil {
+			return withAllocationPath(err, nil)
+		}
+	}
+
+	if err := validateUniqueAllocationNames(allocations.([]interface{})); err != nil {
+		return withAllocationPath(err, nil)
+	}
+	if err := validateSingleHub(allocations.([]interface{})); err != nil {
+		return withAllocationPath(err, nil)
+	}
+
+	// Validate allocation names against the naming convention, if any
+	pattern := diff.Get("allocation_names_regex").(string)
+	if err := validateAllocationNamesRegex(pattern, allocations.([]interface{})); err != nil {
+		return withAllocationPath(err, nil)
+	}
+
+	if allocationSizesKnown(diff, count) {
+		if err := validateAllocationSizesSet(configAllocationBlocks(diff)); err != nil {
+			return withAllocationPath(err, nil)
+		}
+	}
+
+	// Validate prefix lengths against each allocation's allowed_prefix_range,
+	// or the pool's own bounds
+	if diff.NewValueKnown("min_prefix_length") && diff.NewValueKnown("max_prefix_length") {
+		if err := validatePrefixLengthBounds(allocationBlocks, diff.Get("min_prefix_length").(int), diff.Get("max_prefix_length").(int)); err != nil {
+			return withAllocationPath(err, nil)
+		}
+	}
+	return nil
+}
+
+// planAllocationPrefixLengths shows the resolved prefix lengths in the plan,
+// and replaces the pool when one changes. Changes to size and prefix_length
+// alone are suppressed, so switching to a renamed preset of the same size
+// doesn't replace the pool.
+func planAllocationPrefixLengths(diff *schema.ResourceDiff, allocationBlocks []interface{}) error {
+	allocations, ok := diff.GetOk("allocation")
+	if !ok {
+		return nil
+	}
+	count := len(allocations.([]interface{}))
+	requests := expandAllocations(allocationBlocks, diff.Get("auto_generate_names").(bool))
+	if !allocationSizesKnown(diff, count) || !allocationNamesKnown(diff, count) || !prefixLengthsResolved(requests) {
+		return nil
+	}
+
+	prefixLengths := flattenPrefixLengths(requests)
+	old := diff.Get("allocation_prefix_lengths").(map[string]interface{})
+	if diff.Id() == "" {
+		return diff.SetNew("allocation_prefix_lengths", prefixLengths)
+	}
+	if len(old) > 0 && !samePrefixLengths(old, prefixLengths) {
+		if err := diff.SetNew("allocation_prefix_lengths", prefixLengths); err != nil {
+			return err
+		}
+		return diff.ForceNew("allocation_prefix_lengths")
+	}
+	return nil
+}
+
+// planAddressSpaceBaseCIDR shows the base CIDR of an address_space preset in
+// the plan of a new pool, or the default base CIDR unless it will be detected
+// at apply time.
+func planAddressSpaceBaseCIDR(diff *schema.ResourceDiff) error {
+	if diff.Id() != "" || !diff.NewValueKnown("address_space") {
+		return nil
+	}
+	if space, ok := diff.GetOk("address_space"); ok {
+		return diff.SetNew("base_cidr", addressSpaces[space.(string)])
+	}
+	if _, ok := diff.GetOk("base_cidr"); !ok && !diff.Get("detect_base_cidr_from_region").(bool) {
+		return diff.SetNew("base_cidr", defaultBaseCIDR)
+	}
+	return nil
+}
+
+// customizeMigrationDiff replaces the pool for any change to migrate_to_base
+// but clearing it once base_cidr has been set to the base the pool migrated
+// to, which finishes the migration in place.
+func customizeMigrationDiff(diff *schema.ResourceDiff, finishingMigration bool) error {
+	if diff.Id() != "" && diff.HasChange("migrate_to_base") && !finishingMigration {
+		return diff.ForceNew("migrate_to_base")
+	}
+	return nil
+}
+
+// customizeBaseCIDRDiff updates the pool in place when base_cidr is grown,
+// which keeps every allocation within the base, so only later allocations can
+// use the new space. Any other change to it replaces the pool. Differences
+// suppressed by its DiffSuppressFunc aren't in the diff, but still read as a
+// change.
+func customizeBaseCIDRDiff(diff *schema.ResourceDiff, finishingMigration bool) error {
+	if diff.Id() == "" || !diff.HasChange("base_cidr") || len(diff.GetChangedKeysPrefix("base_cidr")) == 0 {
+		return nil
+	}
+
+	old, new := diff.GetChange("base_cidr")
+	if !diff.NewValueKnown("base_cidr") || !isGrownBaseCIDR(old.(string), new.(string)) {
+		return diff.ForceNew("base_cidr")
+	}
+	if growsBaseCIDRInPlace(diff) || finishingMigration {
+		for _, key := range baseCIDRDerivedAttributes {
+			if err := diff.SetNewComputed(key); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// customizeLabelDiff recomputes the attributes derived from the labels of
+// the allocations when a new group or hub relabels them.
+func customizeLabelDiff(diff *schema.ResourceDiff) error {
+	if diff.Id() == "" || !changesAllocationLabels(diff) {
+		return nil
+	}
+	for _, key := range labelDerivedAttributes {
+		if err := diff.SetNewComputed(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkPlannedBaseCIDR checks the allocations against a base CIDR known at
+// plan time: it logs oversized allocations and returns an error if the base
+// can't meet an allocation's visibility. CustomizeDiff can't return warnings,
+// so oversized allocations are only logged during plan and reported as
+// warnings when the pool is created.
+func checkPlannedBaseCIDR(diff *schema.ResourceDiff, allocationBlocks []interface{}) error {
+	baseCIDR, ok := diff.GetOk("base_cidr")
+	if !ok || !diff.NewValueKnown("base_cidr") {
+		return nil
+	}
+
+	requests := expandAllocations(allocationBlocks, diff.Get("auto_generate_names").(bool))
+	threshold := diff.Get("oversize_warning_threshold").(float64)
+	for _, warning := range oversizedAllocationWarnings(baseCIDR.(string), requests, threshold) {
+		log.Printf("[WARN] %s: %s", warning.Summary, warning.Detail)
+	}
+
+	return validateBaseCIDRVisibility(baseCIDR.(string), requests)
+}
+
+// customizeExclusionsFileDiff parses the exclusions file so errors surface
+// during plan, and replaces the pool when its contents change. It returns the
+// file's networks and hash, or nothing without an exclusions file.
+func customizeExclusionsFileDiff(diff *schema.ResourceDiff) ([]*net.IPNet, string, error) {
+	path, ok := diff.GetOk("exclusions_file")
+	if !ok {
+		return nil, "", nil
+	}
+
+	networks, hash, err := loadExclusionsFile(path.(string))
 	if err != nil {
-		return diag.Errorf("Error querying existing CIDRs from DigitalOcean: %s", err)
+		return nil, "", err
+	}
+	if diff.Get("exclusions_file_hash").(string) != hash {
+		if err := diff.SetNew("exclusions_file_hash", hash); err != nil {
+			return nil, "", err
+		}
+		if diff.Id() != "" {
+			if err := diff.ForceNew("exclusions_file_hash"); err != nil {
+				return nil, "", err
+			}
+		}
 	}
+	return networks, hash, nil
+}
 
-	log.Printf("[DEBUG] Found %d existing CIDRs in DigitalOcean account", len(existingCIDRs))
-	for _, cidr := range existingCIDRs {
-		log.Printf("[DEBUG]   - %s", cidr.String())
+// replaceInvalidPoolState replaces a pool whose stored allocations are
+// invalid. The state is checked here as well as in Read so that plans made
+// without a refresh also catch it.
+func replaceInvalidPoolState(diff *schema.ResourceDiff) error {
+	if diff.Id() == "" {
+		return nil
 	}
 
-	// Combine exclusions
-	allExclusions := append(existingCIDRs, userExclusions...)
+	baseCIDR, _ := diff.GetChange("base_cidr")
+	allocations, _ := diff.GetChange("allocations")
+	err := validatePoolState(baseCIDR.(string), allocations.(map[string]interface{}))
+	if err == nil {
+		return nil
+	}
+	log.Printf("[WARN] docidr_pool %s has invalid state and will be replaced: %s", diff.Id(), err)
 
-	// Create allocator and perform allocations
-	allocator, err := cidr.NewAllocator(baseCIDR)
+	// ForceNew needs a change, and state_valid is only false in state once
+	// Read has seen the invalid allocations
+	if diff.Get("state_valid").(bool) {
+		err = diff.SetNewComputed("state_valid")
+	} else {
+		err = diff.SetNew("state_valid", true)
+	}
 	if err != nil {
-		return diag.Errorf("Error creating CIDR allocator: %s", err)
+		return err
+	}
+	return diff.ForceNew("state_valid")
+}
+
+// carryOverAllocations records the allocations of a pool that is being
+// replaced in previous_allocations, so a stable pool's replacement can keep
+// them, and a migration moves from them. The allocations a migration moves
+// from are kept until the migration is finished, so nothing else that would
+// replace the pool can change in between.
+func carryOverAllocations(diff *schema.ResourceDiff, finishingMigration bool) error {
+	if diff.Id() != "" && diff.Get("stable_allocation").(bool) && (len(replacingChanges(diff)) > 0 || replacesPlanOnlyPool(diff)) {
+		if err := diff.SetNew("previous_allocations", diff.Get("allocations")); err != nil {
+			return err
+		}
+	}
+
+	if finishingMigration {
+		if err := diff.SetNew("previous_allocations", map[string]interface{}{}); err != nil {
+			return err
+		}
+		return diff.SetNew("migration_map", []interface{}{})
+	}
+	if diff.Id() != "" && diff.Get("migrate_to_base").(string) != "" && len(replacingChanges(diff)) > 0 {
+		if old, _ := diff.GetChange("migrate_to_base"); old.(string) != "" {
+			return fmt.Errorf("docidr_pool is migrating to %s; finish the migration by setting base_cidr to %s and removing migrate_to_base, or abandon it by removing migrate_to_base, before making other changes", old, old)
+		}
+		return diff.SetNew("previous_allocations", diff.Get("allocations"))
+	}
+	return nil
+}
+
+// forcePlanOnlyReplacement replaces a proposal on every apply until plan_only
+// is set to false, which replaces it with a pool that is kept. ForceNew needs
+// a change, so the allocations are made again, once carryOverAllocations has
+// carried them over.
+func forcePlanOnlyReplacement(diff *schema.ResourceDiff) error {
+	if !replacesPlanOnlyPool(diff) {
+		return nil
+	}
+	log.Printf("[INFO] docidr_pool %s has plan_only set and will be replaced", diff.Id())
+	if err := diff.SetNewComputed("allocations"); err != nil {
+		return err
+	}
+	return diff.ForceNew("allocations")
+}
+
+// customizePoolRegistryDiff catches other pools planned by this provider that
+// could allocate the same space, registers the pool with them, and shows the
+// allocations in the plan when the provider opts in. The previous allocations
+// of a stable pool aren't known while its replacement is planned, so it is
+// allocated at apply time. Allocating from an external IPAM system reserves
+// the blocks there, so it waits for apply too.
+func customizePoolRegistryDiff(ctx context.Context, diff *schema.ResourceDiff, combined *config.CombinedConfig, fileExclusions []*net.IPNet, exclusionsFileHash string) error {
+	if err := shareOverlappingPools(diff, combined); err != nil {
+		return err
 	}
+	if err := registerPool(diff, combined, fileExclusions, exclusionsFileHash); err != nil {
+		return err
+	}
+
+	if combined.ComputeAllocationsAtPlanTime() && diff.Id() == "" && !diff.Get("stable_allocation").(bool) && len(diff.Get("external_allocation_api").([]interface{})) == 0 {
+		return planAllocations(ctx, diff, combined)
+	}
+	return nil
+}
+
+// baseCIDRDerivedAttributes are the computed attributes that change when
+// base_cidr is grown in place.
+var baseCIDRDerivedAttributes = []string{"summary", "utilization_percent", "utilization_breakdown", "allocations_json", "allocations_cidrsubnet"}
+
+// labelDerivedAttributes are the computed attributes that change when one of
+// inPlaceAllocationAttributes is changed.
+var labelDerivedAttributes = []string{"groups", "group_summaries", "netbox_export", "route_table"}
+
+// inPlaceAttributes are the arguments changed in place because they don't
+// affect the allocations: external_allocation_api's auth_token, so the token
+// can be rotated, and arguments that only validate the plan or label the
+// allocations.
+var inPlaceAttributes = []string{
+	"external_allocation_api.0.auth_token",
+	"allocation_count_limit",
+	"exclude_overlapping_pools",
+	"warn_on_existing_cidr_errors",
+	"telemetry",
+	"oversize_warning_threshold",
+	"validate_against_asn_route_table",
+}
+
+// inPlaceAllocationAttributes are the arguments of allocation blocks changed
+// in place, since they only label the allocations.
+var inPlaceAllocationAttributes = []string{"group", "hub"}
+
+// isInPlaceKey reports whether a changed key belongs to one of
+// inPlaceAttributes or inPlaceAllocationAttributes.
+func isInPlaceKey(key string) bool {
+	for _, attr := range inPlaceAttributes {
+		if key == attr || strings.HasPrefix(key, attr+".") {
+			return true
+		}
+	}
+	return isInPlaceAllocationKey(key)
+}
+
+// isInPlaceAllocationKey reports whether a changed key is one of
+// inPlaceAllocationAttributes of an allocation block.
+func isInPlaceAllocationKey(key string) bool {
+	if parts := strings.Split(key, "."); len(parts) == 3 && parts[0] == "allocation" {
+		for _, attr := range inPlaceAllocationAttributes {
+			if parts[2] == attr {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// changesAllocationLabels reports whether the plan changes any of
+// inPlaceAllocationAttributes.
+func changesAllocationLabels(diff *schema.ResourceDiff) bool {
+	for _, key := range diff.GetChangedKeysPrefix("allocation") {
+		if isInPlaceAllocationKey(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// replacingChanges returns the keys changed by the plan of an existing pool,
+// other than those of inPlaceAttributes.
+func replacingChanges(diff *schema.ResourceDiff) []string {
+	var changed []string
+	for _, key := range diff.GetChangedKeysPrefix("") {
+		if !isInPlaceKey(key) {
+			changed = append(changed, key)
+		}
+	}
+	return changed
+}
+
+// growsBaseCIDRInPlace reports whether the only change planned for an existing
+// pool, other than to inPlaceAttributes, is growing its base_cidr, which is
+// made in place.
+func growsBaseCIDRInPlace(diff *schema.ResourceDiff) bool {
+	if diff.Id() == "" || !diff.NewValueKnown("base_cidr") {
+		return false
+	}
+	changed := replacingChanges(diff)
+	if len(changed) != 1 || changed[0] != "base_cidr" {
+		return false
+	}
+	old, new := diff.GetChange("base_cidr")
+	return isGrownBaseCIDR(old.(string), new.(string))
+}
 
-	results, err := allocator.Allocate(allocationRequests, allExclusions)
+// checkIdempotentIDReuse returns an error if an existing pool with an
+// idempotent_id is planned with a different base_cidr or allocation names but
+// the same idempotent_id, which its replacement would reuse. External systems
+// tracking the ID would otherwise mistake a different pool for the one they
+// know. Growing base_cidr and finishing a migration keep the pool, so they
+// are allowed.
+func checkIdempotentIDReuse(diff *schema.ResourceDiff) error {
+	if diff.Id() == "" || !diff.NewValueKnown("idempotent_id") || diff.Get("idempotent_id").(string) != diff.Id() {
+		return nil
+	}
+	if growsBaseCIDRInPlace(diff) || finishesMigration(diff) {
+		return nil
+	}
+
+	oldBase, _ := diff.GetChange("base_cidr")
+	if len(diff.GetChangedKeysPrefix("base_cidr")) > 0 || len(diff.GetChangedKeysPrefix("address_space")) > 0 {
+		return fmt.Errorf("idempotent_id %q is already used by this pool with base_cidr %s; change idempotent_id to replace it with a pool with a different base_cidr", diff.Id(), oldBase)
+	}
+
+	blocks := configAllocationBlocks(diff)
+	if !allocationNamesKnown(diff, len(blocks)) {
+		return nil
+	}
+	oldAllocations, _ := diff.GetChange("allocations")
+	var oldNames, newNames []string
+	for name := range oldAllocations.(map[string]interface{}) {
+		oldNames = append(oldNames, name)
+	}
+	for _, req := range expandAllocations(blocks, diff.Get("auto_generate_names").(bool)) {
+		newNames = append(newNames, req.Name)
+	}
+	sort.Strings(oldNames)
+	sort.Strings(newNames)
+	if strings.Join(oldNames, ",") != strings.Join(newNames, ",") {
+		return fmt.Errorf("idempotent_id %q is already used by this pool with allocations %s; change idempotent_id to replace it with a pool with allocations %s",
+			diff.Id(), strings.Join(oldNames, ", "), strings.Join(newNames, ", "))
+	}
+	return nil
+}
+
+// replacesPlanOnlyPool reports whether an existing pool is replaced because
+// plan_only is set, and was already set when it was created.
+func replacesPlanOnlyPool(diff *schema.ResourceDiff) bool {
+	if diff.Id() == "" || !diff.NewValueKnown("plan_only") {
+		return false
+	}
+	old, new := diff.GetChange("plan_only")
+	return old.(bool) && new.(bool)
+}
+
+// plannedBaseCIDR returns the base CIDR the pool is planned to allocate from,
+// or an empty string if it isn't known until apply.
+func plannedBaseCIDR(diff *schema.ResourceDiff) string {
+	if !diff.NewValueKnown("base_cidr") || !diff.NewValueKnown("address_space") || diff.Get("detect_base_cidr_from_region").(bool) {
+		return ""
+	}
+	if space := diff.Get("address_space").(string); space != "" {
+		return addressSpaces[space]
+	}
+	if baseCIDR := diff.Get("base_cidr").(string); baseCIDR != "" {
+		return baseCIDR
+	}
+	return defaultBaseCIDR
+}
+
+// allocationNamesKnown reports whether the names of all count allocations are
+// known at plan time.
+func allocationNamesKnown(diff *schema.ResourceDiff, count int) bool {
+	for i := 0; i < count; i++ {
+		if !diff.NewValueKnown(fmt.Sprintf("allocation.%d.name", i)) {
+			return false
+		}
+	}
+	return true
+}
+
+// planAllocations runs the allocation during plan and records the results, so
+// the plan shows the exact CIDRs instead of "known after apply". It does
+// nothing while any of the pool's configuration is unknown.
+func planAllocations(ctx context.Context, diff *schema.ResourceDiff, combined *config.CombinedConfig) error {
+	for key, s := range poolSchema() {
+		if !s.Optional && !s.Required {
+			continue
+		}
+		if key == "base_cidr" && diff.Get("detect_base_cidr_from_region").(bool) {
+			continue
+		}
+		if !diff.NewValueKnown(key) {
+			log.Printf("[DEBUG] Not computing allocations at plan time: %s is not known", key)
+			return nil
+		}
+	}
+
+	// CustomizeDiff can only return an error, so warnings are logged
+	allocation, diags := allocatePool(ctx, diff.Get, combined)
+	for _, d := range diags {
+		message := d.Summary
+		if d.Detail != "" {
+			message += ": " + d.Detail
+		}
+		switch d.Severity {
+		case diag.Error:
+			if d.AttributePath != nil {
+				return d.AttributePath.NewErrorf("%s", message)
+			}
+			return fmt.Errorf("%s", message)
+		case diag.Warning:
+			log.Printf("[WARN] %s", message)
+		}
+	}
+
+	if diff.Get("detect_base_cidr_from_region").(bool) || allocation.BaseCIDR != diff.Get("base_cidr").(string) {
+		if err := diff.SetNew("base_cidr", allocation.BaseCIDR); err != nil {
+			return err
+		}
+	}
+	if err := diff.SetNew("region_cidrs", flattenAllocations(groupAllocationsByRegion(allocation.Results, allocation.Requests))); err != nil {
+		return err
+	}
+	groups := GroupAllocations(allocation.Results, allocation.Requests)
+	if err := diff.SetNew("groups", flattenGroups(groups)); err != nil {
+		return err
+	}
+	groupSummaries, err := summarizeGroups(groups)
+	if err != nil {
+		return err
+	}
+	if err := diff.SetNew("group_summaries", groupSummaries); err != nil {
+		return err
+	}
+	if err := diff.SetNew("internal_allocations", flattenAllocations(cidr.FilterAllocationsByVisibility(allocation.Results, allocation.Requests, cidr.VisibilityInternal))); err != nil {
+		return err
+	}
+	if err := diff.SetNew("external_allocations", flattenAllocations(cidr.FilterAllocationsByVisibility(allocation.Results, allocation.Requests, cidr.VisibilityExternal))); err != nil {
+		return err
+	}
+	if err := diff.SetNew("route_table", flattenRouteTable(cidr.GenerateRouteTable(allocation.Results, allocation.Requests))); err != nil {
+		return err
+	}
+	if err := diff.SetNew("tag_recommendations", flattenAllocations(tagRecommendations(allocation.Requests, expandStringList(diff.Get("auto_tag_allocations").([]interface{}))))); err != nil {
+		return err
+	}
+	if err := diff.SetNew("digest", allocationsDigest(allocation.Results)); err != nil {
+		return err
+	}
+	id := poolID(diff.Get, allocation.BaseCIDR, allocation.Requests, allocation.ExclusionsFileHash)
+	ipv6Base, err := ipv6BaseCIDR(diff.Get("use_ipv6_ula_base").(bool), id)
 	if err != nil {
-		return diag.Errorf("Error allocating CIDRs: %s", err)
+		return err
+	}
+	if err := diff.SetNew("ipv6_base_cidr", ipv6Base); err != nil {
+		return err
+	}
+	first, last := flattenUsableHosts(flattenAllocations(allocation.Results))
+	if err := diff.SetNew("allocations_first_usable", first); err != nil {
+		return err
+	}
+	if err := diff.SetNew("allocations_last_usable", last); err != nil {
+		return err
+	}
+	if err := diff.SetNew("allocations_reverse_zones", flattenReverseZones(flattenAllocations(allocation.Results))); err != nil {
+		return err
+	}
+	if err := diff.SetNew("allocation_hash_ids", flattenAllocationHashIDs(id, flattenAllocations(allocation.Results))); err != nil {
+		return err
+	}
+	// Warnings can't be returned from CustomizeDiff, so they are reported
+	// when the pool is created
+	cidrSubnets, _ := flattenCIDRSubnets(allocation.BaseCIDR, allocation.Results, allocation.Requests)
+	if err := diff.SetNew("allocations_cidrsubnet", cidrSubnets); err != nil {
+		return err
+	}
+	return diff.SetNew("allocations", flattenAllocations(allocation.Results))
+}
+
+// shareOverlappingPools records the allocations of an existing pool that is
+// kept by this plan, and gives a new pool the allocations recorded so far of
+// the pools it must avoid. Pools are planned concurrently unless one depends
+// on the other, so only pools planned earlier are seen.
+func shareOverlappingPools(diff *schema.ResourceDiff, combined *config.CombinedConfig) error {
+	if diff.Id() != "" {
+		if replacesPlanOnlyPool(diff) || (len(replacingChanges(diff)) > 0 && !growsBaseCIDRInPlace(diff) && !finishesMigration(diff)) {
+			return nil
+		}
+		var allocations []string
+		for _, v := range diff.Get("allocations").(map[string]interface{}) {
+			allocations = append(allocations, v.(string))
+		}
+		networks, err := cidr.ParseCIDRs(allocations)
+		if err != nil {
+			return err
+		}
+		combined.RecordPoolAllocations(networks, diff.Get("exclude_overlapping_pools").(bool))
+		return nil
+	}
+
+	var cidrs []string
+	for _, network := range cidr.Merge(overlappingPoolAllocations(combined, diff.Get("exclude_overlapping_pools").(bool))) {
+		cidrs = append(cidrs, network.String())
+	}
+	return diff.SetNew("overlapping_pool_cidrs", cidrs)
+}
+
+// overlappingPoolAllocations returns the recorded allocations of other pools
+// that a pool avoids: those of every pool when it has exclude_overlapping_pools
+// set, and otherwise those of the pools that have it set, which it may share
+// address space with.
+func overlappingPoolAllocations(combined *config.CombinedConfig, excludeOverlapping bool) []*net.IPNet {
+	if excludeOverlapping {
+		return combined.PoolAllocations()
+	}
+	return combined.ExcludingPoolAllocations()
+}
+
+// checkPlannedAllocations returns an error if the allocations made at apply
+// time differ from those computed during plan, which happens when the account
+// or exclusions changed in between.
+func checkPlannedAllocations(planned map[string]interface{}, results map[string]string) error {
+	var changed []string
+	for name, cidrBlock := range results {
+		if planned[name] != cidrBlock {
+			changed = append(changed, fmt.Sprintf("%s (planned %v, now %s)", name, planned[name], cidrBlock))
+		}
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+	sort.Strings(changed)
+	return fmt.Errorf("allocations changed since the plan was made: %s; run terraform plan again", strings.Join(changed, ", "))
+}
+
+// resourceDocidrPoolCreate handles the creation of a docidr_pool resource.
+func resourceDocidrPoolCreate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	combined := meta.(*config.CombinedConfig)
+	ctx, metrics := config.WithAPIMetrics(ctx)
+
+	// Pools allocate one at a time, so whichever of two pools sharing address
+	// space is created second sees the allocations of the first, whichever of
+	// them set exclude_overlapping_pools
+	defer combined.LockPoolAllocations()()
+
+	// Pools with an external IPAM system allocate from it instead
+	externalAPI, external := expandExternalIPAMConfig(d.Get("external_allocation_api").([]interface{}))
+	var ipam ExternalIPAMClient
+	if external {
+		var err error
+		if ipam, err = newExternalIPAMClient(externalAPI); err != nil {
+			return diag.FromErr(err)
+		}
+	}
+
+	start := time.Now()
+	var allocation *poolAllocation
+	var diags diag.Diagnostics
+	if external {
+		allocation, diags = allocateExternalPool(ctx, d.Get, combined, ipam)
+	} else {
+		allocation, diags = allocatePool(ctx, d.Get, combined)
+	}
+	if diags.HasError() {
+		return diags
+	}
+	duration := time.Since(start)
+	allocation.Report.APIMetrics = metrics.Summary()
+	baseCIDR, results := allocation.BaseCIDR, allocation.Results
+
+	// Allocations computed during plan must still be valid at apply time
+	if planned := d.Get("allocations").(map[string]interface{}); len(planned) > 0 {
+		if err := checkPlannedAllocations(planned, results); err != nil {
+			return append(diags, diag.FromErr(err)...)
+		}
 	}
 
 	log.Printf("[DEBUG] Successfully allocated CIDRs:")
+	var allocated []*net.IPNet
 	for name, cidrBlock := range results {
 		log.Printf("[DEBUG]   - %s: %s", name, cidrBlock)
+		network, err := cidr.ParseCIDR(cidrBlock)
+		if err != nil {
+			return append(diags, diag.FromErr(err)...)
+		}
+		allocated = append(allocated, network)
 	}
+	combined.RecordPoolAllocations(allocated, d.Get("exclude_overlapping_pools").(bool))
+	diags = append(diags, routeTableWarnings(ctx, combined.HTTPClient(), d.Get("validate_against_asn_route_table").(string), results)...)
 
-	// Generate a stable resource ID based on inputs
-	id := generateResourceID(baseCIDR, allocationRequests, d.Get("exclude").([]interface{}))
+	// Use the idempotent_id, or generate a stable resource ID based on inputs
+	id := poolID(d.Get, baseCIDR, allocation.Requests, allocation.ExclusionsFileHash)
 	d.SetId(id)
 
+	if external {
+		diags = append(diags, syncExternalAllocations(ctx, ipam, id, results, allocation.ExternalIDs)...)
+	}
+	if err := d.Set("external_allocation_ids", allocation.ExternalIDs); err != nil {
+		return append(diags, diag.FromErr(err)...)
+	}
+
+	if err := d.Set("base_cidr", baseCIDR); err != nil {
+		return append(diags, diag.FromErr(err)...)
+	}
+
+	ipv6Base, err := ipv6BaseCIDR(d.Get("use_ipv6_ula_base").(bool), id)
+	if err != nil {
+		return append(diags, diag.FromErr(err)...)
+	}
+	if err := d.Set("ipv6_base_cidr", ipv6Base); err != nil {
+		return append(diags, diag.FromErr(err)...)
+	}
+
+	if err := d.Set("exclusions_file_hash", allocation.ExclusionsFileHash); err != nil {
+		return append(diags, diag.FromErr(err)...)
+	}
+
 	// Set computed attributes
 	if err := d.Set("allocations", flattenAllocations(results)); err != nil {
-		return diag.FromErr(err)
+		return append(diags, diag.FromErr(err)...)
+	}
+
+	if err := setUsableHosts(d, flattenAllocations(results)); err != nil {
+		return append(diags, diag.FromErr(err)...)
+	}
+
+	if err := d.Set("allocations_reverse_zones", flattenReverseZones(flattenAllocations(results))); err != nil {
+		return append(diags, diag.FromErr(err)...)
+	}
+
+	if err := d.Set("allocation_hash_ids", flattenAllocationHashIDs(id, flattenAllocations(results))); err != nil {
+		return append(diags, diag.FromErr(err)...)
+	}
+
+	cidrSubnets, cidrSubnetWarnings := flattenCIDRSubnets(baseCIDR, results, allocation.Requests)
+	diags = append(diags, cidrSubnetWarnings...)
+	if err := d.Set("allocations_cidrsubnet", cidrSubnets); err != nil {
+		return append(diags, diag.FromErr(err)...)
+	}
+
+	if err := d.Set("allocation_prefix_lengths", flattenPrefixLengths(allocation.Requests)); err != nil {
+		return append(diags, diag.FromErr(err)...)
+	}
+
+	if err := d.Set("region_cidrs", flattenAllocations(groupAllocationsByRegion(results, allocation.Requests))); err != nil {
+		return append(diags, diag.FromErr(err)...)
+	}
+
+	groups := GroupAllocations(results, allocation.Requests)
+	if err := d.Set("groups", flattenGroups(groups)); err != nil {
+		return append(diags, diag.FromErr(err)...)
+	}
+
+	groupSummaries, err := summarizeGroups(groups)
+	if err != nil {
+		return append(diags, diag.FromErr(err)...)
+	}
+	if err := d.Set("group_summaries", groupSummaries); err != nil {
+		return append(diags, diag.FromErr(err)...)
+	}
+
+	if err := d.Set("internal_allocations", flattenAllocations(cidr.FilterAllocationsByVisibility(results, allocation.Requests, cidr.VisibilityInternal))); err != nil {
+		return append(diags, diag.FromErr(err)...)
+	}
+
+	if err := d.Set("external_allocations", flattenAllocations(cidr.FilterAllocationsByVisibility(results, allocation.Requests, cidr.VisibilityExternal))); err != nil {
+		return append(diags, diag.FromErr(err)...)
+	}
+
+	if err := d.Set("route_table", flattenRouteTable(cidr.GenerateRouteTable(results, allocation.Requests))); err != nil {
+		return append(diags, diag.FromErr(err)...)
+	}
+
+	if err := d.Set("tag_recommendations", flattenAllocations(tagRecommendations(allocation.Requests, expandStringList(d.Get("auto_tag_allocations").([]interface{}))))); err != nil {
+		return append(diags, diag.FromErr(err)...)
+	}
+
+	if err := d.Set("state_valid", true); err != nil {
+		return append(diags, diag.FromErr(err)...)
+	}
+
+	if err := d.Set("digest", allocationsDigest(results)); err != nil {
+		return append(diags, diag.FromErr(err)...)
+	}
+
+	if err := d.Set("scan_report", flattenScanReport(allocation.Report)); err != nil {
+		return append(diags, diag.FromErr(err)...)
+	}
+
+	if err := d.Set("summary", cidr.FormatCIDRTree(allocation.Allocator.BaseCIDR(), results)); err != nil {
+		return append(diags, diag.FromErr(err)...)
+	}
+
+	allocationsJSON, err := buildAllocationsJSON(id, baseCIDR, time.Now(), results, allocation.Requests, expandStringList(d.Get("auto_tag_allocations").([]interface{})))
+	if err != nil {
+		return append(diags, diag.FromErr(err)...)
+	}
+	if err := d.Set("allocations_json", allocationsJSON); err != nil {
+		return append(diags, diag.FromErr(err)...)
+	}
+
+	netboxExport, err := buildNetboxExport(results, allocation.Requests, expandStringList(d.Get("auto_tag_allocations").([]interface{})), expandNetboxDefaults(d.Get("netbox_defaults").([]interface{})))
+	if err != nil {
+		return append(diags, diag.Errorf("Error formatting allocations for NetBox: %s", err)...)
+	}
+	if err := d.Set("netbox_export", netboxExport); err != nil {
+		return append(diags, diag.FromErr(err)...)
+	}
+
+	if err := d.Set("previous_allocations", d.Get("previous_allocations")); err != nil {
+		return append(diags, diag.FromErr(err)...)
+	}
+
+	migrationMap := []interface{}{}
+	if d.Get("migrate_to_base").(string) != "" {
+		migrationMap = flattenMigrationMap(d.Get("previous_allocations").(map[string]interface{}), results, allocation.Requests)
+	}
+	if err := d.Set("migration_map", migrationMap); err != nil {
+		return append(diags, diag.FromErr(err)...)
+	}
+
+	var poolExclusions []string
+	for _, network := range allocation.PoolExclusions {
+		poolExclusions = append(poolExclusions, network.String())
+	}
+	if err := d.Set("overlapping_pool_cidrs", poolExclusions); err != nil {
+		return append(diags, diag.FromErr(err)...)
+	}
+
+	if err := d.Set("export_terraform_locals", cidr.FormatTerraformLocals(results, d.Id())); err != nil {
+		return append(diags, diag.FromErr(err)...)
+	}
+	allocationsCSV, err := cidr.FormatCSV(results, allocation.Requests)
+	if err != nil {
+		return append(diags, diag.Errorf("Error formatting allocations as CSV: %s", err)...)
+	}
+	if err := d.Set("output_csv", allocationsCSV); err != nil {
+		return append(diags, diag.FromErr(err)...)
+	}
+
+	// Allocations with their own base_cidr don't use the pool's base
+	inBase := poolBaseAllocations(results, allocation.Requests)
+	utilization, err := cidr.Utilization(baseCIDR, inBase)
+	if err != nil {
+		return append(diags, diag.Errorf("Error calculating utilization: %s", err)...)
+	}
+	if err := d.Set("utilization_percent", utilization); err != nil {
+		return append(diags, diag.FromErr(err)...)
+	}
+
+	breakdown, err := cidr.UtilizationBreakdown(baseCIDR, inBase)
+	if err != nil {
+		return append(diags, diag.Errorf("Error calculating utilization: %s", err)...)
+	}
+	if err := d.Set("utilization_breakdown", breakdown); err != nil {
+		return append(diags, diag.FromErr(err)...)
+	}
+
+	if path := d.Get("audit_log_file").(string); path != "" {
+		entry := newAuditLogEntry(auditOperationCreate, d.Id(), baseCIDR, flattenAllocations(results), combined.TerraformVersion())
+		diags = append(diags, appendAuditLog(path, entry)...)
+	}
+
+	prefixLength, _ := allocation.Allocator.BaseCIDR().Mask.Size()
+	sendTelemetry(ctx, expandTelemetryConfig(d.Get("telemetry").([]interface{})), TelemetryPayload{
+		ProviderVersion:      combined.ProviderVersion(),
+		AllocationCount:      len(results),
+		BaseCIDRPrefixLength: prefixLength,
+		Strategy:             d.Get("placement").(string),
+		DurationMS:           duration.Milliseconds(),
+	})
+
+	if d.Get("plan_only").(bool) {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Warning,
+			Summary:  "docidr_pool is a proposal",
+			Detail: fmt.Sprintf("docidr_pool %s has plan_only set, so it will be destroyed and allocated again on the next apply unless plan_only is "+
+				"changed to false. Add lifecycle { create_before_destroy = true } to the pool so resources built from its allocations "+
+				"are never left without one while it is replaced.", d.Id()),
+		})
 	}
 
 	log.Printf("[INFO] Created docidr_pool %s", d.Id())
+	log.Printf("[INFO] API usage for docidr_pool %s: %s", d.Id(), allocation.Report.APIMetrics)
 
-	return nil
+	return diags
 }
 
 // resourceDocidrPoolRead handles reading a docidr_pool resource.
-// Since allocations are stored in state and not in any external system,
-// we simply return the current state without any API calls.
+// Since allocations are stored in state, and those from an external IPAM
+// system aren't read back from it, we simply return the current state
+// without any API calls, after checking that it hasn't been corrupted.
 func resourceDocidrPoolRead(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
 	// State is the source of truth - no API calls needed
 	log.Printf("[DEBUG] Reading docidr_pool %s from state", d.Id())
+
+	valid := true
+	if err := validatePoolState(d.Get("base_cidr").(string), d.Get("allocations").(map[string]interface{})); err != nil {
+		log.Printf("[WARN] docidr_pool %s has invalid state and will be replaced: %s", d.Id(), err)
+		valid = false
+	}
+	if err := d.Set("state_valid", valid); err != nil {
+		return diag.FromErr(err)
+	}
+
+	// Pools created before the usable host, reverse zone and hash ID maps
+	// existed get them here
+	if err := setUsableHosts(d, d.Get("allocations").(map[string]interface{})); err != nil {
+		return diag.FromErr(err)
+	}
+	if err := d.Set("allocations_reverse_zones", flattenReverseZones(d.Get("allocations").(map[string]interface{}))); err != nil {
+		return diag.FromErr(err)
+	}
+	if err := d.Set("allocation_hash_ids", flattenAllocationHashIDs(d.Id(), d.Get("allocations").(map[string]interface{}))); err != nil {
+		return diag.FromErr(err)
+	}
 	return nil
 }
 
-// resourceDocidrPoolDelete handles deletion of a docidr_pool resource.
-// Since there are no external resources to delete, we just remove from state.
-func resourceDocidrPoolDelete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
-	log.Printf("[INFO] Deleting docidr_pool %s", d.Id())
-	d.SetId("")
-	return nil
+// setUsableHosts sets allocations_first_usable and allocations_last_usable from
+// the pool's allocations.
+func setUsableHosts(d *schema.ResourceData, allocations map[string]interface{}) error {
+	first, last := flattenUsableHosts(allocations)
+	if err := d.Set("allocations_first_usable", first); err != nil {
+		return err
+	}
+	return d.Set("allocations_last_usable", last)
 }
 
-// collectExistingCIDRs queries the DigitalOcean API for all CIDRs currently in use.
-func collectExistingCIDRs(ctx context.Context, client *godo.Client) ([]*net.IPNet, error) {
-	var cidrs []*net.IPNet
+// resourceDocidrPoolUpdate handles the only in-place changes to a pool,
+// growing its base_cidr, finishing a migration, changing allocation groups
+// and hubs, and changing inPlaceAttributes. The allocations and ID are kept,
+// so resources built from them are unaffected, and the attributes derived
+// from the base CIDR and labels are recomputed.
+func resourceDocidrPoolUpdate(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	// Only inPlaceAllocationAttributes can change within allocation blocks
+	if d.HasChange("allocation") {
+		if err := setLabelAttributes(d); err != nil {
+			return diag.FromErr(err)
+		}
+	}
 
-	// Collect VPC CIDRs
-	vpcCIDRs, err := collectVPCCIDRs(ctx, client)
-	if err != nil {
-		return nil, fmt.Errorf("error collecting VPC CIDRs: %w", err)
+	// Changes to inPlaceAttributes are only stored
+	if !d.HasChanges("base_cidr", "migrate_to_base") {
+		return nil
 	}
-	cidrs = append(cidrs, vpcCIDRs...)
 
-	// Collect Kubernetes cluster CIDRs
-	k8sCIDRs, err := collectKubernetesCIDRs(ctx, client)
+	baseCIDR := d.Get("base_cidr").(string)
+	base, err := cidr.ParseCIDR(baseCIDR)
 	if err != nil {
-		return nil, fmt.Errorf("error collecting Kubernetes CIDRs: %w", err)
+		return diag.FromErr(err)
+	}
+	if d.HasChange("base_cidr") {
+		log.Printf("[INFO] Growing base CIDR of docidr_pool %s to %s", d.Id(), baseCIDR)
 	}
-	cidrs = append(cidrs, k8sCIDRs...)
 
-	return cidrs, nil
-}
+	// Finishing a migration drops the allocations from before it
+	if d.HasChange("migrate_to_base") {
+		log.Printf("[INFO] Finishing migration of docidr_pool %s to %s", d.Id(), baseCIDR)
+		if err := d.Set("previous_allocations", map[string]interface{}{}); err != nil {
+			return diag.FromErr(err)
+		}
+		if err := d.Set("migration_map", []interface{}{}); err != nil {
+			return diag.FromErr(err)
+		}
+	}
 
-// collectVPCCIDRs retrieves all VPC IP ranges from the DigitalOcean account.
-func collectVPCCIDRs(ctx context.Context, client *godo.Client) ([]*net.IPNet, error) {
-	var cidrs []*net.IPNet
+	results := make(map[string]string)
+	for name, block := range d.Get("allocations").(map[string]interface{}) {
+		results[name] = block.(string)
+	}
 
-	opt := &godo.ListOptions{PerPage: 200}
-	for {
-		vpcs, resp, err := client.VPCs.List(ctx, opt)
-		if err != nil {
-			return nil, err
-		}
-
-		for _, vpc := range vpcs {
-			if vpc.IPRange != "" {
-				network, err := cidr.ParseCIDR(vpc.IPRange)
-				if err != nil {
-					log.Printf("[WARN] Skipping invalid VPC CIDR %q from VPC %s: %v", vpc.IPRange, vpc.ID, err)
-					continue
-				}
-				cidrs = append(cidrs, network)
-				log.Printf("[DEBUG] Found VPC %s with CIDR %s", vpc.Name, vpc.IPRange)
-			}
-		}
+	if err := d.Set("summary", cidr.FormatCIDRTree(base, results)); err != nil {
+		return diag.FromErr(err)
+	}
 
-		if resp.Links == nil || resp.Links.IsLastPage() {
-			break
-		}
+	// Allocations with their own base_cidr don't use the pool's base
+	requests := expandAllocations(d.Get("allocation").([]interface{}), d.Get("auto_generate_names").(bool))
+	inBase := poolBaseAllocations(results, requests)
+	utilization, err := cidr.Utilization(baseCIDR, inBase)
+	if err != nil {
+		return diag.Errorf("Error calculating utilization: %s", err)
+	}
+	if err := d.Set("utilization_percent", utilization); err != nil {
+		return diag.FromErr(err)
+	}
+	breakdown, err := cidr.UtilizationBreakdown(baseCIDR, inBase)
+	if err != nil {
+		return diag.Errorf("Error calculating utilization: %s", err)
+	}
+	if err := d.Set("utilization_breakdown", breakdown); err != nil {
+		return diag.FromErr(err)
+	}
 
-		page, err := resp.Links.CurrentPage()
-		if err != nil {
-			return nil, err
-		}
-		opt.Page = page + 1
+	// Allocations left out when the pool was created were reported then
+	cidrSubnets, _ := flattenCIDRSubnets(baseCIDR, results, requests)
+	if err := d.Set("allocations_cidrsubnet", cidrSubnets); err != nil {
+		return diag.FromErr(err)
 	}
 
-	return cidrs, nil
-}
+	// The planned value is unknown, so rebase the document in state
+	oldJSON, _ := d.GetChange("allocations_json")
+	allocationsJSON, err := rebaseAllocationsJSON(oldJSON.(string), baseCIDR)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	if err := d.Set("allocations_json", allocationsJSON); err != nil {
+		return diag.FromErr(err)
+	}
 
-// collectKubernetesCIDRs retrieves all Kubernetes cluster and service subnets.
-func collectKubernetesCIDRs(ctx context.Context, client *godo.Client) ([]*net.IPNet, error) {
-	var cidrs []*net.IPNet
+	var diags diag.Diagnostics
+	if path := d.Get("audit_log_file").(string); path != "" {
+		entry := newAuditLogEntry(auditOperationUpdate, d.Id(), baseCIDR, d.Get("allocations").(map[string]interface{}), meta.(*config.CombinedConfig).TerraformVersion())
+		diags = appendAuditLog(path, entry)
+	}
+	return diags
+}
 
-	opt := &godo.ListOptions{PerPage: 200}
-	for {
-		clusters, resp, err := client.Kubernetes.List(ctx, opt)
-		if err != nil {
-			return nil, err
-		}
-
-		for _, cluster := range clusters {
-			if cluster.ClusterSubnet != "" {
-				network, err := cidr.ParseCIDR(cluster.ClusterSubnet)
-				if err != nil {
-					log.Printf("[WARN] Skipping invalid cluster subnet %q from cluster %s: %v", cluster.ClusterSubnet, cluster.ID, err)
-				} else {
-					cidrs = append(cidrs, network)
-					log.Printf("[DEBUG] Found Kubernetes cluster %s with cluster subnet %s", cluster.Name, cluster.ClusterSubnet)
-				}
-			}
+// setLabelAttributes recomputes labelDerivedAttributes from the allocations in
+// state and the groups and hubs in the configuration.
+func setLabelAttributes(d *schema.ResourceData) error {
+	results := make(map[string]string)
+	for name, block := range d.Get("allocations").(map[string]interface{}) {
+		results[name] = block.(string)
+	}
+	requests := expandAllocations(d.Get("allocation").([]interface{}), d.Get("auto_generate_names").(bool))
 
-			if cluster.ServiceSubnet != "" {
-				network, err := cidr.ParseCIDR(cluster.ServiceSubnet)
-				if err != nil {
-					log.Printf("[WARN] Skipping invalid service subnet %q from cluster %s: %v", cluster.ServiceSubnet, cluster.ID, err)
-				} else {
-					cidrs = append(cidrs, network)
-					log.Printf("[DEBUG] Found Kubernetes cluster %s with service subnet %s", cluster.Name, cluster.ServiceSubnet)
-				}
-			}
-		}
+	groups := GroupAllocations(results, requests)
+	if err := d.Set("groups", flattenGroups(groups)); err != nil {
+		return err
+	}
+	groupSummaries, err := summarizeGroups(groups)
+	if err != nil {
+		return err
+	}
+	if err := d.Set("group_summaries", groupSummaries); err != nil {
+		return err
+	}
+	netboxExport, err := buildNetboxExport(results, requests, expandStringList(d.Get("auto_tag_allocations").([]interface{})), expandNetboxDefaults(d.Get("netbox_defaults").([]interface{})))
+	if err != nil {
+		return fmt.Errorf("Error formatting allocations for NetBox: %s", err)
+	}
+	if err := d.Set("netbox_export", netboxExport); err != nil {
+		return err
+	}
+	return d.Set("route_table", flattenRouteTable(cidr.GenerateRouteTable(results, requests)))
+}
 
-		if resp.Links == nil || resp.Links.IsLastPage() {
-			break
-		}
+// resourceDocidrPoolDelete handles deletion of a docidr_pool resource.
+// Allocations from an external IPAM system are released there. Otherwise
+// there are no external resources to delete, so we just remove from state.
+func resourceDocidrPoolDelete(ctx context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	log.Printf("[INFO] Deleting docidr_pool %s", d.Id())
 
-		page, err := resp.Links.CurrentPage()
+	if externalAPI, ok := expandExternalIPAMConfig(d.Get("external_allocation_api").([]interface{})); ok {
+		ipam, err := newExternalIPAMClient(externalAPI)
 		if err != nil {
-			return nil, err
+			return diag.FromErr(err)
+		}
+		if err := releaseExternalAllocations(ctx, ipam, d.Get("external_allocation_ids").(map[string]interface{})); err != nil {
+			return diag.FromErr(err)
 		}
-		opt.Page = page + 1
 	}
 
-	return cidrs, nil
+	var diags diag.Diagnostics
+	if path := d.Get("audit_log_file").(string); path != "" {
+		entry := newAuditLogEntry(auditOperationDelete, d.Id(), d.Get("base_cidr").(string),
+			d.Get("allocations").(map[string]interface{}), meta.(*config.CombinedConfig).TerraformVersion())
+		diags = appendAuditLog(path, entry)
+	}
+
+	d.SetId("")
+	return diags
+}
+
+// poolID returns the ID of a pool with the given allocations: its
+// idempotent_id if set, or otherwise one generated from the configuration.
+func poolID(get func(string) interface{}, baseCIDR string, allocations []cidr.AllocationRequest, exclusionsFileHash string) string {
+	if id := get("idempotent_id").(string); id != "" {
+		return id
+	}
+	return generateResourceID(baseCIDR, allocations, get("exclude").([]interface{}), exclusionsFileHash)
 }
 
 // generateResourceID creates a stable resource ID based on the configuration.
 // This ensures the ID remains consistent across applies with the same inputs.
-func generateResourceID(baseCIDR string, allocations []cidr.AllocationRequest, exclusions []interface{}) string {
+// The exclusions file hash is only included when set, so pools without an
+// exclusions file keep their existing IDs.
+func generateResourceID(baseCIDR string, allocations []cidr.AllocationRequest, exclusions []interface{}, exclusionsFileHash string) string {
 	var parts []string
 
 	parts = append(parts, baseCIDR)
@@ -236,7 +1197,14 @@ func generateResourceID(baseCIDR string, allocations []cidr.AllocationRequest, e
 	})
 
 	for _, alloc := range sortedAllocs {
-		parts = append(parts, fmt.Sprintf("%s:%d", alloc.Name, alloc.PrefixLength))
+		part := fmt.Sprintf("%s:%d", alloc.Name, alloc.PrefixLength)
+		if len(alloc.Exclusions) > 0 {
+			part += ":" + strings.Join(sortedNetworkStrings(alloc.Exclusions), ",")
+		}
+		if alloc.BaseCIDR != nil {
+			part += "@" + alloc.BaseCIDR.String()
+		}
+		parts = append(parts, part)
 	}
 
 	// Sort exclusions for determinism
@@ -248,6 +1216,10 @@ func generateResourceID(baseCIDR string, allocations []cidr.AllocationRequest, e
 	sort.Strings(exclCIDRs)
 	parts = append(parts, exclCIDRs...)
 
+	if exclusionsFileHash != "" {
+		parts = append(parts, "file:"+exclusionsFileHash)
+	}
+
 	// Create hash
 	hash := sha256.Sum256([]byte(strings.Join(parts, "|")))
 	return hex.EncodeToString(hash[:])[:16]
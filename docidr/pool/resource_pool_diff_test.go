@@ -0,0 +1,1987 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/DO-Solutions/terraform-provider-docidr/docidr/cidr"
+	"github.com/DO-Solutions/terraform-provider-docidr/docidr/config"
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+// planPool runs the docidr_pool plan (including CustomizeDiff) for a new
+// resource with the given raw configuration.
+func planPool(t *testing.T, raw map[string]interface{}, meta interface{}) (*terraform.InstanceDiff, error) {
+	t.Helper()
+
+	r := ResourceDocidrPool()
+	return r.Diff(context.Background(), nil, terraform.NewResourceConfigRaw(raw), meta)
+}
+
+func TestResourceDocidrPoolCustomizeDiff_DefaultBaseCIDR(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  map[string]interface{}
+		want string
+	}{
+		{
+			name: "default",
+			raw:  map[string]interface{}{},
+			want: "10.0.0.0/8",
+		},
+		{
+			name: "explicit",
+			raw:  map[string]interface{}{"base_cidr": "172.16.0.0/12"},
+			want: "172.16.0.0/12",
+		},
+		{
+			name: "detected at apply",
+			raw:  map[string]interface{}{"region": "nyc1", "detect_base_cidr_from_region": true},
+			want: "",
+		},
+		{
+			name: "address space 10",
+			raw:  map[string]interface{}{"address_space": "rfc1918_10"},
+			want: "10.0.0.0/8",
+		},
+		{
+			name: "address space 172",
+			raw:  map[string]interface{}{"address_space": "rfc1918_172"},
+			want: "172.16.0.0/12",
+		},
+		{
+			name: "address space 192",
+			raw: map[string]interface{}{
+				"address_space": "rfc1918_192",
+				// Unset allocations are internal, which 192.168.0.0/16 can't hold
+				"allocation": []interface{}{
+					map[string]interface{}{"name": "vpc", "prefix_length": 16, "visibility": "external"},
+				},
+			},
+			want: "192.168.0.0/16",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw := map[string]interface{}{
+				"allocation": []interface{}{
+					map[string]interface{}{"name": "vpc", "prefix_length": 16},
+				},
+			}
+			for k, v := range tt.raw {
+				raw[k] = v
+			}
+
+			diff, err := planPool(t, raw, nil)
+			if err != nil {
+				t.Fatalf("Diff() error = %v", err)
+			}
+
+			attr := diff.Attributes["base_cidr"]
+			if tt.want == "" {
+				if attr == nil || !attr.NewComputed {
+					t.Errorf("base_cidr should be computed, got %+v", attr)
+				}
+				return
+			}
+			if attr == nil || attr.New != tt.want {
+				t.Errorf("base_cidr = %+v, want %s", attr, tt.want)
+			}
+		})
+	}
+}
+
+func TestResourceDocidrPoolCustomizeDiff_ComputeAllocationsAtPlanTime(t *testing.T) {
+	mux := newFakeAccountMux(
+		[]interface{}{
+			map[string]interface{}{"id": "vpc-1", "name": "prod", "ip_range": "10.0.0.0/16", "region": "nyc1"},
+		},
+		[]interface{}{},
+	)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	raw := map[string]interface{}{
+		"allocation": []interface{}{
+			map[string]interface{}{"name": "vpc", "prefix_length": 16},
+			map[string]interface{}{"name": "k8s", "prefix_length": 20},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		enabled bool
+		want    map[string]string
+	}{
+		{
+			name:    "enabled",
+			enabled: true,
+			want:    map[string]string{"vpc": "10.1.0.0/16", "k8s": "10.2.0.0/20"},
+		},
+		{
+			name:    "disabled",
+			enabled: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			meta := newTestCombinedConfig(t, &config.Config{
+				APIEndpoint:                  srv.URL + "/",
+				ComputeAllocationsAtPlanTime: tt.enabled,
+			})
+
+			diff, err := planPool(t, raw, meta)
+			if err != nil {
+				t.Fatalf("Diff() error = %v", err)
+			}
+
+			if tt.want == nil {
+				if attr := diff.Attributes["allocations.%"]; attr == nil || !attr.NewComputed {
+					t.Errorf("allocations should be computed, got %+v", attr)
+				}
+				return
+			}
+			for name, want := range tt.want {
+				if attr := diff.Attributes["allocations."+name]; attr == nil || attr.New != want {
+					t.Errorf("allocations.%s = %+v, want %s", name, attr, want)
+				}
+			}
+			if attr := diff.Attributes["region_cidrs.global"]; attr == nil || attr.New != tt.want["vpc"] {
+				t.Errorf("region_cidrs.global = %+v, want %s", attr, tt.want["vpc"])
+			}
+			if attr := diff.Attributes["digest"]; attr == nil || attr.New != allocationsDigest(tt.want) {
+				t.Errorf("digest = %+v, want %s", attr, allocationsDigest(tt.want))
+			}
+		})
+	}
+}
+
+func TestResourceDocidrPool_AddressSpace(t *testing.T) {
+	srv := httptest.NewServer(newFakeAccountMux([]interface{}{}, []interface{}{}))
+	t.Cleanup(srv.Close)
+	newMeta := func() *config.CombinedConfig {
+		return newTestCombinedConfig(t, &config.Config{APIEndpoint: srv.URL + "/"})
+	}
+
+	pool := func(key, value string) map[string]interface{} {
+		return map[string]interface{}{
+			key: value,
+			"allocation": []interface{}{
+				map[string]interface{}{"name": "vpc", "prefix_length": 16, "visibility": "external"},
+			},
+		}
+	}
+
+	preset := applyPool(t, nil, pool("address_space", "rfc1918_172"), newMeta())
+	if got := preset.Attributes["base_cidr"]; got != "172.16.0.0/12" {
+		t.Errorf("base_cidr = %q, want 172.16.0.0/12", got)
+	}
+	if got := preset.Attributes["allocations.vpc"]; got != "172.16.0.0/16" {
+		t.Errorf("allocations.vpc = %q, want 172.16.0.0/16", got)
+	}
+
+	explicit := applyPool(t, nil, pool("base_cidr", "172.16.0.0/12"), newMeta())
+	if preset.ID != explicit.ID {
+		t.Errorf("ID with address_space = %s, with base_cidr = %s, want the same", preset.ID, explicit.ID)
+	}
+
+	tests := []struct {
+		name        string
+		state       *terraform.InstanceState
+		raw         map[string]interface{}
+		wantReplace bool
+	}{
+		{name: "preset to base_cidr", state: preset, raw: pool("base_cidr", "172.16.0.0/12")},
+		{name: "base_cidr to preset", state: explicit, raw: pool("address_space", "rfc1918_172")},
+		{name: "unchanged preset", state: preset, raw: pool("address_space", "rfc1918_172")},
+		{name: "other preset", state: preset, raw: pool("address_space", "rfc1918_192"), wantReplace: true},
+		{name: "base_cidr to other preset", state: explicit, raw: pool("address_space", "rfc1918_10"), wantReplace: true},
+		{name: "preset to other base_cidr", state: preset, raw: pool("base_cidr", "172.16.0.0/16"), wantReplace: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diff, err := ResourceDocidrPool().Diff(context.Background(), tt.state, terraform.NewResourceConfigRaw(tt.raw), newMeta())
+			if err != nil {
+				t.Fatalf("Diff() error = %v", err)
+			}
+			if replaced := diff != nil && diff.RequiresNew(); replaced != tt.wantReplace {
+				t.Errorf("RequiresNew() = %v, want %v (diff %v)", replaced, tt.wantReplace, diff)
+			}
+		})
+	}
+}
+
+func TestResourceDocidrPoolCreate_IPv6ULABase(t *testing.T) {
+	srv := httptest.NewServer(newFakeAccountMux([]interface{}{}, []interface{}{}))
+	t.Cleanup(srv.Close)
+	meta := func() *config.CombinedConfig {
+		return newTestCombinedConfig(t, &config.Config{APIEndpoint: srv.URL + "/", ComputeAllocationsAtPlanTime: true})
+	}
+
+	pool := func(useULABase bool) map[string]interface{} {
+		return map[string]interface{}{
+			"base_cidr":         "10.0.0.0/16",
+			"use_ipv6_ula_base": useULABase,
+			"allocation": []interface{}{
+				map[string]interface{}{"name": "vpc", "prefix_length": 20},
+			},
+		}
+	}
+
+	diff, err := planPool(t, pool(true), meta())
+	if err != nil {
+		t.Fatalf("Diff() error = %v", err)
+	}
+	state := applyPool(t, nil, pool(true), meta())
+
+	want := cidr.ULAPrefix(state.ID).String()
+	if got := state.Attributes["ipv6_base_cidr"]; got != want {
+		t.Errorf("ipv6_base_cidr = %q, want %q", got, want)
+	}
+	if attr := diff.Attributes["ipv6_base_cidr"]; attr == nil || attr.New != want {
+		t.Errorf("planned ipv6_base_cidr = %+v, want %s", attr, want)
+	}
+	if got := state.Attributes["base_cidr"]; got != "10.0.0.0/16" {
+		t.Errorf("base_cidr = %q, want 10.0.0.0/16", got)
+	}
+
+	// The same configuration generates the same prefix
+	if again := applyPool(t, nil, pool(true), meta()); again.Attributes["ipv6_base_cidr"] != want {
+		t.Errorf("ipv6_base_cidr on re-create = %q, want %q", again.Attributes["ipv6_base_cidr"], want)
+	}
+
+	if disabled := applyPool(t, nil, pool(false), meta()); disabled.Attributes["ipv6_base_cidr"] != "" {
+		t.Errorf("ipv6_base_cidr without use_ipv6_ula_base = %q, want empty", disabled.Attributes["ipv6_base_cidr"])
+	}
+}
+
+func TestResourceDocidrPoolCreate_RegionCIDRs(t *testing.T) {
+	meta := newFakeCombinedConfig(t, newFakeAccountMux([]interface{}{}, []interface{}{}))
+
+	state := applyPool(t, nil, map[string]interface{}{
+		"base_cidr": "10.0.0.0/16",
+		"allocation": []interface{}{
+			map[string]interface{}{"name": "nyc_vpc", "prefix_length": 20, "region": "nyc1"},
+			map[string]interface{}{"name": "sfo_vpc", "prefix_length": 20, "region": "sfo3"},
+			map[string]interface{}{"name": "nyc_cluster", "prefix_length": 20, "region": "nyc1"},
+			map[string]interface{}{"name": "shared", "prefix_length": 24},
+		},
+	}, meta)
+
+	want := map[string]string{
+		"region_cidrs.%":      "3",
+		"region_cidrs.nyc1":   state.Attributes["allocations.nyc_vpc"],
+		"region_cidrs.sfo3":   state.Attributes["allocations.sfo_vpc"],
+		"region_cidrs.global": state.Attributes["allocations.shared"],
+	}
+	for key, value := range want {
+		if got := state.Attributes[key]; got != value || got == "" {
+			t.Errorf("%s = %q, want %q", key, got, value)
+		}
+	}
+}
+
+func TestResourceDocidrPoolCreate_Groups(t *testing.T) {
+	srv := httptest.NewServer(newFakeAccountMux([]interface{}{}, []interface{}{}))
+	t.Cleanup(srv.Close)
+	meta := func() *config.CombinedConfig {
+		return newTestCombinedConfig(t, &config.Config{APIEndpoint: srv.URL + "/", ComputeAllocationsAtPlanTime: true})
+	}
+	raw := map[string]interface{}{
+		"base_cidr": "10.0.0.0/16",
+		"allocation": []interface{}{
+			map[string]interface{}{"name": "prod_vpc", "prefix_length": 20, "group": "production"},
+			map[string]interface{}{"name": "prod_k8s", "prefix_length": 20, "group": "production"},
+			map[string]interface{}{"name": "stage_vpc", "prefix_length": 24, "group": "staging"},
+			map[string]interface{}{"name": "shared", "prefix_length": 24},
+		},
+	}
+
+	diff, err := planPool(t, raw, meta())
+	if err != nil {
+		t.Fatalf("Diff() error = %v", err)
+	}
+	state := applyPool(t, nil, raw, meta())
+
+	want := map[string]string{
+		"groups.%":                   "3",
+		"groups.production":          "10.0.0.0/20,10.0.16.0/20",
+		"groups.staging":             "10.0.32.0/24",
+		"groups.default":             "10.0.33.0/24",
+		"group_summaries.%":          "3",
+		"group_summaries.production": "10.0.0.0/19",
+		"group_summaries.staging":    "10.0.32.0/24",
+		"group_summaries.default":    "10.0.33.0/24",
+	}
+	for key, value := range want {
+		if got := state.Attributes[key]; got != value {
+			t.Errorf("%s = %q, want %q", key, got, value)
+		}
+		if attr := diff.Attributes[key]; attr == nil || attr.New != value {
+			t.Errorf("planned %s = %+v, want %s", key, attr, value)
+		}
+	}
+
+	// Moving an allocation to another group updates the pool in place
+	raw["allocation"].([]interface{})[3].(map[string]interface{})["group"] = "staging"
+	replan, err := ResourceDocidrPool().Diff(context.Background(), state, terraform.NewResourceConfigRaw(raw), meta())
+	if err != nil {
+		t.Fatalf("Diff() error = %v", err)
+	}
+	if replan == nil || replan.RequiresNew() {
+		t.Errorf("Diff() after changing a group = %+v, want an in-place update", replan)
+	}
+}
+
+func TestResourceDocidrPoolCreate_UsableHosts(t *testing.T) {
+	srv := httptest.NewServer(newFakeAccountMux([]interface{}{}, []interface{}{}))
+	t.Cleanup(srv.Close)
+	meta := func() *config.CombinedConfig {
+		return newTestCombinedConfig(t, &config.Config{APIEndpoint: srv.URL + "/", ComputeAllocationsAtPlanTime: true})
+	}
+	raw := map[string]interface{}{
+		"base_cidr": "10.0.0.0/16",
+		"allocation": []interface{}{
+			map[string]interface{}{"name": "vpc", "prefix_length": 20},
+			map[string]interface{}{"name": "link", "prefix_length": 31},
+			map[string]interface{}{"name": "host", "prefix_length": 32},
+		},
+	}
+
+	diff, err := planPool(t, raw, meta())
+	if err != nil {
+		t.Fatalf("Diff() error = %v", err)
+	}
+	state := applyPool(t, nil, raw, meta())
+
+	want := map[string]string{
+		"allocations_first_usable.%":    "3",
+		"allocations_first_usable.vpc":  "10.0.0.1",
+		"allocations_last_usable.vpc":   "10.0.15.254",
+		"allocations_first_usable.link": "10.0.16.0",
+		"allocations_last_usable.link":  "10.0.16.1",
+		"allocations_first_usable.host": "10.0.16.2",
+		"allocations_last_usable.host":  "10.0.16.2",
+		"allocations_last_usable.%":     "3",
+	}
+	for key, value := range want {
+		if got := state.Attributes[key]; got != value {
+			t.Errorf("%s = %q, want %q", key, got, value)
+		}
+		if attr := diff.Attributes[key]; attr == nil || attr.New != value {
+			t.Errorf("planned %s = %+v, want %s", key, attr, value)
+		}
+	}
+
+	// State written before the maps existed gets them on refresh
+	old := state.DeepCopy()
+	for key := range old.Attributes {
+		if strings.HasPrefix(key, "allocations_first_usable.") || strings.HasPrefix(key, "allocations_last_usable.") {
+			delete(old.Attributes, key)
+		}
+	}
+	d := ResourceDocidrPool().Data(old)
+	if diags := resourceDocidrPoolRead(context.Background(), d, meta()); diags.HasError() {
+		t.Fatalf("Read() diags = %v", diags)
+	}
+	refreshed := d.State()
+	for key, value := range want {
+		if got := refreshed.Attributes[key]; got != value {
+			t.Errorf("%s after read = %q, want %q", key, got, value)
+		}
+	}
+}
+
+func TestResourceDocidrPoolCreate_ReverseZones(t *testing.T) {
+	srv := httptest.NewServer(newFakeAccountMux([]interface{}{}, []interface{}{}))
+	t.Cleanup(srv.Close)
+	meta := func() *config.CombinedConfig {
+		return newTestCombinedConfig(t, &config.Config{APIEndpoint: srv.URL + "/", ComputeAllocationsAtPlanTime: true})
+	}
+	raw := map[string]interface{}{
+		"base_cidr": "10.0.0.0/16",
+		"allocation": []interface{}{
+			map[string]interface{}{"name": "vpc", "prefix_length": 22},
+			map[string]interface{}{"name": "db", "prefix_length": 28},
+		},
+	}
+
+	diff, err := planPool(t, raw, meta())
+	if err != nil {
+		t.Fatalf("Diff() error = %v", err)
+	}
+	state := applyPool(t, nil, raw, meta())
+
+	want := map[string]string{
+		"allocations_reverse_zones.%":   "2",
+		"allocations_reverse_zones.vpc": "0.0.10.in-addr.arpa,1.0.10.in-addr.arpa,2.0.10.in-addr.arpa,3.0.10.in-addr.arpa",
+		"allocations_reverse_zones.db":  "4.0.10.in-addr.arpa",
+	}
+	for key, value := range want {
+		if got := state.Attributes[key]; got != value {
+			t.Errorf("%s = %q, want %q", key, got, value)
+		}
+		if attr := diff.Attributes[key]; attr == nil || attr.New != value {
+			t.Errorf("planned %s = %+v, want %s", key, attr, value)
+		}
+	}
+
+	// State written before the map existed gets it on refresh
+	old := state.DeepCopy()
+	for key := range old.Attributes {
+		if strings.HasPrefix(key, "allocations_reverse_zones.") {
+			delete(old.Attributes, key)
+		}
+	}
+	d := ResourceDocidrPool().Data(old)
+	if diags := resourceDocidrPoolRead(context.Background(), d, meta()); diags.HasError() {
+		t.Fatalf("Read() diags = %v", diags)
+	}
+	refreshed := d.State()
+	for key, value := range want {
+		if got := refreshed.Attributes[key]; got != value {
+			t.Errorf("%s after read = %q, want %q", key, got, value)
+		}
+	}
+}
+
+func TestResourceDocidrPoolCreate_AllocationHashIDs(t *testing.T) {
+	raw := map[string]interface{}{
+		"base_cidr": "10.0.0.0/16",
+		"allocation": []interface{}{
+			map[string]interface{}{"name": "vpc", "prefix_length": 20},
+			map[string]interface{}{"name": "db", "prefix_length": 24},
+		},
+	}
+	apply := func(vpcs []interface{}) (*terraform.InstanceDiff, *terraform.InstanceState) {
+		srv := httptest.NewServer(newFakeAccountMux(vpcs, []interface{}{}))
+		t.Cleanup(srv.Close)
+		meta := func() *config.CombinedConfig {
+			return newTestCombinedConfig(t, &config.Config{APIEndpoint: srv.URL + "/", ComputeAllocationsAtPlanTime: true})
+		}
+		diff, err := planPool(t, raw, meta())
+		if err != nil {
+			t.Fatalf("Diff() error = %v", err)
+		}
+		return diff, applyPool(t, nil, raw, meta())
+	}
+
+	diff, state := apply([]interface{}{})
+	for _, name := range []string{"vpc", "db"} {
+		key := "allocation_hash_ids." + name
+		want := allocationHashID(state.ID, name)
+		if got := state.Attributes[key]; got != want {
+			t.Errorf("%s = %q, want %q", key, got, want)
+		}
+		if attr := diff.Attributes[key]; attr == nil || attr.New != want {
+			t.Errorf("planned %s = %+v, want %s", key, attr, want)
+		}
+	}
+
+	// Recreating the pool with the same configuration keeps the IDs, even
+	// when a VPC created since moves the allocations
+	_, recreated := apply([]interface{}{
+		map[string]interface{}{"id": "vpc-1", "name": "existing", "ip_range": "10.0.0.0/20"},
+	})
+	if recreated.Attributes["allocations.vpc"] == state.Attributes["allocations.vpc"] {
+		t.Fatalf("allocations.vpc = %s after recreating, want it moved", recreated.Attributes["allocations.vpc"])
+	}
+	for _, name := range []string{"vpc", "db"} {
+		key := "allocation_hash_ids." + name
+		if recreated.Attributes[key] != state.Attributes[key] {
+			t.Errorf("%s = %q after recreating, want %q", key, recreated.Attributes[key], state.Attributes[key])
+		}
+	}
+
+	// State written before the map existed gets it on refresh
+	old := state.DeepCopy()
+	for key := range old.Attributes {
+		if strings.HasPrefix(key, "allocation_hash_ids.") {
+			delete(old.Attributes, key)
+		}
+	}
+	d := ResourceDocidrPool().Data(old)
+	if diags := resourceDocidrPoolRead(context.Background(), d, nil); diags.HasError() {
+		t.Fatalf("Read() diags = %v", diags)
+	}
+	if got := d.State().Attributes["allocation_hash_ids.vpc"]; got != state.Attributes["allocation_hash_ids.vpc"] {
+		t.Errorf("allocation_hash_ids.vpc after read = %q, want %q", got, state.Attributes["allocation_hash_ids.vpc"])
+	}
+}
+
+func TestResourceDocidrPool_PlanOnly(t *testing.T) {
+	srv := httptest.NewServer(newFakeAccountMux([]interface{}{}, []interface{}{}))
+	t.Cleanup(srv.Close)
+	meta := func() *config.CombinedConfig {
+		return newTestCombinedConfig(t, &config.Config{APIEndpoint: srv.URL + "/"})
+	}
+	raw := map[string]interface{}{
+		"base_cidr": "10.0.0.0/16",
+		"plan_only": true,
+		"allocation": []interface{}{
+			map[string]interface{}{"name": "vpc", "prefix_length": 20},
+		},
+	}
+
+	// The proposal is created with its allocations and a warning
+	r := ResourceDocidrPool()
+	diff, err := r.Diff(context.Background(), nil, terraform.NewResourceConfigRaw(raw), meta())
+	if err != nil {
+		t.Fatalf("Diff() error = %v", err)
+	}
+	state, diags := r.Apply(context.Background(), nil, diff, meta())
+	if diags.HasError() {
+		t.Fatalf("Apply() diags = %v", diags)
+	}
+	if got := state.Attributes["allocations.vpc"]; got != "10.0.0.0/20" {
+		t.Errorf("allocations.vpc = %q, want 10.0.0.0/20", got)
+	}
+	var warned bool
+	for _, d := range diags {
+		if d.Severity == diag.Warning && d.Summary == "docidr_pool is a proposal" {
+			warned = strings.Contains(d.Detail, "create_before_destroy")
+		}
+	}
+	if !warned {
+		t.Errorf("Apply() diags = %v, want a plan_only warning", diags)
+	}
+
+	// Planning it again, unchanged, replaces it
+	replan, err := r.Diff(context.Background(), state, terraform.NewResourceConfigRaw(raw), meta())
+	if err != nil {
+		t.Fatalf("Diff() error = %v", err)
+	}
+	if replan == nil || !replan.RequiresNew() {
+		t.Fatalf("Diff() with plan_only still set = %+v, want replacement", replan)
+	}
+	if attr := replan.Attributes["allocations.%"]; attr == nil || !attr.NewComputed || !attr.RequiresNew {
+		t.Errorf("planned allocations.%% = %+v, want it allocated again", attr)
+	}
+	if replaced := applyPool(t, state, raw, meta()); replaced.Attributes["allocations.vpc"] != "10.0.0.0/20" {
+		t.Errorf("allocations.vpc after replacement = %q, want 10.0.0.0/20", replaced.Attributes["allocations.vpc"])
+	}
+
+	// Setting plan_only to false replaces it with a pool that is kept
+	raw["plan_only"] = false
+	promote, err := r.Diff(context.Background(), state, terraform.NewResourceConfigRaw(raw), meta())
+	if err != nil {
+		t.Fatalf("Diff() error = %v", err)
+	}
+	if promote == nil || !promote.RequiresNew() {
+		t.Fatalf("Diff() after clearing plan_only = %+v, want replacement", promote)
+	}
+	kept := applyPool(t, state, raw, meta())
+	if again, err := r.Diff(context.Background(), kept, terraform.NewResourceConfigRaw(raw), meta()); err != nil {
+		t.Fatalf("Diff() error = %v", err)
+	} else if again != nil && !again.Empty() {
+		t.Errorf("Diff() without plan_only = %+v, want no changes", again)
+	}
+}
+
+func TestResourceDocidrPool_PlanOnlyStableAllocation(t *testing.T) {
+	empty := httptest.NewServer(newFakeAccountMux([]interface{}{}, []interface{}{}))
+	t.Cleanup(empty.Close)
+	// The VPC built from the proposed allocation
+	built := httptest.NewServer(newFakeAccountMux([]interface{}{
+		map[string]interface{}{"id": "vpc-1", "name": "prod", "ip_range": "10.0.0.0/24", "region": "nyc1"},
+	}, []interface{}{}))
+	t.Cleanup(built.Close)
+
+	for _, stable := range []bool{false, true} {
+		raw := map[string]interface{}{
+			"base_cidr":         "10.0.0.0/16",
+			"plan_only":         true,
+			"stable_allocation": stable,
+			"allocation": []interface{}{
+				map[string]interface{}{"name": "vpc", "prefix_length": 24},
+			},
+		}
+		state := applyPool(t, nil, raw, newTestCombinedConfig(t, &config.Config{APIEndpoint: empty.URL + "/"}))
+		replaced := applyPool(t, state, raw, newTestCombinedConfig(t, &config.Config{APIEndpoint: built.URL + "/"}))
+
+		want := "10.0.1.0/24"
+		if stable {
+			want = "10.0.0.0/24"
+		}
+		if got := replaced.Attributes["allocations.vpc"]; got != want {
+			t.Errorf("stable_allocation = %v: allocations.vpc after replacement = %q, want %q", stable, got, want)
+		}
+	}
+}
+
+func TestResourceDocidrPool_IdempotentID(t *testing.T) {
+	newMeta := func() *config.CombinedConfig {
+		return newFakeCombinedConfig(t, newFakeAccountMux([]interface{}{}, []interface{}{}))
+	}
+	config := func(idempotentID, baseCIDR string, names ...string) map[string]interface{} {
+		var allocations []interface{}
+		for _, name := range names {
+			allocations = append(allocations, map[string]interface{}{"name": name, "prefix_length": 24})
+		}
+		return map[string]interface{}{
+			"idempotent_id": idempotentID,
+			"base_cidr":     baseCIDR,
+			"allocation":    allocations,
+		}
+	}
+
+	state := applyPool(t, nil, config("cmdb42", "10.0.0.0/16", "vpc", "db"), newMeta())
+	if state.ID != "cmdb42" {
+		t.Fatalf("ID = %q, want the idempotent_id cmdb42", state.ID)
+	}
+
+	tests := []struct {
+		name        string
+		raw         map[string]interface{}
+		wantErr     string
+		wantReplace bool
+	}{
+		{
+			name: "unchanged",
+			raw:  config("cmdb42", "10.0.0.0/16", "vpc", "db"),
+		},
+		{
+			name:        "reordered allocations",
+			raw:         config("cmdb42", "10.0.0.0/16", "db", "vpc"),
+			wantReplace: true,
+		},
+		{
+			name: "grown base",
+			raw:  config("cmdb42", "10.0.0.0/12", "vpc", "db"),
+		},
+		{
+			name:    "new allocation",
+			raw:     config("cmdb42", "10.0.0.0/16", "vpc", "db", "cache"),
+			wantErr: `idempotent_id "cmdb42" is already used by this pool with allocations db, vpc`,
+		},
+		{
+			name:    "renamed allocation",
+			raw:     config("cmdb42", "10.0.0.0/16", "vpc", "database"),
+			wantErr: `idempotent_id "cmdb42" is already used by this pool with allocations db, vpc`,
+		},
+		{
+			name:    "moved base",
+			raw:     config("cmdb42", "10.1.0.0/16", "vpc", "db"),
+			wantErr: `idempotent_id "cmdb42" is already used by this pool with base_cidr 10.0.0.0/16`,
+		},
+		{
+			name:        "new idempotent_id",
+			raw:         config("cmdb43", "10.0.0.0/16", "vpc", "db", "cache"),
+			wantReplace: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diff, err := ResourceDocidrPool().Diff(context.Background(), state, terraform.NewResourceConfigRaw(tt.raw), newMeta())
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Diff() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Diff() error = %v", err)
+			}
+			if got := diff != nil && diff.RequiresNew(); got != tt.wantReplace {
+				t.Errorf("RequiresNew() = %v, want %v", got, tt.wantReplace)
+			}
+		})
+	}
+
+	// A replacement with the same base and names keeps the ID
+	raw := config("cmdb42", "10.0.0.0/16", "vpc", "db")
+	raw["exclude_patterns"] = []interface{}{"10.0.0.*"}
+	replaced := applyPool(t, state, raw, newMeta())
+	if replaced.ID != "cmdb42" {
+		t.Errorf("ID after replacement = %q, want cmdb42", replaced.ID)
+	}
+	if replaced.Attributes["allocations.vpc"] == state.Attributes["allocations.vpc"] {
+		t.Errorf("allocations.vpc = %s after replacement, want it moved out of the exclusion", replaced.Attributes["allocations.vpc"])
+	}
+
+	// Without an idempotent_id, the ID is generated
+	if generated := applyPool(t, nil, config("", "10.0.0.0/16", "vpc", "db"), newMeta()); generated.ID == "cmdb42" || generated.ID == "" {
+		t.Errorf("ID without idempotent_id = %q, want a generated ID", generated.ID)
+	}
+
+	validate := ResourceDocidrPool().Schema["idempotent_id"].ValidateFunc
+	for _, value := range []string{"", "cmdb-42", "cmdb 42", "cmdb_42"} {
+		if _, errs := validate(value, "idempotent_id"); len(errs) == 0 {
+			t.Errorf("idempotent_id %q is valid, want an error", value)
+		}
+	}
+	if _, errs := validate("Cmdb42", "idempotent_id"); len(errs) != 0 {
+		t.Errorf("idempotent_id Cmdb42 errors = %v, want none", errs)
+	}
+}
+
+func TestResourceDocidrPoolCreate_Visibility(t *testing.T) {
+	meta := newFakeCombinedConfig(t, newFakeAccountMux([]interface{}{}, []interface{}{}))
+
+	state := applyPool(t, nil, map[string]interface{}{
+		"base_cidr": "10.0.0.0/16",
+		"allocation": []interface{}{
+			map[string]interface{}{"name": "vpc", "prefix_length": 20},
+			map[string]interface{}{"name": "peering", "prefix_length": 24, "visibility": "external"},
+			map[string]interface{}{"name": "k8s", "prefix_length": 20, "visibility": "internal"},
+		},
+	}, meta)
+
+	want := map[string]string{
+		"internal_allocations.%":       "2",
+		"internal_allocations.vpc":     "10.0.0.0/20",
+		"internal_allocations.k8s":     "10.0.32.0/20",
+		"external_allocations.%":       "1",
+		"external_allocations.peering": "10.0.16.0/24",
+		"allocations.vpc":              "10.0.0.0/20",
+	}
+	for key, value := range want {
+		if got := state.Attributes[key]; got != value {
+			t.Errorf("%s = %q, want %q", key, got, value)
+		}
+	}
+}
+
+func TestResourceDocidrPoolCreate_AllocationBaseCIDR(t *testing.T) {
+	meta := newFakeCombinedConfig(t, newFakeAccountMux([]interface{}{}, []interface{}{}))
+
+	state := applyPool(t, nil, map[string]interface{}{
+		"base_cidr": "10.64.0.0/10",
+		"allocation": []interface{}{
+			map[string]interface{}{"name": "vpc", "prefix_length": 16},
+			map[string]interface{}{"name": "transit", "prefix_length": 24, "base_cidr": "172.31.0.0/16", "visibility": "internal"},
+			map[string]interface{}{"name": "k8s", "prefix_length": 16},
+		},
+	}, meta)
+
+	want := map[string]string{
+		"base_cidr":                     "10.64.0.0/10",
+		"allocations.%":                 "3",
+		"allocations.vpc":               "10.64.0.0/16",
+		"allocations.transit":           "172.31.0.0/24",
+		"allocations.k8s":               "10.65.0.0/16",
+		"utilization_percent":           "3.125",
+		"utilization_breakdown.%":       "2",
+		"state_valid":                   "true",
+		"internal_allocations.transit":  "172.31.0.0/24",
+		"utilization_breakdown.transit": "",
+	}
+	for key, value := range want {
+		if got := state.Attributes[key]; got != value {
+			t.Errorf("%s = %q, want %q", key, got, value)
+		}
+	}
+}
+
+func TestResourceDocidrPoolCreate_CIDRSubnet(t *testing.T) {
+	srv := httptest.NewServer(newFakeAccountMux([]interface{}{}, []interface{}{}))
+	t.Cleanup(srv.Close)
+	meta := func() *config.CombinedConfig {
+		return newTestCombinedConfig(t, &config.Config{APIEndpoint: srv.URL + "/", ComputeAllocationsAtPlanTime: true})
+	}
+	raw := map[string]interface{}{
+		"base_cidr": "10.64.0.0/10",
+		"allocation": []interface{}{
+			map[string]interface{}{"name": "vpc", "prefix_length": 16},
+			map[string]interface{}{"name": "transit", "prefix_length": 24, "base_cidr": "172.31.0.0/16"},
+			map[string]interface{}{"name": "k8s", "prefix_length": 20},
+		},
+	}
+
+	diff, err := planPool(t, raw, meta())
+	if err != nil {
+		t.Fatalf("Diff() error = %v", err)
+	}
+	r := ResourceDocidrPool()
+	state, diags := r.Apply(context.Background(), nil, diff, meta())
+	if diags.HasError() {
+		t.Fatalf("Apply() diags = %v", diags)
+	}
+
+	// transit isn't within the pool's base, so it is left out
+	want := map[string]string{
+		"allocations.vpc":                  "10.64.0.0/16",
+		"allocations.k8s":                  "10.65.0.0/20",
+		"allocations_cidrsubnet.#":         "2",
+		"allocations_cidrsubnet.0.name":    "vpc",
+		"allocations_cidrsubnet.0.newbits": "6",
+		"allocations_cidrsubnet.0.netnum":  "0",
+		"allocations_cidrsubnet.1.name":    "k8s",
+		"allocations_cidrsubnet.1.newbits": "10",
+		"allocations_cidrsubnet.1.netnum":  "16",
+	}
+	for key, value := range want {
+		if got := state.Attributes[key]; got != value {
+			t.Errorf("%s = %q, want %q", key, got, value)
+		}
+		if attr := diff.Attributes[key]; attr == nil || attr.New != value {
+			t.Errorf("planned %s = %+v, want %s", key, attr, value)
+		}
+	}
+
+	var warned bool
+	for _, d := range diags {
+		if d.Severity == diag.Warning && d.Summary == "Allocation not expressible with cidrsubnet" {
+			warned = strings.Contains(d.Detail, `"transit"`)
+		}
+	}
+	if !warned {
+		t.Errorf("Apply() diags = %v, want a warning about transit", diags)
+	}
+}
+
+func TestResourceDocidrPool_RandomPlacement(t *testing.T) {
+	srv := httptest.NewServer(newFakeAccountMux([]interface{}{}, []interface{}{}))
+	t.Cleanup(srv.Close)
+	raw := map[string]interface{}{
+		"base_cidr": "10.0.0.0/8",
+		"placement": "random",
+		"allocation": []interface{}{
+			map[string]interface{}{"name": "vpc", "prefix_length": 16},
+			map[string]interface{}{"name": "k8s", "prefix_length": 20},
+		},
+	}
+
+	// The plan-time allocation picks the same blocks as the apply
+	meta := newTestCombinedConfig(t, &config.Config{APIEndpoint: srv.URL + "/", ComputeAllocationsAtPlanTime: true})
+	diff, err := planPool(t, raw, meta)
+	if err != nil {
+		t.Fatalf("Diff() error = %v", err)
+	}
+	state := applyPool(t, nil, raw, newTestCombinedConfig(t, &config.Config{APIEndpoint: srv.URL + "/"}))
+	for _, name := range []string{"vpc", "k8s"} {
+		got := state.Attributes["allocations."+name]
+		if attr := diff.Attributes["allocations."+name]; attr == nil || attr.New != got {
+			t.Errorf("planned allocations.%s = %+v, applied %s", name, attr, got)
+		}
+	}
+	if state.Attributes["allocations.vpc"] == "10.0.0.0/16" {
+		t.Error("allocations.vpc = 10.0.0.0/16, want a randomly placed block")
+	}
+
+	// A salt moves an otherwise identical pool, without changing its ID
+	salted := map[string]interface{}{"placement_salt": "staging"}
+	for k, v := range raw {
+		salted[k] = v
+	}
+	saltedState := applyPool(t, nil, salted, newTestCombinedConfig(t, &config.Config{APIEndpoint: srv.URL + "/"}))
+	if saltedState.Attributes["allocations.vpc"] == state.Attributes["allocations.vpc"] {
+		t.Errorf("allocations.vpc with placement_salt = %s, want it to differ from the unsalted pool", saltedState.Attributes["allocations.vpc"])
+	}
+	if saltedState.ID != state.ID {
+		t.Errorf("ID with placement_salt = %s, want %s", saltedState.ID, state.ID)
+	}
+}
+
+func TestResourceDocidrPoolCreate_RouteTable(t *testing.T) {
+	meta := newFakeCombinedConfig(t, newFakeAccountMux([]interface{}{}, []interface{}{}))
+
+	state := applyPool(t, nil, map[string]interface{}{
+		"base_cidr": "10.0.0.0/8",
+		"allocation": []interface{}{
+			map[string]interface{}{"name": "vpc_hub", "prefix_length": 16},
+			map[string]interface{}{"name": "nyc1_vpc", "prefix_length": 16, "region": "nyc1"},
+			map[string]interface{}{"name": "sfo3_vpc", "prefix_length": 16, "region": "sfo3"},
+		},
+	}, meta)
+
+	want := map[string]string{
+		"route_table.#":                  "2",
+		"route_table.0.allocation_name":  "nyc1_vpc",
+		"route_table.0.destination_cidr": "10.2.0.0/16",
+		"route_table.0.via_cidr":         "10.0.0.1/32",
+		"route_table.1.allocation_name":  "sfo3_vpc",
+		"route_table.1.destination_cidr": "10.1.0.0/16",
+		"route_table.1.via_cidr":         "10.0.0.1/32",
+	}
+	for key, value := range want {
+		if got := state.Attributes[key]; got != value {
+			t.Errorf("%s = %q, want %q", key, got, value)
+		}
+	}
+}
+
+func TestResourceDocidrPool_RouteTableHub(t *testing.T) {
+	newMeta := func() *config.CombinedConfig {
+		return newFakeCombinedConfig(t, newFakeAccountMux([]interface{}{}, []interface{}{}))
+	}
+	config := func(hub string) map[string]interface{} {
+		var allocations []interface{}
+		for _, name := range []string{"transit", "app", "db"} {
+			allocations = append(allocations, map[string]interface{}{"name": name, "prefix_length": 16, "hub": name == hub})
+		}
+		return map[string]interface{}{"base_cidr": "10.0.0.0/8", "allocation": allocations}
+	}
+
+	// No allocation is named vpc, so only an explicit hub gives routes
+	if state := applyPool(t, nil, config(""), newMeta()); state.Attributes["route_table.#"] != "0" {
+		t.Errorf("route_table.# without a hub = %s, want 0", state.Attributes["route_table.#"])
+	}
+	state := applyPool(t, nil, config("transit"), newMeta())
+	if got := state.Attributes["route_table.0.via_cidr"]; got != "10.0.0.1/32" {
+		t.Errorf("route_table.0.via_cidr = %q, want the transit hub's 10.0.0.1/32", got)
+	}
+
+	// Moving the hub updates the pool in place
+	diff, err := ResourceDocidrPool().Diff(context.Background(), state, terraform.NewResourceConfigRaw(config("db")), newMeta())
+	if err != nil {
+		t.Fatalf("Diff() error = %v", err)
+	}
+	if diff == nil || diff.RequiresNew() {
+		t.Fatalf("Diff() with a new hub = %v, want an in-place update", diff)
+	}
+	moved := applyPool(t, state, config("db"), newMeta())
+	if moved.ID != state.ID {
+		t.Errorf("ID = %s after moving the hub, want %s", moved.ID, state.ID)
+	}
+	if got := moved.Attributes["route_table.0.via_cidr"]; got != "10.2.0.1/32" {
+		t.Errorf("route_table.0.via_cidr = %q, want the db hub's 10.2.0.1/32", got)
+	}
+
+	// There is only one hub
+	twoHubs := config("transit")
+	twoHubs["allocation"].([]interface{})[2].(map[string]interface{})["hub"] = true
+	var pathErr cty.PathError
+	if _, err := planPool(t, twoHubs, newMeta()); !errors.As(err, &pathErr) || !pathErr.Path.Equals(allocationPath(2, "hub")) {
+		t.Errorf("Diff() with two hubs error = %#v, want a cty.PathError at allocation.2.hub", err)
+	}
+}
+
+func TestResourceDocidrPoolCreate_AllocationExcludeCIDRs(t *testing.T) {
+	meta := newFakeCombinedConfig(t, newFakeAccountMux([]interface{}{}, []interface{}{}))
+
+	state := applyPool(t, nil, map[string]interface{}{
+		"base_cidr": "10.96.0.0/11",
+		"allocation": []interface{}{
+			map[string]interface{}{"name": "doks_services", "prefix_length": 16, "exclude_cidrs": []interface{}{"10.96.0.0/12"}},
+			map[string]interface{}{"name": "vpc", "prefix_length": 16},
+		},
+	}, meta)
+
+	want := map[string]string{
+		"allocations.doks_services": "10.112.0.0/16",
+		"allocations.vpc":           "10.96.0.0/16",
+	}
+	for key, value := range want {
+		if got := state.Attributes[key]; got != value {
+			t.Errorf("%s = %q, want %q", key, got, value)
+		}
+	}
+
+	requests := expandAllocations([]interface{}{
+		map[string]interface{}{"name": "a", "prefix_length": 16, "exclude_cidrs": []interface{}{"10.96.0.0/12", "10.80.0.0/12"}},
+	}, false)
+	reordered := expandAllocations([]interface{}{
+		map[string]interface{}{"name": "a", "prefix_length": 16, "exclude_cidrs": []interface{}{"10.80.0.0/12", "10.96.0.0/12"}},
+	}, false)
+	if !sameAllocationRequests(requests, reordered) {
+		t.Error("sameAllocationRequests() = false for reordered exclude_cidrs, want true")
+	}
+	if generateResourceID("10.0.0.0/8", requests, nil, "") != generateResourceID("10.0.0.0/8", reordered, nil, "") {
+		t.Error("generateResourceID() changed when exclude_cidrs were reordered")
+	}
+	if generateResourceID("10.0.0.0/8", requests, nil, "") == generateResourceID("10.0.0.0/8", []cidr.AllocationRequest{{Name: "a", PrefixLength: 16}}, nil, "") {
+		t.Error("generateResourceID() didn't change with exclude_cidrs")
+	}
+}
+
+func TestResourceDocidrPoolCustomizeDiff_Visibility(t *testing.T) {
+	meta := newFakeCombinedConfig(t, newFakeAccountMux([]interface{}{}, []interface{}{}))
+
+	tests := []struct {
+		name      string
+		baseCIDR  string
+		wantError string
+	}{
+		{name: "internal", baseCIDR: "10.0.0.0/16"},
+		{name: "external", baseCIDR: "10.0.0.0/16"},
+		{name: "external", baseCIDR: "100.64.0.0/10", wantError: `allocation "a" is external but base_cidr 100.64.0.0/10 is not RFC 1918 address space`},
+		{name: "internal", baseCIDR: "192.168.0.0/16", wantError: `allocation "a" is internal but base_cidr 192.168.0.0/16 is within 192.168.0.0/16`},
+		{name: "external", baseCIDR: "192.168.0.0/16"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name+" in "+tt.baseCIDR, func(t *testing.T) {
+			_, err := planPool(t, map[string]interface{}{
+				"base_cidr": tt.baseCIDR,
+				"allocation": []interface{}{
+					map[string]interface{}{"name": "a", "prefix_length": 24, "visibility": tt.name},
+				},
+			}, meta)
+			if tt.wantError == "" {
+				if err != nil {
+					t.Errorf("Diff() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantError) {
+				t.Errorf("Diff() error = %v, want %q", err, tt.wantError)
+			}
+		})
+	}
+
+	// Allocations without a visibility are internal
+	_, err := planPool(t, map[string]interface{}{
+		"base_cidr": "192.168.0.0/16",
+		"allocation": []interface{}{
+			map[string]interface{}{"name": "a", "prefix_length": 24},
+		},
+	}, meta)
+	if want := `allocation "a" is internal but base_cidr 192.168.0.0/16 is within 192.168.0.0/16`; err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("Diff() without a visibility error = %v, want %q", err, want)
+	}
+}
+
+func TestResourceDocidrPoolCreate_TagRecommendations(t *testing.T) {
+	meta := newFakeCombinedConfig(t, newFakeAccountMux([]interface{}{}, []interface{}{}))
+
+	state := applyPool(t, nil, map[string]interface{}{
+		"base_cidr":            "10.0.0.0/16",
+		"auto_tag_allocations": []interface{}{"env:prod", "managed-by-docidr"},
+		"allocation": []interface{}{
+			map[string]interface{}{"name": "vpc", "prefix_length": 20},
+			map[string]interface{}{"name": "k8s", "prefix_length": 20},
+		},
+	}, meta)
+
+	want := map[string]string{
+		"tag_recommendations.%":   "2",
+		"tag_recommendations.vpc": "env:prod,managed-by-docidr",
+		"tag_recommendations.k8s": "env:prod,managed-by-docidr",
+	}
+	for key, value := range want {
+		if got := state.Attributes[key]; got != value {
+			t.Errorf("%s = %q, want %q", key, got, value)
+		}
+	}
+}
+
+func TestResourceDocidrPoolCreate_Digest(t *testing.T) {
+	meta := newFakeCombinedConfig(t, newFakeAccountMux([]interface{}{}, []interface{}{}))
+	raw := map[string]interface{}{
+		"base_cidr": "10.0.0.0/16",
+		"allocation": []interface{}{
+			map[string]interface{}{"name": "vpc", "prefix_length": 20},
+			map[string]interface{}{"name": "k8s", "prefix_length": 20},
+		},
+	}
+
+	state := applyPool(t, nil, raw, meta)
+	want := allocationsDigest(map[string]string{
+		"vpc": state.Attributes["allocations.vpc"],
+		"k8s": state.Attributes["allocations.k8s"],
+	})
+	if got := state.Attributes["digest"]; got != want {
+		t.Errorf("digest = %q, want %q", got, want)
+	}
+
+	// An exclusion that doesn't move any allocation replaces the pool but
+	// keeps the digest
+	raw["exclude"] = []interface{}{
+		map[string]interface{}{"cidr": "10.0.255.0/24", "reason": "reserved"},
+	}
+	replaced := applyPool(t, nil, raw, newFakeCombinedConfig(t, newFakeAccountMux([]interface{}{}, []interface{}{})))
+	if replaced.ID == state.ID {
+		t.Fatalf("ID unchanged after adding an exclusion")
+	}
+	if got := replaced.Attributes["digest"]; got != want {
+		t.Errorf("digest after replacement = %q, want %q", got, want)
+	}
+}
+
+func TestResourceDocidrPoolCreate_APIMetrics(t *testing.T) {
+	mux := http.NewServeMux()
+	servePages(mux, "/v2/vpcs", "vpcs",
+		[]interface{}{map[string]interface{}{"id": "vpc-1", "ip_range": "10.0.0.0/24"}},
+		[]interface{}{map[string]interface{}{"id": "vpc-2", "ip_range": "10.0.1.0/24"}},
+		[]interface{}{map[string]interface{}{"id": "vpc-3", "ip_range": "10.0.2.0/24"}},
+	)
+	servePages(mux, "/v2/kubernetes/clusters", "kubernetes_clusters", nil)
+	meta := newFakeCombinedConfig(t, mux)
+
+	state := applyPool(t, nil, map[string]interface{}{
+		"base_cidr": "10.0.0.0/16",
+		"allocation": []interface{}{
+			map[string]interface{}{"name": "vpc", "prefix_length": 24},
+		},
+	}, meta)
+
+	want := map[string]string{
+		"scan_report.0.api_metrics.#":                               "1",
+		"scan_report.0.api_metrics.0.calls":                         "4",
+		"scan_report.0.api_metrics.0.retries":                       "0",
+		"scan_report.0.api_metrics.0.pages.%":                       "2",
+		"scan_report.0.api_metrics.0.pages./v2/vpcs":                "3",
+		"scan_report.0.api_metrics.0.pages./v2/kubernetes/clusters": "1",
+		"scan_report.0.api_metrics.0.rate_limit_remaining":          "-1",
+	}
+	for key, value := range want {
+		if got := state.Attributes[key]; got != value {
+			t.Errorf("%s = %q, want %q", key, got, value)
+		}
+	}
+}
+
+func TestResourceDocidrPoolCustomizeDiff_BaseCIDRExpansion(t *testing.T) {
+	mux := newFakeAccountMux(
+		[]interface{}{
+			map[string]interface{}{"id": "vpc-1", "name": "prod", "ip_range": "10.0.0.0/25", "region": "nyc1"},
+		},
+		[]interface{}{},
+	)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	meta := newTestCombinedConfig(t, &config.Config{
+		APIEndpoint:                  srv.URL + "/",
+		ComputeAllocationsAtPlanTime: true,
+	})
+
+	raw := map[string]interface{}{
+		"base_cidr":           "10.0.0.0/24",
+		"base_cidr_expansion": true,
+		"allocation": []interface{}{
+			map[string]interface{}{"name": "a", "prefix_length": 25},
+			map[string]interface{}{"name": "b", "prefix_length": 25},
+		},
+	}
+
+	diff, err := planPool(t, raw, meta)
+	if err != nil {
+		t.Fatalf("Diff() error = %v", err)
+	}
+	if attr := diff.Attributes["base_cidr"]; attr == nil || attr.New != "10.0.0.0/23" {
+		t.Errorf("base_cidr = %+v, want 10.0.0.0/23", attr)
+	}
+	for name, want := range map[string]string{"a": "10.0.0.128/25", "b": "10.0.1.0/25"} {
+		if attr := diff.Attributes["allocations."+name]; attr == nil || attr.New != want {
+			t.Errorf("allocations.%s = %+v, want %s", name, attr, want)
+		}
+	}
+
+	raw["base_cidr_expansion"] = false
+	if _, err := planPool(t, raw, meta); err == nil || !strings.Contains(err.Error(), "no available space") {
+		t.Errorf("Diff() error = %v, want no available space", err)
+	}
+}
+
+func TestResourceDocidrPoolCustomizeDiff_ComputeAllocationsAtPlanTimeError(t *testing.T) {
+	mux := http.NewServeMux()
+	serveError(mux, "/v2/vpcs", http.StatusInternalServerError)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	meta := newTestCombinedConfig(t, &config.Config{
+		APIEndpoint:                  srv.URL + "/",
+		ComputeAllocationsAtPlanTime: true,
+	})
+
+	raw := map[string]interface{}{
+		"allocation": []interface{}{
+			map[string]interface{}{"name": "vpc", "prefix_length": 16},
+		},
+	}
+	if _, err := planPool(t, raw, meta); err == nil {
+		t.Fatal("expected plan to fail when the account can't be scanned")
+	}
+}
+
+func TestCheckPlannedAllocations(t *testing.T) {
+	results := map[string]string{"vpc": "10.1.0.0/16", "k8s": "10.2.0.0/20"}
+
+	if err := checkPlannedAllocations(map[string]interface{}{"vpc": "10.1.0.0/16", "k8s": "10.2.0.0/20"}, results); err != nil {
+		t.Errorf("unexpected error for matching allocations: %v", err)
+	}
+
+	err := checkPlannedAllocations(map[string]interface{}{"vpc": "10.0.0.0/16", "k8s": "10.2.0.0/20"}, results)
+	if err == nil {
+		t.Fatal("expected error for changed allocations")
+	}
+	if !strings.Contains(err.Error(), "vpc (planned 10.0.0.0/16, now 10.1.0.0/16)") {
+		t.Errorf("error = %v, want it to name the changed allocation", err)
+	}
+}
+
+func TestResourceDocidrPoolCustomizeDiff_AutoGenerateNames(t *testing.T) {
+	allocations := []interface{}{
+		map[string]interface{}{"prefix_length": 24},
+		map[string]interface{}{"prefix_length": 24},
+	}
+
+	if _, err := planPool(t, map[string]interface{}{"allocation": allocations}, nil); err == nil || !strings.Contains(err.Error(), "name is required") {
+		t.Errorf("Diff() error = %v, want missing name error", err)
+	}
+
+	raw := map[string]interface{}{
+		"auto_generate_names": true,
+		"allocation":          allocations,
+	}
+	if _, err := planPool(t, raw, nil); err != nil {
+		t.Errorf("Diff() error = %v", err)
+	}
+}
+
+func TestResourceDocidrPoolCustomizeDiff_StableAllocation(t *testing.T) {
+	srv := httptest.NewServer(newFakeAccountMux([]interface{}{}, []interface{}{}))
+	t.Cleanup(srv.Close)
+	// Each step gets its own provider instance, as in separate Terraform runs
+	newMeta := func() *config.CombinedConfig {
+		return newTestCombinedConfig(t, &config.Config{APIEndpoint: srv.URL + "/"})
+	}
+
+	allocation := func(name string) map[string]interface{} {
+		return map[string]interface{}{"name": name, "prefix_length": 24}
+	}
+	pool := func(stable bool, allocations ...interface{}) map[string]interface{} {
+		return map[string]interface{}{
+			"base_cidr":         "10.0.0.0/16",
+			"stable_allocation": stable,
+			"allocation":        allocations,
+		}
+	}
+
+	tests := []struct {
+		name   string
+		stable bool
+		want   map[string]string
+	}{
+		{
+			name:   "stable",
+			stable: true,
+			want:   map[string]string{"a": "10.0.0.0/24", "b": "10.0.1.0/24", "c": "10.0.2.0/24"},
+		},
+		{
+			name: "not stable",
+			want: map[string]string{"c": "10.0.0.0/24", "b": "10.0.1.0/24", "a": "10.0.2.0/24"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := applyPool(t, nil, pool(tt.stable, allocation("a"), allocation("b")), newMeta())
+
+			// Reordering alone doesn't replace a stable pool
+			r := ResourceDocidrPool()
+			diff, err := r.Diff(context.Background(), state, terraform.NewResourceConfigRaw(pool(tt.stable, allocation("b"), allocation("a"))), newMeta())
+			if err != nil {
+				t.Fatalf("Diff() error = %v", err)
+			}
+			if replaced := diff != nil && diff.RequiresNew(); replaced == tt.stable {
+				t.Errorf("reorder RequiresNew() = %v, want %v", replaced, !tt.stable)
+			}
+
+			// Adding an allocation replaces the pool; a stable pool keeps
+			// the existing allocations where they were
+			state = applyPool(t, state, pool(tt.stable, allocation("c"), allocation("b"), allocation("a")), newMeta())
+			for name, want := range tt.want {
+				if got := state.Attributes["allocations."+name]; got != want {
+					t.Errorf("allocations.%s = %s, want %s", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestResourceDocidrPoolRead_StateValid(t *testing.T) {
+	srv := httptest.NewServer(newFakeAccountMux([]interface{}{}, []interface{}{}))
+	t.Cleanup(srv.Close)
+	newMeta := func() *config.CombinedConfig {
+		return newTestCombinedConfig(t, &config.Config{APIEndpoint: srv.URL + "/"})
+	}
+
+	raw := map[string]interface{}{
+		"base_cidr": "10.0.0.0/16",
+		"allocation": []interface{}{
+			map[string]interface{}{"name": "a", "prefix_length": 24},
+			map[string]interface{}{"name": "b", "prefix_length": 24},
+		},
+	}
+	r := ResourceDocidrPool()
+	read := func(state *terraform.InstanceState) *terraform.InstanceState {
+		t.Helper()
+		d := r.Data(state)
+		if diags := resourceDocidrPoolRead(context.Background(), d, newMeta()); diags.HasError() {
+			t.Fatalf("Read() diags = %v", diags)
+		}
+		return d.State()
+	}
+	requiresNew := func(state *terraform.InstanceState) bool {
+		t.Helper()
+		diff, err := r.Diff(context.Background(), state, terraform.NewResourceConfigRaw(raw), newMeta())
+		if err != nil {
+			t.Fatalf("Diff() error = %v", err)
+		}
+		return diff != nil && diff.RequiresNew()
+	}
+
+	state := applyPool(t, nil, raw, newMeta())
+	if got := state.Attributes["state_valid"]; got != "true" {
+		t.Errorf("state_valid after create = %q, want true", got)
+	}
+	state = read(state)
+	if got := state.Attributes["state_valid"]; got != "true" {
+		t.Errorf("state_valid after read = %q, want true", got)
+	}
+	if requiresNew(state) {
+		t.Error("valid state RequiresNew() = true, want false")
+	}
+
+	// A manual edit makes the allocations overlap
+	corrupt := state.DeepCopy()
+	corrupt.Attributes["allocations.b"] = "10.0.0.128/25"
+
+	// Without a refresh, the plan still replaces the pool
+	if !requiresNew(corrupt) {
+		t.Error("unrefreshed invalid state RequiresNew() = false, want true")
+	}
+
+	corrupt = read(corrupt)
+	if got := corrupt.Attributes["state_valid"]; got != "false" {
+		t.Errorf("state_valid after read = %q, want false", got)
+	}
+	if !requiresNew(corrupt) {
+		t.Error("invalid state RequiresNew() = false, want true")
+	}
+}
+
+func TestResourceDocidrPoolCustomizeDiff_StableAllocationPrefixRange(t *testing.T) {
+	srv := httptest.NewServer(newFakeAccountMux([]interface{}{}, []interface{}{}))
+	t.Cleanup(srv.Close)
+	newMeta := func() *config.CombinedConfig {
+		return newTestCombinedConfig(t, &config.Config{APIEndpoint: srv.URL + "/"})
+	}
+
+	allocation := func(name string, min, max int) map[string]interface{} {
+		return map[string]interface{}{
+			"name":                 name,
+			"prefix_length":        24,
+			"allowed_prefix_range": []interface{}{map[string]interface{}{"min": min, "max": max}},
+		}
+	}
+	pool := func(allocations ...interface{}) map[string]interface{} {
+		return map[string]interface{}{
+			"base_cidr":         "10.0.0.0/16",
+			"stable_allocation": true,
+			"allocation":        allocations,
+		}
+	}
+
+	state := applyPool(t, nil, pool(allocation("a", 20, 24), allocation("b", 24, 28)), newMeta())
+
+	tests := []struct {
+		name         string
+		config       map[string]interface{}
+		wantReplaced bool
+	}{
+		{name: "reordered", config: pool(allocation("b", 24, 28), allocation("a", 20, 24))},
+		{name: "range changed", config: pool(allocation("a", 20, 24), allocation("b", 22, 28)), wantReplaced: true},
+		{name: "ranges swapped", config: pool(allocation("b", 20, 24), allocation("a", 24, 28)), wantReplaced: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diff, err := ResourceDocidrPool().Diff(context.Background(), state, terraform.NewResourceConfigRaw(tt.config), newMeta())
+			if err != nil {
+				t.Fatalf("Diff() error = %v", err)
+			}
+			if replaced := diff != nil && diff.RequiresNew(); replaced != tt.wantReplaced {
+				t.Errorf("RequiresNew() = %v, want %v", replaced, tt.wantReplaced)
+			}
+		})
+	}
+}
+
+func TestExpandReservations(t *testing.T) {
+	allocator, err := cidr.NewAllocator("10.0.0.0/16")
+	if err != nil {
+		t.Fatalf("NewAllocator() error = %v", err)
+	}
+	previous := map[string]interface{}{
+		"kept":      "10.0.0.0/24",
+		"resized":   "10.0.1.0/24",
+		"outside":   "10.1.0.0/24",
+		"excluded":  "10.0.2.0/24",
+		"not_asked": "10.0.3.0/24",
+	}
+	requests := []cidr.AllocationRequest{
+		{Name: "kept", PrefixLength: 24},
+		{Name: "resized", PrefixLength: 23},
+		{Name: "outside", PrefixLength: 24},
+		{Name: "excluded", PrefixLength: 24},
+		{Name: "new", PrefixLength: 24},
+	}
+
+	got, moved := expandReservations(previous, requests, allocator, []*net.IPNet{mustParseTestCIDR(t, "10.0.2.0/25")})
+	if len(got) != 1 || got[0].Name != "kept" || got[0].CIDR.String() != "10.0.0.0/24" {
+		t.Errorf("expandReservations() = %+v, want only kept at 10.0.0.0/24", got)
+	}
+	var movedNames []string
+	for _, m := range moved {
+		movedNames = append(movedNames, m.Name)
+	}
+	if want := []string{"resized", "outside", "excluded"}; !reflect.DeepEqual(movedNames, want) {
+		t.Errorf("expandReservations() moved = %v, want %v", movedNames, want)
+	}
+}
+
+func TestResourceDocidrPool_StableAllocationExclusionChange(t *testing.T) {
+	srv := httptest.NewServer(newFakeAccountMux([]interface{}{}, []interface{}{}))
+	t.Cleanup(srv.Close)
+	newMeta := func() *config.CombinedConfig {
+		return newTestCombinedConfig(t, &config.Config{APIEndpoint: srv.URL + "/"})
+	}
+	pool := func(exclude ...string) map[string]interface{} {
+		var blocks []interface{}
+		for _, c := range exclude {
+			blocks = append(blocks, map[string]interface{}{"cidr": c})
+		}
+		return map[string]interface{}{
+			"base_cidr":         "10.0.0.0/16",
+			"stable_allocation": true,
+			"exclude":           blocks,
+			"allocation": []interface{}{
+				map[string]interface{}{"name": "a", "prefix_length": 24},
+				map[string]interface{}{"name": "b", "prefix_length": 24},
+			},
+		}
+	}
+	apply := func(state *terraform.InstanceState, raw map[string]interface{}) (*terraform.InstanceState, diag.Diagnostics) {
+		t.Helper()
+		meta := newMeta()
+		r := ResourceDocidrPool()
+		diff, err := r.Diff(context.Background(), state, terraform.NewResourceConfigRaw(raw), meta)
+		if err != nil {
+			t.Fatalf("Diff() error = %v", err)
+		}
+		newState, diags := r.Apply(context.Background(), state, diff, meta)
+		if diags.HasError() {
+			t.Fatalf("Apply() diags = %v", diags)
+		}
+		return newState, diags
+	}
+
+	first, _ := apply(nil, pool("10.0.0.0/24"))
+	if a, b := first.Attributes["allocations.a"], first.Attributes["allocations.b"]; a != "10.0.1.0/24" || b != "10.0.2.0/24" {
+		t.Fatalf("allocations = %s, %s, want 10.0.1.0/24, 10.0.2.0/24", a, b)
+	}
+
+	// Removing the exclusion frees 10.0.0.0/24 but moves nothing
+	state, diags := apply(first, pool())
+	if a, b := state.Attributes["allocations.a"], state.Attributes["allocations.b"]; a != "10.0.1.0/24" || b != "10.0.2.0/24" {
+		t.Errorf("after removing the exclusion allocations = %s, %s, want unchanged", a, b)
+	}
+	if len(diags) != 0 {
+		t.Errorf("after removing the exclusion diags = %v, want none", diags)
+	}
+
+	// Excluding a's block instead moves only a, with a warning saying why
+	state, diags = apply(first, pool("10.0.1.0/24"))
+	if a, b := state.Attributes["allocations.a"], state.Attributes["allocations.b"]; a != "10.0.0.0/24" || b != "10.0.2.0/24" {
+		t.Errorf("after excluding a allocations = %s, %s, want 10.0.0.0/24, 10.0.2.0/24", a, b)
+	}
+	if len(diags) != 1 || diags[0].Summary != "Allocation moved" ||
+		!strings.Contains(diags[0].Detail, `"a" couldn't keep its previous CIDR 10.0.1.0/24 because it overlaps exclusion 10.0.1.0/24, so it was allocated 10.0.0.0/24`) {
+		t.Errorf("after excluding a diags = %v, want an Allocation moved warning for a", diags)
+	}
+}
+
+func TestResourceDocidrPool_SizePresets(t *testing.T) {
+	srv := httptest.NewServer(newFakeAccountMux([]interface{}{}, []interface{}{}))
+	t.Cleanup(srv.Close)
+	newMeta := func(presets map[string]int) *config.CombinedConfig {
+		return newTestCombinedConfig(t, &config.Config{APIEndpoint: srv.URL + "/", SizePresets: presets})
+	}
+	pool := func(allocations ...interface{}) map[string]interface{} {
+		return map[string]interface{}{
+			"base_cidr":  "10.0.0.0/8",
+			"allocation": allocations,
+		}
+	}
+	presets := map[string]int{"small": 24, "medium": 20, "large": 16}
+
+	state := applyPool(t, nil, pool(
+		map[string]interface{}{"name": "vpc", "size": "large"},
+		map[string]interface{}{"name": "k8s", "size": "medium"},
+		map[string]interface{}{"name": "db", "prefix_length": 24},
+	), newMeta(presets))
+
+	want := map[string]string{
+		"allocations.vpc":               "10.0.0.0/16",
+		"allocations.k8s":               "10.1.0.0/20",
+		"allocations.db":                "10.1.16.0/24",
+		"allocation_prefix_lengths.%":   "3",
+		"allocation_prefix_lengths.vpc": "16",
+		"allocation_prefix_lengths.k8s": "20",
+		"allocation_prefix_lengths.db":  "24",
+	}
+	for key, value := range want {
+		if got := state.Attributes[key]; got != value {
+			t.Errorf("%s = %q, want %q", key, got, value)
+		}
+	}
+	if !strings.Contains(state.Attributes["allocations_json"], `"prefix_length": 16`) {
+		t.Errorf("allocations_json = %s, want the resolved prefix length of vpc", state.Attributes["allocations_json"])
+	}
+
+	// plan diffs the pool against new allocation blocks, given as name,
+	// size and prefix length. Terraform sends the configuration along with
+	// the state, which is how the suppressed sizes are read.
+	type block struct {
+		name         string
+		size         string
+		prefixLength int
+	}
+	plan := func(presets map[string]int, blocks ...block) *terraform.InstanceDiff {
+		t.Helper()
+		var allocations []interface{}
+		var rawBlocks []cty.Value
+		for _, b := range blocks {
+			m := map[string]interface{}{"name": b.name}
+			size, prefixLength := cty.NullVal(cty.String), cty.NullVal(cty.Number)
+			if b.size != "" {
+				m["size"], size = b.size, cty.StringVal(b.size)
+			}
+			if b.prefixLength != 0 {
+				m["prefix_length"], prefixLength = b.prefixLength, cty.NumberIntVal(int64(b.prefixLength))
+			}
+			allocations = append(allocations, m)
+			rawBlocks = append(rawBlocks, cty.ObjectVal(map[string]cty.Value{
+				"name":          cty.StringVal(b.name),
+				"size":          size,
+				"prefix_length": prefixLength,
+			}))
+		}
+
+		prior := state.DeepCopy()
+		prior.RawConfig = cty.ObjectVal(map[string]cty.Value{
+			"base_cidr":  cty.StringVal("10.0.0.0/8"),
+			"allocation": cty.ListVal(rawBlocks),
+		})
+		diff, err := ResourceDocidrPool().Diff(context.Background(), prior, terraform.NewResourceConfigRaw(pool(allocations...)), newMeta(presets))
+		if err != nil {
+			t.Fatalf("Diff() error = %v", err)
+		}
+		return diff
+	}
+
+	// A renamed preset of the same size, or the same size given as a
+	// prefix length, keeps the pool
+	diff := plan(map[string]int{"s": 24, "m": 20, "l": 16},
+		block{name: "vpc", size: "l"},
+		block{name: "k8s", prefixLength: 20},
+		block{name: "db", size: "s"},
+	)
+	if diff != nil && diff.RequiresNew() {
+		t.Errorf("renamed presets RequiresNew() = true, want false: %v", diff)
+	}
+
+	// A preset of a different size replaces it
+	diff = plan(map[string]int{"medium": 20, "large": 17},
+		block{name: "vpc", size: "large"},
+		block{name: "k8s", size: "medium"},
+		block{name: "db", prefixLength: 24},
+	)
+	if diff == nil || !diff.RequiresNew() {
+		t.Errorf("resized preset RequiresNew() = false, want true")
+	}
+	if got := diff.Attributes["allocation_prefix_lengths.vpc"]; got == nil || got.New != "17" {
+		t.Errorf("allocation_prefix_lengths.vpc diff = %v, want 17", got)
+	}
+}
+
+func TestResourceDocidrPoolCustomizeDiff_SizePresetErrors(t *testing.T) {
+	meta := newTestCombinedConfig(t, &config.Config{SizePresets: map[string]int{"small": 24, "large": 16}})
+
+	tests := []struct {
+		name       string
+		allocation map[string]interface{}
+		wantErr    string
+	}{
+		{
+			name:       "unknown preset",
+			allocation: map[string]interface{}{"name": "vpc", "size": "huge"},
+			wantErr:    `allocation "vpc": size "huge" is not defined in the provider's size_presets (large, small)`,
+		},
+		{
+			name:       "both",
+			allocation: map[string]interface{}{"name": "vpc", "size": "large", "prefix_length": 16},
+			wantErr:    "only one of prefix_length, size and new_bits may be set",
+		},
+		{
+			name:       "neither",
+			allocation: map[string]interface{}{"name": "vpc"},
+			wantErr:    "one of prefix_length, size or new_bits must be set",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := planPool(t, map[string]interface{}{
+				"base_cidr":  "10.0.0.0/8",
+				"allocation": []interface{}{tt.allocation},
+			}, meta)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("planPool() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestResourceDocidrPool_NewBits(t *testing.T) {
+	srv := httptest.NewServer(newFakeAccountMux([]interface{}{}, []interface{}{}))
+	t.Cleanup(srv.Close)
+	newMeta := func() *config.CombinedConfig {
+		return newTestCombinedConfig(t, &config.Config{APIEndpoint: srv.URL + "/"})
+	}
+
+	absolute := applyPool(t, nil, map[string]interface{}{
+		"base_cidr": "10.64.0.0/10",
+		"allocation": []interface{}{
+			map[string]interface{}{"name": "vpc", "prefix_length": 16},
+			map[string]interface{}{"name": "k8s", "prefix_length": 20},
+			map[string]interface{}{"name": "transit", "prefix_length": 24, "base_cidr": "172.31.0.0/16"},
+		},
+	}, newMeta())
+	relative := applyPool(t, nil, map[string]interface{}{
+		"base_cidr": "10.64.0.0/10",
+		"allocation": []interface{}{
+			map[string]interface{}{"name": "vpc", "new_bits": 6},
+			map[string]interface{}{"name": "k8s", "new_bits": 10},
+			map[string]interface{}{"name": "transit", "new_bits": 8, "base_cidr": "172.31.0.0/16"},
+		},
+	}, newMeta())
+
+	if relative.ID != absolute.ID {
+		t.Errorf("ID = %s, want %s", relative.ID, absolute.ID)
+	}
+	for _, key := range []string{"allocations.vpc", "allocations.k8s", "allocations.transit", "allocation_prefix_lengths.k8s", "allocation_prefix_lengths.transit"} {
+		if relative.Attributes[key] != absolute.Attributes[key] {
+			t.Errorf("%s = %q, want %q", key, relative.Attributes[key], absolute.Attributes[key])
+		}
+	}
+	if got := relative.Attributes["allocations.k8s"]; got != "10.65.0.0/20" {
+		t.Errorf("allocations.k8s = %q, want 10.65.0.0/20", got)
+	}
+
+	// Switching the pool with prefix lengths to the equivalent new_bits
+	// keeps it
+	prior := absolute.DeepCopy()
+	rawBlock := func(name string, newBits int64, baseCIDR cty.Value) cty.Value {
+		return cty.ObjectVal(map[string]cty.Value{
+			"name":          cty.StringVal(name),
+			"new_bits":      cty.NumberIntVal(newBits),
+			"prefix_length": cty.NullVal(cty.Number),
+			"base_cidr":     baseCIDR,
+		})
+	}
+	prior.RawConfig = cty.ObjectVal(map[string]cty.Value{
+		"base_cidr": cty.StringVal("10.64.0.0/10"),
+		"allocation": cty.ListVal([]cty.Value{
+			rawBlock("vpc", 6, cty.NullVal(cty.String)),
+			rawBlock("k8s", 10, cty.NullVal(cty.String)),
+			rawBlock("transit", 8, cty.StringVal("172.31.0.0/16")),
+		}),
+	})
+	diff, err := ResourceDocidrPool().Diff(context.Background(), prior, terraform.NewResourceConfigRaw(map[string]interface{}{
+		"base_cidr": "10.64.0.0/10",
+		"allocation": []interface{}{
+			map[string]interface{}{"name": "vpc", "new_bits": 6},
+			map[string]interface{}{"name": "k8s", "new_bits": 10},
+			map[string]interface{}{"name": "transit", "new_bits": 8, "base_cidr": "172.31.0.0/16"},
+		},
+	}), newMeta())
+	if err != nil {
+		t.Fatalf("Diff() error = %v", err)
+	}
+	if diff != nil && diff.RequiresNew() {
+		t.Errorf("switch to new_bits RequiresNew() = true, want false: %v", diff)
+	}
+
+	// new_bits is checked against the base during plan
+	_, err = planPool(t, map[string]interface{}{
+		"base_cidr": "10.0.0.0/8",
+		"allocation": []interface{}{
+			map[string]interface{}{"name": "vpc", "new_bits": 4},
+		},
+	}, newMeta())
+	if err == nil || !strings.Contains(err.Error(), "new_bits 4 on base 10.0.0.0/8 gives a /12") {
+		t.Errorf("planPool() error = %v, want the resolved prefix length to be rejected", err)
+	}
+	_, err = planPool(t, map[string]interface{}{
+		"base_cidr": "10.0.0.0/8",
+		"allocation": []interface{}{
+			map[string]interface{}{"name": "vpc", "new_bits": 8, "prefix_length": 16},
+		},
+	}, newMeta())
+	if err == nil || !strings.Contains(err.Error(), "only one of prefix_length, size and new_bits may be set") {
+		t.Errorf("planPool() error = %v, want new_bits and prefix_length to be exclusive", err)
+	}
+}
+
+func TestResourceDocidrPool_SkipAPIQuery(t *testing.T) {
+	var requests int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		http.Error(w, "unreachable", http.StatusServiceUnavailable)
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	meta := newTestCombinedConfig(t, &config.Config{APIEndpoint: srv.URL + "/", ComputeAllocationsAtPlanTime: true})
+
+	raw := map[string]interface{}{
+		"base_cidr":      "10.0.0.0/16",
+		"skip_api_query": true,
+		"exclude": []interface{}{
+			map[string]interface{}{"cidr": "10.0.0.0/24", "reason": "on-prem"},
+		},
+		"allocation": []interface{}{
+			map[string]interface{}{"name": "vpc", "prefix_length": 24},
+		},
+	}
+	r := ResourceDocidrPool()
+	diff, err := r.Diff(context.Background(), nil, terraform.NewResourceConfigRaw(raw), meta)
+	if err != nil {
+		t.Fatalf("Diff() error = %v", err)
+	}
+	if got := diff.Attributes["allocations.vpc"].New; got != "10.0.1.0/24" {
+		t.Errorf("planned allocations.vpc = %q, want 10.0.1.0/24", got)
+	}
+
+	state, diags := r.Apply(context.Background(), nil, diff, meta)
+	if diags.HasError() {
+		t.Fatalf("Apply() diags = %v", diags)
+	}
+	if got := state.Attributes["allocations.vpc"]; got != "10.0.1.0/24" {
+		t.Errorf("allocations.vpc = %q, want 10.0.1.0/24", got)
+	}
+	warned := false
+	for _, d := range diags {
+		warned = warned || (d.Severity == diag.Warning && d.Summary == "Existing DigitalOcean resources not considered")
+	}
+	if !warned {
+		t.Errorf("Apply() diags = %v, want a warning that existing resources were not considered", diags)
+	}
+	if n := atomic.LoadInt32(&requests); n != 0 {
+		t.Errorf("made %d API requests, want none", n)
+	}
+
+	// Air-gapped deployments don't need a token
+	noToken, err := (&config.Config{APIEndpoint: srv.URL + "/"}).Client()
+	if err != nil {
+		t.Fatalf("Client() error = %v", err)
+	}
+	if state := applyPool(t, nil, raw, noToken); state.Attributes["allocations.vpc"] != "10.0.1.0/24" {
+		t.Errorf("allocations.vpc without a token = %q, want 10.0.1.0/24", state.Attributes["allocations.vpc"])
+	}
+}
+
+func TestResourceDocidrPoolCustomizeDiff_SkipAPIQueryDetectBaseCIDR(t *testing.T) {
+	_, err := planPool(t, map[string]interface{}{
+		"region":                       "nyc1",
+		"detect_base_cidr_from_region": true,
+		"skip_api_query":               true,
+		"allocation": []interface{}{
+			map[string]interface{}{"name": "vpc", "prefix_length": 24},
+		},
+	}, nil)
+	if err == nil || !strings.Contains(err.Error(), "skip_api_query can't be used with detect_base_cidr_from_region") {
+		t.Errorf("planPool() error = %v, want skip_api_query conflict", err)
+	}
+}
+
+func TestResourceDocidrPool_GrowBaseCIDR(t *testing.T) {
+	newMeta := func() *config.CombinedConfig {
+		return newFakeCombinedConfig(t, newFakeAccountMux([]interface{}{}, []interface{}{}))
+	}
+	config := func(baseCIDR string) map[string]interface{} {
+		return map[string]interface{}{
+			"base_cidr": baseCIDR,
+			"allocation": []interface{}{
+				map[string]interface{}{"name": "vpc", "prefix_length": 24},
+				map[string]interface{}{"name": "k8s", "prefix_length": 20},
+			},
+		}
+	}
+	state := applyPool(t, nil, config("10.100.0.0/16"), newMeta())
+
+	tests := []struct {
+		name        string
+		baseCIDR    string
+		wantReplace bool
+	}{
+		{name: "grow", baseCIDR: "10.100.0.0/14"},
+		{name: "grow to containing supernet", baseCIDR: "10.0.0.0/8"},
+		{name: "shrink", baseCIDR: "10.100.0.0/17", wantReplace: true},
+		{name: "disjoint move", baseCIDR: "10.200.0.0/16", wantReplace: true},
+		{name: "overlapping move", baseCIDR: "10.101.0.0/16", wantReplace: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diff, err := ResourceDocidrPool().Diff(context.Background(), state, terraform.NewResourceConfigRaw(config(tt.baseCIDR)), newMeta())
+			if err != nil {
+				t.Fatalf("Diff() error = %v", err)
+			}
+			if diff.RequiresNew() != tt.wantReplace {
+				t.Errorf("RequiresNew() = %v, want %v", diff.RequiresNew(), tt.wantReplace)
+			}
+		})
+	}
+
+	// Growing in place keeps the ID and allocations, and recomputes the
+	// attributes derived from the base
+	grown := applyPool(t, state, config("10.100.0.0/14"), newMeta())
+	if grown.ID != state.ID {
+		t.Errorf("ID = %s after growing base_cidr, want %s", grown.ID, state.ID)
+	}
+	for _, key := range []string{"allocations.vpc", "allocations.k8s", "ipv6_base_cidr"} {
+		if grown.Attributes[key] != state.Attributes[key] {
+			t.Errorf("%s = %q after growing base_cidr, want %q", key, grown.Attributes[key], state.Attributes[key])
+		}
+	}
+	if got := grown.Attributes["base_cidr"]; got != "10.100.0.0/14" {
+		t.Errorf("base_cidr = %q, want 10.100.0.0/14", got)
+	}
+	if before, after := state.Attributes["utilization_percent"], grown.Attributes["utilization_percent"]; after == before || after == "" {
+		t.Errorf("utilization_percent = %q after growing base_cidr, want it recomputed from %q", after, before)
+	}
+	if !strings.Contains(grown.Attributes["summary"], "10.100.0.0/14") {
+		t.Errorf("summary = %q, want it rooted at 10.100.0.0/14", grown.Attributes["summary"])
+	}
+	if !strings.Contains(grown.Attributes["allocations_json"], `"base_cidr": "10.100.0.0/14"`) {
+		t.Errorf("allocations_json = %s, want base_cidr 10.100.0.0/14", grown.Attributes["allocations_json"])
+	}
+	if got := grown.Attributes["allocations_cidrsubnet.0.newbits"]; got != "10" {
+		t.Errorf("allocations_cidrsubnet.0.newbits = %q after growing base_cidr, want 10", got)
+	}
+
+	// Planning the grown pool again shows no changes
+	diff, err := ResourceDocidrPool().Diff(context.Background(), grown, terraform.NewResourceConfigRaw(config("10.100.0.0/14")), newMeta())
+	if err != nil {
+		t.Fatalf("Diff() error = %v", err)
+	}
+	if diff != nil && !diff.Empty() {
+		t.Errorf("Diff() after growing base_cidr = %v, want no changes", diff.Attributes)
+	}
+}
+
+func TestResourceDocidrPool_InPlaceAttributes(t *testing.T) {
+	newMeta := func() *config.CombinedConfig {
+		return newFakeCombinedConfig(t, newFakeAccountMux([]interface{}{}, []interface{}{}))
+	}
+	config := func(baseCIDR string, set map[string]interface{}) map[string]interface{} {
+		raw := map[string]interface{}{
+			"base_cidr": baseCIDR,
+			"allocation": []interface{}{
+				map[string]interface{}{"name": "vpc", "prefix_length": 24},
+				map[string]interface{}{"name": "k8s", "prefix_length": 20},
+			},
+		}
+		for k, v := range set {
+			raw[k] = v
+		}
+		return raw
+	}
+	state := applyPool(t, nil, config("10.100.0.0/16", nil), newMeta())
+
+	tests := []struct {
+		name     string
+		baseCIDR string
+		set      map[string]interface{}
+		key      string
+		want     string
+	}{
+		{name: "allocation_count_limit", baseCIDR: "10.100.0.0/16", set: map[string]interface{}{"allocation_count_limit": 2}, key: "allocation_count_limit", want: "2"},
+		{name: "exclude_overlapping_pools", baseCIDR: "10.100.0.0/16", set: map[string]interface{}{"exclude_overlapping_pools": true}, key: "exclude_overlapping_pools", want: "true"},
+		{name: "warn_on_existing_cidr_errors", baseCIDR: "10.100.0.0/16", set: map[string]interface{}{"warn_on_existing_cidr_errors": false}, key: "warn_on_existing_cidr_errors", want: "false"},
+		{name: "telemetry", baseCIDR: "10.100.0.0/16", set: map[string]interface{}{"telemetry": []interface{}{map[string]interface{}{"enabled": true, "endpoint": "https://telemetry.example.com/docidr"}}}, key: "telemetry.0.endpoint", want: "https://telemetry.example.com/docidr"},
+		{name: "oversize_warning_threshold", baseCIDR: "10.100.0.0/16", set: map[string]interface{}{"oversize_warning_threshold": 0.9}, key: "oversize_warning_threshold", want: "0.9"},
+		{name: "validate_against_asn_route_table", baseCIDR: "10.100.0.0/16", set: map[string]interface{}{"validate_against_asn_route_table": "https://routes.example.com/as64500.json"}, key: "validate_against_asn_route_table", want: "https://routes.example.com/as64500.json"},
+		{name: "with growing base_cidr", baseCIDR: "10.100.0.0/14", set: map[string]interface{}{"allocation_count_limit": 100}, key: "allocation_count_limit", want: "100"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw := config(tt.baseCIDR, tt.set)
+			diff, err := ResourceDocidrPool().Diff(context.Background(), state, terraform.NewResourceConfigRaw(raw), newMeta())
+			if err != nil {
+				t.Fatalf("Diff() error = %v", err)
+			}
+			if diff == nil || diff.RequiresNew() {
+				t.Fatalf("Diff() = %v, want an in-place update", diff)
+			}
+
+			updated := applyPool(t, state, raw, newMeta())
+			if updated.ID != state.ID {
+				t.Errorf("ID = %s after update, want %s", updated.ID, state.ID)
+			}
+			for _, key := range []string{"allocations.vpc", "allocations.k8s"} {
+				if updated.Attributes[key] != state.Attributes[key] {
+					t.Errorf("%s = %q after update, want %q", key, updated.Attributes[key], state.Attributes[key])
+				}
+			}
+			if got := updated.Attributes[tt.key]; got != tt.want {
+				t.Errorf("%s = %q, want %q", tt.key, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResourceDocidrPool_ChangeGroupInPlace(t *testing.T) {
+	newMeta := func() *config.CombinedConfig {
+		return newFakeCombinedConfig(t, newFakeAccountMux([]interface{}{}, []interface{}{}))
+	}
+	config := func(group string) map[string]interface{} {
+		return map[string]interface{}{
+			"base_cidr": "10.100.0.0/16",
+			"allocation": []interface{}{
+				map[string]interface{}{"name": "vpc", "prefix_length": 24, "group": group},
+				map[string]interface{}{"name": "k8s", "prefix_length": 20},
+			},
+		}
+	}
+	state := applyPool(t, nil, config("staging"), newMeta())
+
+	diff, err := ResourceDocidrPool().Diff(context.Background(), state, terraform.NewResourceConfigRaw(config("production")), newMeta())
+	if err != nil {
+		t.Fatalf("Diff() error = %v", err)
+	}
+	if diff == nil || diff.RequiresNew() {
+		t.Fatalf("Diff() with a new group = %v, want an in-place update", diff)
+	}
+	if attr := diff.Attributes["groups.%"]; attr == nil || !attr.NewComputed {
+		t.Errorf("groups.%% = %+v, want it computed", attr)
+	}
+
+	updated := applyPool(t, state, config("production"), newMeta())
+	if updated.ID != state.ID {
+		t.Errorf("ID = %s after changing the group, want %s", updated.ID, state.ID)
+	}
+	vpc := state.Attributes["allocations.vpc"]
+	if got := updated.Attributes["allocations.vpc"]; got != vpc {
+		t.Errorf("allocations.vpc = %q after changing the group, want %q", got, vpc)
+	}
+	if got := updated.Attributes["groups.production"]; got != vpc {
+		t.Errorf("groups.production = %q, want %q", got, vpc)
+	}
+	if _, ok := updated.Attributes["groups.staging"]; ok {
+		t.Errorf("groups.staging = %q, want it removed", updated.Attributes["groups.staging"])
+	}
+	if !strings.Contains(updated.Attributes["netbox_export"], "production") || strings.Contains(updated.Attributes["netbox_export"], "staging") {
+		t.Errorf("netbox_export = %s, want it tagged production", updated.Attributes["netbox_export"])
+	}
+}
@@ -10,6 +10,9 @@ import (
 	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
 )
 
+// TestAccDocidrPool_Basic runs offline against testdata/acceptance_cassette.json
+// when DIGITALOCEAN_TOKEN isn't set. The cassette is an account with only a
+// default VPC in nyc1.
 func TestAccDocidrPool_Basic(t *testing.T) {
 	resource.ParallelTest(t, resource.TestCase{
 		PreCheck:          func() { acceptance.TestAccPreCheck(t) },
@@ -23,6 +26,19 @@ func TestAccDocidrPool_Basic(t *testing.T) {
 					resource.TestCheckResourceAttrSet("docidr_pool.test", "allocations.main_vpc"),
 					resource.TestCheckResourceAttrSet("docidr_pool.test", "allocations.doks_cluster"),
 					resource.TestCheckResourceAttrSet("docidr_pool.test", "allocations.doks_services"),
+					acceptance.CheckAllocationWithinCIDR("docidr_pool.test", "allocations.main_vpc", "10.0.0.0/8"),
+					acceptance.CheckAllocationWithinCIDR("docidr_pool.test", "allocations.doks_cluster", "10.0.0.0/8"),
+					acceptance.CheckAllocationWithinCIDR("docidr_pool.test", "allocations.doks_services", "10.0.0.0/8"),
+					acceptance.CheckAllocationHasPrefixLength("docidr_pool.test", "allocations.main_vpc", 16),
+					acceptance.CheckAllocationHasPrefixLength("docidr_pool.test", "allocations.doks_cluster", 20),
+					acceptance.CheckAllocationHasPrefixLength("docidr_pool.test", "allocations.doks_services", 20),
+					acceptance.CheckAllocationsDoNotOverlap("docidr_pool.test", "allocations"),
+					resource.TestMatchResourceAttr("docidr_pool.test", "summary", regexp.MustCompile(`^10\.0\.0\.0/8\n`)),
+					resource.TestCheckResourceAttr("docidr_pool.test", "utilization_percent", "0.439453125"),
+					resource.TestCheckResourceAttr("docidr_pool.test", "utilization_breakdown.main_vpc", "0.390625"),
+					resource.TestCheckResourceAttr("docidr_pool.test", "utilization_breakdown.doks_cluster", "0.0244140625"),
+					resource.TestMatchResourceAttr("docidr_pool.test", "export_terraform_locals", regexp.MustCompile(`\n  main_vpc_cidr      = "10\.`)),
+					resource.TestMatchResourceAttr("docidr_pool.test", "output_csv", regexp.MustCompile(`^name,cidr,prefix_length,network_address,broadcast_address,first_host,last_host,host_count\nmain_vpc,10\.[0-9.]+/16,16,`)),
 				),
 			},
 		},
@@ -39,7 +55,12 @@ func TestAccDocidrPool_CustomBaseCIDR(t *testing.T) {
 				Check: resource.ComposeTestCheckFunc(
 					resource.TestCheckResourceAttr("docidr_pool.test", "base_cidr", "172.16.0.0/12"),
 					resource.TestCheckResourceAttrSet("docidr_pool.test", "allocations.vpc"),
-					resource.TestMatchResourceAttr("docidr_pool.test", "allocations.vpc", regexp.MustCompile(`^172\.\d+\.\d+\.\d+/16$`)),
+					acceptance.CheckAllocationWithinCIDR("docidr_pool.test", "allocations.vpc", "172.16.0.0/12"),
+					acceptance.CheckAllocationHasPrefixLength("docidr_pool.test", "allocations.vpc", 16),
+					resource.TestCheckResourceAttr("docidr_pool.test", "allocations.vpc", "172.16.0.0/16"),
+					resource.TestCheckResourceAttr("docidr_pool.test", "allocations_first_usable.vpc", "172.16.0.1"),
+					resource.TestCheckResourceAttr("docidr_pool.test", "allocations_last_usable.vpc", "172.16.255.254"),
+					resource.TestCheckResourceAttr("docidr_pool.test", "allocations_reverse_zones.vpc", "16.172.in-addr.arpa"),
 				),
 			},
 		},
@@ -57,7 +78,8 @@ func TestAccDocidrPool_WithExclusions(t *testing.T) {
 					resource.TestCheckResourceAttr("docidr_pool.test", "base_cidr", "10.0.0.0/8"),
 					resource.TestCheckResourceAttrSet("docidr_pool.test", "allocations.vpc"),
 					// The allocated CIDR should be in 10.x.x.x/16 format
-					resource.TestMatchResourceAttr("docidr_pool.test", "allocations.vpc", regexp.MustCompile(`^10\.\d+\.\d+\.\d+/16$`)),
+					acceptance.CheckAllocationWithinCIDR("docidr_pool.test", "allocations.vpc", "10.0.0.0/8"),
+					acceptance.CheckAllocationHasPrefixLength("docidr_pool.test", "allocations.vpc", 16),
 					// Verify it's not the excluded range by checking it's set (exclusion is validated in unit tests)
 					testAccCheckAllocationNotEqual("docidr_pool.test", "allocations.vpc", "10.0.0.0/16"),
 				),
@@ -76,6 +98,8 @@ func TestAccDocidrPool_SingleAllocation(t *testing.T) {
 				Check: resource.ComposeTestCheckFunc(
 					resource.TestCheckResourceAttr("docidr_pool.test", "base_cidr", "10.0.0.0/8"),
 					resource.TestCheckResourceAttrSet("docidr_pool.test", "allocations.only_vpc"),
+					acceptance.CheckAllocationWithinCIDR("docidr_pool.test", "allocations.only_vpc", "10.0.0.0/8"),
+					acceptance.CheckAllocationHasPrefixLength("docidr_pool.test", "allocations.only_vpc", 16),
 				),
 			},
 		},
@@ -100,6 +124,43 @@ func TestAccDocidrPool_ForceNew(t *testing.T) {
 					resource.TestCheckResourceAttr("docidr_pool.test", "base_cidr", "10.0.0.0/8"),
 					resource.TestCheckResourceAttrSet("docidr_pool.test", "allocations.vpc"),
 					resource.TestCheckResourceAttrSet("docidr_pool.test", "allocations.extra"),
+					acceptance.CheckAllocationHasPrefixLength("docidr_pool.test", "allocations.vpc", 16),
+					acceptance.CheckAllocationHasPrefixLength("docidr_pool.test", "allocations.extra", 20),
+					acceptance.CheckAllocationsDoNotOverlap("docidr_pool.test", "allocations"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccDocidrPool_StableAllocationOnReorder(t *testing.T) {
+	var vpcCIDR, clusterCIDR string
+
+	resource.ParallelTest(t, resource.TestCase{
+		PreCheck:          func() { acceptance.TestAccPreCheck(t) },
+		ProviderFactories: acceptance.TestAccProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccDocidrPoolConfig_StableAllocation(`"vpc", "cluster"`),
+				Check: resource.ComposeTestCheckFunc(
+					testAccCaptureAttr("docidr_pool.test", "allocations.vpc", &vpcCIDR),
+					testAccCaptureAttr("docidr_pool.test", "allocations.cluster", &clusterCIDR),
+				),
+			},
+			{
+				// Reordering alone plans no changes
+				Config:   testAccDocidrPoolConfig_StableAllocation(`"cluster", "vpc"`),
+				PlanOnly: true,
+			},
+			{
+				// Adding an allocation replaces the pool, but existing names
+				// keep their CIDRs
+				Config: testAccDocidrPoolConfig_StableAllocation(`"extra", "cluster", "vpc"`),
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckAttrCaptured("docidr_pool.test", "allocations.vpc", &vpcCIDR),
+					testAccCheckAttrCaptured("docidr_pool.test", "allocations.cluster", &clusterCIDR),
+					resource.TestCheckResourceAttrSet("docidr_pool.test", "allocations.extra"),
+					acceptance.CheckAllocationsDoNotOverlap("docidr_pool.test", "allocations"),
 				),
 			},
 		},
@@ -194,6 +255,42 @@ resource "docidr_pool" "test" {
 `
 }
 
+func testAccDocidrPoolConfig_StableAllocation(names string) string {
+	return fmt.Sprintf(`
+resource "docidr_pool" "test" {
+  stable_allocation = true
+
+  dynamic "allocation" {
+    for_each = [%s]
+    content {
+      name          = allocation.value
+      prefix_length = 24
+    }
+  }
+}
+`, names)
+}
+
+// testAccCaptureAttr stores the value of an attribute for a later step.
+func testAccCaptureAttr(resourceName, attrName string, value *string) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		rs, ok := s.RootModule().Resources[resourceName]
+		if !ok {
+			return fmt.Errorf("Not found: %s", resourceName)
+		}
+		*value = rs.Primary.Attributes[attrName]
+		return nil
+	}
+}
+
+// testAccCheckAttrCaptured checks that an attribute still has the value
+// stored by testAccCaptureAttr.
+func testAccCheckAttrCaptured(resourceName, attrName string, value *string) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		return resource.TestCheckResourceAttr(resourceName, attrName, *value)(s)
+	}
+}
+
 // testAccCheckAllocationNotEqual verifies that an allocation attribute is not equal to a specific value.
 func testAccCheckAllocationNotEqual(resourceName, attrName, notExpected string) resource.TestCheckFunc {
 	return func(s *terraform.State) error {
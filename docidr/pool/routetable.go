@@ -0,0 +1,163 @@
+package pool
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/DO-Solutions/terraform-provider-docidr/docidr/cidr"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+)
+
+// maxRouteTableSize caps the size of a route table response. Full tables run
+// to around a million prefixes.
+const maxRouteTableSize = 100 << 20
+
+// AnnouncedRoute is a prefix from a route table, with the AS originating it,
+// or 0 if the table doesn't say.
+type AnnouncedRoute struct {
+	Network *net.IPNet
+	ASN     uint32
+}
+
+// FetchRouteTable downloads the announced prefixes at url with client, such
+// as the provider's HTTP client. The route table is a JSON array whose
+// elements are prefix strings or objects with a "prefix" and an optional
+// "asn".
+func FetchRouteTable(ctx context.Context, client *http.Client, url string) ([]AnnouncedRoute, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching route table %s: %w", url, err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching route table %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error fetching route table %s: unexpected status %s", url, resp.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxRouteTableSize))
+	if err != nil {
+		return nil, fmt.Errorf("error reading route table %s: %w", url, err)
+	}
+	routes, err := parseRouteTable(body)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing route table %s: %w", url, err)
+	}
+	return routes, nil
+}
+
+// parseRouteTable parses a JSON array of prefix strings or objects with a
+// "prefix" and an optional "asn", given as a number or a string such as
+// "AS64500".
+func parseRouteTable(body []byte) ([]AnnouncedRoute, error) {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return nil, fmt.Errorf("expected a JSON array: %w", err)
+	}
+
+	routes := make([]AnnouncedRoute, 0, len(raw))
+	for i, element := range raw {
+		var prefix string
+		var asn json.RawMessage
+		if err := json.Unmarshal(element, &prefix); err != nil {
+			var object struct {
+				Prefix string          `json:"prefix"`
+				ASN    json.RawMessage `json:"asn"`
+			}
+			if err := json.Unmarshal(element, &object); err != nil {
+				return nil, fmt.Errorf("element %d: expected a prefix string or an object with a prefix", i)
+			}
+			prefix, asn = object.Prefix, object.ASN
+		}
+
+		network, err := cidr.ParseCIDR(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("element %d: %w", i, err)
+		}
+		route := AnnouncedRoute{Network: network}
+		if route.ASN, err = parseASN(asn); err != nil {
+			return nil, fmt.Errorf("element %d: %w", i, err)
+		}
+		routes = append(routes, route)
+	}
+	return routes, nil
+}
+
+// parseASN parses an AS number given as a JSON number or string, with or
+// without an "AS" prefix. A missing or null ASN is 0.
+func parseASN(raw json.RawMessage) (uint32, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return 0, nil
+	}
+	value := string(raw)
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		value = strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(s)), "AS")
+	}
+	asn, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid asn %s", raw)
+	}
+	return uint32(asn), nil
+}
+
+// routeTableWarnings checks the allocations against the route table at url,
+// returning a warning for each allocation overlapping an announced prefix. The
+// allocations are already made, so a route table that can't be read is a
+// warning too.
+func routeTableWarnings(ctx context.Context, client *http.Client, url string, results map[string]string) diag.Diagnostics {
+	if url == "" {
+		return nil
+	}
+
+	routes, err := FetchRouteTable(ctx, client, url)
+	if err != nil {
+		return diag.Diagnostics{{
+			Severity: diag.Warning,
+			Summary:  "Allocations not checked against route table",
+			Detail:   err.Error(),
+		}}
+	}
+
+	names := make([]string, 0, len(results))
+	for name := range results {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var diags diag.Diagnostics
+	for _, name := range names {
+		network, err := cidr.ParseCIDR(results[name])
+		if err != nil {
+			continue
+		}
+		for _, route := range routes {
+			if cidr.Classify(network, route.Network) == cidr.RelationshipNone {
+				continue
+			}
+			announcedBy := ""
+			if route.ASN != 0 {
+				announcedBy = fmt.Sprintf(" by AS%d", route.ASN)
+			}
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Warning,
+				Summary:  "Allocation overlaps an announced route",
+				Detail: fmt.Sprintf("%s (%s) overlaps %s, announced%s according to %s. Traffic to it may be routed "+
+					"to the announcing network instead; exclude the prefix if that matters.", name, results[name], route.Network, announcedBy, url),
+			})
+		}
+	}
+	return diags
+}
@@ -0,0 +1,141 @@
+package pool
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+const routeTableJSON = `[
+	"10.0.1.0/24",
+	{"prefix": "10.0.16.0/20", "asn": 64500},
+	{"prefix": "192.0.2.0/24", "asn": "AS64501"},
+	{"prefix": "2001:db8::/32", "asn": null}
+]`
+
+func newRouteTableServer(t *testing.T, status int, body string) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		fmt.Fprint(w, body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestFetchRouteTable(t *testing.T) {
+	srv := newRouteTableServer(t, http.StatusOK, routeTableJSON)
+
+	routes, err := FetchRouteTable(context.Background(), srv.Client(), srv.URL)
+	if err != nil {
+		t.Fatalf("FetchRouteTable() error = %v", err)
+	}
+	var got []string
+	for _, route := range routes {
+		got = append(got, route.Network.String())
+	}
+	want := "10.0.1.0/24, 10.0.16.0/20, 192.0.2.0/24, 2001:db8::/32"
+	if strings.Join(got, ", ") != want {
+		t.Errorf("FetchRouteTable() = %v, want %s", got, want)
+	}
+
+	if _, err := FetchRouteTable(context.Background(), srv.Client(), newRouteTableServer(t, http.StatusNotFound, "").URL); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("FetchRouteTable() with a 404 error = %v", err)
+	}
+}
+
+func TestParseRouteTable(t *testing.T) {
+	routes, err := parseRouteTable([]byte(routeTableJSON))
+	if err != nil {
+		t.Fatalf("parseRouteTable() error = %v", err)
+	}
+	for i, want := range []uint32{0, 64500, 64501, 0} {
+		if routes[i].ASN != want {
+			t.Errorf("routes[%d].ASN = %d, want %d", i, routes[i].ASN, want)
+		}
+	}
+
+	for _, body := range []string{
+		`{"prefixes": []}`,
+		`["10.0.0.0/33"]`,
+		`[{"asn": 64500}]`,
+		`[{"prefix": "10.0.0.0/8", "asn": "private"}]`,
+		`[42]`,
+	} {
+		if _, err := parseRouteTable([]byte(body)); err == nil {
+			t.Errorf("parseRouteTable(%s) error = nil, want error", body)
+		}
+	}
+}
+
+func TestRouteTableWarnings(t *testing.T) {
+	srv := newRouteTableServer(t, http.StatusOK, routeTableJSON)
+
+	diags := routeTableWarnings(context.Background(), http.DefaultClient, srv.URL, map[string]string{
+		"app":  "10.0.0.0/24",
+		"data": "10.0.16.0/24",
+		"web":  "10.0.0.0/20",
+	})
+	var got []string
+	for _, d := range diags {
+		if d.Severity != diag.Warning {
+			t.Errorf("routeTableWarnings() severity = %v, want a warning", d.Severity)
+		}
+		got = append(got, d.Detail[:strings.Index(d.Detail, " according")])
+	}
+	want := []string{
+		"data (10.0.16.0/24) overlaps 10.0.16.0/20, announced by AS64500",
+		"web (10.0.0.0/20) overlaps 10.0.1.0/24, announced",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("routeTableWarnings() = %q, want %q", got, want)
+	}
+
+	// A route table that can't be read doesn't fail the allocation
+	diags = routeTableWarnings(context.Background(), http.DefaultClient, newRouteTableServer(t, http.StatusOK, "not json").URL, map[string]string{"app": "10.0.0.0/24"})
+	if len(diags) != 1 || diags[0].Severity != diag.Warning || diags[0].Summary != "Allocations not checked against route table" {
+		t.Errorf("routeTableWarnings() with a bad table = %v", diags)
+	}
+
+	if diags := routeTableWarnings(context.Background(), http.DefaultClient, "", map[string]string{"app": "10.0.0.0/24"}); len(diags) != 0 {
+		t.Errorf("routeTableWarnings() without a URL = %v", diags)
+	}
+}
+
+func TestResourceDocidrPoolCreate_ASNRouteTable(t *testing.T) {
+	routes := newRouteTableServer(t, http.StatusOK, routeTableJSON)
+	meta := newFakeCombinedConfig(t, newFakeAccountMux([]interface{}{}, []interface{}{}))
+
+	d := schema.TestResourceDataRaw(t, ResourceDocidrPool().Schema, map[string]interface{}{
+		"base_cidr":                        "10.0.0.0/16",
+		"validate_against_asn_route_table": routes.URL,
+		"allocation": []interface{}{
+			map[string]interface{}{"name": "vpc", "prefix_length": 16},
+		},
+	})
+	diags := resourceDocidrPoolCreate(context.Background(), d, meta)
+	if diags.HasError() {
+		t.Fatalf("Create() diags = %v", diags)
+	}
+	if d.Get("allocations.vpc") != "10.0.0.0/16" {
+		t.Errorf("allocations.vpc = %v, want 10.0.0.0/16 despite the announced routes", d.Get("allocations.vpc"))
+	}
+
+	var overlaps int
+	for _, d := range diags {
+		if d.Summary == "Allocation overlaps an announced route" {
+			overlaps++
+		}
+	}
+	if overlaps != 2 {
+		t.Errorf("Create() diags = %v, want 2 announced route warnings", diags)
+	}
+}
@@ -0,0 +1,74 @@
+package pool
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+)
+
+// scanNameFilter selects which VPCs and Kubernetes clusters found in the
+// account are excluded from allocation, by name.
+type scanNameFilter struct {
+	// Include, when set, keeps only resources whose names match.
+	Include *regexp.Regexp
+	// Exclude, when set, drops resources whose names match, even if they
+	// match Include.
+	Exclude *regexp.Regexp
+}
+
+// expandScanNameFilter reads scan_name_regex and scan_name_exclude_regex from
+// the resource configuration.
+func expandScanNameFilter(get func(string) interface{}) (scanNameFilter, error) {
+	var filter scanNameFilter
+	if pattern := get("scan_name_regex").(string); pattern != "" {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return filter, fmt.Errorf("invalid scan_name_regex %q: %w", pattern, err)
+		}
+		filter.Include = re
+	}
+	if pattern := get("scan_name_exclude_regex").(string); pattern != "" {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return filter, fmt.Errorf("invalid scan_name_exclude_regex %q: %w", pattern, err)
+		}
+		filter.Exclude = re
+	}
+	return filter, nil
+}
+
+// skips reports whether the resource named name is filtered out.
+func (f scanNameFilter) skips(name string) bool {
+	if f.Include != nil && !f.Include.MatchString(name) {
+		return true
+	}
+	return f.Exclude != nil && f.Exclude.MatchString(name)
+}
+
+// apply removes the CIDRs of VPCs and Kubernetes clusters the filter skips,
+// returning the CIDRs kept and how many resources were skipped. CIDRs from
+// other sources are always kept.
+func (f scanNameFilter) apply(existing []existingCIDR) ([]existingCIDR, int) {
+	if f.Include == nil && f.Exclude == nil {
+		return existing, 0
+	}
+
+	result := make([]existingCIDR, 0, len(existing))
+	skipped := make(map[string]bool)
+	for _, e := range existing {
+		kind := ""
+		switch e.Source {
+		case sourceVPC:
+			kind = "VPC"
+		case sourceKubernetesClusterSubnet, sourceKubernetesServiceSubnet:
+			kind = "Kubernetes cluster"
+		}
+		if kind == "" || !f.skips(e.ResourceName) {
+			result = append(result, e)
+			continue
+		}
+		log.Printf("[DEBUG] Skipping %s %s of %s %s %q: name doesn't pass the scan name filter", e.Source, e.Network.String(), kind, e.ResourceID, e.ResourceName)
+		skipped[kind+" "+e.ResourceID] = true
+	}
+	return result, len(skipped)
+}
@@ -0,0 +1,127 @@
+package pool
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestScanNameFilter(t *testing.T) {
+	mux := newFakeAccountMux(
+		[]interface{}{
+			map[string]interface{}{"id": "vpc-1", "name": "prod-nyc1", "ip_range": "10.0.0.0/16", "region": "nyc1"},
+			map[string]interface{}{"id": "vpc-2", "name": "staging-nyc1", "ip_range": "10.1.0.0/16", "region": "nyc1"},
+			map[string]interface{}{"id": "vpc-3", "name": "scratch", "ip_range": "10.2.0.0/16", "region": "nyc1"},
+			map[string]interface{}{"id": "vpc-4", "name": "prod-nyc1-legacy", "ip_range": "10.3.0.0/16", "region": "nyc1"},
+		},
+		[]interface{}{
+			map[string]interface{}{"id": "k8s-1", "name": "prod-k8s", "region": "nyc1", "cluster_subnet": "10.244.0.0/16", "service_subnet": "10.245.0.0/16"},
+			map[string]interface{}{"id": "k8s-2", "name": "experiment", "region": "nyc1", "cluster_subnet": "10.246.0.0/16", "service_subnet": "10.247.0.0/16"},
+		},
+	)
+	client := newFakeGodoClient(t, mux)
+
+	existing, _, diags := collectExistingCIDRs(context.Background(), client, scanOptions{})
+	if diags.HasError() {
+		t.Fatalf("collectExistingCIDRs() diags = %v", diags)
+	}
+
+	tests := []struct {
+		name        string
+		include     string
+		exclude     string
+		want        []string
+		wantSkipped int
+	}{
+		{
+			name: "no filter",
+			want: []string{
+				"10.0.0.0/16", "10.1.0.0/16", "10.2.0.0/16", "10.3.0.0/16",
+				"10.244.0.0/16", "10.245.0.0/16", "10.246.0.0/16", "10.247.0.0/16",
+			},
+		},
+		{
+			name:        "include",
+			include:     "^prod-|^staging-",
+			want:        []string{"10.0.0.0/16", "10.1.0.0/16", "10.3.0.0/16", "10.244.0.0/16", "10.245.0.0/16"},
+			wantSkipped: 2,
+		},
+		{
+			name:        "exclude",
+			exclude:     "scratch|experiment",
+			want:        []string{"10.0.0.0/16", "10.1.0.0/16", "10.3.0.0/16", "10.244.0.0/16", "10.245.0.0/16"},
+			wantSkipped: 2,
+		},
+		{
+			name:        "both",
+			include:     "^prod-|^staging-",
+			exclude:     "-legacy$",
+			want:        []string{"10.0.0.0/16", "10.1.0.0/16", "10.244.0.0/16", "10.245.0.0/16"},
+			wantSkipped: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := expandScanNameFilter(func(key string) interface{} {
+				return map[string]interface{}{"scan_name_regex": tt.include, "scan_name_exclude_regex": tt.exclude}[key]
+			})
+			if err != nil {
+				t.Fatalf("expandScanNameFilter() error = %v", err)
+			}
+
+			kept, skipped := filter.apply(existing)
+			sort.Strings(tt.want)
+			if got := sortedNetworkStrings(existingNetworks(kept)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("apply() kept %v, want %v", got, tt.want)
+			}
+			if skipped != tt.wantSkipped {
+				t.Errorf("apply() skipped %d resources, want %d", skipped, tt.wantSkipped)
+			}
+		})
+	}
+}
+
+func TestResourceDocidrPool_ScanNameRegex(t *testing.T) {
+	meta := newFakeCombinedConfig(t, newFakeAccountMux(
+		[]interface{}{
+			map[string]interface{}{"id": "vpc-1", "name": "scratch", "ip_range": "10.0.0.0/24", "region": "nyc1"},
+			map[string]interface{}{"id": "vpc-2", "name": "prod-nyc1", "ip_range": "10.0.1.0/24", "region": "nyc1"},
+		},
+		[]interface{}{},
+	))
+
+	state := applyPool(t, nil, map[string]interface{}{
+		"base_cidr":       "10.0.0.0/16",
+		"scan_name_regex": "^prod-",
+		"allocation": []interface{}{
+			map[string]interface{}{"name": "a", "prefix_length": 24},
+			map[string]interface{}{"name": "b", "prefix_length": 24},
+		},
+	}, meta)
+
+	// The scratch VPC's range is free to allocate
+	want := map[string]string{
+		"allocations.a":                  "10.0.0.0/24",
+		"allocations.b":                  "10.0.2.0/24",
+		"scan_report.0.existing_cidrs.#": "1",
+		"scan_report.0.existing_cidrs.0": "10.0.1.0/24",
+		"scan_report.0.name_filtered":    "1",
+	}
+	for key, value := range want {
+		if got := state.Attributes[key]; got != value {
+			t.Errorf("%s = %q, want %q", key, got, value)
+		}
+	}
+}
+
+func TestResourceDocidrPool_ScanNameRegexInvalid(t *testing.T) {
+	for _, key := range []string{"scan_name_regex", "scan_name_exclude_regex"} {
+		_, errs := ResourceDocidrPool().Schema[key].ValidateFunc("^prod-(", key)
+		if len(errs) == 0 || !strings.Contains(errs[0].Error(), key) {
+			t.Errorf("%s ValidateFunc errors = %v, want an invalid regex error", key, errs)
+		}
+	}
+}
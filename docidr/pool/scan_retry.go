@@ -0,0 +1,97 @@
+package pool
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+)
+
+// scanFunc performs a single scan of the existing CIDRs in the account.
+type scanFunc func() ([]existingCIDR, []string, diag.Diagnostics)
+
+// consistentScan guards against eventually-consistent list endpoints. It scans
+// until two consecutive runs return the same CIDRs without anomalies, rescanning
+// up to retries times with delay between runs. If the runs never agree, the last
+// snapshot is returned with a warning. A retries value of 0 scans once.
+func consistentScan(ctx context.Context, retries int, delay time.Duration, scan scanFunc) ([]existingCIDR, []string, diag.Diagnostics) {
+	previous, skipped, diags := scan()
+	if retries <= 0 || diags.HasError() {
+		return previous, skipped, diags
+	}
+
+	var reason string
+	for attempt := 1; attempt <= retries; attempt++ {
+		select {
+		case <-ctx.Done():
+			return nil, nil, diag.FromErr(ctx.Err())
+		case <-time.After(delay):
+		}
+
+		current, currentSkipped, currentDiags := scan()
+		if currentDiags.HasError() {
+			return current, currentSkipped, currentDiags
+		}
+
+		reason = scanDifference(previous, current)
+		if reason == "" {
+			log.Printf("[DEBUG] Existing CIDR scan is consistent after %d rescans", attempt)
+			return current, currentSkipped, currentDiags
+		}
+		log.Printf("[DEBUG] Rescanning existing CIDRs (%d/%d): %s", attempt, retries, reason)
+
+		previous, skipped, diags = current, currentSkipped, currentDiags
+	}
+
+	return previous, skipped, append(diags, diag.Diagnostic{
+		Severity: diag.Warning,
+		Summary:  "Existing CIDR scan did not stabilize",
+		Detail: fmt.Sprintf("Consecutive scans of the DigitalOcean account still disagreed after %d rescans (%s). "+
+			"Allocations were made from the last scan, which may include resources that were just created or deleted.", retries, reason),
+	})
+}
+
+// scanDifference returns a description of why two consecutive scans can't be
+// trusted, or an empty string if they agree and neither contains anomalies.
+func scanDifference(previous, current []existingCIDR) string {
+	previousKeys, previousDuplicate := scanKeys(previous)
+	if previousDuplicate != "" {
+		return fmt.Sprintf("duplicate entry %s", previousDuplicate)
+	}
+	currentKeys, currentDuplicate := scanKeys(current)
+	if currentDuplicate != "" {
+		return fmt.Sprintf("duplicate entry %s", currentDuplicate)
+	}
+
+	if len(previousKeys) != len(currentKeys) {
+		return fmt.Sprintf("found %d CIDRs, then %d", len(previousKeys), len(currentKeys))
+	}
+	for i := range previousKeys {
+		if previousKeys[i] != currentKeys[i] {
+			return fmt.Sprintf("%s changed to %s", previousKeys[i], currentKeys[i])
+		}
+	}
+	return ""
+}
+
+// scanKeys returns a sorted key for each existing CIDR, along with the first
+// API resource that appears more than once, which indicates an inconsistent
+// listing. Entries without a resource ID come from configuration, not the API.
+func scanKeys(existing []existingCIDR) ([]string, string) {
+	keys := make([]string, 0, len(existing))
+	seen := make(map[string]bool, len(existing))
+	var duplicate string
+	for _, e := range existing {
+		key := fmt.Sprintf("%s/%s=%s", e.Source, e.ResourceID, e.Network.String())
+		if seen[key] && duplicate == "" && e.ResourceID != "" {
+			duplicate = key
+		}
+		seen[key] = true
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys, duplicate
+}
@@ -0,0 +1,137 @@
+package pool
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+)
+
+// serveChangingVPCs registers a VPC list endpoint that returns listings[n] on
+// the nth request, repeating the last listing once they run out.
+func serveChangingVPCs(mux *http.ServeMux, calls *int32, listings ...[]interface{}) {
+	mux.HandleFunc("/v2/vpcs", func(w http.ResponseWriter, r *http.Request) {
+		n := int(atomic.AddInt32(calls, 1)) - 1
+		if n >= len(listings) {
+			n = len(listings) - 1
+		}
+		items := listings[n]
+		if items == nil {
+			items = []interface{}{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"vpcs":  items,
+			"links": map[string]interface{}{},
+			"meta":  map[string]interface{}{"total": len(items)},
+		})
+	})
+}
+
+func TestConsistentScan(t *testing.T) {
+	prod := map[string]interface{}{"id": "vpc-1", "name": "prod", "ip_range": "10.0.0.0/16", "region": "nyc1"}
+	deleted := map[string]interface{}{"id": "vpc-2", "name": "deleted", "ip_range": "10.1.0.0/16", "region": "nyc1"}
+
+	tests := []struct {
+		name        string
+		retries     int
+		listings    [][]interface{}
+		wantCIDRs   int
+		wantScans   int32
+		wantWarning bool
+	}{
+		{
+			name:      "retries disabled",
+			retries:   0,
+			listings:  [][]interface{}{{prod, deleted}, {prod}},
+			wantCIDRs: 2,
+			wantScans: 1,
+		},
+		{
+			name:      "consistent listing",
+			retries:   3,
+			listings:  [][]interface{}{{prod}},
+			wantCIDRs: 1,
+			wantScans: 2,
+		},
+		{
+			name:      "deleted VPC disappears",
+			retries:   3,
+			listings:  [][]interface{}{{prod, deleted}, {prod}},
+			wantCIDRs: 1,
+			wantScans: 3,
+		},
+		{
+			name:      "empty page mid-listing",
+			retries:   3,
+			listings:  [][]interface{}{{prod}, {}, {prod}, {prod}},
+			wantCIDRs: 1,
+			wantScans: 4,
+		},
+		{
+			name:      "duplicate entry",
+			retries:   3,
+			listings:  [][]interface{}{{prod, prod}, {prod, prod}, {prod}, {prod}},
+			wantCIDRs: 1,
+			wantScans: 4,
+		},
+		{
+			name:        "never stabilizes",
+			retries:     2,
+			listings:    [][]interface{}{{prod}, {prod, deleted}, {prod}, {prod, deleted}},
+			wantCIDRs:   1,
+			wantScans:   3,
+			wantWarning: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int32
+			mux := http.NewServeMux()
+			serveChangingVPCs(mux, &calls, tt.listings...)
+			servePages(mux, "/v2/kubernetes/clusters", "kubernetes_clusters", nil)
+			client := newFakeGodoClient(t, mux)
+
+			got, _, diags := consistentScan(context.Background(), tt.retries, 0, func() ([]existingCIDR, []string, diag.Diagnostics) {
+				return collectExistingCIDRs(context.Background(), client, scanOptions{})
+			})
+			if diags.HasError() {
+				t.Fatalf("consistentScan() diags = %v", diags)
+			}
+			if len(got) != tt.wantCIDRs {
+				t.Errorf("consistentScan() returned %d CIDRs, want %d: %v", len(got), tt.wantCIDRs, got)
+			}
+			if scans := atomic.LoadInt32(&calls); scans != tt.wantScans {
+				t.Errorf("scanned %d times, want %d", scans, tt.wantScans)
+			}
+
+			hasWarning := len(diags) == 1 && diags[0].Severity == diag.Warning && strings.Contains(diags[0].Summary, "did not stabilize")
+			if hasWarning != tt.wantWarning {
+				t.Errorf("diags = %v, wantWarning %v", diags, tt.wantWarning)
+			}
+		})
+	}
+}
+
+func TestConsistentScan_Error(t *testing.T) {
+	var calls int32
+	mux := http.NewServeMux()
+	serveChangingVPCs(mux, &calls, []interface{}{})
+	serveError(mux, "/v2/kubernetes/clusters", http.StatusInternalServerError)
+	client := newFakeGodoClient(t, mux)
+
+	_, _, diags := consistentScan(context.Background(), 3, 0, func() ([]existingCIDR, []string, diag.Diagnostics) {
+		return collectExistingCIDRs(context.Background(), client, scanOptions{})
+	})
+	if !diags.HasError() {
+		t.Fatal("consistentScan() expected error, got none")
+	}
+	if scans := atomic.LoadInt32(&calls); scans != 1 {
+		t.Errorf("scanned %d times, want 1 (errors should not be retried)", scans)
+	}
+}
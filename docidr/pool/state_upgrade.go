@@ -0,0 +1,105 @@
+package pool
+
+import (
+	"context"
+	"regexp"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// resourceDocidrPoolV0 is the docidr_pool schema before arguments with
+// defaults were added, kept to decode state written with it.
+func resourceDocidrPoolV0() *schema.Resource {
+	return &schema.Resource{
+		Schema: map[string]*schema.Schema{
+			"allocation": {
+				Type:     schema.TypeList,
+				Required: true,
+				ForceNew: true,
+				MinItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"name": {
+							Type:     schema.TypeString,
+							Required: true,
+							ForceNew: true,
+							ValidateFunc: validation.StringMatch(
+								regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_]*$`),
+								"must start with a letter and contain only letters, numbers, and underscores",
+							),
+						},
+						"prefix_length": {
+							Type:     schema.TypeInt,
+							Required: true,
+							ForceNew: true,
+						},
+					},
+				},
+			},
+			"base_cidr": {
+				Type:     schema.TypeString,
+				Optional: true,
+				Default:  defaultBaseCIDR,
+				ForceNew: true,
+			},
+			"exclude": {
+				Type:     schema.TypeList,
+				Optional: true,
+				ForceNew: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"cidr": {
+							Type:     schema.TypeString,
+							Required: true,
+							ForceNew: true,
+						},
+						"reason": {
+							Type:     schema.TypeString,
+							Optional: true,
+							ForceNew: true,
+						},
+					},
+				},
+			},
+			"allocations": {
+				Type:     schema.TypeMap,
+				Computed: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+		},
+	}
+}
+
+// upgradePoolStateV0 writes the defaults of the arguments added since version
+// 0 into the state. Without them, the defaults read as changes from null, and
+// as most arguments are ForceNew every existing pool would be replaced.
+func upgradePoolStateV0(_ context.Context, rawState map[string]interface{}, _ interface{}) (map[string]interface{}, error) {
+	if rawState == nil {
+		rawState = map[string]interface{}{}
+	}
+	setStateDefaults(rawState, poolSchema())
+	return rawState, nil
+}
+
+// setStateDefaults sets each attribute of s with a default that is missing
+// or null in state to its default, including those of nested blocks.
+func setStateDefaults(state map[string]interface{}, s map[string]*schema.Schema) {
+	for key, attr := range s {
+		if elem, ok := attr.Elem.(*schema.Resource); ok {
+			blocks, _ := state[key].([]interface{})
+			for _, block := range blocks {
+				if m, ok := block.(map[string]interface{}); ok {
+					setStateDefaults(m, elem.Schema)
+				}
+			}
+			continue
+		}
+		if attr.Default == nil {
+			continue
+		}
+		if v, ok := state[key]; !ok || v == nil {
+			state[key] = attr.Default
+		}
+	}
+}
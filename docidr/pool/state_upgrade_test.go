@@ -0,0 +1,73 @@
+package pool
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+// poolStateV0 is the state of a pool created with schema version 0.
+func poolStateV0() map[string]interface{} {
+	return map[string]interface{}{
+		"id":        "pool-v0",
+		"base_cidr": "10.0.0.0/16",
+		"allocation": []interface{}{
+			map[string]interface{}{"name": "vpc", "prefix_length": 24},
+		},
+		"exclude":     []interface{}{},
+		"allocations": map[string]interface{}{"vpc": "10.0.0.0/24"},
+	}
+}
+
+func TestUpgradePoolStateV0(t *testing.T) {
+	got, err := upgradePoolStateV0(context.Background(), poolStateV0(), nil)
+	if err != nil {
+		t.Fatalf("upgradePoolStateV0() error = %v", err)
+	}
+
+	want := map[string]interface{}{
+		"sort_strategy":                SortStrategyDeclaration,
+		"scan_reserved_ips":            false,
+		"warn_on_existing_cidr_errors": true,
+		"oversize_warning_threshold":   0.5,
+		"allocation_count_limit":       defaultAllocationCountLimit,
+		"base_cidr":                    "10.0.0.0/16",
+	}
+	for key, value := range want {
+		if got[key] != value {
+			t.Errorf("upgraded %s = %#v, want %#v", key, got[key], value)
+		}
+	}
+}
+
+func TestResourceDocidrPool_UpgradedStateNotReplaced(t *testing.T) {
+	r := ResourceDocidrPool()
+	upgraded, err := r.StateUpgraders[0].Upgrade(context.Background(), poolStateV0(), nil)
+	if err != nil {
+		t.Fatalf("Upgrade() error = %v", err)
+	}
+	value, err := schema.JSONMapToStateValue(upgraded, r.CoreConfigSchema())
+	if err != nil {
+		t.Fatalf("JSONMapToStateValue() error = %v", err)
+	}
+	state, err := r.ShimInstanceStateFromValue(value)
+	if err != nil {
+		t.Fatalf("ShimInstanceStateFromValue() error = %v", err)
+	}
+
+	// The configuration the pool was created with
+	diff, err := r.Diff(context.Background(), state, terraform.NewResourceConfigRaw(map[string]interface{}{
+		"base_cidr": "10.0.0.0/16",
+		"allocation": []interface{}{
+			map[string]interface{}{"name": "vpc", "prefix_length": 24},
+		},
+	}), nil)
+	if err != nil {
+		t.Fatalf("Diff() error = %v", err)
+	}
+	if diff.RequiresNew() {
+		t.Errorf("Diff() = %#v, want the upgraded pool kept", diff.Attributes)
+	}
+}
@@ -0,0 +1,80 @@
+package pool
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+)
+
+// telemetryTimeout bounds how long sending a usage report may delay an apply.
+const telemetryTimeout = 5 * time.Second
+
+// telemetryClient sends usage reports. It doesn't retry, since a lost report
+// doesn't matter.
+var telemetryClient = &http.Client{Timeout: telemetryTimeout}
+
+// TelemetryConfig is a pool's telemetry block.
+type TelemetryConfig struct {
+	Enabled  bool
+	Endpoint string
+}
+
+// TelemetryPayload is the usage report sent after a pool is allocated. It
+// deliberately holds no allocation names or CIDRs.
+type TelemetryPayload struct {
+	ProviderVersion      string `json:"provider_version"`
+	AllocationCount      int    `json:"allocation_count"`
+	BaseCIDRPrefixLength int    `json:"base_cidr_prefix_length"`
+	Strategy             string `json:"strategy"`
+	DurationMS           int64  `json:"duration_ms"`
+}
+
+// expandTelemetryConfig converts the telemetry block from the resource
+// configuration. Telemetry is disabled without one.
+func expandTelemetryConfig(raw []interface{}) TelemetryConfig {
+	if len(raw) == 0 || raw[0] == nil {
+		return TelemetryConfig{}
+	}
+	m := raw[0].(map[string]interface{})
+	return TelemetryConfig{
+		Enabled:  m["enabled"].(bool),
+		Endpoint: m["endpoint"].(string),
+	}
+}
+
+// sendTelemetry posts payload as JSON to the configured endpoint if telemetry
+// is enabled. Failures are only logged, so telemetry never fails an apply, and
+// the request is abandoned when ctx is canceled.
+func sendTelemetry(ctx context.Context, config TelemetryConfig, payload TelemetryPayload) {
+	if !config.Enabled || config.Endpoint == "" {
+		return
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("[DEBUG] Error encoding telemetry: %s", err)
+		return
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, config.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		log.Printf("[DEBUG] Error creating telemetry request: %s", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := telemetryClient.Do(req)
+	if err != nil {
+		log.Printf("[DEBUG] Error sending telemetry to %s: %s", config.Endpoint, err)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		log.Printf("[DEBUG] Error sending telemetry to %s: unexpected status %s", config.Endpoint, resp.Status)
+		return
+	}
+	log.Printf("[DEBUG] Sent telemetry to %s", config.Endpoint)
+}
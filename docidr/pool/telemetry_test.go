@@ -0,0 +1,171 @@
+package pool
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/DO-Solutions/terraform-provider-docidr/docidr/config"
+)
+
+// telemetryRecorder is a telemetry endpoint that records the bodies posted to
+// it.
+type telemetryRecorder struct {
+	mu     sync.Mutex
+	bodies []string
+	status int
+}
+
+func newTelemetryServer(t *testing.T, status int) (*httptest.Server, *telemetryRecorder) {
+	t.Helper()
+
+	rec := &telemetryRecorder{status: status}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("telemetry request = %s with Content-Type %q, want a JSON POST", r.Method, r.Header.Get("Content-Type"))
+		}
+		body, _ := io.ReadAll(r.Body)
+		rec.mu.Lock()
+		rec.bodies = append(rec.bodies, string(body))
+		rec.mu.Unlock()
+		w.WriteHeader(rec.status)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, rec
+}
+
+func (r *telemetryRecorder) Bodies() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string{}, r.bodies...)
+}
+
+func TestSendTelemetry(t *testing.T) {
+	srv, rec := newTelemetryServer(t, http.StatusNoContent)
+
+	sendTelemetry(context.Background(), TelemetryConfig{Enabled: true, Endpoint: srv.URL}, TelemetryPayload{
+		ProviderVersion:      "1.2.3",
+		AllocationCount:      3,
+		BaseCIDRPrefixLength: 8,
+		Strategy:             "first_fit",
+		DurationMS:           42,
+	})
+
+	bodies := rec.Bodies()
+	if len(bodies) != 1 {
+		t.Fatalf("telemetry requests = %d, want 1", len(bodies))
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal([]byte(bodies[0]), &got); err != nil {
+		t.Fatalf("telemetry body %q is not JSON: %v", bodies[0], err)
+	}
+	want := map[string]interface{}{
+		"provider_version":        "1.2.3",
+		"allocation_count":        float64(3),
+		"base_cidr_prefix_length": float64(8),
+		"strategy":                "first_fit",
+		"duration_ms":             float64(42),
+	}
+	if len(got) != len(want) {
+		t.Errorf("telemetry body = %v, want exactly %v", got, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("telemetry %s = %v, want %v", k, got[k], v)
+		}
+	}
+}
+
+func TestSendTelemetry_Disabled(t *testing.T) {
+	srv, rec := newTelemetryServer(t, http.StatusOK)
+
+	sendTelemetry(context.Background(), TelemetryConfig{Enabled: false, Endpoint: srv.URL}, TelemetryPayload{AllocationCount: 1})
+	sendTelemetry(context.Background(), TelemetryConfig{Enabled: true}, TelemetryPayload{AllocationCount: 1})
+
+	if bodies := rec.Bodies(); len(bodies) != 0 {
+		t.Errorf("telemetry requests = %v, want none", bodies)
+	}
+}
+
+func TestSendTelemetry_Failures(t *testing.T) {
+	srv, rec := newTelemetryServer(t, http.StatusInternalServerError)
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	// Neither may panic or block; failures are only logged
+	sendTelemetry(context.Background(), TelemetryConfig{Enabled: true, Endpoint: srv.URL}, TelemetryPayload{})
+	sendTelemetry(context.Background(), TelemetryConfig{Enabled: true, Endpoint: closed.URL}, TelemetryPayload{})
+
+	if bodies := rec.Bodies(); len(bodies) != 1 {
+		t.Errorf("telemetry requests = %d, want 1", len(bodies))
+	}
+	if telemetryClient.Timeout != telemetryTimeout {
+		t.Errorf("telemetry timeout = %s, want %s", telemetryClient.Timeout, telemetryTimeout)
+	}
+}
+
+func TestResourceDocidrPool_Telemetry(t *testing.T) {
+	telemetry, rec := newTelemetryServer(t, http.StatusOK)
+	srv := httptest.NewServer(newFakeAccountMux([]interface{}{}, []interface{}{}))
+	t.Cleanup(srv.Close)
+	meta := newTestCombinedConfig(t, &config.Config{APIEndpoint: srv.URL + "/", ProviderVersion: "1.2.3"})
+
+	state := applyPool(t, nil, map[string]interface{}{
+		"base_cidr": "10.0.0.0/12",
+		"placement": "random",
+		"allocation": []interface{}{
+			map[string]interface{}{"name": "secret_vpc", "prefix_length": 16},
+			map[string]interface{}{"name": "secret_k8s", "prefix_length": 20},
+		},
+		"telemetry": []interface{}{
+			map[string]interface{}{"enabled": true, "endpoint": telemetry.URL},
+		},
+	}, meta)
+
+	bodies := rec.Bodies()
+	if len(bodies) != 1 {
+		t.Fatalf("telemetry requests = %d, want 1", len(bodies))
+	}
+	var got TelemetryPayload
+	if err := json.Unmarshal([]byte(bodies[0]), &got); err != nil {
+		t.Fatalf("telemetry body %q is not JSON: %v", bodies[0], err)
+	}
+	if got.ProviderVersion != "1.2.3" || got.AllocationCount != 2 || got.BaseCIDRPrefixLength != 12 || got.Strategy != "random" || got.DurationMS < 0 {
+		t.Errorf("telemetry = %+v, want version 1.2.3, 2 allocations from a /12 with random placement", got)
+	}
+
+	// Nothing identifying the pool's allocations is sent
+	for _, secret := range []string{"secret", "10.", state.Attributes["allocations.secret_vpc"]} {
+		if strings.Contains(bodies[0], secret) {
+			t.Errorf("telemetry body %q contains %q", bodies[0], secret)
+		}
+	}
+
+	// Without the block, nothing is sent
+	applyPool(t, nil, map[string]interface{}{
+		"base_cidr": "10.16.0.0/12",
+		"allocation": []interface{}{
+			map[string]interface{}{"name": "vpc", "prefix_length": 16},
+		},
+	}, newTestCombinedConfig(t, &config.Config{APIEndpoint: srv.URL + "/"}))
+	if bodies := rec.Bodies(); len(bodies) != 1 {
+		t.Errorf("telemetry requests = %d after a pool without telemetry, want 1", len(bodies))
+	}
+}
+
+func TestSendTelemetry_Canceled(t *testing.T) {
+	srv, rec := newTelemetryServer(t, http.StatusOK)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	sendTelemetry(ctx, TelemetryConfig{Enabled: true, Endpoint: srv.URL}, TelemetryPayload{AllocationCount: 1})
+
+	if bodies := rec.Bodies(); len(bodies) != 0 {
+		t.Errorf("telemetry requests after cancel = %v, want none", bodies)
+	}
+}
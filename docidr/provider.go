@@ -2,13 +2,29 @@ package docidr
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
 
+	"github.com/DO-Solutions/terraform-provider-docidr/docidr/cidr"
 	"github.com/DO-Solutions/terraform-provider-docidr/docidr/config"
+	"github.com/DO-Solutions/terraform-provider-docidr/docidr/datasources"
 	"github.com/DO-Solutions/terraform-provider-docidr/docidr/pool"
+	"github.com/digitalocean/godo"
+	"github.com/hashicorp/go-cty/cty"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
 )
 
+// Version is the release version of the provider, set by main from its build
+// flags.
+var Version = "dev"
+
 // Provider returns the docidr Terraform provider.
 func Provider() *schema.Provider {
 	p := &schema.Provider{
@@ -22,6 +38,17 @@ func Provider() *schema.Provider {
 				}, nil),
 				Description: "The token key for API operations.",
 			},
+			"use_doctl_config": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "Whether to use the token of doctl's current auth context when token isn't otherwise set.",
+			},
+			"doctl_config_path": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "The path of the doctl config read by use_doctl_config. Defaults to config.yaml in doctl's configuration directory.",
+			},
 			"api_endpoint": {
 				Type:        schema.TypeString,
 				Optional:    true,
@@ -46,13 +73,149 @@ func Provider() *schema.Provider {
 				Default:     30.0,
 				Description: "The maximum wait time (in seconds) between failed API requests.",
 			},
+			"http_timeout": {
+				Type:        schema.TypeFloat,
+				Optional:    true,
+				Default:     60.0,
+				Description: "The timeout (in seconds) for each HTTP request attempt, to the DigitalOcean API and to the other URLs the provider fetches, except ipam_source, which has a timeout of its own. Set to 0 to disable.",
+			},
+			"api_page_size": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      config.DefaultAPIPageSize,
+				ValidateFunc: validation.IntBetween(1, config.DefaultAPIPageSize),
+				Description:  "The number of items to request per page when listing resources from the DigitalOcean API.",
+			},
+			"headers": {
+				Type:             schema.TypeMap,
+				Optional:         true,
+				Sensitive:        true,
+				ValidateDiagFunc: validateHeaders,
+				Description:      "Additional HTTP headers to send with every API request, such as those required by a gateway in front of api_endpoint.",
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"validate_credentials": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     true,
+				Description: "Whether to check the token against the DigitalOcean API when the provider is configured, so a rejected token fails immediately.",
+			},
+			"exclusion_urls": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				Description: "URLs of remote exclusion lists (plain text or JSON array of CIDRs) merged into every pool's exclusions.",
+				Elem: &schema.Schema{
+					Type:         schema.TypeString,
+					ValidateFunc: validation.IsURLWithHTTPorHTTPS,
+				},
+			},
+			"on_exclusion_source_error": {
+				Type:     schema.TypeString,
+				Optional: true,
+				Default:  config.ExclusionSourceErrorFail,
+				ValidateFunc: validation.StringInSlice([]string{
+					config.ExclusionSourceErrorFail,
+					config.ExclusionSourceErrorWarn,
+				}, false),
+				Description: "Behavior when a remote exclusion source cannot be fetched or parsed: `error` fails the operation, `warn` emits a warning and continues.",
+			},
+			"ipam_source": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				MaxItems:    1,
+				Description: "An IPAM system whose prefixes are merged into every pool's exclusions, so space allocated there is avoided before it is used in DigitalOcean.",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"type": {
+							Type:         schema.TypeString,
+							Required:     true,
+							ValidateFunc: validation.StringInSlice([]string{config.IPAMSourceNetbox}, false),
+							Description:  "The IPAM system: netbox.",
+						},
+						"url": {
+							Type:         schema.TypeString,
+							Required:     true,
+							ValidateFunc: validation.IsURLWithHTTPorHTTPS,
+							Description:  "The root URL of the IPAM system.",
+						},
+						"token": {
+							Type:        schema.TypeString,
+							Required:    true,
+							Sensitive:   true,
+							Description: "The API token to read prefixes with.",
+						},
+						"filter": {
+							Type:        schema.TypeMap,
+							Optional:    true,
+							Description: "Query parameters that filter the prefixes read, such as { status = \"active\", vrf_id = \"3\" }.",
+							Elem: &schema.Schema{
+								Type: schema.TypeString,
+							},
+						},
+						"timeout": {
+							Type:         schema.TypeFloat,
+							Optional:     true,
+							Default:      30.0,
+							ValidateFunc: validation.FloatAtLeast(0),
+							Description:  "The timeout (in seconds) for each request attempt. Set to 0 to disable.",
+						},
+					},
+				},
+			},
+			"app_platform_ranges_url": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				DefaultFunc: schema.EnvDefaultFunc("DOCIDR_APP_PLATFORM_RANGES_URL", ""),
+				ValidateFunc: validation.Any(
+					validation.StringIsEmpty,
+					validation.IsURLWithHTTPorHTTPS,
+				),
+				Description: "URL of the App Platform internal network ranges document used by pools with include_app_platform_ranges.",
+			},
+			"honor_env_exclusions": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     true,
+				Description: "Whether to apply the comma-separated CIDRs in the " + envExcludeVar + " environment variable as exclusions for every pool.",
+			},
+			"size_presets": {
+				Type:             schema.TypeMap,
+				Optional:         true,
+				ValidateDiagFunc: validateSizePresets,
+				Description:      "Named prefix lengths, such as { small = 24, large = 16 }, that allocations can request by name with size instead of prefix_length.",
+				Elem: &schema.Schema{
+					Type: schema.TypeInt,
+				},
+			},
+			"compute_allocations_at_plan_time": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "Whether new pools query the DigitalOcean API during plan so that their allocations are shown in the plan instead of being known after apply.",
+			},
 		},
 
 		ResourcesMap: map[string]*schema.Resource{
 			"docidr_pool": pool.ResourceDocidrPool(),
 		},
 
-		DataSourcesMap: map[string]*schema.Resource{},
+		DataSourcesMap: map[string]*schema.Resource{
+			"docidr_vpc_lookup":          pool.DataSourceDocidrVPCLookup(),
+			"docidr_doks_subnets":        pool.DataSourceDocidrDOKSSubnets(),
+			"docidr_rfc1918_free":        pool.DataSourceDocidrRFC1918Free(),
+			"docidr_account_utilization": pool.DataSourceDocidrAccountUtilization(),
+			"docidr_pool_plan":           pool.DataSourceDocidrPoolPlan(),
+			"docidr_supernet":            datasources.DataSourceDocidrSupernet(),
+			"docidr_ip_range":            datasources.DataSourceDocidrIPRange(),
+			"docidr_conflicts":           datasources.DataSourceDocidrConflicts(),
+			"docidr_usable_hosts":        datasources.DataSourceDocidrUsableHosts(),
+			"docidr_cidr_calculator":     datasources.DataSourceDocidrCIDRCalculator(),
+			"docidr_ula":                 datasources.DataSourceDocidrULA(),
+			"docidr_parse":               datasources.DataSourceDocidrParse(),
+			"docidr_ip_range_to_cidrs":   datasources.DataSourceDocidrIPRangeToCIDRs(),
+		},
 	}
 
 	p.ConfigureContextFunc = providerConfigure(p)
@@ -60,26 +223,211 @@ func Provider() *schema.Provider {
 	return p
 }
 
+// envExcludeVar is the environment variable holding ad-hoc exclusions.
+const envExcludeVar = "DOCIDR_EXCLUDE"
+
+// envExclusions parses the comma-separated CIDRs in the DOCIDR_EXCLUDE
+// environment variable. Empty entries are ignored.
+func envExclusions() ([]*net.IPNet, error) {
+	var result []*net.IPNet
+	for _, entry := range strings.Split(os.Getenv(envExcludeVar), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		network, err := cidr.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid entry %q in %s: %w", entry, envExcludeVar, err)
+		}
+		result = append(result, network)
+	}
+	return result, nil
+}
+
+// validateHeaders rejects custom headers that would replace those set by the
+// client itself.
+func validateHeaders(v interface{}, path cty.Path) diag.Diagnostics {
+	var diags diag.Diagnostics
+	for name := range v.(map[string]interface{}) {
+		for _, reserved := range config.ReservedHeaders {
+			if strings.EqualFold(name, reserved) {
+				diags = append(diags, diag.Diagnostic{
+					Severity:      diag.Error,
+					Summary:       "Reserved header",
+					Detail:        fmt.Sprintf("The %s header is set by the provider and can't be set in headers.", reserved),
+					AttributePath: path,
+				})
+			}
+		}
+	}
+	return diags
+}
+
+// validateSizePresets rejects size presets whose prefix length can't be used
+// for an allocation.
+func validateSizePresets(v interface{}, path cty.Path) diag.Diagnostics {
+	var diags diag.Diagnostics
+	for name, raw := range v.(map[string]interface{}) {
+		prefixLength, ok := raw.(int)
+		if !ok {
+			// Values not known yet are checked once they are
+			continue
+		}
+		if prefixLength < 16 || prefixLength > 28 {
+			diags = append(diags, diag.Diagnostic{
+				Severity:      diag.Error,
+				Summary:       "Invalid size preset",
+				Detail:        fmt.Sprintf("Size preset %q has prefix length %d. Valid range: 16-28.", name, prefixLength),
+				AttributePath: path,
+			})
+		}
+	}
+	return diags
+}
+
+// expandSizePresets converts the size_presets map to a map of prefix lengths.
+func expandSizePresets(raw map[string]interface{}) map[string]int {
+	result := make(map[string]int, len(raw))
+	for name, prefixLength := range raw {
+		result[name] = prefixLength.(int)
+	}
+	return result
+}
+
+// expandIPAMSource converts the ipam_source block, returning nil without one.
+func expandIPAMSource(raw []interface{}) *config.IPAMSource {
+	if len(raw) == 0 || raw[0] == nil {
+		return nil
+	}
+	m := raw[0].(map[string]interface{})
+	filter := make(map[string]string)
+	for key, value := range m["filter"].(map[string]interface{}) {
+		filter[key] = value.(string)
+	}
+	return &config.IPAMSource{
+		Type:    m["type"].(string),
+		URL:     strings.TrimSuffix(m["url"].(string), "/"),
+		Token:   m["token"].(string),
+		Filter:  filter,
+		Timeout: m["timeout"].(float64),
+	}
+}
+
+// expandHeaders converts the headers map to a map of strings.
+func expandHeaders(raw map[string]interface{}) map[string]string {
+	result := make(map[string]string, len(raw))
+	for name, value := range raw {
+		result[name] = value.(string)
+	}
+	return result
+}
+
 func providerConfigure(p *schema.Provider) schema.ConfigureContextFunc {
 	return func(ctx context.Context, d *schema.ResourceData) (interface{}, diag.Diagnostics) {
-		config := &config.Config{
-			Token:            d.Get("token").(string),
-			APIEndpoint:      d.Get("api_endpoint").(string),
-			HTTPRetryMax:     d.Get("http_retry_max").(int),
-			HTTPRetryWaitMin: d.Get("http_retry_wait_min").(float64),
-			HTTPRetryWaitMax: d.Get("http_retry_wait_max").(float64),
-			TerraformVersion: p.TerraformVersion,
+		var exclusionURLs []string
+		for _, u := range d.Get("exclusion_urls").([]interface{}) {
+			exclusionURLs = append(exclusionURLs, u.(string))
 		}
 
-		if config.Token == "" {
-			return nil, diag.Errorf("DigitalOcean token must be configured. Set the token in the provider configuration or use the DIGITALOCEAN_TOKEN environment variable.")
+		var exclusions []*net.IPNet
+		if d.Get("honor_env_exclusions").(bool) {
+			var err error
+			exclusions, err = envExclusions()
+			if err != nil {
+				return nil, diag.FromErr(err)
+			}
+			for _, network := range exclusions {
+				log.Printf("[DEBUG] Applying exclusion %s from %s", network.String(), envExcludeVar)
+			}
+		}
+
+		token := d.Get("token").(string)
+		if token == "" && d.Get("use_doctl_config").(bool) {
+			path := d.Get("doctl_config_path").(string)
+			if path == "" {
+				var err error
+				if path, err = config.DefaultDoctlConfigPath(); err != nil {
+					return nil, diag.FromErr(err)
+				}
+			}
+			var err error
+			if token, err = config.DoctlToken(path); err != nil {
+				return nil, diag.FromErr(err)
+			}
+			log.Printf("[DEBUG] Using the DigitalOcean token from doctl config %s", path)
+		}
+
+		config := &config.Config{
+			Token:                  token,
+			APIEndpoint:            d.Get("api_endpoint").(string),
+			HTTPRetryMax:           d.Get("http_retry_max").(int),
+			HTTPRetryWaitMin:       d.Get("http_retry_wait_min").(float64),
+			HTTPRetryWaitMax:       d.Get("http_retry_wait_max").(float64),
+			HTTPTimeout:            d.Get("http_timeout").(float64),
+			APIPageSize:            d.Get("api_page_size").(int),
+			Headers:                expandHeaders(d.Get("headers").(map[string]interface{})),
+			SizePresets:            expandSizePresets(d.Get("size_presets").(map[string]interface{})),
+			ExclusionURLs:          exclusionURLs,
+			OnExclusionSourceError: d.Get("on_exclusion_source_error").(string),
+			AppPlatformRangesURL:   d.Get("app_platform_ranges_url").(string),
+			EnvExclusions:          exclusions,
+			IPAMSource:             expandIPAMSource(d.Get("ipam_source").([]interface{})),
+			TerraformVersion:       p.TerraformVersion,
+			ProviderVersion:        Version,
+
+			ComputeAllocationsAtPlanTime: d.Get("compute_allocations_at_plan_time").(bool),
 		}
 
+		// A missing token fails the first request to the API instead, so
+		// that data sources and pools that don't query it work without one
 		client, err := config.Client()
 		if err != nil {
 			return nil, diag.FromErr(err)
 		}
 
-		return client, nil
+		// Without a token there is nothing to check, and air-gapped
+		// configurations, whose pools set skip_api_query, don't query the API
+		var diags diag.Diagnostics
+		if d.Get("validate_credentials").(bool) {
+			if config.Token == "" {
+				log.Printf("[DEBUG] Not validating credentials: no DigitalOcean token is configured")
+				return client, nil
+			}
+			diags = validateCredentials(ctx, client)
+			if diags.HasError() {
+				return nil, diags
+			}
+		}
+
+		return client, diags
 	}
 }
+
+// validateCredentials checks the token by fetching the account it belongs to.
+// A rejected token is an error. Other failures are warnings, so that planning
+// still works when the API can't be reached.
+func validateCredentials(ctx context.Context, client *config.CombinedConfig) diag.Diagnostics {
+	err := client.LoadAccount(ctx)
+	if err == nil {
+		log.Printf("[DEBUG] Token belongs to DigitalOcean account %s", client.AccountUUID())
+		return nil
+	}
+
+	host := client.GodoClient().BaseURL.Host
+	var errResp *godo.ErrorResponse
+	if errors.As(err, &errResp) && errResp.Response != nil && errResp.Response.StatusCode == http.StatusUnauthorized {
+		return diag.Diagnostics{{
+			Severity: diag.Error,
+			Summary:  fmt.Sprintf("token rejected by %s; check DIGITALOCEAN_TOKEN", host),
+			Detail:   fmt.Sprintf("The DigitalOcean token in the provider configuration or environment is not valid: %s", err),
+		}}
+	}
+
+	log.Printf("[WARN] Unable to validate DigitalOcean credentials: %v", err)
+	return diag.Diagnostics{{
+		Severity: diag.Warning,
+		Summary:  "Unable to validate DigitalOcean credentials",
+		Detail:   fmt.Sprintf("The token could not be checked against %s: %s. Operations that query the API may fail.", host, err),
+	}}
+}
@@ -1,9 +1,19 @@
 package docidr
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/DO-Solutions/terraform-provider-docidr/docidr/config"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
 )
 
 func TestProvider(t *testing.T) {
@@ -35,6 +45,32 @@ func TestProvider_HasRequiredResources(t *testing.T) {
 	}
 }
 
+func TestProvider_HasRequiredDataSources(t *testing.T) {
+	p := Provider()
+
+	expectedDataSources := []string{
+		"docidr_vpc_lookup",
+		"docidr_doks_subnets",
+		"docidr_rfc1918_free",
+		"docidr_supernet",
+		"docidr_ip_range",
+		"docidr_conflicts",
+		"docidr_account_utilization",
+		"docidr_usable_hosts",
+		"docidr_cidr_calculator",
+		"docidr_ula",
+		"docidr_parse",
+		"docidr_ip_range_to_cidrs",
+		"docidr_pool_plan",
+	}
+
+	for _, name := range expectedDataSources {
+		if _, ok := p.DataSourcesMap[name]; !ok {
+			t.Errorf("Provider missing expected data source: %s", name)
+		}
+	}
+}
+
 func TestProvider_Schema(t *testing.T) {
 	p := Provider()
 
@@ -44,6 +80,19 @@ func TestProvider_Schema(t *testing.T) {
 		"http_retry_max",
 		"http_retry_wait_min",
 		"http_retry_wait_max",
+		"http_timeout",
+		"validate_credentials",
+		"api_page_size",
+		"headers",
+		"exclusion_urls",
+		"on_exclusion_source_error",
+		"ipam_source",
+		"use_doctl_config",
+		"doctl_config_path",
+		"app_platform_ranges_url",
+		"honor_env_exclusions",
+		"size_presets",
+		"compute_allocations_at_plan_time",
 	}
 
 	for _, key := range expectedSchemaKeys {
@@ -52,3 +101,314 @@ func TestProvider_Schema(t *testing.T) {
 		}
 	}
 }
+
+func TestProvider_ValidateHeaders(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers map[string]interface{}
+		wantErr bool
+	}{
+		{name: "custom", headers: map[string]interface{}{"X-Gateway-Key": "secret", "X-Route": "docidr"}},
+		{name: "authorization", headers: map[string]interface{}{"Authorization": "Bearer other"}, wantErr: true},
+		{name: "authorization lowercase", headers: map[string]interface{}{"authorization": "Bearer other"}, wantErr: true},
+		{name: "user agent", headers: map[string]interface{}{"X-Route": "docidr", "USER-AGENT": "curl"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diags := validateHeaders(tt.headers, nil)
+			if diags.HasError() != tt.wantErr {
+				t.Errorf("validateHeaders() diags = %v, wantErr %v", diags, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestProvider_SizePresets(t *testing.T) {
+	p := Provider()
+	diags := p.Configure(context.Background(), terraform.NewResourceConfigRaw(map[string]interface{}{
+		"token":                "test-token",
+		"validate_credentials": false,
+		"size_presets":         map[string]interface{}{"small": 24, "medium": 20, "large": 16},
+	}))
+	if diags.HasError() {
+		t.Fatalf("Configure() diags = %v", diags)
+	}
+
+	got := p.Meta().(*config.CombinedConfig).SizePresets()
+	want := map[string]int{"small": 24, "medium": 20, "large": 16}
+	if len(got) != len(want) {
+		t.Fatalf("SizePresets() = %v, want %v", got, want)
+	}
+	for name, prefixLength := range want {
+		if got[name] != prefixLength {
+			t.Errorf("SizePresets()[%q] = %d, want %d", name, got[name], prefixLength)
+		}
+	}
+}
+
+func TestProvider_ValidateSizePresets(t *testing.T) {
+	tests := []struct {
+		name    string
+		presets map[string]interface{}
+		wantErr bool
+	}{
+		{name: "valid", presets: map[string]interface{}{"small": 24, "large": 16}},
+		{name: "too large", presets: map[string]interface{}{"huge": 8}, wantErr: true},
+		{name: "too small", presets: map[string]interface{}{"tiny": 29}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diags := validateSizePresets(tt.presets, nil)
+			if diags.HasError() != tt.wantErr {
+				t.Errorf("validateSizePresets() diags = %v, wantErr %v", diags, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestProvider_EnvExclusions(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     string
+		honor   bool
+		want    []string
+		wantErr string
+	}{
+		{
+			name:  "comma separated with whitespace",
+			env:   "10.0.0.0/16, 172.16.0.0/12,,",
+			honor: true,
+			want:  []string{"10.0.0.0/16", "172.16.0.0/12"},
+		},
+		{
+			name:  "unset",
+			env:   "",
+			honor: true,
+			want:  nil,
+		},
+		{
+			name:  "opted out",
+			env:   "10.0.0.0/16",
+			honor: false,
+			want:  nil,
+		},
+		{
+			name:    "invalid entry",
+			env:     "10.0.0.0/16,10.1.0.0/33",
+			honor:   true,
+			wantErr: `"10.1.0.0/33"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("DOCIDR_EXCLUDE", tt.env)
+
+			p := Provider()
+			diags := p.Configure(context.Background(), terraform.NewResourceConfigRaw(map[string]interface{}{
+				"token":                "test-token",
+				"honor_env_exclusions": tt.honor,
+				"validate_credentials": false,
+			}))
+
+			if tt.wantErr != "" {
+				if !diags.HasError() {
+					t.Fatal("Configure() expected error, got none")
+				}
+				if !strings.Contains(diags[0].Summary, tt.wantErr) {
+					t.Errorf("Configure() error = %q, want it to contain %q", diags[0].Summary, tt.wantErr)
+				}
+				return
+			}
+			if diags.HasError() {
+				t.Fatalf("Configure() diags = %v", diags)
+			}
+
+			got := p.Meta().(*config.CombinedConfig).EnvExclusions()
+			if len(got) != len(tt.want) {
+				t.Fatalf("EnvExclusions() returned %d networks, want %d", len(got), len(tt.want))
+			}
+			for i, want := range tt.want {
+				if got[i].String() != want {
+					t.Errorf("EnvExclusions()[%d] = %s, want %s", i, got[i].String(), want)
+				}
+			}
+		})
+	}
+}
+
+func TestProvider_ValidateCredentials(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		wantErr     string
+		wantWarning bool
+		wantAccount string
+	}{
+		{name: "valid", status: http.StatusOK, wantAccount: "acct-1"},
+		{name: "rejected", status: http.StatusUnauthorized, wantErr: "token rejected by"},
+		{name: "unavailable", status: http.StatusInternalServerError, wantWarning: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/v2/account" {
+					t.Errorf("unexpected request to %s", r.URL.Path)
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(tt.status)
+				if tt.status == http.StatusOK {
+					fmt.Fprint(w, `{"account": {"uuid": "acct-1", "status": "active"}}`)
+					return
+				}
+				fmt.Fprint(w, `{"id": "error", "message": "fake error"}`)
+			}))
+			t.Cleanup(srv.Close)
+
+			p := Provider()
+			diags := p.Configure(context.Background(), terraform.NewResourceConfigRaw(map[string]interface{}{
+				"token":                "test-token",
+				"api_endpoint":         srv.URL,
+				"http_retry_max":       0,
+				"validate_credentials": true,
+			}))
+
+			if tt.wantErr != "" {
+				if !diags.HasError() {
+					t.Fatal("Configure() expected error, got none")
+				}
+				if !strings.Contains(diags[0].Summary, tt.wantErr) {
+					t.Errorf("Configure() error = %q, want it to contain %q", diags[0].Summary, tt.wantErr)
+				}
+				return
+			}
+			if diags.HasError() {
+				t.Fatalf("Configure() diags = %v", diags)
+			}
+			if got := len(diags) > 0 && diags[0].Severity == diag.Warning; got != tt.wantWarning {
+				t.Errorf("Configure() diags = %v, want warning %v", diags, tt.wantWarning)
+			}
+			if got := p.Meta().(*config.CombinedConfig).AccountUUID(); got != tt.wantAccount {
+				t.Errorf("AccountUUID() = %q, want %q", got, tt.wantAccount)
+			}
+		})
+	}
+}
+
+func TestProvider_ValidateCredentialsDefault(t *testing.T) {
+	var requested bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = r.URL.Path == "/v2/account"
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"account": {"uuid": "acct-1", "status": "active"}}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	p := Provider()
+	diags := p.Configure(context.Background(), terraform.NewResourceConfigRaw(map[string]interface{}{
+		"token":          "test-token",
+		"api_endpoint":   srv.URL,
+		"http_retry_max": 0,
+	}))
+	if len(diags) > 0 {
+		t.Errorf("Configure() diags = %v, want none", diags)
+	}
+	if !requested {
+		t.Error("Configure() didn't validate the token by default")
+	}
+
+	// An unreachable API only warns, so planning continues offline
+	srv.Close()
+	p = Provider()
+	diags = p.Configure(context.Background(), terraform.NewResourceConfigRaw(map[string]interface{}{
+		"token":          "test-token",
+		"api_endpoint":   srv.URL,
+		"http_retry_max": 0,
+	}))
+	if diags.HasError() || len(diags) == 0 {
+		t.Errorf("Configure() with an unreachable API diags = %v, want a warning", diags)
+	}
+}
+
+func TestProvider_WithoutToken(t *testing.T) {
+	t.Setenv("DIGITALOCEAN_TOKEN", "")
+	t.Setenv("DIGITALOCEAN_ACCESS_TOKEN", "")
+
+	p := Provider()
+	if diags := p.Configure(context.Background(), terraform.NewResourceConfigRaw(map[string]interface{}{})); diags.HasError() {
+		t.Fatalf("Configure() diags = %v", diags)
+	}
+
+	// Credentials aren't validated without a token
+	p = Provider()
+	if diags := p.Configure(context.Background(), terraform.NewResourceConfigRaw(map[string]interface{}{"validate_credentials": true})); len(diags) > 0 {
+		t.Fatalf("Configure() with validate_credentials diags = %v, want none", diags)
+	}
+
+	// Only requests to the API fail, without being retried
+	start := time.Now()
+	_, _, err := p.Meta().(*config.CombinedConfig).GodoClient().Account.Get(context.Background())
+	if !errors.Is(err, config.ErrMissingToken) {
+		t.Errorf("Account.Get() error = %v, want %v", err, config.ErrMissingToken)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Account.Get() took %s, want it to fail without retrying", elapsed)
+	}
+}
+
+func TestProvider_UseDoctlConfig(t *testing.T) {
+	t.Setenv("DIGITALOCEAN_TOKEN", "")
+	t.Setenv("DIGITALOCEAN_ACCESS_TOKEN", "")
+
+	var gotAuth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"account": {"uuid": "acct-1", "status": "active"}}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	p := Provider()
+	diags := p.Configure(context.Background(), terraform.NewResourceConfigRaw(map[string]interface{}{
+		"use_doctl_config":     true,
+		"doctl_config_path":    "config/testdata/doctl/named.yaml",
+		"api_endpoint":         srv.URL,
+		"http_retry_max":       0,
+		"validate_credentials": true,
+	}))
+	if diags.HasError() {
+		t.Fatalf("Configure() diags = %v", diags)
+	}
+	if gotAuth != "Bearer dop_v1_staging_fixture" {
+		t.Errorf("Authorization = %q, want the staging context's token", gotAuth)
+	}
+
+	// An explicit token takes precedence over doctl's
+	p = Provider()
+	diags = p.Configure(context.Background(), terraform.NewResourceConfigRaw(map[string]interface{}{
+		"token":                "test-token",
+		"use_doctl_config":     true,
+		"doctl_config_path":    "config/testdata/doctl/absent.yaml",
+		"api_endpoint":         srv.URL,
+		"http_retry_max":       0,
+		"validate_credentials": true,
+	}))
+	if diags.HasError() {
+		t.Fatalf("Configure() with a token diags = %v", diags)
+	}
+	if gotAuth != "Bearer test-token" {
+		t.Errorf("Authorization = %q, want the configured token", gotAuth)
+	}
+
+	p = Provider()
+	diags = p.Configure(context.Background(), terraform.NewResourceConfigRaw(map[string]interface{}{
+		"use_doctl_config":  true,
+		"doctl_config_path": "config/testdata/doctl/missing_context.yaml",
+	}))
+	if !diags.HasError() || !strings.Contains(diags[0].Summary, `no auth context "staging"`) {
+		t.Errorf("Configure() with a missing context diags = %v", diags)
+	}
+}
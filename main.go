@@ -1,12 +1,59 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"os"
+
 	"github.com/DO-Solutions/terraform-provider-docidr/docidr"
-	"github.com/hashicorp/terraform-plugin-sdk/v2/plugin"
+	"github.com/hashicorp/terraform-plugin-go/tfprotov6/tf6server"
 )
 
+// providerAddr is the registry address the provider is published under.
+// Terraform uses it to match a debug server in TF_REATTACH_PROVIDERS to the
+// provider in a configuration.
+const providerAddr = "registry.terraform.io/DO-Solutions/docidr"
+
+// version is the release version, set at build time with -ldflags.
+var version = "dev"
+
 func main() {
-	plugin.Serve(&plugin.ServeOpts{
-		ProviderFunc: docidr.Provider,
-	})
+	debug, err := parseFlags(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	docidr.Version = version
+	server, err := docidr.ProviderServerFactory(context.Background())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := tf6server.Serve(providerAddr, server, serveOpts(debug)...); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// parseFlags parses the command line. With -debug, the provider runs as a
+// long-lived server that a debugger such as delve can be attached to, and
+// prints the TF_REATTACH_PROVIDERS value that points Terraform at it.
+func parseFlags(args []string) (debug bool, err error) {
+	flags := flag.NewFlagSet("terraform-provider-docidr", flag.ContinueOnError)
+	flags.BoolVar(&debug, "debug", false, "start the provider in debug mode, for attaching a debugger such as delve")
+	err = flags.Parse(args)
+	return debug, err
+}
+
+// serveOpts returns the options for serving the provider over protocol 6,
+// which the mux of its SDKv2 and plugin-framework halves speaks.
+func serveOpts(debug bool) []tf6server.ServeOpt {
+	var opts []tf6server.ServeOpt
+	if debug {
+		opts = append(opts, tf6server.WithManagedDebug())
+	}
+	return opts
 }
@@ -0,0 +1,39 @@
+package main
+
+import "testing"
+
+func TestParseFlags(t *testing.T) {
+	tests := []struct {
+		name      string
+		args      []string
+		wantDebug bool
+		wantErr   bool
+	}{
+		{name: "none", args: nil},
+		{name: "debug", args: []string{"-debug"}, wantDebug: true},
+		{name: "debug=true", args: []string{"-debug=true"}, wantDebug: true},
+		{name: "debug=false", args: []string{"-debug=false"}},
+		{name: "unknown", args: []string{"-verbose"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			debug, err := parseFlags(tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseFlags(%v) error = %v, wantErr %v", tt.args, err, tt.wantErr)
+			}
+			if debug != tt.wantDebug {
+				t.Errorf("parseFlags(%v) debug = %v, want %v", tt.args, debug, tt.wantDebug)
+			}
+		})
+	}
+}
+
+func TestServeOpts(t *testing.T) {
+	if opts := serveOpts(true); len(opts) != 1 {
+		t.Errorf("serveOpts(true) = %d options, want the managed debug option", len(opts))
+	}
+	if opts := serveOpts(false); len(opts) != 0 {
+		t.Errorf("serveOpts(false) = %d options, want none", len(opts))
+	}
+}